@@ -10,12 +10,17 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Database DatabaseConfig `json:"database"`
-	Redis    RedisConfig    `json:"redis"`
-	JWT      JWTConfig      `json:"jwt"`
-	Binance  BinanceConfig  `json:"binance"`
-	Security SecurityConfig `json:"security"`
+	Server     ServerConfig     `json:"server"`
+	Database   DatabaseConfig   `json:"database"`
+	Redis      RedisConfig      `json:"redis"`
+	JWT        JWTConfig        `json:"jwt"`
+	Binance    BinanceConfig    `json:"binance"`
+	Exchange   ExchangeConfig   `json:"exchange"`
+	Paper      PaperConfig      `json:"paper"`
+	Market     MarketConfig     `json:"market"`
+	UserStream UserStreamConfig `json:"user_stream"`
+	Risk       RiskConfig       `json:"risk"`
+	Security   SecurityConfig   `json:"security"`
 }
 
 type ServerConfig struct {
@@ -67,6 +72,53 @@ type BinanceConfig struct {
 	GlobalSecretKey string `json:"global_secret_key"`
 }
 
+type ExchangeConfig struct {
+	// 默认使用的交易所实现
+	Default string `json:"default"`
+}
+
+// PaperConfig 模拟交易配置
+type PaperConfig struct {
+	InitialUSDT         float64 `json:"initial_usdt"`           // 模拟账户初始USDT（现货与期货各一份）
+	SpotFeeRate         float64 `json:"spot_fee_rate"`          // 现货手续费率
+	FuturesMakerFeeRate float64 `json:"futures_maker_fee_rate"` // 期货挂单手续费率
+	FuturesTakerFeeRate float64 `json:"futures_taker_fee_rate"` // 期货吃单手续费率
+}
+
+// RiskConfig 风控的默认限额，用户未设置风控策略时使用，0表示不限制
+type RiskConfig struct {
+	MaxOrderNotional    float64 `json:"max_order_notional"`     // 单笔订单最大名义价值
+	MaxSymbolExposure   float64 `json:"max_symbol_exposure"`    // 单个交易对最大持仓敞口
+	MaxPriceDeviationBP int     `json:"max_price_deviation_bp"` // 限价偏离当前价格的最大万分比（仅限立即成交方向）
+	DailyLossLimit      float64 `json:"daily_loss_limit"`       // 当日已实现净亏损上限
+	MaxDrawdownPercent  float64 `json:"max_drawdown_percent"`   // 权益距峰值的最大回撤百分比
+	MaxGrossExposure    float64 `json:"max_gross_exposure"`     // 账户总敞口上限
+	MaxOpenOrders       int     `json:"max_open_orders"`        // 未完成订单数上限
+	FlattenOnBreach     bool    `json:"flatten_on_breach"`      // 触发账户风控后是否平掉期货持仓
+	MonitorSeconds      int     `json:"monitor_seconds"`        // 账户风控检查间隔
+	LiqTopUpDistance    float64 `json:"liq_top_up_distance"`    // 强平距离低于该百分比时追加保证金
+	LiqTargetDistance   float64 `json:"liq_target_distance"`    // 追加保证金后的目标强平距离
+	LiqMaxTopUp         float64 `json:"liq_max_top_up"`         // 单次追加保证金上限
+	LiqTransferFromSpot bool    `json:"liq_transfer_from_spot"` // 期货余额不足时是否从现货划转
+	LiqReduceDistance   float64 `json:"liq_reduce_distance"`    // 强平距离低于该百分比时只减仓
+	LiqReduceRatio      float64 `json:"liq_reduce_ratio"`       // 每次减仓比例
+}
+
+// MarketConfig 行情推送配置
+type MarketConfig struct {
+	StreamEnabled   bool   `json:"stream_enabled"`   // 是否启用WebSocket行情推送
+	KlineInterval   string `json:"kline_interval"`   // 订阅的K线周期
+	DepthLevels     int    `json:"depth_levels"`     // 订阅的深度档位：5、10或20
+	StaleSeconds    int    `json:"stale_seconds"`    // 超过该时间未更新的行情视为失效，回退到REST轮询
+	TriggerInterval int    `json:"trigger_interval"` // 同一交易对两次价格事件触发策略的最小间隔（毫秒）
+}
+
+// UserStreamConfig 用户数据流配置
+type UserStreamConfig struct {
+	Enabled          bool `json:"enabled"`           // 是否启用listenKey用户数据流推送订单成交
+	ReconcileSeconds int  `json:"reconcile_seconds"` // 数据流正常时，超过该时间未更新的挂单才通过REST轮询对账
+}
+
 type SecurityConfig struct {
 	EncryptionKey    string `json:"encryption_key"`
 	PasswordMinLen   int    `json:"password_min_len"`
@@ -125,6 +177,43 @@ func LoadConfig() *Config {
 			GlobalAPIKey:     getEnv("BINANCE_API_KEY", ""),
 			GlobalSecretKey:  getEnv("BINANCE_SECRET_KEY", ""),
 		},
+		Exchange: ExchangeConfig{
+			Default: getEnv("EXCHANGE_DEFAULT", "binance"),
+		},
+		Paper: PaperConfig{
+			InitialUSDT:         getEnvAsFloat("PAPER_INITIAL_USDT", 10000),
+			SpotFeeRate:         getEnvAsFloat("PAPER_SPOT_FEE_RATE", 0.001),
+			FuturesMakerFeeRate: getEnvAsFloat("PAPER_FUTURES_MAKER_FEE_RATE", 0.0002),
+			FuturesTakerFeeRate: getEnvAsFloat("PAPER_FUTURES_TAKER_FEE_RATE", 0.0004),
+		},
+		Market: MarketConfig{
+			StreamEnabled:   getEnvAsBool("MARKET_STREAM_ENABLED", true),
+			KlineInterval:   getEnv("MARKET_KLINE_INTERVAL", "1m"),
+			DepthLevels:     getEnvAsInt("MARKET_DEPTH_LEVELS", 20),
+			StaleSeconds:    getEnvAsInt("MARKET_STALE_SECONDS", 15),
+			TriggerInterval: getEnvAsInt("MARKET_TRIGGER_INTERVAL_MS", 1000),
+		},
+		UserStream: UserStreamConfig{
+			Enabled:          getEnvAsBool("USER_STREAM_ENABLED", true),
+			ReconcileSeconds: getEnvAsInt("ORDER_RECONCILE_SECONDS", 300),
+		},
+		Risk: RiskConfig{
+			MaxOrderNotional:    getEnvAsFloat("RISK_MAX_ORDER_NOTIONAL", 0),
+			MaxSymbolExposure:   getEnvAsFloat("RISK_MAX_SYMBOL_EXPOSURE", 0),
+			MaxPriceDeviationBP: getEnvAsInt("RISK_MAX_PRICE_DEVIATION_BP", 500),
+			DailyLossLimit:      getEnvAsFloat("RISK_DAILY_LOSS_LIMIT", 0),
+			MaxDrawdownPercent:  getEnvAsFloat("RISK_MAX_DRAWDOWN_PERCENT", 0),
+			MaxGrossExposure:    getEnvAsFloat("RISK_MAX_GROSS_EXPOSURE", 0),
+			MaxOpenOrders:       getEnvAsInt("RISK_MAX_OPEN_ORDERS", 0),
+			FlattenOnBreach:     getEnvAsBool("RISK_FLATTEN_ON_BREACH", false),
+			MonitorSeconds:      getEnvAsInt("RISK_MONITOR_SECONDS", 60),
+			LiqTopUpDistance:    getEnvAsFloat("RISK_LIQ_TOP_UP_DISTANCE", 0),
+			LiqTargetDistance:   getEnvAsFloat("RISK_LIQ_TARGET_DISTANCE", 0),
+			LiqMaxTopUp:         getEnvAsFloat("RISK_LIQ_MAX_TOP_UP", 0),
+			LiqTransferFromSpot: getEnvAsBool("RISK_LIQ_TRANSFER_FROM_SPOT", false),
+			LiqReduceDistance:   getEnvAsFloat("RISK_LIQ_REDUCE_DISTANCE", 0),
+			LiqReduceRatio:      getEnvAsFloat("RISK_LIQ_REDUCE_RATIO", 25),
+		},
 		Security: SecurityConfig{
 			EncryptionKey:    "", // Will be set below
 			PasswordMinLen:   getEnvAsInt("PASSWORD_MIN_LEN", 8),
@@ -210,6 +299,15 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 // generateRandomKey 生成指定长度的随机密钥
 func generateRandomKey(length int) (string, error) {
 	bytes := make([]byte, length)
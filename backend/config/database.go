@@ -120,6 +120,22 @@ func AutoMigrate() error {
 		&models.DualInvestmentOrder{},
 		&models.Withdrawal{},
 		&models.WithdrawalHistory{},
+		&models.PaperBalance{},
+		&models.PaperOrder{},
+		&models.PaperSetting{},
+		&models.BacktestRun{},
+		&models.KlineCache{},
+		&models.EquitySnapshot{},
+		&models.OrderList{},
+		&models.BracketOrder{},
+		&models.RiskPolicy{},
+		&models.PreTradeRejection{},
+		&models.RiskBreach{},
+		&models.LiquidationAction{},
+		&models.FundingRate{},
+		&models.FuturesIncome{},
+		&models.FuturesIncomeAllocation{},
+		&models.StrategyStatusHistory{},
 	)
 	if err != nil {
 		return fmt.Errorf("数据库迁移失败: %v", err)
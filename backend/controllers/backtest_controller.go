@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"github.com/ccj241/cctrade/services"
+	"github.com/ccj241/cctrade/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type BacktestController struct {
+	backtestService *services.BacktestService
+}
+
+func NewBacktestController() *BacktestController {
+	return &BacktestController{
+		backtestService: services.NewBacktestService(),
+	}
+}
+
+func (bc *BacktestController) RunBacktest(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+
+	var req services.BacktestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+			return
+		}
+	}
+
+	run, err := bc.backtestService.RunBacktest(userID, uint(strategyID), &req)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, run)
+}
+
+func (bc *BacktestController) GetBacktestRuns(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	runs, total, err := bc.backtestService.GetBacktestRuns(userID, uint(strategyID), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取回测记录失败")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, runs, total, page, limit)
+}
+
+func (bc *BacktestController) GetBacktestRun(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	runID, err := strconv.ParseUint(c.Param("backtest_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "回测ID无效")
+		return
+	}
+
+	run, err := bc.backtestService.GetBacktestRun(userID, uint(runID))
+	if err != nil {
+		utils.NotFoundResponse(c, "回测记录不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, run)
+}
+
+// ImportKlines 上传CSV文件导入K线，表单字段: file、symbol、interval
+func (bc *BacktestController) ImportKlines(c *gin.Context) {
+	symbol := c.PostForm("symbol")
+	interval := c.DefaultPostForm("interval", "1h")
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		utils.BadRequestResponse(c, "请上传CSV文件")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.BadRequestResponse(c, "读取文件失败")
+		return
+	}
+	defer file.Close()
+
+	count, err := bc.backtestService.ImportKlinesCSV(symbol, interval, file)
+	if err != nil {
+		utils.BadRequestResponse(c, "导入K线失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "K线导入成功", gin.H{"imported": count})
+}
@@ -21,15 +21,15 @@ func DiagnoseBinanceAPI(c *gin.Context) {
 		return
 	}
 	
-	// 创建Binance服务
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
+	// 创建交易所服务
+	exchange, err := services.NewExchange(apiKey, secretKey)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+		utils.InternalServerErrorResponse(c, "创建交易所服务失败: "+err.Error())
 		return
 	}
 	
 	// 执行诊断
-	diagnosis, err := binanceService.DiagnoseAPIConnection(c.Request.Context())
+	diagnosis, err := exchange.DiagnoseAPIConnection(c.Request.Context())
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "诊断执行失败: "+err.Error())
 		return
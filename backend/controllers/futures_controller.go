@@ -1,19 +1,23 @@
 package controllers
 
 import (
+	"github.com/ccj241/cctrade/models"
 	"github.com/ccj241/cctrade/services"
 	"github.com/ccj241/cctrade/utils"
 	"github.com/gin-gonic/gin"
 	"strconv"
+	"strings"
 )
 
 type FuturesController struct {
 	futuresService *services.FuturesService
+	fundingService *services.FundingService
 }
 
 func NewFuturesController() *FuturesController {
 	return &FuturesController{
 		futuresService: services.NewFuturesService(),
+		fundingService: services.NewFundingService(),
 	}
 }
 
@@ -47,7 +51,13 @@ func (fc *FuturesController) GetUserFuturesStrategies(c *gin.Context) {
 		limit = 10
 	}
 
-	strategies, total, err := fc.futuresService.GetUserFuturesStrategies(userID, page, limit)
+	status := models.StrategyStatus(c.Query("status"))
+	if status != "" && !status.IsValid() {
+		utils.BadRequestResponse(c, "策略状态无效")
+		return
+	}
+
+	strategies, total, err := fc.futuresService.GetUserFuturesStrategies(userID, status, page, limit)
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "获取期货策略列表失败")
 		return
@@ -74,6 +84,24 @@ func (fc *FuturesController) GetFuturesStrategyByID(c *gin.Context) {
 	utils.SuccessResponse(c, strategy)
 }
 
+// GetCashCarryReport 期现套利策略现货和永续两腿的合并盈亏
+func (fc *FuturesController) GetCashCarryReport(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+
+	report, err := fc.futuresService.GetCashCarryReport(userID, uint(strategyID))
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, report)
+}
+
 func (fc *FuturesController) UpdateFuturesStrategy(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	strategyIDStr := c.Param("strategy_id")
@@ -114,6 +142,59 @@ func (fc *FuturesController) ToggleFuturesStrategy(c *gin.Context) {
 	utils.SuccessWithMessage(c, "期货策略状态切换成功", nil)
 }
 
+// SetFuturesStrategyStatus 启动(armed)、暂停(paused)或停止(stopping)期货策略，停止只撤销挂单，持仓保留
+func (fc *FuturesController) SetFuturesStrategyStatus(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+
+	var req struct {
+		Status models.StrategyStatus `json:"status" binding:"required"`
+		Reason string                `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	strategy, err := fc.futuresService.SetFuturesStrategyStatus(userID, uint(strategyID), req.Status, req.Reason)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "期货策略状态更新成功", strategy)
+}
+
+// GetFuturesStrategyStatusHistory 期货策略状态变更历史
+func (fc *FuturesController) GetFuturesStrategyStatusHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	histories, total, err := fc.futuresService.GetFuturesStrategyStatusHistory(userID, uint(strategyID), page, limit)
+	if err != nil {
+		utils.NotFoundResponse(c, "期货策略不存在")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, histories, total, page, limit)
+}
+
 func (fc *FuturesController) DeleteFuturesStrategy(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	strategyIDStr := c.Param("strategy_id")
@@ -165,3 +246,118 @@ func (fc *FuturesController) GetFuturesStats(c *gin.Context) {
 
 	utils.SuccessResponse(c, stats)
 }
+
+// GetPositionMode 查询期货持仓模式（hedge双向持仓，one_way单向持仓）
+func (fc *FuturesController) GetPositionMode(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	mode, err := fc.futuresService.GetPositionMode(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取持仓模式失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"position_mode": mode})
+}
+
+// SetPositionMode 切换期货持仓模式
+func (fc *FuturesController) SetPositionMode(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		PositionMode models.PositionMode `json:"position_mode" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	if err := fc.futuresService.SetPositionMode(userID, req.PositionMode); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "持仓模式设置成功", gin.H{"position_mode": req.PositionMode})
+}
+
+// GetFundingRates 查询同步的资金费率，symbols为逗号分隔的交易对，为空时按费率绝对值排序
+func (fc *FuturesController) GetFundingRates(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	var symbols []string
+	if raw := c.Query("symbols"); raw != "" {
+		symbols = strings.Split(raw, ",")
+	}
+
+	rates, err := fc.fundingService.GetFundingRates(symbols, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取资金费率失败")
+		return
+	}
+
+	utils.SuccessResponse(c, rates)
+}
+
+// GetIncomes 查询期货资金流水及按类型的汇总
+func (fc *FuturesController) GetIncomes(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := services.IncomeQuery{
+		UserID:     userID,
+		IncomeType: c.Query("type"),
+		Symbol:     c.Query("symbol"),
+	}
+	if raw := c.Query("strategy_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			utils.BadRequestResponse(c, "无效的策略ID")
+			return
+		}
+		value := uint(id)
+		query.StrategyID = &value
+	}
+
+	incomes, total, err := fc.fundingService.GetIncomes(query, page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取资金流水失败")
+		return
+	}
+	summary, err := fc.fundingService.IncomeSummary(query)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取资金流水汇总失败")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"incomes": incomes,
+		"summary": summary,
+		"total":   total,
+		"page":    page,
+		"limit":   limit,
+	})
+}
+
+// SyncIncomes 立即同步期货资金流水
+func (fc *FuturesController) SyncIncomes(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	synced, err := fc.fundingService.SyncIncomeHistory(c.Request.Context(), userID)
+	if err != nil {
+		utils.BadRequestResponse(c, "同步资金流水失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "资金流水同步成功", gin.H{"synced": synced})
+}
@@ -10,12 +10,14 @@ import (
 )
 
 type GeneralController struct {
-	userService *services.UserService
+	userService      *services.UserService
+	orderListService *services.OrderListService
 }
 
 func NewGeneralController() *GeneralController {
 	return &GeneralController{
-		userService: services.NewUserService(),
+		userService:      services.NewUserService(),
+		orderListService: services.NewOrderListService(),
 	}
 }
 
@@ -28,13 +30,13 @@ func (gc *GeneralController) GetBalance(c *gin.Context) {
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
+	exchange, err := services.NewExchange(apiKey, secretKey)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+		utils.InternalServerErrorResponse(c, "创建交易所服务失败: "+err.Error())
 		return
 	}
 
-	account, err := binanceService.GetAccountInfo(c.Request.Context())
+	account, err := exchange.GetAccountInfo(c.Request.Context())
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "获取账户信息失败: "+err.Error())
 		return
@@ -42,7 +44,7 @@ func (gc *GeneralController) GetBalance(c *gin.Context) {
 
 	// 尝试获取期货账户信息，但在测试网环境下容忍失败
 	var futuresAccount interface{}
-	futuresAccount, err = binanceService.GetFuturesAccountInfo(c.Request.Context())
+	futuresAccount, err = exchange.GetFuturesAccountInfo(c.Request.Context())
 	if err != nil {
 		// 检查是否是测试网环境
 		if config.AppConfig.Binance.TestNet {
@@ -140,18 +142,12 @@ func (gc *GeneralController) CreateOrder(c *gin.Context) {
 		}
 	}
 
-	apiKey, secretKey, err := gc.userService.GetUserAPIKeys(userID)
+	exchange, err := gc.userService.GetTradingExchange(userID, false)
 	if err != nil {
 		utils.BadRequestResponse(c, "请先设置API密钥")
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
-	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
-		return
-	}
-
 	order := &models.Order{
 		UserID:        userID,
 		Symbol:        utils.ToUpper(req.Symbol),
@@ -163,13 +159,13 @@ func (gc *GeneralController) CreateOrder(c *gin.Context) {
 		ClientOrderID: utils.GenerateUUID(),
 	}
 
-	resp, err := binanceService.CreateSpotOrder(c.Request.Context(), order)
+	resp, err := exchange.CreateSpotOrder(c.Request.Context(), order)
 	if err != nil {
 		utils.BadRequestResponse(c, "创建订单失败: "+err.Error())
 		return
 	}
 
-	order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+	order.OrderID = resp.OrderID
 	order.Status = models.OrderStatus(resp.Status)
 
 	if err := config.DB.Create(order).Error; err != nil {
@@ -195,19 +191,27 @@ func (gc *GeneralController) CancelOrder(c *gin.Context) {
 		return
 	}
 
-	apiKey, secretKey, err := gc.userService.GetUserAPIKeys(userID)
-	if err != nil {
-		utils.BadRequestResponse(c, "请先设置API密钥")
+	// OCO中的订单只能随整个列表撤销
+	if order.OrderListID != "" {
+		if _, err := gc.orderListService.CancelOCO(c.Request.Context(), userID, order.OrderListID); err != nil {
+			utils.BadRequestResponse(c, "取消订单失败: "+err.Error())
+			return
+		}
+		utils.SuccessWithMessage(c, "OCO订单已撤销", nil)
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
-	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+	// 按订单本身是否为模拟订单选择交易所，与用户当前的交易模式无关
+	var exchange services.Exchange
+	var err error
+	if order.IsSimulated {
+		exchange = gc.userService.GetPaperExchange(userID)
+	} else if exchange, err = gc.userService.GetExchange(userID); err != nil {
+		utils.BadRequestResponse(c, "请先设置API密钥")
 		return
 	}
 
-	_, err = binanceService.CancelSpotOrder(c.Request.Context(), order.Symbol, order.OrderID)
+	_, err = exchange.CancelSpotOrder(c.Request.Context(), order.Symbol, order.OrderID)
 	if err != nil {
 		utils.BadRequestResponse(c, "取消订单失败: "+err.Error())
 		return
@@ -218,6 +222,202 @@ func (gc *GeneralController) CancelOrder(c *gin.Context) {
 	utils.SuccessWithMessage(c, "订单取消成功", nil)
 }
 
+// CreateOCOOrder 提交现货OCO订单：限价单与止损单共用数量，一方成交或触发后另一方自动撤销
+func (gc *GeneralController) CreateOCOOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		Symbol         string  `json:"symbol" binding:"required"`
+		Side           string  `json:"side" binding:"required"`
+		Quantity       float64 `json:"quantity" binding:"required"`
+		Price          float64 `json:"price" binding:"required"`
+		StopPrice      float64 `json:"stop_price" binding:"required"`
+		StopLimitPrice float64 `json:"stop_limit_price"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateSymbol(req.Symbol); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	if err := utils.ValidateQuantity(req.Quantity); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	list, err := gc.orderListService.CreateOCO(c.Request.Context(), userID, &services.OCORequest{
+		Symbol:         req.Symbol,
+		Side:           models.OrderSide(req.Side),
+		Quantity:       req.Quantity,
+		Price:          req.Price,
+		StopPrice:      req.StopPrice,
+		StopLimitPrice: req.StopLimitPrice,
+	})
+	if err != nil {
+		utils.BadRequestResponse(c, "创建OCO订单失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "OCO订单创建成功", list)
+}
+
+// CancelOCOOrder 撤销OCO订单列表
+func (gc *GeneralController) CancelOCOOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	list, err := gc.orderListService.CancelOCO(c.Request.Context(), userID, c.Param("order_list_id"))
+	if err != nil {
+		utils.BadRequestResponse(c, "撤销OCO订单失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "OCO订单撤销成功", list)
+}
+
+// GetOrderLists 获取OCO订单列表及其中的订单
+func (gc *GeneralController) GetOrderLists(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var lists []models.OrderList
+	var total int64
+
+	query := config.DB.Model(&models.OrderList{}).Where("user_id = ?", userID)
+
+	if symbol := c.Query("symbol"); symbol != "" {
+		query = query.Where("symbol = ?", utils.ToUpper(symbol))
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		utils.InternalServerErrorResponse(c, "获取OCO订单数量失败")
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Orders").Offset(offset).Limit(limit).Order("created_at desc").Find(&lists).Error; err != nil {
+		utils.InternalServerErrorResponse(c, "获取OCO订单列表失败")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, lists, total, page, limit)
+}
+
+// CreateBracketOrder 提交括号单：入场单成交后按成交数量自动挂出止盈止损OCO
+func (gc *GeneralController) CreateBracketOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		Symbol          string  `json:"symbol" binding:"required"`
+		Side            string  `json:"side" binding:"required"`
+		Type            string  `json:"type"`
+		Quantity        float64 `json:"quantity" binding:"required"`
+		Price           float64 `json:"price"`
+		TakeProfitPrice float64 `json:"take_profit_price" binding:"required"`
+		StopPrice       float64 `json:"stop_price" binding:"required"`
+		StopLimitPrice  float64 `json:"stop_limit_price"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	if err := utils.ValidateSymbol(req.Symbol); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	if err := utils.ValidateQuantity(req.Quantity); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	bracket, err := gc.orderListService.CreateBracket(c.Request.Context(), userID, &services.BracketRequest{
+		Symbol:          req.Symbol,
+		Side:            models.OrderSide(req.Side),
+		Type:            models.OrderType(req.Type),
+		Quantity:        req.Quantity,
+		Price:           req.Price,
+		TakeProfitPrice: req.TakeProfitPrice,
+		StopPrice:       req.StopPrice,
+		StopLimitPrice:  req.StopLimitPrice,
+	})
+	if err != nil {
+		utils.BadRequestResponse(c, "创建括号单失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "括号单创建成功", bracket)
+}
+
+// CancelBracketOrder 撤销括号单
+func (gc *GeneralController) CancelBracketOrder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	bracketID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "无效的括号单ID")
+		return
+	}
+
+	bracket, err := gc.orderListService.CancelBracket(c.Request.Context(), userID, uint(bracketID))
+	if err != nil {
+		utils.BadRequestResponse(c, "撤销括号单失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "括号单撤销成功", bracket)
+}
+
+// GetBracketOrders 获取括号单列表
+func (gc *GeneralController) GetBracketOrders(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var brackets []models.BracketOrder
+	var total int64
+
+	query := config.DB.Model(&models.BracketOrder{}).Where("user_id = ?", userID)
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		utils.InternalServerErrorResponse(c, "获取括号单数量失败")
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("OrderList.Orders").Offset(offset).Limit(limit).Order("created_at desc").Find(&brackets).Error; err != nil {
+		utils.InternalServerErrorResponse(c, "获取括号单列表失败")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, brackets, total, page, limit)
+}
+
 func (gc *GeneralController) BatchCancelOrders(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
@@ -237,9 +437,9 @@ func (gc *GeneralController) BatchCancelOrders(c *gin.Context) {
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
+	exchange, err := services.NewExchange(apiKey, secretKey)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+		utils.InternalServerErrorResponse(c, "创建交易所服务失败: "+err.Error())
 		return
 	}
 
@@ -258,7 +458,7 @@ func (gc *GeneralController) BatchCancelOrders(c *gin.Context) {
 			continue
 		}
 
-		_, err := binanceService.CancelSpotOrder(c.Request.Context(), order.Symbol, order.OrderID)
+		_, err := exchange.CancelSpotOrder(c.Request.Context(), order.Symbol, order.OrderID)
 		if err != nil {
 			failedOrders = append(failedOrders, orderID)
 			continue
@@ -317,13 +517,13 @@ func (gc *GeneralController) GetTradingSymbols(c *gin.Context) {
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
+	exchange, err := services.NewExchange(apiKey, secretKey)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+		utils.InternalServerErrorResponse(c, "创建交易所服务失败: "+err.Error())
 		return
 	}
 
-	symbols, err := binanceService.GetTradingSymbols(c.Request.Context())
+	symbols, err := exchange.GetTradingSymbols(c.Request.Context())
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "获取交易对信息失败: "+err.Error())
 		return
@@ -341,13 +541,13 @@ func (gc *GeneralController) GetFuturesTradingSymbols(c *gin.Context) {
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
+	exchange, err := services.NewExchange(apiKey, secretKey)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+		utils.InternalServerErrorResponse(c, "创建交易所服务失败: "+err.Error())
 		return
 	}
 
-	symbols, err := binanceService.GetFuturesTradingSymbols(c.Request.Context())
+	symbols, err := exchange.GetFuturesTradingSymbols(c.Request.Context())
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "获取期货交易对信息失败: "+err.Error())
 		return
@@ -375,13 +575,13 @@ func (gc *GeneralController) GetPrice(c *gin.Context) {
 		return
 	}
 
-	binanceService, err := services.NewBinanceService(apiKey, secretKey)
+	exchange, err := services.NewExchange(apiKey, secretKey)
 	if err != nil {
-		utils.InternalServerErrorResponse(c, "创建Binance服务失败: "+err.Error())
+		utils.InternalServerErrorResponse(c, "创建交易所服务失败: "+err.Error())
 		return
 	}
 
-	price, err := binanceService.GetPrice(c.Request.Context(), utils.ToUpper(symbol))
+	price, err := exchange.GetPrice(c.Request.Context(), utils.ToUpper(symbol))
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "获取价格失败: "+err.Error())
 		return
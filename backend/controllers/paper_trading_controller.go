@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"github.com/ccj241/cctrade/services"
+	"github.com/ccj241/cctrade/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type PaperTradingController struct {
+	paperTradingService *services.PaperTradingService
+}
+
+func NewPaperTradingController() *PaperTradingController {
+	return &PaperTradingController{
+		paperTradingService: services.NewPaperTradingService(),
+	}
+}
+
+func (pc *PaperTradingController) SetPaperTrading(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	if err := pc.paperTradingService.SetUserPaperTrading(userID, *req.Enabled); err != nil {
+		utils.InternalServerErrorResponse(c, "更新模拟交易设置失败: "+err.Error())
+		return
+	}
+
+	message := "已关闭模拟交易"
+	if *req.Enabled {
+		message = "已开启模拟交易"
+	}
+	utils.SuccessWithMessage(c, message, gin.H{"paper_trading": *req.Enabled})
+}
+
+func (pc *PaperTradingController) GetAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	account, err := pc.paperTradingService.GetAccount(userID)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取模拟账户失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, account)
+}
+
+func (pc *PaperTradingController) GetOrders(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	orders, total, err := pc.paperTradingService.GetOrders(userID, page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取模拟委托失败")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, orders, total, page, limit)
+}
+
+func (pc *PaperTradingController) ResetAccount(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	if err := pc.paperTradingService.ResetAccount(userID); err != nil {
+		utils.InternalServerErrorResponse(c, "重置模拟账户失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "模拟账户已重置", nil)
+}
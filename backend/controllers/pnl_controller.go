@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"github.com/ccj241/cctrade/services"
+	"github.com/ccj241/cctrade/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type PnLController struct {
+	ledgerService *services.PnLLedgerService
+	userService   *services.UserService
+}
+
+func NewPnLController() *PnLController {
+	return &PnLController{
+		ledgerService: services.NewPnLLedgerService(),
+		userService:   services.NewUserService(),
+	}
+}
+
+// GetPnL 账户盈亏及按交易对、按策略的拆分，可按市场、交易对和策略过滤
+func (pc *PnLController) GetPnL(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	method, err := services.ParsePnLCostMethod(c.Query("method"))
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	query := services.PnLQuery{
+		UserID: userID,
+		Symbol: c.Query("symbol"),
+		Method: method,
+	}
+
+	switch market := c.Query("market"); market {
+	case "":
+	case string(services.MarketSpot), string(services.MarketFutures):
+		query.Market = services.MarketType(market)
+	default:
+		utils.BadRequestResponse(c, "无效的市场类型: "+market)
+		return
+	}
+
+	if value := c.Query("strategy_id"); value != "" {
+		strategyID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			utils.BadRequestResponse(c, "无效的策略ID")
+			return
+		}
+		if query.Market == "" {
+			utils.BadRequestResponse(c, services.ErrPnLMarketRequired.Error())
+			return
+		}
+		id := uint(strategyID)
+		query.StrategyID = &id
+	}
+
+	// 默认只统计用户当前交易模式（模拟或实盘）的成交
+	simulated, ok := simulatedQuery(c, pc.userService, userID)
+	if !ok {
+		return
+	}
+	query.Simulated = &simulated
+
+	pnl, err := pc.ledgerService.AccountPnL(query)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取盈亏统计失败")
+		return
+	}
+
+	utils.SuccessResponse(c, pnl)
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"github.com/ccj241/cctrade/services"
+	"github.com/ccj241/cctrade/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+)
+
+type PortfolioController struct {
+	equityService *services.EquityService
+	userService   *services.UserService
+}
+
+func NewPortfolioController() *PortfolioController {
+	return &PortfolioController{
+		equityService: services.NewEquityService(),
+		userService:   services.NewUserService(),
+	}
+}
+
+// simulatedQuery 读取simulated查询参数，未指定时使用用户当前的交易模式
+func simulatedQuery(c *gin.Context, userService *services.UserService, userID uint) (bool, bool) {
+	value := c.Query("simulated")
+	if value == "" {
+		return userService.IsPaperTrading(userID), true
+	}
+	simulated, err := strconv.ParseBool(value)
+	if err != nil {
+		utils.BadRequestResponse(c, "无效的simulated参数")
+		return false, false
+	}
+	return simulated, true
+}
+
+// GetEquityCurve 权益曲线及每个点的回撤
+func (pc *PortfolioController) GetEquityCurve(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	simulated, ok := simulatedQuery(c, pc.userService, userID)
+	if !ok {
+		return
+	}
+
+	performance, err := pc.equityService.GetPerformance(userID, simulated, c.Query("window"), true)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, performance)
+}
+
+// GetEquitySnapshots 窗口内的权益快照明细
+func (pc *PortfolioController) GetEquitySnapshots(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	simulated, ok := simulatedQuery(c, pc.userService, userID)
+	if !ok {
+		return
+	}
+
+	snapshots, err := pc.equityService.GetSnapshots(userID, simulated, c.Query("window"))
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, snapshots)
+}
+
+// GetPerformance 收益率、最大回撤、波动率和夏普比率
+func (pc *PortfolioController) GetPerformance(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	simulated, ok := simulatedQuery(c, pc.userService, userID)
+	if !ok {
+		return
+	}
+
+	performance, err := pc.equityService.GetPerformance(userID, simulated, c.Query("window"), false)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, performance)
+}
+
+// TakeSnapshot 立即记录一次当前交易模式的权益快照
+func (pc *PortfolioController) TakeSnapshot(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	snapshot, err := pc.equityService.TakeSnapshot(userID, pc.userService.IsPaperTrading(userID))
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "记录权益快照失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "权益快照已记录", snapshot)
+}
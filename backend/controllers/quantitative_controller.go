@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"github.com/ccj241/cctrade/services"
+	"github.com/ccj241/cctrade/utils"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+)
+
+type QuantitativeController struct {
+	executor      *services.StrategyExecutor
+	equityService *services.EquityService
+	userService   *services.UserService
+}
+
+func NewQuantitativeController(executor *services.StrategyExecutor) *QuantitativeController {
+	return &QuantitativeController{
+		executor:      executor,
+		equityService: services.NewEquityService(),
+		userService:   services.NewUserService(),
+	}
+}
+
+// strategyIDQuery 读取可选的strategy_id查询参数，未指定时返回0（使用最近的量化策略）
+func strategyIDQuery(c *gin.Context) (uint, bool) {
+	strategyIDStr := c.Query("strategy_id")
+	if strategyIDStr == "" {
+		return 0, true
+	}
+	strategyID, err := strconv.ParseUint(strategyIDStr, 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return 0, false
+	}
+	return uint(strategyID), true
+}
+
+func (qc *QuantitativeController) GetScores(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	scores, err := qc.executor.GetScores(userID, strategyID)
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, scores)
+}
+
+func (qc *QuantitativeController) GetScoreDetails(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	symbol := strings.ToUpper(c.Param("symbol"))
+	if err := utils.ValidateSymbol(symbol); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	score, err := qc.executor.GetScoreDetails(userID, strategyID, symbol)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, score)
+}
+
+func (qc *QuantitativeController) GetTopPairs(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	pairs, err := qc.executor.GetTopPairs(userID, strategyID, limit)
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, pairs)
+}
+
+func (qc *QuantitativeController) GetHistoricalScores(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	symbol := strings.ToUpper(c.Query("symbol"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	history, err := qc.executor.GetHistoricalScores(userID, strategyID, symbol, limit)
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, history)
+}
+
+func (qc *QuantitativeController) GetPositions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	positions, err := qc.executor.GetPositions(userID, strategyID)
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, positions)
+}
+
+func (qc *QuantitativeController) GetRiskMetrics(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	metrics, err := qc.executor.GetRiskMetrics(userID, strategyID)
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, metrics)
+}
+
+func (qc *QuantitativeController) GetStrategyConfig(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	config, err := qc.executor.GetStrategyConfig(userID, strategyID)
+	if err != nil {
+		utils.NotFoundResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, config)
+}
+
+func (qc *QuantitativeController) UpdateStrategyConfig(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	config, err := qc.executor.UpdateStrategyConfig(userID, strategyID, req)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "策略配置更新成功", config)
+}
+
+func (qc *QuantitativeController) UpdateCapital(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		TotalCapital float64 `json:"total_capital_usdt" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	if err := qc.executor.UpdateCapital(userID, strategyID, req.TotalCapital); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "资金更新成功", nil)
+}
+
+func (qc *QuantitativeController) ResetDailyStats(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	if err := qc.executor.ResetDailyStats(userID, strategyID); err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "当日统计已重置", nil)
+}
+
+func (qc *QuantitativeController) GetPerformanceStats(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, ok := strategyIDQuery(c)
+	if !ok {
+		return
+	}
+
+	simulated, ok := simulatedQuery(c, qc.userService, userID)
+	if !ok {
+		return
+	}
+	account, err := qc.equityService.GetPerformance(userID, simulated, c.Query("window"), c.Query("curve") == "true")
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	// 未指定策略且没有量化策略时只返回账户权益表现
+	stats, err := qc.executor.GetPerformanceStats(userID, strategyID)
+	if err != nil {
+		if strategyID > 0 {
+			utils.NotFoundResponse(c, err.Error())
+			return
+		}
+		stats = map[string]interface{}{}
+	}
+	stats["account"] = account
+
+	utils.SuccessResponse(c, stats)
+}
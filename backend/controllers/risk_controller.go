@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/services"
+	"github.com/ccj241/cctrade/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type RiskController struct {
+	riskService *services.RiskService
+}
+
+func NewRiskController() *RiskController {
+	return &RiskController{
+		riskService: services.NewRiskService(),
+	}
+}
+
+func (rc *RiskController) GetPolicy(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	utils.SuccessResponse(c, rc.riskService.GetPolicy(userID))
+}
+
+func (rc *RiskController) UpdatePolicy(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var req models.RiskPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	policy, err := rc.riskService.UpdatePolicy(userID, &req)
+	if err != nil {
+		utils.BadRequestResponse(c, "更新风控策略失败: "+err.Error())
+		return
+	}
+	utils.SuccessWithMessage(c, "风控策略已更新", policy)
+}
+
+func (rc *RiskController) GetRejections(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var strategyID *uint
+	if raw := c.Query("strategy_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			utils.BadRequestResponse(c, "无效的策略ID")
+			return
+		}
+		value := uint(id)
+		strategyID = &value
+	}
+
+	rejections, total, err := rc.riskService.GetRejections(userID, strategyID, page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取风控拒绝记录失败")
+		return
+	}
+	utils.PaginatedSuccessResponse(c, rejections, total, page, limit)
+}
+
+func (rc *RiskController) Resume(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	policy, err := rc.riskService.Resume(userID)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+	utils.SuccessWithMessage(c, "已恢复交易，已停止的策略需要手动重新启动", policy)
+}
+
+func (rc *RiskController) GetBreaches(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	breaches, total, err := rc.riskService.GetBreaches(userID, page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取风控触发记录失败")
+		return
+	}
+	utils.PaginatedSuccessResponse(c, breaches, total, page, limit)
+}
+
+func (rc *RiskController) GetLiquidationActions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	actions, total, err := rc.riskService.GetLiquidationActions(userID, strings.ToUpper(c.Query("symbol")), page, limit)
+	if err != nil {
+		utils.InternalServerErrorResponse(c, "获取强平保护记录失败")
+		return
+	}
+	utils.PaginatedSuccessResponse(c, actions, total, page, limit)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"github.com/ccj241/cctrade/models"
 	"github.com/ccj241/cctrade/services"
 	"github.com/ccj241/cctrade/utils"
 	"github.com/gin-gonic/gin"
@@ -47,7 +48,13 @@ func (sc *StrategyController) GetUserStrategies(c *gin.Context) {
 		limit = 10
 	}
 
-	strategies, total, err := sc.strategyService.GetUserStrategies(userID, page, limit)
+	status := models.StrategyStatus(c.Query("status"))
+	if status != "" && !status.IsValid() {
+		utils.BadRequestResponse(c, "策略状态无效")
+		return
+	}
+
+	strategies, total, err := sc.strategyService.GetUserStrategies(userID, status, page, limit)
 	if err != nil {
 		utils.InternalServerErrorResponse(c, "获取策略列表失败")
 		return
@@ -114,6 +121,59 @@ func (sc *StrategyController) ToggleStrategy(c *gin.Context) {
 	utils.SuccessWithMessage(c, "策略状态切换成功", nil)
 }
 
+// SetStrategyStatus 启动(armed)、暂停(paused)或停止(stopping)策略
+func (sc *StrategyController) SetStrategyStatus(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+
+	var req struct {
+		Status models.StrategyStatus `json:"status" binding:"required"`
+		Reason string                `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequestResponse(c, "请求参数无效: "+err.Error())
+		return
+	}
+
+	strategy, err := sc.strategyService.SetStrategyStatus(userID, uint(strategyID), req.Status, req.Reason)
+	if err != nil {
+		utils.BadRequestResponse(c, err.Error())
+		return
+	}
+
+	utils.SuccessWithMessage(c, "策略状态更新成功", strategy)
+}
+
+// GetStrategyStatusHistory 策略状态变更历史
+func (sc *StrategyController) GetStrategyStatusHistory(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	strategyID, err := strconv.ParseUint(c.Param("strategy_id"), 10, 32)
+	if err != nil {
+		utils.BadRequestResponse(c, "策略ID无效")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	histories, total, err := sc.strategyService.GetStrategyStatusHistory(userID, uint(strategyID), page, limit)
+	if err != nil {
+		utils.NotFoundResponse(c, "策略不存在")
+		return
+	}
+
+	utils.PaginatedSuccessResponse(c, histories, total, page, limit)
+}
+
 func (sc *StrategyController) DeleteStrategy(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	strategyIDStr := c.Param("strategy_id")
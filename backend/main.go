@@ -72,7 +72,18 @@ func main() {
 
 	routes.SetupRoutes(r, strategyExecutor)
 
-	scheduler := tasks.NewScheduler()
+	// 行情推送中心：订阅活跃策略的交易对，驱动策略执行并更新价格缓存
+	marketDataHub := services.NewMarketDataHub(config.DB)
+	services.SetDefaultMarketDataHub(marketDataHub)
+	marketDataHub.Start()
+	defer marketDataHub.Stop()
+
+	// 用户数据流：通过listenKey实时接收订单和成交推送，轮询只做对账兜底
+	userDataStream := services.NewUserDataStreamManager(config.DB)
+	userDataStream.Start()
+	defer userDataStream.Stop()
+
+	scheduler := tasks.NewScheduler(marketDataHub, userDataStream)
 	scheduler.Start()
 	defer scheduler.Stop()
 
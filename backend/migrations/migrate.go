@@ -4,16 +4,21 @@ import (
 	"fmt"
 	"github.com/ccj241/cctrade/config"
 	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
 	"log"
 )
 
 func RunMigrations() error {
 	log.Println("开始数据库迁移...")
 
+	dropLegacyIndexes()
+
 	if err := config.AutoMigrate(); err != nil {
 		return err
 	}
 
+	backfillStrategyStatus()
+
 	if err := createIndexes(); err != nil {
 		return err
 	}
@@ -26,6 +31,42 @@ func RunMigrations() error {
 	return nil
 }
 
+// dropLegacyIndexes 删除已被联合索引取代的旧索引
+func dropLegacyIndexes() {
+	db := config.DB
+	if db == nil {
+		return
+	}
+
+	// 成交ID只在同一市场同一交易对内唯一，改为idx_trades_market_symbol_trade
+	if db.Migrator().HasIndex(&models.Trade{}, "idx_trades_trade_id") {
+		if err := db.Migrator().DropIndex(&models.Trade{}, "idx_trades_trade_id"); err != nil {
+			log.Printf("删除旧索引idx_trades_trade_id失败: %v", err)
+		}
+	}
+}
+
+// backfillStrategyStatus 新增status字段前创建的策略按is_active和is_completed补齐状态，
+// 已启动的策略先置为等待触发，由调度器在确认已下单后转为运行中
+func backfillStrategyStatus() {
+	db := config.DB
+	if db == nil {
+		return
+	}
+
+	for _, model := range []interface{}{&models.Strategy{}, &models.FuturesStrategy{}} {
+		legacy := func() *gorm.DB {
+			return db.Model(model).Where("status = ? OR status = '' OR status IS NULL", models.StrategyStatusDraft)
+		}
+		if err := legacy().Where("is_completed = ?", true).Update("status", models.StrategyStatusCompleted).Error; err != nil {
+			log.Printf("补齐策略状态失败: %v", err)
+		}
+		if err := legacy().Where("is_active = ?", true).Update("status", models.StrategyStatusArmed).Error; err != nil {
+			log.Printf("补齐策略状态失败: %v", err)
+		}
+	}
+}
+
 func createIndexes() error {
 	log.Println("创建数据库索引...")
 
@@ -63,6 +104,9 @@ func createIndexes() error {
 		"CREATE INDEX IF NOT EXISTS idx_withdrawals_asset ON withdrawals(asset)",
 		"CREATE INDEX IF NOT EXISTS idx_withdrawal_histories_user_id ON withdrawal_histories(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_withdrawal_histories_withdrawal_id ON withdrawal_histories(withdrawal_id)",
+		"CREATE INDEX IF NOT EXISTS idx_paper_orders_user_status ON paper_orders(user_id, status)",
+		"CREATE INDEX IF NOT EXISTS idx_backtest_runs_strategy_created ON backtest_runs(strategy_id, created_at)",
+		"CREATE INDEX IF NOT EXISTS idx_futures_orders_status ON futures_orders(status)",
 	}
 
 	for _, query := range queries {
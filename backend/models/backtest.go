@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type BacktestStatus string
+
+const (
+	BacktestStatusCompleted BacktestStatus = "completed"
+	BacktestStatusFailed    BacktestStatus = "failed"
+)
+
+// BacktestTrade 回测成交明细
+type BacktestTrade struct {
+	Time            int64   `json:"time"`
+	OrderID         string  `json:"order_id"`
+	Side            string  `json:"side"`
+	Type            string  `json:"type"`
+	Price           float64 `json:"price"`
+	Quantity        float64 `json:"quantity"`
+	QuoteQty        float64 `json:"quote_qty"`
+	Commission      float64 `json:"commission"`
+	CommissionAsset string  `json:"commission_asset"`
+	IsMaker         bool    `json:"is_maker"`
+}
+
+type BacktestTrades []BacktestTrade
+
+func (t BacktestTrades) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+func (t *BacktestTrades) Scan(value interface{}) error {
+	if value == nil {
+		*t = BacktestTrades{}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return errors.New("cannot scan BacktestTrades")
+	}
+}
+
+// BacktestRun 策略回测记录，Config为发起回测时的策略配置快照
+type BacktestRun struct {
+	BaseModel
+	UserID        uint           `json:"user_id" gorm:"not null;index"`
+	StrategyID    uint           `json:"strategy_id" gorm:"not null;index"`
+	StrategyType  StrategyType   `json:"strategy_type" gorm:"size:30"`
+	Symbol        string         `json:"symbol" gorm:"size:20;not null"`
+	Interval      string         `json:"interval" gorm:"column:kline_interval;size:10"`
+	KlineSource   string         `json:"kline_source" gorm:"size:20"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	KlineCount    int            `json:"kline_count"`
+	FeeRate       float64        `json:"fee_rate" gorm:"type:decimal(10,6)"`
+	InitialQuote  float64        `json:"initial_quote" gorm:"type:decimal(30,8)"`
+	InitialBase   float64        `json:"initial_base" gorm:"type:decimal(30,8)"`
+	InitialEquity float64        `json:"initial_equity" gorm:"type:decimal(30,8)"`
+	FinalEquity   float64        `json:"final_equity" gorm:"type:decimal(30,8)"`
+	TotalPnL      float64        `json:"total_pnl" gorm:"column:total_pnl;type:decimal(30,8)"`
+	PnLPercent    float64        `json:"pnl_percent" gorm:"column:pnl_percent;type:decimal(10,4)"`
+	MaxDrawdown   float64        `json:"max_drawdown" gorm:"type:decimal(10,4)"` // 最大回撤百分比
+	TotalFees     float64        `json:"total_fees" gorm:"type:decimal(30,8)"`   // 折算为计价资产的手续费
+	FeeDrag       float64        `json:"fee_drag" gorm:"type:decimal(10,4)"`     // 手续费占初始权益的百分比
+	FillCount     int            `json:"fill_count"`
+	BuyCount      int            `json:"buy_count"`
+	SellCount     int            `json:"sell_count"`
+	OpenOrders    int            `json:"open_orders"` // 回测结束时仍未成交的挂单数
+	Status        BacktestStatus `json:"status" gorm:"size:20;index"`
+	ErrorMessage  string         `json:"error_message" gorm:"type:text"`
+	Config        StrategyConfig `json:"config" gorm:"type:json"`
+	Trades        BacktestTrades `json:"trades,omitempty" gorm:"type:json"`
+
+	User     User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Strategy Strategy `json:"strategy,omitempty" gorm:"foreignKey:StrategyID"`
+}
+
+func (br *BacktestRun) TableName() string {
+	return "backtest_runs"
+}
+
+// KlineCache 本地K线缓存，来源为交易所GetKlines或导入的CSV文件
+type KlineCache struct {
+	BaseModel
+	Symbol    string  `json:"symbol" gorm:"size:20;not null;uniqueIndex:idx_kline_cache"`
+	Interval  string  `json:"interval" gorm:"column:kline_interval;size:10;not null;uniqueIndex:idx_kline_cache"`
+	OpenTime  int64   `json:"open_time" gorm:"not null;uniqueIndex:idx_kline_cache"`
+	Open      float64 `json:"open" gorm:"type:decimal(20,8)"`
+	High      float64 `json:"high" gorm:"type:decimal(20,8)"`
+	Low       float64 `json:"low" gorm:"type:decimal(20,8)"`
+	Close     float64 `json:"close" gorm:"type:decimal(20,8)"`
+	Volume    float64 `json:"volume" gorm:"type:decimal(30,8)"`
+	CloseTime int64   `json:"close_time"`
+	Source    string  `json:"source" gorm:"size:20"`
+}
+
+func (kc *KlineCache) TableName() string {
+	return "kline_caches"
+}
@@ -35,6 +35,9 @@ const (
 	StrategySlowIceberg StrategyType = "slow_iceberg"
 	StrategyGrid        StrategyType = "grid"
 	StrategyDCA         StrategyType = "dca"
+	StrategyTWAP        StrategyType = "twap"           // 按时间均分的算法执行
+	StrategyVWAP        StrategyType = "vwap"           // 按历史成交量分布的算法执行
+	StrategyCashCarry   StrategyType = "cash_and_carry" // 现货多头加永续空头的资金费率套利，仅用于期货策略
 	// 高级策略类型
 	StrategyQuantitative    StrategyType = "quantitative"     // 综合量化策略
 	StrategyWeightedScoring StrategyType = "weighted_scoring" // 加权评分策略
@@ -66,14 +69,15 @@ const (
 type OrderType string
 
 const (
-	OrderTypeMarket          OrderType = "market"
-	OrderTypeLimit           OrderType = "limit"
-	OrderTypeStop            OrderType = "stop"
-	OrderTypeStopLoss        OrderType = "stop_loss"
-	OrderTypeStopLossLimit   OrderType = "stop_loss_limit"
-	OrderTypeTakeProfit      OrderType = "take_profit"
-	OrderTypeTakeProfitLimit OrderType = "take_profit_limit"
-	OrderTypeLimitMaker      OrderType = "limit_maker"
+	OrderTypeMarket             OrderType = "market"
+	OrderTypeLimit              OrderType = "limit"
+	OrderTypeStop               OrderType = "stop"
+	OrderTypeStopLoss           OrderType = "stop_loss"
+	OrderTypeStopLossLimit      OrderType = "stop_loss_limit"
+	OrderTypeTakeProfit         OrderType = "take_profit"
+	OrderTypeTakeProfitLimit    OrderType = "take_profit_limit"
+	OrderTypeLimitMaker         OrderType = "limit_maker"
+	OrderTypeTrailingStopMarket OrderType = "trailing_stop_market" // 期货跟踪止损市价单
 )
 
 type PositionSide string
@@ -84,6 +88,14 @@ const (
 	PositionSideBoth  PositionSide = "both"
 )
 
+// PositionMode 期货持仓模式：双向持仓按LONG/SHORT分别持仓，单向持仓只有BOTH一个方向
+type PositionMode string
+
+const (
+	PositionModeHedge  PositionMode = "hedge"
+	PositionModeOneWay PositionMode = "one_way"
+)
+
 type MarginType string
 
 const (
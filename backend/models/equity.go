@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// EquitySnapshot 账户权益快照，所有金额折算为USDT
+type EquitySnapshot struct {
+	BaseModel
+	UserID                  uint      `json:"user_id" gorm:"not null;index:idx_equity_snapshots_user_time,priority:1"`
+	IsSimulated             bool      `json:"is_simulated" gorm:"default:false;index:idx_equity_snapshots_user_time,priority:2"`
+	SnapshotTime            time.Time `json:"snapshot_time" gorm:"not null;index:idx_equity_snapshots_user_time,priority:3"`
+	SpotEquity              float64   `json:"spot_equity" gorm:"type:decimal(30,8);default:0"`               // 现货余额（含冻结）估值
+	FuturesWalletBalance    float64   `json:"futures_wallet_balance" gorm:"type:decimal(30,8);default:0"`    // 期货钱包余额
+	FuturesUnrealizedPnL    float64   `json:"futures_unrealized_pnl" gorm:"type:decimal(30,8);default:0"`    // 期货未实现盈亏
+	DualInvestmentPrincipal float64   `json:"dual_investment_principal" gorm:"type:decimal(30,8);default:0"` // 未结算的双币投资本金
+	TotalEquity             float64   `json:"total_equity" gorm:"type:decimal(30,8);default:0"`
+	UnpricedAssets          string    `json:"unpriced_assets" gorm:"size:255"` // 无法估值而未计入权益的资产
+}
+
+func (es *EquitySnapshot) TableName() string {
+	return "equity_snapshots"
+}
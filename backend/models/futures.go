@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type FuturesStrategy struct {
 	BaseModel
 	UserID           uint           `json:"user_id" gorm:"not null;index"`
@@ -12,13 +14,17 @@ type FuturesStrategy struct {
 	FloatBasisPoints float64        `json:"float_basis_points" gorm:"type:decimal(10,4);default:0.1"` // 首单万分比浮动（支持小数）
 	TakeProfitBP     int            `json:"take_profit_bp" gorm:"default:0"`             // 止盈万分比
 	StopLossBP       int            `json:"stop_loss_bp" gorm:"default:0"`               // 止损万分比
+	TrailingCallbackRate float64    `json:"trailing_callback_rate" gorm:"type:decimal(6,2);default:0"` // 跟踪止损回调比例（百分比，0.1-10），0为不启用
+	TrailingActivationBP int        `json:"trailing_activation_bp" gorm:"default:0"`                    // 跟踪止损激活价相对入场价的盈利万分比，0为立即激活
 	Leverage         int            `json:"leverage" gorm:"default:8"`                   // 杠杆倍数，默认8倍
 	MarginType       MarginType     `json:"margin_type" gorm:"default:'isolated'"`       // 默认逐仓
 	Config           StrategyConfig `json:"config" gorm:"type:json"`
 	State            StrategyState  `json:"state" gorm:"type:json"`
-	IsActive         bool           `json:"is_active" gorm:"default:false"`
-	IsCompleted      bool           `json:"is_completed" gorm:"default:false"`
+	Status           StrategyStatus `json:"status" gorm:"size:20;default:'draft';index"`
+	IsActive         bool           `json:"is_active" gorm:"default:false"`    // 由status派生，保留兼容
+	IsCompleted      bool           `json:"is_completed" gorm:"default:false"` // 由status派生，保留兼容
 	AutoRestart      bool           `json:"auto_restart" gorm:"default:false"`
+	PaperTrading     bool           `json:"paper_trading" gorm:"default:false"`        // 模拟交易
 	
 	// 计算字段（不存储）
 	OrderQuantity    float64        `json:"order_quantity" gorm:"-"`     // 实际下单数量
@@ -53,6 +59,10 @@ type FuturesOrder struct {
 	TimeInForce        string       `json:"time_in_force" gorm:"size:10"`
 	ReduceOnly         bool         `json:"reduce_only" gorm:"default:false"`
 	WorkingType        string       `json:"working_type" gorm:"size:20"`
+	CallbackRate       float64      `json:"callback_rate,omitempty" gorm:"type:decimal(6,2);default:0"`     // 跟踪止损回调比例（百分比）
+	ActivationPrice    float64      `json:"activation_price,omitempty" gorm:"type:decimal(20,8);default:0"` // 跟踪止损激活价
+	IsSimulated        bool         `json:"is_simulated" gorm:"default:false;index"`
+	BackfillAttempts   int          `json:"-" gorm:"default:0"` // 补录成交明细的尝试次数
 
 	User     User             `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Strategy *FuturesStrategy `json:"strategy,omitempty" gorm:"foreignKey:StrategyID"`
@@ -77,6 +87,7 @@ type FuturesPosition struct {
 	MarginType       MarginType   `json:"margin_type"`
 	IsolatedMargin   float64      `json:"isolated_margin" gorm:"type:decimal(20,8)"`
 	IsAutoAddMargin  bool         `json:"is_auto_add_margin"`
+	IsSimulated      bool         `json:"is_simulated" gorm:"default:false;index"`
 
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 }
@@ -84,3 +95,66 @@ type FuturesPosition struct {
 func (fp *FuturesPosition) TableName() string {
 	return "futures_positions"
 }
+
+// FundingRate 永续合约资金费率，由premiumIndex定期同步
+type FundingRate struct {
+	BaseModel
+	Symbol               string     `json:"symbol" gorm:"size:20;uniqueIndex;not null"`
+	MarkPrice            float64    `json:"mark_price" gorm:"type:decimal(20,8)"`
+	IndexPrice           float64    `json:"index_price" gorm:"type:decimal(20,8)"`
+	FundingRate          float64    `json:"funding_rate" gorm:"type:decimal(20,10)"`           // 本期资金费率，在NextFundingTime结算
+	PredictedFundingRate float64    `json:"predicted_funding_rate" gorm:"type:decimal(20,10)"` // 按当前溢价指数估算的资金费率
+	Premium              float64    `json:"premium" gorm:"type:decimal(20,10)"`                // 标记价格相对指数价格的溢价率
+	InterestRate         float64    `json:"interest_rate" gorm:"type:decimal(20,10)"`
+	NextFundingTime      time.Time  `json:"next_funding_time"`
+	SettledFundingRate   float64    `json:"settled_funding_rate" gorm:"type:decimal(20,10)"` // 上一期结算时的资金费率
+	SettledAt            *time.Time `json:"settled_at"`
+}
+
+func (fr *FundingRate) TableName() string {
+	return "funding_rates"
+}
+
+// FuturesIncomeType 期货资金流水类型
+type FuturesIncomeType string
+
+const (
+	FuturesIncomeFundingFee  FuturesIncomeType = "FUNDING_FEE"
+	FuturesIncomeCommission  FuturesIncomeType = "COMMISSION"
+	FuturesIncomeRealizedPnL FuturesIncomeType = "REALIZED_PNL"
+)
+
+// FuturesIncome 从交易所同步的期货资金流水
+type FuturesIncome struct {
+	BaseModel
+	UserID     uint              `json:"user_id" gorm:"not null;uniqueIndex:idx_futures_income"`
+	TranID     int64             `json:"tran_id" gorm:"not null;uniqueIndex:idx_futures_income"`
+	IncomeType FuturesIncomeType `json:"income_type" gorm:"size:30;not null;uniqueIndex:idx_futures_income"`
+	Symbol     string            `json:"symbol" gorm:"size:20;uniqueIndex:idx_futures_income;index"`
+	Income     float64           `json:"income" gorm:"type:decimal(30,8)"` // 正数为收入，负数为支出
+	Asset      string            `json:"asset" gorm:"size:20"`
+	Info       string            `json:"info" gorm:"size:100"`
+	TradeID    string            `json:"trade_id" gorm:"size:50;index"`
+	StrategyID *uint             `json:"strategy_id" gorm:"index"` // 归属的期货策略，资金费用为持仓最大的策略；为空表示手动交易或无法归属
+	IncomeTime time.Time         `json:"income_time" gorm:"index"`
+}
+
+func (fi *FuturesIncome) TableName() string {
+	return "futures_incomes"
+}
+
+// FuturesIncomeAllocation 资金费用按结算时各策略净持仓比例拆分后的归属，盈亏统计按拆分金额计入各策略
+type FuturesIncomeAllocation struct {
+	BaseModel
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	IncomeID   uint      `json:"income_id" gorm:"not null;index"`
+	StrategyID *uint     `json:"strategy_id" gorm:"index"` // 为空表示手动交易的持仓
+	Symbol     string    `json:"symbol" gorm:"size:20;index"`
+	Income     float64   `json:"income" gorm:"type:decimal(30,8)"`
+	Asset      string    `json:"asset" gorm:"size:20"`
+	IncomeTime time.Time `json:"income_time" gorm:"index"`
+}
+
+func (fia *FuturesIncomeAllocation) TableName() string {
+	return "futures_income_allocations"
+}
@@ -0,0 +1,62 @@
+package models
+
+// OrderList 现货订单列表（OCO），止盈限价单与止损限价单一方成交或触发后另一方自动撤销
+type OrderList struct {
+	BaseModel
+	UserID            uint      `json:"user_id" gorm:"not null;index"`
+	OrderListID       string    `json:"order_list_id" gorm:"size:50;uniqueIndex"`
+	ListClientOrderID string    `json:"list_client_order_id" gorm:"size:50"`
+	Symbol            string    `json:"symbol" gorm:"size:20;not null;index"`
+	ContingencyType   string    `json:"contingency_type" gorm:"size:10;default:'OCO'"`
+	Side              OrderSide `json:"side" gorm:"not null"`
+	Quantity          float64   `json:"quantity" gorm:"type:decimal(20,8)"`
+	Price             float64   `json:"price" gorm:"type:decimal(20,8)"`            // 限价单价格
+	StopPrice         float64   `json:"stop_price" gorm:"type:decimal(20,8)"`       // 止损单触发价
+	StopLimitPrice    float64   `json:"stop_limit_price" gorm:"type:decimal(20,8)"` // 止损单限价，为0时止损单按市价成交
+	ListStatus        string    `json:"list_status" gorm:"size:20;index"`           // EXEC_STARTED、ALL_DONE、REJECT
+	ListOrderStatus   string    `json:"list_order_status" gorm:"size:20"`           // EXECUTING、ALL_DONE、REJECT
+	IsSimulated       bool      `json:"is_simulated" gorm:"default:false;index"`
+
+	Orders []Order `json:"orders,omitempty" gorm:"foreignKey:OrderListID;references:OrderListID"`
+}
+
+func (ol *OrderList) TableName() string {
+	return "order_lists"
+}
+
+// BracketStatus 括号单状态
+type BracketStatus string
+
+const (
+	BracketStatusPending   BracketStatus = "pending"   // 入场单未完成
+	BracketStatusActive    BracketStatus = "active"    // 入场已成交，止盈止损OCO挂单中
+	BracketStatusCompleted BracketStatus = "completed" // 止盈或止损已成交
+	BracketStatusCanceled  BracketStatus = "canceled"
+	BracketStatusFailed    BracketStatus = "failed"
+)
+
+// BracketOrder 现货括号单：入场单成交后按成交数量挂出止盈止损OCO
+type BracketOrder struct {
+	BaseModel
+	UserID          uint          `json:"user_id" gorm:"not null;index"`
+	Symbol          string        `json:"symbol" gorm:"size:20;not null;index"`
+	Side            OrderSide     `json:"side" gorm:"not null"` // 入场方向，离场方向相反
+	EntryType       OrderType     `json:"entry_type" gorm:"not null"`
+	Quantity        float64       `json:"quantity" gorm:"type:decimal(20,8)"`
+	EntryPrice      float64       `json:"entry_price" gorm:"type:decimal(20,8)"`
+	EntryOrderID    string        `json:"entry_order_id" gorm:"size:50;index"`
+	ExecutedQty     float64       `json:"executed_qty" gorm:"type:decimal(20,8);default:0"` // 入场成交数量（扣除基础资产手续费）
+	TakeProfitPrice float64       `json:"take_profit_price" gorm:"type:decimal(20,8)"`
+	StopPrice       float64       `json:"stop_price" gorm:"type:decimal(20,8)"`
+	StopLimitPrice  float64       `json:"stop_limit_price" gorm:"type:decimal(20,8)"`
+	OrderListID     string        `json:"order_list_id" gorm:"size:50;index"`
+	Status          BracketStatus `json:"status" gorm:"size:20;index;default:'pending'"`
+	Message         string        `json:"message" gorm:"size:255"`
+	IsSimulated     bool          `json:"is_simulated" gorm:"default:false;index"`
+
+	OrderList *OrderList `json:"order_list,omitempty" gorm:"foreignKey:OrderListID;references:OrderListID"`
+}
+
+func (bo *BracketOrder) TableName() string {
+	return "bracket_orders"
+}
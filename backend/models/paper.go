@@ -0,0 +1,71 @@
+package models
+
+// PaperMarket 模拟账户市场类型
+type PaperMarket string
+
+const (
+	PaperMarketSpot    PaperMarket = "spot"
+	PaperMarketFutures PaperMarket = "futures"
+)
+
+// PaperBalance 模拟账户余额，期货市场的Locked为已占用保证金
+type PaperBalance struct {
+	BaseModel
+	UserID uint        `json:"user_id" gorm:"not null;uniqueIndex:idx_paper_balance"`
+	Market PaperMarket `json:"market" gorm:"size:10;not null;uniqueIndex:idx_paper_balance"`
+	Asset  string      `json:"asset" gorm:"size:20;not null;uniqueIndex:idx_paper_balance"`
+	Free   float64     `json:"free" gorm:"type:decimal(30,8);default:0"`
+	Locked float64     `json:"locked" gorm:"type:decimal(30,8);default:0"`
+
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (pb *PaperBalance) TableName() string {
+	return "paper_balances"
+}
+
+// PaperSetting 模拟账户设置
+type PaperSetting struct {
+	BaseModel
+	UserID              uint         `json:"user_id" gorm:"not null;uniqueIndex"`
+	FuturesPositionMode PositionMode `json:"futures_position_mode" gorm:"size:10;default:'hedge'"`
+}
+
+func (ps *PaperSetting) TableName() string {
+	return "paper_settings"
+}
+
+// PaperOrder 模拟撮合引擎的委托簿，相当于交易所侧的订单记录
+type PaperOrder struct {
+	BaseModel
+	UserID          uint        `json:"user_id" gorm:"not null;index"`
+	Market          PaperMarket `json:"market" gorm:"size:10;not null"`
+	OrderID         string      `json:"order_id" gorm:"size:50;uniqueIndex"`
+	ClientOrderID   string      `json:"client_order_id" gorm:"size:50"`
+	Symbol          string      `json:"symbol" gorm:"size:20;not null;index"`
+	Side            string      `json:"side" gorm:"size:10;not null"`
+	Type            string      `json:"type" gorm:"size:30;not null"`
+	PositionSide    string      `json:"position_side" gorm:"size:10"`
+	Price           float64     `json:"price" gorm:"type:decimal(20,8)"`
+	StopPrice       float64     `json:"stop_price" gorm:"type:decimal(20,8)"`
+	OrigQty         float64     `json:"orig_qty" gorm:"type:decimal(20,8)"`
+	ExecutedQty     float64     `json:"executed_qty" gorm:"type:decimal(20,8);default:0"`
+	CumQuote        float64     `json:"cum_quote" gorm:"type:decimal(30,8);default:0"`
+	Commission      float64     `json:"commission" gorm:"type:decimal(20,8);default:0"`
+	CommissionAsset string      `json:"commission_asset" gorm:"size:20"`
+	RealizedPnL     float64     `json:"realized_pnl" gorm:"column:realized_pnl;type:decimal(20,8);default:0"` // 期货平仓的已实现盈亏
+	IsMaker         bool        `json:"is_maker" gorm:"default:false"`
+	Reserved        float64     `json:"reserved" gorm:"type:decimal(30,8);default:0"` // 现货挂单冻结的资产数量
+	ReduceOnly      bool        `json:"reduce_only" gorm:"default:false"`
+	Triggered       bool        `json:"triggered" gorm:"default:false"`                       // 条件单是否已触发
+	OrderListID     string      `json:"order_list_id" gorm:"size:50;index"`                   // OCO订单列表，同一列表的订单一方成交或触发后其余失效
+	CallbackRate    float64     `json:"callback_rate" gorm:"type:decimal(6,2);default:0"`     // 跟踪止损回调比例（百分比）
+	ActivationPrice float64     `json:"activation_price" gorm:"type:decimal(20,8);default:0"` // 跟踪止损激活价
+	Status          string      `json:"status" gorm:"size:20;index"`
+
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (po *PaperOrder) TableName() string {
+	return "paper_orders"
+}
@@ -0,0 +1,139 @@
+package models
+
+import "time"
+
+// RiskPolicy 用户风控策略，覆盖配置文件中的默认限额，0表示不限制
+type RiskPolicy struct {
+	BaseModel
+	UserID              uint    `json:"user_id" gorm:"not null;uniqueIndex"`
+	MaxOrderNotional    float64 `json:"max_order_notional" gorm:"type:decimal(30,8);default:0"`  // 单笔订单最大名义价值
+	MaxSymbolExposure   float64 `json:"max_symbol_exposure" gorm:"type:decimal(30,8);default:0"` // 单个交易对最大敞口（现货持仓估值或期货持仓名义价值，含未成交的开仓挂单）
+	MaxPriceDeviationBP int     `json:"max_price_deviation_bp" gorm:"default:0"`                 // 限价买高于或卖低于当前价格的最大万分比
+
+	// 账户级限额，由风控监控任务检查，触发后停止全部策略并暂停交易
+	DailyLossLimit     float64    `json:"daily_loss_limit" gorm:"type:decimal(30,8);default:0"`     // 当日已实现净亏损上限（USDT）
+	MaxDrawdownPercent float64    `json:"max_drawdown_percent" gorm:"type:decimal(10,4);default:0"` // 权益距峰值的最大回撤百分比
+	MaxGrossExposure   float64    `json:"max_gross_exposure" gorm:"type:decimal(30,8);default:0"`   // 现货非稳定币持仓与期货持仓名义价值之和的上限
+	MaxOpenOrders      int        `json:"max_open_orders" gorm:"default:0"`                         // 未完成订单数上限
+	FlattenOnBreach    bool       `json:"flatten_on_breach" gorm:"default:false"`                   // 触发后是否市价平掉期货持仓
+	HaltedAt           *time.Time `json:"halted_at"`                                                // 触发熔断的时间，为空表示正常交易
+	HaltReason         string     `json:"halt_reason" gorm:"size:255"`
+	ResumedAt          *time.Time `json:"resumed_at"` // 最近一次恢复交易的时间，回撤峰值从该时间起计算
+
+	// 期货强平距离监控，距离为标记价格到强平价格的百分比，0表示不启用对应操作
+	LiqTopUpDistance    float64 `json:"liq_top_up_distance" gorm:"type:decimal(10,4);default:0"` // 低于该距离时追加保证金
+	LiqTargetDistance   float64 `json:"liq_target_distance" gorm:"type:decimal(10,4);default:0"` // 追加保证金后的目标距离，不大于触发距离时取触发距离的2倍
+	LiqMaxTopUp         float64 `json:"liq_max_top_up" gorm:"type:decimal(30,8);default:0"`      // 单次追加保证金上限（USDT）
+	LiqTransferFromSpot bool    `json:"liq_transfer_from_spot" gorm:"default:false"`             // 期货可用余额不足时从现货划转
+	LiqReduceDistance   float64 `json:"liq_reduce_distance" gorm:"type:decimal(10,4);default:0"` // 低于该距离时只减仓
+	LiqReduceRatio      float64 `json:"liq_reduce_ratio" gorm:"type:decimal(10,4);default:0"`    // 每次减仓的持仓比例（百分比）
+}
+
+// LiquidationGuardEnabled 是否启用了强平距离的自动处置
+func (rp *RiskPolicy) LiquidationGuardEnabled() bool {
+	return rp.LiqTopUpDistance > 0 || (rp.LiqReduceDistance > 0 && rp.LiqReduceRatio > 0)
+}
+
+// AccountLimitsEnabled 是否设置了任一账户级限额
+func (rp *RiskPolicy) AccountLimitsEnabled() bool {
+	return rp.DailyLossLimit > 0 || rp.MaxDrawdownPercent > 0 || rp.MaxGrossExposure > 0 || rp.MaxOpenOrders > 0
+}
+
+func (rp *RiskPolicy) TableName() string {
+	return "risk_policies"
+}
+
+// PreTradeReason 下单前检查的拒绝原因代码
+type PreTradeReason string
+
+const (
+	PreTradeInsufficientBalance PreTradeReason = "INSUFFICIENT_BALANCE" // 现货可用余额不足
+	PreTradeInsufficientMargin  PreTradeReason = "INSUFFICIENT_MARGIN"  // 期货可用保证金不足
+	PreTradeMaxOrderNotional    PreTradeReason = "MAX_ORDER_NOTIONAL"   // 超过单笔最大名义价值
+	PreTradeSymbolExposure      PreTradeReason = "SYMBOL_EXPOSURE_CAP"  // 超过单个交易对敞口上限
+	PreTradePriceDeviation      PreTradeReason = "PRICE_DEVIATION"      // 限价偏离当前价格过大
+	PreTradeNoReferencePrice    PreTradeReason = "NO_REFERENCE_PRICE"   // 无法获取当前价格
+	PreTradeTradingHalted       PreTradeReason = "TRADING_HALTED"       // 账户风控熔断后暂停开仓
+)
+
+// PreTradeRejection 下单前检查拒绝的订单记录
+type PreTradeRejection struct {
+	BaseModel
+	UserID      uint           `json:"user_id" gorm:"not null;index"`
+	StrategyID  *uint          `json:"strategy_id" gorm:"index"`
+	Market      string         `json:"market" gorm:"size:10;not null"` // spot、futures
+	Symbol      string         `json:"symbol" gorm:"size:20;not null;index"`
+	Side        OrderSide      `json:"side" gorm:"not null"`
+	Type        OrderType      `json:"type" gorm:"not null"`
+	Quantity    float64        `json:"quantity" gorm:"type:decimal(20,8)"`
+	Price       float64        `json:"price" gorm:"type:decimal(20,8)"`      // 订单价格，市价单为参考价格
+	Notional    float64        `json:"notional" gorm:"type:decimal(30,8)"`   // 订单名义价值
+	Reason      PreTradeReason `json:"reason" gorm:"size:30;not null;index"` // 拒绝原因代码
+	Message     string         `json:"message" gorm:"size:255"`              // 拒绝原因说明
+	IsSimulated bool           `json:"is_simulated" gorm:"default:false;index"`
+}
+
+func (ptr *PreTradeRejection) TableName() string {
+	return "pre_trade_rejections"
+}
+
+// RiskRule 账户级风控规则
+type RiskRule string
+
+const (
+	RiskRuleDailyLoss     RiskRule = "daily_loss"
+	RiskRuleMaxDrawdown   RiskRule = "max_drawdown"
+	RiskRuleGrossExposure RiskRule = "gross_exposure"
+	RiskRuleOpenOrders    RiskRule = "open_orders"
+)
+
+// RiskBreach 账户级风控触发记录及执行的处置
+type RiskBreach struct {
+	BaseModel
+	UserID            uint     `json:"user_id" gorm:"not null;index"`
+	IsSimulated       bool     `json:"is_simulated" gorm:"default:false"`
+	Rule              RiskRule `json:"rule" gorm:"size:30;not null"`
+	Value             float64  `json:"value" gorm:"type:decimal(30,8)"`     // 触发时的实际值
+	Threshold         float64  `json:"threshold" gorm:"type:decimal(30,8)"` // 限额
+	Message           string   `json:"message" gorm:"size:255"`
+	StrategiesStopped int      `json:"strategies_stopped"`
+	OrdersCanceled    int      `json:"orders_canceled"`
+	PositionsClosed   int      `json:"positions_closed"`
+	ActionErrors      string   `json:"action_errors" gorm:"type:text"` // 处置过程中的错误
+}
+
+func (rb *RiskBreach) TableName() string {
+	return "risk_breaches"
+}
+
+// LiquidationActionType 强平距离监控的处置类型
+type LiquidationActionType string
+
+const (
+	LiquidationActionAddMargin LiquidationActionType = "add_margin" // 追加逐仓保证金
+	LiquidationActionTransfer  LiquidationActionType = "transfer"   // 从现货划转到期货账户
+	LiquidationActionReduce    LiquidationActionType = "reduce"     // 只减仓
+)
+
+// LiquidationAction 强平距离监控执行的处置记录
+type LiquidationAction struct {
+	BaseModel
+	UserID           uint                  `json:"user_id" gorm:"not null;index"`
+	IsSimulated      bool                  `json:"is_simulated" gorm:"default:false"`
+	Symbol           string                `json:"symbol" gorm:"size:20;not null;index"`
+	PositionSide     PositionSide          `json:"position_side" gorm:"size:10"`
+	MarginType       MarginType            `json:"margin_type"`
+	PositionAmt      float64               `json:"position_amt" gorm:"type:decimal(20,8)"`
+	MarkPrice        float64               `json:"mark_price" gorm:"type:decimal(20,8)"`
+	LiquidationPrice float64               `json:"liquidation_price" gorm:"type:decimal(20,8)"`
+	Distance         float64               `json:"distance" gorm:"type:decimal(10,4)"` // 处置前的强平距离百分比
+	Action           LiquidationActionType `json:"action" gorm:"size:20;not null"`
+	Amount           float64               `json:"amount" gorm:"type:decimal(30,8)"` // 保证金或划转金额，减仓时为减仓数量
+	OrderID          string                `json:"order_id" gorm:"size:50"`          // 减仓订单号
+	Success          bool                  `json:"success"`
+	Message          string                `json:"message" gorm:"size:255"`
+}
+
+func (la *LiquidationAction) TableName() string {
+	return "liquidation_actions"
+}
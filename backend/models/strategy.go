@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 )
 
 type StrategyConfig map[string]interface{}
@@ -65,9 +66,11 @@ type Strategy struct {
 	StopLoss     float64        `json:"stop_loss" gorm:"type:decimal(20,8)"`
 	Config       StrategyConfig `json:"config" gorm:"type:json"`
 	State        StrategyState  `json:"state" gorm:"type:json"`
-	IsActive     bool           `json:"is_active" gorm:"default:false"`
-	IsCompleted  bool           `json:"is_completed" gorm:"default:false"`
+	Status       StrategyStatus `json:"status" gorm:"size:20;default:'draft';index"`
+	IsActive     bool           `json:"is_active" gorm:"default:false"`    // 由status派生，保留兼容
+	IsCompleted  bool           `json:"is_completed" gorm:"default:false"` // 由status派生，保留兼容
 	AutoRestart  bool           `json:"auto_restart" gorm:"default:false"`
+	PaperTrading bool           `json:"paper_trading" gorm:"default:false"` // 模拟交易
 
 	User   User    `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Orders []Order `json:"orders,omitempty" gorm:"foreignKey:StrategyID"`
@@ -95,6 +98,9 @@ type Order struct {
 	TimeInForce        string      `json:"time_in_force" gorm:"size:10"`
 	IsWorking          bool        `json:"is_working" gorm:"default:true"`
 	OrigQty            float64     `json:"orig_qty" gorm:"type:decimal(20,8)"`
+	IsSimulated        bool        `json:"is_simulated" gorm:"default:false;index"`
+	OrderListID        string      `json:"order_list_id,omitempty" gorm:"size:50;index"` // 所属OCO订单列表
+	BackfillAttempts   int         `json:"-" gorm:"default:0"`                           // 补录成交明细的尝试次数
 
 	User     User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Strategy *Strategy `json:"strategy,omitempty" gorm:"foreignKey:StrategyID"`
@@ -105,18 +111,27 @@ func (o *Order) TableName() string {
 	return "orders"
 }
 
+// Trade 成交明细，现货与期货共用，同一市场同一交易对的成交ID唯一
 type Trade struct {
 	BaseModel
-	OrderID         string  `json:"order_id" gorm:"size:50;not null;index"`
-	TradeID         string  `json:"trade_id" gorm:"size:50;uniqueIndex"`
-	Symbol          string  `json:"symbol" gorm:"size:20;not null;index"`
-	Price           float64 `json:"price" gorm:"type:decimal(20,8)"`
-	Quantity        float64 `json:"quantity" gorm:"type:decimal(20,8)"`
-	Commission      float64 `json:"commission" gorm:"type:decimal(20,8)"`
-	CommissionAsset string  `json:"commission_asset" gorm:"size:10"`
-	IsBuyer         bool    `json:"is_buyer"`
-	IsMaker         bool    `json:"is_maker"`
-	IsBestMatch     bool    `json:"is_best_match"`
+	UserID          uint      `json:"user_id" gorm:"index"`
+	StrategyID      *uint     `json:"strategy_id" gorm:"index"`
+	Market          string    `json:"market" gorm:"size:10;default:'spot';uniqueIndex:idx_trades_market_symbol_trade,priority:1"` // spot或futures
+	OrderID         string    `json:"order_id" gorm:"size:50;not null;index"`
+	TradeID         string    `json:"trade_id" gorm:"size:50;uniqueIndex:idx_trades_market_symbol_trade,priority:3"`
+	Symbol          string    `json:"symbol" gorm:"size:20;not null;index;uniqueIndex:idx_trades_market_symbol_trade,priority:2"`
+	Side            OrderSide `json:"side" gorm:"size:10"`
+	PositionSide    string    `json:"position_side,omitempty" gorm:"size:10"`
+	Price           float64   `json:"price" gorm:"type:decimal(20,8)"`
+	Quantity        float64   `json:"quantity" gorm:"type:decimal(20,8)"`
+	QuoteQty        float64   `json:"quote_qty" gorm:"type:decimal(20,8)"`
+	Commission      float64   `json:"commission" gorm:"type:decimal(20,8)"`
+	CommissionAsset string    `json:"commission_asset" gorm:"size:10"`
+	RealizedPnL     float64   `json:"realized_pnl" gorm:"column:realized_pnl;type:decimal(20,8);default:0"` // 期货成交的已实现盈亏
+	IsBuyer         bool      `json:"is_buyer"`
+	IsMaker         bool      `json:"is_maker"`
+	IsBestMatch     bool      `json:"is_best_match"`
+	TradeTime       time.Time `json:"trade_time" gorm:"index"`
 
 	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID;references:OrderID"`
 }
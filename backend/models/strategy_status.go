@@ -0,0 +1,82 @@
+package models
+
+// StrategyStatus 策略生命周期状态，现货和期货策略共用
+type StrategyStatus string
+
+const (
+	StrategyStatusDraft     StrategyStatus = "draft"     // 已创建，尚未启动
+	StrategyStatusArmed     StrategyStatus = "armed"     // 已启动，等待触发条件，尚未下单
+	StrategyStatusRunning   StrategyStatus = "running"   // 已下单，可能持有挂单或持仓
+	StrategyStatusPaused    StrategyStatus = "paused"    // 暂停执行，已有挂单和持仓保留
+	StrategyStatusStopping  StrategyStatus = "stopping"  // 正在撤销策略挂单，撤完后结束
+	StrategyStatusCompleted StrategyStatus = "completed" // 已结束，不能再启动
+	StrategyStatusFailed    StrategyStatus = "failed"    // 持续执行失败，处理后可重新启动
+)
+
+// strategyTransitions 允许的状态变更
+var strategyTransitions = map[StrategyStatus][]StrategyStatus{
+	StrategyStatusDraft:    {StrategyStatusArmed},
+	StrategyStatusArmed:    {StrategyStatusRunning, StrategyStatusPaused, StrategyStatusStopping, StrategyStatusCompleted, StrategyStatusFailed},
+	StrategyStatusRunning:  {StrategyStatusPaused, StrategyStatusStopping, StrategyStatusCompleted, StrategyStatusFailed},
+	StrategyStatusPaused:   {StrategyStatusArmed, StrategyStatusStopping},
+	StrategyStatusStopping: {StrategyStatusCompleted, StrategyStatusFailed},
+	StrategyStatusFailed:   {StrategyStatusArmed, StrategyStatusStopping},
+}
+
+// ScheduledStrategyStatuses 调度器需要处理的状态，停止中的策略由调度器撤单
+var ScheduledStrategyStatuses = []StrategyStatus{StrategyStatusArmed, StrategyStatusRunning, StrategyStatusStopping}
+
+// ActiveStrategyStatuses 正常执行中的状态
+var ActiveStrategyStatuses = []StrategyStatus{StrategyStatusArmed, StrategyStatusRunning}
+
+// OpenStrategyStatuses 可能持有挂单的状态，暂停的策略挂单仍会成交
+var OpenStrategyStatuses = []StrategyStatus{StrategyStatusArmed, StrategyStatusRunning, StrategyStatusPaused, StrategyStatusStopping}
+
+// IsValid 是否为已定义的状态
+func (s StrategyStatus) IsValid() bool {
+	switch s {
+	case StrategyStatusDraft, StrategyStatusArmed, StrategyStatusRunning, StrategyStatusPaused,
+		StrategyStatusStopping, StrategyStatusCompleted, StrategyStatusFailed:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo 是否允许变更为目标状态
+func (s StrategyStatus) CanTransitionTo(to StrategyStatus) bool {
+	for _, next := range strategyTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive 兼容is_active字段，等待触发和运行中视为启用
+func (s StrategyStatus) IsActive() bool {
+	return s == StrategyStatusArmed || s == StrategyStatusRunning
+}
+
+// 状态变更的操作方
+const (
+	StrategyActorUser      = "user"
+	StrategyActorScheduler = "scheduler"
+	StrategyActorRisk      = "risk"
+	StrategyActorSystem    = "system"
+)
+
+// StrategyStatusHistory 策略状态变更记录
+type StrategyStatusHistory struct {
+	BaseModel
+	UserID     uint           `json:"user_id" gorm:"not null;index"`
+	Market     string         `json:"market" gorm:"size:10;not null;index:idx_strategy_status_history,priority:1"` // spot或futures
+	StrategyID uint           `json:"strategy_id" gorm:"not null;index:idx_strategy_status_history,priority:2"`
+	FromStatus StrategyStatus `json:"from_status" gorm:"size:20"`
+	ToStatus   StrategyStatus `json:"to_status" gorm:"size:20;not null"`
+	Actor      string         `json:"actor" gorm:"size:20;not null"`
+	Reason     string         `json:"reason" gorm:"size:500"`
+}
+
+func (h *StrategyStatusHistory) TableName() string {
+	return "strategy_status_histories"
+}
@@ -0,0 +1,82 @@
+package models
+
+import "testing"
+
+func TestStrategyStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from StrategyStatus
+		to   StrategyStatus
+		want bool
+	}{
+		{StrategyStatusDraft, StrategyStatusArmed, true},
+		{StrategyStatusDraft, StrategyStatusRunning, false},
+		{StrategyStatusDraft, StrategyStatusCompleted, false},
+		{StrategyStatusArmed, StrategyStatusRunning, true},
+		{StrategyStatusArmed, StrategyStatusPaused, true},
+		{StrategyStatusArmed, StrategyStatusStopping, true},
+		{StrategyStatusArmed, StrategyStatusCompleted, true},
+		{StrategyStatusArmed, StrategyStatusFailed, true},
+		{StrategyStatusArmed, StrategyStatusDraft, false},
+		{StrategyStatusRunning, StrategyStatusArmed, false},
+		{StrategyStatusRunning, StrategyStatusPaused, true},
+		{StrategyStatusRunning, StrategyStatusFailed, true},
+		{StrategyStatusPaused, StrategyStatusArmed, true},
+		{StrategyStatusPaused, StrategyStatusRunning, false},
+		{StrategyStatusPaused, StrategyStatusStopping, true},
+		{StrategyStatusPaused, StrategyStatusCompleted, false},
+		{StrategyStatusStopping, StrategyStatusCompleted, true},
+		{StrategyStatusStopping, StrategyStatusFailed, true},
+		{StrategyStatusStopping, StrategyStatusArmed, false},
+		{StrategyStatusFailed, StrategyStatusArmed, true},
+		{StrategyStatusFailed, StrategyStatusStopping, true},
+		{StrategyStatusFailed, StrategyStatusRunning, false},
+		{StrategyStatusCompleted, StrategyStatusArmed, false},
+		{StrategyStatusCompleted, StrategyStatusDraft, false},
+		{StrategyStatus("unknown"), StrategyStatusArmed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategyTransitionsTargetDefinedStatuses(t *testing.T) {
+	for from, targets := range strategyTransitions {
+		if !from.IsValid() {
+			t.Errorf("transition source %q is not a defined status", from)
+		}
+		for _, to := range targets {
+			if !to.IsValid() {
+				t.Errorf("transition %s -> %q targets an undefined status", from, to)
+			}
+			if to == from {
+				t.Errorf("transition %s -> %s is a self transition", from, to)
+			}
+		}
+	}
+}
+
+func TestStrategyStatusIsActive(t *testing.T) {
+	tests := []struct {
+		status StrategyStatus
+		want   bool
+	}{
+		{StrategyStatusDraft, false},
+		{StrategyStatusArmed, true},
+		{StrategyStatusRunning, true},
+		{StrategyStatusPaused, false},
+		{StrategyStatusStopping, false},
+		{StrategyStatusCompleted, false},
+		{StrategyStatusFailed, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.status.IsActive(); got != tt.want {
+			t.Errorf("%s.IsActive() = %v, want %v", tt.status, got, tt.want)
+		}
+	}
+}
@@ -7,16 +7,17 @@ import (
 
 type User struct {
 	BaseModel
-	Username    string     `json:"username" gorm:"uniqueIndex;size:50;not null"`
-	Email       string     `json:"email" gorm:"uniqueIndex;size:100;not null"`
-	Password    string     `json:"-" gorm:"size:255;not null"`
-	Role        UserRole   `json:"role" gorm:"default:'user'"`
-	Status      UserStatus `json:"status" gorm:"default:'pending'"`
-	APIKey      string     `json:"-" gorm:"size:255"`
-	SecretKey   string     `json:"-" gorm:"size:255"`
-	IsEncrypted bool       `json:"is_encrypted" gorm:"default:false"`
-	LastLoginAt *time.Time `json:"last_login_at"`
-	HasAPIKey   bool       `json:"has_api_key" gorm:"-"`
+	Username     string     `json:"username" gorm:"uniqueIndex;size:50;not null"`
+	Email        string     `json:"email" gorm:"uniqueIndex;size:100;not null"`
+	Password     string     `json:"-" gorm:"size:255;not null"`
+	Role         UserRole   `json:"role" gorm:"default:'user'"`
+	Status       UserStatus `json:"status" gorm:"default:'pending'"`
+	APIKey       string     `json:"-" gorm:"size:255"`
+	SecretKey    string     `json:"-" gorm:"size:255"`
+	IsEncrypted  bool       `json:"is_encrypted" gorm:"default:false"`
+	LastLoginAt  *time.Time `json:"last_login_at"`
+	HasAPIKey    bool       `json:"has_api_key" gorm:"-"`
+	PaperTrading bool       `json:"paper_trading" gorm:"default:false"` // 开启后该用户所有策略使用模拟撮合
 
 	Strategies           []Strategy               `json:"strategies,omitempty" gorm:"foreignKey:UserID"`
 	FuturesStrategies    []FuturesStrategy        `json:"futures_strategies,omitempty" gorm:"foreignKey:UserID"`
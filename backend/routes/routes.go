@@ -16,6 +16,11 @@ func SetupRoutes(r *gin.Engine, executor *services.StrategyExecutor) {
 	withdrawalController := controllers.NewWithdrawalController()
 	generalController := controllers.NewGeneralController()
 	quantitativeController := controllers.NewQuantitativeController(executor)
+	paperTradingController := controllers.NewPaperTradingController()
+	backtestController := controllers.NewBacktestController()
+	pnlController := controllers.NewPnLController()
+	portfolioController := controllers.NewPortfolioController()
+	riskController := controllers.NewRiskController()
 
 	r.Use(middleware.CORSMiddleware())
 	r.Use(middleware.LoggerMiddleware())
@@ -43,11 +48,18 @@ func SetupRoutes(r *gin.Engine, executor *services.StrategyExecutor) {
 			authenticated.POST("/order", generalController.CreateOrder)
 			authenticated.DELETE("/order/:order_id", generalController.CancelOrder)
 			authenticated.POST("/batch-cancel-orders", generalController.BatchCancelOrders)
+			authenticated.GET("/oco-orders", generalController.GetOrderLists)
+			authenticated.POST("/oco-orders", generalController.CreateOCOOrder)
+			authenticated.DELETE("/oco-orders/:order_list_id", generalController.CancelOCOOrder)
+			authenticated.GET("/bracket-orders", generalController.GetBracketOrders)
+			authenticated.POST("/bracket-orders", generalController.CreateBracketOrder)
+			authenticated.DELETE("/bracket-orders/:id", generalController.CancelBracketOrder)
 			authenticated.GET("/cancelled-orders", generalController.GetCancelledOrders)
 			authenticated.GET("/trading-symbols", generalController.GetTradingSymbols)
 			authenticated.GET("/futures-trading-symbols", generalController.GetFuturesTradingSymbols)
 			authenticated.GET("/price", generalController.GetPrice)
 			authenticated.GET("/diagnose", controllers.DiagnoseBinanceAPI)
+			authenticated.GET("/pnl", pnlController.GetPnL)
 
 			strategies := authenticated.Group("/strategies")
 			strategies.Use(middleware.UserRateLimitMiddleware(100, time.Minute))
@@ -57,8 +69,12 @@ func SetupRoutes(r *gin.Engine, executor *services.StrategyExecutor) {
 				strategies.GET("/:strategy_id", strategyController.GetStrategyByID)
 				strategies.PUT("/:strategy_id", strategyController.UpdateStrategy)
 				strategies.POST("/:strategy_id/toggle", strategyController.ToggleStrategy)
+				strategies.POST("/:strategy_id/status", strategyController.SetStrategyStatus)
+				strategies.GET("/:strategy_id/history", strategyController.GetStrategyStatusHistory)
 				strategies.DELETE("/:strategy_id", strategyController.DeleteStrategy)
 				strategies.GET("/:strategy_id/stats", strategyController.GetStrategyStats)
+				strategies.POST("/:strategy_id/backtest", backtestController.RunBacktest)
+				strategies.GET("/:strategy_id/backtests", backtestController.GetBacktestRuns)
 			}
 
 			futures := authenticated.Group("/futures")
@@ -67,12 +83,20 @@ func SetupRoutes(r *gin.Engine, executor *services.StrategyExecutor) {
 				futures.GET("/strategies", futuresController.GetUserFuturesStrategies)
 				futures.POST("/strategy", futuresController.CreateFuturesStrategy)
 				futures.GET("/strategy/:strategy_id", futuresController.GetFuturesStrategyByID)
+				futures.GET("/strategy/:strategy_id/carry", futuresController.GetCashCarryReport)
 				futures.PUT("/strategy/:strategy_id", futuresController.UpdateFuturesStrategy)
 				futures.POST("/strategy/:strategy_id/toggle", futuresController.ToggleFuturesStrategy)
+				futures.POST("/strategy/:strategy_id/status", futuresController.SetFuturesStrategyStatus)
+				futures.GET("/strategy/:strategy_id/history", futuresController.GetFuturesStrategyStatusHistory)
 				futures.DELETE("/strategy/:strategy_id", futuresController.DeleteFuturesStrategy)
 				futures.GET("/positions", futuresController.GetUserPositions)
 				futures.POST("/positions/sync", futuresController.UpdatePositions)
+				futures.GET("/position-mode", futuresController.GetPositionMode)
+				futures.PUT("/position-mode", futuresController.SetPositionMode)
 				futures.GET("/stats", futuresController.GetFuturesStats)
+				futures.GET("/funding-rates", futuresController.GetFundingRates)
+				futures.GET("/income", futuresController.GetIncomes)
+				futures.POST("/income/sync", futuresController.SyncIncomes)
 			}
 
 			dual := authenticated.Group("/dual")
@@ -128,6 +152,46 @@ func SetupRoutes(r *gin.Engine, executor *services.StrategyExecutor) {
 				// 性能统计
 				quantitative.GET("/performance", quantitativeController.GetPerformanceStats)
 			}
+
+			// 账户权益与绩效路由
+			portfolio := authenticated.Group("/portfolio")
+			portfolio.Use(middleware.UserRateLimitMiddleware(60, time.Minute))
+			{
+				portfolio.GET("/equity", portfolioController.GetEquityCurve)
+				portfolio.GET("/snapshots", portfolioController.GetEquitySnapshots)
+				portfolio.GET("/performance", portfolioController.GetPerformance)
+				portfolio.POST("/snapshot", portfolioController.TakeSnapshot)
+			}
+
+			// 模拟交易路由
+			paper := authenticated.Group("/paper")
+			paper.Use(middleware.UserRateLimitMiddleware(100, time.Minute))
+			{
+				paper.PUT("/mode", paperTradingController.SetPaperTrading)
+				paper.GET("/account", paperTradingController.GetAccount)
+				paper.GET("/orders", paperTradingController.GetOrders)
+				paper.POST("/reset", paperTradingController.ResetAccount)
+			}
+
+			// 风控路由
+			risk := authenticated.Group("/risk")
+			risk.Use(middleware.UserRateLimitMiddleware(60, time.Minute))
+			{
+				risk.GET("/policy", riskController.GetPolicy)
+				risk.PUT("/policy", riskController.UpdatePolicy)
+				risk.GET("/rejections", riskController.GetRejections)
+				risk.GET("/breaches", riskController.GetBreaches)
+				risk.GET("/liquidation-actions", riskController.GetLiquidationActions)
+				risk.POST("/resume", riskController.Resume)
+			}
+
+			// 回测路由
+			backtests := authenticated.Group("/backtests")
+			backtests.Use(middleware.UserRateLimitMiddleware(30, time.Minute))
+			{
+				backtests.GET("/:backtest_id", backtestController.GetBacktestRun)
+				backtests.POST("/klines/import", backtestController.ImportKlines)
+			}
 		}
 
 		admin := api.Group("/admin")
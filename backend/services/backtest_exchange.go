@@ -0,0 +1,525 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+var errBacktestUnsupported = errors.New("回测仅支持现货单交易对")
+
+// BacktestExchange 实现Exchange接口
+var _ Exchange = (*BacktestExchange)(nil)
+
+// backtestOrder 回测撮合中的委托
+type backtestOrder struct {
+	result   OrderResult
+	reserved float64 // 挂单冻结的资产数量
+}
+
+// BacktestExchange 基于历史K线的回测交易所，策略按K线收盘价逐根回放
+// 限价挂单在后续K线的最高/最低价穿越委托价时按委托价成交（挂单方），市价单和可立即成交的限价单按收盘价成交（吃单方）
+type BacktestExchange struct {
+	symbol     string
+	baseAsset  string
+	quoteAsset string
+	klines     []KlineData
+	cursor     int
+	feeRate    float64
+
+	balances   map[string]*AssetBalance
+	orders     map[string]*backtestOrder
+	openOrders []string
+	trades     models.BacktestTrades
+	totalFees  float64 // 折算为计价资产
+	nextID     int64
+}
+
+// NewBacktestExchange 创建回测交易所，klines需按时间升序排列
+func NewBacktestExchange(symbol string, klines []KlineData, feeRate, initialQuote, initialBase float64) *BacktestExchange {
+	base, quote := splitSymbolBySuffix(symbol)
+	return &BacktestExchange{
+		symbol:     symbol,
+		baseAsset:  base,
+		quoteAsset: quote,
+		klines:     klines,
+		feeRate:    feeRate,
+		balances: map[string]*AssetBalance{
+			base:  {Asset: base, Free: initialBase},
+			quote: {Asset: quote, Free: initialQuote},
+		},
+		orders: make(map[string]*backtestOrder),
+	}
+}
+
+func (be *BacktestExchange) Name() string {
+	return "backtest"
+}
+
+// current 当前回放到的K线
+func (be *BacktestExchange) current() KlineData {
+	return be.klines[be.cursor]
+}
+
+// Step 推进到第i根K线，并用该K线的价格区间撮合此前的挂单
+func (be *BacktestExchange) Step(i int) {
+	be.cursor = i
+	kline := be.current()
+
+	remaining := be.openOrders[:0]
+	for _, id := range be.openOrders {
+		bo := be.orders[id]
+		crossed := (bo.result.Side == "BUY" && kline.Low <= bo.result.Price) ||
+			(bo.result.Side == "SELL" && kline.High >= bo.result.Price)
+		if !crossed {
+			remaining = append(remaining, id)
+			continue
+		}
+
+		be.releaseReserved(bo)
+		if err := be.fill(bo, bo.result.Price, true); err != nil {
+			bo.result.Status = paperStatusExpired
+		}
+	}
+	be.openOrders = remaining
+}
+
+// Equity 按当前收盘价计算的账户权益（计价资产）
+func (be *BacktestExchange) Equity() float64 {
+	base := be.balances[be.baseAsset]
+	quote := be.balances[be.quoteAsset]
+	return quote.Free + quote.Locked + (base.Free+base.Locked)*be.current().Close
+}
+
+// Trades 回测成交明细
+func (be *BacktestExchange) Trades() models.BacktestTrades {
+	return be.trades
+}
+
+// TotalFees 折算为计价资产的手续费总额
+func (be *BacktestExchange) TotalFees() float64 {
+	return be.totalFees
+}
+
+// OpenOrderCount 未成交的挂单数
+func (be *BacktestExchange) OpenOrderCount() int {
+	return len(be.openOrders)
+}
+
+func (be *BacktestExchange) checkSymbol(symbol string) error {
+	if symbol != be.symbol {
+		return fmt.Errorf("%w: %s", ErrInvalidSymbol, symbol)
+	}
+	return nil
+}
+
+func (be *BacktestExchange) releaseReserved(bo *backtestOrder) {
+	asset := be.baseAsset
+	if bo.result.Side == "BUY" {
+		asset = be.quoteAsset
+	}
+	be.balances[asset].Locked -= bo.reserved
+	be.balances[asset].Free += bo.reserved
+	bo.reserved = 0
+}
+
+// fill 按指定价格全部成交：买入手续费扣基础资产，卖出手续费扣计价资产
+func (be *BacktestExchange) fill(bo *backtestOrder, price float64, isMaker bool) error {
+	base := be.balances[be.baseAsset]
+	quote := be.balances[be.quoteAsset]
+	qty := bo.result.OrigQty
+	quoteQty := qty * price
+
+	var commission, feeInQuote float64
+	var commissionAsset string
+	if bo.result.Side == "BUY" {
+		if quote.Free+paperBalanceEpsilon < quoteQty {
+			return fmt.Errorf("%w: 需要%.8f %s，可用%.8f", ErrInsufficientBalance, quoteQty, be.quoteAsset, quote.Free)
+		}
+		commission = qty * be.feeRate
+		commissionAsset = be.baseAsset
+		feeInQuote = commission * price
+		quote.Free -= quoteQty
+		base.Free += qty - commission
+	} else {
+		if base.Free+paperBalanceEpsilon < qty {
+			return fmt.Errorf("%w: 需要%.8f %s，可用%.8f", ErrInsufficientBalance, qty, be.baseAsset, base.Free)
+		}
+		commission = quoteQty * be.feeRate
+		commissionAsset = be.quoteAsset
+		feeInQuote = commission
+		base.Free -= qty
+		quote.Free += quoteQty - commission
+	}
+
+	kline := be.current()
+	bo.result.Status = paperStatusFilled
+	bo.result.ExecutedQty = qty
+	bo.result.CumulativeQuoteQty = quoteQty
+	bo.result.AvgPrice = price
+	bo.result.UpdateTime = kline.CloseTime
+	bo.result.Fills = []OrderFill{{
+		TradeID:         int64(len(be.trades) + 1),
+		Price:           price,
+		Quantity:        qty,
+		Commission:      commission,
+		CommissionAsset: commissionAsset,
+	}}
+
+	be.totalFees += feeInQuote
+	be.trades = append(be.trades, models.BacktestTrade{
+		Time:            kline.CloseTime,
+		OrderID:         bo.result.OrderID,
+		Side:            bo.result.Side,
+		Type:            bo.result.Type,
+		Price:           price,
+		Quantity:        qty,
+		QuoteQty:        quoteQty,
+		Commission:      commission,
+		CommissionAsset: commissionAsset,
+		IsMaker:         isMaker,
+	})
+	return nil
+}
+
+// ===== 行情 =====
+
+func (be *BacktestExchange) GetPrice(ctx context.Context, symbol string) (float64, error) {
+	if err := be.checkSymbol(symbol); err != nil {
+		return 0, err
+	}
+	return be.current().Close, nil
+}
+
+func (be *BacktestExchange) GetFuturesPrice(ctx context.Context, symbol string) (float64, error) {
+	return 0, errBacktestUnsupported
+}
+
+// GetOrderBook 以收盘价构造单档深度
+func (be *BacktestExchange) GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	if err := be.checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	kline := be.current()
+	return &OrderBook{
+		Symbol:       symbol,
+		LastUpdateID: kline.CloseTime,
+		Bids:         []PriceLevel{{Price: kline.Close, Quantity: kline.Volume}},
+		Asks:         []PriceLevel{{Price: kline.Close, Quantity: kline.Volume}},
+	}, nil
+}
+
+func (be *BacktestExchange) GetFuturesOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	return nil, errBacktestUnsupported
+}
+
+// GetKlines 返回截至当前K线的历史数据，不会泄露未来K线
+func (be *BacktestExchange) GetKlines(symbol string, interval string, limit int) ([]KlineData, error) {
+	if err := be.checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	end := be.cursor + 1
+	start := 0
+	if limit > 0 && end-limit > 0 {
+		start = end - limit
+	}
+	return append([]KlineData(nil), be.klines[start:end]...), nil
+}
+
+func (be *BacktestExchange) GetFuturesKlines(symbol string, interval string, limit int) ([]KlineData, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) Get24hrTicker(symbol string) (*TickerData, error) {
+	if err := be.checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	kline := be.current()
+	return &TickerData{
+		Symbol:    symbol,
+		LastPrice: kline.Close,
+		Volume:    kline.Volume,
+		HighPrice: kline.High,
+		LowPrice:  kline.Low,
+	}, nil
+}
+
+func (be *BacktestExchange) GetTopSymbols(limit int) ([]string, error) {
+	return []string{be.symbol}, nil
+}
+
+func (be *BacktestExchange) GetTradingSymbols(ctx context.Context) ([]SymbolInfo, error) {
+	info, _ := be.GetSymbolInfo(be.symbol)
+	return []SymbolInfo{*info}, nil
+}
+
+func (be *BacktestExchange) GetFuturesTradingSymbols(ctx context.Context) ([]SymbolInfo, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) GetFundingRates(ctx context.Context) ([]FundingRateInfo, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) GetSymbolInfo(symbol string) (*SymbolInfo, error) {
+	if err := be.checkSymbol(symbol); err != nil {
+		return nil, err
+	}
+	return &SymbolInfo{
+		Symbol:     be.symbol,
+		BaseAsset:  be.baseAsset,
+		QuoteAsset: be.quoteAsset,
+	}, nil
+}
+
+func (be *BacktestExchange) GetFuturesSymbolInfo(symbol string) (*SymbolInfo, error) {
+	return be.GetSymbolInfo(symbol)
+}
+
+// ===== 订单 =====
+
+// CreateSpotOrder 创建回测现货订单，仅支持市价单和限价单
+func (be *BacktestExchange) CreateSpotOrder(ctx context.Context, order *models.Order) (*OrderResult, error) {
+	if err := be.checkSymbol(order.Symbol); err != nil {
+		return nil, err
+	}
+	if order.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	side := strings.ToUpper(string(order.Side))
+	orderType := strings.ToUpper(string(order.Type))
+	if side != "BUY" && side != "SELL" {
+		return nil, ErrInvalidOrderSide
+	}
+
+	be.nextID++
+	bo := &backtestOrder{result: OrderResult{
+		OrderID:       fmt.Sprintf("BT-%d", be.nextID),
+		ClientOrderID: order.ClientOrderID,
+		Symbol:        order.Symbol,
+		Side:          side,
+		Type:          orderType,
+		Price:         order.Price,
+		OrigQty:       order.Quantity,
+		Status:        paperStatusNew,
+		UpdateTime:    be.current().CloseTime,
+	}}
+
+	price := be.current().Close
+	switch orderType {
+	case "MARKET":
+		if err := be.fill(bo, price, false); err != nil {
+			return nil, err
+		}
+	case "LIMIT", "LIMIT_MAKER":
+		if bo.result.Price <= 0 {
+			return nil, ErrInvalidPrice
+		}
+		if isLimitMarketable(side, bo.result.Price, price) {
+			if orderType == "LIMIT_MAKER" {
+				return nil, errors.New("LIMIT_MAKER订单会立即成交，已拒绝")
+			}
+			if err := be.fill(bo, price, false); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		// 挂单冻结资产：买单冻结计价资产，卖单冻结基础资产
+		asset, amount := be.baseAsset, bo.result.OrigQty
+		if side == "BUY" {
+			asset, amount = be.quoteAsset, bo.result.OrigQty*bo.result.Price
+		}
+		balance := be.balances[asset]
+		if balance.Free+paperBalanceEpsilon < amount {
+			return nil, fmt.Errorf("%w: 需要%.8f %s，可用%.8f", ErrInsufficientBalance, amount, asset, balance.Free)
+		}
+		balance.Free -= amount
+		balance.Locked += amount
+		bo.reserved = amount
+		be.openOrders = append(be.openOrders, bo.result.OrderID)
+	default:
+		return nil, fmt.Errorf("%w: 回测暂不支持%s", ErrInvalidOrderType, orderType)
+	}
+
+	be.orders[bo.result.OrderID] = bo
+	result := bo.result
+	return &result, nil
+}
+
+func (be *BacktestExchange) CancelSpotOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	bo, ok := be.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	if bo.result.Status != paperStatusNew {
+		return nil, fmt.Errorf("订单状态为%s，无法取消", bo.result.Status)
+	}
+
+	be.releaseReserved(bo)
+	bo.result.Status = paperStatusCanceled
+	for i, id := range be.openOrders {
+		if id == orderID {
+			be.openOrders = append(be.openOrders[:i], be.openOrders[i+1:]...)
+			break
+		}
+	}
+
+	result := bo.result
+	return &result, nil
+}
+
+func (be *BacktestExchange) GetSpotOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	bo, ok := be.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	result := bo.result
+	return &result, nil
+}
+
+func (be *BacktestExchange) GetSpotOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	if _, ok := be.orders[orderID]; !ok {
+		return nil, ErrOrderNotFound
+	}
+
+	var fills []OrderFill
+	for i, trade := range be.trades {
+		if trade.OrderID != orderID {
+			continue
+		}
+		fills = append(fills, OrderFill{
+			TradeID:         int64(i + 1),
+			Price:           trade.Price,
+			Quantity:        trade.Quantity,
+			QuoteQty:        trade.QuoteQty,
+			Commission:      trade.Commission,
+			CommissionAsset: trade.CommissionAsset,
+			IsBuyer:         trade.Side == "BUY",
+			IsMaker:         trade.IsMaker,
+			Time:            trade.Time,
+		})
+	}
+	return fills, nil
+}
+
+func (be *BacktestExchange) CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*OrderResult, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) CancelFuturesOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) GetFuturesOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) GetFuturesOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	return nil, errBacktestUnsupported
+}
+
+// CreateSpotOCO 回测按K线逐根撮合单个委托，不支持订单列表
+func (be *BacktestExchange) CreateSpotOCO(ctx context.Context, req *OCORequest) (*OrderListResult, error) {
+	return nil, fmt.Errorf("%w: 回测暂不支持OCO", ErrInvalidOrderType)
+}
+
+func (be *BacktestExchange) CancelSpotOCO(ctx context.Context, symbol, orderListID string) (*OrderListResult, error) {
+	return nil, fmt.Errorf("%w: 回测暂不支持OCO", ErrInvalidOrderType)
+}
+
+// ===== 持仓 =====
+
+func (be *BacktestExchange) GetFuturesPositions(ctx context.Context) ([]*PositionInfo, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) SetFuturesLeverage(ctx context.Context, symbol string, leverage int) error {
+	return errBacktestUnsupported
+}
+
+func (be *BacktestExchange) SetFuturesMarginType(ctx context.Context, symbol string, marginType models.MarginType) error {
+	return errBacktestUnsupported
+}
+
+func (be *BacktestExchange) GetFuturesPositionMode(ctx context.Context) (bool, error) {
+	return false, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) SetFuturesPositionMode(ctx context.Context, dualSide bool) error {
+	return errBacktestUnsupported
+}
+
+func (be *BacktestExchange) ModifyFuturesIsolatedMargin(ctx context.Context, symbol string, positionSide models.PositionSide, amount float64) error {
+	return errBacktestUnsupported
+}
+
+// ===== 资产 =====
+
+func (be *BacktestExchange) GetAccountInfo(ctx context.Context) (*SpotAccount, error) {
+	return &SpotAccount{
+		CanTrade: true,
+		Balances: []AssetBalance{
+			*be.balances[be.baseAsset],
+			*be.balances[be.quoteAsset],
+		},
+		UpdateTime: be.current().CloseTime,
+	}, nil
+}
+
+func (be *BacktestExchange) GetFuturesAccountInfo(ctx context.Context) (*FuturesAccount, error) {
+	return nil, errBacktestUnsupported
+}
+
+func (be *BacktestExchange) TransferToFutures(ctx context.Context, asset string, amount float64) error {
+	return errBacktestUnsupported
+}
+
+func (be *BacktestExchange) Withdraw(ctx context.Context, asset, address, network string, amount float64, addressTag string) (string, error) {
+	return "", errors.New("回测账户不支持提现")
+}
+
+func (be *BacktestExchange) GetFuturesIncomeHistory(ctx context.Context, incomeType string, startTime time.Time, limit int) ([]FuturesIncomeRecord, error) {
+	return []FuturesIncomeRecord{}, nil
+}
+
+func (be *BacktestExchange) GetWithdrawHistory(ctx context.Context, asset string, limit int) ([]*WithdrawRecord, error) {
+	return []*WithdrawRecord{}, nil
+}
+
+// ===== 账户 =====
+
+func (be *BacktestExchange) ValidateAPICredentials(ctx context.Context) error {
+	return nil
+}
+
+func (be *BacktestExchange) DiagnoseAPIConnection(ctx context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"exchange": be.Name(),
+		"symbol":   be.symbol,
+		"klines":   len(be.klines),
+		"cursor":   be.cursor,
+	}, nil
+}
+
+func (be *BacktestExchange) Close() error {
+	return nil
+}
+
+// maxDrawdownPercent 根据权益曲线计算最大回撤百分比
+func maxDrawdownPercent(equity []float64) float64 {
+	var peak, maxDrawdown float64
+	for _, value := range equity {
+		peak = math.Max(peak, value)
+		if peak > 0 {
+			maxDrawdown = math.Max(maxDrawdown, (peak-value)/peak*100)
+		}
+	}
+	return maxDrawdown
+}
@@ -0,0 +1,507 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	BacktestSourceExchange = "exchange" // 通过GetKlines拉取并写入本地缓存
+	BacktestSourceCache    = "cache"    // 仅使用本地缓存，包括导入的CSV
+
+	backtestDefaultInterval   = "1h"
+	backtestDefaultLimit      = 500
+	backtestMaxLimit          = 5000
+	backtestMaxExchangeKlines = 1000
+)
+
+// backtestStrategyTypes 支持回测的现货策略类型
+var backtestStrategyTypes = map[models.StrategyType]bool{
+	models.StrategyGrid:        true,
+	models.StrategyDCA:         true,
+	models.StrategyIceberg:     true,
+	models.StrategySlowIceberg: true,
+	models.StrategyTWAP:        true,
+	models.StrategyVWAP:        true,
+}
+
+// BacktestRequest 回测参数，时间均为毫秒时间戳
+type BacktestRequest struct {
+	Interval     string   `json:"interval"`
+	Limit        int      `json:"limit"`
+	StartTime    int64    `json:"start_time"`
+	EndTime      int64    `json:"end_time"`
+	Source       string   `json:"source"`
+	InitialQuote float64  `json:"initial_quote"`
+	InitialBase  float64  `json:"initial_base"`
+	FeeRate      *float64 `json:"fee_rate"`
+}
+
+type BacktestService struct {
+	db          *gorm.DB
+	userService *UserService
+}
+
+func NewBacktestService() *BacktestService {
+	return &BacktestService{
+		db:          config.DB,
+		userService: NewUserService(),
+	}
+}
+
+// RunBacktest 在历史K线上回放策略并保存回测记录
+func (bs *BacktestService) RunBacktest(userID, strategyID uint, req *BacktestRequest) (*models.BacktestRun, error) {
+	var strategy models.Strategy
+	if err := bs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+		return nil, errors.New("策略不存在")
+	}
+	if !backtestStrategyTypes[strategy.Type] {
+		return nil, fmt.Errorf("策略类型%s暂不支持回测", strategy.Type)
+	}
+
+	if err := bs.normalizeRequest(req); err != nil {
+		return nil, err
+	}
+
+	klines, err := bs.loadKlines(userID, strategy.Symbol, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(klines) < 2 {
+		return nil, fmt.Errorf("%s %s 可用K线不足，请先拉取或导入K线数据", strategy.Symbol, req.Interval)
+	}
+
+	run := &models.BacktestRun{
+		UserID:       userID,
+		StrategyID:   strategy.ID,
+		StrategyType: strategy.Type,
+		Symbol:       strategy.Symbol,
+		Interval:     req.Interval,
+		KlineSource:  req.Source,
+		StartTime:    time.UnixMilli(klines[0].OpenTime),
+		EndTime:      time.UnixMilli(klines[len(klines)-1].CloseTime),
+		KlineCount:   len(klines),
+		FeeRate:      *req.FeeRate,
+		InitialQuote: req.InitialQuote,
+		InitialBase:  req.InitialBase,
+		Config:       strategy.Config,
+		Status:       models.BacktestStatusCompleted,
+	}
+
+	if err := bs.replay(&strategy, klines, run); err != nil {
+		run.Status = models.BacktestStatusFailed
+		run.ErrorMessage = err.Error()
+	}
+
+	if err := bs.db.Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func (bs *BacktestService) normalizeRequest(req *BacktestRequest) error {
+	if req.Interval == "" {
+		req.Interval = backtestDefaultInterval
+	}
+	if _, err := klineIntervalDuration(req.Interval); err != nil {
+		return err
+	}
+	if req.Limit <= 0 {
+		req.Limit = backtestDefaultLimit
+	}
+	if req.Limit > backtestMaxLimit {
+		req.Limit = backtestMaxLimit
+	}
+	if req.Source == "" {
+		req.Source = BacktestSourceExchange
+	}
+	if req.Source != BacktestSourceExchange && req.Source != BacktestSourceCache {
+		return fmt.Errorf("不支持的K线来源: %s", req.Source)
+	}
+	if req.InitialQuote < 0 || req.InitialBase < 0 {
+		return errors.New("初始资金不能为负数")
+	}
+	if req.InitialQuote == 0 && req.InitialBase == 0 {
+		req.InitialQuote = 10000
+		if config.AppConfig != nil && config.AppConfig.Paper.InitialUSDT > 0 {
+			req.InitialQuote = config.AppConfig.Paper.InitialUSDT
+		}
+	}
+	if req.FeeRate == nil {
+		feeRate := 0.001
+		if config.AppConfig != nil {
+			feeRate = config.AppConfig.Paper.SpotFeeRate
+		}
+		req.FeeRate = &feeRate
+	}
+	if *req.FeeRate < 0 || *req.FeeRate >= 0.1 {
+		return errors.New("手续费率必须在0到0.1之间")
+	}
+	return nil
+}
+
+// replay 逐根K线回放：先撮合挂单，再同步订单状态，最后以收盘价执行一轮策略
+// 策略副本和订单写在事务中并最终回滚，复用StrategyService的执行逻辑而不污染实盘数据
+func (bs *BacktestService) replay(strategy *models.Strategy, klines []KlineData, run *models.BacktestRun) error {
+	exchange := NewBacktestExchange(strategy.Symbol, klines, run.FeeRate, run.InitialQuote, run.InitialBase)
+
+	var clock time.Time
+	now := func() time.Time { return clock }
+	tx := bs.db.Session(&gorm.Session{NowFunc: now}).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	sandbox := models.Strategy{
+		UserID:       strategy.UserID,
+		Name:         strategy.Name,
+		Symbol:       strategy.Symbol,
+		Type:         strategy.Type,
+		Side:         strategy.Side,
+		Quantity:     strategy.Quantity,
+		Price:        strategy.Price,
+		TriggerPrice: strategy.TriggerPrice,
+		StopPrice:    strategy.StopPrice,
+		TakeProfit:   strategy.TakeProfit,
+		StopLoss:     strategy.StopLoss,
+		Config:       strategy.Config,
+		Status:       models.StrategyStatusArmed,
+		IsActive:     true,
+	}
+	clock = time.UnixMilli(klines[0].OpenTime)
+	if err := tx.Omit(clause.Associations).Create(&sandbox).Error; err != nil {
+		return err
+	}
+
+	runner := &StrategyService{db: tx, userService: bs.userService, now: now}
+	equity := make([]float64, 0, len(klines))
+	var execErrors int
+
+	for i, kline := range klines {
+		exchange.Step(i)
+		clock = time.UnixMilli(kline.CloseTime)
+
+		var openOrders []models.Order
+		tx.Where("strategy_id = ? AND status IN ?", sandbox.ID, []string{"NEW", "PARTIALLY_FILLED"}).Find(&openOrders)
+		for j := range openOrders {
+			if err := runner.syncOrderStatus(&sandbox, &openOrders[j], exchange); err != nil {
+				return err
+			}
+		}
+
+		if !sandbox.IsCompleted {
+			if err := runner.executeWithExchange(&sandbox, exchange); err != nil {
+				execErrors++
+				if execErrors <= 5 {
+					log.Printf("回测策略%d在%s执行失败: %v", strategy.ID, clock.Format(time.RFC3339), err)
+				}
+			}
+			if err := tx.First(&sandbox, sandbox.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		equity = append(equity, exchange.Equity())
+	}
+
+	trades := exchange.Trades()
+	run.InitialEquity = equity[0]
+	run.FinalEquity = equity[len(equity)-1]
+	run.TotalPnL = run.FinalEquity - run.InitialEquity
+	if run.InitialEquity > 0 {
+		run.PnLPercent = run.TotalPnL / run.InitialEquity * 100
+		run.FeeDrag = exchange.TotalFees() / run.InitialEquity * 100
+	}
+	run.MaxDrawdown = maxDrawdownPercent(equity)
+	run.TotalFees = exchange.TotalFees()
+	run.FillCount = len(trades)
+	for _, trade := range trades {
+		if trade.Side == "BUY" {
+			run.BuyCount++
+		} else {
+			run.SellCount++
+		}
+	}
+	run.OpenOrders = exchange.OpenOrderCount()
+	run.Trades = trades
+
+	if execErrors > 0 && len(trades) == 0 {
+		return fmt.Errorf("回测期间策略执行失败%d次且没有任何成交，请检查初始资金和策略配置", execErrors)
+	}
+	return nil
+}
+
+// loadKlines 按请求加载K线：exchange来源先拉取最新K线写入缓存，再统一从缓存读取
+func (bs *BacktestService) loadKlines(userID uint, symbol string, req *BacktestRequest) ([]KlineData, error) {
+	if req.Source == BacktestSourceExchange {
+		exchange, err := bs.userService.GetExchange(userID)
+		if err != nil {
+			exchange, err = NewPublicExchange()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		limit := req.Limit
+		if limit > backtestMaxExchangeKlines {
+			limit = backtestMaxExchangeKlines
+		}
+		klines, err := exchange.GetKlines(symbol, req.Interval, limit)
+		if err != nil {
+			return nil, fmt.Errorf("获取K线失败: %v", err)
+		}
+		if _, err := bs.saveKlines(symbol, req.Interval, klines, BacktestSourceExchange); err != nil {
+			return nil, err
+		}
+	}
+
+	query := bs.db.Model(&models.KlineCache{}).Where("symbol = ? AND kline_interval = ?", symbol, req.Interval)
+	if req.StartTime > 0 {
+		query = query.Where("open_time >= ?", req.StartTime)
+	}
+	if req.EndTime > 0 {
+		query = query.Where("open_time <= ?", req.EndTime)
+	}
+
+	// 指定开始时间时从开始时间向后取，否则取最近的K线
+	var rows []models.KlineCache
+	if req.StartTime > 0 {
+		query = query.Order("open_time asc")
+	} else {
+		query = query.Order("open_time desc")
+	}
+	if err := query.Limit(req.Limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].OpenTime < rows[j].OpenTime })
+
+	klines := make([]KlineData, 0, len(rows))
+	for _, row := range rows {
+		klines = append(klines, KlineData{
+			OpenTime:  row.OpenTime,
+			Open:      row.Open,
+			High:      row.High,
+			Low:       row.Low,
+			Close:     row.Close,
+			Volume:    row.Volume,
+			CloseTime: row.CloseTime,
+		})
+	}
+
+	// 交易所来源只拉取最近的K线，缓存中缺少的历史区间不能静默截断
+	if err := checkKlineCoverage(klines, req, time.Now().UnixMilli()); err != nil {
+		return nil, err
+	}
+	return klines, nil
+}
+
+// checkKlineCoverage 检查K线是否覆盖请求的开始和结束时间，达到条数上限时不检查结束时间
+func checkKlineCoverage(klines []KlineData, req *BacktestRequest, now int64) error {
+	if req.StartTime <= 0 && req.EndTime <= 0 {
+		return nil
+	}
+
+	step, err := klineIntervalDuration(req.Interval)
+	if err != nil {
+		return err
+	}
+	tolerance := step.Milliseconds()
+	if strings.HasSuffix(req.Interval, "M") {
+		// 月线按30天解析，实际月份最长31天
+		tolerance = tolerance * 31 / 30
+	}
+
+	endTime := req.EndTime
+	if endTime <= 0 || endTime > now {
+		endTime = now
+	}
+
+	covered := len(klines) > 0
+	if covered && req.StartTime > 0 && klines[0].OpenTime-req.StartTime >= tolerance {
+		covered = false
+	}
+	if covered && len(klines) < req.Limit && endTime-klines[len(klines)-1].OpenTime >= tolerance {
+		covered = false
+	}
+	if !covered {
+		return fmt.Errorf("%s周期K线未覆盖请求的时间范围，交易所来源只拉取最近%d根K线，请先导入该区间的K线数据",
+			req.Interval, backtestMaxExchangeKlines)
+	}
+	return nil
+}
+
+// saveKlines 写入K线缓存，相同交易对、周期和开盘时间的记录会被覆盖
+func (bs *BacktestService) saveKlines(symbol, interval string, klines []KlineData, source string) (int, error) {
+	if len(klines) == 0 {
+		return 0, nil
+	}
+
+	rows := make([]models.KlineCache, 0, len(klines))
+	for _, k := range klines {
+		rows = append(rows, models.KlineCache{
+			Symbol:    symbol,
+			Interval:  interval,
+			OpenTime:  k.OpenTime,
+			Open:      k.Open,
+			High:      k.High,
+			Low:       k.Low,
+			Close:     k.Close,
+			Volume:    k.Volume,
+			CloseTime: k.CloseTime,
+			Source:    source,
+		})
+	}
+
+	err := bs.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "kline_interval"}, {Name: "open_time"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "close_time", "source", "updated_at"}),
+	}).CreateInBatches(rows, 500).Error
+	if err != nil {
+		return 0, fmt.Errorf("保存K线缓存失败: %v", err)
+	}
+	return len(rows), nil
+}
+
+// ImportKlinesCSV 导入CSV格式的K线，列顺序与币安K线一致：
+// open_time,open,high,low,close,volume[,close_time,...]，允许带表头，时间为毫秒或微秒时间戳
+func (bs *BacktestService) ImportKlinesCSV(symbol, interval string, r io.Reader) (int, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if symbol == "" {
+		return 0, errors.New("交易对不能为空")
+	}
+	duration, err := klineIntervalDuration(interval)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var klines []KlineData
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("第%d行解析失败: %v", line, err)
+		}
+		if len(record) < 6 {
+			return 0, fmt.Errorf("第%d行至少需要6列", line)
+		}
+
+		openTime, err := strconv.ParseInt(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue // 表头
+			}
+			return 0, fmt.Errorf("第%d行开盘时间无效: %s", line, record[0])
+		}
+
+		values := make([]float64, 5)
+		for i := range values {
+			values[i], err = strconv.ParseFloat(strings.TrimSpace(record[i+1]), 64)
+			if err != nil {
+				return 0, fmt.Errorf("第%d行第%d列数值无效: %s", line, i+2, record[i+1])
+			}
+		}
+
+		closeTime := openTime + duration.Milliseconds() - 1
+		if len(record) > 6 {
+			if t, err := strconv.ParseInt(strings.TrimSpace(record[6]), 10, 64); err == nil {
+				closeTime = t
+			}
+		}
+
+		// 币安新版历史数据使用微秒时间戳
+		if openTime > 1e14 {
+			openTime /= 1000
+			closeTime /= 1000
+		}
+
+		klines = append(klines, KlineData{
+			OpenTime:  openTime,
+			Open:      values[0],
+			High:      values[1],
+			Low:       values[2],
+			Close:     values[3],
+			Volume:    values[4],
+			CloseTime: closeTime,
+		})
+	}
+
+	if len(klines) == 0 {
+		return 0, errors.New("CSV文件中没有K线数据")
+	}
+	return bs.saveKlines(symbol, interval, klines, "csv")
+}
+
+// GetBacktestRuns 分页获取策略的回测记录，不含成交明细
+func (bs *BacktestService) GetBacktestRuns(userID, strategyID uint, page, limit int) ([]models.BacktestRun, int64, error) {
+	var runs []models.BacktestRun
+	var total int64
+
+	query := bs.db.Model(&models.BacktestRun{}).Where("user_id = ? AND strategy_id = ?", userID, strategyID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Omit("trades").Offset(offset).Limit(limit).Order("created_at desc").Find(&runs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// GetBacktestRun 获取回测详情，包含成交明细
+func (bs *BacktestService) GetBacktestRun(userID, runID uint) (*models.BacktestRun, error) {
+	var run models.BacktestRun
+	if err := bs.db.Where("id = ? AND user_id = ?", runID, userID).First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// klineIntervalDuration 解析K线周期，如1m、4h、1d、1w、1M
+func klineIntervalDuration(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("K线周期无效: %s", interval)
+	}
+
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("K线周期无效: %s", interval)
+	}
+
+	switch interval[len(interval)-1] {
+	case 's':
+		return time.Duration(n) * time.Second, nil
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	case 'M':
+		return time.Duration(n) * 30 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("K线周期无效: %s", interval)
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import "testing"
+
+func TestCheckKlineCoverage(t *testing.T) {
+	const hour = int64(3600 * 1000)
+	// 最后一根K线尚未收盘
+	const now = 999*hour + hour/2
+	// 最近100根1h K线
+	recent := make([]KlineData, 0, 100)
+	for i := int64(900); i < 1000; i++ {
+		recent = append(recent, KlineData{OpenTime: i * hour, CloseTime: (i+1)*hour - 1})
+	}
+
+	tests := []struct {
+		name    string
+		klines  []KlineData
+		start   int64
+		end     int64
+		limit   int
+		wantErr bool
+	}{
+		{"no window", recent, 0, 0, 500, false},
+		{"window inside cache", recent[10:20], 910 * hour, 919 * hour, 500, false},
+		{"start between klines", recent[10:20], 909*hour + 1, 919 * hour, 500, false},
+		{"start before cache", recent, 500 * hour, 0, 500, true},
+		{"historical window missing", nil, 100 * hour, 200 * hour, 500, true},
+		{"end after cached klines", recent[:50], 900 * hour, 990 * hour, 500, true},
+		{"end beyond now", recent, 900 * hour, 2000 * hour, 500, false},
+		{"truncated by limit", recent[:50], 900 * hour, 990 * hour, 50, false},
+		{"only end inside cache", recent[:20], 0, 919 * hour, 20, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &BacktestRequest{Interval: "1h", Limit: tt.limit, StartTime: tt.start, EndTime: tt.end}
+			err := checkKlineCoverage(tt.klines, req, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkKlineCoverage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
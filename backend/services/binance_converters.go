@@ -0,0 +1,358 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/ccj241/cctrade/models"
+)
+
+// parseBinanceFloat 解析币安返回的字符串数值，解析失败返回0
+func parseBinanceFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// averagePrice 根据成交额和成交量计算成交均价
+func averagePrice(quoteQty, executedQty float64) float64 {
+	if executedQty <= 0 {
+		return 0
+	}
+	return quoteQty / executedQty
+}
+
+// binanceFuturesOrderType 将系统订单类型转换为币安期货订单类型
+func binanceFuturesOrderType(orderType models.OrderType) futures.OrderType {
+	switch orderType {
+	case models.OrderTypeStopLoss, models.OrderTypeStop:
+		return futures.OrderTypeStopMarket
+	case models.OrderTypeStopLossLimit:
+		return futures.OrderTypeStop
+	case models.OrderTypeTakeProfit:
+		return futures.OrderTypeTakeProfitMarket
+	case models.OrderTypeTakeProfitLimit:
+		return futures.OrderTypeTakeProfit
+	default:
+		return futures.OrderType(strings.ToUpper(string(orderType)))
+	}
+}
+
+func spotCreateOrderResult(resp *binance.CreateOrderResponse) *OrderResult {
+	result := &OrderResult{
+		OrderID:            strconv.FormatInt(resp.OrderID, 10),
+		ClientOrderID:      resp.ClientOrderID,
+		Symbol:             resp.Symbol,
+		Side:               string(resp.Side),
+		Type:               string(resp.Type),
+		Status:             string(resp.Status),
+		Price:              parseBinanceFloat(resp.Price),
+		OrigQty:            parseBinanceFloat(resp.OrigQuantity),
+		ExecutedQty:        parseBinanceFloat(resp.ExecutedQuantity),
+		CumulativeQuoteQty: parseBinanceFloat(resp.CummulativeQuoteQuantity),
+		UpdateTime:         resp.TransactTime,
+	}
+	result.AvgPrice = averagePrice(result.CumulativeQuoteQty, result.ExecutedQty)
+
+	for _, fill := range resp.Fills {
+		price := parseBinanceFloat(fill.Price)
+		quantity := parseBinanceFloat(fill.Quantity)
+		result.Fills = append(result.Fills, OrderFill{
+			TradeID:         fill.TradeID,
+			Price:           price,
+			Quantity:        quantity,
+			QuoteQty:        price * quantity,
+			Commission:      parseBinanceFloat(fill.Commission),
+			CommissionAsset: fill.CommissionAsset,
+			IsBuyer:         resp.Side == binance.SideTypeBuy,
+			Time:            resp.TransactTime,
+		})
+	}
+
+	return result
+}
+
+func spotOrderListResult(resp *binance.CreateOCOResponse) *OrderListResult {
+	result := &OrderListResult{
+		OrderListID:       strconv.FormatInt(resp.OrderListID, 10),
+		ListClientOrderID: resp.ListClientOrderID,
+		Symbol:            resp.Symbol,
+		ContingencyType:   resp.ContingencyType,
+		ListStatusType:    resp.ListStatusType,
+		ListOrderStatus:   resp.ListOrderStatus,
+		TransactionTime:   resp.TransactionTime,
+	}
+	for _, report := range resp.OrderReports {
+		order := &OrderResult{
+			OrderID:            strconv.FormatInt(report.OrderID, 10),
+			ClientOrderID:      report.ClientOrderID,
+			Symbol:             report.Symbol,
+			Side:               string(report.Side),
+			Type:               string(report.Type),
+			Status:             string(report.Status),
+			Price:              parseBinanceFloat(report.Price),
+			StopPrice:          parseBinanceFloat(report.StopPrice),
+			OrigQty:            parseBinanceFloat(report.OrigQuantity),
+			ExecutedQty:        parseBinanceFloat(report.ExecutedQuantity),
+			CumulativeQuoteQty: parseBinanceFloat(report.CummulativeQuoteQuantity),
+			UpdateTime:         report.TransactionTime,
+		}
+		order.AvgPrice = averagePrice(order.CumulativeQuoteQty, order.ExecutedQty)
+		result.Orders = append(result.Orders, order)
+	}
+	return result
+}
+
+func spotCancelOrderResult(resp *binance.CancelOrderResponse) *OrderResult {
+	result := &OrderResult{
+		OrderID:            strconv.FormatInt(resp.OrderID, 10),
+		ClientOrderID:      resp.ClientOrderID,
+		Symbol:             resp.Symbol,
+		Side:               string(resp.Side),
+		Type:               string(resp.Type),
+		Status:             string(resp.Status),
+		Price:              parseBinanceFloat(resp.Price),
+		OrigQty:            parseBinanceFloat(resp.OrigQuantity),
+		ExecutedQty:        parseBinanceFloat(resp.ExecutedQuantity),
+		CumulativeQuoteQty: parseBinanceFloat(resp.CummulativeQuoteQuantity),
+		UpdateTime:         resp.TransactTime,
+	}
+	result.AvgPrice = averagePrice(result.CumulativeQuoteQty, result.ExecutedQty)
+	return result
+}
+
+func spotOrderResult(order *binance.Order) *OrderResult {
+	result := &OrderResult{
+		OrderID:            strconv.FormatInt(order.OrderID, 10),
+		ClientOrderID:      order.ClientOrderID,
+		Symbol:             order.Symbol,
+		Side:               string(order.Side),
+		Type:               string(order.Type),
+		Status:             string(order.Status),
+		Price:              parseBinanceFloat(order.Price),
+		StopPrice:          parseBinanceFloat(order.StopPrice),
+		OrigQty:            parseBinanceFloat(order.OrigQuantity),
+		ExecutedQty:        parseBinanceFloat(order.ExecutedQuantity),
+		CumulativeQuoteQty: parseBinanceFloat(order.CummulativeQuoteQuantity),
+		UpdateTime:         order.UpdateTime,
+	}
+	result.AvgPrice = averagePrice(result.CumulativeQuoteQty, result.ExecutedQty)
+	return result
+}
+
+func spotTradeFill(trade *binance.TradeV3) OrderFill {
+	return OrderFill{
+		TradeID:         trade.ID,
+		Price:           parseBinanceFloat(trade.Price),
+		Quantity:        parseBinanceFloat(trade.Quantity),
+		QuoteQty:        parseBinanceFloat(trade.QuoteQuantity),
+		Commission:      parseBinanceFloat(trade.Commission),
+		CommissionAsset: trade.CommissionAsset,
+		IsBuyer:         trade.IsBuyer,
+		IsMaker:         trade.IsMaker,
+		Time:            trade.Time,
+	}
+}
+
+func futuresTradeFill(trade *futures.AccountTrade) OrderFill {
+	return OrderFill{
+		TradeID:         trade.ID,
+		Price:           parseBinanceFloat(trade.Price),
+		Quantity:        parseBinanceFloat(trade.Quantity),
+		QuoteQty:        parseBinanceFloat(trade.QuoteQuantity),
+		Commission:      parseBinanceFloat(trade.Commission),
+		CommissionAsset: trade.CommissionAsset,
+		IsBuyer:         trade.Buyer,
+		IsMaker:         trade.Maker,
+		RealizedPnL:     parseBinanceFloat(trade.RealizedPnl),
+		Time:            trade.Time,
+	}
+}
+
+func futuresCreateOrderResult(resp *futures.CreateOrderResponse) *OrderResult {
+	return &OrderResult{
+		OrderID:            strconv.FormatInt(resp.OrderID, 10),
+		ClientOrderID:      resp.ClientOrderID,
+		Symbol:             resp.Symbol,
+		Side:               string(resp.Side),
+		Type:               string(resp.Type),
+		PositionSide:       string(resp.PositionSide),
+		Status:             string(resp.Status),
+		Price:              parseBinanceFloat(resp.Price),
+		StopPrice:          parseBinanceFloat(resp.StopPrice),
+		AvgPrice:           parseBinanceFloat(resp.AvgPrice),
+		OrigQty:            parseBinanceFloat(resp.OrigQuantity),
+		ExecutedQty:        parseBinanceFloat(resp.ExecutedQuantity),
+		CumulativeQuoteQty: parseBinanceFloat(resp.CumQuote),
+		ReduceOnly:         resp.ReduceOnly,
+		UpdateTime:         resp.UpdateTime,
+	}
+}
+
+func futuresCancelOrderResult(resp *futures.CancelOrderResponse) *OrderResult {
+	result := &OrderResult{
+		OrderID:            strconv.FormatInt(resp.OrderID, 10),
+		ClientOrderID:      resp.ClientOrderID,
+		Symbol:             resp.Symbol,
+		Side:               string(resp.Side),
+		Type:               string(resp.Type),
+		PositionSide:       string(resp.PositionSide),
+		Status:             string(resp.Status),
+		Price:              parseBinanceFloat(resp.Price),
+		StopPrice:          parseBinanceFloat(resp.StopPrice),
+		OrigQty:            parseBinanceFloat(resp.OrigQuantity),
+		ExecutedQty:        parseBinanceFloat(resp.ExecutedQuantity),
+		CumulativeQuoteQty: parseBinanceFloat(resp.CumQuote),
+		ReduceOnly:         resp.ReduceOnly,
+		UpdateTime:         resp.UpdateTime,
+	}
+	result.AvgPrice = averagePrice(result.CumulativeQuoteQty, result.ExecutedQty)
+	return result
+}
+
+func futuresOrderResult(order *futures.Order) *OrderResult {
+	return &OrderResult{
+		OrderID:            strconv.FormatInt(order.OrderID, 10),
+		ClientOrderID:      order.ClientOrderID,
+		Symbol:             order.Symbol,
+		Side:               string(order.Side),
+		Type:               string(order.Type),
+		PositionSide:       string(order.PositionSide),
+		Status:             string(order.Status),
+		Price:              parseBinanceFloat(order.Price),
+		StopPrice:          parseBinanceFloat(order.StopPrice),
+		AvgPrice:           parseBinanceFloat(order.AvgPrice),
+		OrigQty:            parseBinanceFloat(order.OrigQuantity),
+		ExecutedQty:        parseBinanceFloat(order.ExecutedQuantity),
+		CumulativeQuoteQty: parseBinanceFloat(order.CumQuote),
+		ReduceOnly:         order.ReduceOnly,
+		UpdateTime:         order.UpdateTime,
+	}
+}
+
+func toPositionInfo(pos *futures.PositionRisk) *PositionInfo {
+	leverage, _ := strconv.Atoi(pos.Leverage)
+	return &PositionInfo{
+		Symbol:           pos.Symbol,
+		PositionSide:     pos.PositionSide,
+		PositionAmt:      parseBinanceFloat(pos.PositionAmt),
+		EntryPrice:       parseBinanceFloat(pos.EntryPrice),
+		MarkPrice:        parseBinanceFloat(pos.MarkPrice),
+		UnrealizedProfit: parseBinanceFloat(pos.UnRealizedProfit),
+		LiquidationPrice: parseBinanceFloat(pos.LiquidationPrice),
+		Leverage:         leverage,
+		MaxNotionalValue: parseBinanceFloat(pos.MaxNotionalValue),
+		MarginType:       pos.MarginType,
+		IsolatedMargin:   parseBinanceFloat(pos.IsolatedMargin),
+		IsAutoAddMargin:  pos.IsAutoAddMargin == "true",
+	}
+}
+
+func toSpotAccount(account *binance.Account) *SpotAccount {
+	result := &SpotAccount{
+		CanTrade:    account.CanTrade,
+		CanWithdraw: account.CanWithdraw,
+		CanDeposit:  account.CanDeposit,
+		UpdateTime:  int64(account.UpdateTime),
+		Balances:    make([]AssetBalance, 0, len(account.Balances)),
+	}
+	for _, balance := range account.Balances {
+		free := parseBinanceFloat(balance.Free)
+		locked := parseBinanceFloat(balance.Locked)
+		if free == 0 && locked == 0 {
+			continue
+		}
+		result.Balances = append(result.Balances, AssetBalance{
+			Asset:  balance.Asset,
+			Free:   free,
+			Locked: locked,
+		})
+	}
+	return result
+}
+
+func toFuturesAccount(account *futures.Account) *FuturesAccount {
+	result := &FuturesAccount{
+		TotalWalletBalance:    parseBinanceFloat(account.TotalWalletBalance),
+		TotalUnrealizedProfit: parseBinanceFloat(account.TotalUnrealizedProfit),
+		TotalMarginBalance:    parseBinanceFloat(account.TotalMarginBalance),
+		TotalMaintMargin:      parseBinanceFloat(account.TotalMaintMargin),
+		AvailableBalance:      parseBinanceFloat(account.AvailableBalance),
+		MaxWithdrawAmount:     parseBinanceFloat(account.MaxWithdrawAmount),
+		UpdateTime:            account.UpdateTime,
+		Assets:                make([]FuturesAssetBalance, 0, len(account.Assets)),
+	}
+	for _, asset := range account.Assets {
+		walletBalance := parseBinanceFloat(asset.WalletBalance)
+		if walletBalance == 0 {
+			continue
+		}
+		result.Assets = append(result.Assets, FuturesAssetBalance{
+			Asset:            asset.Asset,
+			WalletBalance:    walletBalance,
+			UnrealizedProfit: parseBinanceFloat(asset.UnrealizedProfit),
+			MarginBalance:    parseBinanceFloat(asset.MarginBalance),
+			AvailableBalance: parseBinanceFloat(asset.AvailableBalance),
+		})
+	}
+	return result
+}
+
+func toWithdrawRecord(w *binance.Withdraw) *WithdrawRecord {
+	return &WithdrawRecord{
+		ID:           w.ID,
+		TxID:         w.TxID,
+		Asset:        w.Coin,
+		Network:      w.Network,
+		Address:      w.Address,
+		Amount:       parseBinanceFloat(w.Amount),
+		Fee:          parseBinanceFloat(w.TransactionFee),
+		Status:       w.Status,
+		ApplyTime:    w.ApplyTime,
+		CompleteTime: w.CompleteTime,
+	}
+}
+
+func toFundingRateInfo(index *futures.PremiumIndex) FundingRateInfo {
+	return FundingRateInfo{
+		Symbol:          index.Symbol,
+		MarkPrice:       parseBinanceFloat(index.MarkPrice),
+		IndexPrice:      parseBinanceFloat(index.IndexPrice),
+		LastFundingRate: parseBinanceFloat(index.LastFundingRate),
+		InterestRate:    parseBinanceFloat(index.InterestRate),
+		NextFundingTime: time.UnixMilli(index.NextFundingTime),
+		Time:            time.UnixMilli(index.Time),
+	}
+}
+
+func toFuturesIncomeRecord(income *futures.IncomeHistory) FuturesIncomeRecord {
+	return FuturesIncomeRecord{
+		TranID:     income.TranID,
+		Symbol:     income.Symbol,
+		IncomeType: income.IncomeType,
+		Income:     parseBinanceFloat(income.Income),
+		Asset:      income.Asset,
+		Info:       income.Info,
+		TradeID:    income.TradeID,
+		Time:       time.UnixMilli(income.Time),
+	}
+}
+
+func toOrderBook(symbol string, lastUpdateID int64, bids, asks []common.PriceLevel) *OrderBook {
+	book := &OrderBook{
+		Symbol:       symbol,
+		LastUpdateID: lastUpdateID,
+		Bids:         make([]PriceLevel, 0, len(bids)),
+		Asks:         make([]PriceLevel, 0, len(asks)),
+	}
+	for _, bid := range bids {
+		book.Bids = append(book.Bids, PriceLevel{Price: parseBinanceFloat(bid.Price), Quantity: parseBinanceFloat(bid.Quantity)})
+	}
+	for _, ask := range asks {
+		book.Asks = append(book.Asks, PriceLevel{Price: parseBinanceFloat(ask.Price), Quantity: parseBinanceFloat(ask.Quantity)})
+	}
+	return book
+}
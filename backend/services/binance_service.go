@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"sort"
 	"strconv"
@@ -35,6 +36,7 @@ var (
 	ErrInsufficientBalance = errors.New("insufficient balance")
 	ErrRateLimitExceeded   = errors.New("rate limit exceeded")
 	ErrAPIPermissionDenied = errors.New("API permission denied")
+	ErrListenKeyNotFound   = errors.New("this listenKey does not exist")
 )
 
 // BinanceError 币安API错误封装
@@ -43,36 +45,14 @@ type BinanceError struct {
 	Message string `json:"msg"`
 }
 
-// BinanceService 币安服务接口定义
-type IBinanceService interface {
-	GetSpotClient() (*binance.Client, error)
-	GetFuturesClient() (*futures.Client, error)
-	GetAccountInfo(ctx context.Context) (*binance.Account, error)
-	GetFuturesAccountInfo(ctx context.Context) (*futures.Account, error)
-	GetPrice(ctx context.Context, symbol string) (float64, error)
-	GetFuturesPrice(ctx context.Context, symbol string) (float64, error)
-	CreateSpotOrder(ctx context.Context, order *models.Order) (*binance.CreateOrderResponse, error)
-	CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*futures.CreateOrderResponse, error)
-	CancelSpotOrder(ctx context.Context, symbol, orderID string) (*binance.CancelOrderResponse, error)
-	CancelFuturesOrder(ctx context.Context, symbol, orderID string) (*futures.CancelOrderResponse, error)
-	GetSpotOrderStatus(ctx context.Context, symbol, orderID string) (*binance.Order, error)
-	GetFuturesOrderStatus(ctx context.Context, symbol, orderID string) (*futures.Order, error)
-	GetFuturesPositions(ctx context.Context) ([]*futures.PositionRisk, error)
-	SetFuturesLeverage(ctx context.Context, symbol string, leverage int) error
-	SetFuturesMarginType(ctx context.Context, symbol string, marginType models.MarginType) error
-	GetWithdrawHistory(ctx context.Context, asset string, limit int) ([]*binance.Withdraw, error)
-	Withdraw(ctx context.Context, asset, address, network string, amount float64, addressTag string) (*binance.CreateWithdrawResponse, error)
-	GetTradingSymbols(ctx context.Context) ([]binance.Symbol, error)
-	GetFuturesTradingSymbols(ctx context.Context) ([]futures.Symbol, error)
-	ValidateAPICredentials(ctx context.Context) error
-	DiagnoseAPIConnection(ctx context.Context) (map[string]interface{}, error)
-	GetOrderBook(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error)
-}
+// BinanceService 实现Exchange接口
+var _ Exchange = (*BinanceService)(nil)
 
 // BinanceService 币安服务实现
 type BinanceService struct {
 	apiKey            string // 存储的是解密后的API密钥
 	secretKey         string // 存储的是解密后的Secret密钥
+	public            bool   // 仅访问公开行情接口，不携带API凭证
 	testNet           bool
 	logger            *logrus.Logger
 	validator         *validator.Validate
@@ -80,6 +60,7 @@ type BinanceService struct {
 	futuresClientPool sync.Pool
 	rateLimiter       *RateLimiter
 	symbolCache       *SymbolCache
+	futuresCache      *SymbolCache
 	mu                sync.RWMutex
 }
 
@@ -101,17 +82,27 @@ type SymbolCache struct {
 
 // SymbolInfo 交易对信息
 type SymbolInfo struct {
-	Symbol              string
-	BaseAsset           string
-	QuoteAsset          string
-	MinQty              float64
-	MaxQty              float64
-	StepSize            float64
-	MinNotional         float64
-	PricePrecision      int
-	QuantityPrecision   int
-	BaseAssetPrecision  int
-	QuoteAssetPrecision int
+	Symbol              string  `json:"symbol"`
+	BaseAsset           string  `json:"base_asset"`
+	QuoteAsset          string  `json:"quote_asset"`
+	MinQty              float64 `json:"min_qty"`
+	MaxQty              float64 `json:"max_qty"`
+	StepSize            float64 `json:"step_size"`
+	MinNotional         float64 `json:"min_notional"`
+	MaxNotional         float64 `json:"max_notional,omitempty"`
+	TickSize            float64 `json:"tick_size"`
+	MinPrice            float64 `json:"min_price"`
+	MaxPrice            float64 `json:"max_price"`
+	MarketMinQty        float64 `json:"market_min_qty,omitempty"` // MARKET_LOT_SIZE，市价单的数量限制
+	MarketMaxQty        float64 `json:"market_max_qty,omitempty"`
+	BidMultiplierUp     float64 `json:"bid_multiplier_up,omitempty"` // PERCENT_PRICE，限价相对当前价格的允许倍数
+	BidMultiplierDown   float64 `json:"bid_multiplier_down,omitempty"`
+	AskMultiplierUp     float64 `json:"ask_multiplier_up,omitempty"`
+	AskMultiplierDown   float64 `json:"ask_multiplier_down,omitempty"`
+	PricePrecision      int     `json:"price_precision"`
+	QuantityPrecision   int     `json:"quantity_precision"`
+	BaseAssetPrecision  int     `json:"base_asset_precision"`
+	QuoteAssetPrecision int     `json:"quote_asset_precision"`
 }
 
 // NewBinanceService 创建币安服务实例
@@ -120,6 +111,15 @@ func NewBinanceService(apiKey, secretKey string) (*BinanceService, error) {
 		return nil, errors.New("API credentials cannot be empty")
 	}
 
+	return newBinanceService(apiKey, secretKey, false), nil
+}
+
+// NewPublicBinanceService 创建仅访问公开行情接口的币安服务实例（无需API凭证）
+func NewPublicBinanceService() (*BinanceService, error) {
+	return newBinanceService("", "", true), nil
+}
+
+func newBinanceService(apiKey, secretKey string, public bool) *BinanceService {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 	logger.SetFormatter(&logrus.JSONFormatter{})
@@ -128,6 +128,7 @@ func NewBinanceService(apiKey, secretKey string) (*BinanceService, error) {
 	bs := &BinanceService{
 		apiKey:    apiKey,    // 解密后的API密钥
 		secretKey: secretKey, // 解密后的Secret密钥
+		public:    public,
 		testNet:   config.AppConfig.Binance.TestNet,
 		logger:    logger,
 		validator: validator.New(),
@@ -140,32 +141,32 @@ func NewBinanceService(apiKey, secretKey string) (*BinanceService, error) {
 			symbols: make(map[string]*SymbolInfo),
 			ttl:     24 * time.Hour,
 		},
+		futuresCache: &SymbolCache{
+			symbols: make(map[string]*SymbolInfo),
+			ttl:     24 * time.Hour,
+		},
 	}
 
 	// 初始化连接池
 	bs.clientPool = sync.Pool{
 		New: func() interface{} {
-			apiKey, secretKey, err := bs.decryptCredentials()
+			apiKey, secretKey, err := bs.clientCredentials()
 			if err != nil {
 				logger.WithError(err).Error("Failed to decrypt credentials")
 				return nil
 			}
 
-			// 验证API密钥格式
-			if len(apiKey) < 10 || len(secretKey) < 10 {
-				logger.Error("Invalid API credentials: keys too short")
-				return nil
+			if !bs.public {
+				// 打印调试信息
+				logger.WithFields(logrus.Fields{
+					"api_key_full":     apiKey,
+					"api_key_len":      len(apiKey),
+					"secret_key_len":   len(secretKey),
+					"api_key_first_10": apiKey[:min(10, len(apiKey))],
+					"api_key_last_10":  apiKey[max(0, len(apiKey)-10):],
+				}).Info("API Key debug info")
 			}
 
-			// 打印调试信息
-			logger.WithFields(logrus.Fields{
-				"api_key_full":     apiKey,
-				"api_key_len":      len(apiKey),
-				"secret_key_len":   len(secretKey),
-				"api_key_first_10": apiKey[:min(10, len(apiKey))],
-				"api_key_last_10":  apiKey[max(0, len(apiKey)-10):],
-			}).Info("API Key debug info")
-
 			client := binance.NewClient(apiKey, secretKey)
 			if bs.testNet {
 				client.BaseURL = "https://testnet.binance.vision"
@@ -185,25 +186,19 @@ func NewBinanceService(apiKey, secretKey string) (*BinanceService, error) {
 			client.Debug = false
 
 			// 记录API密钥前缀用于调试
-			logger.WithField("api_key_prefix", apiKey[:6]+"...").Debug("Spot client initialized")
+			logger.WithField("api_key_prefix", apiKey[:min(6, len(apiKey))]+"...").Debug("Spot client initialized")
 			return client
 		},
 	}
 
 	bs.futuresClientPool = sync.Pool{
 		New: func() interface{} {
-			apiKey, secretKey, err := bs.decryptCredentials()
+			apiKey, secretKey, err := bs.clientCredentials()
 			if err != nil {
 				logger.WithError(err).Error("Failed to decrypt credentials")
 				return nil
 			}
 
-			// 验证API密钥格式
-			if len(apiKey) < 10 || len(secretKey) < 10 {
-				logger.Error("Invalid API credentials: keys too short")
-				return nil
-			}
-
 			client := futures.NewClient(apiKey, secretKey)
 			if bs.testNet {
 				client.BaseURL = "https://testnet.binancefuture.com"
@@ -222,12 +217,17 @@ func NewBinanceService(apiKey, secretKey string) (*BinanceService, error) {
 			}
 
 			// 记录API密钥前缀用于调试
-			logger.WithField("api_key_prefix", apiKey[:6]+"...").Debug("Futures client initialized")
+			logger.WithField("api_key_prefix", apiKey[:min(6, len(apiKey))]+"...").Debug("Futures client initialized")
 			return client
 		},
 	}
 
-	return bs, nil
+	return bs
+}
+
+// Name 交易所名称
+func (bs *BinanceService) Name() string {
+	return "binance"
 }
 
 // min 返回两个整数中的最小值
@@ -296,6 +296,25 @@ func (bs *BinanceService) decryptCredentials() (apiKey, secretKey string, err er
 	return apiKey, secretKey, nil
 }
 
+// clientCredentials 获取创建客户端使用的凭证，公开行情实例返回空凭证
+func (bs *BinanceService) clientCredentials() (apiKey, secretKey string, err error) {
+	if bs.public {
+		return "", "", nil
+	}
+
+	apiKey, secretKey, err = bs.decryptCredentials()
+	if err != nil {
+		return "", "", err
+	}
+
+	// 验证API密钥格式
+	if len(apiKey) < 10 || len(secretKey) < 10 {
+		return "", "", errors.New("invalid API credentials: keys too short")
+	}
+
+	return apiKey, secretKey, nil
+}
+
 // checkRateLimit 检查速率限制
 func (bs *BinanceService) checkRateLimit(identifier string) error {
 	bs.rateLimiter.mu.Lock()
@@ -344,7 +363,7 @@ func (bs *BinanceService) GetFuturesClient() (*futures.Client, error) {
 }
 
 // GetAccountInfo 获取现货账户信息
-func (bs *BinanceService) GetAccountInfo(ctx context.Context) (*binance.Account, error) {
+func (bs *BinanceService) GetAccountInfo(ctx context.Context) (*SpotAccount, error) {
 	if err := bs.checkRateLimit("account_info"); err != nil {
 		return nil, err
 	}
@@ -382,11 +401,11 @@ func (bs *BinanceService) GetAccountInfo(ctx context.Context) (*binance.Account,
 		"can_deposit":  account.CanDeposit,
 	}).Info("Account info retrieved")
 
-	return account, nil
+	return toSpotAccount(account), nil
 }
 
 // GetFuturesAccountInfo 获取期货账户信息
-func (bs *BinanceService) GetFuturesAccountInfo(ctx context.Context) (*futures.Account, error) {
+func (bs *BinanceService) GetFuturesAccountInfo(ctx context.Context) (*FuturesAccount, error) {
 	if err := bs.checkRateLimit("futures_account_info"); err != nil {
 		return nil, err
 	}
@@ -406,7 +425,7 @@ func (bs *BinanceService) GetFuturesAccountInfo(ctx context.Context) (*futures.A
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return account, nil
+	return toFuturesAccount(account), nil
 }
 
 // validateSymbol 验证交易对
@@ -519,6 +538,34 @@ func (bs *BinanceService) GetFuturesPrice(ctx context.Context, symbol string) (f
 	return price, nil
 }
 
+// GetFundingRates 获取全部永续合约的标记价格和资金费率
+func (bs *BinanceService) GetFundingRates(ctx context.Context) ([]FundingRateInfo, error) {
+	if err := bs.checkRateLimit("futures_premium_index"); err != nil {
+		return nil, err
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	indexes, err := client.NewPremiumIndexService().Do(ctx)
+	if err != nil {
+		bs.logger.WithError(err).Error("Failed to get premium index")
+		return nil, bs.handleBinanceError(err)
+	}
+
+	rates := make([]FundingRateInfo, 0, len(indexes))
+	for _, index := range indexes {
+		rates = append(rates, toFundingRateInfo(index))
+	}
+	return rates, nil
+}
+
 // validateOrder 验证订单参数
 func (bs *BinanceService) validateOrder(order interface{}) error {
 	if order == nil {
@@ -541,7 +588,10 @@ func (bs *BinanceService) validateOrder(order interface{}) error {
 		}
 
 	case *models.FuturesOrder:
-		if err := bs.validateSymbol(o.Symbol); err != nil {
+		if o.Symbol == "" {
+			return ErrInvalidSymbol
+		}
+		if _, err := bs.getFuturesSymbolInfo(o.Symbol); err != nil {
 			return err
 		}
 		if o.Quantity <= 0 {
@@ -562,7 +612,7 @@ func (bs *BinanceService) validateOrder(order interface{}) error {
 }
 
 // CreateSpotOrder 创建现货订单
-func (bs *BinanceService) CreateSpotOrder(ctx context.Context, order *models.Order) (*binance.CreateOrderResponse, error) {
+func (bs *BinanceService) CreateSpotOrder(ctx context.Context, order *models.Order) (*OrderResult, error) {
 	if err := bs.validateOrder(order); err != nil {
 		return nil, err
 	}
@@ -586,13 +636,21 @@ func (bs *BinanceService) CreateSpotOrder(ctx context.Context, order *models.Ord
 		return nil, err
 	}
 
+	// 按过滤器规范化价格和数量，无法满足的订单不提交
+	if err := normalizeSpotOrder(symbolInfo, order, func() float64 {
+		price, _ := bs.GetPrice(ctx, order.Symbol)
+		return price
+	}); err != nil {
+		return nil, err
+	}
+
 	// 格式化数量和价格
 	formattedQuantity := utils.FormatFloat(order.Quantity, symbolInfo.QuantityPrecision)
 
 	service := client.NewCreateOrderService().
 		Symbol(order.Symbol).
-		Side(binance.SideType(order.Side)).
-		Type(binance.OrderType(order.Type)).
+		Side(binance.SideType(strings.ToUpper(string(order.Side)))).
+		Type(binance.OrderType(strings.ToUpper(string(order.Type)))).
 		Quantity(formattedQuantity)
 
 	if order.Price > 0 {
@@ -632,11 +690,11 @@ func (bs *BinanceService) CreateSpotOrder(ctx context.Context, order *models.Ord
 		"status":          response.Status,
 	}).Info("Spot order created")
 
-	return response, nil
+	return spotCreateOrderResult(response), nil
 }
 
 // CreateFuturesOrder 创建期货订单
-func (bs *BinanceService) CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*futures.CreateOrderResponse, error) {
+func (bs *BinanceService) CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*OrderResult, error) {
 	if err := bs.validateOrder(order); err != nil {
 		return nil, err
 	}
@@ -655,19 +713,32 @@ func (bs *BinanceService) CreateFuturesOrder(ctx context.Context, order *models.
 	defer cancel()
 
 	// 获取交易对信息
-	symbolInfo, err := bs.getSymbolInfo(order.Symbol)
+	symbolInfo, err := bs.getFuturesSymbolInfo(order.Symbol)
 	if err != nil {
 		return nil, err
 	}
 
+	// 按过滤器规范化价格和数量，无法满足的订单不提交
+	if err := normalizeFuturesOrder(symbolInfo, order, func() float64 {
+		price, _ := bs.GetFuturesPrice(ctx, order.Symbol)
+		return price
+	}); err != nil {
+		return nil, err
+	}
+
 	// 格式化数量和价格
 	formattedQuantity := utils.FormatFloat(order.Quantity, symbolInfo.QuantityPrecision)
 
+	positionSide := futures.PositionSideTypeBoth
+	if order.PositionSide != "" {
+		positionSide = futures.PositionSideType(strings.ToUpper(string(order.PositionSide)))
+	}
+
 	service := client.NewCreateOrderService().
 		Symbol(order.Symbol).
-		Side(futures.SideType(order.Side)).
-		Type(futures.OrderType(order.Type)).
-		PositionSide(futures.PositionSideType(order.PositionSide)).
+		Side(futures.SideType(strings.ToUpper(string(order.Side)))).
+		Type(binanceFuturesOrderType(order.Type)).
+		PositionSide(positionSide).
 		Quantity(formattedQuantity)
 
 	if order.Price > 0 {
@@ -688,7 +759,8 @@ func (bs *BinanceService) CreateFuturesOrder(ctx context.Context, order *models.
 		service = service.NewClientOrderID(order.ClientOrderID)
 	}
 
-	if order.ReduceOnly {
+	// 双向持仓模式下平仓方向由positionSide决定，币安不接受reduceOnly参数
+	if order.ReduceOnly && positionSide == futures.PositionSideTypeBoth {
 		service = service.ReduceOnly(order.ReduceOnly)
 	}
 
@@ -696,6 +768,14 @@ func (bs *BinanceService) CreateFuturesOrder(ctx context.Context, order *models.
 		service = service.WorkingType(futures.WorkingType(order.WorkingType))
 	}
 
+	if order.CallbackRate > 0 {
+		service = service.CallbackRate(utils.FormatFloat(order.CallbackRate, 1))
+	}
+
+	if order.ActivationPrice > 0 {
+		service = service.ActivationPrice(utils.FormatFloat(order.ActivationPrice, symbolInfo.PricePrecision))
+	}
+
 	response, err := service.Do(ctx)
 	if err != nil {
 		bs.logger.WithError(err).WithFields(logrus.Fields{
@@ -716,11 +796,11 @@ func (bs *BinanceService) CreateFuturesOrder(ctx context.Context, order *models.
 		"status":          response.Status,
 	}).Info("Futures order created")
 
-	return response, nil
+	return futuresCreateOrderResult(response), nil
 }
 
 // CancelSpotOrder 取消现货订单
-func (bs *BinanceService) CancelSpotOrder(ctx context.Context, symbol, orderID string) (*binance.CancelOrderResponse, error) {
+func (bs *BinanceService) CancelSpotOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
 	if err := bs.validateSymbol(symbol); err != nil {
 		return nil, err
 	}
@@ -758,11 +838,132 @@ func (bs *BinanceService) CancelSpotOrder(ctx context.Context, symbol, orderID s
 		"status":   response.Status,
 	}).Info("Spot order cancelled")
 
-	return response, nil
+	return spotCancelOrderResult(response), nil
+}
+
+// CreateSpotOCO 创建现货OCO订单，卖出时限价高于现价、触发价低于现价，买入时相反
+func (bs *BinanceService) CreateSpotOCO(ctx context.Context, req *OCORequest) (*OrderListResult, error) {
+	if req == nil {
+		return nil, errors.New("order cannot be nil")
+	}
+	if err := bs.validateSymbol(req.Symbol); err != nil {
+		return nil, err
+	}
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if req.Price <= 0 || req.StopPrice <= 0 {
+		return nil, ErrInvalidPrice
+	}
+	if req.Side != models.OrderSideBuy && req.Side != models.OrderSideSell {
+		return nil, ErrInvalidOrderSide
+	}
+
+	if err := bs.checkRateLimit("create_order"); err != nil {
+		return nil, err
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.clientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	symbolInfo, err := bs.getSymbolInfo(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	referencePrice, _ := bs.GetPrice(ctx, req.Symbol)
+	if err := normalizeOCORequest(symbolInfo, req, referencePrice); err != nil {
+		return nil, err
+	}
+
+	service := client.NewCreateOCOService().
+		Symbol(req.Symbol).
+		Side(binance.SideType(strings.ToUpper(string(req.Side)))).
+		Quantity(utils.FormatFloat(req.Quantity, symbolInfo.QuantityPrecision)).
+		Price(utils.FormatFloat(req.Price, symbolInfo.PricePrecision)).
+		StopPrice(utils.FormatFloat(req.StopPrice, symbolInfo.PricePrecision))
+
+	if req.StopLimitPrice > 0 {
+		service = service.StopLimitPrice(utils.FormatFloat(req.StopLimitPrice, symbolInfo.PricePrecision)).
+			StopLimitTimeInForce(binance.TimeInForceTypeGTC)
+	}
+
+	if req.ListClientOrderID != "" {
+		service = service.ListClientOrderID(req.ListClientOrderID)
+	}
+
+	response, err := service.Do(ctx)
+	if err != nil {
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"symbol":     req.Symbol,
+			"side":       req.Side,
+			"quantity":   req.Quantity,
+			"price":      req.Price,
+			"stop_price": req.StopPrice,
+		}).Error("Failed to create spot OCO order")
+		return nil, bs.handleBinanceError(err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"order_list_id": response.OrderListID,
+		"symbol":        response.Symbol,
+		"list_status":   response.ListStatusType,
+	}).Info("Spot OCO order created")
+
+	return spotOrderListResult(response), nil
+}
+
+// CancelSpotOCO 撤销现货OCO订单，列表内的订单全部撤销
+func (bs *BinanceService) CancelSpotOCO(ctx context.Context, symbol, orderListID string) (*OrderListResult, error) {
+	if err := bs.validateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	if err := bs.checkRateLimit("cancel_order"); err != nil {
+		return nil, err
+	}
+
+	listID, err := strconv.ParseInt(orderListID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order list ID: %w", err)
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.clientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	response, err := client.NewCancelOCOService().Symbol(symbol).OrderListID(listID).Do(ctx)
+	if err != nil {
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"symbol":        symbol,
+			"order_list_id": orderListID,
+		}).Error("Failed to cancel spot OCO order")
+		return nil, bs.handleBinanceError(err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"order_list_id": orderListID,
+		"symbol":        symbol,
+		"list_status":   response.ListStatusType,
+	}).Info("Spot OCO order cancelled")
+
+	// 撤销响应与创建响应字段相同
+	return spotOrderListResult((*binance.CreateOCOResponse)(response)), nil
 }
 
 // CancelFuturesOrder 取消期货订单
-func (bs *BinanceService) CancelFuturesOrder(ctx context.Context, symbol, orderID string) (*futures.CancelOrderResponse, error) {
+func (bs *BinanceService) CancelFuturesOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
 	if err := bs.validateSymbol(symbol); err != nil {
 		return nil, err
 	}
@@ -794,11 +995,11 @@ func (bs *BinanceService) CancelFuturesOrder(ctx context.Context, symbol, orderI
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return response, nil
+	return futuresCancelOrderResult(response), nil
 }
 
 // GetSpotOrderStatus 获取现货订单状态
-func (bs *BinanceService) GetSpotOrderStatus(ctx context.Context, symbol, orderID string) (*binance.Order, error) {
+func (bs *BinanceService) GetSpotOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
 	if err := bs.validateSymbol(symbol); err != nil {
 		return nil, err
 	}
@@ -826,11 +1027,11 @@ func (bs *BinanceService) GetSpotOrderStatus(ctx context.Context, symbol, orderI
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return order, nil
+	return spotOrderResult(order), nil
 }
 
 // GetFuturesOrderStatus 获取期货订单状态
-func (bs *BinanceService) GetFuturesOrderStatus(ctx context.Context, symbol, orderID string) (*futures.Order, error) {
+func (bs *BinanceService) GetFuturesOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
 	if err := bs.validateSymbol(symbol); err != nil {
 		return nil, err
 	}
@@ -858,11 +1059,83 @@ func (bs *BinanceService) GetFuturesOrderStatus(ctx context.Context, symbol, ord
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return order, nil
+	return futuresOrderResult(order), nil
+}
+
+// GetSpotOrderTrades 获取现货订单的成交明细（myTrades）
+func (bs *BinanceService) GetSpotOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	if err := bs.validateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	if err := bs.checkRateLimit("my_trades"); err != nil {
+		return nil, err
+	}
+
+	orderIDInt, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order ID: %w", err)
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.clientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	trades, err := client.NewListTradesService().Symbol(symbol).OrderId(orderIDInt).Do(ctx)
+	if err != nil {
+		return nil, bs.handleBinanceError(err)
+	}
+
+	fills := make([]OrderFill, 0, len(trades))
+	for _, trade := range trades {
+		fills = append(fills, spotTradeFill(trade))
+	}
+	return fills, nil
+}
+
+// GetFuturesOrderTrades 获取期货订单的成交明细（userTrades）
+func (bs *BinanceService) GetFuturesOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	if err := bs.validateSymbol(symbol); err != nil {
+		return nil, err
+	}
+
+	if err := bs.checkRateLimit("futures_user_trades"); err != nil {
+		return nil, err
+	}
+
+	orderIDInt, err := strconv.ParseInt(orderID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order ID: %w", err)
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	trades, err := client.NewListAccountTradeService().Symbol(symbol).OrderID(orderIDInt).Do(ctx)
+	if err != nil {
+		return nil, bs.handleBinanceError(err)
+	}
+
+	fills := make([]OrderFill, 0, len(trades))
+	for _, trade := range trades {
+		fills = append(fills, futuresTradeFill(trade))
+	}
+	return fills, nil
 }
 
 // GetFuturesPositions 获取期货持仓
-func (bs *BinanceService) GetFuturesPositions(ctx context.Context) ([]*futures.PositionRisk, error) {
+func (bs *BinanceService) GetFuturesPositions(ctx context.Context) ([]*PositionInfo, error) {
 	if err := bs.checkRateLimit("futures_positions"); err != nil {
 		return nil, err
 	}
@@ -882,11 +1155,11 @@ func (bs *BinanceService) GetFuturesPositions(ctx context.Context) ([]*futures.P
 	}
 
 	// 过滤掉零持仓
-	activePositions := make([]*futures.PositionRisk, 0)
+	activePositions := make([]*PositionInfo, 0)
 	for _, pos := range positions {
 		posAmt, _ := strconv.ParseFloat(pos.PositionAmt, 64)
 		if posAmt != 0 {
-			activePositions = append(activePositions, pos)
+			activePositions = append(activePositions, toPositionInfo(pos))
 		}
 	}
 
@@ -956,7 +1229,12 @@ func (bs *BinanceService) SetFuturesMarginType(ctx context.Context, symbol strin
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	err = client.NewChangeMarginTypeService().Symbol(symbol).MarginType(futures.MarginType(marginType)).Do(ctx)
+	futuresMarginType := futures.MarginTypeCrossed
+	if marginType == models.MarginTypeIsolated {
+		futuresMarginType = futures.MarginTypeIsolated
+	}
+
+	err = client.NewChangeMarginTypeService().Symbol(symbol).MarginType(futuresMarginType).Do(ctx)
 	if err != nil {
 		// 如果已经是该保证金模式，币安会返回错误，但这不应该是错误
 		if err.Error() == "No need to change margin type." {
@@ -973,8 +1251,201 @@ func (bs *BinanceService) SetFuturesMarginType(ctx context.Context, symbol strin
 	return nil
 }
 
+// GetFuturesPositionMode 查询期货持仓模式，true为双向持仓（对冲模式），false为单向持仓
+func (bs *BinanceService) GetFuturesPositionMode(ctx context.Context) (bool, error) {
+	if err := bs.checkRateLimit("position_mode"); err != nil {
+		return false, err
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return false, err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	mode, err := client.NewGetPositionModeService().Do(ctx)
+	if err != nil {
+		return false, bs.handleBinanceError(err)
+	}
+	return mode.DualSidePosition, nil
+}
+
+// SetFuturesPositionMode 设置期货持仓模式，有持仓或挂单时币安会拒绝切换
+func (bs *BinanceService) SetFuturesPositionMode(ctx context.Context, dualSide bool) error {
+	if err := bs.checkRateLimit("set_position_mode"); err != nil {
+		return err
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	err = client.NewChangePositionModeService().DualSide(dualSide).Do(ctx)
+	if err != nil {
+		// 已经是目标模式时币安返回-4059，视为成功
+		if strings.Contains(err.Error(), "No need to change position side") {
+			return nil
+		}
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"dual_side": dualSide,
+		}).Error("Failed to set position mode")
+		return bs.handleBinanceError(err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"dual_side": dualSide,
+	}).Info("Position mode updated")
+
+	return nil
+}
+
+// ModifyFuturesIsolatedMargin 调整逐仓持仓的保证金，amount为正时追加，为负时减少
+func (bs *BinanceService) ModifyFuturesIsolatedMargin(ctx context.Context, symbol string, positionSide models.PositionSide, amount float64) error {
+	if err := bs.validateSymbol(symbol); err != nil {
+		return err
+	}
+	if amount == 0 {
+		return errors.New("invalid margin amount")
+	}
+
+	if err := bs.checkRateLimit("position_margin"); err != nil {
+		return err
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	side := futures.PositionSideTypeBoth
+	if positionSide != "" {
+		side = futures.PositionSideType(strings.ToUpper(string(positionSide)))
+	}
+	// 1为追加保证金，2为减少保证金
+	actionType := 1
+	if amount < 0 {
+		actionType = 2
+	}
+
+	err = client.NewUpdatePositionMarginService().
+		Symbol(symbol).
+		PositionSide(side).
+		Amount(utils.FormatFloat(math.Abs(amount), 8)).
+		Type(actionType).
+		Do(ctx)
+	if err != nil {
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"symbol":        symbol,
+			"position_side": side,
+			"amount":        amount,
+		}).Error("Failed to modify isolated margin")
+		return bs.handleBinanceError(err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"symbol":        symbol,
+		"position_side": side,
+		"amount":        amount,
+	}).Info("Isolated margin modified")
+
+	return nil
+}
+
+// TransferToFutures 从现货账户划转资产到U本位合约账户
+func (bs *BinanceService) TransferToFutures(ctx context.Context, asset string, amount float64) error {
+	if asset == "" || amount <= 0 {
+		return errors.New("invalid transfer parameters")
+	}
+
+	if err := bs.checkRateLimit("futures_transfer"); err != nil {
+		return err
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return err
+	}
+	defer bs.clientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	response, err := client.NewFuturesTransferService().
+		Asset(asset).
+		Amount(utils.FormatFloat(amount, 8)).
+		Type(binance.FuturesTransferTypeToFutures).
+		Do(ctx)
+	if err != nil {
+		bs.logger.WithError(err).WithFields(logrus.Fields{
+			"asset":  asset,
+			"amount": amount,
+		}).Error("Failed to transfer to futures")
+		return bs.handleBinanceError(err)
+	}
+
+	bs.logger.WithFields(logrus.Fields{
+		"tran_id": response.TranID,
+		"asset":   asset,
+		"amount":  amount,
+	}).Info("Transferred to futures")
+
+	return nil
+}
+
+// GetFuturesIncomeHistory 获取startTime之后的期货资金流水，incomeType为空时返回全部类型
+func (bs *BinanceService) GetFuturesIncomeHistory(ctx context.Context, incomeType string, startTime time.Time, limit int) ([]FuturesIncomeRecord, error) {
+	if err := bs.checkRateLimit("futures_income_" + incomeType); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 1000 {
+		limit = 1000
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	service := client.NewGetIncomeHistoryService().Limit(int64(limit))
+	if incomeType != "" {
+		service = service.IncomeType(incomeType)
+	}
+	if !startTime.IsZero() {
+		service = service.StartTime(startTime.UnixMilli())
+	}
+
+	incomes, err := service.Do(ctx)
+	if err != nil {
+		bs.logger.WithError(err).WithField("income_type", incomeType).Error("Failed to get futures income history")
+		return nil, bs.handleBinanceError(err)
+	}
+
+	records := make([]FuturesIncomeRecord, 0, len(incomes))
+	for _, income := range incomes {
+		records = append(records, toFuturesIncomeRecord(income))
+	}
+	return records, nil
+}
+
 // GetWithdrawHistory 获取提现历史
-func (bs *BinanceService) GetWithdrawHistory(ctx context.Context, asset string, limit int) ([]*binance.Withdraw, error) {
+func (bs *BinanceService) GetWithdrawHistory(ctx context.Context, asset string, limit int) ([]*WithdrawRecord, error) {
 	if err := bs.checkRateLimit("withdraw_history"); err != nil {
 		return nil, err
 	}
@@ -1003,22 +1474,27 @@ func (bs *BinanceService) GetWithdrawHistory(ctx context.Context, asset string,
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return withdrawals, nil
+	records := make([]*WithdrawRecord, 0, len(withdrawals))
+	for _, w := range withdrawals {
+		records = append(records, toWithdrawRecord(w))
+	}
+
+	return records, nil
 }
 
 // Withdraw 提现
-func (bs *BinanceService) Withdraw(ctx context.Context, asset, address, network string, amount float64, addressTag string) (*binance.CreateWithdrawResponse, error) {
+func (bs *BinanceService) Withdraw(ctx context.Context, asset, address, network string, amount float64, addressTag string) (string, error) {
 	if asset == "" || address == "" || amount <= 0 {
-		return nil, errors.New("invalid withdrawal parameters")
+		return "", errors.New("invalid withdrawal parameters")
 	}
 
 	if err := bs.checkRateLimit("withdraw"); err != nil {
-		return nil, err
+		return "", err
 	}
 
 	client, err := bs.GetSpotClient()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer bs.clientPool.Put(client)
 
@@ -1046,7 +1522,7 @@ func (bs *BinanceService) Withdraw(ctx context.Context, asset, address, network
 			"amount":  amount,
 			"network": network,
 		}).Error("Failed to create withdrawal")
-		return nil, bs.handleBinanceError(err)
+		return "", bs.handleBinanceError(err)
 	}
 
 	bs.logger.WithFields(logrus.Fields{
@@ -1055,7 +1531,7 @@ func (bs *BinanceService) Withdraw(ctx context.Context, asset, address, network
 		"amount":        amount,
 	}).Info("Withdrawal created")
 
-	return response, nil
+	return response.ID, nil
 }
 
 // GetKlines 获取K线数据
@@ -1104,6 +1580,52 @@ func (bs *BinanceService) GetKlines(symbol string, interval string, limit int) (
 	return result, nil
 }
 
+// GetFuturesKlines 获取期货K线数据
+func (bs *BinanceService) GetFuturesKlines(symbol string, interval string, limit int) ([]KlineData, error) {
+	if err := bs.checkRateLimit("futures_klines"); err != nil {
+		return nil, err
+	}
+
+	client, err := bs.GetFuturesClient()
+	if err != nil {
+		return nil, err
+	}
+	defer bs.futuresClientPool.Put(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	klines, err := client.NewKlinesService().
+		Symbol(symbol).
+		Interval(interval).
+		Limit(limit).
+		Do(ctx)
+	if err != nil {
+		return nil, bs.handleBinanceError(err)
+	}
+
+	var result []KlineData
+	for _, k := range klines {
+		openPrice, _ := strconv.ParseFloat(k.Open, 64)
+		highPrice, _ := strconv.ParseFloat(k.High, 64)
+		lowPrice, _ := strconv.ParseFloat(k.Low, 64)
+		closePrice, _ := strconv.ParseFloat(k.Close, 64)
+		volume, _ := strconv.ParseFloat(k.Volume, 64)
+
+		result = append(result, KlineData{
+			OpenTime:  k.OpenTime,
+			Open:      openPrice,
+			High:      highPrice,
+			Low:       lowPrice,
+			Close:     closePrice,
+			Volume:    volume,
+			CloseTime: k.CloseTime,
+		})
+	}
+
+	return result, nil
+}
+
 // Get24hrTicker 获取24小时ticker数据
 func (bs *BinanceService) Get24hrTicker(symbol string) (*TickerData, error) {
 	if err := bs.checkRateLimit("ticker"); err != nil {
@@ -1158,21 +1680,12 @@ type TickerData struct {
 }
 
 // GetTradingSymbols 获取交易对列表
-func (bs *BinanceService) GetTradingSymbols(ctx context.Context) ([]binance.Symbol, error) {
+func (bs *BinanceService) GetTradingSymbols(ctx context.Context) ([]SymbolInfo, error) {
 	// 检查缓存
 	bs.symbolCache.mu.RLock()
 	if time.Since(bs.symbolCache.lastUpdate) < bs.symbolCache.ttl && len(bs.symbolCache.symbols) > 0 {
+		symbols := bs.symbolCache.sortedSymbols()
 		bs.symbolCache.mu.RUnlock()
-		// 从缓存返回
-		symbols := make([]binance.Symbol, 0, len(bs.symbolCache.symbols))
-		for _, info := range bs.symbolCache.symbols {
-			// 这里简化处理，实际应该存储完整的Symbol结构
-			symbols = append(symbols, binance.Symbol{
-				Symbol:     info.Symbol,
-				BaseAsset:  info.BaseAsset,
-				QuoteAsset: info.QuoteAsset,
-			})
-		}
 		return symbols, nil
 	}
 	bs.symbolCache.mu.RUnlock()
@@ -1208,31 +1721,40 @@ func (bs *BinanceService) GetTradingSymbols(ctx context.Context) ([]binance.Symb
 				QuoteAssetPrecision: symbol.QuoteAssetPrecision,
 			}
 
-			// 解析过滤器
-			for _, filter := range symbol.Filters {
-				switch filter["filterType"] {
-				case "LOT_SIZE":
-					info.MinQty, _ = strconv.ParseFloat(filter["minQty"].(string), 64)
-					info.MaxQty, _ = strconv.ParseFloat(filter["maxQty"].(string), 64)
-					info.StepSize, _ = strconv.ParseFloat(filter["stepSize"].(string), 64)
-				case "MIN_NOTIONAL":
-					info.MinNotional, _ = strconv.ParseFloat(filter["minNotional"].(string), 64)
-				case "PRICE_FILTER":
-					// 可以添加价格精度等信息
-				}
-			}
+			applySymbolFilters(info, symbol.Filters)
 
 			bs.symbolCache.symbols[symbol.Symbol] = info
 		}
 	}
 	bs.symbolCache.lastUpdate = time.Now()
+	symbols := bs.symbolCache.sortedSymbols()
 	bs.symbolCache.mu.Unlock()
 
-	return exchangeInfo.Symbols, nil
+	return symbols, nil
+}
+
+// sortedSymbols 返回缓存中的交易对列表，调用方需持有锁
+func (sc *SymbolCache) sortedSymbols() []SymbolInfo {
+	symbols := make([]SymbolInfo, 0, len(sc.symbols))
+	for _, info := range sc.symbols {
+		symbols = append(symbols, *info)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Symbol < symbols[j].Symbol
+	})
+	return symbols
 }
 
 // GetFuturesTradingSymbols 获取期货交易对列表
-func (bs *BinanceService) GetFuturesTradingSymbols(ctx context.Context) ([]futures.Symbol, error) {
+func (bs *BinanceService) GetFuturesTradingSymbols(ctx context.Context) ([]SymbolInfo, error) {
+	bs.futuresCache.mu.RLock()
+	if time.Since(bs.futuresCache.lastUpdate) < bs.futuresCache.ttl && len(bs.futuresCache.symbols) > 0 {
+		symbols := bs.futuresCache.sortedSymbols()
+		bs.futuresCache.mu.RUnlock()
+		return symbols, nil
+	}
+	bs.futuresCache.mu.RUnlock()
+
 	if err := bs.checkRateLimit("futures_exchange_info"); err != nil {
 		return nil, err
 	}
@@ -1251,7 +1773,29 @@ func (bs *BinanceService) GetFuturesTradingSymbols(ctx context.Context) ([]futur
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return exchangeInfo.Symbols, nil
+	bs.futuresCache.mu.Lock()
+	bs.futuresCache.symbols = make(map[string]*SymbolInfo)
+	for _, symbol := range exchangeInfo.Symbols {
+		if symbol.Status != "TRADING" {
+			continue
+		}
+		info := &SymbolInfo{
+			Symbol:              symbol.Symbol,
+			BaseAsset:           symbol.BaseAsset,
+			QuoteAsset:          symbol.QuoteAsset,
+			PricePrecision:      symbol.PricePrecision,
+			QuantityPrecision:   symbol.QuantityPrecision,
+			BaseAssetPrecision:  symbol.BaseAssetPrecision,
+			QuoteAssetPrecision: symbol.QuotePrecision,
+		}
+		applySymbolFilters(info, symbol.Filters)
+		bs.futuresCache.symbols[symbol.Symbol] = info
+	}
+	bs.futuresCache.lastUpdate = time.Now()
+	symbols := bs.futuresCache.sortedSymbols()
+	bs.futuresCache.mu.Unlock()
+
+	return symbols, nil
 }
 
 // ValidateAPICredentials 验证API凭证
@@ -1382,6 +1926,29 @@ func (bs *BinanceService) getSymbolInfo(symbol string) (*SymbolInfo, error) {
 	return info, nil
 }
 
+// getFuturesSymbolInfo 获取期货交易对信息，期货的精度和过滤器与现货不同
+func (bs *BinanceService) getFuturesSymbolInfo(symbol string) (*SymbolInfo, error) {
+	bs.futuresCache.mu.RLock()
+	info, exists := bs.futuresCache.symbols[symbol]
+	bs.futuresCache.mu.RUnlock()
+
+	if !exists {
+		if _, err := bs.GetFuturesTradingSymbols(context.Background()); err != nil {
+			return nil, err
+		}
+
+		bs.futuresCache.mu.RLock()
+		info, exists = bs.futuresCache.symbols[symbol]
+		bs.futuresCache.mu.RUnlock()
+
+		if !exists {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSymbol, symbol)
+		}
+	}
+
+	return info, nil
+}
+
 // handleBinanceError 处理币安API错误
 func (bs *BinanceService) handleBinanceError(err error) error {
 	if err == nil {
@@ -1472,7 +2039,7 @@ func (bs *BinanceService) handleBinanceError(err error) error {
 	case contains(errStr, "-1121"):
 		return errors.New("invalid symbol")
 	case contains(errStr, "-1125"):
-		return errors.New("this listenKey does not exist")
+		return ErrListenKeyNotFound
 	case contains(errStr, "-2013"):
 		return errors.New("order does not exist")
 	case contains(errStr, "-2014"):
@@ -1490,7 +2057,7 @@ func contains(s, substr string) bool {
 }
 
 // GetOrderBook 获取订单簿
-func (bs *BinanceService) GetOrderBook(ctx context.Context, symbol string, limit int) (*binance.DepthResponse, error) {
+func (bs *BinanceService) GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
 	if symbol == "" {
 		return nil, ErrInvalidSymbol
 	}
@@ -1518,11 +2085,11 @@ func (bs *BinanceService) GetOrderBook(ctx context.Context, symbol string, limit
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return depth, nil
+	return toOrderBook(symbol, depth.LastUpdateID, depth.Bids, depth.Asks), nil
 }
 
-// GetFuturesDepth 获取期货订单簿深度
-func (bs *BinanceService) GetFuturesDepth(ctx context.Context, symbol string, limit int) (*futures.DepthResponse, error) {
+// GetFuturesOrderBook 获取期货订单簿深度
+func (bs *BinanceService) GetFuturesOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
 	if symbol == "" {
 		return nil, ErrInvalidSymbol
 	}
@@ -1550,7 +2117,7 @@ func (bs *BinanceService) GetFuturesDepth(ctx context.Context, symbol string, li
 		return nil, bs.handleBinanceError(err)
 	}
 
-	return depth, nil
+	return toOrderBook(symbol, depth.LastUpdateID, depth.Bids, depth.Asks), nil
 }
 
 // Close 关闭服务
@@ -1645,15 +2212,19 @@ func (bs *BinanceService) GetSymbolInfo(symbol string) (*SymbolInfo, error) {
 		return nil, err
 	}
 
-	return &SymbolInfo{
-		Symbol:              info.Symbol,
-		BaseAssetPrecision:  info.BaseAssetPrecision,
-		QuoteAssetPrecision: info.QuoteAssetPrecision,
-		MinQty:              info.MinQty,
-		MaxQty:              info.MaxQty,
-		StepSize:            info.StepSize,
-		MinNotional:         info.MinNotional,
-	}, nil
+	infoCopy := *info
+	return &infoCopy, nil
+}
+
+// GetFuturesSymbolInfo 获取期货交易对信息
+func (bs *BinanceService) GetFuturesSymbolInfo(symbol string) (*SymbolInfo, error) {
+	info, err := bs.getFuturesSymbolInfo(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	infoCopy := *info
+	return &infoCopy, nil
 }
 
 // GetSpotClientDirect 获取现货客户端（公开方法）
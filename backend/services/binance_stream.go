@@ -0,0 +1,168 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/common"
+	"github.com/adshao/go-binance/v2/futures"
+	"github.com/ccj241/cctrade/config"
+)
+
+var binanceStreamOnce sync.Once
+
+// binanceMarketStream 订阅币安bookTicker、depth和kline合并流，三条连接任一断开即视为整体断开
+func binanceMarketStream(market MarketType, symbols []string, opts MarketStreamOptions, handler MarketEventHandler, errHandler func(error)) (<-chan struct{}, func(), error) {
+	binanceStreamOnce.Do(func() {
+		if config.AppConfig != nil && config.AppConfig.Binance.TestNet {
+			binance.UseTestnet = true
+			futures.UseTestnet = true
+		}
+	})
+
+	levels := strconv.Itoa(opts.DepthLevels)
+	depthLevels := make(map[string]string, len(symbols))
+	klineIntervals := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		depthLevels[symbol] = levels
+		klineIntervals[symbol] = opts.KlineInterval
+	}
+
+	var starters []func() (chan struct{}, chan struct{}, error)
+	if market == MarketFutures {
+		starters = []func() (chan struct{}, chan struct{}, error){
+			func() (chan struct{}, chan struct{}, error) {
+				return futures.WsCombinedBookTickerServe(symbols, func(e *futures.WsBookTickerEvent) {
+					handler(newBookTickerEvent(market, e.Symbol, e.BestBidPrice, e.BestAskPrice, e.Time))
+				}, errHandler)
+			},
+			func() (chan struct{}, chan struct{}, error) {
+				return futures.WsCombinedDepthServe(depthLevels, func(e *futures.WsDepthEvent) {
+					handler(newDepthEvent(market, e.Symbol, e.LastUpdateID, e.Bids, e.Asks, e.Time))
+				}, errHandler)
+			},
+			func() (chan struct{}, chan struct{}, error) {
+				return futures.WsCombinedKlineServe(klineIntervals, func(e *futures.WsKlineEvent) {
+					k := e.Kline
+					handler(newKlineEvent(market, e.Symbol, k.StartTime, k.EndTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.IsFinal, e.Time))
+				}, errHandler)
+			},
+		}
+	} else {
+		starters = []func() (chan struct{}, chan struct{}, error){
+			func() (chan struct{}, chan struct{}, error) {
+				return binance.WsCombinedBookTickerServe(symbols, func(e *binance.WsBookTickerEvent) {
+					handler(newBookTickerEvent(market, e.Symbol, e.BestBidPrice, e.BestAskPrice, 0))
+				}, errHandler)
+			},
+			func() (chan struct{}, chan struct{}, error) {
+				return binance.WsCombinedPartialDepthServe(depthLevels, func(e *binance.WsPartialDepthEvent) {
+					handler(newDepthEvent(market, e.Symbol, e.LastUpdateID, e.Bids, e.Asks, 0))
+				}, errHandler)
+			},
+			func() (chan struct{}, chan struct{}, error) {
+				return binance.WsCombinedKlineServe(klineIntervals, func(e *binance.WsKlineEvent) {
+					k := e.Kline
+					handler(newKlineEvent(market, e.Symbol, k.StartTime, k.EndTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.IsFinal, e.Time))
+				}, errHandler)
+			},
+		}
+	}
+
+	var doneCs, stopCs []chan struct{}
+	stopAll := func() {
+		for _, stopC := range stopCs {
+			close(stopC)
+		}
+	}
+
+	for _, start := range starters {
+		doneC, stopC, err := start()
+		if err != nil {
+			stopAll()
+			return nil, nil, err
+		}
+		doneCs = append(doneCs, doneC)
+		stopCs = append(stopCs, stopC)
+	}
+
+	done := make(chan struct{})
+	var doneOnce sync.Once
+	for _, doneC := range doneCs {
+		go func(c chan struct{}) {
+			<-c
+			doneOnce.Do(func() { close(done) })
+		}(doneC)
+	}
+
+	var stopOnce sync.Once
+	return done, func() { stopOnce.Do(stopAll) }, nil
+}
+
+func newBookTickerEvent(market MarketType, symbol, bid, ask string, eventTime int64) *MarketEvent {
+	bestBid, _ := strconv.ParseFloat(bid, 64)
+	bestAsk, _ := strconv.ParseFloat(ask, 64)
+
+	price := bestBid
+	if bestBid > 0 && bestAsk > 0 {
+		price = (bestBid + bestAsk) / 2
+	}
+	return &MarketEvent{
+		Type:    MarketEventBookTicker,
+		Market:  market,
+		Symbol:  strings.ToUpper(symbol),
+		Price:   price,
+		BestBid: bestBid,
+		BestAsk: bestAsk,
+		Time:    eventTime,
+	}
+}
+
+func newDepthEvent(market MarketType, symbol string, updateID int64, bids, asks []common.PriceLevel, eventTime int64) *MarketEvent {
+	book := &OrderBook{
+		Symbol:       strings.ToUpper(symbol),
+		LastUpdateID: updateID,
+		Bids:         make([]PriceLevel, 0, len(bids)),
+		Asks:         make([]PriceLevel, 0, len(asks)),
+	}
+	for _, level := range bids {
+		price, quantity, err := level.Parse()
+		if err == nil {
+			book.Bids = append(book.Bids, PriceLevel{Price: price, Quantity: quantity})
+		}
+	}
+	for _, level := range asks {
+		price, quantity, err := level.Parse()
+		if err == nil {
+			book.Asks = append(book.Asks, PriceLevel{Price: price, Quantity: quantity})
+		}
+	}
+	return &MarketEvent{
+		Type:   MarketEventDepth,
+		Market: market,
+		Symbol: book.Symbol,
+		Depth:  book,
+		Time:   eventTime,
+	}
+}
+
+func newKlineEvent(market MarketType, symbol string, openTime, closeTime int64, open, high, low, closePrice, volume string, closed bool, eventTime int64) *MarketEvent {
+	kline := &KlineData{OpenTime: openTime, CloseTime: closeTime}
+	kline.Open, _ = strconv.ParseFloat(open, 64)
+	kline.High, _ = strconv.ParseFloat(high, 64)
+	kline.Low, _ = strconv.ParseFloat(low, 64)
+	kline.Close, _ = strconv.ParseFloat(closePrice, 64)
+	kline.Volume, _ = strconv.ParseFloat(volume, 64)
+
+	return &MarketEvent{
+		Type:        MarketEventKline,
+		Market:      market,
+		Symbol:      strings.ToUpper(symbol),
+		Price:       kline.Close,
+		Kline:       kline,
+		KlineClosed: closed,
+		Time:        eventTime,
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adshao/go-binance/v2"
+	"github.com/adshao/go-binance/v2/futures"
+)
+
+// BinanceService 实现UserDataStreamer接口
+var _ UserDataStreamer = (*BinanceService)(nil)
+
+// StartUserDataStream 创建现货或U本位合约listenKey
+func (bs *BinanceService) StartUserDataStream(ctx context.Context, market MarketType) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if market == MarketFutures {
+		client, err := bs.GetFuturesClient()
+		if err != nil {
+			return "", err
+		}
+		defer bs.futuresClientPool.Put(client)
+
+		listenKey, err := client.NewStartUserStreamService().Do(ctx)
+		if err != nil {
+			return "", bs.handleBinanceError(err)
+		}
+		return listenKey, nil
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return "", err
+	}
+	defer bs.clientPool.Put(client)
+
+	listenKey, err := client.NewStartUserStreamService().Do(ctx)
+	if err != nil {
+		return "", bs.handleBinanceError(err)
+	}
+	return listenKey, nil
+}
+
+// KeepaliveUserDataStream 延长listenKey有效期，listenKey已失效时返回ErrListenKeyNotFound
+func (bs *BinanceService) KeepaliveUserDataStream(ctx context.Context, market MarketType, listenKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if market == MarketFutures {
+		client, err := bs.GetFuturesClient()
+		if err != nil {
+			return err
+		}
+		defer bs.futuresClientPool.Put(client)
+
+		if err := client.NewKeepaliveUserStreamService().ListenKey(listenKey).Do(ctx); err != nil {
+			return bs.handleBinanceError(err)
+		}
+		return nil
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return err
+	}
+	defer bs.clientPool.Put(client)
+
+	if err := client.NewKeepaliveUserStreamService().ListenKey(listenKey).Do(ctx); err != nil {
+		return bs.handleBinanceError(err)
+	}
+	return nil
+}
+
+// CloseUserDataStream 关闭listenKey
+func (bs *BinanceService) CloseUserDataStream(ctx context.Context, market MarketType, listenKey string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if market == MarketFutures {
+		client, err := bs.GetFuturesClient()
+		if err != nil {
+			return err
+		}
+		defer bs.futuresClientPool.Put(client)
+
+		if err := client.NewCloseUserStreamService().ListenKey(listenKey).Do(ctx); err != nil {
+			return bs.handleBinanceError(err)
+		}
+		return nil
+	}
+
+	client, err := bs.GetSpotClient()
+	if err != nil {
+		return err
+	}
+	defer bs.clientPool.Put(client)
+
+	if err := client.NewCloseUserStreamService().ListenKey(listenKey).Do(ctx); err != nil {
+		return bs.handleBinanceError(err)
+	}
+	return nil
+}
+
+// ServeUserDataStream 连接用户数据流，只转发订单更新和listenKey过期事件
+func (bs *BinanceService) ServeUserDataStream(market MarketType, listenKey string, handler UserDataEventHandler, errHandler func(error)) (<-chan struct{}, func(), error) {
+	binanceStreamOnce.Do(func() {
+		if bs.testNet {
+			binance.UseTestnet = true
+			futures.UseTestnet = true
+		}
+	})
+
+	var doneC, stopC chan struct{}
+	var err error
+	if market == MarketFutures {
+		doneC, stopC, err = futures.WsUserDataServe(listenKey, func(e *futures.WsUserDataEvent) {
+			switch e.Event {
+			case futures.UserDataEventTypeOrderTradeUpdate:
+				handler(&UserDataEvent{
+					Type:   UserDataEventOrderUpdate,
+					Market: MarketFutures,
+					Order:  futuresOrderUpdate(&e.OrderTradeUpdate),
+					Time:   e.Time,
+				})
+			case futures.UserDataEventTypeListenKeyExpired:
+				handler(&UserDataEvent{Type: UserDataEventListenKeyExpired, Market: MarketFutures, Time: e.Time})
+			}
+		}, errHandler)
+	} else {
+		doneC, stopC, err = binance.WsUserDataServe(listenKey, func(e *binance.WsUserDataEvent) {
+			switch e.Event {
+			case binance.UserDataEventTypeExecutionReport:
+				handler(&UserDataEvent{
+					Type:   UserDataEventOrderUpdate,
+					Market: MarketSpot,
+					Order:  spotOrderUpdate(&e.OrderUpdate),
+					Time:   e.Time,
+				})
+			case userDataEventListenKeyExpired:
+				handler(&UserDataEvent{Type: UserDataEventListenKeyExpired, Market: MarketSpot, Time: e.Time})
+			}
+		}, errHandler)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var stopOnce sync.Once
+	return doneC, func() { stopOnce.Do(func() { close(stopC) }) }, nil
+}
+
+// userDataEventListenKeyExpired 现货SDK未定义listenKey过期事件类型
+const userDataEventListenKeyExpired binance.UserDataEventType = "listenKeyExpired"
+
+// spotOrderUpdate 将现货executionReport转换为订单更新，成交事件附带成交明细
+func spotOrderUpdate(e *binance.WsOrderUpdate) *OrderUpdate {
+	update := &OrderUpdate{
+		Market:             MarketSpot,
+		Symbol:             e.Symbol,
+		OrderID:            strconv.FormatInt(e.Id, 10),
+		ClientOrderID:      e.ClientOrderId,
+		Side:               e.Side,
+		Type:               e.Type,
+		Status:             e.Status,
+		ExecutionType:      e.ExecutionType,
+		ExecutedQty:        parseBinanceFloat(e.FilledVolume),
+		CumulativeQuoteQty: parseBinanceFloat(e.FilledQuoteVolume),
+		UpdateTime:         e.TransactionTime,
+	}
+	update.AvgPrice = averagePrice(update.CumulativeQuoteQty, update.ExecutedQty)
+
+	if e.ExecutionType == "TRADE" {
+		update.Fill = &OrderUpdateFill{
+			TradeID:         strconv.FormatInt(e.TradeId, 10),
+			Price:           parseBinanceFloat(e.LatestPrice),
+			Quantity:        parseBinanceFloat(e.LatestVolume),
+			QuoteQty:        parseBinanceFloat(e.LatestQuoteVolume),
+			Commission:      parseBinanceFloat(e.FeeCost),
+			CommissionAsset: e.FeeAsset,
+			IsMaker:         e.IsMaker,
+			Time:            e.TransactionTime,
+		}
+	}
+	return update
+}
+
+// futuresOrderUpdate 将合约ORDER_TRADE_UPDATE转换为订单更新，成交事件附带成交明细和已实现盈亏
+func futuresOrderUpdate(e *futures.WsOrderTradeUpdate) *OrderUpdate {
+	update := &OrderUpdate{
+		Market:        MarketFutures,
+		Symbol:        e.Symbol,
+		OrderID:       strconv.FormatInt(e.ID, 10),
+		ClientOrderID: e.ClientOrderID,
+		Side:          string(e.Side),
+		Type:          string(e.Type),
+		PositionSide:  string(e.PositionSide),
+		Status:        string(e.Status),
+		ExecutionType: string(e.ExecutionType),
+		ExecutedQty:   parseBinanceFloat(e.AccumulatedFilledQty),
+		AvgPrice:      parseBinanceFloat(e.AveragePrice),
+		ReduceOnly:    e.IsReduceOnly,
+		UpdateTime:    e.TradeTime,
+	}
+	update.CumulativeQuoteQty = update.ExecutedQty * update.AvgPrice
+
+	if e.ExecutionType == futures.OrderExecutionTypeTrade {
+		price := parseBinanceFloat(e.LastFilledPrice)
+		quantity := parseBinanceFloat(e.LastFilledQty)
+		update.Fill = &OrderUpdateFill{
+			TradeID:         strconv.FormatInt(e.TradeID, 10),
+			Price:           price,
+			Quantity:        quantity,
+			QuoteQty:        price * quantity,
+			Commission:      parseBinanceFloat(e.Commission),
+			CommissionAsset: e.CommissionAsset,
+			IsMaker:         e.IsMaker,
+			RealizedPnL:     parseBinanceFloat(e.RealizedPnL),
+			Time:            e.TradeTime,
+		}
+	}
+	return update
+}
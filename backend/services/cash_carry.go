@@ -0,0 +1,528 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+// cashCarryStateKey 期现套利状态在FuturesStrategy.State中的键
+const cashCarryStateKey = "cash_and_carry"
+
+// cashCarryFundingMaxAge 本地同步的资金费率超过该时长视为过期，改为向交易所查询
+const cashCarryFundingMaxAge = 15 * time.Minute
+
+// 期现套利运行阶段
+const (
+	cashCarryWaiting   = "waiting"   // 等待资金费率或基差达到入场条件
+	cashCarryOpen      = "open"      // 持有现货多头和永续空头
+	cashCarryUnwinding = "unwinding" // 收益收窄，正在平掉两腿
+)
+
+// CashCarryLeg 期现套利的一条腿，现货为多头持仓，永续为空头持仓
+type CashCarryLeg struct {
+	Quantity       float64 `json:"quantity"`    // 当前持仓数量，现货为扣除基础资产手续费后的到账数量
+	EntryValue     float64 `json:"entry_value"` // 当前持仓的开仓成交额（计价资产）
+	Fees           float64 `json:"fees"`        // 累计手续费（计价资产）
+	Realized       float64 `json:"realized"`    // 累计平仓盈亏，未扣除手续费
+	PendingOrderID string  `json:"pending_order_id,omitempty"`
+	PendingSide    string  `json:"pending_side,omitempty"`
+}
+
+// CashCarryState 期现套利运行状态
+type CashCarryState struct {
+	Phase           string       `json:"phase"`
+	Spot            CashCarryLeg `json:"spot"`
+	Perp            CashCarryLeg `json:"perp"`
+	EnteredAt       *time.Time   `json:"entered_at,omitempty"`
+	EntryFundingAPR float64      `json:"entry_funding_apr"` // 入场时的年化资金费率（百分数）
+	EntryBasis      float64      `json:"entry_basis"`       // 入场时永续相对现货的溢价（百分数）
+	FundingAPR      float64      `json:"funding_apr"`       // 最近一次检查时的年化资金费率
+	Basis           float64      `json:"basis"`             // 最近一次检查时的基差
+	Rebalances      int          `json:"rebalances"`
+	Cycles          int          `json:"cycles"` // 完成的开平仓周期
+	UnwindReason    string       `json:"unwind_reason,omitempty"`
+	CheckedAt       *time.Time   `json:"checked_at,omitempty"`
+}
+
+// cashCarryConfig 期现套利配置，百分比参数均为百分数。每个入场条件都有对应的出场条件，
+// 配置的全部条件都低于出场值时认为收益已收窄
+type cashCarryConfig struct {
+	entryFundingAPR    float64 // 年化资金费率达到该值时入场，0表示不按资金费率入场
+	exitFundingAPR     float64
+	entryBasis         float64 // 永续溢价达到该值时入场，0表示不按基差入场
+	exitBasis          float64
+	rebalanceThreshold float64 // 两腿数量偏差超过现货数量的该比例时调整永续腿
+	repeat             bool    // 平仓后是否继续等待下一次入场
+}
+
+func parseCashCarryConfig(config map[string]interface{}) (*cashCarryConfig, error) {
+	cfg := &cashCarryConfig{rebalanceThreshold: 2}
+	cfg.entryFundingAPR, _ = config["entry_funding_apr"].(float64)
+	cfg.exitFundingAPR, _ = config["exit_funding_apr"].(float64)
+	cfg.entryBasis, _ = config["entry_basis_percent"].(float64)
+	cfg.exitBasis, _ = config["exit_basis_percent"].(float64)
+	if threshold, ok := config["rebalance_threshold_percent"].(float64); ok {
+		cfg.rebalanceThreshold = threshold
+	}
+	cfg.repeat, _ = config["repeat"].(bool)
+
+	if cfg.entryFundingAPR < 0 || cfg.entryBasis < 0 {
+		return nil, errors.New("入场资金费率和基差不能为负数")
+	}
+	if cfg.entryFundingAPR == 0 && cfg.entryBasis == 0 {
+		return nil, errors.New("请至少设置年化资金费率(entry_funding_apr)或基差(entry_basis_percent)其中一个入场条件")
+	}
+	if cfg.entryFundingAPR > 0 && cfg.exitFundingAPR >= cfg.entryFundingAPR {
+		return nil, errors.New("出场年化资金费率必须低于入场值")
+	}
+	if cfg.entryBasis > 0 && cfg.exitBasis >= cfg.entryBasis {
+		return nil, errors.New("出场基差必须低于入场值")
+	}
+	if cfg.rebalanceThreshold <= 0 || cfg.rebalanceThreshold > 50 {
+		return nil, errors.New("再平衡阈值必须在0-50之间")
+	}
+	return cfg, nil
+}
+
+// shouldEnter 任一配置的入场条件达到即入场
+func (cfg *cashCarryConfig) shouldEnter(fundingAPR, basis float64) bool {
+	return (cfg.entryFundingAPR > 0 && fundingAPR >= cfg.entryFundingAPR) ||
+		(cfg.entryBasis > 0 && basis >= cfg.entryBasis)
+}
+
+// compressed 配置的全部条件都低于出场值
+func (cfg *cashCarryConfig) compressed(fundingAPR, basis float64) bool {
+	return (cfg.entryFundingAPR == 0 || fundingAPR < cfg.exitFundingAPR) &&
+		(cfg.entryBasis == 0 || basis < cfg.exitBasis)
+}
+
+func loadCashCarryState(strategy *models.FuturesStrategy) *CashCarryState {
+	state := &CashCarryState{Phase: cashCarryWaiting}
+	if strategy.State == nil || strategy.State[cashCarryStateKey] == nil {
+		return state
+	}
+	if err := decodeStateValue(strategy.State[cashCarryStateKey], state); err != nil {
+		return &CashCarryState{Phase: cashCarryWaiting}
+	}
+	return state
+}
+
+// cashCarryFundingAPR 年化资金费率（百分数），结算间隔由上一次结算时间推算，未知时按8小时计
+func (fs *FuturesService) cashCarryFundingAPR(symbol string, exchange Exchange) (float64, error) {
+	rate, err := NewFundingService().GetFundingRate(symbol)
+	if err != nil || time.Since(rate.UpdatedAt) > cashCarryFundingMaxAge {
+		rates, err := exchange.GetFundingRates(context.Background())
+		if err != nil {
+			return 0, fmt.Errorf("获取资金费率失败: %w", err)
+		}
+		rate = nil
+		for _, info := range rates {
+			if info.Symbol == symbol {
+				rate = &models.FundingRate{Symbol: symbol, FundingRate: info.LastFundingRate, NextFundingTime: info.NextFundingTime}
+				break
+			}
+		}
+		if rate == nil {
+			return 0, fmt.Errorf("交易对%s没有资金费率", symbol)
+		}
+	}
+
+	interval := 8 * time.Hour
+	if rate.SettledAt != nil {
+		if gap := rate.NextFundingTime.Sub(*rate.SettledAt); gap >= time.Hour && gap <= 8*time.Hour {
+			interval = gap
+		}
+	}
+	return rate.FundingRate * float64(365*24*time.Hour/interval) * 100, nil
+}
+
+// executeCashCarryStrategy 期现套利：资金费率或基差达到阈值时以市价买入现货并做空等量永续合约，
+// 持仓期间两腿数量偏差超过阈值时调整永续腿保持中性，收益收窄后平掉两腿。
+// 现货腿按margin_amount的计价资产金额买入，永续腿按策略杠杆占用保证金
+func (fs *FuturesService) executeCashCarryStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
+	cfg, err := parseCashCarryConfig(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	spotPrice, err := exchange.GetPrice(ctx, strategy.Symbol)
+	if err != nil {
+		return err
+	}
+	perpPrice, err := exchange.GetFuturesPrice(ctx, strategy.Symbol)
+	if err != nil {
+		return err
+	}
+	fundingAPR, err := fs.cashCarryFundingAPR(strategy.Symbol, exchange)
+	if err != nil {
+		return err
+	}
+	basis := (perpPrice - spotPrice) / spotPrice * 100
+
+	base, quote := splitSymbolBySuffix(strings.ToUpper(strategy.Symbol))
+	spotInfo, _ := exchange.GetSymbolInfo(strategy.Symbol)
+	perpInfo, _ := exchange.GetFuturesSymbolInfo(strategy.Symbol)
+
+	state := loadCashCarryState(strategy)
+	now := time.Now()
+	state.FundingAPR, state.Basis, state.CheckedAt = fundingAPR, basis, &now
+
+	fs.settleCashCarryOrders(state, exchange, strategy.Symbol, base, quote)
+	if state.Spot.PendingOrderID != "" || state.Perp.PendingOrderID != "" {
+		return fs.saveCashCarryState(strategy, state, spotPrice, perpPrice)
+	}
+
+	switch state.Phase {
+	case cashCarryWaiting:
+		if !cfg.shouldEnter(fundingAPR, basis) {
+			break
+		}
+		quantity := cashCarryQuantity(strategy.MarginAmount/spotPrice, spotInfo, perpInfo)
+		if quantity <= 0 || (spotInfo != nil && quantity*spotPrice < spotInfo.MinNotional) ||
+			(perpInfo != nil && quantity*perpPrice < perpInfo.MinNotional) {
+			return fmt.Errorf("期现套利策略%d下单数量%.8g低于交易所最小下单量", strategy.ID, quantity)
+		}
+
+		// 先买现货，永续腿下单失败时由再平衡补足空头
+		if err := fs.placeCashCarrySpotOrder(strategy, exchange, state, models.OrderSideBuy, quantity); err != nil {
+			return err
+		}
+		if err := fs.placeCashCarryPerpOrder(strategy, exchange, state, models.OrderSideSell, quantity); err != nil {
+			log.Printf("期现套利策略%d永续开空失败，将在下一轮补足: %v", strategy.ID, err)
+		}
+		state.Phase = cashCarryOpen
+		state.EnteredAt = &now
+		state.EntryFundingAPR, state.EntryBasis = fundingAPR, basis
+		state.UnwindReason = ""
+		log.Printf("期现套利策略%d入场：年化资金费率%.2f%%，基差%.4f%%，数量%.8g", strategy.ID, fundingAPR, basis, quantity)
+
+	case cashCarryOpen:
+		if cfg.compressed(fundingAPR, basis) {
+			state.Phase = cashCarryUnwinding
+			state.UnwindReason = fmt.Sprintf("年化资金费率%.2f%%，基差%.4f%%，收益收窄", fundingAPR, basis)
+			log.Printf("期现套利策略%d%s，开始平仓", strategy.ID, state.UnwindReason)
+			fs.unwindCashCarry(strategy, exchange, state, spotInfo, perpInfo)
+			break
+		}
+		fs.rebalanceCashCarry(strategy, exchange, state, cfg, perpInfo)
+
+	case cashCarryUnwinding:
+		if fs.unwindCashCarry(strategy, exchange, state, spotInfo, perpInfo) {
+			state.Phase = cashCarryWaiting
+			state.Cycles++
+			state.EnteredAt = nil
+			log.Printf("期现套利策略%d已平掉两腿，第%d个周期结束", strategy.ID, state.Cycles)
+			if !cfg.repeat {
+				if err := fs.saveCashCarryState(strategy, state, spotPrice, perpPrice); err != nil {
+					return err
+				}
+				return fs.completeFuturesStrategy(strategy, state.UnwindReason)
+			}
+		}
+	}
+
+	return fs.saveCashCarryState(strategy, state, spotPrice, perpPrice)
+}
+
+// cashCarryQuantity 按现货和永续中较粗的数量步长向下取整，保证两腿下单数量一致
+func cashCarryQuantity(quantity float64, infos ...*SymbolInfo) float64 {
+	for _, info := range infos {
+		if info != nil && info.StepSize > 0 {
+			quantity = roundToStep(quantity, info.StepSize, "floor")
+		}
+	}
+	return quantity
+}
+
+// cashCarryMinQty 低于交易所最小下单量的剩余数量无法下单，视为已平仓
+func cashCarryMinQty(info *SymbolInfo) float64 {
+	if info != nil && info.MinQty > 0 {
+		return info.MinQty
+	}
+	return pnlEpsilon
+}
+
+// rebalanceCashCarry 以现货数量为准调整永续空头，偏差超过阈值时增开空头或只减仓买回
+func (fs *FuturesService) rebalanceCashCarry(strategy *models.FuturesStrategy, exchange Exchange, state *CashCarryState, cfg *cashCarryConfig, perpInfo *SymbolInfo) {
+	if state.Spot.Quantity <= 0 {
+		return
+	}
+	diff := state.Spot.Quantity - state.Perp.Quantity
+	if math.Abs(diff)/state.Spot.Quantity*100 < cfg.rebalanceThreshold {
+		return
+	}
+	quantity := cashCarryQuantity(math.Abs(diff), perpInfo)
+	if quantity < cashCarryMinQty(perpInfo) {
+		return
+	}
+
+	side := models.OrderSideSell
+	if diff < 0 {
+		side = models.OrderSideBuy
+	}
+	if err := fs.placeCashCarryPerpOrder(strategy, exchange, state, side, quantity); err != nil {
+		log.Printf("期现套利策略%d再平衡失败: %v", strategy.ID, err)
+		return
+	}
+	state.Rebalances++
+	log.Printf("期现套利策略%d再平衡：现货%.8g，永续空头%.8g，永续%s %.8g", strategy.ID, state.Spot.Quantity, state.Perp.Quantity, side, quantity)
+}
+
+// unwindCashCarry 以市价卖出现货并只减仓买回永续空头，两腿都低于最小下单量时返回true
+func (fs *FuturesService) unwindCashCarry(strategy *models.FuturesStrategy, exchange Exchange, state *CashCarryState, spotInfo, perpInfo *SymbolInfo) bool {
+	done := true
+	if quantity := cashCarryQuantity(state.Spot.Quantity, spotInfo); quantity >= cashCarryMinQty(spotInfo) {
+		done = false
+		if err := fs.placeCashCarrySpotOrder(strategy, exchange, state, models.OrderSideSell, quantity); err != nil {
+			log.Printf("期现套利策略%d卖出现货失败: %v", strategy.ID, err)
+		}
+	}
+	if quantity := cashCarryQuantity(state.Perp.Quantity, perpInfo); quantity >= cashCarryMinQty(perpInfo) {
+		done = false
+		if err := fs.placeCashCarryPerpOrder(strategy, exchange, state, models.OrderSideBuy, quantity); err != nil {
+			log.Printf("期现套利策略%d买回永续空头失败: %v", strategy.ID, err)
+		}
+	}
+	if done {
+		// 取整后剩余的零头不再跟踪，持仓成本计入已实现盈亏
+		state.Spot.Realized -= state.Spot.EntryValue
+		state.Perp.Realized += state.Perp.EntryValue
+		state.Spot.Quantity, state.Spot.EntryValue = 0, 0
+		state.Perp.Quantity, state.Perp.EntryValue = 0, 0
+	}
+	return done
+}
+
+// placeCashCarrySpotOrder 现货腿市价单，订单不关联现货策略
+func (fs *FuturesService) placeCashCarrySpotOrder(strategy *models.FuturesStrategy, exchange Exchange, state *CashCarryState, side models.OrderSide, quantity float64) error {
+	order := &models.Order{
+		UserID:        strategy.UserID,
+		Symbol:        strategy.Symbol,
+		Side:          side,
+		Type:          models.OrderTypeMarket,
+		Quantity:      quantity,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+	resp, err := exchange.CreateSpotOrder(context.Background(), order)
+	if err != nil {
+		return err
+	}
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
+	if err := fs.db.Create(order).Error; err != nil {
+		log.Printf("保存订单失败: %v", err)
+	}
+	state.Spot.PendingOrderID, state.Spot.PendingSide = order.OrderID, string(side)
+	return nil
+}
+
+// placeCashCarryPerpOrder 永续腿市价单，卖出为开空，买入为只减仓平空
+func (fs *FuturesService) placeCashCarryPerpOrder(strategy *models.FuturesStrategy, exchange Exchange, state *CashCarryState, side models.OrderSide, quantity float64) error {
+	order, err := fs.placeFuturesGridOrder(strategy, exchange, side, models.PositionSideShort, models.OrderTypeMarket, 0, quantity, side == models.OrderSideBuy)
+	if err != nil {
+		return err
+	}
+	state.Perp.PendingOrderID, state.Perp.PendingSide = order.OrderID, string(side)
+	return nil
+}
+
+// settleCashCarryOrders 结算两腿已结束的订单，订单记录不存在时放弃跟踪
+func (fs *FuturesService) settleCashCarryOrders(state *CashCarryState, exchange Exchange, symbol, base, quote string) {
+	if state.Spot.PendingOrderID != "" {
+		var order models.Order
+		err := fs.db.Where("order_id = ?", state.Spot.PendingOrderID).First(&order).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			state.Spot.PendingOrderID, state.Spot.PendingSide = "", ""
+		case err == nil && isFinalOrderStatus(string(order.Status)):
+			baseFee, quoteFee := (&StrategyService{db: fs.db}).orderFees(order.OrderID, base, quote)
+			if baseFee == 0 && quoteFee == 0 && order.ExecutedQty > 0 {
+				// 成交记录尚未同步（如模拟账户）时向交易所查询成交明细
+				baseFee, quoteFee = exchangeOrderFees(exchange, symbol, order.OrderID, base, quote)
+			}
+			applyCashCarrySpotFill(&state.Spot, &order, baseFee, quoteFee)
+		}
+	}
+
+	if state.Perp.PendingOrderID != "" {
+		var order models.FuturesOrder
+		err := fs.db.Where("order_id = ?", state.Perp.PendingOrderID).First(&order).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			state.Perp.PendingOrderID, state.Perp.PendingSide = "", ""
+		case err == nil && isFinalOrderStatus(string(order.Status)):
+			fee := fs.futuresOrderFee(order.OrderID, quote)
+			if fee == 0 && order.ExecutedQty > 0 {
+				if fills, err := exchange.GetFuturesOrderTrades(context.Background(), symbol, order.OrderID); err == nil {
+					for _, f := range fills {
+						if strings.EqualFold(f.CommissionAsset, quote) {
+							fee += f.Commission
+						}
+					}
+				}
+			}
+			applyCashCarryPerpFill(&state.Perp, &order, fee)
+		}
+	}
+}
+
+// applyCashCarrySpotFill 现货买入增加持仓，基础资产手续费减少到账数量；卖出按平均成本结转盈亏
+func applyCashCarrySpotFill(leg *CashCarryLeg, order *models.Order, baseFee, quoteFee float64) {
+	executed, quoteQty := order.ExecutedQty, order.CumulativeQuoteQty
+	if quoteQty <= 0 {
+		quoteQty = executed * order.Price
+	}
+	price := 0.0
+	if executed > 0 {
+		price = quoteQty / executed
+	}
+	leg.Fees += quoteFee + baseFee*price
+
+	if models.OrderSide(leg.PendingSide) == models.OrderSideBuy {
+		leg.Quantity += executed - baseFee
+		leg.EntryValue += quoteQty
+	} else if executed > 0 && leg.Quantity > 0 {
+		closed := math.Min(executed, leg.Quantity)
+		cost := leg.EntryValue * closed / leg.Quantity
+		leg.Realized += quoteQty*closed/executed - cost
+		leg.EntryValue -= cost
+		leg.Quantity -= closed + baseFee
+		if leg.Quantity < pnlEpsilon {
+			leg.Quantity, leg.EntryValue = 0, 0
+		}
+	}
+	leg.PendingOrderID, leg.PendingSide = "", ""
+}
+
+// applyCashCarryPerpFill 永续卖出增加空头持仓，买入按平均开仓价结转空头盈亏
+func applyCashCarryPerpFill(leg *CashCarryLeg, order *models.FuturesOrder, fee float64) {
+	executed, quoteQty := order.ExecutedQty, order.CumulativeQuoteQty
+	if quoteQty <= 0 {
+		quoteQty = executed * order.Price
+	}
+	leg.Fees += fee
+
+	if models.OrderSide(leg.PendingSide) == models.OrderSideSell {
+		leg.Quantity += executed
+		leg.EntryValue += quoteQty
+	} else if executed > 0 && leg.Quantity > 0 {
+		closed := math.Min(executed, leg.Quantity)
+		entry := leg.EntryValue * closed / leg.Quantity
+		leg.Realized += entry - quoteQty*closed/executed
+		leg.EntryValue -= entry
+		leg.Quantity -= closed
+		if leg.Quantity < pnlEpsilon {
+			leg.Quantity, leg.EntryValue = 0, 0
+		}
+	}
+	leg.PendingOrderID, leg.PendingSide = "", ""
+}
+
+// CashCarryReport 期现套利两腿合并的持仓和盈亏，金额以计价资产计
+type CashCarryReport struct {
+	StrategyID      uint           `json:"strategy_id"`
+	Symbol          string         `json:"symbol"`
+	Phase           string         `json:"phase"`
+	SpotPrice       float64        `json:"spot_price"`
+	PerpPrice       float64        `json:"perp_price"`
+	Basis           float64        `json:"basis"`
+	FundingAPR      float64        `json:"funding_apr"`
+	SpotQuantity    float64        `json:"spot_quantity"`
+	PerpQuantity    float64        `json:"perp_quantity"`
+	Delta           float64        `json:"delta"` // 现货数量减永续空头数量
+	SpotUnrealized  float64        `json:"spot_unrealized"`
+	PerpUnrealized  float64        `json:"perp_unrealized"`
+	SpotRealized    float64        `json:"spot_realized"`
+	PerpRealized    float64        `json:"perp_realized"`
+	Fees            float64        `json:"fees"`
+	Funding         float64        `json:"funding"` // 归属该策略的资金费用，模拟交易不结算资金费用
+	NetPnL          float64        `json:"net_pnl"` // 两腿已实现和浮动盈亏合计，扣除手续费并计入资金费用
+	State           CashCarryState `json:"state"`
+	ValuationSource string         `json:"valuation_source"` // live或state，行情不可用时按最近一次检查时的价格估值
+}
+
+// cashCarryReport 按给定价格汇总两腿盈亏
+func (fs *FuturesService) cashCarryReport(strategy *models.FuturesStrategy, state *CashCarryState, spotPrice, perpPrice float64) *CashCarryReport {
+	report := &CashCarryReport{
+		StrategyID:     strategy.ID,
+		Symbol:         strategy.Symbol,
+		Phase:          state.Phase,
+		SpotPrice:      spotPrice,
+		PerpPrice:      perpPrice,
+		Basis:          state.Basis,
+		FundingAPR:     state.FundingAPR,
+		SpotQuantity:   state.Spot.Quantity,
+		PerpQuantity:   state.Perp.Quantity,
+		Delta:          state.Spot.Quantity - state.Perp.Quantity,
+		SpotUnrealized: state.Spot.Quantity*spotPrice - state.Spot.EntryValue,
+		PerpUnrealized: state.Perp.EntryValue - state.Perp.Quantity*perpPrice,
+		SpotRealized:   state.Spot.Realized,
+		PerpRealized:   state.Perp.Realized,
+		Fees:           state.Spot.Fees + state.Perp.Fees,
+		State:          *state,
+	}
+
+	var funding struct{ Total float64 }
+	fs.db.Model(&models.FuturesIncomeAllocation{}).
+		Select("COALESCE(SUM(income), 0) AS total").
+		Where("user_id = ? AND strategy_id = ? AND symbol = ?", strategy.UserID, strategy.ID, strategy.Symbol).
+		Scan(&funding)
+	report.Funding = funding.Total
+
+	report.NetPnL = report.SpotRealized + report.PerpRealized + report.SpotUnrealized + report.PerpUnrealized -
+		report.Fees + report.Funding
+	return report
+}
+
+func (fs *FuturesService) saveCashCarryState(strategy *models.FuturesStrategy, state *CashCarryState, spotPrice, perpPrice float64) error {
+	strategyState := strategy.State
+	if strategyState == nil {
+		strategyState = make(models.StrategyState)
+	}
+	report := fs.cashCarryReport(strategy, state, spotPrice, perpPrice)
+	strategyState[cashCarryStateKey] = encodeStateValue(state)
+	strategyState["carry_spot_price"] = spotPrice
+	strategyState["carry_perp_price"] = perpPrice
+	strategyState["carry_net_pnl"] = report.NetPnL
+	return fs.db.Model(strategy).Update("state", strategyState).Error
+}
+
+// GetCashCarryReport 期现套利策略两腿合并的盈亏，按当前行情估值，行情不可用时使用最近一次检查时的价格
+func (fs *FuturesService) GetCashCarryReport(userID, strategyID uint) (*CashCarryReport, error) {
+	var strategy models.FuturesStrategy
+	if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+		return nil, errors.New("期货策略不存在")
+	}
+	if strategy.Type != models.StrategyCashCarry {
+		return nil, errors.New("该策略不是期现套利策略")
+	}
+
+	state := loadCashCarryState(&strategy)
+	spotPrice, _ := strategy.State["carry_spot_price"].(float64)
+	perpPrice, _ := strategy.State["carry_perp_price"].(float64)
+	source := "state"
+	if exchange, err := fs.userService.GetTradingExchange(userID, strategy.PaperTrading); err == nil {
+		ctx := context.Background()
+		spot, spotErr := exchange.GetPrice(ctx, strategy.Symbol)
+		perp, perpErr := exchange.GetFuturesPrice(ctx, strategy.Symbol)
+		if spotErr == nil && perpErr == nil {
+			spotPrice, perpPrice, source = spot, perp, "live"
+		}
+	}
+
+	report := fs.cashCarryReport(&strategy, state, spotPrice, perpPrice)
+	report.ValuationSource = source
+	return report, nil
+}
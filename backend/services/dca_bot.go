@@ -0,0 +1,461 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// dcaModeBot 现货DCA策略config.mode取该值时按马丁格尔式加仓机器人运行，否则按固定间隔定投
+const dcaModeBot = "bot"
+
+// dcaStateKey DCA机器人状态在State中的键
+const dcaStateKey = "dca_bot"
+
+// dcaHistoryLimit State中保留的已完成周期数
+const dcaHistoryLimit = 20
+
+// DCACycle 一个已完成的DCA周期
+type DCACycle struct {
+	Cycle        int       `json:"cycle"`
+	SafetyOrders int       `json:"safety_orders"`
+	Quantity     float64   `json:"quantity"`
+	AveragePrice float64   `json:"average_price"`
+	ExitPrice    float64   `json:"exit_price"`
+	Profit       float64   `json:"profit"`
+	StartedAt    time.Time `json:"started_at"`
+	ClosedAt     time.Time `json:"closed_at"`
+}
+
+// DCABotState DCA机器人运行状态。一个周期从基础单开始，价格每向不利方向偏离到下一档就以市价追加一笔安全单，
+// 每次成交后按新的持仓均价重挂止盈单，止盈单完全成交后结束本周期并开始下一周期
+type DCABotState struct {
+	Direction          string     `json:"direction"` // long或short
+	Cycle              int        `json:"cycle"`
+	CycleStartedAt     *time.Time `json:"cycle_started_at,omitempty"`
+	BasePrice          float64    `json:"base_price"` // 基础单成交均价，安全单触发价以此计算
+	SafetyOrdersFilled int        `json:"safety_orders_filled"`
+	Position           float64    `json:"position"`
+	EntryValue         float64    `json:"entry_value"` // 持仓的开仓成交额（计价资产）
+	Fees               float64    `json:"fees"`        // 持仓已支付的开仓手续费（计价资产）
+	AveragePrice       float64    `json:"average_price"`
+	CycleProfit        float64    `json:"cycle_profit"` // 本周期止盈单部分成交已实现的利润
+	PendingOrderID     string     `json:"pending_order_id,omitempty"`
+	PendingSafety      int        `json:"pending_safety"` // 待成交开仓单的序号，0为基础单
+	TakeProfitOrderID  string     `json:"take_profit_order_id,omitempty"`
+	TakeProfitPrice    float64    `json:"take_profit_price,omitempty"`
+	TotalProfit        float64    `json:"total_profit"`
+	CompletedCycles    int        `json:"completed_cycles"`
+	History            []DCACycle `json:"history,omitempty"`
+}
+
+// dcaBotConfig DCA机器人配置，百分比参数均为百分数
+type dcaBotConfig struct {
+	safetyOrders    int
+	priceDeviation  float64 // 第一笔安全单相对基础单成交价的偏离
+	stepScale       float64 // 后续每档偏离相对上一档的放大倍数
+	safetyOrderSize float64 // 第一笔安全单数量相对基础单的倍数
+	volumeScale     float64 // 后续每笔安全单数量相对上一笔的倍数
+	takeProfit      float64 // 止盈价相对持仓均价的偏离
+	maxCycles       int     // 0表示不限周期数
+}
+
+func parseDCABotConfig(config map[string]interface{}) (*dcaBotConfig, error) {
+	cfg := &dcaBotConfig{stepScale: 1, safetyOrderSize: 1, volumeScale: 1}
+
+	safetyOrders, _ := config["safety_orders"].(float64)
+	if safetyOrders < 0 || safetyOrders != math.Trunc(safetyOrders) {
+		return nil, errors.New("安全单数量必须为非负整数")
+	}
+	cfg.safetyOrders = int(safetyOrders)
+
+	cfg.priceDeviation, _ = config["price_deviation"].(float64)
+	if cfg.safetyOrders > 0 && (cfg.priceDeviation <= 0 || cfg.priceDeviation >= 100) {
+		return nil, errors.New("安全单价格偏离必须在0-100之间")
+	}
+
+	for key, target := range map[string]*float64{
+		"step_scale":        &cfg.stepScale,
+		"safety_order_size": &cfg.safetyOrderSize,
+		"volume_scale":      &cfg.volumeScale,
+	} {
+		if value, exists := config[key]; exists {
+			number, ok := value.(float64)
+			if !ok || number <= 0 {
+				return nil, fmt.Errorf("%s必须大于0", key)
+			}
+			*target = number
+		}
+	}
+
+	cfg.takeProfit, _ = config["take_profit"].(float64)
+	if cfg.takeProfit <= 0 || cfg.takeProfit >= 100 {
+		return nil, errors.New("止盈百分比必须在0-100之间")
+	}
+
+	maxCycles, _ := config["max_cycles"].(float64)
+	if maxCycles < 0 {
+		return nil, errors.New("最大周期数不能为负数")
+	}
+	cfg.maxCycles = int(maxCycles)
+
+	// 最后一档安全单的偏离不能达到100%
+	if cfg.safetyOrders > 0 && cfg.safetyDeviation(cfg.safetyOrders) >= 100 {
+		return nil, errors.New("安全单累计价格偏离必须小于100%")
+	}
+	return cfg, nil
+}
+
+// safetyDeviation 第n笔安全单（从1开始）相对基础单成交价的累计偏离百分比
+func (cfg *dcaBotConfig) safetyDeviation(n int) float64 {
+	deviation, step := 0.0, cfg.priceDeviation
+	for i := 0; i < n; i++ {
+		deviation += step
+		step *= cfg.stepScale
+	}
+	return deviation
+}
+
+// safetyQuantity 第n笔安全单（从1开始）的数量
+func (cfg *dcaBotConfig) safetyQuantity(baseQuantity float64, n int) float64 {
+	return baseQuantity * cfg.safetyOrderSize * math.Pow(cfg.volumeScale, float64(n-1))
+}
+
+// dcaBot 单次执行的DCA机器人
+type dcaBot struct {
+	label string
+	cfg   *dcaBotConfig
+	state *DCABotState
+	venue orderVenue
+	now   time.Time
+}
+
+func (bot *dcaBot) long() bool {
+	return bot.state.Direction != string(GridBiasShort)
+}
+
+func (bot *dcaBot) entrySide() models.OrderSide {
+	if bot.long() {
+		return models.OrderSideBuy
+	}
+	return models.OrderSideSell
+}
+
+// run 执行一轮，返回值表示已达到最大周期数
+func (bot *dcaBot) run() (bool, error) {
+	state := bot.state
+
+	if state.TakeProfitOrderID != "" {
+		fill, err := bot.venue.orderFill(state.TakeProfitOrderID)
+		if err != nil {
+			return false, err
+		}
+		if fill != nil {
+			bot.applyExit(fill)
+			state.TakeProfitOrderID, state.TakeProfitPrice = "", 0
+		}
+	}
+
+	replaceTakeProfit, err := bot.settleEntry()
+	if err != nil {
+		return false, err
+	}
+	if state.PendingOrderID != "" {
+		return false, nil
+	}
+
+	price, err := bot.venue.price()
+	if err != nil {
+		return false, err
+	}
+
+	if state.Position <= 0 && state.TakeProfitOrderID == "" {
+		if bot.cfg.maxCycles > 0 && state.CompletedCycles >= bot.cfg.maxCycles {
+			return true, nil
+		}
+		if err := bot.placeEntry(0, bot.venue.defaultQuantity(price)); err != nil {
+			return false, err
+		}
+		if replaceTakeProfit, err = bot.settleEntry(); err != nil || state.PendingOrderID != "" {
+			return false, err
+		}
+	} else if next := state.SafetyOrdersFilled + 1; state.Position > 0 && next <= bot.cfg.safetyOrders {
+		deviation := bot.cfg.safetyDeviation(next) / 100
+		trigger := state.BasePrice * (1 - deviation)
+		triggered := price <= trigger
+		if !bot.long() {
+			trigger = state.BasePrice * (1 + deviation)
+			triggered = price >= trigger
+		}
+		if triggered {
+			quantity := bot.cfg.safetyQuantity(bot.venue.defaultQuantity(state.BasePrice), next)
+			if err := bot.placeEntry(next, quantity); err != nil {
+				return false, err
+			}
+			changed, err := bot.settleEntry()
+			if err != nil || state.PendingOrderID != "" {
+				return false, err
+			}
+			replaceTakeProfit = replaceTakeProfit || changed
+		}
+	}
+
+	if state.Position > 0 && (replaceTakeProfit || state.TakeProfitOrderID == "") {
+		bot.replaceTakeProfit()
+	}
+	return false, nil
+}
+
+// placeEntry 以市价下开仓单，safety为0表示基础单
+func (bot *dcaBot) placeEntry(safety int, quantity float64) error {
+	_, quantity = roundGridOrder(bot.venue.symbolInfo(), 0, quantity)
+	if quantity <= 0 {
+		return errors.New("DCA下单数量过小")
+	}
+	if info := bot.venue.symbolInfo(); info != nil && quantity < info.MinQty {
+		return fmt.Errorf("DCA下单数量%.8f小于最小下单数量%.8f", quantity, info.MinQty)
+	}
+
+	orderID, err := bot.venue.placeOrder(bot.entrySide(), models.OrderTypeMarket, 0, quantity, false)
+	if err != nil {
+		return err
+	}
+	if safety == 0 {
+		bot.state.Cycle++
+		bot.state.CycleStartedAt = &bot.now
+		bot.state.SafetyOrdersFilled = 0
+		bot.state.CycleProfit = 0
+	}
+	bot.state.PendingOrderID, bot.state.PendingSafety = orderID, safety
+	return nil
+}
+
+// settleEntry 结算已结束的开仓单，返回是否有新成交需要重挂止盈单
+func (bot *dcaBot) settleEntry() (bool, error) {
+	state := bot.state
+	if state.PendingOrderID == "" {
+		return false, nil
+	}
+	fill, err := bot.venue.orderFill(state.PendingOrderID)
+	if err != nil || fill == nil {
+		return false, err
+	}
+
+	safety := state.PendingSafety
+	state.PendingOrderID, state.PendingSafety = "", 0
+	if fill.Executed <= 0 {
+		if safety == 0 {
+			// 基础单未成交，下一轮重新开始本周期
+			state.Cycle--
+			state.CycleStartedAt = nil
+		}
+		return false, nil
+	}
+
+	quantity := fill.Executed
+	if bot.long() {
+		quantity -= fill.BaseFee
+	}
+	state.Position += quantity
+	state.EntryValue += fill.QuoteQty
+	state.Fees += fill.QuoteFee
+	state.AveragePrice = state.EntryValue / state.Position
+	if safety == 0 {
+		state.BasePrice = fill.QuoteQty / fill.Executed
+	} else {
+		state.SafetyOrdersFilled = safety
+	}
+	return true, nil
+}
+
+// applyExit 结算止盈单的成交，完全成交时结束本周期
+func (bot *dcaBot) applyExit(fill *venueFill) {
+	state := bot.state
+	if fill.Executed > 0 && state.Position > 0 {
+		closed := math.Min(fill.Executed+fill.BaseFee, state.Position)
+		if fill.Filled {
+			// 完全成交后不足一个步长的余量一并结转
+			closed = state.Position
+		}
+		portion := closed / state.Position
+		entryValue, entryFees := state.EntryValue*portion, state.Fees*portion
+
+		profit := fill.QuoteQty - entryValue
+		if !bot.long() {
+			profit = -profit
+		}
+		profit -= entryFees + fill.QuoteFee
+
+		state.Position -= closed
+		state.EntryValue -= entryValue
+		state.Fees -= entryFees
+		state.CycleProfit += profit
+		state.TotalProfit += profit
+	}
+
+	if !fill.Filled && state.Position > 1e-12 {
+		return
+	}
+
+	cycle := DCACycle{
+		Cycle:        state.Cycle,
+		SafetyOrders: state.SafetyOrdersFilled,
+		AveragePrice: state.AveragePrice,
+		Profit:       state.CycleProfit,
+		ClosedAt:     bot.now,
+	}
+	if fill.Executed > 0 {
+		cycle.Quantity = fill.Executed
+		cycle.ExitPrice = fill.QuoteQty / fill.Executed
+	}
+	if state.CycleStartedAt != nil {
+		cycle.StartedAt = *state.CycleStartedAt
+	}
+	state.History = append(state.History, cycle)
+	if len(state.History) > dcaHistoryLimit {
+		state.History = state.History[len(state.History)-dcaHistoryLimit:]
+	}
+	log.Printf("%s第%d个DCA周期止盈完成，利润%.8f", bot.label, state.Cycle, state.CycleProfit)
+
+	state.CompletedCycles++
+	state.CycleStartedAt = nil
+	state.BasePrice, state.SafetyOrdersFilled = 0, 0
+	state.Position, state.EntryValue, state.Fees, state.AveragePrice, state.CycleProfit = 0, 0, 0, 0, 0
+}
+
+// replaceTakeProfit 撤销旧止盈单并按当前持仓均价重挂，旧止盈单在撤单前已成交的部分先结转
+func (bot *dcaBot) replaceTakeProfit() {
+	state := bot.state
+	if state.TakeProfitOrderID != "" {
+		if err := bot.venue.cancelOrder(state.TakeProfitOrderID); err != nil {
+			log.Printf("%s撤销止盈单%s失败: %v", bot.label, state.TakeProfitOrderID, err)
+		}
+		fill, err := bot.venue.orderFill(state.TakeProfitOrderID)
+		if err != nil || fill == nil {
+			// 撤单未生效，保留旧止盈单等待下一轮
+			return
+		}
+		bot.applyExit(fill)
+		state.TakeProfitOrderID, state.TakeProfitPrice = "", 0
+		if state.Position <= 0 {
+			return
+		}
+	}
+
+	side, price := models.OrderSideSell, state.AveragePrice*(1+bot.cfg.takeProfit/100)
+	if !bot.long() {
+		side, price = models.OrderSideBuy, state.AveragePrice*(1-bot.cfg.takeProfit/100)
+	}
+	price, quantity := roundGridOrder(bot.venue.symbolInfo(), price, state.Position)
+	if quantity <= 0 {
+		return
+	}
+
+	orderID, err := bot.venue.placeOrder(side, models.OrderTypeLimit, price, quantity, true)
+	if err != nil {
+		log.Printf("%s挂止盈单失败: %v", bot.label, err)
+		return
+	}
+	state.TakeProfitOrderID, state.TakeProfitPrice = orderID, price
+}
+
+// dcaStats DCA机器人的周期和利润汇总
+func dcaStats(state models.StrategyState) map[string]interface{} {
+	if state == nil || state[dcaStateKey] == nil {
+		return nil
+	}
+	var bot DCABotState
+	if err := decodeStateValue(state[dcaStateKey], &bot); err != nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"direction":            bot.Direction,
+		"cycle":                bot.Cycle,
+		"completed_cycles":     bot.CompletedCycles,
+		"total_profit":         bot.TotalProfit,
+		"position":             bot.Position,
+		"average_price":        bot.AveragePrice,
+		"safety_orders_filled": bot.SafetyOrdersFilled,
+		"take_profit_price":    bot.TakeProfitPrice,
+		"history":              bot.History,
+	}
+}
+
+// loadDCABotState 读取DCA机器人状态，不存在时按方向新建
+func loadDCABotState(state models.StrategyState, direction string) *DCABotState {
+	var bot DCABotState
+	if state != nil && state[dcaStateKey] != nil {
+		if err := decodeStateValue(state[dcaStateKey], &bot); err != nil {
+			bot = DCABotState{}
+		}
+	}
+	bot.Direction = direction
+	return &bot
+}
+
+func saveDCABotState(db *gorm.DB, model interface{}, strategyState models.StrategyState, bot *DCABotState) error {
+	if strategyState == nil {
+		strategyState = make(models.StrategyState)
+	}
+	strategyState[dcaStateKey] = encodeStateValue(bot)
+	strategyState["dca_profit"] = bot.TotalProfit
+	strategyState["dca_cycles"] = bot.CompletedCycles
+	return db.Model(model).Update("state", strategyState).Error
+}
+
+// executeDCABot 现货DCA机器人，基础单数量为strategy.Quantity
+func (ss *StrategyService) executeDCABot(strategy *models.Strategy, exchange Exchange) error {
+	cfg, err := parseDCABotConfig(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	bot := &dcaBot{
+		label: fmt.Sprintf("策略%d", strategy.ID),
+		cfg:   cfg,
+		state: loadDCABotState(strategy.State, string(GridBiasLong)),
+		venue: newSpotOrderVenue(ss, strategy, exchange),
+		now:   ss.currentTime(),
+	}
+	done, runErr := bot.run()
+	if err := saveDCABotState(ss.db, strategy, strategy.State, bot.state); err != nil {
+		return err
+	}
+	if done {
+		return ss.completeStrategy(strategy, "定投周期已结束")
+	}
+	return runErr
+}
+
+// executeFuturesDCAStrategy 期货DCA机器人，side为buy时做多、sell时做空
+func (fs *FuturesService) executeFuturesDCAStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
+	cfg, err := parseDCABotConfig(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	direction := string(GridBiasLong)
+	if strategy.Side == models.OrderSideSell {
+		direction = string(GridBiasShort)
+	}
+	bot := &dcaBot{
+		label: fmt.Sprintf("期货策略%d", strategy.ID),
+		cfg:   cfg,
+		state: loadDCABotState(strategy.State, direction),
+		venue: newFuturesOrderVenue(fs, strategy, exchange),
+		now:   time.Now(),
+	}
+	done, runErr := bot.run()
+	if err := saveDCABotState(fs.db, strategy, strategy.State, bot.state); err != nil {
+		return err
+	}
+	if done {
+		return fs.completeFuturesStrategy(strategy, "定投周期已结束")
+	}
+	return runErr
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDCABotSafetyDeviation(t *testing.T) {
+	tests := []struct {
+		name      string
+		deviation float64
+		stepScale float64
+		n         int
+		want      float64
+	}{
+		{"no safety orders", 2, 1, 0, 0},
+		{"first safety order", 2, 1, 1, 2},
+		{"linear steps", 2, 1, 3, 6},
+		{"scaled steps", 1, 2, 3, 7},         // 1 + 2 + 4
+		{"fractional scale", 2, 1.5, 3, 9.5}, // 2 + 3 + 4.5
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &dcaBotConfig{priceDeviation: tt.deviation, stepScale: tt.stepScale}
+			if got := cfg.safetyDeviation(tt.n); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("safetyDeviation(%d) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDCABotConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"safety_orders": 3.0, "price_deviation": 2.0, "take_profit": 1.5}, false},
+		{"no safety orders", map[string]interface{}{"take_profit": 1.5}, false},
+		{"fractional safety orders", map[string]interface{}{"safety_orders": 1.5, "price_deviation": 2.0, "take_profit": 1.5}, true},
+		{"missing deviation", map[string]interface{}{"safety_orders": 3.0, "take_profit": 1.5}, true},
+		{"missing take profit", map[string]interface{}{"safety_orders": 3.0, "price_deviation": 2.0}, true},
+		{"non-positive step scale", map[string]interface{}{"safety_orders": 3.0, "price_deviation": 2.0, "step_scale": 0.0, "take_profit": 1.5}, true},
+		{"cumulative deviation reaches 100%", map[string]interface{}{"safety_orders": 5.0, "price_deviation": 10.0, "step_scale": 2.0, "take_profit": 1.5}, true},
+		{"negative max cycles", map[string]interface{}{"take_profit": 1.5, "max_cycles": -1.0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseDCABotConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDCABotConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDCABotApplyExit(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	openState := func(direction string, position float64) *DCABotState {
+		return &DCABotState{
+			Direction:          direction,
+			Cycle:              1,
+			CycleStartedAt:     &started,
+			BasePrice:          100,
+			SafetyOrdersFilled: 1,
+			Position:           position,
+			EntryValue:         200,
+			Fees:               0.2,
+			AveragePrice:       200 / position,
+		}
+	}
+
+	tests := []struct {
+		name          string
+		state         *DCABotState
+		fill          venueFill
+		wantProfit    float64
+		wantPosition  float64
+		wantCompleted int
+	}{
+		{
+			name:         "long partial exit realizes pro-rata profit",
+			state:        openState("long", 2),
+			fill:         venueFill{Executed: 1, QuoteQty: 110, QuoteFee: 0.11},
+			wantProfit:   110 - 100 - 0.1 - 0.11,
+			wantPosition: 1,
+		},
+		{
+			name:         "long partial exit counts base asset fee as closed",
+			state:        openState("long", 2),
+			fill:         venueFill{Executed: 0.999, QuoteQty: 109.89, BaseFee: 0.001},
+			wantProfit:   109.89 - 100 - 0.1,
+			wantPosition: 1,
+		},
+		{
+			name:          "long full exit closes the cycle",
+			state:         openState("long", 2),
+			fill:          venueFill{Filled: true, Executed: 2, QuoteQty: 220, QuoteFee: 0.22},
+			wantProfit:    220 - 200 - 0.2 - 0.22,
+			wantCompleted: 1,
+		},
+		{
+			name:          "short full exit profits when buying back lower",
+			state:         openState("short", 2),
+			fill:          venueFill{Filled: true, Executed: 2, QuoteQty: 180, QuoteFee: 0.18},
+			wantProfit:    200 - 180 - 0.2 - 0.18,
+			wantCompleted: 1,
+		},
+		{
+			name:          "full exit sweeps residual below one step",
+			state:         openState("long", 2.0004),
+			fill:          venueFill{Filled: true, Executed: 2, QuoteQty: 220},
+			wantProfit:    220 - 200 - 0.2,
+			wantCompleted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := &dcaBot{label: "test", state: tt.state, now: started.Add(time.Hour)}
+			bot.applyExit(&tt.fill)
+			state := bot.state
+
+			if math.Abs(state.TotalProfit-tt.wantProfit) > 1e-9 {
+				t.Errorf("total profit = %v, want %v", state.TotalProfit, tt.wantProfit)
+			}
+			if math.Abs(state.Position-tt.wantPosition) > 1e-9 {
+				t.Errorf("position = %v, want %v", state.Position, tt.wantPosition)
+			}
+			if state.CompletedCycles != tt.wantCompleted {
+				t.Fatalf("completed cycles = %d, want %d", state.CompletedCycles, tt.wantCompleted)
+			}
+
+			if tt.wantCompleted == 0 {
+				if math.Abs(state.CycleProfit-tt.wantProfit) > 1e-9 {
+					t.Errorf("cycle profit = %v, want %v", state.CycleProfit, tt.wantProfit)
+				}
+				if math.Abs(state.EntryValue-100) > 1e-9 || math.Abs(state.Fees-0.1) > 1e-9 {
+					t.Errorf("remaining entry value/fees = %v/%v, want 100/0.1", state.EntryValue, state.Fees)
+				}
+				return
+			}
+
+			if state.EntryValue != 0 || state.Fees != 0 || state.CycleProfit != 0 || state.BasePrice != 0 || state.CycleStartedAt != nil {
+				t.Errorf("cycle state not reset: %+v", state)
+			}
+			if len(state.History) != 1 {
+				t.Fatalf("history length = %d, want 1", len(state.History))
+			}
+			cycle := state.History[0]
+			if math.Abs(cycle.Profit-tt.wantProfit) > 1e-9 || cycle.SafetyOrders != 1 || !cycle.StartedAt.Equal(started) {
+				t.Errorf("history cycle = %+v", cycle)
+			}
+			if math.Abs(cycle.ExitPrice-tt.fill.QuoteQty/tt.fill.Executed) > 1e-9 {
+				t.Errorf("exit price = %v, want %v", cycle.ExitPrice, tt.fill.QuoteQty/tt.fill.Executed)
+			}
+		})
+	}
+}
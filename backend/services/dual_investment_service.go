@@ -167,48 +167,48 @@ func (dis *DualInvestmentService) ExecuteDualInvestmentStrategy(strategy *models
 		return err
 	}
 
-	binanceService, err := NewBinanceService(apiKey, secretKey)
+	exchange, err := NewExchange(apiKey, secretKey)
 	if err != nil {
 		return err
 	}
 
 	switch strategy.InvestmentType {
 	case "single":
-		return dis.executeSingleInvestment(strategy, binanceService)
+		return dis.executeSingleInvestment(strategy, exchange)
 	case "auto_reinvest":
-		return dis.executeAutoReinvestment(strategy, binanceService)
+		return dis.executeAutoReinvestment(strategy, exchange)
 	case "ladder":
-		return dis.executeLadderInvestment(strategy, binanceService)
+		return dis.executeLadderInvestment(strategy, exchange)
 	case "price_trigger":
-		return dis.executePriceTriggerInvestment(strategy, binanceService)
+		return dis.executePriceTriggerInvestment(strategy, exchange)
 	default:
 		return errors.New("不支持的投资类型")
 	}
 }
 
-func (dis *DualInvestmentService) executeSingleInvestment(strategy *models.DualInvestmentStrategy, binanceService *BinanceService) error {
+func (dis *DualInvestmentService) executeSingleInvestment(strategy *models.DualInvestmentStrategy, exchange Exchange) error {
 	var existingOrder models.DualInvestmentOrder
 	if err := dis.db.Where("strategy_id = ? AND status NOT IN ?", strategy.ID, []string{"SETTLED", "FAILED"}).First(&existingOrder).Error; err == nil {
 		return nil
 	}
 
-	return dis.createDualInvestmentOrder(strategy, strategy.Amount, binanceService)
+	return dis.createDualInvestmentOrder(strategy, strategy.Amount, exchange)
 }
 
-func (dis *DualInvestmentService) executeAutoReinvestment(strategy *models.DualInvestmentStrategy, binanceService *BinanceService) error {
+func (dis *DualInvestmentService) executeAutoReinvestment(strategy *models.DualInvestmentStrategy, exchange Exchange) error {
 	var lastOrder models.DualInvestmentOrder
 	if err := dis.db.Where("strategy_id = ?", strategy.ID).Order("created_at desc").First(&lastOrder).Error; err != nil {
-		return dis.createDualInvestmentOrder(strategy, strategy.Amount, binanceService)
+		return dis.createDualInvestmentOrder(strategy, strategy.Amount, exchange)
 	}
 
 	if lastOrder.Status == "SETTLED" {
-		return dis.createDualInvestmentOrder(strategy, strategy.Amount, binanceService)
+		return dis.createDualInvestmentOrder(strategy, strategy.Amount, exchange)
 	}
 
 	return nil
 }
 
-func (dis *DualInvestmentService) executeLadderInvestment(strategy *models.DualInvestmentStrategy, binanceService *BinanceService) error {
+func (dis *DualInvestmentService) executeLadderInvestment(strategy *models.DualInvestmentStrategy, exchange Exchange) error {
 	var completedSteps int64
 	dis.db.Model(&models.DualInvestmentOrder{}).Where("strategy_id = ? AND status = ?", strategy.ID, "PURCHASED").Count(&completedSteps)
 
@@ -216,16 +216,16 @@ func (dis *DualInvestmentService) executeLadderInvestment(strategy *models.DualI
 		return nil
 	}
 
-	return dis.createDualInvestmentOrder(strategy, strategy.AmountPerStep, binanceService)
+	return dis.createDualInvestmentOrder(strategy, strategy.AmountPerStep, exchange)
 }
 
-func (dis *DualInvestmentService) executePriceTriggerInvestment(strategy *models.DualInvestmentStrategy, binanceService *BinanceService) error {
+func (dis *DualInvestmentService) executePriceTriggerInvestment(strategy *models.DualInvestmentStrategy, exchange Exchange) error {
 	if strategy.TriggerPrice <= 0 {
 		return errors.New("价格触发投资需要设置触发价格")
 	}
 
 	symbol := strategy.BaseAsset + strategy.QuoteAsset
-	currentPrice, err := binanceService.GetPrice(context.Background(), symbol)
+	currentPrice, err := exchange.GetPrice(context.Background(), symbol)
 	if err != nil {
 		return err
 	}
@@ -239,10 +239,10 @@ func (dis *DualInvestmentService) executePriceTriggerInvestment(strategy *models
 		return nil
 	}
 
-	return dis.createDualInvestmentOrder(strategy, strategy.Amount, binanceService)
+	return dis.createDualInvestmentOrder(strategy, strategy.Amount, exchange)
 }
 
-func (dis *DualInvestmentService) createDualInvestmentOrder(strategy *models.DualInvestmentStrategy, amount float64, binanceService *BinanceService) error {
+func (dis *DualInvestmentService) createDualInvestmentOrder(strategy *models.DualInvestmentStrategy, amount float64, exchange Exchange) error {
 	var product models.DualInvestmentProduct
 	if err := dis.db.Where("product_id = ?", strategy.ProductID).First(&product).Error; err != nil {
 		return err
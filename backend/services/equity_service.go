@@ -0,0 +1,369 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+// equityWindows 权益曲线可选的统计窗口，all表示全部快照
+var equityWindows = map[string]time.Duration{
+	"1d":   24 * time.Hour,
+	"7d":   7 * 24 * time.Hour,
+	"30d":  30 * 24 * time.Hour,
+	"90d":  90 * 24 * time.Hour,
+	"180d": 180 * 24 * time.Hour,
+	"1y":   365 * 24 * time.Hour,
+	"all":  0,
+}
+
+// DefaultEquityWindow 未指定窗口时的默认统计窗口
+const DefaultEquityWindow = "30d"
+
+// ValidateEquityWindow 校验统计窗口，为空时返回默认窗口
+func ValidateEquityWindow(window string) (string, error) {
+	if window == "" {
+		return DefaultEquityWindow, nil
+	}
+	window = strings.ToLower(window)
+	if _, ok := equityWindows[window]; !ok {
+		keys := make([]string, 0, len(equityWindows))
+		for key := range equityWindows {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return "", fmt.Errorf("不支持的统计窗口: %s，可选值: %s", window, strings.Join(keys, ", "))
+	}
+	return window, nil
+}
+
+// EquityPoint 权益曲线上的一个点
+type EquityPoint struct {
+	Time     time.Time `json:"time"`
+	Equity   float64   `json:"equity"`
+	Drawdown float64   `json:"drawdown"` // 距窗口内历史最高权益的回撤百分比
+}
+
+// EquityPerformance 窗口内的权益表现，百分比指标均为百分数，年化按快照平均间隔折算
+type EquityPerformance struct {
+	Window          string        `json:"window"`
+	IsSimulated     bool          `json:"is_simulated"`
+	Snapshots       int           `json:"snapshots"`
+	StartTime       *time.Time    `json:"start_time,omitempty"`
+	EndTime         *time.Time    `json:"end_time,omitempty"`
+	StartEquity     float64       `json:"start_equity"`
+	EndEquity       float64       `json:"end_equity"`
+	PeakEquity      float64       `json:"peak_equity"`
+	TotalReturn     float64       `json:"total_return"`
+	MaxDrawdown     float64       `json:"max_drawdown"`
+	CurrentDrawdown float64       `json:"current_drawdown"`
+	Volatility      float64       `json:"volatility"`   // 年化波动率
+	SharpeRatio     float64       `json:"sharpe_ratio"` // 年化夏普比率，无风险利率按0计
+	Curve           []EquityPoint `json:"curve,omitempty"`
+}
+
+// EquityService 定期记录账户权益快照，并根据快照计算权益曲线和风险收益指标。
+// 快照不区分出入金，窗口内有充提时收益率和回撤会包含资金变动
+type EquityService struct {
+	db          *gorm.DB
+	userService *UserService
+}
+
+func NewEquityService() *EquityService {
+	return &EquityService{
+		db:          config.DB,
+		userService: NewUserService(),
+	}
+}
+
+// equityValuer 把资产数量折算为USDT，同一次快照内缓存价格
+type equityValuer struct {
+	ctx      context.Context
+	exchange Exchange
+	prices   map[string]float64
+}
+
+func (ev *equityValuer) value(asset string, amount float64) (float64, bool) {
+	asset = strings.ToUpper(asset)
+	if asset == PnLValuationAsset {
+		return amount, true
+	}
+
+	price, cached := ev.prices[asset]
+	if !cached {
+		price = ev.lookup(asset)
+		// 理财资产（如LDBTC）按标的资产估值
+		if price <= 0 && strings.HasPrefix(asset, "LD") && len(asset) > 2 {
+			price = ev.lookup(asset[2:])
+		}
+		ev.prices[asset] = price
+	}
+	if price <= 0 {
+		return 0, false
+	}
+	return amount * price, true
+}
+
+func (ev *equityValuer) lookup(asset string) float64 {
+	if asset == PnLValuationAsset {
+		return 1
+	}
+	if price, err := ev.exchange.GetPrice(ev.ctx, asset+PnLValuationAsset); err == nil && price > 0 {
+		return price
+	}
+	if stableValuationAssets[asset] {
+		return 1
+	}
+	return 0
+}
+
+// TakeSnapshot 记录用户的权益快照：现货余额、期货钱包余额和未实现盈亏、未结算的双币投资本金。
+// 模拟模式读取模拟账户，不包含双币投资
+func (es *EquityService) TakeSnapshot(userID uint, simulated bool) (*models.EquitySnapshot, error) {
+	snapshot, err := es.MeasureEquity(userID, simulated)
+	if err != nil {
+		return nil, err
+	}
+	if err := es.db.Create(snapshot).Error; err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// MeasureEquity 计算用户当前的权益，不保存快照
+func (es *EquityService) MeasureEquity(userID uint, simulated bool) (*models.EquitySnapshot, error) {
+	if es.db == nil {
+		return nil, errors.New("数据库未连接")
+	}
+
+	var exchange Exchange
+	if simulated {
+		exchange = es.userService.GetPaperExchange(userID)
+	} else {
+		var err error
+		exchange, err = es.userService.GetExchange(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	valuer := &equityValuer{ctx: ctx, exchange: exchange, prices: make(map[string]float64)}
+	snapshot := &models.EquitySnapshot{
+		UserID:       userID,
+		IsSimulated:  simulated,
+		SnapshotTime: time.Now(),
+	}
+	var unpriced []string
+
+	spot, err := exchange.GetAccountInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取现货账户失败: %w", err)
+	}
+	for _, balance := range spot.Balances {
+		amount := balance.Free + balance.Locked
+		if amount <= 0 {
+			continue
+		}
+		value, ok := valuer.value(balance.Asset, amount)
+		if !ok {
+			unpriced = appendUnique(unpriced, balance.Asset)
+			continue
+		}
+		snapshot.SpotEquity += value
+	}
+
+	// 未开通期货的账户查询会失败，按0计入
+	if futuresAccount, err := exchange.GetFuturesAccountInfo(ctx); err != nil {
+		log.Printf("获取用户%d期货账户失败，期货权益按0计入: %v", userID, err)
+	} else {
+		for _, asset := range futuresAccount.Assets {
+			if asset.WalletBalance == 0 && asset.UnrealizedProfit == 0 {
+				continue
+			}
+			wallet, ok := valuer.value(asset.Asset, asset.WalletBalance)
+			if !ok {
+				unpriced = appendUnique(unpriced, asset.Asset)
+				continue
+			}
+			unrealized, _ := valuer.value(asset.Asset, asset.UnrealizedProfit)
+			snapshot.FuturesWalletBalance += wallet
+			snapshot.FuturesUnrealizedPnL += unrealized
+		}
+	}
+
+	if !simulated {
+		var orders []models.DualInvestmentOrder
+		if err := es.db.Where("user_id = ? AND status <> ?", userID, "SETTLED").Find(&orders).Error; err != nil {
+			return nil, err
+		}
+		for _, order := range orders {
+			value, ok := valuer.value(order.Currency, order.Amount)
+			if !ok {
+				unpriced = appendUnique(unpriced, order.Currency)
+				continue
+			}
+			snapshot.DualInvestmentPrincipal += value
+		}
+	}
+
+	snapshot.TotalEquity = snapshot.SpotEquity + snapshot.FuturesWalletBalance + snapshot.FuturesUnrealizedPnL + snapshot.DualInvestmentPrincipal
+	snapshot.UnpricedAssets = strings.Join(unpriced, ",")
+	if len(snapshot.UnpricedAssets) > 255 {
+		snapshot.UnpricedAssets = snapshot.UnpricedAssets[:255]
+	}
+	return snapshot, nil
+}
+
+// SnapshotAllUsers 为配置了API密钥的用户记录实盘快照，为开启模拟交易的用户记录模拟账户快照，返回成功记录的快照数
+func (es *EquityService) SnapshotAllUsers() (int, error) {
+	if es.db == nil {
+		return 0, errors.New("数据库未连接")
+	}
+
+	var liveUsers []uint
+	if err := es.db.Model(&models.User{}).
+		Where("api_key != '' AND secret_key != '' AND status = ?", models.StatusActive).
+		Pluck("id", &liveUsers).Error; err != nil {
+		return 0, err
+	}
+	var paperUsers []uint
+	if err := es.db.Model(&models.User{}).
+		Where("paper_trading = ? AND status = ?", true, models.StatusActive).
+		Pluck("id", &paperUsers).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, userID := range liveUsers {
+		if _, err := es.TakeSnapshot(userID, false); err != nil {
+			log.Printf("记录用户%d权益快照失败: %v", userID, err)
+			continue
+		}
+		count++
+	}
+	for _, userID := range paperUsers {
+		if _, err := es.TakeSnapshot(userID, true); err != nil {
+			log.Printf("记录用户%d模拟账户权益快照失败: %v", userID, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// GetSnapshots 获取窗口内的权益快照，按时间升序
+func (es *EquityService) GetSnapshots(userID uint, simulated bool, window string) ([]models.EquitySnapshot, error) {
+	window, err := ValidateEquityWindow(window)
+	if err != nil {
+		return nil, err
+	}
+
+	query := es.db.Where("user_id = ? AND is_simulated = ?", userID, simulated)
+	if duration := equityWindows[window]; duration > 0 {
+		query = query.Where("snapshot_time >= ?", time.Now().Add(-duration))
+	}
+
+	var snapshots []models.EquitySnapshot
+	if err := query.Order("snapshot_time").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// GetPerformance 计算窗口内的收益率、回撤、波动率和夏普比率，withCurve为true时附带权益曲线
+func (es *EquityService) GetPerformance(userID uint, simulated bool, window string, withCurve bool) (*EquityPerformance, error) {
+	window, err := ValidateEquityWindow(window)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := es.GetSnapshots(userID, simulated, window)
+	if err != nil {
+		return nil, err
+	}
+
+	performance := calculateEquityPerformance(snapshots, withCurve)
+	performance.Window = window
+	performance.IsSimulated = simulated
+	return performance, nil
+}
+
+func calculateEquityPerformance(snapshots []models.EquitySnapshot, withCurve bool) *EquityPerformance {
+	performance := &EquityPerformance{Snapshots: len(snapshots)}
+	if len(snapshots) == 0 {
+		return performance
+	}
+
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	performance.StartTime = &first.SnapshotTime
+	performance.EndTime = &last.SnapshotTime
+	performance.StartEquity = first.TotalEquity
+	performance.EndEquity = last.TotalEquity
+	performance.TotalReturn = utils.CalculatePercentChange(first.TotalEquity, last.TotalEquity)
+
+	equity := make([]float64, len(snapshots))
+	var peak float64
+	for i, snapshot := range snapshots {
+		equity[i] = snapshot.TotalEquity
+		peak = math.Max(peak, snapshot.TotalEquity)
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = (peak - snapshot.TotalEquity) / peak * 100
+		}
+		if withCurve {
+			performance.Curve = append(performance.Curve, EquityPoint{
+				Time:     snapshot.SnapshotTime,
+				Equity:   snapshot.TotalEquity,
+				Drawdown: drawdown,
+			})
+		}
+		performance.CurrentDrawdown = drawdown
+	}
+	performance.PeakEquity = peak
+	performance.MaxDrawdown = maxDrawdownPercent(equity)
+
+	// 逐快照收益率，年化倍数取一年内的平均快照间隔数
+	var returns []float64
+	for i := 1; i < len(equity); i++ {
+		if equity[i-1] > 0 {
+			returns = append(returns, equity[i]/equity[i-1]-1)
+		}
+	}
+	if len(returns) < 2 {
+		return performance
+	}
+	interval := last.SnapshotTime.Sub(first.SnapshotTime) / time.Duration(len(snapshots)-1)
+	if interval <= 0 {
+		return performance
+	}
+	periodsPerYear := float64(365*24*time.Hour) / float64(interval)
+
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(returns)-1))
+
+	performance.Volatility = stddev * math.Sqrt(periodsPerYear) * 100
+	if stddev > 0 {
+		performance.SharpeRatio = mean / stddev * math.Sqrt(periodsPerYear)
+	}
+	return performance
+}
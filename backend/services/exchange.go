@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+)
+
+// Exchange 交易所抽象接口，策略、调度器和控制器只依赖该接口
+type Exchange interface {
+	// Name 交易所名称
+	Name() string
+
+	// 行情
+	GetPrice(ctx context.Context, symbol string) (float64, error)
+	GetFuturesPrice(ctx context.Context, symbol string) (float64, error)
+	GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error)
+	GetFuturesOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error)
+	GetKlines(symbol string, interval string, limit int) ([]KlineData, error)
+	GetFuturesKlines(symbol string, interval string, limit int) ([]KlineData, error)
+	Get24hrTicker(symbol string) (*TickerData, error)
+	GetTopSymbols(limit int) ([]string, error)
+	GetTradingSymbols(ctx context.Context) ([]SymbolInfo, error)
+	GetFuturesTradingSymbols(ctx context.Context) ([]SymbolInfo, error)
+	GetSymbolInfo(symbol string) (*SymbolInfo, error)
+	GetFuturesSymbolInfo(symbol string) (*SymbolInfo, error)
+	GetFundingRates(ctx context.Context) ([]FundingRateInfo, error)
+
+	// 订单
+	CreateSpotOrder(ctx context.Context, order *models.Order) (*OrderResult, error)
+	CancelSpotOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error)
+	GetSpotOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error)
+	CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*OrderResult, error)
+	CancelFuturesOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error)
+	GetFuturesOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error)
+	GetSpotOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error)
+	GetFuturesOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error)
+	CreateSpotOCO(ctx context.Context, req *OCORequest) (*OrderListResult, error)
+	CancelSpotOCO(ctx context.Context, symbol, orderListID string) (*OrderListResult, error)
+
+	// 持仓
+	GetFuturesPositions(ctx context.Context) ([]*PositionInfo, error)
+	SetFuturesLeverage(ctx context.Context, symbol string, leverage int) error
+	SetFuturesMarginType(ctx context.Context, symbol string, marginType models.MarginType) error
+	GetFuturesPositionMode(ctx context.Context) (bool, error)
+	SetFuturesPositionMode(ctx context.Context, dualSide bool) error
+	ModifyFuturesIsolatedMargin(ctx context.Context, symbol string, positionSide models.PositionSide, amount float64) error
+
+	// 资产
+	GetAccountInfo(ctx context.Context) (*SpotAccount, error)
+	GetFuturesAccountInfo(ctx context.Context) (*FuturesAccount, error)
+	TransferToFutures(ctx context.Context, asset string, amount float64) error
+	GetFuturesIncomeHistory(ctx context.Context, incomeType string, startTime time.Time, limit int) ([]FuturesIncomeRecord, error)
+
+	// 提现
+	Withdraw(ctx context.Context, asset, address, network string, amount float64, addressTag string) (string, error)
+	GetWithdrawHistory(ctx context.Context, asset string, limit int) ([]*WithdrawRecord, error)
+
+	// 账户
+	ValidateAPICredentials(ctx context.Context) error
+	DiagnoseAPIConnection(ctx context.Context) (map[string]interface{}, error)
+	Close() error
+}
+
+// OrderResult 交易所返回的订单信息（现货与期货通用）
+type OrderResult struct {
+	OrderID            string      `json:"order_id"`
+	ClientOrderID      string      `json:"client_order_id"`
+	Symbol             string      `json:"symbol"`
+	Side               string      `json:"side"`
+	Type               string      `json:"type"`
+	PositionSide       string      `json:"position_side,omitempty"`
+	Status             string      `json:"status"`
+	Price              float64     `json:"price"`
+	StopPrice          float64     `json:"stop_price"`
+	AvgPrice           float64     `json:"avg_price"`
+	OrigQty            float64     `json:"orig_qty"`
+	ExecutedQty        float64     `json:"executed_qty"`
+	CumulativeQuoteQty float64     `json:"cumulative_quote_qty"`
+	ReduceOnly         bool        `json:"reduce_only,omitempty"`
+	Fills              []OrderFill `json:"fills,omitempty"`
+	UpdateTime         int64       `json:"update_time"`
+}
+
+// OCORequest 现货OCO委托：限价单与止损单共用数量，一方成交或触发后另一方自动撤销
+type OCORequest struct {
+	Symbol            string           `json:"symbol"`
+	Side              models.OrderSide `json:"side"`
+	Quantity          float64          `json:"quantity"`
+	Price             float64          `json:"price"`            // 限价单价格
+	StopPrice         float64          `json:"stop_price"`       // 止损单触发价
+	StopLimitPrice    float64          `json:"stop_limit_price"` // 止损单限价，为0时止损单触发后按市价成交
+	ListClientOrderID string           `json:"list_client_order_id"`
+}
+
+// OrderListResult 交易所返回的订单列表信息
+type OrderListResult struct {
+	OrderListID       string         `json:"order_list_id"`
+	ListClientOrderID string         `json:"list_client_order_id"`
+	Symbol            string         `json:"symbol"`
+	ContingencyType   string         `json:"contingency_type"`
+	ListStatusType    string         `json:"list_status_type"`
+	ListOrderStatus   string         `json:"list_order_status"`
+	Orders            []*OrderResult `json:"orders"`
+	TransactionTime   int64          `json:"transaction_time"`
+}
+
+// OrderFill 订单成交明细
+type OrderFill struct {
+	TradeID         int64   `json:"trade_id"`
+	Price           float64 `json:"price"`
+	Quantity        float64 `json:"quantity"`
+	QuoteQty        float64 `json:"quote_qty"`
+	Commission      float64 `json:"commission"`
+	CommissionAsset string  `json:"commission_asset"`
+	IsBuyer         bool    `json:"is_buyer"`
+	IsMaker         bool    `json:"is_maker"`
+	RealizedPnL     float64 `json:"realized_pnl"` // 仅期货成交
+	Time            int64   `json:"time"`
+}
+
+// PriceLevel 订单簿档位
+type PriceLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook 订单簿
+type OrderBook struct {
+	Symbol       string       `json:"symbol"`
+	LastUpdateID int64        `json:"last_update_id"`
+	Bids         []PriceLevel `json:"bids"`
+	Asks         []PriceLevel `json:"asks"`
+}
+
+// PositionInfo 期货持仓信息
+type PositionInfo struct {
+	Symbol           string  `json:"symbol"`
+	PositionSide     string  `json:"position_side"`
+	PositionAmt      float64 `json:"position_amt"`
+	EntryPrice       float64 `json:"entry_price"`
+	MarkPrice        float64 `json:"mark_price"`
+	UnrealizedProfit float64 `json:"unrealized_profit"`
+	LiquidationPrice float64 `json:"liquidation_price"`
+	Leverage         int     `json:"leverage"`
+	MaxNotionalValue float64 `json:"max_notional_value"`
+	MarginType       string  `json:"margin_type"`
+	IsolatedMargin   float64 `json:"isolated_margin"`
+	IsAutoAddMargin  bool    `json:"is_auto_add_margin"`
+}
+
+// FundingRateInfo 永续合约的标记价格、指数价格和资金费率（premiumIndex）
+type FundingRateInfo struct {
+	Symbol          string    `json:"symbol"`
+	MarkPrice       float64   `json:"mark_price"`
+	IndexPrice      float64   `json:"index_price"`
+	LastFundingRate float64   `json:"last_funding_rate"` // 本期资金费率，在NextFundingTime结算
+	InterestRate    float64   `json:"interest_rate"`
+	NextFundingTime time.Time `json:"next_funding_time"`
+	Time            time.Time `json:"time"`
+}
+
+// FuturesIncomeRecord 期货账户资金流水
+type FuturesIncomeRecord struct {
+	TranID     int64     `json:"tran_id"`
+	Symbol     string    `json:"symbol"`
+	IncomeType string    `json:"income_type"` // FUNDING_FEE、COMMISSION、REALIZED_PNL等
+	Income     float64   `json:"income"`      // 正数为收入，负数为支出
+	Asset      string    `json:"asset"`
+	Info       string    `json:"info"`
+	TradeID    string    `json:"trade_id"`
+	Time       time.Time `json:"time"`
+}
+
+// AssetBalance 现货资产余额
+type AssetBalance struct {
+	Asset  string  `json:"asset"`
+	Free   float64 `json:"free"`
+	Locked float64 `json:"locked"`
+}
+
+// SpotAccount 现货账户
+type SpotAccount struct {
+	CanTrade    bool           `json:"can_trade"`
+	CanWithdraw bool           `json:"can_withdraw"`
+	CanDeposit  bool           `json:"can_deposit"`
+	Balances    []AssetBalance `json:"balances"`
+	UpdateTime  int64          `json:"update_time"`
+}
+
+// FuturesAssetBalance 期货账户资产
+type FuturesAssetBalance struct {
+	Asset            string  `json:"asset"`
+	WalletBalance    float64 `json:"wallet_balance"`
+	UnrealizedProfit float64 `json:"unrealized_profit"`
+	MarginBalance    float64 `json:"margin_balance"`
+	AvailableBalance float64 `json:"available_balance"`
+}
+
+// FuturesAccount 期货账户
+type FuturesAccount struct {
+	TotalWalletBalance    float64               `json:"total_wallet_balance"`
+	TotalUnrealizedProfit float64               `json:"total_unrealized_profit"`
+	TotalMarginBalance    float64               `json:"total_margin_balance"`
+	TotalMaintMargin      float64               `json:"total_maint_margin"`
+	AvailableBalance      float64               `json:"available_balance"`
+	MaxWithdrawAmount     float64               `json:"max_withdraw_amount"`
+	Assets                []FuturesAssetBalance `json:"assets"`
+	UpdateTime            int64                 `json:"update_time"`
+}
+
+// WithdrawRecord 提现记录
+type WithdrawRecord struct {
+	ID           string  `json:"id"`
+	TxID         string  `json:"tx_id"`
+	Asset        string  `json:"asset"`
+	Network      string  `json:"network"`
+	Address      string  `json:"address"`
+	Amount       float64 `json:"amount"`
+	Fee          float64 `json:"fee"`
+	Status       int     `json:"status"`
+	ApplyTime    string  `json:"apply_time"`
+	CompleteTime string  `json:"complete_time"`
+}
+
+// ExchangeFactory 根据API凭证创建交易所实例
+type ExchangeFactory func(apiKey, secretKey string) (Exchange, error)
+
+// PublicExchangeFactory 创建无需凭证、仅提供公开行情的交易所实例，用于价格更新、模拟交易行情和回测K线
+type PublicExchangeFactory func() (Exchange, error)
+
+var (
+	exchangeFactories = map[string]ExchangeFactory{
+		"binance": func(apiKey, secretKey string) (Exchange, error) {
+			return NewBinanceService(apiKey, secretKey)
+		},
+	}
+	exchangeFactoriesMu sync.RWMutex
+
+	// publicExchangeFactories 无需凭证、仅提供公开行情的交易所实例
+	publicExchangeFactories = map[string]PublicExchangeFactory{
+		"binance": func() (Exchange, error) {
+			return NewPublicBinanceService()
+		},
+	}
+)
+
+// RegisterExchange 注册交易所实现，可用于接入其他交易所或测试替身。
+// 作为默认交易所时还需通过RegisterPublicExchange注册公开行情，行情推送可选通过RegisterMarketStream注册
+func RegisterExchange(name string, factory ExchangeFactory) {
+	exchangeFactoriesMu.Lock()
+	defer exchangeFactoriesMu.Unlock()
+	exchangeFactories[strings.ToLower(name)] = factory
+}
+
+// RegisterPublicExchange 注册交易所的公开行情实现
+func RegisterPublicExchange(name string, factory PublicExchangeFactory) {
+	exchangeFactoriesMu.Lock()
+	defer exchangeFactoriesMu.Unlock()
+	publicExchangeFactories[strings.ToLower(name)] = factory
+}
+
+// NewExchange 创建默认交易所实例
+func NewExchange(apiKey, secretKey string) (Exchange, error) {
+	name := "binance"
+	if config.AppConfig != nil && config.AppConfig.Exchange.Default != "" {
+		name = config.AppConfig.Exchange.Default
+	}
+	return NewExchangeByName(name, apiKey, secretKey)
+}
+
+// NewExchangeByName 按名称创建交易所实例
+func NewExchangeByName(name, apiKey, secretKey string) (Exchange, error) {
+	exchangeFactoriesMu.RLock()
+	factory, ok := exchangeFactories[strings.ToLower(name)]
+	exchangeFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("不支持的交易所: %s", name)
+	}
+	return factory(apiKey, secretKey)
+}
+
+// NewPublicExchange 创建仅提供公开行情的默认交易所实例
+func NewPublicExchange() (Exchange, error) {
+	name := "binance"
+	if config.AppConfig != nil && config.AppConfig.Exchange.Default != "" {
+		name = config.AppConfig.Exchange.Default
+	}
+
+	exchangeFactoriesMu.RLock()
+	factory, ok := publicExchangeFactories[strings.ToLower(name)]
+	exchangeFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("交易所%s不支持公开行情", name)
+	}
+	return factory()
+}
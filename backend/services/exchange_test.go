@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ccj241/cctrade/config"
+)
+
+// stubExchange 只用于区分工厂返回的实例
+type stubExchange struct {
+	Exchange
+	name string
+}
+
+func TestRegisterExchangeFactories(t *testing.T) {
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+	config.AppConfig = &config.Config{Exchange: config.ExchangeConfig{Default: "StubVenue"}}
+
+	if _, err := NewPublicExchange(); err == nil {
+		t.Fatal("NewPublicExchange() succeeded before the venue registered public data")
+	}
+	if hub := NewMarketDataHub(nil); hub.factory != nil {
+		t.Fatal("market data hub found a stream factory before the venue registered one")
+	}
+
+	RegisterExchange("stubvenue", func(apiKey, secretKey string) (Exchange, error) {
+		return &stubExchange{name: "private:" + apiKey}, nil
+	})
+	RegisterPublicExchange("STUBVENUE", func() (Exchange, error) {
+		return &stubExchange{name: "public"}, nil
+	})
+	RegisterMarketStream("StubVenue", func(market MarketType, symbols []string, opts MarketStreamOptions, handler MarketEventHandler, errHandler func(error)) (<-chan struct{}, func(), error) {
+		return make(chan struct{}), func() {}, nil
+	})
+	t.Cleanup(func() {
+		exchangeFactoriesMu.Lock()
+		delete(exchangeFactories, "stubvenue")
+		delete(publicExchangeFactories, "stubvenue")
+		exchangeFactoriesMu.Unlock()
+		marketStreamFactoriesMu.Lock()
+		delete(marketStreamFactories, "stubvenue")
+		marketStreamFactoriesMu.Unlock()
+	})
+
+	exchange, err := NewExchange("key", "secret")
+	if err != nil || exchange.(*stubExchange).name != "private:key" {
+		t.Errorf("NewExchange() = %v, %v", exchange, err)
+	}
+	public, err := NewPublicExchange()
+	if err != nil || public.(*stubExchange).name != "public" {
+		t.Errorf("NewPublicExchange() = %v, %v", public, err)
+	}
+	if hub := NewMarketDataHub(nil); hub.factory == nil {
+		t.Error("market data hub did not pick up the registered stream factory")
+	}
+}
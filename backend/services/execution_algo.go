@@ -0,0 +1,467 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// executionStateKey TWAP/VWAP执行状态在State中的键
+const executionStateKey = "execution"
+
+const (
+	executionDefaultSliceInterval = 60 * time.Second
+	executionDefaultProfileDays   = 7
+	// executionVolumeInterval 参与率按该周期K线的成交量计算
+	executionVolumeInterval = "1m"
+)
+
+// ExecutionState TWAP/VWAP执行进度。开始后计划固定，修改配置不影响正在执行的计划；
+// 滑点以到达价（开始执行时的价格）为基准，单位为基点，正数表示成交均价比到达价差
+type ExecutionState struct {
+	Algo             string     `json:"algo"`
+	Side             string     `json:"side"`
+	TotalQuantity    float64    `json:"total_quantity"`
+	StartTime        time.Time  `json:"start_time"`
+	EndTime          time.Time  `json:"end_time"`
+	ArrivalPrice     float64    `json:"arrival_price"`
+	FilledQuantity   float64    `json:"filled_quantity"`
+	FilledValue      float64    `json:"filled_value"` // 成交额（计价资产）
+	Fees             float64    `json:"fees"`         // 以计价资产支付的手续费
+	AveragePrice     float64    `json:"average_price"`
+	SlippageBps      float64    `json:"slippage_bps"`
+	Progress         float64    `json:"progress"`          // 已成交占总数量的百分比
+	ScheduleProgress float64    `json:"schedule_progress"` // 计划应完成的百分比
+	ChildOrders      int        `json:"child_orders"`
+	PendingOrderID   string     `json:"pending_order_id,omitempty"`
+	LastChildAt      *time.Time `json:"last_child_at,omitempty"`
+	LimitSkips       int        `json:"limit_skips"`       // 因价格超出限价跳过的轮次
+	Profile          []float64  `json:"profile,omitempty"` // VWAP按UTC小时统计的成交量占比
+	FinalSweep       bool       `json:"final_sweep"`       // 结束时间后是否已尝试补齐剩余数量
+	Finished         bool       `json:"finished"`
+	FinishReason     string     `json:"finish_reason,omitempty"`
+}
+
+// executionConfig TWAP/VWAP配置
+type executionConfig struct {
+	algo             models.StrategyType
+	totalQuantity    float64 // 为0时取策略默认下单数量
+	startTime        time.Time
+	endTime          time.Time
+	duration         time.Duration
+	sliceInterval    time.Duration
+	participationCap float64 // 子单数量占同期市场成交量的最大百分比，0表示不限制
+	priceLimit       float64 // 买入不高于、卖出不低于该价格，0表示不限制
+	profileDays      int
+	reduceOnly       bool
+}
+
+// parseConfigTime 解析RFC3339字符串或Unix秒时间戳，未设置时返回零值
+func parseConfigTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case nil:
+		return time.Time{}, nil
+	case string:
+		if v == "" {
+			return time.Time{}, nil
+		}
+		return time.Parse(time.RFC3339, v)
+	case float64:
+		if v <= 0 {
+			return time.Time{}, nil
+		}
+		return time.Unix(int64(v), 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("无法解析时间: %v", value)
+	}
+}
+
+func parseExecutionConfig(algo models.StrategyType, config map[string]interface{}) (*executionConfig, error) {
+	cfg := &executionConfig{algo: algo, sliceInterval: executionDefaultSliceInterval, profileDays: executionDefaultProfileDays}
+
+	cfg.totalQuantity, _ = config["total_quantity"].(float64)
+	if cfg.totalQuantity < 0 {
+		return nil, errors.New("总数量不能为负数")
+	}
+
+	var err error
+	if cfg.startTime, err = parseConfigTime(config["start_time"]); err != nil {
+		return nil, fmt.Errorf("开始时间无效: %w", err)
+	}
+	if cfg.endTime, err = parseConfigTime(config["end_time"]); err != nil {
+		return nil, fmt.Errorf("结束时间无效: %w", err)
+	}
+	if minutes, _ := config["duration"].(float64); minutes > 0 {
+		cfg.duration = time.Duration(minutes * float64(time.Minute))
+	}
+	if cfg.endTime.IsZero() && cfg.duration <= 0 {
+		return nil, errors.New("需要设置结束时间或执行时长（分钟）")
+	}
+	if !cfg.endTime.IsZero() && !cfg.startTime.IsZero() && !cfg.endTime.After(cfg.startTime) {
+		return nil, errors.New("结束时间必须晚于开始时间")
+	}
+
+	if seconds, exists := config["slice_interval"]; exists {
+		value, ok := seconds.(float64)
+		if !ok || value <= 0 {
+			return nil, errors.New("子单间隔必须大于0秒")
+		}
+		cfg.sliceInterval = time.Duration(value * float64(time.Second))
+	}
+
+	cfg.participationCap, _ = config["participation_cap"].(float64)
+	if cfg.participationCap < 0 || cfg.participationCap > 100 {
+		return nil, errors.New("参与率上限必须在0-100之间")
+	}
+
+	cfg.priceLimit, _ = config["price_limit"].(float64)
+	if cfg.priceLimit < 0 {
+		return nil, errors.New("限价不能为负数")
+	}
+
+	if days, exists := config["profile_days"]; exists {
+		value, ok := days.(float64)
+		if !ok || value < 1 || value > 30 {
+			return nil, errors.New("成交量分布统计天数必须在1-30之间")
+		}
+		cfg.profileDays = int(value)
+	}
+
+	cfg.reduceOnly, _ = config["reduce_only"].(bool)
+	return cfg, nil
+}
+
+// newExecutionState 按配置生成执行计划，开始时间未设置时从now开始
+func newExecutionState(cfg *executionConfig, side models.OrderSide, now time.Time) *ExecutionState {
+	state := &ExecutionState{
+		Algo:          string(cfg.algo),
+		Side:          string(side),
+		TotalQuantity: cfg.totalQuantity,
+		StartTime:     cfg.startTime,
+		EndTime:       cfg.endTime,
+	}
+	if state.StartTime.IsZero() {
+		state.StartTime = now
+	}
+	if state.EndTime.IsZero() {
+		state.EndTime = state.StartTime.Add(cfg.duration)
+	}
+	return state
+}
+
+// buildVolumeProfile 用最近profileDays天的小时K线统计每个UTC小时的平均成交量占比
+func buildVolumeProfile(venue orderVenue, profileDays int) ([]float64, error) {
+	klines, err := venue.klines("1h", int(math.Min(float64(profileDays*24), 1000)))
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]float64, 24)
+	counts := make([]float64, 24)
+	for _, kline := range klines {
+		hour := time.UnixMilli(kline.OpenTime).UTC().Hour()
+		volumes[hour] += kline.Volume
+		counts[hour]++
+	}
+
+	var total float64
+	for hour := range volumes {
+		if counts[hour] > 0 {
+			volumes[hour] /= counts[hour]
+		}
+		total += volumes[hour]
+	}
+	if total <= 0 {
+		return nil, errors.New("K线成交量为0")
+	}
+	for hour := range volumes {
+		volumes[hour] /= total
+	}
+	return volumes, nil
+}
+
+// profileWeight 按小时成交量占比对[from, to)积分，小时内按时间线性分摊
+func profileWeight(profile []float64, from, to time.Time) float64 {
+	var weight float64
+	for cursor := from; cursor.Before(to); {
+		next := cursor.UTC().Truncate(time.Hour).Add(time.Hour)
+		if next.After(to) {
+			next = to
+		}
+		weight += profile[cursor.UTC().Hour()] * float64(next.Sub(cursor)) / float64(time.Hour)
+		cursor = next
+	}
+	return weight
+}
+
+// scheduledFraction 截至now计划应完成的比例：TWAP按时间均分，VWAP按成交量分布
+func (es *ExecutionState) scheduledFraction(now time.Time) float64 {
+	if !now.After(es.StartTime) {
+		return 0
+	}
+	if !now.Before(es.EndTime) {
+		return 1
+	}
+	if len(es.Profile) == 24 {
+		if total := profileWeight(es.Profile, es.StartTime, es.EndTime); total > 0 {
+			return profileWeight(es.Profile, es.StartTime, now) / total
+		}
+	}
+	return float64(now.Sub(es.StartTime)) / float64(es.EndTime.Sub(es.StartTime))
+}
+
+func (es *ExecutionState) finish(reason string) {
+	es.Finished = true
+	es.FinishReason = reason
+}
+
+// executionRunner 单次执行的TWAP/VWAP
+type executionRunner struct {
+	label string
+	cfg   *executionConfig
+	state *ExecutionState
+	venue orderVenue
+	now   time.Time
+}
+
+// run 推进一轮执行，返回执行是否已结束
+func (er *executionRunner) run() (bool, error) {
+	state := er.state
+	if state.Finished {
+		return true, nil
+	}
+
+	if err := er.settle(); err != nil || state.PendingOrderID != "" {
+		return false, err
+	}
+	if state.TotalQuantity > 0 && state.FilledQuantity >= state.TotalQuantity-executionDust(er.venue.symbolInfo()) {
+		state.finish("completed")
+		return true, nil
+	}
+	if er.now.Before(state.StartTime) {
+		return false, nil
+	}
+	if !er.now.Before(state.EndTime) && state.FinalSweep {
+		state.finish("expired")
+		log.Printf("%s执行到期，剩余%.8f未成交", er.label, state.TotalQuantity-state.FilledQuantity)
+		return true, nil
+	}
+
+	price, err := er.venue.price()
+	if err != nil {
+		return false, err
+	}
+	if state.ArrivalPrice <= 0 {
+		state.ArrivalPrice = price
+		if state.TotalQuantity <= 0 {
+			state.TotalQuantity = er.venue.defaultQuantity(price)
+		}
+		if state.Algo == string(models.StrategyVWAP) {
+			profile, err := buildVolumeProfile(er.venue, er.cfg.profileDays)
+			if err != nil {
+				log.Printf("%s构建成交量分布失败，按时间均分执行: %v", er.label, err)
+			}
+			state.Profile = profile
+		}
+	}
+
+	final := !er.now.Before(state.EndTime)
+	if state.LastChildAt != nil && !final && er.now.Sub(*state.LastChildAt) < er.cfg.sliceInterval {
+		return false, nil
+	}
+	if final {
+		state.FinalSweep = true
+	}
+
+	fraction := state.scheduledFraction(er.now)
+	state.ScheduleProgress = fraction * 100
+	due := state.TotalQuantity*fraction - state.FilledQuantity
+	if due <= 0 {
+		return false, nil
+	}
+
+	if limit := er.cfg.priceLimit; limit > 0 {
+		if (state.Side == string(models.OrderSideBuy) && price > limit) || (state.Side == string(models.OrderSideSell) && price < limit) {
+			state.LimitSkips++
+			return false, nil
+		}
+	}
+
+	if er.cfg.participationCap > 0 {
+		since := er.now.Add(-er.cfg.sliceInterval)
+		if state.LastChildAt != nil && state.LastChildAt.Before(since) {
+			since = *state.LastChildAt
+		}
+		volume, err := er.marketVolume(since)
+		if err != nil {
+			log.Printf("%s获取成交量失败，本轮不限制参与率: %v", er.label, err)
+		} else {
+			due = math.Min(due, volume*er.cfg.participationCap/100)
+		}
+	}
+
+	info := er.venue.symbolInfo()
+	_, quantity := roundGridOrder(info, 0, due)
+	if quantity <= 0 || (info != nil && (quantity < info.MinQty || quantity*price < info.MinNotional)) {
+		return false, nil
+	}
+
+	orderID, err := er.venue.placeOrder(models.OrderSide(state.Side), models.OrderTypeMarket, 0, quantity, er.cfg.reduceOnly)
+	if err != nil {
+		return false, err
+	}
+	state.PendingOrderID = orderID
+	state.LastChildAt = &er.now
+	state.ChildOrders++
+	return false, er.settle()
+}
+
+// settle 结算已结束的子单，更新成交均价、滑点和进度
+func (er *executionRunner) settle() error {
+	state := er.state
+	if state.PendingOrderID == "" {
+		return nil
+	}
+	fill, err := er.venue.orderFill(state.PendingOrderID)
+	if err != nil || fill == nil {
+		return err
+	}
+	state.PendingOrderID = ""
+
+	state.FilledQuantity += fill.Executed
+	state.FilledValue += fill.QuoteQty
+	state.Fees += fill.QuoteFee
+	if state.FilledQuantity > 0 {
+		state.AveragePrice = state.FilledValue / state.FilledQuantity
+	}
+	if state.ArrivalPrice > 0 && state.AveragePrice > 0 {
+		slippage := (state.AveragePrice - state.ArrivalPrice) / state.ArrivalPrice * 10000
+		if state.Side == string(models.OrderSideSell) {
+			slippage = -slippage
+		}
+		state.SlippageBps = slippage
+	}
+	if state.TotalQuantity > 0 {
+		state.Progress = math.Min(state.FilledQuantity/state.TotalQuantity*100, 100)
+	}
+	return nil
+}
+
+// marketVolume 统计since至今的成交量。与区间部分重叠的K线（含未收盘K线）按重叠时长折算，
+// 否则区间短于一根K线时统计结果始终为0
+func (er *executionRunner) marketVolume(since time.Time) (float64, error) {
+	minutes := int(er.now.Sub(since)/time.Minute) + 2
+	klines, err := er.venue.klines(executionVolumeInterval, int(math.Min(float64(minutes), 1000)))
+	if err != nil {
+		return 0, err
+	}
+	return prorateVolume(klines, since.UnixMilli(), er.now.UnixMilli()), nil
+}
+
+// prorateVolume 按K线与[from, to)的重叠时长折算成交量，未收盘K线的成交量按已经过的时长计
+func prorateVolume(klines []KlineData, from, to int64) float64 {
+	var volume float64
+	for _, kline := range klines {
+		start, end := kline.OpenTime, kline.CloseTime+1
+		if end > to {
+			end = to
+		}
+		span := end - start
+		if start < from {
+			start = from
+		}
+		overlap := end - start
+		if span <= 0 || overlap <= 0 {
+			continue
+		}
+		volume += kline.Volume * float64(overlap) / float64(span)
+	}
+	return volume
+}
+
+// executionDust 剩余数量低于一个步长时视为已完成
+func executionDust(info *SymbolInfo) float64 {
+	if info != nil && info.StepSize > 0 {
+		return info.StepSize
+	}
+	return 1e-12
+}
+
+// loadExecutionState 读取执行状态，不存在时返回nil
+func loadExecutionState(state models.StrategyState) *ExecutionState {
+	if state == nil || state[executionStateKey] == nil {
+		return nil
+	}
+	var execution ExecutionState
+	if err := decodeStateValue(state[executionStateKey], &execution); err != nil {
+		return nil
+	}
+	return &execution
+}
+
+func saveExecutionState(db *gorm.DB, model interface{}, strategyState models.StrategyState, execution *ExecutionState) error {
+	if strategyState == nil {
+		strategyState = make(models.StrategyState)
+	}
+	strategyState[executionStateKey] = encodeStateValue(execution)
+	strategyState["total_filled_quantity"] = execution.FilledQuantity
+	strategyState["progress"] = execution.Progress
+	strategyState["slippage_bps"] = execution.SlippageBps
+	return db.Model(model).Update("state", strategyState).Error
+}
+
+// runExecution 执行一轮TWAP/VWAP并保存状态
+func runExecution(db *gorm.DB, model interface{}, strategyState models.StrategyState, algo models.StrategyType, side models.OrderSide, config map[string]interface{}, venue orderVenue, label string, now time.Time) (bool, error) {
+	cfg, err := parseExecutionConfig(algo, config)
+	if err != nil {
+		return false, err
+	}
+
+	execution := loadExecutionState(strategyState)
+	if execution == nil {
+		execution = newExecutionState(cfg, side, now)
+	}
+	runner := &executionRunner{label: label, cfg: cfg, state: execution, venue: venue, now: now}
+	done, runErr := runner.run()
+	if err := saveExecutionState(db, model, strategyState, execution); err != nil {
+		return false, err
+	}
+	return done, runErr
+}
+
+// executeExecutionStrategy 现货TWAP/VWAP，总数量未设置时取strategy.Quantity
+func (ss *StrategyService) executeExecutionStrategy(strategy *models.Strategy, exchange Exchange) error {
+	label := fmt.Sprintf("%s策略%d", strings.ToUpper(string(strategy.Type)), strategy.ID)
+	done, err := runExecution(ss.db, strategy, strategy.State, strategy.Type, strategy.Side, strategy.Config,
+		newSpotOrderVenue(ss, strategy, exchange), label, ss.currentTime())
+	if done {
+		return ss.completeStrategy(strategy, "执行完成")
+	}
+	return err
+}
+
+// executeFuturesExecutionStrategy 期货TWAP/VWAP，总数量未设置时按保证金乘杠杆和到达价折算，reduce_only用于分批平仓
+func (fs *FuturesService) executeFuturesExecutionStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
+	label := fmt.Sprintf("期货%s策略%d", strings.ToUpper(string(strategy.Type)), strategy.ID)
+	done, err := runExecution(fs.db, strategy, strategy.State, strategy.Type, strategy.Side, strategy.Config,
+		newFuturesOrderVenue(fs, strategy, exchange), label, time.Now())
+	if done {
+		return fs.completeFuturesStrategy(strategy, "执行完成")
+	}
+	return err
+}
+
+// executionStats TWAP/VWAP的执行进度
+func executionStats(state models.StrategyState) *ExecutionState {
+	execution := loadExecutionState(state)
+	if execution != nil {
+		execution.Profile = nil
+	}
+	return execution
+}
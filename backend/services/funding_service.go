@@ -0,0 +1,489 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fundingInterestClamp 资金费率公式中利率与溢价之差的限幅（0.05%）
+const fundingInterestClamp = 0.0005
+
+// incomeInitialLookback 首次同步资金流水时回溯的时长，与交易所不传起始时间时的默认范围一致
+const incomeInitialLookback = 7 * 24 * time.Hour
+
+// syncedIncomeTypes 需要同步的资金流水类型
+var syncedIncomeTypes = []models.FuturesIncomeType{
+	models.FuturesIncomeFundingFee,
+	models.FuturesIncomeCommission,
+	models.FuturesIncomeRealizedPnL,
+}
+
+// FundingService 同步永续合约资金费率和用户的期货资金流水
+type FundingService struct {
+	db          *gorm.DB
+	userService *UserService
+}
+
+func NewFundingService() *FundingService {
+	return &FundingService{
+		db:          config.DB,
+		userService: NewUserService(),
+	}
+}
+
+// predictedFundingRate 按资金费率公式 F = P + clamp(I - P, ±0.05%) 用当前溢价估算资金费率
+func predictedFundingRate(premium, interestRate float64) float64 {
+	return premium + math.Max(-fundingInterestClamp, math.Min(fundingInterestClamp, interestRate-premium))
+}
+
+// SyncFundingRates 从premiumIndex同步全部永续合约的资金费率，返回更新的交易对数量
+func (fs *FundingService) SyncFundingRates(ctx context.Context) (int, error) {
+	if fs.db == nil {
+		return 0, errors.New("数据库未连接")
+	}
+	exchange, err := NewPublicExchange()
+	if err != nil {
+		return 0, err
+	}
+	rates, err := exchange.GetFundingRates(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var existing []models.FundingRate
+	if err := fs.db.Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	bySymbol := make(map[string]*models.FundingRate, len(existing))
+	for i := range existing {
+		bySymbol[existing[i].Symbol] = &existing[i]
+	}
+
+	updated := 0
+	err = fs.db.Transaction(func(tx *gorm.DB) error {
+		for _, rate := range rates {
+			// 交割合约没有资金费率
+			if rate.NextFundingTime.UnixMilli() <= 0 || rate.MarkPrice <= 0 {
+				continue
+			}
+			record, ok := bySymbol[rate.Symbol]
+			if !ok {
+				record = &models.FundingRate{Symbol: rate.Symbol}
+			} else if rate.NextFundingTime.After(record.NextFundingTime) && !record.NextFundingTime.IsZero() {
+				// 进入下一期，上一期的资金费率已按该值结算
+				settledAt := record.NextFundingTime
+				record.SettledFundingRate = record.FundingRate
+				record.SettledAt = &settledAt
+			}
+
+			premium := 0.0
+			if rate.IndexPrice > 0 {
+				premium = (rate.MarkPrice - rate.IndexPrice) / rate.IndexPrice
+			}
+			record.MarkPrice = rate.MarkPrice
+			record.IndexPrice = rate.IndexPrice
+			record.FundingRate = rate.LastFundingRate
+			record.PredictedFundingRate = predictedFundingRate(premium, rate.InterestRate)
+			record.Premium = premium
+			record.InterestRate = rate.InterestRate
+			record.NextFundingTime = rate.NextFundingTime
+			if err := tx.Save(record).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	return updated, err
+}
+
+// GetFundingRate 获取交易对最近同步的资金费率
+func (fs *FundingService) GetFundingRate(symbol string) (*models.FundingRate, error) {
+	var rate models.FundingRate
+	if err := fs.db.Where("symbol = ?", strings.ToUpper(symbol)).First(&rate).Error; err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// GetFundingRates 获取资金费率列表，symbols为空时按资金费率绝对值从高到低返回
+func (fs *FundingService) GetFundingRates(symbols []string, limit int) ([]models.FundingRate, error) {
+	var rates []models.FundingRate
+	query := fs.db.Model(&models.FundingRate{})
+	if len(symbols) > 0 {
+		upper := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			upper = append(upper, strings.ToUpper(strings.TrimSpace(symbol)))
+		}
+		query = query.Where("symbol IN ?", upper)
+	}
+	if err := query.Order("ABS(funding_rate) DESC").Limit(limit).Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// SyncAllIncomeHistory 为配置了API密钥的用户同步期货资金流水
+func (fs *FundingService) SyncAllIncomeHistory(ctx context.Context) error {
+	var users []models.User
+	if err := fs.db.Where("api_key != '' AND secret_key != '' AND status = ?", models.StatusActive).Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		synced, err := fs.SyncIncomeHistory(ctx, user.ID)
+		if err != nil {
+			log.Printf("同步用户%d期货资金流水失败: %v", user.ID, err)
+			continue
+		}
+		if synced > 0 {
+			log.Printf("同步用户%d期货资金流水%d条", user.ID, synced)
+		}
+	}
+	return nil
+}
+
+// SyncIncomeHistory 从上次同步的时间开始增量拉取资金费用、手续费和已实现盈亏流水并归属到策略，返回新增条数
+func (fs *FundingService) SyncIncomeHistory(ctx context.Context, userID uint) (int, error) {
+	exchange, err := fs.userService.GetExchange(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	synced := 0
+	for _, incomeType := range syncedIncomeTypes {
+		var last models.FuturesIncome
+		start := time.Now().Add(-incomeInitialLookback)
+		if err := fs.db.Where("user_id = ? AND income_type = ?", userID, incomeType).
+			Order("income_time DESC").First(&last).Error; err == nil {
+			start = last.IncomeTime
+		}
+
+		for {
+			records, err := exchange.GetFuturesIncomeHistory(ctx, string(incomeType), start, 1000)
+			if err != nil {
+				return synced, err
+			}
+			for _, record := range records {
+				created, err := fs.saveIncome(userID, incomeType, record)
+				if err != nil {
+					return synced, err
+				}
+				if created {
+					synced++
+				}
+			}
+
+			// 起始时间包含边界，同一毫秒的流水靠唯一索引去重
+			if len(records) < 1000 || !records[len(records)-1].Time.After(start) {
+				break
+			}
+			start = records[len(records)-1].Time
+		}
+	}
+
+	fs.attributePendingIncomes(userID)
+	return synced, nil
+}
+
+// saveIncome 保存一条资金流水，已存在时返回false
+func (fs *FundingService) saveIncome(userID uint, incomeType models.FuturesIncomeType, record FuturesIncomeRecord) (bool, error) {
+	income := &models.FuturesIncome{
+		UserID:     userID,
+		TranID:     record.TranID,
+		IncomeType: incomeType,
+		Symbol:     record.Symbol,
+		Income:     record.Income,
+		Asset:      record.Asset,
+		Info:       record.Info,
+		TradeID:    record.TradeID,
+		IncomeTime: record.Time,
+	}
+	income.StrategyID = fs.incomeStrategy(income)
+
+	result := fs.db.Clauses(clause.OnConflict{DoNothing: true}).Create(income)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if income.IncomeType == models.FuturesIncomeFundingFee {
+		if err := fs.allocateFunding(income); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// incomeStrategy 手续费和已实现盈亏按成交归属；资金费用归属结算时持仓数量最大的策略，各策略的拆分金额见allocateFunding
+func (fs *FundingService) incomeStrategy(income *models.FuturesIncome) *uint {
+	if income.Symbol == "" {
+		return nil
+	}
+
+	if income.TradeID != "" {
+		var trade models.Trade
+		if err := fs.db.Where("user_id = ? AND market = ? AND symbol = ? AND trade_id = ?",
+			income.UserID, string(MarketFutures), income.Symbol, income.TradeID).First(&trade).Error; err == nil {
+			return trade.StrategyID
+		}
+		return nil
+	}
+	if income.IncomeType != models.FuturesIncomeFundingFee {
+		return nil
+	}
+
+	totals, err := fs.fundingHoldings(income)
+	if err != nil {
+		return nil
+	}
+	var strategyID *uint
+	largest := math.Max(totals[0], pnlEpsilon)
+	for id, quantity := range totals {
+		if id != 0 && quantity > largest {
+			value := id
+			strategyID = &value
+			largest = quantity
+		}
+	}
+	return strategyID
+}
+
+// fundingHoldings 资金费用结算时各策略在该交易对上的持仓数量（各持仓方向净持仓的绝对值之和），0表示手动交易
+func (fs *FundingService) fundingHoldings(income *models.FuturesIncome) (map[uint]float64, error) {
+	var rows []struct {
+		StrategyID   *uint
+		PositionSide string
+		Side         models.OrderSide
+		Quantity     float64
+	}
+	if err := fs.db.Model(&models.Trade{}).
+		Select("strategy_id, position_side, side, SUM(quantity) AS quantity").
+		Where("user_id = ? AND market = ? AND symbol = ? AND trade_time <= ? AND trade_id NOT LIKE ?",
+			income.UserID, string(MarketFutures), income.Symbol, income.IncomeTime, paperTradeIDPrefix+"%").
+		Group("strategy_id, position_side, side").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	// 按策略和持仓方向求净持仓，双向持仓的多空两侧都支付资金费用
+	type holding struct {
+		strategyID   uint // 0表示手动交易
+		positionSide string
+	}
+	net := make(map[holding]float64)
+	for _, row := range rows {
+		key := holding{positionSide: strings.ToUpper(row.PositionSide)}
+		if row.StrategyID != nil {
+			key.strategyID = *row.StrategyID
+		}
+		if row.Side == models.OrderSideSell {
+			net[key] -= row.Quantity
+		} else {
+			net[key] += row.Quantity
+		}
+	}
+
+	totals := make(map[uint]float64)
+	for key, quantity := range net {
+		if math.Abs(quantity) > pnlEpsilon {
+			totals[key.strategyID] += math.Abs(quantity)
+		}
+	}
+	return totals, nil
+}
+
+// allocateFunding 按结算时各策略的持仓数量拆分资金费用，重新拆分时替换原有记录。
+// 多空持仓的费率方向不同，同一交易对上多空策略并存时按数量拆分只是近似
+func (fs *FundingService) allocateFunding(income *models.FuturesIncome) error {
+	totals, err := fs.fundingHoldings(income)
+	if err != nil {
+		return err
+	}
+	total := 0.0
+	for _, quantity := range totals {
+		total += quantity
+	}
+
+	allocation := func(strategyID uint, amount float64) *models.FuturesIncomeAllocation {
+		record := &models.FuturesIncomeAllocation{
+			UserID:     income.UserID,
+			IncomeID:   income.ID,
+			Symbol:     income.Symbol,
+			Income:     amount,
+			Asset:      income.Asset,
+			IncomeTime: income.IncomeTime,
+		}
+		if strategyID != 0 {
+			record.StrategyID = &strategyID
+		}
+		return record
+	}
+
+	var allocations []*models.FuturesIncomeAllocation
+	if total <= pnlEpsilon {
+		// 找不到结算时的持仓（如成交尚未回补），整笔计入手动交易，回补后由attributePendingIncomes重新拆分
+		allocations = append(allocations, allocation(0, income.Income))
+	} else {
+		for strategyID, quantity := range totals {
+			allocations = append(allocations, allocation(strategyID, income.Income*quantity/total))
+		}
+	}
+
+	return fs.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("income_id = ?", income.ID).Delete(&models.FuturesIncomeAllocation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&allocations).Error
+	})
+}
+
+// attributePendingIncomes 成交回补晚于流水同步时，补充最近未归属流水的策略并重新拆分资金费用；
+// 同时为还没有拆分记录的历史资金费用补做拆分
+func (fs *FundingService) attributePendingIncomes(userID uint) {
+	var pending []models.FuturesIncome
+	if err := fs.db.Where("user_id = ? AND strategy_id IS NULL AND trade_id <> '' AND income_time > ?",
+		userID, time.Now().Add(-incomeInitialLookback)).Find(&pending).Error; err != nil {
+		return
+	}
+	for i := range pending {
+		if strategyID := fs.incomeStrategy(&pending[i]); strategyID != nil {
+			fs.db.Model(&pending[i]).Update("strategy_id", *strategyID)
+		}
+	}
+
+	var funding []models.FuturesIncome
+	if err := fs.db.Where("user_id = ? AND income_type = ?", userID, models.FuturesIncomeFundingFee).
+		Where(fs.db.Where("strategy_id IS NULL AND income_time > ?", time.Now().Add(-incomeInitialLookback)).
+			Or("NOT EXISTS (SELECT 1 FROM futures_income_allocations a WHERE a.income_id = futures_incomes.id AND a.deleted_at IS NULL)")).
+		Find(&funding).Error; err != nil {
+		return
+	}
+	for i := range funding {
+		if strategyID := fs.incomeStrategy(&funding[i]); strategyID != nil {
+			fs.db.Model(&funding[i]).Update("strategy_id", *strategyID)
+		}
+		if err := fs.allocateFunding(&funding[i]); err != nil {
+			log.Printf("拆分资金费用失败 (用户%d, 流水%d): %v", userID, funding[i].ID, err)
+		}
+	}
+}
+
+// IncomeQuery 资金流水查询条件
+type IncomeQuery struct {
+	UserID     uint
+	IncomeType string
+	Symbol     string
+	StrategyID *uint
+	StartTime  *time.Time
+	EndTime    *time.Time
+}
+
+func (fs *FundingService) incomeQuery(query IncomeQuery) *gorm.DB {
+	tx := fs.db.Model(&models.FuturesIncome{}).Where("user_id = ?", query.UserID)
+	if query.IncomeType != "" {
+		tx = tx.Where("income_type = ?", strings.ToUpper(query.IncomeType))
+	}
+	if query.Symbol != "" {
+		tx = tx.Where("symbol = ?", strings.ToUpper(query.Symbol))
+	}
+	if query.StrategyID != nil {
+		tx = tx.Where("strategy_id = ?", *query.StrategyID)
+	}
+	if query.StartTime != nil {
+		tx = tx.Where("income_time >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		tx = tx.Where("income_time < ?", *query.EndTime)
+	}
+	return tx
+}
+
+// allocationQuery 按查询条件过滤资金费用拆分记录
+func (fs *FundingService) allocationQuery(query IncomeQuery) *gorm.DB {
+	tx := fs.db.Model(&models.FuturesIncomeAllocation{}).Where("user_id = ?", query.UserID)
+	if query.Symbol != "" {
+		tx = tx.Where("symbol = ?", strings.ToUpper(query.Symbol))
+	}
+	if query.StrategyID != nil {
+		tx = tx.Where("strategy_id = ?", *query.StrategyID)
+	}
+	if query.StartTime != nil {
+		tx = tx.Where("income_time >= ?", *query.StartTime)
+	}
+	if query.EndTime != nil {
+		tx = tx.Where("income_time < ?", *query.EndTime)
+	}
+	return tx
+}
+
+// GetIncomes 分页获取资金流水
+func (fs *FundingService) GetIncomes(query IncomeQuery, page, limit int) ([]models.FuturesIncome, int64, error) {
+	var incomes []models.FuturesIncome
+	var total int64
+
+	if err := fs.incomeQuery(query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := fs.incomeQuery(query).Order("income_time desc").Offset(offset).Limit(limit).Find(&incomes).Error; err != nil {
+		return nil, 0, err
+	}
+	return incomes, total, nil
+}
+
+// IncomeSummary 按流水类型和资产汇总金额
+func (fs *FundingService) IncomeSummary(query IncomeQuery) (map[string]map[string]float64, error) {
+	type incomeTotal struct {
+		IncomeType string
+		Asset      string
+		Total      float64
+	}
+	var rows []incomeTotal
+	tx := fs.incomeQuery(query)
+	if query.StrategyID != nil {
+		// 按策略汇总时资金费用取拆分后的金额
+		tx = tx.Where("income_type <> ?", models.FuturesIncomeFundingFee)
+	}
+	if err := tx.Select("income_type, asset, SUM(income) AS total").
+		Group("income_type, asset").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	if query.StrategyID != nil && (query.IncomeType == "" || strings.EqualFold(query.IncomeType, string(models.FuturesIncomeFundingFee))) {
+		var funding []struct {
+			Asset string
+			Total float64
+		}
+		if err := fs.allocationQuery(query).
+			Select("asset, SUM(income) AS total").
+			Group("asset").
+			Scan(&funding).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range funding {
+			rows = append(rows, incomeTotal{string(models.FuturesIncomeFundingFee), row.Asset, row.Total})
+		}
+	}
+
+	summary := make(map[string]map[string]float64)
+	for _, row := range rows {
+		if summary[row.IncomeType] == nil {
+			summary[row.IncomeType] = make(map[string]float64)
+		}
+		summary[row.IncomeType][row.Asset] += row.Total
+	}
+	return summary, nil
+}
@@ -0,0 +1,504 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+// GridBias 期货网格方向
+type GridBias string
+
+const (
+	GridBiasLong    GridBias = "long"    // 做多网格：下沿开多，上沿平多
+	GridBiasShort   GridBias = "short"   // 做空网格：上沿开空，下沿平空
+	GridBiasNeutral GridBias = "neutral" // 中性网格：启动时价格下方的格子做多，上方的格子做空
+)
+
+// futuresGridStateKey 期货网格状态在FuturesStrategy.State中的键
+const futuresGridStateKey = "futures_grid"
+
+// FuturesGridSlot 期货网格的一格，Direction在建网格时确定。
+// 做多格在下沿挂开多单，成交后在上沿挂只减仓的平多单；做空格在上沿挂开空单，成交后在下沿挂只减仓的平空单
+type FuturesGridSlot struct {
+	Index      int     `json:"index"`
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+	Direction  string  `json:"direction"`          // long或short
+	Side       string  `json:"side,omitempty"`     // 当前挂单方向，为空表示未挂单
+	OrderID    string  `json:"order_id,omitempty"` // 当前挂单
+	Position   float64 `json:"position"`           // 开仓成交后待平仓的数量
+	EntryValue float64 `json:"entry_value"`        // 待平仓数量的开仓成交额（计价资产）
+	Fees       float64 `json:"fees"`               // 待平仓数量已支付的开仓手续费（计价资产）
+	Profit     float64 `json:"profit"`             // 该格累计已实现利润（计价资产，已扣除手续费）
+	RoundTrips int     `json:"round_trips"`        // 该格完成的开平仓次数
+}
+
+// FuturesGridState 期货网格运行状态，配置变化时平仓重建
+type FuturesGridState struct {
+	LowerPrice     float64            `json:"lower_price"`
+	UpperPrice     float64            `json:"upper_price"`
+	GridCount      int                `json:"grid_count"`
+	Spacing        GridSpacing        `json:"spacing"`
+	Bias           GridBias           `json:"bias"`
+	StopLowerPrice float64            `json:"stop_lower_price,omitempty"`
+	StopUpperPrice float64            `json:"stop_upper_price,omitempty"`
+	Levels         []float64          `json:"levels"`
+	Slots          []*FuturesGridSlot `json:"slots"`
+	TotalProfit    float64            `json:"total_profit"`
+	RoundTrips     int                `json:"round_trips"`
+	StopReason     string             `json:"stop_reason,omitempty"`
+	StoppedAt      *time.Time         `json:"stopped_at,omitempty"`
+}
+
+// futuresGridConfig 期货网格配置，在现货网格配置基础上增加方向和突破止损区间
+type futuresGridConfig struct {
+	*gridConfig
+	bias           GridBias
+	stopLowerPrice float64
+	stopUpperPrice float64
+}
+
+// parseFuturesGridConfig 解析期货网格配置，bias未设置时按策略方向取long或short，止损区间为0表示不启用
+func parseFuturesGridConfig(config map[string]interface{}, side models.OrderSide) (*futuresGridConfig, error) {
+	base, err := parseGridConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &futuresGridConfig{gridConfig: base, bias: GridBiasLong}
+	if side == models.OrderSideSell {
+		cfg.bias = GridBiasShort
+	}
+
+	if bias, ok := config["bias"].(string); ok && bias != "" {
+		switch GridBias(strings.ToLower(bias)) {
+		case GridBiasLong:
+			cfg.bias = GridBiasLong
+		case GridBiasShort:
+			cfg.bias = GridBiasShort
+		case GridBiasNeutral:
+			cfg.bias = GridBiasNeutral
+		default:
+			return nil, fmt.Errorf("不支持的网格方向: %s", bias)
+		}
+	}
+
+	cfg.stopLowerPrice, _ = config["stop_lower_price"].(float64)
+	cfg.stopUpperPrice, _ = config["stop_upper_price"].(float64)
+	if cfg.stopLowerPrice < 0 || cfg.stopUpperPrice < 0 {
+		return nil, errors.New("止损价格不能为负数")
+	}
+	if cfg.stopLowerPrice > 0 && cfg.stopLowerPrice >= cfg.lowerPrice {
+		return nil, errors.New("下方止损价格必须低于网格下限价格")
+	}
+	if cfg.stopUpperPrice > 0 && cfg.stopUpperPrice <= cfg.upperPrice {
+		return nil, errors.New("上方止损价格必须高于网格上限价格")
+	}
+	return cfg, nil
+}
+
+// newFuturesGridState 按当前价格分配每格的方向
+func newFuturesGridState(cfg *futuresGridConfig, currentPrice float64) *FuturesGridState {
+	state := &FuturesGridState{
+		LowerPrice:     cfg.lowerPrice,
+		UpperPrice:     cfg.upperPrice,
+		GridCount:      cfg.gridCount,
+		Spacing:        cfg.spacing,
+		Bias:           cfg.bias,
+		StopLowerPrice: cfg.stopLowerPrice,
+		StopUpperPrice: cfg.stopUpperPrice,
+		Levels:         gridLevels(cfg.lowerPrice, cfg.upperPrice, cfg.gridCount, cfg.spacing),
+	}
+	for i := 0; i < cfg.gridCount-1; i++ {
+		slot := &FuturesGridSlot{Index: i, Lower: state.Levels[i], Upper: state.Levels[i+1], Direction: string(GridBiasLong)}
+		switch cfg.bias {
+		case GridBiasShort:
+			slot.Direction = string(GridBiasShort)
+		case GridBiasNeutral:
+			if (slot.Lower+slot.Upper)/2 > currentPrice {
+				slot.Direction = string(GridBiasShort)
+			}
+		}
+		state.Slots = append(state.Slots, slot)
+	}
+	return state
+}
+
+// matches 配置是否与当前网格一致
+func (fgs *FuturesGridState) matches(cfg *futuresGridConfig) bool {
+	return fgs.GridCount == cfg.gridCount && fgs.Spacing == cfg.spacing && fgs.Bias == cfg.bias &&
+		fgs.LowerPrice == cfg.lowerPrice && fgs.UpperPrice == cfg.upperPrice && len(fgs.Slots) == cfg.gridCount-1
+}
+
+// loadFuturesGridState 从FuturesStrategy.State读取网格状态，不存在时返回nil
+func loadFuturesGridState(strategy *models.FuturesStrategy) *FuturesGridState {
+	if strategy.State == nil || strategy.State[futuresGridStateKey] == nil {
+		return nil
+	}
+	var state FuturesGridState
+	if err := decodeStateValue(strategy.State[futuresGridStateKey], &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+// futuresGridPositionSide 格子方向对应的持仓方向
+func futuresGridPositionSide(direction string) models.PositionSide {
+	if direction == string(GridBiasShort) {
+		return models.PositionSideShort
+	}
+	return models.PositionSideLong
+}
+
+// executeFuturesGridStrategy 永续合约网格：结算已结束的网格挂单并挂出平仓单，再为空闲的格子挂开仓单。
+// 每格名义价值为保证金乘杠杆平均分到grid_count-1格，开仓价按FloatBasisPoints向远离现价方向浮动。
+// 价格突破止损区间时撤销全部挂单、以只减仓市价单平掉网格持仓并结束策略
+func (fs *FuturesService) executeFuturesGridStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
+	cfg, err := parseFuturesGridConfig(strategy.Config, strategy.Side)
+	if err != nil {
+		return err
+	}
+	// 单向持仓下多空格子的持仓会相互抵消，中性网格只能在双向持仓模式运行
+	if cfg.bias == GridBiasNeutral && !fs.dualSidePosition(strategy.UserID, exchange) {
+		return errors.New("中性网格需要账户开启双向持仓模式")
+	}
+
+	currentPrice, err := exchange.GetFuturesPrice(context.Background(), strategy.Symbol)
+	if err != nil {
+		return err
+	}
+
+	_, quote := splitSymbolBySuffix(strings.ToUpper(strategy.Symbol))
+	var info *SymbolInfo
+	if symbolInfo, err := exchange.GetFuturesSymbolInfo(strategy.Symbol); err == nil {
+		info = symbolInfo
+		if info.QuoteAsset != "" {
+			quote = info.QuoteAsset
+		}
+	}
+
+	state := loadFuturesGridState(strategy)
+	if state == nil || !state.matches(cfg) {
+		if state != nil {
+			// 网格参数被修改，平掉旧网格的持仓后按新参数重建，已实现利润保留。旧网格订单未全部结束时下一轮重试
+			if !fs.closeFuturesGrid(strategy, state, exchange, currentPrice, quote) {
+				if err := fs.saveFuturesGridState(strategy, state); err != nil {
+					return err
+				}
+				return errors.New("旧网格订单尚未全部撤销，下一轮重试")
+			}
+			profit, roundTrips := state.TotalProfit, state.RoundTrips
+			state = newFuturesGridState(cfg, currentPrice)
+			state.TotalProfit, state.RoundTrips = profit, roundTrips
+		} else {
+			state = newFuturesGridState(cfg, currentPrice)
+		}
+	}
+	// 止损区间不影响格子划分，修改后直接生效
+	state.StopLowerPrice, state.StopUpperPrice = cfg.stopLowerPrice, cfg.stopUpperPrice
+
+	for _, slot := range state.Slots {
+		if slot.OrderID == "" {
+			continue
+		}
+		if err := fs.settleFuturesGridOrder(state, slot, quote); err != nil {
+			log.Printf("处理期货网格策略%d第%d格订单失败: %v", strategy.ID, slot.Index, err)
+		}
+	}
+
+	// 触发止损后持续平仓，直到全部订单结束才完成策略，期间价格回到区间内也不恢复网格
+	if state.StoppedAt != nil || (cfg.stopLowerPrice > 0 && currentPrice <= cfg.stopLowerPrice) || (cfg.stopUpperPrice > 0 && currentPrice >= cfg.stopUpperPrice) {
+		if state.StoppedAt == nil {
+			now := time.Now()
+			state.StopReason = fmt.Sprintf("价格%.8g突破止损区间[%.8g, %.8g]", currentPrice, cfg.stopLowerPrice, cfg.stopUpperPrice)
+			state.StoppedAt = &now
+		}
+		done := fs.closeFuturesGrid(strategy, state, exchange, currentPrice, quote)
+		if err := fs.saveFuturesGridState(strategy, state); err != nil {
+			return err
+		}
+		if !done {
+			return fmt.Errorf("期货网格%s，订单尚未全部结束，下一轮重试", state.StopReason)
+		}
+		log.Printf("期货网格策略%d%s，已平仓并停止", strategy.ID, state.StopReason)
+		return fs.completeFuturesStrategy(strategy, state.StopReason)
+	}
+
+	// 价格超出网格区间时暂停开新仓，已有持仓的平仓单照常挂出
+	inRange := currentPrice >= cfg.lowerPrice && currentPrice <= cfg.upperPrice
+	slotValue := strategy.MarginAmount * float64(strategy.Leverage) / float64(len(state.Slots))
+	floatRate := strategy.FloatBasisPoints / 10000.0
+	for _, slot := range state.Slots {
+		if slot.OrderID != "" {
+			continue
+		}
+
+		long := slot.Direction != string(GridBiasShort)
+		var side models.OrderSide
+		var price, quantity float64
+		reduceOnly := slot.Position > 0
+		switch {
+		case reduceOnly && long:
+			side, price, quantity = models.OrderSideSell, slot.Upper, slot.Position
+		case reduceOnly:
+			side, price, quantity = models.OrderSideBuy, slot.Lower, slot.Position
+		case !inRange:
+			continue
+		case long:
+			// 只在价格高于下沿时挂开多单，避免开仓单立即以市价成交
+			if currentPrice <= slot.Lower {
+				continue
+			}
+			side, price = models.OrderSideBuy, slot.Lower*(1-floatRate)
+			quantity = slotValue / price
+		default:
+			if currentPrice >= slot.Upper {
+				continue
+			}
+			side, price = models.OrderSideSell, slot.Upper*(1+floatRate)
+			quantity = slotValue / price
+		}
+
+		price, quantity = roundGridOrder(info, price, quantity)
+		if quantity <= 0 || (!reduceOnly && info != nil && (quantity < info.MinQty || price*quantity < info.MinNotional)) {
+			continue
+		}
+
+		order, err := fs.placeFuturesGridOrder(strategy, exchange, side, futuresGridPositionSide(slot.Direction), models.OrderTypeLimit, price, quantity, reduceOnly)
+		if err != nil {
+			log.Printf("创建期货网格策略%d第%d格%s单失败: %v", strategy.ID, slot.Index, side, err)
+			continue
+		}
+		slot.Side = string(side)
+		slot.OrderID = order.OrderID
+	}
+
+	return fs.saveFuturesGridState(strategy, state)
+}
+
+// settleFuturesGridOrder 处理已结束的网格挂单：开仓单成交计入该格持仓，平仓单成交按开仓成交额结转利润。
+// 被撤销或过期的订单只结算已成交部分，格子回到空闲状态等待重新挂单
+func (fs *FuturesService) settleFuturesGridOrder(state *FuturesGridState, slot *FuturesGridSlot, quote string) error {
+	var order models.FuturesOrder
+	if err := fs.db.Where("order_id = ?", slot.OrderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slot.OrderID, slot.Side = "", ""
+			return nil
+		}
+		return err
+	}
+	if !isFinalOrderStatus(string(order.Status)) {
+		return nil
+	}
+
+	applyFuturesGridFill(state, slot, &order, fs.futuresOrderFee(order.OrderID, quote))
+	slot.OrderID, slot.Side = "", ""
+	return nil
+}
+
+// applyFuturesGridFill 把订单的成交和以计价资产支付的手续费计入格子，order.ReduceOnly区分开仓和平仓
+func applyFuturesGridFill(state *FuturesGridState, slot *FuturesGridSlot, order *models.FuturesOrder, fee float64) {
+	executed := order.ExecutedQty
+	if executed <= 0 {
+		return
+	}
+	quoteQty := order.CumulativeQuoteQty
+	if quoteQty <= 0 {
+		quoteQty = executed * order.Price
+	}
+
+	if !order.ReduceOnly {
+		slot.Position += executed
+		slot.EntryValue += quoteQty
+		slot.Fees += fee
+		return
+	}
+	if slot.Position <= 0 {
+		return
+	}
+
+	closed := math.Min(executed, slot.Position)
+	if order.Status == "FILLED" {
+		// 完全成交后不足一个步长的余量一并结转
+		closed = slot.Position
+	}
+	portion := closed / slot.Position
+	entryValue, entryFees := slot.EntryValue*portion, slot.Fees*portion
+	// 平仓数量超过格子持仓时只计入对应部分的成交额，结转的余量没有平仓成交额
+	exitValue := quoteQty * math.Min(closed, executed) / executed
+
+	profit := exitValue - entryValue
+	if slot.Direction == string(GridBiasShort) {
+		profit = -profit
+	}
+	profit -= entryFees + fee
+
+	slot.Position -= closed
+	slot.EntryValue -= entryValue
+	slot.Fees -= entryFees
+	slot.Profit += profit
+	state.TotalProfit += profit
+	if slot.Position <= 1e-12 {
+		slot.Position, slot.EntryValue, slot.Fees = 0, 0, 0
+		slot.RoundTrips++
+		state.RoundTrips++
+	}
+}
+
+// futuresOrderFee 订单以计价资产支付的手续费，其他资产支付的手续费不计入网格利润
+func (fs *FuturesService) futuresOrderFee(orderID, quote string) float64 {
+	var trades []models.Trade
+	if err := fs.db.Where("order_id = ? AND market = ?", orderID, string(MarketFutures)).Find(&trades).Error; err != nil {
+		return 0
+	}
+
+	var fee float64
+	for _, trade := range trades {
+		if strings.EqualFold(trade.CommissionAsset, quote) {
+			fee += trade.Commission
+		}
+	}
+	return fee
+}
+
+func (fs *FuturesService) placeFuturesGridOrder(strategy *models.FuturesStrategy, exchange Exchange, side models.OrderSide, positionSide models.PositionSide, orderType models.OrderType, price, quantity float64, reduceOnly bool) (*models.FuturesOrder, error) {
+	if !fs.dualSidePosition(strategy.UserID, exchange) {
+		// 单向持仓模式下平仓依靠reduceOnly
+		positionSide = models.PositionSideBoth
+	}
+	order := &models.FuturesOrder{
+		UserID:        strategy.UserID,
+		StrategyID:    &strategy.ID,
+		Symbol:        strategy.Symbol,
+		Side:          side,
+		PositionSide:  positionSide,
+		Type:          orderType,
+		Quantity:      quantity,
+		ReduceOnly:    reduceOnly,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+	if orderType == models.OrderTypeLimit {
+		order.Price = price
+		order.TimeInForce = "GTC"
+	}
+
+	resp, err := exchange.CreateFuturesOrder(context.Background(), order)
+	if err != nil {
+		return nil, err
+	}
+
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	// 挂单可能立即成交，记录成交数量供下一轮结算
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
+
+	if err := fs.db.Create(order).Error; err != nil {
+		log.Printf("保存期货订单失败: %v", err)
+	}
+	return order, nil
+}
+
+// closeFuturesGrid 撤销网格的全部挂单并结算已成交部分，再按持仓方向以只减仓市价单平掉各格持仓。
+// 平仓成交价取交易所返回的成交均价，未返回时按当前价格估算利润。
+// 撤单失败时按订单最新状态结算，订单仍未结束则保留在格子上并返回false，由调用方下一轮重试，此时不平仓以免挂单随后成交
+func (fs *FuturesService) closeFuturesGrid(strategy *models.FuturesStrategy, state *FuturesGridState, exchange Exchange, currentPrice float64, quote string) bool {
+	ctx := context.Background()
+	orderSync := NewOrderSyncService()
+	pending := 0
+	for _, slot := range state.Slots {
+		if slot.OrderID == "" {
+			continue
+		}
+		resp, err := exchange.CancelFuturesOrder(ctx, strategy.Symbol, slot.OrderID)
+		if err != nil {
+			log.Printf("撤销期货网格策略%d订单%s失败: %v", strategy.ID, slot.OrderID, err)
+			if resp, err = exchange.GetFuturesOrderStatus(ctx, strategy.Symbol, slot.OrderID); err != nil {
+				log.Printf("查询期货网格策略%d订单%s失败: %v", strategy.ID, slot.OrderID, err)
+				pending++
+				continue
+			}
+			orderSync.applyOrderResult(strategy.UserID, MarketFutures, resp, slot.OrderID)
+		} else {
+			fs.db.Model(&models.FuturesOrder{}).Where("order_id = ?", slot.OrderID).Updates(map[string]interface{}{
+				"status":               resp.Status,
+				"executed_qty":         resp.ExecutedQty,
+				"cumulative_quote_qty": resp.CumulativeQuoteQty,
+			})
+		}
+		if err := fs.settleFuturesGridOrder(state, slot, quote); err != nil {
+			log.Printf("处理期货网格策略%d第%d格订单失败: %v", strategy.ID, slot.Index, err)
+		}
+		if slot.OrderID != "" {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return false
+	}
+
+	closed := true
+	for _, direction := range []string{string(GridBiasLong), string(GridBiasShort)} {
+		var total float64
+		for _, slot := range state.Slots {
+			if slot.Direction == direction {
+				total += slot.Position
+			}
+		}
+		if total <= 0 {
+			continue
+		}
+
+		side := models.OrderSideSell
+		if direction == string(GridBiasShort) {
+			side = models.OrderSideBuy
+		}
+		order, err := fs.placeFuturesGridOrder(strategy, exchange, side, futuresGridPositionSide(direction), models.OrderTypeMarket, 0, total, true)
+		if err != nil {
+			log.Printf("期货网格策略%d平%s仓失败: %v", strategy.ID, direction, err)
+			closed = false
+			continue
+		}
+
+		exitPrice := currentPrice
+		if order.ExecutedQty > 0 && order.CumulativeQuoteQty > 0 {
+			exitPrice = order.CumulativeQuoteQty / order.ExecutedQty
+		}
+		fee := fs.futuresOrderFee(order.OrderID, quote)
+		for _, slot := range state.Slots {
+			if slot.Direction != direction || slot.Position <= 0 {
+				continue
+			}
+			fill := &models.FuturesOrder{
+				OrderID:            order.OrderID,
+				Price:              exitPrice,
+				ExecutedQty:        slot.Position,
+				CumulativeQuoteQty: slot.Position * exitPrice,
+				ReduceOnly:         true,
+				Status:             "FILLED",
+			}
+			// 平仓手续费按数量分摊到各格
+			applyFuturesGridFill(state, slot, fill, fee*slot.Position/total)
+		}
+	}
+	return closed
+}
+
+func (fs *FuturesService) saveFuturesGridState(strategy *models.FuturesStrategy, state *FuturesGridState) error {
+	strategyState := strategy.State
+	if strategyState == nil {
+		strategyState = make(models.StrategyState)
+	}
+	strategyState[futuresGridStateKey] = encodeStateValue(state)
+	strategyState["grid_profit"] = state.TotalProfit
+	strategyState["grid_round_trips"] = state.RoundTrips
+	return fs.db.Model(strategy).Update("state", strategyState).Error
+}
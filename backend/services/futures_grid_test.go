@@ -0,0 +1,120 @@
+package services
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+func TestApplyFuturesGridFill(t *testing.T) {
+	tests := []struct {
+		name           string
+		slot           FuturesGridSlot
+		order          models.FuturesOrder
+		fee            float64
+		wantPosition   float64
+		wantEntryValue float64
+		wantFees       float64
+		wantProfit     float64
+		wantRoundTrips int
+	}{
+		{
+			name:           "long entry adds position",
+			slot:           FuturesGridSlot{Direction: "long"},
+			order:          models.FuturesOrder{Status: "FILLED", Price: 100, ExecutedQty: 1, CumulativeQuoteQty: 100},
+			fee:            0.04,
+			wantPosition:   1,
+			wantEntryValue: 100,
+			wantFees:       0.04,
+		},
+		{
+			name:           "entry without quote quantity uses limit price",
+			slot:           FuturesGridSlot{Direction: "short"},
+			order:          models.FuturesOrder{Status: "CANCELED", Price: 110, ExecutedQty: 0.5},
+			wantPosition:   0.5,
+			wantEntryValue: 55,
+		},
+		{
+			name:           "long close realizes profit net of fees",
+			slot:           FuturesGridSlot{Direction: "long", Position: 1, EntryValue: 100, Fees: 0.04},
+			order:          models.FuturesOrder{Status: "FILLED", ReduceOnly: true, Price: 110, ExecutedQty: 1, CumulativeQuoteQty: 110},
+			fee:            0.044,
+			wantProfit:     110 - 100 - 0.04 - 0.044,
+			wantRoundTrips: 1,
+		},
+		{
+			name:           "short close profits when buying back lower",
+			slot:           FuturesGridSlot{Direction: "short", Position: 1, EntryValue: 110, Fees: 0.044},
+			order:          models.FuturesOrder{Status: "FILLED", ReduceOnly: true, Price: 100, ExecutedQty: 1, CumulativeQuoteQty: 100},
+			fee:            0.04,
+			wantProfit:     110 - 100 - 0.044 - 0.04,
+			wantRoundTrips: 1,
+		},
+		{
+			name:           "short close loses when buying back higher",
+			slot:           FuturesGridSlot{Direction: "short", Position: 1, EntryValue: 100},
+			order:          models.FuturesOrder{Status: "FILLED", ReduceOnly: true, Price: 105, ExecutedQty: 1, CumulativeQuoteQty: 105},
+			wantProfit:     -5,
+			wantRoundTrips: 1,
+		},
+		{
+			name:           "partial close keeps pro-rata entry value and fees",
+			slot:           FuturesGridSlot{Direction: "long", Position: 2, EntryValue: 200, Fees: 0.08},
+			order:          models.FuturesOrder{Status: "CANCELED", ReduceOnly: true, Price: 110, ExecutedQty: 0.5, CumulativeQuoteQty: 55},
+			wantPosition:   1.5,
+			wantEntryValue: 150,
+			wantFees:       0.06,
+			wantProfit:     55 - 50 - 0.02,
+		},
+		{
+			name:           "close larger than position only books the position",
+			slot:           FuturesGridSlot{Direction: "long", Position: 1, EntryValue: 100},
+			order:          models.FuturesOrder{Status: "CANCELED", ReduceOnly: true, Price: 110, ExecutedQty: 2, CumulativeQuoteQty: 220},
+			wantProfit:     10,
+			wantRoundTrips: 1,
+		},
+		{
+			name:           "filled close sweeps residual below one step",
+			slot:           FuturesGridSlot{Direction: "long", Position: 1.0004, EntryValue: 100.04},
+			order:          models.FuturesOrder{Status: "FILLED", ReduceOnly: true, Price: 110, ExecutedQty: 1, CumulativeQuoteQty: 110},
+			wantProfit:     110 - 100.04,
+			wantRoundTrips: 1,
+		},
+		{
+			name:  "close without position is ignored",
+			slot:  FuturesGridSlot{Direction: "long"},
+			order: models.FuturesOrder{Status: "FILLED", ReduceOnly: true, Price: 110, ExecutedQty: 1, CumulativeQuoteQty: 110},
+		},
+		{
+			name:  "unfilled order changes nothing",
+			slot:  FuturesGridSlot{Direction: "long"},
+			order: models.FuturesOrder{Status: "CANCELED", Price: 100},
+			fee:   0.04,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot := tt.slot
+			state := &FuturesGridState{Slots: []*FuturesGridSlot{&slot}}
+			applyFuturesGridFill(state, &slot, &tt.order, tt.fee)
+
+			if math.Abs(slot.Position-tt.wantPosition) > 1e-9 {
+				t.Errorf("position = %v, want %v", slot.Position, tt.wantPosition)
+			}
+			if math.Abs(slot.EntryValue-tt.wantEntryValue) > 1e-9 {
+				t.Errorf("entry value = %v, want %v", slot.EntryValue, tt.wantEntryValue)
+			}
+			if math.Abs(slot.Fees-tt.wantFees) > 1e-9 {
+				t.Errorf("fees = %v, want %v", slot.Fees, tt.wantFees)
+			}
+			if math.Abs(slot.Profit-tt.wantProfit) > 1e-9 || math.Abs(state.TotalProfit-tt.wantProfit) > 1e-9 {
+				t.Errorf("profit = %v (total %v), want %v", slot.Profit, state.TotalProfit, tt.wantProfit)
+			}
+			if slot.RoundTrips != tt.wantRoundTrips || state.RoundTrips != tt.wantRoundTrips {
+				t.Errorf("round trips = %d (total %d), want %d", slot.RoundTrips, state.RoundTrips, tt.wantRoundTrips)
+			}
+		})
+	}
+}
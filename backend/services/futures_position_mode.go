@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+// positionModeTTL 持仓模式缓存有效期，在交易所网页上切换模式后最迟在该时间后生效
+const positionModeTTL = 10 * time.Minute
+
+type positionModeEntry struct {
+	dualSide  bool
+	checkedAt time.Time
+}
+
+// positionModes 按用户和交易所缓存的期货持仓模式，避免每次下单都查询
+var positionModes sync.Map
+
+func positionModeKey(userID uint, exchange Exchange) string {
+	return fmt.Sprintf("%d:%s", userID, exchange.Name())
+}
+
+// dualSidePosition 账户是否为双向持仓模式，查询失败时按双向持仓处理（与此前的下单方式一致）
+func (fs *FuturesService) dualSidePosition(userID uint, exchange Exchange) bool {
+	key := positionModeKey(userID, exchange)
+	if cached, ok := positionModes.Load(key); ok {
+		entry := cached.(positionModeEntry)
+		if time.Since(entry.checkedAt) < positionModeTTL {
+			return entry.dualSide
+		}
+	}
+
+	dualSide, err := exchange.GetFuturesPositionMode(context.Background())
+	if err != nil {
+		log.Printf("查询用户%d期货持仓模式失败，按双向持仓下单: %v", userID, err)
+		return true
+	}
+	positionModes.Store(key, positionModeEntry{dualSide: dualSide, checkedAt: time.Now()})
+	return dualSide
+}
+
+// futuresPositionSide 按持仓模式确定订单的持仓方向：双向持仓为LONG/SHORT，单向持仓为BOTH
+func (fs *FuturesService) futuresPositionSide(strategy *models.FuturesStrategy, exchange Exchange, long bool) models.PositionSide {
+	if !fs.dualSidePosition(strategy.UserID, exchange) {
+		return models.PositionSideBoth
+	}
+	if long {
+		return models.PositionSideLong
+	}
+	return models.PositionSideShort
+}
+
+// GetPositionMode 查询用户当前交易模式下的期货持仓模式
+func (fs *FuturesService) GetPositionMode(userID uint) (models.PositionMode, error) {
+	exchange, err := fs.userService.GetTradingExchange(userID, false)
+	if err != nil {
+		return "", err
+	}
+	dualSide, err := exchange.GetFuturesPositionMode(context.Background())
+	if err != nil {
+		return "", err
+	}
+	positionModes.Store(positionModeKey(userID, exchange), positionModeEntry{dualSide: dualSide, checkedAt: time.Now()})
+	if dualSide {
+		return models.PositionModeHedge, nil
+	}
+	return models.PositionModeOneWay, nil
+}
+
+// SetPositionMode 切换用户当前交易模式下的期货持仓模式，有持仓或挂单时交易所会拒绝
+func (fs *FuturesService) SetPositionMode(userID uint, mode models.PositionMode) error {
+	if mode != models.PositionModeHedge && mode != models.PositionModeOneWay {
+		return fmt.Errorf("持仓模式无效: %s", mode)
+	}
+	exchange, err := fs.userService.GetTradingExchange(userID, false)
+	if err != nil {
+		return err
+	}
+
+	dualSide := mode == models.PositionModeHedge
+	if err := exchange.SetFuturesPositionMode(context.Background(), dualSide); err != nil {
+		return err
+	}
+	positionModes.Store(positionModeKey(userID, exchange), positionModeEntry{dualSide: dualSide, checkedAt: time.Now()})
+	return nil
+}
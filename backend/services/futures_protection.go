@@ -0,0 +1,496 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+)
+
+const (
+	futuresProtectionStateKey = "protection"
+
+	protectionStatusPending = "pending" // 等待入场单成交
+	protectionStatusActive  = "active"  // 保护单挂单中
+	protectionStatusClosed  = "closed"
+
+	protectionLegStopLoss   = "stop_loss"
+	protectionLegTakeProfit = "take_profit"
+	protectionLegTrailing   = "trailing_stop"
+
+	// orphanOrderGrace 持仓同步有延迟，刚提交的只减仓单不按孤儿单清理
+	orphanOrderGrace = 2 * time.Minute
+)
+
+// FuturesProtectionLeg 一笔保护单，订单被撤销或过期且持仓仍在时会重新挂出
+type FuturesProtectionLeg struct {
+	Kind            string  `json:"kind"`
+	OrderID         string  `json:"order_id,omitempty"`
+	StopPrice       float64 `json:"stop_price,omitempty"`
+	ActivationPrice float64 `json:"activation_price,omitempty"`
+	ExecutedQty     float64 `json:"executed_qty,omitempty"`
+}
+
+// FuturesProtectionState 期货策略的止损、止盈和跟踪止损只减仓条件单，三者联动：任一成交后撤销其余，持仓平掉后全部撤销
+type FuturesProtectionState struct {
+	Status       string                 `json:"status"`
+	PositionSide string                 `json:"position_side"`
+	CloseSide    string                 `json:"close_side"`
+	PlannedPrice float64                `json:"planned_price"` // 下单时的计划入场价，入场成交前的参考价
+	EntryPrice   float64                `json:"entry_price"`   // 入场成交均价
+	Quantity     float64                `json:"quantity"`      // 保护单覆盖的持仓数量
+	Legs         []FuturesProtectionLeg `json:"legs"`
+	CloseReason  string                 `json:"close_reason,omitempty"` // 成交的保护单类型、position_closed或entry_unfilled
+	ClosedAt     *time.Time             `json:"closed_at,omitempty"`
+}
+
+// protectionRunning 同一策略的保护单同时只由一个调用方处理，避免定时任务与成交推送重复挂单
+var protectionRunning sync.Map
+
+// hasFuturesProtection 策略是否设置了止盈、止损或跟踪止损
+func hasFuturesProtection(strategy *models.FuturesStrategy) bool {
+	return strategy.TakeProfitBP > 0 || strategy.StopLossBP > 0 || strategy.TrailingCallbackRate > 0
+}
+
+// validateTrailingCallbackRate 校验跟踪止损回调比例，0表示不启用
+func validateTrailingCallbackRate(rate float64) error {
+	if rate != 0 && (rate < 0.1 || rate > 10) {
+		return errors.New("跟踪止损回调比例必须在0.1-10之间")
+	}
+	return nil
+}
+
+func loadFuturesProtection(strategy *models.FuturesStrategy) *FuturesProtectionState {
+	raw, ok := strategy.State[futuresProtectionStateKey]
+	if !ok {
+		return nil
+	}
+	var state FuturesProtectionState
+	if err := decodeStateValue(raw, &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+func (fs *FuturesService) saveFuturesProtection(strategy *models.FuturesStrategy, state *FuturesProtectionState) error {
+	if strategy.State == nil {
+		strategy.State = make(models.StrategyState)
+	}
+	strategy.State[futuresProtectionStateKey] = encodeStateValue(state)
+	return fs.db.Model(strategy).Update("state", strategy.State).Error
+}
+
+// armProtection 入场单提交后登记保护单，入场成交后按实际持仓数量挂出，取代固定延时后直接挂单
+func (fs *FuturesService) armProtection(strategy *models.FuturesStrategy, exchange Exchange, plannedPrice float64) {
+	if !hasFuturesProtection(strategy) {
+		return
+	}
+	if state := loadFuturesProtection(strategy); state != nil && state.Status != protectionStatusClosed {
+		// 分层入场的后续订单沿用已登记的保护单，数量变化时重新挂出
+		return
+	}
+
+	state := &FuturesProtectionState{
+		Status:       protectionStatusPending,
+		PositionSide: string(fs.futuresPositionSide(strategy, exchange, strategy.Side == models.OrderSideBuy)),
+		CloseSide:    string(oppositeOrder(strategy.Side)),
+		PlannedPrice: plannedPrice,
+	}
+	if err := fs.saveFuturesProtection(strategy, state); err != nil {
+		log.Printf("登记期货策略%d止盈止损失败: %v", strategy.ID, err)
+		return
+	}
+	if err := fs.manageProtection(strategy, exchange); err != nil {
+		log.Printf("期货策略%d挂出止盈止损失败: %v", strategy.ID, err)
+	}
+}
+
+// ManageProtections 推进所有未结束的期货保护单，入场后已标记完成的策略也在此处理
+func (fs *FuturesService) ManageProtections() error {
+	var strategies []models.FuturesStrategy
+	if err := fs.db.Where("take_profit_bp > 0 OR stop_loss_bp > 0 OR trailing_callback_rate > 0").
+		Find(&strategies).Error; err != nil {
+		return err
+	}
+
+	for i := range strategies {
+		strategy := &strategies[i]
+		state := loadFuturesProtection(strategy)
+		if state == nil || state.Status == protectionStatusClosed {
+			continue
+		}
+		exchange, err := fs.userService.GetTradingExchange(strategy.UserID, strategy.PaperTrading)
+		if err != nil {
+			continue
+		}
+		if err := fs.manageProtection(strategy, exchange); err != nil {
+			log.Printf("期货策略%d止盈止损处理失败: %v", strategy.ID, err)
+		}
+	}
+	return nil
+}
+
+// ManageStrategyProtection 策略订单有成交推送时立即推进该策略的保护单
+func (fs *FuturesService) ManageStrategyProtection(strategyID uint) error {
+	var strategy models.FuturesStrategy
+	if err := fs.db.First(&strategy, strategyID).Error; err != nil {
+		return err
+	}
+	state := loadFuturesProtection(&strategy)
+	if state == nil || state.Status == protectionStatusClosed {
+		return nil
+	}
+	exchange, err := fs.userService.GetTradingExchange(strategy.UserID, strategy.PaperTrading)
+	if err != nil {
+		return err
+	}
+	return fs.manageProtection(&strategy, exchange)
+}
+
+// strategyFuturesPosition 按策略订单的成交汇总策略持仓：开仓单成交减去只减仓单成交，同时返回开仓均价和未完成的开仓单数量
+func (fs *FuturesService) strategyFuturesPosition(strategyID uint) (quantity, entryPrice float64, openEntries int, err error) {
+	var orders []models.FuturesOrder
+	if err = fs.db.Where("strategy_id = ?", strategyID).Find(&orders).Error; err != nil {
+		return 0, 0, 0, err
+	}
+
+	var opened, openedValue, closed float64
+	for _, order := range orders {
+		if order.ReduceOnly {
+			closed += order.ExecutedQty
+			continue
+		}
+		opened += order.ExecutedQty
+		openedValue += order.CumulativeQuoteQty
+		if !isFinalOrderStatus(string(order.Status)) {
+			openEntries++
+		}
+	}
+	if opened > 0 {
+		entryPrice = openedValue / opened
+	}
+	return math.Max(0, opened-closed), entryPrice, openEntries, nil
+}
+
+// manageProtection 同步保护单状态：任一保护单成交或持仓已平时撤销其余保护单，持仓数量变化时按新数量重新挂出
+func (fs *FuturesService) manageProtection(strategy *models.FuturesStrategy, exchange Exchange) error {
+	if _, running := protectionRunning.LoadOrStore(strategy.ID, true); running {
+		return nil
+	}
+	defer protectionRunning.Delete(strategy.ID)
+
+	// 取得处理权后重新加载状态，调用方读取的状态可能已被上一个处理者更新
+	var current models.FuturesStrategy
+	if err := fs.db.Select("state").First(&current, strategy.ID).Error; err != nil {
+		return err
+	}
+	strategy.State = current.State
+	state := loadFuturesProtection(strategy)
+	if state == nil || state.Status == protectionStatusClosed {
+		return nil
+	}
+
+	before, _ := json.Marshal(state)
+	label := fmt.Sprintf("期货策略%d", strategy.ID)
+	ctx := context.Background()
+	orderSync := NewOrderSyncService()
+
+	// 刷新保护单状态
+	triggered := ""
+	for i := range state.Legs {
+		leg := &state.Legs[i]
+		if leg.OrderID == "" {
+			continue
+		}
+		result, err := exchange.GetFuturesOrderStatus(ctx, strategy.Symbol, leg.OrderID)
+		if err != nil {
+			return err
+		}
+		orderSync.applyOrderResult(strategy.UserID, MarketFutures, result, leg.OrderID)
+		if !isFinalOrderStatus(result.Status) {
+			continue
+		}
+		if result.ExecutedQty > 0 {
+			leg.ExecutedQty = result.ExecutedQty
+			if triggered == "" {
+				triggered = leg.Kind
+			}
+		}
+		leg.OrderID = ""
+	}
+
+	quantity, entryPrice, openEntries, err := fs.strategyFuturesPosition(strategy.ID)
+	if err != nil {
+		return err
+	}
+	info, _ := exchange.GetFuturesSymbolInfo(strategy.Symbol)
+	dust := executionDust(info) / 2
+
+	flat := quantity <= dust
+	if !flat && triggered == "" && state.Status == protectionStatusActive {
+		// 持仓在系统外被手动平掉时，交易所持仓为准，避免重新挂出被撤销的保护单
+		if amount, err := exchangePositionAmount(ctx, exchange, strategy.Symbol, state.PositionSide, strategy.Side == models.OrderSideBuy); err == nil && amount <= dust {
+			flat = true
+		}
+	}
+
+	switch {
+	case triggered != "":
+		log.Printf("%s%s成交，撤销其余保护单", label, triggered)
+		fs.cancelProtectionLegs(ctx, strategy, exchange, state)
+		fs.closeProtection(state, triggered)
+	case flat && state.Status == protectionStatusActive:
+		log.Printf("%s持仓已平，撤销保护单", label)
+		fs.cancelProtectionLegs(ctx, strategy, exchange, state)
+		fs.closeProtection(state, "position_closed")
+	case flat:
+		if openEntries == 0 {
+			fs.closeProtection(state, "entry_unfilled")
+		}
+	default:
+		if math.Abs(quantity-state.Quantity) > dust {
+			// 分层入场陆续成交时按最新持仓重新挂出
+			fs.cancelProtectionLegs(ctx, strategy, exchange, state)
+			state.Quantity = quantity
+			state.EntryPrice = entryPrice
+			state.Legs = nil
+		}
+		if err := fs.placeProtectionLegs(strategy, exchange, state, info); err != nil {
+			log.Printf("%s挂出保护单失败: %v", label, err)
+		}
+		state.Status = protectionStatusActive
+	}
+
+	after, _ := json.Marshal(state)
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	return fs.saveFuturesProtection(strategy, state)
+}
+
+// exchangePositionAmount 交易所上策略方向的持仓数量（绝对值），单向持仓模式按持仓符号判断方向
+func exchangePositionAmount(ctx context.Context, exchange Exchange, symbol, positionSide string, long bool) (float64, error) {
+	positions, err := exchange.GetFuturesPositions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	side := strings.ToUpper(positionSide)
+	var amount float64
+	for _, position := range positions {
+		if position.Symbol != symbol || strings.ToUpper(position.PositionSide) != side {
+			continue
+		}
+		if side == "BOTH" && (position.PositionAmt > 0) != long {
+			continue
+		}
+		amount += math.Abs(position.PositionAmt)
+	}
+	return amount, nil
+}
+
+func (fs *FuturesService) closeProtection(state *FuturesProtectionState, reason string) {
+	now := time.Now()
+	state.Status = protectionStatusClosed
+	state.CloseReason = reason
+	state.ClosedAt = &now
+}
+
+// cancelProtectionLegs 撤销仍在挂单的保护单，撤销失败时按订单最新状态记录
+func (fs *FuturesService) cancelProtectionLegs(ctx context.Context, strategy *models.FuturesStrategy, exchange Exchange, state *FuturesProtectionState) {
+	orderSync := NewOrderSyncService()
+	for i := range state.Legs {
+		leg := &state.Legs[i]
+		if leg.OrderID == "" {
+			continue
+		}
+		result, err := exchange.CancelFuturesOrder(ctx, strategy.Symbol, leg.OrderID)
+		if err != nil {
+			log.Printf("撤销期货策略%d的%s订单%s失败: %v", strategy.ID, leg.Kind, leg.OrderID, err)
+			if result, err = exchange.GetFuturesOrderStatus(ctx, strategy.Symbol, leg.OrderID); err != nil {
+				continue
+			}
+		}
+		orderSync.applyOrderResult(strategy.UserID, MarketFutures, result, leg.OrderID)
+		leg.OrderID = ""
+	}
+}
+
+// placeProtectionLegs 按入场均价挂出缺失的保护单：止损为STOP_MARKET，止盈为TAKE_PROFIT_MARKET，跟踪止损为TRAILING_STOP_MARKET
+func (fs *FuturesService) placeProtectionLegs(strategy *models.FuturesStrategy, exchange Exchange, state *FuturesProtectionState, info *SymbolInfo) error {
+	entry := state.EntryPrice
+	if entry <= 0 {
+		entry = state.PlannedPrice
+	}
+	long := strategy.Side == models.OrderSideBuy
+	// 盈利方向的价格偏移，做多向上、做空向下
+	offset := func(bp int, profit bool) float64 {
+		rate := float64(bp) / 10000
+		if long != profit {
+			rate = -rate
+		}
+		return entry * (1 + rate)
+	}
+
+	wanted := []FuturesProtectionLeg{}
+	if strategy.StopLossBP > 0 {
+		wanted = append(wanted, FuturesProtectionLeg{Kind: protectionLegStopLoss, StopPrice: offset(strategy.StopLossBP, false)})
+	}
+	if strategy.TakeProfitBP > 0 {
+		wanted = append(wanted, FuturesProtectionLeg{Kind: protectionLegTakeProfit, StopPrice: offset(strategy.TakeProfitBP, true)})
+	}
+	if strategy.TrailingCallbackRate > 0 {
+		leg := FuturesProtectionLeg{Kind: protectionLegTrailing}
+		if strategy.TrailingActivationBP > 0 {
+			leg.ActivationPrice = offset(strategy.TrailingActivationBP, true)
+		}
+		wanted = append(wanted, leg)
+	}
+
+	var errs []string
+	for _, want := range wanted {
+		index := -1
+		for i := range state.Legs {
+			if state.Legs[i].Kind == want.Kind {
+				index = i
+			}
+		}
+		if index >= 0 && state.Legs[index].OrderID != "" {
+			continue
+		}
+
+		orderID, err := fs.placeProtectionOrder(strategy, exchange, state, info, want)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", want.Kind, err))
+			continue
+		}
+		want.OrderID = orderID
+		if index >= 0 {
+			state.Legs[index] = want
+		} else {
+			state.Legs = append(state.Legs, want)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (fs *FuturesService) placeProtectionOrder(strategy *models.FuturesStrategy, exchange Exchange, state *FuturesProtectionState, info *SymbolInfo, leg FuturesProtectionLeg) (string, error) {
+	stopPrice, quantity := roundGridOrder(info, leg.StopPrice, state.Quantity)
+	if quantity <= 0 {
+		return "", errors.New("保护单数量过小")
+	}
+
+	order := &models.FuturesOrder{
+		UserID:        strategy.UserID,
+		StrategyID:    &strategy.ID,
+		Symbol:        strategy.Symbol,
+		Side:          models.OrderSide(state.CloseSide),
+		PositionSide:  models.PositionSide(state.PositionSide),
+		Quantity:      quantity,
+		StopPrice:     stopPrice,
+		ReduceOnly:    true,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+	switch leg.Kind {
+	case protectionLegStopLoss:
+		order.Type = models.OrderTypeStopLoss
+	case protectionLegTakeProfit:
+		order.Type = models.OrderTypeTakeProfit
+	case protectionLegTrailing:
+		order.Type = models.OrderTypeTrailingStopMarket
+		order.StopPrice = 0
+		order.CallbackRate = strategy.TrailingCallbackRate
+		order.ActivationPrice, _ = roundGridOrder(info, leg.ActivationPrice, 0)
+	}
+
+	resp, err := exchange.CreateFuturesOrder(context.Background(), order)
+	if err != nil {
+		return "", err
+	}
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	if err := fs.db.Create(order).Error; err != nil {
+		log.Printf("保存期货保护单失败: %v", err)
+	}
+	return order.OrderID, nil
+}
+
+// CleanupOrphanReduceOnlyOrders 撤销持仓已平（futures_positions中对应方向持仓为0或不存在，且交易所确认无持仓）的只减仓挂单，返回撤销数量
+func (fs *FuturesService) CleanupOrphanReduceOnlyOrders() (int, error) {
+	var orders []models.FuturesOrder
+	if err := fs.db.Where("reduce_only = ? AND status IN ? AND created_at < ?",
+		true, []string{"NEW", "PARTIALLY_FILLED"}, time.Now().Add(-orphanOrderGrace)).Find(&orders).Error; err != nil {
+		return 0, err
+	}
+	if len(orders) == 0 {
+		return 0, nil
+	}
+
+	var positions []models.FuturesPosition
+	if err := fs.db.Where("position_amt <> 0").Find(&positions).Error; err != nil {
+		return 0, err
+	}
+	positionKey := func(userID uint, simulated bool, symbol string, side models.PositionSide) string {
+		return fmt.Sprintf("%d:%t:%s:%s", userID, simulated, symbol, strings.ToUpper(string(side)))
+	}
+	open := make(map[string]bool)
+	for _, position := range positions {
+		open[positionKey(position.UserID, position.IsSimulated, position.Symbol, position.PositionSide)] = true
+	}
+
+	orderSync := NewOrderSyncService()
+	exchanges := make(map[string]Exchange)
+	canceled := 0
+	for _, order := range orders {
+		side := order.PositionSide
+		if side == "" {
+			side = models.PositionSideBoth
+		}
+		if open[positionKey(order.UserID, order.IsSimulated, order.Symbol, side)] {
+			continue
+		}
+
+		key := fmt.Sprintf("%d:%t", order.UserID, order.IsSimulated)
+		exchange, ok := exchanges[key]
+		if !ok {
+			var err error
+			if order.IsSimulated {
+				exchange = fs.userService.GetPaperExchange(order.UserID)
+			} else if exchange, err = fs.userService.GetExchange(order.UserID); err != nil {
+				continue
+			}
+			exchanges[key] = exchange
+		}
+
+		// 本地持仓表可能尚未同步，以交易所持仓为准，查询失败时不撤单
+		amount, err := exchangePositionAmount(context.Background(), exchange, order.Symbol, string(side), strings.EqualFold(string(order.Side), string(models.OrderSideSell)))
+		if err != nil {
+			log.Printf("查询用户%d的%s持仓失败，跳过只减仓订单%s: %v", order.UserID, order.Symbol, order.OrderID, err)
+			continue
+		}
+		if amount > 0 {
+			continue
+		}
+
+		result, err := exchange.CancelFuturesOrder(context.Background(), order.Symbol, order.OrderID)
+		if err != nil {
+			log.Printf("撤销孤儿只减仓订单%s失败: %v", order.OrderID, err)
+			continue
+		}
+		orderSync.applyOrderResult(order.UserID, MarketFutures, result, order.OrderID)
+		log.Printf("用户%d的%s持仓已平，撤销只减仓订单%s", order.UserID, order.Symbol, order.OrderID)
+		canceled++
+	}
+	return canceled, nil
+}
@@ -5,7 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ccj241/cctrade/config"
@@ -29,6 +30,7 @@ func NewFuturesService() *FuturesService {
 func (fs *FuturesService) CreateFuturesStrategy(userID uint, strategyData map[string]interface{}) (*models.FuturesStrategy, error) {
 	strategy := &models.FuturesStrategy{
 		UserID: userID,
+		Status: models.StrategyStatusDraft,
 	}
 
 	if name, ok := strategyData["name"].(string); ok {
@@ -68,10 +70,10 @@ func (fs *FuturesService) CreateFuturesStrategy(userID uint, strategyData map[st
 		return nil, errors.New("保证金金额不能为空")
 	}
 
-	// 触发价格
+	// 触发价格，只有按触发价下单的策略需要
 	if price, ok := strategyData["price"].(float64); ok && price > 0 {
 		strategy.Price = price
-	} else {
+	} else if strategy.Type == models.StrategySimple || strategy.Type == models.StrategyIceberg || strategy.Type == models.StrategySlowIceberg {
 		return nil, errors.New("触发价格不能为空")
 	}
 
@@ -93,6 +95,17 @@ func (fs *FuturesService) CreateFuturesStrategy(userID uint, strategyData map[st
 		strategy.StopLossBP = int(stopLossBP)
 	}
 
+	// 跟踪止损回调比例（百分比）及激活万分比
+	if callbackRate, ok := strategyData["trailing_callback_rate"].(float64); ok {
+		if err := validateTrailingCallbackRate(callbackRate); err != nil {
+			return nil, err
+		}
+		strategy.TrailingCallbackRate = callbackRate
+	}
+	if activationBP, ok := strategyData["trailing_activation_bp"].(float64); ok && activationBP >= 0 {
+		strategy.TrailingActivationBP = int(activationBP)
+	}
+
 	// 杠杆倍数
 	if leverage, ok := strategyData["leverage"].(float64); ok {
 		if leverage < 1 || leverage > 20 {
@@ -113,22 +126,34 @@ func (fs *FuturesService) CreateFuturesStrategy(userID uint, strategyData map[st
 		strategy.AutoRestart = autoRestart
 	}
 
+	if paperTrading, ok := strategyData["paper_trading"].(bool); ok {
+		strategy.PaperTrading = paperTrading
+	}
+
 	if config, ok := strategyData["config"].(map[string]interface{}); ok {
 		strategy.Config = models.StrategyConfig(config)
 	}
 
+	if err := validateFuturesStrategyConfig(strategy.Type, strategy.Config, strategy.Side); err != nil {
+		return nil, err
+	}
+
 	if err := fs.db.Create(strategy).Error; err != nil {
 		return nil, err
 	}
+	recordStrategyCreated(fs.db, MarketFutures, strategy.ID, userID)
 
 	return strategy, nil
 }
 
-func (fs *FuturesService) GetUserFuturesStrategies(userID uint, page, limit int) ([]models.FuturesStrategy, int64, error) {
+func (fs *FuturesService) GetUserFuturesStrategies(userID uint, status models.StrategyStatus, page, limit int) ([]models.FuturesStrategy, int64, error) {
 	var strategies []models.FuturesStrategy
 	var total int64
 
 	query := fs.db.Model(&models.FuturesStrategy{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -138,6 +163,7 @@ func (fs *FuturesService) GetUserFuturesStrategies(userID uint, page, limit int)
 		return nil, 0, err
 	}
 
+	fs.fillLiquidationPrices(userID, strategies)
 	return strategies, total, nil
 }
 
@@ -146,11 +172,13 @@ func (fs *FuturesService) GetFuturesStrategyByID(userID, strategyID uint) (*mode
 	if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
 		return nil, err
 	}
-	return &strategy, nil
+	strategies := []models.FuturesStrategy{strategy}
+	fs.fillLiquidationPrices(userID, strategies)
+	return &strategies[0], nil
 }
 
 func (fs *FuturesService) UpdateFuturesStrategy(userID, strategyID uint, updates map[string]interface{}) error {
-	allowedFields := []string{"name", "is_active", "auto_restart", "take_profit", "stop_loss", "leverage", "margin_type", "config"}
+	allowedFields := []string{"name", "auto_restart", "take_profit", "stop_loss", "leverage", "margin_type", "config", "paper_trading"}
 	filteredUpdates := make(map[string]interface{})
 
 	for field, value := range updates {
@@ -168,59 +196,175 @@ func (fs *FuturesService) UpdateFuturesStrategy(userID, strategyID uint, updates
 		}
 	}
 
-	if len(filteredUpdates) == 0 {
+	// is_active保留兼容，转换为启动或暂停
+	active, hasActive := updates["is_active"].(bool)
+	if len(filteredUpdates) == 0 && !hasActive {
 		return errors.New("没有有效的更新字段")
 	}
 
-	return fs.db.Model(&models.FuturesStrategy{}).Where("id = ? AND user_id = ?", strategyID, userID).Updates(filteredUpdates).Error
+	if _, ok := filteredUpdates["paper_trading"]; ok {
+		var strategy models.FuturesStrategy
+		if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+			return err
+		}
+		if strategy.IsActive {
+			return errors.New("请先停止策略再切换模拟交易模式")
+		}
+	}
+
+	if config, ok := filteredUpdates["config"].(map[string]interface{}); ok {
+		var strategy models.FuturesStrategy
+		if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+			return err
+		}
+		if err := validateFuturesStrategyConfig(strategy.Type, config, strategy.Side); err != nil {
+			return err
+		}
+	}
+
+	if len(filteredUpdates) > 0 {
+		if err := fs.db.Model(&models.FuturesStrategy{}).Where("id = ? AND user_id = ?", strategyID, userID).Updates(filteredUpdates).Error; err != nil {
+			return err
+		}
+	}
+
+	if hasActive {
+		var strategy models.FuturesStrategy
+		if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+			return err
+		}
+		if active != strategy.Status.IsActive() {
+			return transitionFuturesStrategy(fs.db, &strategy, toggleTarget(strategy.Status), models.StrategyActorUser, "更新is_active")
+		}
+	}
+	return nil
 }
 
+// ToggleFuturesStrategy 执行中的策略暂停，其余启动
 func (fs *FuturesService) ToggleFuturesStrategy(userID, strategyID uint) error {
 	var strategy models.FuturesStrategy
 	if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
 		return err
 	}
 
-	return fs.db.Model(&strategy).Update("is_active", !strategy.IsActive).Error
+	return transitionFuturesStrategy(fs.db, &strategy, toggleTarget(strategy.Status), models.StrategyActorUser, "切换启停")
+}
+
+// SetFuturesStrategyStatus 用户启动、暂停或停止期货策略，停止后由调度器撤销挂单，持仓保留
+func (fs *FuturesService) SetFuturesStrategyStatus(userID, strategyID uint, status models.StrategyStatus, reason string) (*models.FuturesStrategy, error) {
+	if err := validateUserStrategyStatus(status); err != nil {
+		return nil, err
+	}
+	var strategy models.FuturesStrategy
+	if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+		return nil, err
+	}
+	if err := transitionFuturesStrategy(fs.db, &strategy, status, models.StrategyActorUser, reason); err != nil {
+		return nil, err
+	}
+	return &strategy, nil
+}
+
+// GetFuturesStrategyStatusHistory 期货策略状态变更历史
+func (fs *FuturesService) GetFuturesStrategyStatusHistory(userID, strategyID uint, page, limit int) ([]models.StrategyStatusHistory, int64, error) {
+	var strategy models.FuturesStrategy
+	if err := fs.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+		return nil, 0, err
+	}
+	return getStrategyStatusHistory(fs.db, MarketFutures, strategyID, page, limit)
 }
 
 func (fs *FuturesService) DeleteFuturesStrategy(userID, strategyID uint) error {
 	return fs.db.Where("id = ? AND user_id = ?", strategyID, userID).Delete(&models.FuturesStrategy{}).Error
 }
 
+// ExecuteFuturesStrategy 执行一轮期货策略并按执行结果更新策略状态
 func (fs *FuturesService) ExecuteFuturesStrategy(strategy *models.FuturesStrategy) error {
-	apiKey, secretKey, err := fs.userService.GetUserAPIKeys(strategy.UserID)
-	if err != nil {
-		return err
-	}
+	err := fs.executeFuturesStrategy(strategy)
+	fs.afterFuturesStrategyExecution(strategy, err)
+	return err
+}
 
-	binanceService, err := NewBinanceService(apiKey, secretKey)
+// executeFuturesStrategy 停止中的策略只撤销挂单，其余按策略类型执行
+func (fs *FuturesService) executeFuturesStrategy(strategy *models.FuturesStrategy) error {
+	exchange, err := fs.userService.GetTradingExchange(strategy.UserID, strategy.PaperTrading)
 	if err != nil {
 		return err
 	}
 
-	if err := binanceService.SetFuturesLeverage(context.Background(), strategy.Symbol, strategy.Leverage); err != nil {
-		log.Printf("设置杠杆失败: %v", err)
+	if strategy.Status == models.StrategyStatusStopping {
+		return fs.finishFuturesStopping(strategy, exchange)
 	}
 
-	if err := binanceService.SetFuturesMarginType(context.Background(), strategy.Symbol, strategy.MarginType); err != nil {
-		log.Printf("设置保证金模式失败: %v", err)
-	}
+	fs.applyFuturesSettings(exchange, strategy)
 
 	switch strategy.Type {
 	case models.StrategySimple:
-		return fs.executeSimpleFuturesStrategy(strategy, binanceService)
+		return fs.executeSimpleFuturesStrategy(strategy, exchange)
 	case models.StrategyIceberg:
-		return fs.executeFuturesIcebergStrategy(strategy, binanceService)
+		return fs.executeFuturesIcebergStrategy(strategy, exchange)
 	case models.StrategySlowIceberg:
-		return fs.executeSlowFuturesIcebergStrategy(strategy, binanceService)
+		return fs.executeSlowFuturesIcebergStrategy(strategy, exchange)
+	case models.StrategyGrid:
+		return fs.executeFuturesGridStrategy(strategy, exchange)
+	case models.StrategyDCA:
+		return fs.executeFuturesDCAStrategy(strategy, exchange)
+	case models.StrategyTWAP, models.StrategyVWAP:
+		return fs.executeFuturesExecutionStrategy(strategy, exchange)
+	case models.StrategyCashCarry:
+		return fs.executeCashCarryStrategy(strategy, exchange)
 	default:
 		return fmt.Errorf("不支持的期货策略类型: %s", strategy.Type)
 	}
 }
 
-func (fs *FuturesService) executeSimpleFuturesStrategy(strategy *models.FuturesStrategy, binanceService *BinanceService) error {
-	currentPrice, err := binanceService.GetFuturesPrice(context.Background(), strategy.Symbol)
+// validateFuturesStrategyConfig 校验网格、DCA、算法执行和期现套利期货策略的配置
+func validateFuturesStrategyConfig(strategyType models.StrategyType, config map[string]interface{}, side models.OrderSide) error {
+	var err error
+	switch strategyType {
+	case models.StrategyGrid:
+		_, err = parseFuturesGridConfig(config, side)
+	case models.StrategyDCA:
+		_, err = parseDCABotConfig(config)
+	case models.StrategyTWAP, models.StrategyVWAP:
+		_, err = parseExecutionConfig(strategyType, config)
+	case models.StrategyCashCarry:
+		if side != models.OrderSideSell {
+			return errors.New("期现套利策略的永续腿只能做空，side必须为sell")
+		}
+		_, err = parseCashCarryConfig(config)
+	}
+	return err
+}
+
+// appliedFuturesSettings 已成功设置的杠杆和保证金模式，策略由价格事件频繁触发时避免重复调用交易所接口
+var appliedFuturesSettings sync.Map
+
+func (fs *FuturesService) applyFuturesSettings(exchange Exchange, strategy *models.FuturesStrategy) {
+	key := fmt.Sprintf("%s:%d:%s", exchange.Name(), strategy.UserID, strategy.Symbol)
+	settings := fmt.Sprintf("%d:%s", strategy.Leverage, strategy.MarginType)
+	if applied, ok := appliedFuturesSettings.Load(key); ok && applied == settings {
+		return
+	}
+
+	ok := true
+	if err := exchange.SetFuturesLeverage(context.Background(), strategy.Symbol, strategy.Leverage); err != nil {
+		log.Printf("设置杠杆失败: %v", err)
+		ok = false
+	}
+
+	if err := exchange.SetFuturesMarginType(context.Background(), strategy.Symbol, strategy.MarginType); err != nil {
+		log.Printf("设置保证金模式失败: %v", err)
+		ok = false
+	}
+
+	if ok {
+		appliedFuturesSettings.Store(key, settings)
+	}
+}
+
+func (fs *FuturesService) executeSimpleFuturesStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
+	currentPrice, err := exchange.GetFuturesPrice(context.Background(), strategy.Symbol)
 	if err != nil {
 		return err
 	}
@@ -260,13 +404,8 @@ func (fs *FuturesService) executeSimpleFuturesStrategy(strategy *models.FuturesS
 	orderValue := strategy.MarginAmount * float64(strategy.Leverage)
 	orderQuantity := orderValue / actualOrderPrice
 
-	// 根据做多/做空设置持仓方向
-	positionSide := models.PositionSideBoth
-	if strategy.Side == models.OrderSideBuy {
-		positionSide = models.PositionSideLong
-	} else {
-		positionSide = models.PositionSideShort
-	}
+	// 根据做多/做空和账户持仓模式设置持仓方向
+	positionSide := fs.futuresPositionSide(strategy, exchange, strategy.Side == models.OrderSideBuy)
 
 	order := &models.FuturesOrder{
 		UserID:        strategy.UserID,
@@ -284,28 +423,26 @@ func (fs *FuturesService) executeSimpleFuturesStrategy(strategy *models.FuturesS
 		order.Type = models.OrderTypeLimit
 	}
 
-	resp, err := binanceService.CreateFuturesOrder(context.Background(), order)
+	resp, err := exchange.CreateFuturesOrder(context.Background(), order)
 	if err != nil {
 		return err
 	}
 
-	order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+	order.OrderID = resp.OrderID
 	order.Status = models.OrderStatus(resp.Status)
 
 	if err := fs.db.Create(order).Error; err != nil {
 		log.Printf("保存期货订单失败: %v", err)
 	}
 
-	if strategy.TakeProfitBP > 0 || strategy.StopLossBP > 0 {
-		go fs.createStopLossAndTakeProfit(strategy, binanceService, actualOrderPrice, orderQuantity)
-	}
+	fs.armProtection(strategy, exchange, actualOrderPrice)
 
-	fs.db.Model(strategy).Update("is_completed", true)
+	fs.completeFuturesStrategy(strategy, "订单已提交")
 
 	return nil
 }
 
-func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.FuturesStrategy, binanceService *BinanceService) error {
+func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
 	config := strategy.Config
 	if config == nil {
 		config = make(map[string]interface{})
@@ -352,7 +489,7 @@ func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.Futures
 	}
 	
 	// 获取当前价格
-	currentPrice, err := binanceService.GetFuturesPrice(context.Background(), strategy.Symbol)
+	currentPrice, err := exchange.GetFuturesPrice(context.Background(), strategy.Symbol)
 	if err != nil {
 		return err
 	}
@@ -375,16 +512,16 @@ func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.Futures
 	
 	// 获取订单簿深度，获取买1价或卖1价
 	basePrice := currentPrice
-	if depth, err := binanceService.GetFuturesDepth(context.Background(), strategy.Symbol, 5); err == nil {
+	if depth, err := exchange.GetFuturesOrderBook(context.Background(), strategy.Symbol, 5); err == nil {
 		if strategy.Side == models.OrderSideBuy && len(depth.Bids) > 0 {
 			// 做多时使用买1价
-			bidPrice, _ := strconv.ParseFloat(depth.Bids[0].Price, 64)
+			bidPrice := depth.Bids[0].Price
 			if bidPrice > 0 {
 				basePrice = bidPrice
 			}
 		} else if strategy.Side == models.OrderSideSell && len(depth.Asks) > 0 {
 			// 做空时使用卖1价
-			askPrice, _ := strconv.ParseFloat(depth.Asks[0].Price, 64)
+			askPrice := depth.Asks[0].Price
 			if askPrice > 0 {
 				basePrice = askPrice
 			}
@@ -400,10 +537,7 @@ func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.Futures
 	priceFloats := fs.getPriceFloats(layers, firstLayerFloat, customPriceFloats)
 	
 	// 设置持仓方向
-	positionSide := models.PositionSideLong
-	if strategy.Side == models.OrderSideSell {
-		positionSide = models.PositionSideShort
-	}
+	positionSide := fs.futuresPositionSide(strategy, exchange, strategy.Side == models.OrderSideBuy)
 	
 	// 创建所有层的订单
 	var orders []*models.FuturesOrder
@@ -437,13 +571,13 @@ func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.Futures
 			TimeInForce:   "GTC",
 		}
 		
-		resp, err := binanceService.CreateFuturesOrder(context.Background(), order)
+		resp, err := exchange.CreateFuturesOrder(context.Background(), order)
 		if err != nil {
 			log.Printf("创建第%d层订单失败: %v", i+1, err)
 			continue
 		}
 		
-		order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+		order.OrderID = resp.OrderID
 		order.Status = models.OrderStatus(resp.Status)
 		orders = append(orders, order)
 		
@@ -452,20 +586,18 @@ func (fs *FuturesService) executeFuturesIcebergStrategy(strategy *models.Futures
 		}
 	}
 	
-	// 设置止盈止损（仅第一次）
-	if strategy.TakeProfitBP > 0 || strategy.StopLossBP > 0 {
-		go fs.createStopLossAndTakeProfit(strategy, binanceService, basePrice, totalQuantity)
-	}
+	// 设置止盈止损，入场成交后按实际持仓挂出
+	fs.armProtection(strategy, exchange, basePrice)
 	
 	// 启动超时监控
 	if timeoutMinutes > 0 && len(orders) > 0 {
-		go fs.monitorIcebergTimeout(strategy, binanceService, orders, timeoutMinutes)
+		go fs.monitorIcebergTimeout(strategy, exchange, orders, timeoutMinutes)
 	}
 	
 	return nil
 }
 
-func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.FuturesStrategy, binanceService *BinanceService) error {
+func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.FuturesStrategy, exchange Exchange) error {
 	config := strategy.Config
 	if config == nil {
 		config = make(map[string]interface{})
@@ -523,12 +655,12 @@ func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.Fut
 	
 	// 如果已完成所有层级
 	if currentLayer >= layers {
-		fs.db.Model(strategy).Update("is_completed", true)
+		fs.completeFuturesStrategy(strategy, "所有层级已完成")
 		return nil
 	}
 	
 	// 获取当前价格
-	currentPrice, err := binanceService.GetFuturesPrice(context.Background(), strategy.Symbol)
+	currentPrice, err := exchange.GetFuturesPrice(context.Background(), strategy.Symbol)
 	if err != nil {
 		return err
 	}
@@ -555,13 +687,13 @@ func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.Fut
 		if err := fs.db.Where("strategy_id = ? AND client_order_id LIKE ?", strategy.ID, fmt.Sprintf("%%_L%d", currentLayer)).
 			Order("created_at desc").First(&lastOrder).Error; err == nil {
 			// 检查订单状态
-			orderStatus, err := binanceService.GetFuturesOrderStatus(context.Background(), strategy.Symbol, lastOrder.OrderID)
+			orderStatus, err := exchange.GetFuturesOrderStatus(context.Background(), strategy.Symbol, lastOrder.OrderID)
 			if err == nil {
 				if orderStatus.Status == "NEW" || orderStatus.Status == "PARTIALLY_FILLED" {
 					// 检查是否超时
 					if time.Since(lastOrder.CreatedAt).Minutes() > float64(timeoutMinutes) {
 						// 撤销订单
-						_, err := binanceService.CancelFuturesOrder(context.Background(), strategy.Symbol, lastOrder.OrderID)
+						_, err := exchange.CancelFuturesOrder(context.Background(), strategy.Symbol, lastOrder.OrderID)
 						if err != nil {
 							log.Printf("撤销第%d层订单失败: %v", currentLayer, err)
 						}
@@ -577,14 +709,14 @@ func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.Fut
 	
 	// 获取订单簿深度，获取买1价或卖1价
 	basePrice := currentPrice
-	if depth, err := binanceService.GetFuturesDepth(context.Background(), strategy.Symbol, 5); err == nil {
+	if depth, err := exchange.GetFuturesOrderBook(context.Background(), strategy.Symbol, 5); err == nil {
 		if strategy.Side == models.OrderSideBuy && len(depth.Bids) > 0 {
-			bidPrice, _ := strconv.ParseFloat(depth.Bids[0].Price, 64)
+			bidPrice := depth.Bids[0].Price
 			if bidPrice > 0 {
 				basePrice = bidPrice
 			}
 		} else if strategy.Side == models.OrderSideSell && len(depth.Asks) > 0 {
-			askPrice, _ := strconv.ParseFloat(depth.Asks[0].Price, 64)
+			askPrice := depth.Asks[0].Price
 			if askPrice > 0 {
 				basePrice = askPrice
 			}
@@ -614,10 +746,7 @@ func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.Fut
 	}
 	
 	// 设置持仓方向
-	positionSide := models.PositionSideLong
-	if strategy.Side == models.OrderSideSell {
-		positionSide = models.PositionSideShort
-	}
+	positionSide := fs.futuresPositionSide(strategy, exchange, strategy.Side == models.OrderSideBuy)
 	
 	order := &models.FuturesOrder{
 		UserID:        strategy.UserID,
@@ -632,12 +761,12 @@ func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.Fut
 		TimeInForce:   "GTC",
 	}
 	
-	resp, err := binanceService.CreateFuturesOrder(context.Background(), order)
+	resp, err := exchange.CreateFuturesOrder(context.Background(), order)
 	if err != nil {
 		return fmt.Errorf("创建第%d层订单失败: %v", currentLayer+1, err)
 	}
 	
-	order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+	order.OrderID = resp.OrderID
 	order.Status = models.OrderStatus(resp.Status)
 	
 	if err := fs.db.Create(order).Error; err != nil {
@@ -651,98 +780,14 @@ func (fs *FuturesService) executeSlowFuturesIcebergStrategy(strategy *models.Fut
 	strategy.State["current_layer"] = currentLayer + 1
 	fs.db.Model(strategy).Update("state", strategy.State)
 	
-	// 设置止盈止损（仅第一层）
-	if currentLayer == 0 && (strategy.TakeProfitBP > 0 || strategy.StopLossBP > 0) {
-		go fs.createStopLossAndTakeProfit(strategy, binanceService, basePrice, totalQuantity)
+	// 设置止盈止损（仅第一层登记，后续层级成交后按新持仓重新挂出）
+	if currentLayer == 0 {
+		fs.armProtection(strategy, exchange, basePrice)
 	}
 	
 	return nil
 }
 
-func (fs *FuturesService) createStopLossAndTakeProfit(strategy *models.FuturesStrategy, binanceService *BinanceService, entryPrice float64, quantity float64) {
-	time.Sleep(5 * time.Second)
-
-	// 根据做多/做空决定平仓方向
-	closeSide := models.OrderSideSell
-	positionSide := models.PositionSideLong
-	if strategy.Side == models.OrderSideSell {
-		closeSide = models.OrderSideBuy
-		positionSide = models.PositionSideShort
-	}
-
-	// 止损订单
-	if strategy.StopLossBP > 0 {
-		stopLossRate := float64(strategy.StopLossBP) / 10000.0
-		var stopLossPrice float64
-
-		if strategy.Side == models.OrderSideBuy {
-			// 做多止损：入场价格 * (1 - 止损万分比)
-			stopLossPrice = entryPrice * (1 - stopLossRate)
-		} else {
-			// 做空止损：入场价格 * (1 + 止损万分比)
-			stopLossPrice = entryPrice * (1 + stopLossRate)
-		}
-
-		stopLossOrder := &models.FuturesOrder{
-			UserID:        strategy.UserID,
-			StrategyID:    &strategy.ID,
-			Symbol:        strategy.Symbol,
-			Side:          closeSide,
-			PositionSide:  positionSide,
-			Type:          models.OrderTypeStopLoss,
-			Quantity:      quantity,
-			StopPrice:     stopLossPrice,
-			ReduceOnly:    true,
-			ClientOrderID: utils.GenerateUUID(),
-		}
-
-		resp, err := binanceService.CreateFuturesOrder(context.Background(), stopLossOrder)
-		if err != nil {
-			log.Printf("创建止损订单失败: %v", err)
-		} else {
-			stopLossOrder.OrderID = strconv.FormatInt(resp.OrderID, 10)
-			stopLossOrder.Status = models.OrderStatus(resp.Status)
-			fs.db.Create(stopLossOrder)
-		}
-	}
-
-	// 止盈订单
-	if strategy.TakeProfitBP > 0 {
-		takeProfitRate := float64(strategy.TakeProfitBP) / 10000.0
-		var takeProfitPrice float64
-
-		if strategy.Side == models.OrderSideBuy {
-			// 做多止盈：入场价格 * (1 + 止盈万分比)
-			takeProfitPrice = entryPrice * (1 + takeProfitRate)
-		} else {
-			// 做空止盈：入场价格 * (1 - 止盈万分比)
-			takeProfitPrice = entryPrice * (1 - takeProfitRate)
-		}
-
-		takeProfitOrder := &models.FuturesOrder{
-			UserID:        strategy.UserID,
-			StrategyID:    &strategy.ID,
-			Symbol:        strategy.Symbol,
-			Side:          closeSide,
-			PositionSide:  positionSide,
-			Type:          models.OrderTypeTakeProfit,
-			Quantity:      quantity,
-			StopPrice:     takeProfitPrice,
-			ReduceOnly:    true,
-			ClientOrderID: utils.GenerateUUID(),
-		}
-
-		resp, err := binanceService.CreateFuturesOrder(context.Background(), takeProfitOrder)
-		if err != nil {
-			log.Printf("创建止盈订单失败: %v", err)
-		} else {
-			takeProfitOrder.OrderID = strconv.FormatInt(resp.OrderID, 10)
-			takeProfitOrder.Status = models.OrderStatus(resp.Status)
-			fs.db.Create(takeProfitOrder)
-		}
-	}
-}
-
 func oppositeOrder(side models.OrderSide) models.OrderSide {
 	if side == models.OrderSideBuy {
 		return models.OrderSideSell
@@ -756,54 +801,69 @@ func (fs *FuturesService) UpdatePositions(userID uint) error {
 		return err
 	}
 
-	binanceService, err := NewBinanceService(apiKey, secretKey)
+	exchange, err := NewExchange(apiKey, secretKey)
 	if err != nil {
 		return err
 	}
-	positions, err := binanceService.GetFuturesPositions(context.Background())
+	positions, err := exchange.GetFuturesPositions(context.Background())
 	if err != nil {
 		return err
 	}
 
+	// 单向持仓模式只有BOTH方向，双向持仓模式分LONG和SHORT，按交易所返回的方向分别保存
+	open := make(map[string]bool)
 	for _, pos := range positions {
-		positionAmt, _ := strconv.ParseFloat(pos.PositionAmt, 64)
-		if positionAmt == 0 {
+		if pos.PositionAmt == 0 {
 			continue
 		}
-
-		entryPrice, _ := strconv.ParseFloat(pos.EntryPrice, 64)
-		markPrice, _ := strconv.ParseFloat(pos.MarkPrice, 64)
-		unRealizedProfit, _ := strconv.ParseFloat(pos.UnRealizedProfit, 64)
-		liquidationPrice, _ := strconv.ParseFloat(pos.LiquidationPrice, 64)
-		leverage, _ := strconv.Atoi(pos.Leverage)
-		maxNotionalValue, _ := strconv.ParseFloat(pos.MaxNotionalValue, 64)
-		isolatedMargin, _ := strconv.ParseFloat(pos.IsolatedMargin, 64)
+		positionSide := strings.ToUpper(pos.PositionSide)
+		if positionSide == "" {
+			positionSide = "BOTH"
+		}
+		open[pos.Symbol+":"+positionSide] = true
 
 		position := &models.FuturesPosition{
 			UserID:           userID,
 			Symbol:           pos.Symbol,
-			PositionSide:     models.PositionSide(pos.PositionSide),
-			PositionAmt:      positionAmt,
-			EntryPrice:       entryPrice,
-			MarkPrice:        markPrice,
-			UnRealizedProfit: unRealizedProfit,
-			LiquidationPrice: liquidationPrice,
-			Leverage:         leverage,
-			MaxNotionalValue: maxNotionalValue,
+			PositionSide:     models.PositionSide(positionSide),
+			PositionAmt:      pos.PositionAmt,
+			EntryPrice:       pos.EntryPrice,
+			MarkPrice:        pos.MarkPrice,
+			UnRealizedProfit: pos.UnrealizedProfit,
+			LiquidationPrice: pos.LiquidationPrice,
+			Leverage:         pos.Leverage,
+			MaxNotionalValue: pos.MaxNotionalValue,
 			MarginType:       models.MarginType(pos.MarginType),
-			IsolatedMargin:   isolatedMargin,
-			IsAutoAddMargin:  pos.IsAutoAddMargin == "true",
+			IsolatedMargin:   pos.IsolatedMargin,
+			IsAutoAddMargin:  pos.IsAutoAddMargin,
 		}
 
+		// 模拟持仓由模拟撮合引擎维护，这里只同步真实持仓
 		var existingPosition models.FuturesPosition
-		if err := fs.db.Where("user_id = ? AND symbol = ? AND position_side = ?",
-			userID, pos.Symbol, pos.PositionSide).First(&existingPosition).Error; err != nil {
+		if err := fs.db.Where("user_id = ? AND symbol = ? AND position_side = ? AND is_simulated = ?",
+			userID, pos.Symbol, positionSide, false).First(&existingPosition).Error; err != nil {
 			fs.db.Create(position)
 		} else {
 			fs.db.Model(&existingPosition).Updates(position)
 		}
 	}
 
+	// 已平仓或切换持仓模式后不再存在的方向清零，孤儿只减仓单清理依赖这里的持仓状态
+	var stale []models.FuturesPosition
+	if err := fs.db.Where("user_id = ? AND is_simulated = ? AND position_amt <> 0", userID, false).Find(&stale).Error; err != nil {
+		return err
+	}
+	for _, position := range stale {
+		if open[position.Symbol+":"+strings.ToUpper(string(position.PositionSide))] {
+			continue
+		}
+		fs.db.Model(&position).Updates(map[string]interface{}{
+			"position_amt":       0,
+			"un_realized_profit": 0,
+			"isolated_margin":    0,
+		})
+	}
+
 	return nil
 }
 
@@ -819,23 +879,68 @@ func (fs *FuturesService) GetFuturesStats(userID uint) (map[string]interface{},
 	var totalStrategies int64
 	var activeStrategies int64
 	var totalOrders int64
-	var totalProfit float64
 
 	fs.db.Model(&models.FuturesStrategy{}).Where("user_id = ?", userID).Count(&totalStrategies)
-	fs.db.Model(&models.FuturesStrategy{}).Where("user_id = ? AND is_active = ?", userID, true).Count(&activeStrategies)
+	fs.db.Model(&models.FuturesStrategy{}).Where("user_id = ? AND status IN ?", userID, models.ActiveStrategyStatuses).Count(&activeStrategies)
 	fs.db.Model(&models.FuturesOrder{}).Where("user_id = ?", userID).Count(&totalOrders)
 
+	// 交易所同步的持仓浮盈，包含系统外下单的持仓
+	var positionProfit float64
 	var positions []models.FuturesPosition
 	fs.db.Where("user_id = ?", userID).Find(&positions)
 	for _, position := range positions {
-		totalProfit += position.UnRealizedProfit
+		positionProfit += position.UnRealizedProfit
+	}
+
+	// 手续费和挂单比例按各交易对的成交明细汇总
+	var trades []models.Trade
+	fs.db.Where("user_id = ? AND market = ?", userID, string(MarketFutures)).Find(&trades)
+
+	bySymbol := make(map[string][]models.Trade)
+	for _, trade := range trades {
+		bySymbol[trade.Symbol] = append(bySymbol[trade.Symbol], trade)
+	}
+	summary := &TradeSummary{Fees: make(map[string]float64)}
+	for symbol, symbolTrades := range bySymbol {
+		mergeTradeSummary(summary, SummarizeFuturesTrades(symbol, symbolTrades))
+	}
+
+	// 盈亏由盈亏账本按当前交易模式计算
+	ledger := NewPnLLedgerService()
+	simulated := ledger.UserSimulated(userID)
+	pnl, err := ledger.Report(PnLQuery{
+		UserID:    userID,
+		Market:    MarketFutures,
+		Simulated: &simulated,
+		Method:    PnLCostFIFO,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 交易所资金流水按类型和资产汇总，模拟交易没有资金流水
+	var income map[string]map[string]float64
+	if !simulated {
+		income, _ = NewFundingService().IncomeSummary(IncomeQuery{UserID: userID})
 	}
 
 	stats := map[string]interface{}{
-		"total_strategies":  totalStrategies,
-		"active_strategies": activeStrategies,
-		"total_orders":      totalOrders,
-		"total_profit":      totalProfit,
+		"total_strategies":        totalStrategies,
+		"active_strategies":       activeStrategies,
+		"total_orders":            totalOrders,
+		"total_profit":            pnl.TotalPnL,
+		"unrealized_pnl":          pnl.UnrealizedPnL,
+		"realized_pnl":            pnl.AllTime.NetPnL,
+		"daily_pnl":               pnl.Daily.NetPnL,
+		"weekly_pnl":              pnl.Weekly.NetPnL,
+		"position_unrealized_pnl": positionProfit,
+		"fees":                    summary.Fees,
+		"fees_in_quote":           pnl.AllTime.Fees,
+		"funding_fees":            pnl.AllTime.Funding,
+		"daily_funding_fees":      pnl.Daily.Funding,
+		"trade_count":             summary.TradeCount,
+		"maker_ratio":             summary.MakerRatio,
+		"income":                  income,
 	}
 
 	return stats, nil
@@ -912,7 +1017,7 @@ func (fs *FuturesService) getPriceFloats(layers int, firstLayerFloat float64, cu
 }
 
 // monitorIcebergTimeout 监控冰山策略订单超时
-func (fs *FuturesService) monitorIcebergTimeout(strategy *models.FuturesStrategy, binanceService *BinanceService, orders []*models.FuturesOrder, timeoutMinutes int) {
+func (fs *FuturesService) monitorIcebergTimeout(strategy *models.FuturesStrategy, exchange Exchange, orders []*models.FuturesOrder, timeoutMinutes int) {
 	// 等待超时时间
 	time.Sleep(time.Duration(timeoutMinutes) * time.Minute)
 	
@@ -921,7 +1026,7 @@ func (fs *FuturesService) monitorIcebergTimeout(strategy *models.FuturesStrategy
 	
 	for _, order := range orders {
 		// 检查订单状态
-		orderStatus, err := binanceService.GetFuturesOrderStatus(ctx, strategy.Symbol, order.OrderID)
+		orderStatus, err := exchange.GetFuturesOrderStatus(ctx, strategy.Symbol, order.OrderID)
 		if err != nil {
 			log.Printf("获取订单%s状态失败: %v", order.OrderID, err)
 			continue
@@ -930,7 +1035,7 @@ func (fs *FuturesService) monitorIcebergTimeout(strategy *models.FuturesStrategy
 		if orderStatus.Status == "NEW" || orderStatus.Status == "PARTIALLY_FILLED" {
 			allCompleted = false
 			// 撤销未完成的订单
-			_, err := binanceService.CancelFuturesOrder(ctx, strategy.Symbol, order.OrderID)
+			_, err := exchange.CancelFuturesOrder(ctx, strategy.Symbol, order.OrderID)
 			if err != nil {
 				log.Printf("撤销订单%s失败: %v", order.OrderID, err)
 			} else {
@@ -941,6 +1046,6 @@ func (fs *FuturesService) monitorIcebergTimeout(strategy *models.FuturesStrategy
 	
 	// 如果所有订单都完成了，标记策略为完成
 	if allCompleted {
-		fs.db.Model(strategy).Update("is_completed", true)
+		fs.completeFuturesStrategy(strategy, "所有订单已结束")
 	}
 }
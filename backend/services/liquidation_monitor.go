@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+// liquidationActionCooldown 同一持仓同类处置的最小间隔，等待交易所持仓数据更新后再判断是否需要继续处置
+const liquidationActionCooldown = 5 * time.Minute
+
+// liquidationDistance 标记价格到强平价格的距离百分比，没有强平风险时返回-1
+func liquidationDistance(position *models.FuturesPosition) float64 {
+	if position.PositionAmt == 0 || position.MarkPrice <= 0 || position.LiquidationPrice <= 0 {
+		return -1
+	}
+	return math.Abs(position.MarkPrice-position.LiquidationPrice) / position.MarkPrice * 100
+}
+
+// MonitorLiquidationRisk 检查全部持仓的强平距离，按用户风控策略追加保证金、从现货划转或只减仓
+func (fs *FuturesService) MonitorLiquidationRisk() error {
+	// 模拟持仓没有交易所推送，先按当前价格重新标记
+	var paperUsers []uint
+	fs.db.Model(&models.FuturesPosition{}).Where("is_simulated = ? AND position_amt <> 0", true).Distinct().Pluck("user_id", &paperUsers)
+	for _, userID := range paperUsers {
+		if _, err := fs.userService.GetPaperExchange(userID).GetFuturesPositions(context.Background()); err != nil {
+			log.Printf("更新用户%d模拟持仓失败: %v", userID, err)
+		}
+	}
+
+	var positions []models.FuturesPosition
+	if err := fs.db.Where("position_amt <> 0 AND liquidation_price > 0").Find(&positions).Error; err != nil {
+		return err
+	}
+
+	policies := make(map[uint]*models.RiskPolicy)
+	exchanges := make(map[string]Exchange)
+	for i := range positions {
+		position := &positions[i]
+		policy, ok := policies[position.UserID]
+		if !ok {
+			policy = LoadRiskPolicy(fs.db, position.UserID)
+			policies[position.UserID] = policy
+		}
+		if !policy.LiquidationGuardEnabled() {
+			continue
+		}
+
+		distance := liquidationDistance(position)
+		if distance < 0 || (distance >= policy.LiqTopUpDistance && distance >= policy.LiqReduceDistance) {
+			continue
+		}
+
+		key := fmt.Sprintf("%d:%t", position.UserID, position.IsSimulated)
+		exchange, ok := exchanges[key]
+		if !ok {
+			var err error
+			if position.IsSimulated {
+				exchange = fs.userService.GetPaperExchange(position.UserID)
+			} else if exchange, err = fs.userService.GetExchange(position.UserID); err != nil {
+				continue
+			}
+			exchanges[key] = exchange
+		}
+
+		log.Printf("用户%d的%s %s持仓距强平%.2f%%（标记价格%.8g，强平价格%.8g）",
+			position.UserID, position.Symbol, position.PositionSide, distance, position.MarkPrice, position.LiquidationPrice)
+		fs.protectPosition(policy, exchange, position, distance)
+	}
+	return nil
+}
+
+// protectPosition 先追加保证金把强平距离拉回目标距离，追加不足时再按比例只减仓
+func (fs *FuturesService) protectPosition(policy *models.RiskPolicy, exchange Exchange, position *models.FuturesPosition, distance float64) {
+	expected := distance
+	if policy.LiqTopUpDistance > 0 && distance < policy.LiqTopUpDistance {
+		if added := fs.topUpMargin(policy, exchange, position, distance); added > 0 {
+			// 追加保证金后强平价按数量等比移动，估算新的强平距离
+			shift := added / math.Abs(position.PositionAmt)
+			expected += shift / position.MarkPrice * 100
+		}
+	}
+
+	if policy.LiqReduceDistance > 0 && policy.LiqReduceRatio > 0 && expected < policy.LiqReduceDistance {
+		fs.reducePosition(policy, exchange, position, distance)
+	}
+}
+
+// topUpMargin 追加保证金，返回实际增加到持仓（逐仓）或期货账户（全仓）的金额
+func (fs *FuturesService) topUpMargin(policy *models.RiskPolicy, exchange Exchange, position *models.FuturesPosition, distance float64) float64 {
+	// 模拟持仓按逐仓模型计算强平价
+	isolated := position.IsSimulated || strings.EqualFold(string(position.MarginType), string(models.MarginTypeIsolated))
+	if !isolated && !policy.LiqTransferFromSpot {
+		return 0
+	}
+	if fs.recentLiquidationAction(position, models.LiquidationActionAddMargin) || fs.recentLiquidationAction(position, models.LiquidationActionTransfer) {
+		return 0
+	}
+
+	target := policy.LiqTargetDistance
+	if target <= policy.LiqTopUpDistance {
+		target = policy.LiqTopUpDistance * 2
+	}
+	qty := math.Abs(position.PositionAmt)
+	targetPrice := position.MarkPrice * (1 - target/100)
+	shift := position.LiquidationPrice - targetPrice
+	if position.PositionAmt < 0 {
+		targetPrice = position.MarkPrice * (1 + target/100)
+		shift = targetPrice - position.LiquidationPrice
+	}
+	amount := shift * qty
+	if policy.LiqMaxTopUp > 0 {
+		amount = math.Min(amount, policy.LiqMaxTopUp)
+	}
+	amount = floorCents(amount)
+	if amount <= 0 {
+		return 0
+	}
+
+	ctx := context.Background()
+	_, quote := splitSymbolBySuffix(position.Symbol)
+	available := 0.0
+	if account, err := exchange.GetFuturesAccountInfo(ctx); err == nil {
+		for _, asset := range account.Assets {
+			if asset.Asset == quote {
+				available = asset.AvailableBalance
+			}
+		}
+		if available == 0 {
+			available = account.AvailableBalance
+		}
+	}
+
+	// 全仓持仓共用期货钱包，划入期货账户即为追加保证金；逐仓持仓在可用余额不足时先补足差额
+	transfer := amount
+	if isolated {
+		transfer = math.Max(0, amount-available)
+	}
+	if transfer > 0 && policy.LiqTransferFromSpot {
+		transfer = fs.transferForMargin(exchange, position, distance, quote, transfer)
+		available += transfer
+	}
+	if !isolated {
+		return transfer
+	}
+
+	amount = floorCents(math.Min(amount, available))
+	if amount <= 0 {
+		fs.recordLiquidationAction(position, distance, models.LiquidationActionAddMargin, 0, "", fmt.Errorf("期货账户%s可用余额不足", quote))
+		return 0
+	}
+	err := exchange.ModifyFuturesIsolatedMargin(ctx, position.Symbol, position.PositionSide, amount)
+	fs.recordLiquidationAction(position, distance, models.LiquidationActionAddMargin, amount, "", err)
+	if err != nil {
+		return 0
+	}
+	return amount
+}
+
+// transferForMargin 从现货划转计价资产到期货账户，现货余额不足时划转全部可用余额，返回实际划转金额
+func (fs *FuturesService) transferForMargin(exchange Exchange, position *models.FuturesPosition, distance float64, asset string, amount float64) float64 {
+	ctx := context.Background()
+	spot, err := exchange.GetAccountInfo(ctx)
+	if err != nil {
+		fs.recordLiquidationAction(position, distance, models.LiquidationActionTransfer, amount, "", err)
+		return 0
+	}
+	free := 0.0
+	for _, balance := range spot.Balances {
+		if balance.Asset == asset {
+			free = balance.Free
+		}
+	}
+	amount = floorCents(math.Min(amount, free))
+	if amount <= 0 {
+		fs.recordLiquidationAction(position, distance, models.LiquidationActionTransfer, 0, "", fmt.Errorf("现货%s可用余额不足", asset))
+		return 0
+	}
+
+	err = exchange.TransferToFutures(ctx, asset, amount)
+	fs.recordLiquidationAction(position, distance, models.LiquidationActionTransfer, amount, "", err)
+	if err != nil {
+		return 0
+	}
+	return amount
+}
+
+// reducePosition 按比例市价只减仓
+func (fs *FuturesService) reducePosition(policy *models.RiskPolicy, exchange Exchange, position *models.FuturesPosition, distance float64) {
+	if fs.recentLiquidationAction(position, models.LiquidationActionReduce) {
+		return
+	}
+
+	qty := math.Abs(position.PositionAmt) * policy.LiqReduceRatio / 100
+	if info, err := exchange.GetFuturesSymbolInfo(position.Symbol); err == nil && info != nil {
+		qty = roundToStep(qty, info.StepSize, "floor")
+		// 按比例取整后低于最小下单量时整笔平仓
+		if qty <= 0 || (info.MinQty > 0 && qty < info.MinQty) {
+			qty = math.Abs(position.PositionAmt)
+		}
+	}
+
+	side := models.OrderSideSell
+	if position.PositionAmt < 0 {
+		side = models.OrderSideBuy
+	}
+	positionSide := models.PositionSide(strings.ToLower(string(position.PositionSide)))
+	if positionSide == "" {
+		positionSide = models.PositionSideBoth
+	}
+	order := &models.FuturesOrder{
+		UserID:       position.UserID,
+		Symbol:       position.Symbol,
+		Side:         side,
+		PositionSide: positionSide,
+		Type:         models.OrderTypeMarket,
+		Quantity:     qty,
+		ReduceOnly:   positionSide == models.PositionSideBoth,
+		IsSimulated:  position.IsSimulated,
+	}
+	result, err := exchange.CreateFuturesOrder(context.Background(), order)
+	if err != nil {
+		fs.recordLiquidationAction(position, distance, models.LiquidationActionReduce, qty, "", err)
+		return
+	}
+	order.OrderID = result.OrderID
+	order.Status = models.OrderStatus(result.Status)
+	order.ExecutedQty = result.ExecutedQty
+	order.CumulativeQuoteQty = result.CumulativeQuoteQty
+	if err := fs.db.Create(order).Error; err != nil {
+		log.Printf("保存强平保护减仓单失败: %v", err)
+	}
+	fs.recordLiquidationAction(position, distance, models.LiquidationActionReduce, order.Quantity, order.OrderID, nil)
+}
+
+// recentLiquidationAction 冷却期内是否已对该持仓成功执行过同类处置
+func (fs *FuturesService) recentLiquidationAction(position *models.FuturesPosition, action models.LiquidationActionType) bool {
+	var count int64
+	fs.db.Model(&models.LiquidationAction{}).
+		Where("user_id = ? AND is_simulated = ? AND symbol = ? AND position_side = ? AND action = ? AND success = ? AND created_at > ?",
+			position.UserID, position.IsSimulated, position.Symbol, position.PositionSide, action, true, time.Now().Add(-liquidationActionCooldown)).
+		Count(&count)
+	return count > 0
+}
+
+// recordLiquidationAction 记录并打印强平保护处置结果
+func (fs *FuturesService) recordLiquidationAction(position *models.FuturesPosition, distance float64, action models.LiquidationActionType, amount float64, orderID string, err error) {
+	record := &models.LiquidationAction{
+		UserID:           position.UserID,
+		IsSimulated:      position.IsSimulated,
+		Symbol:           position.Symbol,
+		PositionSide:     position.PositionSide,
+		MarginType:       position.MarginType,
+		PositionAmt:      position.PositionAmt,
+		MarkPrice:        position.MarkPrice,
+		LiquidationPrice: position.LiquidationPrice,
+		Distance:         distance,
+		Action:           action,
+		Amount:           amount,
+		OrderID:          orderID,
+		Success:          err == nil,
+	}
+	if err != nil {
+		record.Message = err.Error()
+		if len(record.Message) > 255 {
+			record.Message = record.Message[:255]
+		}
+		log.Printf("用户%d的%s %s强平保护[%s %.8g]失败: %v", position.UserID, position.Symbol, position.PositionSide, action, amount, err)
+	} else {
+		log.Printf("用户%d的%s %s强平保护[%s %.8g]已执行", position.UserID, position.Symbol, position.PositionSide, action, amount)
+	}
+	if err := fs.db.Create(record).Error; err != nil {
+		log.Printf("保存强平保护记录失败: %v", err)
+	}
+}
+
+// fillLiquidationPrices 用策略方向对应的持仓填充策略的强平价格
+func (fs *FuturesService) fillLiquidationPrices(userID uint, strategies []models.FuturesStrategy) {
+	if len(strategies) == 0 {
+		return
+	}
+	var positions []models.FuturesPosition
+	if err := fs.db.Where("user_id = ? AND position_amt <> 0", userID).Find(&positions).Error; err != nil {
+		return
+	}
+	userPaper := fs.userService.IsPaperTrading(userID)
+
+	for i := range strategies {
+		strategy := &strategies[i]
+		simulated := strategy.PaperTrading || userPaper
+		long := strategy.Side == models.OrderSideBuy
+		for _, position := range positions {
+			if position.IsSimulated != simulated || position.Symbol != strategy.Symbol {
+				continue
+			}
+			side := strings.ToUpper(string(position.PositionSide))
+			if (long && (side == "LONG" || (side == "BOTH" && position.PositionAmt > 0))) ||
+				(!long && (side == "SHORT" || (side == "BOTH" && position.PositionAmt < 0))) {
+				strategy.LiquidationPrice = position.LiquidationPrice
+				break
+			}
+		}
+	}
+}
+
+// floorCents 保证金和划转金额保留两位小数并向下取整
+func floorCents(amount float64) float64 {
+	return math.Floor(amount*100) / 100
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// MarketType 行情市场
+type MarketType string
+
+const (
+	MarketSpot    MarketType = "spot"
+	MarketFutures MarketType = "futures"
+)
+
+// MarketEventType 行情事件类型
+type MarketEventType string
+
+const (
+	MarketEventBookTicker MarketEventType = "book_ticker"
+	MarketEventDepth      MarketEventType = "depth"
+	MarketEventKline      MarketEventType = "kline"
+)
+
+// MarketEvent 行情推送事件，Price为最新参考价：bookTicker取买一卖一中间价，kline取收盘价，depth事件为0
+type MarketEvent struct {
+	Type        MarketEventType `json:"type"`
+	Market      MarketType      `json:"market"`
+	Symbol      string          `json:"symbol"`
+	Price       float64         `json:"price"`
+	BestBid     float64         `json:"best_bid"`
+	BestAsk     float64         `json:"best_ask"`
+	Depth       *OrderBook      `json:"depth,omitempty"`
+	Kline       *KlineData      `json:"kline,omitempty"`
+	KlineClosed bool            `json:"kline_closed"`
+	Time        int64           `json:"time"`
+}
+
+// MarketEventHandler 行情事件回调，在推送连接的读协程中同步调用，不能阻塞
+type MarketEventHandler func(event *MarketEvent)
+
+// MarketStreamOptions 行情订阅参数
+type MarketStreamOptions struct {
+	KlineInterval string
+	DepthLevels   int
+}
+
+// MarketStreamFactory 建立交易所行情推送连接，返回的done在连接断开时关闭，stop用于主动断开
+type MarketStreamFactory func(market MarketType, symbols []string, opts MarketStreamOptions, handler MarketEventHandler, errHandler func(error)) (<-chan struct{}, func(), error)
+
+var (
+	marketStreamFactories = map[string]MarketStreamFactory{
+		"binance": binanceMarketStream,
+	}
+	marketStreamFactoriesMu sync.RWMutex
+)
+
+// RegisterMarketStream 注册交易所的行情推送实现，未注册的交易所由策略定时轮询行情
+func RegisterMarketStream(name string, factory MarketStreamFactory) {
+	marketStreamFactoriesMu.Lock()
+	defer marketStreamFactoriesMu.Unlock()
+	marketStreamFactories[strings.ToLower(name)] = factory
+}
+
+const (
+	marketStreamMinBackoff    = time.Second
+	marketStreamMaxBackoff    = time.Minute
+	marketStreamStableAfter   = time.Minute      // 连接持续超过该时间后重置退避
+	marketSubscriptionRefresh = 30 * time.Second // 重新计算订阅交易对的周期
+	marketPricePersistEvery   = 2 * time.Second  // 同一交易对写入价格表和Redis的最小间隔
+)
+
+// marketSnapshot 单个交易对的最新行情
+type marketSnapshot struct {
+	price          float64
+	depth          *OrderBook
+	updatedAt      time.Time
+	depthUpdatedAt time.Time
+	persistedAt    time.Time
+}
+
+// MarketDataHub 行情推送中心：为活跃策略引用的交易对订阅bookTicker、depth和kline推送，
+// 断线按指数退避重连，并把行情分发给订阅者、价格表和Redis
+type MarketDataHub struct {
+	db         *gorm.DB
+	factory    MarketStreamFactory
+	opts       MarketStreamOptions
+	enabled    bool
+	staleAfter time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.RWMutex
+	snapshots map[string]*marketSnapshot
+	symbols   map[MarketType][]string
+	streams   map[MarketType]context.CancelFunc
+
+	subMu       sync.RWMutex
+	subscribers []MarketEventHandler
+}
+
+var defaultMarketDataHub atomic.Pointer[MarketDataHub]
+
+// SetDefaultMarketDataHub 设置全局行情中心，交易所实例会优先读取其缓存的行情
+func SetDefaultMarketDataHub(hub *MarketDataHub) {
+	defaultMarketDataHub.Store(hub)
+}
+
+// DefaultMarketDataHub 获取全局行情中心，未设置时返回nil
+func DefaultMarketDataHub() *MarketDataHub {
+	return defaultMarketDataHub.Load()
+}
+
+func NewMarketDataHub(db *gorm.DB) *MarketDataHub {
+	ctx, cancel := context.WithCancel(context.Background())
+	hub := &MarketDataHub{
+		db:         db,
+		opts:       MarketStreamOptions{KlineInterval: "1m", DepthLevels: 20},
+		enabled:    true,
+		staleAfter: 15 * time.Second,
+		ctx:        ctx,
+		cancel:     cancel,
+		snapshots:  make(map[string]*marketSnapshot),
+		symbols:    make(map[MarketType][]string),
+		streams:    make(map[MarketType]context.CancelFunc),
+	}
+
+	name := "binance"
+	if config.AppConfig != nil {
+		marketConfig := config.AppConfig.Market
+		hub.enabled = marketConfig.StreamEnabled
+		if marketConfig.KlineInterval != "" {
+			hub.opts.KlineInterval = marketConfig.KlineInterval
+		}
+		switch marketConfig.DepthLevels {
+		case 5, 10, 20:
+			hub.opts.DepthLevels = marketConfig.DepthLevels
+		}
+		if marketConfig.StaleSeconds > 0 {
+			hub.staleAfter = time.Duration(marketConfig.StaleSeconds) * time.Second
+		}
+		if config.AppConfig.Exchange.Default != "" {
+			name = strings.ToLower(config.AppConfig.Exchange.Default)
+		}
+	}
+
+	marketStreamFactoriesMu.RLock()
+	factory, ok := marketStreamFactories[name]
+	marketStreamFactoriesMu.RUnlock()
+	if !ok {
+		log.Printf("交易所%s未提供行情推送，策略将使用定时轮询", name)
+		hub.enabled = false
+	}
+	hub.factory = factory
+
+	return hub
+}
+
+// Start 启动订阅维护协程
+func (h *MarketDataHub) Start() {
+	if !h.enabled {
+		log.Println("行情推送未启用，策略将使用定时轮询")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(marketSubscriptionRefresh)
+		defer ticker.Stop()
+
+		log.Println("行情推送中心已启动")
+		h.refreshSubscriptions()
+
+		for {
+			select {
+			case <-h.ctx.Done():
+				log.Println("行情推送中心已停止")
+				return
+			case <-ticker.C:
+				h.refreshSubscriptions()
+			}
+		}
+	}()
+}
+
+// Stop 断开所有行情推送
+func (h *MarketDataHub) Stop() {
+	h.cancel()
+}
+
+// Subscribe 注册行情事件回调
+func (h *MarketDataHub) Subscribe(handler MarketEventHandler) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	h.subscribers = append(h.subscribers, handler)
+}
+
+// refreshSubscriptions 按活跃策略重新计算订阅的交易对，有变化时重建对应市场的连接
+func (h *MarketDataHub) refreshSubscriptions() {
+	active, err := ActiveMarketSymbols(h.db)
+	if err != nil {
+		log.Printf("获取活跃策略交易对失败: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, market := range []MarketType{MarketSpot, MarketFutures} {
+		symbols := active[market]
+		if equalStrings(symbols, h.symbols[market]) {
+			continue
+		}
+
+		if cancel, ok := h.streams[market]; ok {
+			cancel()
+			delete(h.streams, market)
+		}
+		h.symbols[market] = symbols
+
+		if len(symbols) == 0 {
+			log.Printf("%s行情推送无订阅交易对，已断开", market)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(h.ctx)
+		h.streams[market] = cancel
+		go h.runStream(ctx, market, symbols)
+	}
+}
+
+// runStream 维持单个市场的推送连接，断开后按指数退避重连
+func (h *MarketDataHub) runStream(ctx context.Context, market MarketType, symbols []string) {
+	backoff := marketStreamMinBackoff
+
+	for {
+		done, stop, err := h.factory(market, symbols, h.opts, h.handleEvent, func(err error) {
+			log.Printf("%s行情推送错误: %v", market, err)
+		})
+		if err != nil {
+			log.Printf("%s行情推送连接失败: %v，%v后重试", market, err, backoff)
+		} else {
+			log.Printf("%s行情推送已连接，订阅%d个交易对", market, len(symbols))
+			connectedAt := time.Now()
+
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case <-done:
+			}
+			stop()
+
+			if time.Since(connectedAt) > marketStreamStableAfter {
+				backoff = marketStreamMinBackoff
+			}
+			log.Printf("%s行情推送已断开，%v后重连", market, backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > marketStreamMaxBackoff {
+			backoff = marketStreamMaxBackoff
+		}
+	}
+}
+
+func (h *MarketDataHub) handleEvent(event *MarketEvent) {
+	key := marketKey(event.Market, event.Symbol)
+	now := time.Now()
+	persist := false
+
+	h.mu.Lock()
+	snapshot, ok := h.snapshots[key]
+	if !ok {
+		snapshot = &marketSnapshot{}
+		h.snapshots[key] = snapshot
+	}
+	if event.Type == MarketEventDepth {
+		snapshot.depth = event.Depth
+		snapshot.depthUpdatedAt = now
+	}
+	if event.Price > 0 {
+		snapshot.price = event.Price
+		snapshot.updatedAt = now
+		if now.Sub(snapshot.persistedAt) >= marketPricePersistEvery {
+			snapshot.persistedAt = now
+			persist = true
+		}
+	}
+	h.mu.Unlock()
+
+	if persist {
+		go RecordPrice(h.db, event.Market, event.Symbol, event.Price)
+	}
+
+	h.subMu.RLock()
+	subscribers := h.subscribers
+	h.subMu.RUnlock()
+	for _, handler := range subscribers {
+		handler(event)
+	}
+}
+
+// GetPrice 获取推送缓存的最新价格，缓存过期时返回false
+func (h *MarketDataHub) GetPrice(market MarketType, symbol string) (float64, bool) {
+	if h == nil {
+		return 0, false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snapshot, ok := h.snapshots[marketKey(market, symbol)]
+	if !ok || snapshot.price <= 0 || time.Since(snapshot.updatedAt) > h.staleAfter {
+		return 0, false
+	}
+	return snapshot.price, true
+}
+
+// GetOrderBook 获取推送缓存的深度，缓存过期或档位不足时返回false
+func (h *MarketDataHub) GetOrderBook(market MarketType, symbol string, limit int) (*OrderBook, bool) {
+	if h == nil {
+		return nil, false
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	snapshot, ok := h.snapshots[marketKey(market, symbol)]
+	if !ok || snapshot.depth == nil || time.Since(snapshot.depthUpdatedAt) > h.staleAfter {
+		return nil, false
+	}
+	if limit <= 0 {
+		limit = h.opts.DepthLevels
+	}
+	if limit > h.opts.DepthLevels {
+		return nil, false
+	}
+
+	depth := snapshot.depth
+	book := &OrderBook{
+		Symbol:       depth.Symbol,
+		LastUpdateID: depth.LastUpdateID,
+		Bids:         append([]PriceLevel(nil), depth.Bids[:min(limit, len(depth.Bids))]...),
+		Asks:         append([]PriceLevel(nil), depth.Asks[:min(limit, len(depth.Asks))]...),
+	}
+	return book, true
+}
+
+// IsLive 交易对是否在订阅中且行情未过期，为false时调用方应回退到轮询
+func (h *MarketDataHub) IsLive(market MarketType, symbol string) bool {
+	_, ok := h.GetPrice(market, symbol)
+	return ok
+}
+
+// Symbols 当前订阅的交易对
+func (h *MarketDataHub) Symbols(market MarketType) []string {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]string(nil), h.symbols[market]...)
+}
+
+// ActiveMarketSymbols 活跃的现货策略和期货策略引用的交易对
+func ActiveMarketSymbols(db *gorm.DB) (map[MarketType][]string, error) {
+	if db == nil {
+		return nil, gorm.ErrInvalidDB
+	}
+
+	var spotSymbols, futuresSymbols []string
+	if err := db.Model(&models.Strategy{}).
+		Where("status IN ?", models.ScheduledStrategyStatuses).
+		Distinct().Pluck("symbol", &spotSymbols).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.FuturesStrategy{}).
+		Where("status IN ?", models.ScheduledStrategyStatuses).
+		Distinct().Pluck("symbol", &futuresSymbols).Error; err != nil {
+		return nil, err
+	}
+	// 期现套利策略同时需要现货价格
+	var carrySymbols []string
+	if err := db.Model(&models.FuturesStrategy{}).
+		Where("status IN ? AND type = ?", models.ScheduledStrategyStatuses, models.StrategyCashCarry).
+		Distinct().Pluck("symbol", &carrySymbols).Error; err != nil {
+		return nil, err
+	}
+	spotSymbols = append(spotSymbols, carrySymbols...)
+
+	// 量化和加权评分策略对配置中的多个交易对评分。加权评分策略由这些交易对的行情触发（见ConfigSymbolStrategies），
+	// 量化策略按update_interval独立调度，订阅只用于读取缓存行情
+	var multiSymbol []models.Strategy
+	if err := db.Select("config").
+		Where("status IN ? AND type IN ?", models.ScheduledStrategyStatuses,
+			[]models.StrategyType{models.StrategyQuantitative, models.StrategyWeightedScoring}).
+		Find(&multiSymbol).Error; err != nil {
+		return nil, err
+	}
+	for _, strategy := range multiSymbol {
+		spotSymbols = append(spotSymbols, configSymbols(strategy.Config)...)
+	}
+
+	return map[MarketType][]string{
+		MarketSpot:    normalizeSymbols(spotSymbols),
+		MarketFutures: normalizeSymbols(futuresSymbols),
+	}, nil
+}
+
+// ConfigSymbolStrategies 配置的symbols中包含该交易对、但策略本身交易对不同的加权评分策略，用于行情事件触发
+func ConfigSymbolStrategies(db *gorm.DB, symbol string) ([]models.Strategy, error) {
+	var candidates []models.Strategy
+	if err := db.Where("type = ? AND status IN ? AND symbol <> ?",
+		models.StrategyWeightedScoring, models.ScheduledStrategyStatuses, symbol).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var strategies []models.Strategy
+	for _, strategy := range candidates {
+		for _, configured := range configSymbols(strategy.Config) {
+			if strings.EqualFold(configured, symbol) {
+				strategies = append(strategies, strategy)
+				break
+			}
+		}
+	}
+	return strategies, nil
+}
+
+// configSymbols 策略配置中的symbols列表
+func configSymbols(config models.StrategyConfig) []string {
+	var symbols []string
+	switch list := config["symbols"].(type) {
+	case []interface{}:
+		for _, item := range list {
+			if symbol, ok := item.(string); ok {
+				symbols = append(symbols, symbol)
+			}
+		}
+	case []string:
+		symbols = list
+	}
+	return symbols
+}
+
+// RecordPrice 写入价格表和Redis缓存，价格表只保存现货价格
+func RecordPrice(db *gorm.DB, market MarketType, symbol string, price float64) {
+	if market == MarketSpot && db != nil {
+		var priceModel models.Price
+		if err := db.Where("symbol = ?", symbol).First(&priceModel).Error; err != nil {
+			priceModel = models.Price{
+				Symbol: symbol,
+				Price:  price,
+			}
+			db.Create(&priceModel)
+		} else {
+			db.Model(&priceModel).Update("price", price)
+		}
+	}
+
+	// 只在Redis可用时缓存价格
+	if config.Redis != nil {
+		key := "price:" + symbol
+		if market == MarketFutures {
+			key = "futures_price:" + symbol
+		}
+		config.Redis.Set(context.Background(), key, price, 5*time.Minute)
+	}
+}
+
+func marketKey(market MarketType, symbol string) string {
+	return string(market) + ":" + strings.ToUpper(symbol)
+}
+
+func normalizeSymbols(symbols []string) []string {
+	seen := make(map[string]bool, len(symbols))
+	result := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		result = append(result, symbol)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// marketDataExchange 优先使用行情推送缓存的价格和深度，缓存失效时回退到交易所REST接口
+type marketDataExchange struct {
+	Exchange
+	hub *MarketDataHub
+}
+
+// WithMarketData 为交易所实例接入全局行情中心，未启用推送时原样返回
+func WithMarketData(exchange Exchange) Exchange {
+	hub := DefaultMarketDataHub()
+	if exchange == nil || hub == nil || !hub.enabled {
+		return exchange
+	}
+	return &marketDataExchange{Exchange: exchange, hub: hub}
+}
+
+func (m *marketDataExchange) GetPrice(ctx context.Context, symbol string) (float64, error) {
+	if price, ok := m.hub.GetPrice(MarketSpot, symbol); ok {
+		return price, nil
+	}
+	return m.Exchange.GetPrice(ctx, symbol)
+}
+
+func (m *marketDataExchange) GetFuturesPrice(ctx context.Context, symbol string) (float64, error) {
+	if price, ok := m.hub.GetPrice(MarketFutures, symbol); ok {
+		return price, nil
+	}
+	return m.Exchange.GetFuturesPrice(ctx, symbol)
+}
+
+func (m *marketDataExchange) GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	if book, ok := m.hub.GetOrderBook(MarketSpot, symbol, limit); ok {
+		return book, nil
+	}
+	return m.Exchange.GetOrderBook(ctx, symbol, limit)
+}
+
+func (m *marketDataExchange) GetFuturesOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	if book, ok := m.hub.GetOrderBook(MarketFutures, symbol, limit); ok {
+		return book, nil
+	}
+	return m.Exchange.GetFuturesOrderBook(ctx, symbol, limit)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+func TestConfigSymbolStrategies(t *testing.T) {
+	db := newTestDB(t, &models.Strategy{})
+	strategies := []models.Strategy{
+		{Name: "configured", Symbol: "BTCUSDT", Type: models.StrategyWeightedScoring, Status: models.StrategyStatusArmed,
+			Config: models.StrategyConfig{"symbols": []interface{}{"btcusdt", "ETHUSDT"}}},
+		{Name: "not configured", Symbol: "BTCUSDT", Type: models.StrategyWeightedScoring, Status: models.StrategyStatusRunning,
+			Config: models.StrategyConfig{"symbols": []interface{}{"SOLUSDT"}}},
+		{Name: "paused", Symbol: "BTCUSDT", Type: models.StrategyWeightedScoring, Status: models.StrategyStatusPaused,
+			Config: models.StrategyConfig{"symbols": []interface{}{"ETHUSDT"}}},
+		{Name: "own symbol", Symbol: "ETHUSDT", Type: models.StrategyWeightedScoring, Status: models.StrategyStatusArmed,
+			Config: models.StrategyConfig{"symbols": []interface{}{"ETHUSDT"}}},
+		{Name: "quantitative", Symbol: "BTCUSDT", Type: models.StrategyQuantitative, Status: models.StrategyStatusArmed,
+			Config: models.StrategyConfig{"symbols": []interface{}{"ETHUSDT"}}},
+	}
+	if err := db.Create(&strategies).Error; err != nil {
+		t.Fatalf("创建策略失败: %v", err)
+	}
+
+	got, err := ConfigSymbolStrategies(db, "ETHUSDT")
+	if err != nil {
+		t.Fatalf("ConfigSymbolStrategies() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "configured" {
+		names := make([]string, len(got))
+		for i, strategy := range got {
+			names[i] = strategy.Name
+		}
+		t.Errorf("ConfigSymbolStrategies(ETHUSDT) = %v, want [configured]", names)
+	}
+}
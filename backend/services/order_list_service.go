@@ -0,0 +1,468 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+// OrderListService 现货OCO订单列表与括号单
+type OrderListService struct {
+	db          *gorm.DB
+	userService *UserService
+	orderSync   *OrderSyncService
+}
+
+func NewOrderListService() *OrderListService {
+	return &OrderListService{
+		db:          config.DB,
+		userService: NewUserService(),
+		orderSync:   NewOrderSyncService(),
+	}
+}
+
+// BracketRequest 括号单参数：入场单成交后按成交数量挂出止盈限价单和止损单组成的OCO
+type BracketRequest struct {
+	Symbol          string           `json:"symbol"`
+	Side            models.OrderSide `json:"side"`
+	Type            models.OrderType `json:"type"` // 入场单类型，market或limit
+	Quantity        float64          `json:"quantity"`
+	Price           float64          `json:"price"`
+	TakeProfitPrice float64          `json:"take_profit_price"`
+	StopPrice       float64          `json:"stop_price"`
+	StopLimitPrice  float64          `json:"stop_limit_price"`
+}
+
+// exchangeFor 按订单是否为模拟订单选择交易所，与用户当前的交易模式无关。不带下单前风控，只用于撤单和查询
+func (ols *OrderListService) exchangeFor(userID uint, simulated bool) (Exchange, error) {
+	if simulated {
+		return ols.userService.GetPaperExchange(userID), nil
+	}
+	return ols.userService.GetExchange(userID)
+}
+
+// placementExchangeFor 下单使用的交易所，接入下单前风控和熔断检查
+func (ols *OrderListService) placementExchangeFor(userID uint, simulated bool) (Exchange, error) {
+	exchange, err := ols.exchangeFor(userID, simulated)
+	if err != nil {
+		return nil, err
+	}
+	return WithPreTradeChecks(ols.db, userID, simulated, exchange), nil
+}
+
+// validateOCOPrices 校验OCO价格关系：卖出时限价高于触发价，买入时限价低于触发价，提供参考价时参考价须位于两者之间
+func validateOCOPrices(side models.OrderSide, price, stopPrice, reference float64) error {
+	if price <= 0 || stopPrice <= 0 {
+		return errors.New("限价和触发价必须大于0")
+	}
+	switch side {
+	case models.OrderSideSell:
+		if price <= stopPrice {
+			return errors.New("卖出OCO的限价必须高于触发价")
+		}
+		if reference > 0 && (reference >= price || reference <= stopPrice) {
+			return fmt.Errorf("卖出OCO要求限价高于当前价格%.8f、触发价低于当前价格", reference)
+		}
+	case models.OrderSideBuy:
+		if price >= stopPrice {
+			return errors.New("买入OCO的限价必须低于触发价")
+		}
+		if reference > 0 && (reference <= price || reference >= stopPrice) {
+			return fmt.Errorf("买入OCO要求限价低于当前价格%.8f、触发价高于当前价格", reference)
+		}
+	default:
+		return ErrInvalidOrderSide
+	}
+	return nil
+}
+
+// CreateOCO 按用户当前的交易模式提交现货OCO订单
+func (ols *OrderListService) CreateOCO(ctx context.Context, userID uint, req *OCORequest) (*models.OrderList, error) {
+	req.Symbol = strings.ToUpper(req.Symbol)
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if err := validateOCOPrices(req.Side, req.Price, req.StopPrice, 0); err != nil {
+		return nil, err
+	}
+
+	simulated := ols.userService.IsPaperTrading(userID)
+	exchange, err := ols.placementExchangeFor(userID, simulated)
+	if err != nil {
+		return nil, errors.New("请先设置API密钥")
+	}
+	return ols.placeOCO(ctx, userID, exchange, simulated, req)
+}
+
+// placeOCO 提交OCO订单并保存订单列表及其中的订单
+func (ols *OrderListService) placeOCO(ctx context.Context, userID uint, exchange Exchange, simulated bool, req *OCORequest) (*models.OrderList, error) {
+	if req.ListClientOrderID == "" {
+		req.ListClientOrderID = utils.GenerateUUID()
+	}
+
+	resp, err := exchange.CreateSpotOCO(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &models.OrderList{
+		UserID:            userID,
+		OrderListID:       resp.OrderListID,
+		ListClientOrderID: req.ListClientOrderID,
+		Symbol:            req.Symbol,
+		ContingencyType:   resp.ContingencyType,
+		Side:              req.Side,
+		Quantity:          req.Quantity,
+		Price:             req.Price,
+		StopPrice:         req.StopPrice,
+		StopLimitPrice:    req.StopLimitPrice,
+		ListStatus:        resp.ListStatusType,
+		ListOrderStatus:   resp.ListOrderStatus,
+		IsSimulated:       simulated,
+	}
+	for _, result := range resp.Orders {
+		list.Orders = append(list.Orders, models.Order{
+			UserID:        userID,
+			Symbol:        req.Symbol,
+			OrderID:       result.OrderID,
+			ClientOrderID: result.ClientOrderID,
+			Side:          req.Side,
+			Type:          models.OrderType(strings.ToLower(result.Type)),
+			Quantity:      req.Quantity,
+			Price:         result.Price,
+			StopPrice:     result.StopPrice,
+			Status:        models.OrderStatus(result.Status),
+			TimeInForce:   "GTC",
+			IsSimulated:   simulated,
+			OrderListID:   resp.OrderListID,
+		})
+	}
+
+	if err := ols.db.Create(list).Error; err != nil {
+		log.Printf("保存OCO订单列表%s失败: %v", list.OrderListID, err)
+		return nil, fmt.Errorf("OCO订单已提交但保存失败: %w", err)
+	}
+	return list, nil
+}
+
+// CancelOCO 撤销用户的OCO订单列表
+func (ols *OrderListService) CancelOCO(ctx context.Context, userID uint, orderListID string) (*models.OrderList, error) {
+	var list models.OrderList
+	if err := ols.db.Where("order_list_id = ? AND user_id = ?", orderListID, userID).First(&list).Error; err != nil {
+		return nil, errors.New("订单列表不存在")
+	}
+	if list.ListOrderStatus == "ALL_DONE" {
+		return nil, errors.New("订单列表已结束，无法撤销")
+	}
+
+	exchange, err := ols.exchangeFor(userID, list.IsSimulated)
+	if err != nil {
+		return nil, errors.New("请先设置API密钥")
+	}
+
+	resp, err := exchange.CancelSpotOCO(ctx, list.Symbol, list.OrderListID)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range resp.Orders {
+		ols.orderSync.applyOrderResult(userID, MarketSpot, result, result.OrderID)
+	}
+	if err := ols.db.Model(&list).Updates(map[string]interface{}{
+		"list_status":       resp.ListStatusType,
+		"list_order_status": resp.ListOrderStatus,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateBracket 提交括号单的入场单，止盈止损OCO在入场单成交后由ProcessOrderLists挂出
+func (ols *OrderListService) CreateBracket(ctx context.Context, userID uint, req *BracketRequest) (*models.BracketOrder, error) {
+	req.Symbol = strings.ToUpper(req.Symbol)
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if req.Type == "" {
+		req.Type = models.OrderTypeMarket
+	}
+	if req.Type != models.OrderTypeMarket && req.Type != models.OrderTypeLimit {
+		return nil, errors.New("入场单类型只支持market或limit")
+	}
+	if req.Type == models.OrderTypeLimit && req.Price <= 0 {
+		return nil, ErrInvalidPrice
+	}
+
+	simulated := ols.userService.IsPaperTrading(userID)
+	exchange, err := ols.placementExchangeFor(userID, simulated)
+	if err != nil {
+		return nil, errors.New("请先设置API密钥")
+	}
+
+	entryPrice := req.Price
+	if req.Type == models.OrderTypeMarket {
+		if entryPrice, err = exchange.GetPrice(ctx, req.Symbol); err != nil {
+			return nil, fmt.Errorf("获取价格失败: %w", err)
+		}
+	}
+	// 离场方向与入场相反，入场价须位于止盈价和触发价之间
+	if err := validateOCOPrices(oppositeOrder(req.Side), req.TakeProfitPrice, req.StopPrice, entryPrice); err != nil {
+		return nil, fmt.Errorf("止盈止损价格无效: %w", err)
+	}
+
+	order := &models.Order{
+		UserID:        userID,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		Type:          req.Type,
+		Quantity:      req.Quantity,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+	if req.Type == models.OrderTypeLimit {
+		order.Price = req.Price
+		order.TimeInForce = "GTC"
+	}
+
+	resp, err := exchange.CreateSpotOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
+	order.IsSimulated = simulated
+
+	bracket := &models.BracketOrder{
+		UserID:          userID,
+		Symbol:          req.Symbol,
+		Side:            req.Side,
+		EntryType:       req.Type,
+		Quantity:        req.Quantity,
+		EntryPrice:      req.Price,
+		EntryOrderID:    order.OrderID,
+		TakeProfitPrice: req.TakeProfitPrice,
+		StopPrice:       req.StopPrice,
+		StopLimitPrice:  req.StopLimitPrice,
+		Status:          models.BracketStatusPending,
+		IsSimulated:     simulated,
+	}
+	err = ols.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		return tx.Create(bracket).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("入场单已提交但保存失败: %w", err)
+	}
+
+	// 市价入场通常立即成交，直接挂出止盈止损
+	if isFinalOrderStatus(resp.Status) {
+		if err := ols.advanceBracket(ctx, exchange, bracket); err != nil {
+			log.Printf("括号单%d挂出止盈止损失败: %v", bracket.ID, err)
+		}
+	}
+	return bracket, nil
+}
+
+// CancelBracket 撤销括号单：入场阶段撤销入场单，持仓阶段撤销止盈止损OCO
+func (ols *OrderListService) CancelBracket(ctx context.Context, userID, bracketID uint) (*models.BracketOrder, error) {
+	var bracket models.BracketOrder
+	if err := ols.db.Where("id = ? AND user_id = ?", bracketID, userID).First(&bracket).Error; err != nil {
+		return nil, errors.New("括号单不存在")
+	}
+
+	switch bracket.Status {
+	case models.BracketStatusPending:
+		exchange, err := ols.exchangeFor(userID, bracket.IsSimulated)
+		if err != nil {
+			return nil, errors.New("请先设置API密钥")
+		}
+		result, err := exchange.CancelSpotOrder(ctx, bracket.Symbol, bracket.EntryOrderID)
+		if err != nil {
+			return nil, err
+		}
+		ols.orderSync.applyOrderResult(userID, MarketSpot, result, bracket.EntryOrderID)
+	case models.BracketStatusActive:
+		if _, err := ols.CancelOCO(ctx, userID, bracket.OrderListID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("括号单已结束，无法撤销")
+	}
+
+	if err := ols.db.Model(&bracket).Updates(map[string]interface{}{
+		"status":  models.BracketStatusCanceled,
+		"message": "用户撤销",
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &bracket, nil
+}
+
+// ProcessOrderLists 推进未结束的括号单并按订单状态结算OCO订单列表
+func (ols *OrderListService) ProcessOrderLists(ctx context.Context) error {
+	var brackets []models.BracketOrder
+	if err := ols.db.Where("status IN ?", []models.BracketStatus{models.BracketStatusPending, models.BracketStatusActive}).
+		Find(&brackets).Error; err != nil {
+		return err
+	}
+
+	for i := range brackets {
+		bracket := &brackets[i]
+		// 入场成交后挂出止盈止损OCO，需经过下单前风控
+		exchange, err := ols.placementExchangeFor(bracket.UserID, bracket.IsSimulated)
+		if err != nil {
+			continue
+		}
+		if err := ols.advanceBracket(ctx, exchange, bracket); err != nil {
+			log.Printf("推进括号单%d失败: %v", bracket.ID, err)
+		}
+	}
+
+	var lists []models.OrderList
+	if err := ols.db.Where("list_order_status <> ?", "ALL_DONE").Find(&lists).Error; err != nil {
+		return err
+	}
+	for i := range lists {
+		if _, err := ols.settleOrderList(&lists[i]); err != nil {
+			log.Printf("结算OCO订单列表%s失败: %v", lists[i].OrderListID, err)
+		}
+	}
+	return nil
+}
+
+// settleOrderList 列表中的订单全部结束后将列表标记为ALL_DONE，返回是否有订单成交
+func (ols *OrderListService) settleOrderList(list *models.OrderList) (bool, error) {
+	var orders []models.Order
+	if err := ols.db.Where("order_list_id = ?", list.OrderListID).Find(&orders).Error; err != nil {
+		return false, err
+	}
+
+	filled := false
+	for _, order := range orders {
+		if !isFinalOrderStatus(string(order.Status)) {
+			return false, nil
+		}
+		if order.ExecutedQty > 0 {
+			filled = true
+		}
+	}
+	if len(orders) == 0 {
+		return false, nil
+	}
+
+	return filled, ols.db.Model(list).Updates(map[string]interface{}{
+		"list_status":       "ALL_DONE",
+		"list_order_status": "ALL_DONE",
+	}).Error
+}
+
+// advanceBracket 入场单结束后按成交数量挂出止盈止损OCO，OCO结束后括号单完成
+func (ols *OrderListService) advanceBracket(ctx context.Context, exchange Exchange, bracket *models.BracketOrder) error {
+	switch bracket.Status {
+	case models.BracketStatusPending:
+		result, err := exchange.GetSpotOrderStatus(ctx, bracket.Symbol, bracket.EntryOrderID)
+		if err != nil {
+			return err
+		}
+		ols.orderSync.applyOrderResult(bracket.UserID, MarketSpot, result, bracket.EntryOrderID)
+		if !isFinalOrderStatus(result.Status) {
+			return nil
+		}
+		if result.ExecutedQty <= 0 {
+			return ols.db.Model(bracket).Updates(map[string]interface{}{
+				"status":  models.BracketStatusCanceled,
+				"message": "入场单未成交: " + result.Status,
+			}).Error
+		}
+
+		// 买入入场的手续费以基础资产扣除时，离场数量需扣除手续费
+		quantity := result.ExecutedQty
+		info, _ := exchange.GetSymbolInfo(bracket.Symbol)
+		base, quote := splitSymbolBySuffix(bracket.Symbol)
+		if info != nil && info.BaseAsset != "" {
+			base, quote = info.BaseAsset, info.QuoteAsset
+		}
+		if bracket.Side == models.OrderSideBuy {
+			baseFee, _ := exchangeOrderFees(exchange, bracket.Symbol, bracket.EntryOrderID, base, quote)
+			quantity -= baseFee
+		}
+		_, quantity = roundGridOrder(info, 0, quantity)
+
+		// 先将括号单标记为持仓阶段，避免调度器和成交推送并发处理时重复挂单
+		claim := ols.db.Model(&models.BracketOrder{}).Where("id = ? AND status = ?", bracket.ID, models.BracketStatusPending).
+			Updates(map[string]interface{}{"status": models.BracketStatusActive, "executed_qty": quantity})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			return claim.Error
+		}
+
+		list, err := ols.placeOCO(ctx, bracket.UserID, exchange, bracket.IsSimulated, &OCORequest{
+			Symbol:         bracket.Symbol,
+			Side:           oppositeOrder(bracket.Side),
+			Quantity:       quantity,
+			Price:          bracket.TakeProfitPrice,
+			StopPrice:      bracket.StopPrice,
+			StopLimitPrice: bracket.StopLimitPrice,
+		})
+		if err != nil {
+			// 入场已成交但止盈止损未挂出，需要人工处理
+			log.Printf("括号单%d入场已成交%.8f，挂出止盈止损失败: %v", bracket.ID, result.ExecutedQty, err)
+			return ols.db.Model(bracket).Updates(map[string]interface{}{
+				"status":       models.BracketStatusFailed,
+				"executed_qty": quantity,
+				"message":      "挂出止盈止损失败: " + err.Error(),
+			}).Error
+		}
+		return ols.db.Model(bracket).Updates(map[string]interface{}{
+			"status":        models.BracketStatusActive,
+			"executed_qty":  quantity,
+			"order_list_id": list.OrderListID,
+		}).Error
+
+	case models.BracketStatusActive:
+		if bracket.OrderListID == "" {
+			// 止盈止损正在挂出
+			return nil
+		}
+		var legs []models.Order
+		if err := ols.db.Where("order_list_id = ?", bracket.OrderListID).Find(&legs).Error; err != nil {
+			return err
+		}
+		for _, leg := range legs {
+			if isFinalOrderStatus(string(leg.Status)) {
+				continue
+			}
+			result, err := exchange.GetSpotOrderStatus(ctx, leg.Symbol, leg.OrderID)
+			if err != nil {
+				return err
+			}
+			ols.orderSync.applyOrderResult(bracket.UserID, MarketSpot, result, leg.OrderID)
+		}
+
+		var list models.OrderList
+		if err := ols.db.Where("order_list_id = ?", bracket.OrderListID).First(&list).Error; err != nil {
+			return err
+		}
+		filled, err := ols.settleOrderList(&list)
+		if err != nil || list.ListOrderStatus != "ALL_DONE" {
+			return err
+		}
+		status, message := models.BracketStatusCompleted, ""
+		if !filled {
+			status, message = models.BracketStatusCanceled, "止盈止损未成交即结束"
+		}
+		return ols.db.Model(bracket).Updates(map[string]interface{}{
+			"status":  status,
+			"message": message,
+		}).Error
+	}
+	return nil
+}
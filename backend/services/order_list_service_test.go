@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+func TestValidateOCOPrices(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      models.OrderSide
+		price     float64
+		stopPrice float64
+		reference float64
+		wantErr   bool
+	}{
+		{"sell take-profit above stop", models.OrderSideSell, 110, 90, 0, false},
+		{"sell with reference between legs", models.OrderSideSell, 110, 90, 100, false},
+		{"sell limit not above stop", models.OrderSideSell, 90, 90, 0, true},
+		{"sell limit below stop", models.OrderSideSell, 80, 90, 0, true},
+		{"sell reference above limit", models.OrderSideSell, 110, 90, 115, true},
+		{"sell reference at stop", models.OrderSideSell, 110, 90, 90, true},
+		{"buy limit below stop", models.OrderSideBuy, 90, 110, 0, false},
+		{"buy with reference between legs", models.OrderSideBuy, 90, 110, 100, false},
+		{"buy limit not below stop", models.OrderSideBuy, 110, 110, 0, true},
+		{"buy reference below limit", models.OrderSideBuy, 90, 110, 85, true},
+		{"buy reference at stop", models.OrderSideBuy, 90, 110, 110, true},
+		{"missing limit price", models.OrderSideSell, 0, 90, 0, true},
+		{"missing stop price", models.OrderSideBuy, 90, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOCOPrices(tt.side, tt.price, tt.stopPrice, tt.reference)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOCOPrices(%s, %v, %v, %v) error = %v, wantErr %v",
+					tt.side, tt.price, tt.stopPrice, tt.reference, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOCOPricesInvalidSide(t *testing.T) {
+	if err := validateOCOPrices(models.OrderSide("HOLD"), 110, 90, 0); !errors.Is(err, ErrInvalidOrderSide) {
+		t.Errorf("validateOCOPrices() error = %v, want ErrInvalidOrderSide", err)
+	}
+}
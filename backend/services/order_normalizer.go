@@ -0,0 +1,280 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/shopspring/decimal"
+)
+
+// ErrOrderRejected 订单按交易对规则无法成为有效订单，在提交交易所之前被拒绝
+var ErrOrderRejected = errors.New("订单不符合交易规则")
+
+// OrderSpec 待提交订单的价格和数量，规范化后原地修改
+type OrderSpec struct {
+	Symbol         string
+	Side           models.OrderSide
+	Market         bool    // 市价单，数量按MARKET_LOT_SIZE校验
+	Price          float64 // 限价，0表示无
+	StopPrice      float64 // 触发价，0表示无
+	Quantity       float64
+	ReduceOnly     bool    // 期货只减仓单不受最小名义价值限制
+	ReferencePrice float64 // 当前价格，用于市价单名义价值和PERCENT_PRICE校验，0表示跳过
+}
+
+// applySymbolFilters 解析交易所的交易对过滤器，现货和期货的字段名略有不同
+func applySymbolFilters(info *SymbolInfo, filters []map[string]interface{}) {
+	value := func(filter map[string]interface{}, key string) float64 {
+		raw, ok := filter[key]
+		if !ok || raw == nil {
+			return 0
+		}
+		parsed, _ := strconv.ParseFloat(fmt.Sprint(raw), 64)
+		return parsed
+	}
+
+	for _, filter := range filters {
+		switch filter["filterType"] {
+		case "PRICE_FILTER":
+			info.MinPrice = value(filter, "minPrice")
+			info.MaxPrice = value(filter, "maxPrice")
+			info.TickSize = value(filter, "tickSize")
+		case "LOT_SIZE":
+			info.MinQty = value(filter, "minQty")
+			info.MaxQty = value(filter, "maxQty")
+			info.StepSize = value(filter, "stepSize")
+		case "MARKET_LOT_SIZE":
+			info.MarketMinQty = value(filter, "minQty")
+			info.MarketMaxQty = value(filter, "maxQty")
+		case "MIN_NOTIONAL":
+			// 现货为minNotional，期货为notional
+			if minNotional := value(filter, "minNotional"); minNotional > 0 {
+				info.MinNotional = minNotional
+			} else {
+				info.MinNotional = value(filter, "notional")
+			}
+		case "NOTIONAL":
+			info.MinNotional = value(filter, "minNotional")
+			info.MaxNotional = value(filter, "maxNotional")
+		case "PERCENT_PRICE":
+			info.BidMultiplierUp = value(filter, "multiplierUp")
+			info.BidMultiplierDown = value(filter, "multiplierDown")
+			info.AskMultiplierUp = info.BidMultiplierUp
+			info.AskMultiplierDown = info.BidMultiplierDown
+		case "PERCENT_PRICE_BY_SIDE":
+			info.BidMultiplierUp = value(filter, "bidMultiplierUp")
+			info.BidMultiplierDown = value(filter, "bidMultiplierDown")
+			info.AskMultiplierUp = value(filter, "askMultiplierUp")
+			info.AskMultiplierDown = value(filter, "askMultiplierDown")
+		}
+	}
+
+	// 现货交易所信息不提供价格和数量精度，按步长推算
+	if info.PricePrecision == 0 && info.TickSize > 0 {
+		info.PricePrecision = stepDecimals(info.TickSize)
+	}
+	if info.QuantityPrecision == 0 && info.StepSize > 0 {
+		info.QuantityPrecision = stepDecimals(info.StepSize)
+	}
+}
+
+// stepDecimals 步长的小数位数，如0.001为3
+func stepDecimals(step float64) int {
+	text := strings.TrimRight(strconv.FormatFloat(step, 'f', -1, 64), "0")
+	if index := strings.IndexByte(text, '.'); index >= 0 {
+		return len(text) - index - 1
+	}
+	return 0
+}
+
+// roundToStep 按步长取整，mode为floor、ceil或round
+func roundToStep(value, step float64, mode string) float64 {
+	if step <= 0 || value <= 0 {
+		return value
+	}
+	steps := decimal.NewFromFloat(value).Div(decimal.NewFromFloat(step))
+	switch mode {
+	case "floor":
+		// 消除浮点误差，避免0.3/0.1被取成2
+		steps = steps.Add(decimal.New(1, -9)).Floor()
+	case "ceil":
+		steps = steps.Sub(decimal.New(1, -9)).Ceil()
+	default:
+		steps = steps.Round(0)
+	}
+	result, _ := steps.Mul(decimal.NewFromFloat(step)).Float64()
+	return result
+}
+
+// NormalizeOrder 按交易对过滤器规范化订单：价格对齐tickSize（买单向下、卖单向上取整，触发价四舍五入），
+// 数量按stepSize向下取整，再校验数量上下限、最小名义价值和PERCENT_PRICE，无法满足时返回ErrOrderRejected
+func NormalizeOrder(info *SymbolInfo, spec *OrderSpec) error {
+	if info == nil {
+		return nil
+	}
+	reject := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s %s", ErrOrderRejected, spec.Symbol, fmt.Sprintf(format, args...))
+	}
+
+	priceMode := "ceil"
+	if spec.Side == models.OrderSideBuy {
+		priceMode = "floor"
+	}
+	if spec.Price > 0 {
+		spec.Price = roundToStep(spec.Price, info.TickSize, priceMode)
+		if spec.Price <= 0 {
+			return reject("价格按最小变动单位%.8g取整后为0", info.TickSize)
+		}
+	}
+	if spec.StopPrice > 0 {
+		spec.StopPrice = roundToStep(spec.StopPrice, info.TickSize, "round")
+	}
+	for _, price := range []float64{spec.Price, spec.StopPrice} {
+		if price <= 0 {
+			continue
+		}
+		if info.MinPrice > 0 && price < info.MinPrice {
+			return reject("价格%.8g低于最低价格%.8g", price, info.MinPrice)
+		}
+		if info.MaxPrice > 0 && price > info.MaxPrice {
+			return reject("价格%.8g高于最高价格%.8g", price, info.MaxPrice)
+		}
+	}
+
+	requested := spec.Quantity
+	spec.Quantity = roundToStep(spec.Quantity, info.StepSize, "floor")
+	minQty, maxQty := info.MinQty, info.MaxQty
+	if spec.Market {
+		if info.MarketMinQty > 0 {
+			minQty = info.MarketMinQty
+		}
+		if info.MarketMaxQty > 0 {
+			maxQty = info.MarketMaxQty
+		}
+	}
+	if spec.Quantity <= 0 || (minQty > 0 && spec.Quantity < minQty) {
+		return reject("数量%.8g按步长%.8g取整后低于最小下单量%.8g", requested, info.StepSize, minQty)
+	}
+	if maxQty > 0 && spec.Quantity > maxQty {
+		return reject("数量%.8g超过单笔最大下单量%.8g", spec.Quantity, maxQty)
+	}
+
+	notionalPrice := spec.Price
+	if notionalPrice <= 0 {
+		notionalPrice = spec.StopPrice
+	}
+	if notionalPrice <= 0 || spec.Market {
+		notionalPrice = spec.ReferencePrice
+	}
+	if notionalPrice > 0 {
+		notional := spec.Quantity * notionalPrice
+		if info.MinNotional > 0 && !spec.ReduceOnly && notional < info.MinNotional {
+			return reject("名义价值%.8g低于最小名义价值%.8g", notional, info.MinNotional)
+		}
+		if info.MaxNotional > 0 && notional > info.MaxNotional {
+			return reject("名义价值%.8g超过最大名义价值%.8g", notional, info.MaxNotional)
+		}
+	}
+
+	if spec.Price > 0 && spec.ReferencePrice > 0 {
+		up, down := info.AskMultiplierUp, info.AskMultiplierDown
+		if spec.Side == models.OrderSideBuy {
+			up, down = info.BidMultiplierUp, info.BidMultiplierDown
+		}
+		if up > 0 && spec.Price > spec.ReferencePrice*up {
+			return reject("价格%.8g高于当前价格%.8g的%.4g倍上限", spec.Price, spec.ReferencePrice, up)
+		}
+		if down > 0 && spec.Price < spec.ReferencePrice*down {
+			return reject("价格%.8g低于当前价格%.8g的%.4g倍下限", spec.Price, spec.ReferencePrice, down)
+		}
+	}
+	return nil
+}
+
+// needsReferencePrice 是否需要当前价格做名义价值或价格偏离校验
+func needsReferencePrice(info *SymbolInfo, spec *OrderSpec) bool {
+	if info == nil {
+		return false
+	}
+	if spec.Price > 0 && (info.BidMultiplierUp > 0 || info.AskMultiplierUp > 0) {
+		return true
+	}
+	return (info.MinNotional > 0 || info.MaxNotional > 0) && (spec.Market || (spec.Price <= 0 && spec.StopPrice <= 0))
+}
+
+// normalizeSpotOrder 规范化现货订单，结果写回订单
+func normalizeSpotOrder(info *SymbolInfo, order *models.Order, referencePrice func() float64) error {
+	spec := &OrderSpec{
+		Symbol:    order.Symbol,
+		Side:      order.Side,
+		Market:    order.Type == models.OrderTypeMarket,
+		Price:     order.Price,
+		StopPrice: order.StopPrice,
+		Quantity:  order.Quantity,
+	}
+	if needsReferencePrice(info, spec) {
+		spec.ReferencePrice = referencePrice()
+	}
+	if err := NormalizeOrder(info, spec); err != nil {
+		return err
+	}
+	order.Price, order.StopPrice, order.Quantity = spec.Price, spec.StopPrice, spec.Quantity
+	return nil
+}
+
+// normalizeFuturesOrder 规范化期货订单，结果写回订单
+func normalizeFuturesOrder(info *SymbolInfo, order *models.FuturesOrder, referencePrice func() float64) error {
+	spec := &OrderSpec{
+		Symbol:     order.Symbol,
+		Side:       order.Side,
+		Market:     order.Type == models.OrderTypeMarket || order.Type == models.OrderTypeTrailingStopMarket,
+		Price:      order.Price,
+		StopPrice:  order.StopPrice,
+		Quantity:   order.Quantity,
+		ReduceOnly: order.ReduceOnly,
+	}
+	if needsReferencePrice(info, spec) {
+		spec.ReferencePrice = referencePrice()
+	}
+	if err := NormalizeOrder(info, spec); err != nil {
+		return err
+	}
+	order.Price, order.StopPrice, order.Quantity = spec.Price, spec.StopPrice, spec.Quantity
+	if order.ActivationPrice > 0 && info != nil {
+		order.ActivationPrice = roundToStep(order.ActivationPrice, info.TickSize, "round")
+	}
+	return nil
+}
+
+// normalizeOCORequest 规范化OCO订单，限价腿与止损腿共用数量
+func normalizeOCORequest(info *SymbolInfo, req *OCORequest, referencePrice float64) error {
+	spec := &OrderSpec{
+		Symbol:         req.Symbol,
+		Side:           req.Side,
+		Price:          req.Price,
+		StopPrice:      req.StopPrice,
+		Quantity:       req.Quantity,
+		ReferencePrice: referencePrice,
+	}
+	if err := NormalizeOrder(info, spec); err != nil {
+		return err
+	}
+	req.Price, req.StopPrice, req.Quantity = spec.Price, spec.StopPrice, spec.Quantity
+
+	if req.StopLimitPrice > 0 {
+		stopLimit := &OrderSpec{
+			Symbol:   req.Symbol,
+			Side:     req.Side,
+			Price:    req.StopLimitPrice,
+			Quantity: req.Quantity,
+		}
+		if err := NormalizeOrder(info, stopLimit); err != nil {
+			return err
+		}
+		req.StopLimitPrice = stopLimit.Price
+	}
+	return nil
+}
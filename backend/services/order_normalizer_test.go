@@ -0,0 +1,177 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+func TestRoundToStep(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float64
+		step  float64
+		mode  string
+		want  float64
+	}{
+		{"floor keeps exact multiple despite float error", 0.3, 0.1, "floor", 0.3},
+		{"floor drops remainder", 1.23456, 0.001, "floor", 1.234},
+		{"ceil rounds up remainder", 1.23401, 0.001, "ceil", 1.235},
+		{"ceil keeps exact multiple", 0.7, 0.1, "ceil", 0.7},
+		{"round to nearest", 100.26, 0.5, "round", 100.5},
+		{"round down to nearest", 100.24, 0.5, "round", 100},
+		{"integer step", 1234.5, 10, "floor", 1230},
+		{"zero step unchanged", 1.23456, 0, "floor", 1.23456},
+		{"non-positive value unchanged", -1.5, 0.1, "floor", -1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roundToStep(tt.value, tt.step, tt.mode); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("roundToStep(%v, %v, %q) = %v, want %v", tt.value, tt.step, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeOrder(t *testing.T) {
+	info := &SymbolInfo{
+		Symbol:            "BTCUSDT",
+		TickSize:          0.1,
+		MinPrice:          1,
+		MaxPrice:          1000000,
+		StepSize:          0.001,
+		MinQty:            0.001,
+		MaxQty:            100,
+		MarketMinQty:      0.01,
+		MarketMaxQty:      10,
+		MinNotional:       10,
+		BidMultiplierUp:   1.1,
+		BidMultiplierDown: 0.9,
+		AskMultiplierUp:   1.2,
+		AskMultiplierDown: 0.8,
+	}
+
+	tests := []struct {
+		name      string
+		spec      OrderSpec
+		rejected  bool
+		wantPrice float64
+		wantStop  float64
+		wantQty   float64
+	}{
+		{
+			name:      "buy limit price floors to tick and quantity floors to step",
+			spec:      OrderSpec{Side: models.OrderSideBuy, Price: 50000.17, Quantity: 0.12345},
+			wantPrice: 50000.1,
+			wantQty:   0.123,
+		},
+		{
+			name:      "sell limit price ceils to tick",
+			spec:      OrderSpec{Side: models.OrderSideSell, Price: 50000.11, Quantity: 0.1},
+			wantPrice: 50000.2,
+			wantQty:   0.1,
+		},
+		{
+			name:     "stop price rounds to nearest tick",
+			spec:     OrderSpec{Side: models.OrderSideSell, StopPrice: 49999.96, Quantity: 0.1},
+			wantStop: 50000,
+			wantQty:  0.1,
+		},
+		{
+			name:     "quantity below minimum after flooring",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Price: 50000, Quantity: 0.0009},
+			rejected: true,
+		},
+		{
+			name:     "quantity above maximum",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Price: 1, Quantity: 150},
+			rejected: true,
+		},
+		{
+			name:     "market order uses market lot size minimum",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Market: true, Quantity: 0.005, ReferencePrice: 50000},
+			rejected: true,
+		},
+		{
+			name:     "market order uses market lot size maximum",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Market: true, Quantity: 20, ReferencePrice: 50000},
+			rejected: true,
+		},
+		{
+			name:     "price below minimum",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Price: 0.5, Quantity: 50},
+			rejected: true,
+		},
+		{
+			name:     "notional below minimum",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Price: 100, Quantity: 0.05},
+			rejected: true,
+		},
+		{
+			name:      "reduce-only order skips minimum notional",
+			spec:      OrderSpec{Side: models.OrderSideSell, Price: 100, Quantity: 0.05, ReduceOnly: true},
+			wantPrice: 100,
+			wantQty:   0.05,
+		},
+		{
+			name:     "market notional uses reference price",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Market: true, Quantity: 0.01, ReferencePrice: 500},
+			rejected: true,
+		},
+		{
+			name:     "buy price above bid multiplier",
+			spec:     OrderSpec{Side: models.OrderSideBuy, Price: 56000, Quantity: 0.1, ReferencePrice: 50000},
+			rejected: true,
+		},
+		{
+			name:      "sell price within ask multiplier",
+			spec:      OrderSpec{Side: models.OrderSideSell, Price: 56000, Quantity: 0.1, ReferencePrice: 50000},
+			wantPrice: 56000,
+			wantQty:   0.1,
+		},
+		{
+			name:     "sell price below ask multiplier",
+			spec:     OrderSpec{Side: models.OrderSideSell, Price: 39000, Quantity: 0.1, ReferencePrice: 50000},
+			rejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.Symbol = info.Symbol
+			err := NormalizeOrder(info, &spec)
+			if tt.rejected {
+				if !errors.Is(err, ErrOrderRejected) {
+					t.Fatalf("NormalizeOrder() error = %v, want ErrOrderRejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeOrder() unexpected error: %v", err)
+			}
+			if math.Abs(spec.Price-tt.wantPrice) > 1e-9 {
+				t.Errorf("price = %v, want %v", spec.Price, tt.wantPrice)
+			}
+			if math.Abs(spec.StopPrice-tt.wantStop) > 1e-9 {
+				t.Errorf("stop price = %v, want %v", spec.StopPrice, tt.wantStop)
+			}
+			if math.Abs(spec.Quantity-tt.wantQty) > 1e-12 {
+				t.Errorf("quantity = %v, want %v", spec.Quantity, tt.wantQty)
+			}
+		})
+	}
+}
+
+func TestNormalizeOrderWithoutSymbolInfo(t *testing.T) {
+	spec := &OrderSpec{Symbol: "BTCUSDT", Side: models.OrderSideBuy, Price: 1.23456, Quantity: 0.00001}
+	if err := NormalizeOrder(nil, spec); err != nil {
+		t.Fatalf("NormalizeOrder(nil) error = %v", err)
+	}
+	if spec.Price != 1.23456 || spec.Quantity != 0.00001 {
+		t.Errorf("spec modified without symbol info: %+v", spec)
+	}
+}
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderUpdate 订单状态变化，来自用户数据流推送或REST轮询对账（轮询时Fill为空）
+type OrderUpdate struct {
+	Market             MarketType       `json:"market"`
+	Symbol             string           `json:"symbol"`
+	OrderID            string           `json:"order_id"`
+	ClientOrderID      string           `json:"client_order_id"`
+	Side               string           `json:"side"`
+	Type               string           `json:"type"`
+	PositionSide       string           `json:"position_side,omitempty"`
+	Status             string           `json:"status"`
+	ExecutionType      string           `json:"execution_type"`
+	ExecutedQty        float64          `json:"executed_qty"`
+	CumulativeQuoteQty float64          `json:"cumulative_quote_qty"`
+	AvgPrice           float64          `json:"avg_price"`
+	ReduceOnly         bool             `json:"reduce_only,omitempty"`
+	Fill               *OrderUpdateFill `json:"fill,omitempty"`
+	UpdateTime         int64            `json:"update_time"`
+}
+
+// OrderUpdateFill 单笔成交
+type OrderUpdateFill struct {
+	TradeID         string  `json:"trade_id"`
+	Price           float64 `json:"price"`
+	Quantity        float64 `json:"quantity"`
+	QuoteQty        float64 `json:"quote_qty"`
+	Commission      float64 `json:"commission"`
+	CommissionAsset string  `json:"commission_asset"`
+	IsMaker         bool    `json:"is_maker"`
+	RealizedPnL     float64 `json:"realized_pnl"`
+	Time            int64   `json:"time"`
+}
+
+// AppliedOrderUpdate 订单更新落库结果，供订阅者判断是否需要驱动策略
+type AppliedOrderUpdate struct {
+	UserID      uint
+	StrategyID  *uint
+	Market      MarketType
+	Symbol      string
+	OrderID     string
+	Status      string
+	FilledDelta float64 // 本次新增成交数量
+}
+
+// OrderSyncService 将交易所推送或轮询得到的订单状态写入orders、futures_orders、trades和策略状态
+type OrderSyncService struct {
+	db *gorm.DB
+}
+
+func NewOrderSyncService() *OrderSyncService {
+	return &OrderSyncService{db: config.DB}
+}
+
+// isFinalOrderStatus 订单是否已处于终态
+func isFinalOrderStatus(status string) bool {
+	switch strings.ToUpper(status) {
+	case "FILLED", "CANCELED", "EXPIRED", "REJECTED", "EXPIRED_IN_MATCH":
+		return true
+	}
+	return false
+}
+
+// ApplyOrderUpdate 按用户应用订单更新。系统外创建的订单返回nil；乱序到达的旧事件不会回退已成交数量和终态
+func (oss *OrderSyncService) ApplyOrderUpdate(userID uint, update *OrderUpdate) (*AppliedOrderUpdate, error) {
+	if update == nil || update.OrderID == "" {
+		return nil, nil
+	}
+
+	var applied *AppliedOrderUpdate
+	err := oss.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if update.Market == MarketFutures {
+			applied, err = oss.applyFuturesOrderUpdate(tx, userID, update)
+		} else {
+			applied, err = oss.applySpotOrderUpdate(tx, userID, update)
+		}
+		return err
+	})
+	return applied, err
+}
+
+func (oss *OrderSyncService) applySpotOrderUpdate(tx *gorm.DB, userID uint, update *OrderUpdate) (*AppliedOrderUpdate, error) {
+	var order models.Order
+	if err := tx.Where("user_id = ? AND order_id = ?", userID, update.OrderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	previousQty := order.ExecutedQty
+	status, executedQty, quoteQty := mergeOrderProgress(string(order.Status), order.ExecutedQty, order.CumulativeQuoteQty, update)
+	if err := tx.Model(&order).Updates(map[string]interface{}{
+		"status":               status,
+		"executed_qty":         executedQty,
+		"cumulative_quote_qty": quoteQty,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if update.Fill != nil {
+		if err := recordTrade(tx, userID, order.StrategyID, MarketSpot, order.OrderID, order.Symbol, string(order.Side), "", update.Fill); err != nil {
+			return nil, err
+		}
+	}
+
+	applied := &AppliedOrderUpdate{
+		UserID:      userID,
+		StrategyID:  order.StrategyID,
+		Market:      MarketSpot,
+		Symbol:      order.Symbol,
+		OrderID:     order.OrderID,
+		Status:      status,
+		FilledDelta: executedQty - previousQty,
+	}
+
+	if order.StrategyID != nil && applied.FilledDelta > 0 {
+		if err := applySpotStrategyFill(tx, *order.StrategyID, applied); err != nil {
+			return nil, err
+		}
+	}
+	return applied, nil
+}
+
+func (oss *OrderSyncService) applyFuturesOrderUpdate(tx *gorm.DB, userID uint, update *OrderUpdate) (*AppliedOrderUpdate, error) {
+	var order models.FuturesOrder
+	if err := tx.Where("user_id = ? AND order_id = ?", userID, update.OrderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	previousQty := order.ExecutedQty
+	status, executedQty, quoteQty := mergeOrderProgress(string(order.Status), order.ExecutedQty, order.CumulativeQuoteQty, update)
+	if err := tx.Model(&order).Updates(map[string]interface{}{
+		"status":               status,
+		"executed_qty":         executedQty,
+		"cumulative_quote_qty": quoteQty,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	if update.Fill != nil {
+		if err := recordTrade(tx, userID, order.StrategyID, MarketFutures, order.OrderID, order.Symbol, string(order.Side), string(order.PositionSide), update.Fill); err != nil {
+			return nil, err
+		}
+	}
+
+	applied := &AppliedOrderUpdate{
+		UserID:      userID,
+		StrategyID:  order.StrategyID,
+		Market:      MarketFutures,
+		Symbol:      order.Symbol,
+		OrderID:     order.OrderID,
+		Status:      status,
+		FilledDelta: executedQty - previousQty,
+	}
+
+	if order.StrategyID != nil && applied.FilledDelta > 0 {
+		if err := applyFuturesStrategyFill(tx, *order.StrategyID, applied, update); err != nil {
+			return nil, err
+		}
+	}
+	return applied, nil
+}
+
+// mergeOrderProgress 合并订单进度：已成交数量只增不减，终态不被非终态覆盖
+func mergeOrderProgress(currentStatus string, currentQty, currentQuoteQty float64, update *OrderUpdate) (string, float64, float64) {
+	status := update.Status
+	if isFinalOrderStatus(currentStatus) && !isFinalOrderStatus(status) {
+		status = currentStatus
+	}
+
+	executedQty, quoteQty := currentQty, currentQuoteQty
+	if update.ExecutedQty > currentQty {
+		executedQty = update.ExecutedQty
+		quoteQty = update.CumulativeQuoteQty
+	}
+	return status, executedQty, quoteQty
+}
+
+// recordTrade 写入成交明细，同一成交重复推送时忽略
+func recordTrade(tx *gorm.DB, userID uint, strategyID *uint, market MarketType, orderID, symbol, side, positionSide string, fill *OrderUpdateFill) error {
+	if fill.TradeID == "" || fill.Quantity <= 0 {
+		return nil
+	}
+
+	tradeTime := time.Now()
+	if fill.Time > 0 {
+		tradeTime = time.UnixMilli(fill.Time)
+	}
+	quoteQty := fill.QuoteQty
+	if quoteQty <= 0 {
+		quoteQty = fill.Price * fill.Quantity
+	}
+
+	trade := &models.Trade{
+		UserID:          userID,
+		StrategyID:      strategyID,
+		Market:          string(market),
+		OrderID:         orderID,
+		TradeID:         fill.TradeID,
+		Symbol:          symbol,
+		Side:            models.OrderSide(strings.ToLower(side)),
+		PositionSide:    strings.ToLower(positionSide),
+		Price:           fill.Price,
+		Quantity:        fill.Quantity,
+		QuoteQty:        quoteQty,
+		Commission:      fill.Commission,
+		CommissionAsset: fill.CommissionAsset,
+		RealizedPnL:     fill.RealizedPnL,
+		IsBuyer:         strings.EqualFold(side, "buy"),
+		IsMaker:         fill.IsMaker,
+		TradeTime:       tradeTime,
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(trade).Error
+}
+
+// tradeBackfillWindow 只为该时间内更新过的订单补录成交
+const tradeBackfillWindow = 7 * 24 * time.Hour
+
+// tradeBackfillMaxAttempts 补录尝试达到该次数后不再处理，避免查询不到成交的订单反复占用每轮名额
+const tradeBackfillMaxAttempts = 10
+
+// paperTradeIDPrefix 模拟成交的成交ID前缀，统计时据此区分模拟成交和真实成交
+const paperTradeIDPrefix = "paper-"
+
+// tradeBackfillOrder 需要补录成交的订单
+type tradeBackfillOrder struct {
+	UserID       uint
+	StrategyID   *uint
+	OrderID      string
+	Symbol       string
+	Side         string
+	PositionSide string
+	IsSimulated  bool
+}
+
+// BackfillTrades 为已成交数量大于已记录成交的订单，通过交易所成交查询（现货myTrades、期货userTrades）补录成交明细，
+// 返回补录涉及的订单数。数据流推送的成交已写入trades，这里只处理轮询发现的成交和断线期间漏掉的推送
+func (oss *OrderSyncService) BackfillTrades(market MarketType, limit int, exchangeFor func(userID uint, simulated bool) (Exchange, error)) int {
+	// 现货订单表没有持仓方向字段
+	table, columns := "orders", "user_id, strategy_id, order_id, symbol, side, is_simulated"
+	if market == MarketFutures {
+		table, columns = "futures_orders", "user_id, strategy_id, order_id, symbol, side, position_side, is_simulated"
+	}
+
+	var orders []tradeBackfillOrder
+	err := oss.db.Table(table).Select(columns).
+		Where(table+".deleted_at IS NULL AND executed_qty > 0 AND updated_at > ? AND backfill_attempts < ?", time.Now().Add(-tradeBackfillWindow), tradeBackfillMaxAttempts).
+		Where("executed_qty - COALESCE((SELECT SUM(t.quantity) FROM trades t WHERE t.market = ? AND t.order_id = "+table+".order_id AND t.symbol = "+table+".symbol AND t.user_id = "+table+".user_id AND t.deleted_at IS NULL), 0) > ?", string(market), 0.000000005).
+		Order("backfill_attempts, updated_at").Limit(limit).
+		Scan(&orders).Error
+	if err != nil {
+		log.Printf("查询待补录成交的%s订单失败: %v", market, err)
+		return 0
+	}
+
+	type exchangeKey struct {
+		userID    uint
+		simulated bool
+	}
+	exchanges := make(map[exchangeKey]Exchange)
+
+	count := 0
+	for _, order := range orders {
+		// 先记录尝试次数，尝试较少的订单下一轮优先；不更新updated_at，以免延长补录窗口
+		if err := oss.db.Table(table).Where("user_id = ? AND symbol = ? AND order_id = ?", order.UserID, order.Symbol, order.OrderID).
+			UpdateColumn("backfill_attempts", gorm.Expr("backfill_attempts + 1")).Error; err != nil {
+			log.Printf("记录订单%s补录次数失败: %v", order.OrderID, err)
+		}
+
+		key := exchangeKey{order.UserID, order.IsSimulated}
+		exchange, ok := exchanges[key]
+		if !ok {
+			exchange, err = exchangeFor(order.UserID, order.IsSimulated)
+			if err != nil {
+				exchange = nil
+			}
+			exchanges[key] = exchange
+		}
+		if exchange == nil {
+			continue
+		}
+
+		var fills []OrderFill
+		if market == MarketFutures {
+			fills, err = exchange.GetFuturesOrderTrades(context.Background(), order.Symbol, order.OrderID)
+		} else {
+			fills, err = exchange.GetSpotOrderTrades(context.Background(), order.Symbol, order.OrderID)
+		}
+		if err != nil {
+			log.Printf("查询订单%s成交明细失败: %v", order.OrderID, err)
+			continue
+		}
+
+		err = oss.db.Transaction(func(tx *gorm.DB) error {
+			for _, fill := range fills {
+				tradeID := strconv.FormatInt(fill.TradeID, 10)
+				if order.IsSimulated {
+					// 模拟成交ID取自模拟订单主键，加前缀避免与真实成交冲突
+					tradeID = paperTradeIDPrefix + tradeID
+				}
+				if err := recordTrade(tx, order.UserID, order.StrategyID, market, order.OrderID, order.Symbol, order.Side, order.PositionSide, &OrderUpdateFill{
+					TradeID:         tradeID,
+					Price:           fill.Price,
+					Quantity:        fill.Quantity,
+					QuoteQty:        fill.QuoteQty,
+					Commission:      fill.Commission,
+					CommissionAsset: fill.CommissionAsset,
+					IsMaker:         fill.IsMaker,
+					RealizedPnL:     fill.RealizedPnL,
+					Time:            fill.Time,
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("补录订单%s成交明细失败: %v", order.OrderID, err)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// applySpotStrategyFill 按新增成交量更新现货策略状态
+func applySpotStrategyFill(tx *gorm.DB, strategyID uint, applied *AppliedOrderUpdate) error {
+	var strategy models.Strategy
+	if err := tx.First(&strategy, strategyID).Error; err != nil {
+		return nil
+	}
+
+	if strategy.Type != models.StrategySlowIceberg || strategy.State == nil {
+		return nil
+	}
+	strategyState := strategy.State
+
+	if applied.Status == "FILLED" {
+		// 订单完全成交，当前层已成交数量清零
+		strategyState["layer_filled_quantity"] = 0.0
+	} else {
+		currentLayerFilled, _ := strategyState["layer_filled_quantity"].(float64)
+		strategyState["layer_filled_quantity"] = currentLayerFilled + applied.FilledDelta
+	}
+
+	totalFilled, _ := strategyState["total_filled_quantity"].(float64)
+	strategyState["total_filled_quantity"] = totalFilled + applied.FilledDelta
+
+	return tx.Model(&strategy).Update("state", strategyState).Error
+}
+
+// applyFuturesStrategyFill 按新增成交量累计期货策略的成交数量和最新成交价
+func applyFuturesStrategyFill(tx *gorm.DB, strategyID uint, applied *AppliedOrderUpdate, update *OrderUpdate) error {
+	var strategy models.FuturesStrategy
+	if err := tx.First(&strategy, strategyID).Error; err != nil {
+		return nil
+	}
+
+	if strategy.State == nil {
+		strategy.State = make(models.StrategyState)
+	}
+	totalFilled, _ := strategy.State["total_filled_quantity"].(float64)
+	strategy.State["total_filled_quantity"] = totalFilled + applied.FilledDelta
+	if update.Fill != nil && update.Fill.Price > 0 {
+		strategy.State["last_fill_price"] = update.Fill.Price
+	} else if update.AvgPrice > 0 {
+		strategy.State["last_fill_price"] = update.AvgPrice
+	}
+
+	return tx.Model(&strategy).Update("state", strategy.State).Error
+}
+
+// ReconcileOpenOrders 通过REST查询对账用户的未完成订单，simulated区分模拟订单，staleAfter大于0时只检查超过该时间未更新的订单
+func (oss *OrderSyncService) ReconcileOpenOrders(userID uint, market MarketType, simulated bool, exchange Exchange, staleAfter time.Duration) []*AppliedOrderUpdate {
+	openStatuses := []string{"NEW", "PARTIALLY_FILLED"}
+	var results []*AppliedOrderUpdate
+
+	if market == MarketFutures {
+		query := oss.db.Where("user_id = ? AND status IN ? AND is_simulated = ?", userID, openStatuses, simulated)
+		if staleAfter > 0 {
+			query = query.Where("updated_at < ?", time.Now().Add(-staleAfter))
+		}
+		var orders []models.FuturesOrder
+		if err := query.Find(&orders).Error; err != nil {
+			log.Printf("查询用户%d期货挂单失败: %v", userID, err)
+			return nil
+		}
+
+		for _, order := range orders {
+			orderStatus, err := exchange.GetFuturesOrderStatus(context.Background(), order.Symbol, order.OrderID)
+			if err != nil {
+				log.Printf("检查期货订单状态失败: %v", err)
+				continue
+			}
+			if applied := oss.applyOrderResult(userID, MarketFutures, orderStatus, order.OrderID); applied != nil {
+				results = append(results, applied)
+			}
+		}
+		return results
+	}
+
+	query := oss.db.Where("user_id = ? AND status IN ? AND is_simulated = ?", userID, openStatuses, simulated)
+	if staleAfter > 0 {
+		query = query.Where("updated_at < ?", time.Now().Add(-staleAfter))
+	}
+	var orders []models.Order
+	if err := query.Find(&orders).Error; err != nil {
+		log.Printf("查询用户%d挂单失败: %v", userID, err)
+		return nil
+	}
+
+	for _, order := range orders {
+		orderStatus, err := exchange.GetSpotOrderStatus(context.Background(), order.Symbol, order.OrderID)
+		if err != nil {
+			log.Printf("检查订单状态失败: %v", err)
+			continue
+		}
+		if applied := oss.applyOrderResult(userID, MarketSpot, orderStatus, order.OrderID); applied != nil {
+			results = append(results, applied)
+		}
+	}
+	return results
+}
+
+// applyOrderResult 将轮询得到的订单状态按订单更新落库，未变化时也刷新updated_at以免重复对账
+func (oss *OrderSyncService) applyOrderResult(userID uint, market MarketType, result *OrderResult, orderID string) *AppliedOrderUpdate {
+	update := &OrderUpdate{
+		Market:             market,
+		Symbol:             result.Symbol,
+		OrderID:            orderID,
+		Status:             result.Status,
+		ExecutedQty:        result.ExecutedQty,
+		CumulativeQuoteQty: result.CumulativeQuoteQty,
+		AvgPrice:           result.AvgPrice,
+		UpdateTime:         result.UpdateTime,
+	}
+	if update.CumulativeQuoteQty <= 0 && update.AvgPrice > 0 {
+		update.CumulativeQuoteQty = update.ExecutedQty * update.AvgPrice
+	}
+
+	applied, err := oss.ApplyOrderUpdate(userID, update)
+	if err != nil {
+		log.Printf("更新订单%s状态失败: %v", orderID, err)
+		return nil
+	}
+	return applied
+}
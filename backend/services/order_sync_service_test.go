@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+// fillsExchange 按订单返回固定成交明细
+type fillsExchange struct {
+	Exchange
+	fills map[string][]OrderFill
+}
+
+func (e *fillsExchange) GetSpotOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	return e.fills[symbol+"/"+orderID], nil
+}
+
+func TestBackfillTradesMatchesOrderSymbol(t *testing.T) {
+	db := newTestDB(t, &models.Order{}, &models.Trade{})
+	order := models.Order{UserID: 1, Symbol: "BTCUSDT", OrderID: "1001", Side: models.OrderSideBuy, ExecutedQty: 0.5, Status: "FILLED"}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatalf("创建订单失败: %v", err)
+	}
+	// 其他交易对、其他用户的成交使用相同的订单ID，不能算作该订单的成交
+	for _, trade := range []models.Trade{
+		{UserID: 1, Market: string(MarketSpot), OrderID: "1001", TradeID: "1", Symbol: "ETHUSDT", Quantity: 0.5, TradeTime: time.Now()},
+		{UserID: 2, Market: string(MarketSpot), OrderID: "1001", TradeID: "2", Symbol: "BTCUSDT", Quantity: 0.5, TradeTime: time.Now()},
+	} {
+		if err := db.Create(&trade).Error; err != nil {
+			t.Fatalf("创建成交失败: %v", err)
+		}
+	}
+
+	exchange := &fillsExchange{fills: map[string][]OrderFill{
+		"BTCUSDT/1001": {{TradeID: 3, Price: 50000, Quantity: 0.5, QuoteQty: 25000, Time: time.Now().UnixMilli()}},
+	}}
+	oss := &OrderSyncService{db: db}
+	if got := oss.BackfillTrades(MarketSpot, 10, func(uint, bool) (Exchange, error) { return exchange, nil }); got != 1 {
+		t.Fatalf("BackfillTrades() = %d, want 1", got)
+	}
+
+	var recorded int64
+	db.Model(&models.Trade{}).Where("user_id = ? AND symbol = ? AND order_id = ?", 1, "BTCUSDT", "1001").Count(&recorded)
+	if recorded != 1 {
+		t.Errorf("recorded trades for order = %d, want 1", recorded)
+	}
+
+	// 补录完成后不再重复处理
+	if got := oss.BackfillTrades(MarketSpot, 10, func(uint, bool) (Exchange, error) { return exchange, nil }); got != 0 {
+		t.Errorf("second BackfillTrades() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+// venueFill 订单结束时的成交情况，手续费按基础资产和计价资产拆分
+type venueFill struct {
+	Filled   bool // 是否完全成交
+	Executed float64
+	QuoteQty float64
+	BaseFee  float64
+	QuoteFee float64
+}
+
+// orderVenue 按成交推进的策略（DCA机器人、TWAP/VWAP）下单的市场，现货和期货分别实现
+type orderVenue interface {
+	price() (float64, error)
+	symbolInfo() *SymbolInfo
+	defaultQuantity(price float64) float64
+	placeOrder(side models.OrderSide, orderType models.OrderType, price, quantity float64, reduceOnly bool) (string, error)
+	cancelOrder(orderID string) error
+	klines(interval string, limit int) ([]KlineData, error)
+	// orderFill 订单未结束时返回nil，订单记录不存在时返回空成交
+	orderFill(orderID string) (*venueFill, error)
+}
+
+// spotOrderVenue 现货市场，默认下单数量为strategy.Quantity
+type spotOrderVenue struct {
+	ss          *StrategyService
+	strategy    *models.Strategy
+	exchange    Exchange
+	info        *SymbolInfo
+	base, quote string
+}
+
+func newSpotOrderVenue(ss *StrategyService, strategy *models.Strategy, exchange Exchange) *spotOrderVenue {
+	venue := &spotOrderVenue{ss: ss, strategy: strategy, exchange: exchange}
+	venue.base, venue.quote = splitSymbolBySuffix(strings.ToUpper(strategy.Symbol))
+	if info, err := exchange.GetSymbolInfo(strategy.Symbol); err == nil {
+		venue.info = info
+		if info.BaseAsset != "" {
+			venue.base, venue.quote = info.BaseAsset, info.QuoteAsset
+		}
+	}
+	return venue
+}
+
+func (v *spotOrderVenue) price() (float64, error) {
+	return v.exchange.GetPrice(context.Background(), v.strategy.Symbol)
+}
+
+func (v *spotOrderVenue) symbolInfo() *SymbolInfo { return v.info }
+
+func (v *spotOrderVenue) defaultQuantity(price float64) float64 { return v.strategy.Quantity }
+
+func (v *spotOrderVenue) placeOrder(side models.OrderSide, orderType models.OrderType, price, quantity float64, reduceOnly bool) (string, error) {
+	order := &models.Order{
+		UserID:        v.strategy.UserID,
+		StrategyID:    &v.strategy.ID,
+		Symbol:        v.strategy.Symbol,
+		Side:          side,
+		Type:          orderType,
+		Quantity:      quantity,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+	if orderType == models.OrderTypeLimit {
+		order.Price = price
+		order.TimeInForce = "GTC"
+	}
+
+	resp, err := v.exchange.CreateSpotOrder(context.Background(), order)
+	if err != nil {
+		return "", err
+	}
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
+	if err := v.ss.db.Create(order).Error; err != nil {
+		log.Printf("保存订单失败: %v", err)
+	}
+	return order.OrderID, nil
+}
+
+func (v *spotOrderVenue) cancelOrder(orderID string) error {
+	resp, err := v.exchange.CancelSpotOrder(context.Background(), v.strategy.Symbol, orderID)
+	if err != nil {
+		return err
+	}
+	return v.ss.db.Model(&models.Order{}).Where("order_id = ?", orderID).Updates(map[string]interface{}{
+		"status":               resp.Status,
+		"executed_qty":         resp.ExecutedQty,
+		"cumulative_quote_qty": resp.CumulativeQuoteQty,
+	}).Error
+}
+
+func (v *spotOrderVenue) klines(interval string, limit int) ([]KlineData, error) {
+	return v.exchange.GetKlines(v.strategy.Symbol, interval, limit)
+}
+
+func (v *spotOrderVenue) orderFill(orderID string) (*venueFill, error) {
+	var order models.Order
+	if err := v.ss.db.Where("order_id = ?", orderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &venueFill{}, nil
+		}
+		return nil, err
+	}
+	if !isFinalOrderStatus(string(order.Status)) {
+		return nil, nil
+	}
+	fill := &venueFill{Filled: order.Status == "FILLED", Executed: order.ExecutedQty, QuoteQty: order.CumulativeQuoteQty}
+	if fill.QuoteQty <= 0 {
+		fill.QuoteQty = order.ExecutedQty * order.Price
+	}
+	fill.BaseFee, fill.QuoteFee = v.ss.orderFees(orderID, v.base, v.quote)
+	if fill.BaseFee == 0 && fill.QuoteFee == 0 && fill.Executed > 0 {
+		// 成交记录尚未同步（如模拟账户）时向交易所查询成交明细
+		fill.BaseFee, fill.QuoteFee = exchangeOrderFees(v.exchange, v.strategy.Symbol, orderID, v.base, v.quote)
+	}
+	return fill, nil
+}
+
+// exchangeOrderFees 通过交易所成交查询汇总现货订单的手续费，按基础资产和计价资产拆分，查询失败时返回0
+func exchangeOrderFees(exchange Exchange, symbol, orderID, base, quote string) (float64, float64) {
+	fills, err := exchange.GetSpotOrderTrades(context.Background(), symbol, orderID)
+	if err != nil {
+		return 0, 0
+	}
+
+	var baseFee, quoteFee float64
+	for _, f := range fills {
+		switch strings.ToUpper(f.CommissionAsset) {
+		case base:
+			baseFee += f.Commission
+		case quote:
+			quoteFee += f.Commission
+		}
+	}
+	return baseFee, quoteFee
+}
+
+// futuresOrderVenue 期货市场，按订单方向和是否只减仓确定持仓方向，默认下单数量为保证金乘杠杆折算的数量
+type futuresOrderVenue struct {
+	fs       *FuturesService
+	strategy *models.FuturesStrategy
+	exchange Exchange
+	info     *SymbolInfo
+	quote    string
+}
+
+func newFuturesOrderVenue(fs *FuturesService, strategy *models.FuturesStrategy, exchange Exchange) *futuresOrderVenue {
+	venue := &futuresOrderVenue{fs: fs, strategy: strategy, exchange: exchange}
+	_, venue.quote = splitSymbolBySuffix(strings.ToUpper(strategy.Symbol))
+	if info, err := exchange.GetFuturesSymbolInfo(strategy.Symbol); err == nil {
+		venue.info = info
+		if info.QuoteAsset != "" {
+			venue.quote = info.QuoteAsset
+		}
+	}
+	return venue
+}
+
+func (v *futuresOrderVenue) price() (float64, error) {
+	return v.exchange.GetFuturesPrice(context.Background(), v.strategy.Symbol)
+}
+
+func (v *futuresOrderVenue) symbolInfo() *SymbolInfo { return v.info }
+
+func (v *futuresOrderVenue) defaultQuantity(price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	return v.strategy.MarginAmount * float64(v.strategy.Leverage) / price
+}
+
+// futuresVenuePositionSide 开仓买入和平仓卖出对应多头，开仓卖出和平仓买入对应空头
+func futuresVenuePositionSide(side models.OrderSide, reduceOnly bool) models.PositionSide {
+	if (side == models.OrderSideBuy) != reduceOnly {
+		return models.PositionSideLong
+	}
+	return models.PositionSideShort
+}
+
+func (v *futuresOrderVenue) placeOrder(side models.OrderSide, orderType models.OrderType, price, quantity float64, reduceOnly bool) (string, error) {
+	order, err := v.fs.placeFuturesGridOrder(v.strategy, v.exchange, side, futuresVenuePositionSide(side, reduceOnly), orderType, price, quantity, reduceOnly)
+	if err != nil {
+		return "", err
+	}
+	return order.OrderID, nil
+}
+
+func (v *futuresOrderVenue) cancelOrder(orderID string) error {
+	resp, err := v.exchange.CancelFuturesOrder(context.Background(), v.strategy.Symbol, orderID)
+	if err != nil {
+		return err
+	}
+	return v.fs.db.Model(&models.FuturesOrder{}).Where("order_id = ?", orderID).Updates(map[string]interface{}{
+		"status":               resp.Status,
+		"executed_qty":         resp.ExecutedQty,
+		"cumulative_quote_qty": resp.CumulativeQuoteQty,
+	}).Error
+}
+
+func (v *futuresOrderVenue) klines(interval string, limit int) ([]KlineData, error) {
+	return v.exchange.GetFuturesKlines(v.strategy.Symbol, interval, limit)
+}
+
+func (v *futuresOrderVenue) orderFill(orderID string) (*venueFill, error) {
+	var order models.FuturesOrder
+	if err := v.fs.db.Where("order_id = ?", orderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &venueFill{}, nil
+		}
+		return nil, err
+	}
+	if !isFinalOrderStatus(string(order.Status)) {
+		return nil, nil
+	}
+	fill := &venueFill{Filled: order.Status == "FILLED", Executed: order.ExecutedQty, QuoteQty: order.CumulativeQuoteQty}
+	if fill.QuoteQty <= 0 {
+		fill.QuoteQty = order.ExecutedQty * order.Price
+	}
+	fill.QuoteFee = v.fs.futuresOrderFee(orderID, v.quote)
+	return fill, nil
+}
@@ -0,0 +1,1656 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+const (
+	paperStatusNew      = "NEW"
+	paperStatusFilled   = "FILLED"
+	paperStatusCanceled = "CANCELED"
+	paperStatusExpired  = "EXPIRED"
+
+	paperDefaultLeverage   = 20
+	paperMaintMarginRate   = 0.004
+	paperBalanceEpsilon    = 1e-9
+	paperDefaultQuoteAsset = "USDT"
+)
+
+var (
+	// paperMu 串行化模拟撮合，保证余额、委托与持仓的一致性
+	paperMu sync.Mutex
+
+	errPaperNothingToReduce = errors.New("只减仓订单没有可平的持仓")
+
+	paperQuoteAssets = []string{"USDT", "FDUSD", "USDC", "BUSD", "TUSD", "BTC", "ETH", "BNB"}
+)
+
+// PaperExchange 实现Exchange接口
+var _ Exchange = (*PaperExchange)(nil)
+
+// PaperExchange 模拟交易所：行情来自真实交易所（不可用时使用prices表中记录的价格），
+// 订单在本地撮合，委托记录在paper_orders，余额记录在paper_balances，期货持仓写入futures_positions
+type PaperExchange struct {
+	db     *gorm.DB
+	userID uint
+	market Exchange
+}
+
+// NewPaperExchange 创建模拟交易所实例，market为行情来源，可以为nil
+func NewPaperExchange(db *gorm.DB, userID uint, market Exchange) *PaperExchange {
+	return &PaperExchange{
+		db:     db,
+		userID: userID,
+		market: market,
+	}
+}
+
+// Name 交易所名称
+func (pe *PaperExchange) Name() string {
+	return "paper"
+}
+
+func (pe *PaperExchange) marketData() (Exchange, error) {
+	if pe.market == nil {
+		return nil, errors.New("模拟交易行情服务不可用")
+	}
+	return pe.market, nil
+}
+
+// recordedPrice 读取价格监控任务记录的最新价格
+func (pe *PaperExchange) recordedPrice(symbol string) (float64, error) {
+	var price models.Price
+	if err := pe.db.Where("symbol = ?", symbol).First(&price).Error; err != nil || price.Price <= 0 {
+		return 0, fmt.Errorf("无法获取%s的价格", symbol)
+	}
+	return price.Price, nil
+}
+
+// GetPrice 获取现货价格，实时行情不可用时使用记录价格
+func (pe *PaperExchange) GetPrice(ctx context.Context, symbol string) (float64, error) {
+	if pe.market != nil {
+		if price, err := pe.market.GetPrice(ctx, symbol); err == nil && price > 0 {
+			return price, nil
+		}
+	}
+	return pe.recordedPrice(symbol)
+}
+
+// GetFuturesPrice 获取期货价格，实时行情不可用时使用记录价格
+func (pe *PaperExchange) GetFuturesPrice(ctx context.Context, symbol string) (float64, error) {
+	if pe.market != nil {
+		if price, err := pe.market.GetFuturesPrice(ctx, symbol); err == nil && price > 0 {
+			return price, nil
+		}
+	}
+	return pe.recordedPrice(symbol)
+}
+
+// GetOrderBook 获取现货订单簿，实时行情不可用时以当前价格构造单档盘口
+func (pe *PaperExchange) GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	if pe.market != nil {
+		if book, err := pe.market.GetOrderBook(ctx, symbol, limit); err == nil {
+			return book, nil
+		}
+	}
+	price, err := pe.GetPrice(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return syntheticOrderBook(symbol, price), nil
+}
+
+// GetFuturesOrderBook 获取期货订单簿，实时行情不可用时以当前价格构造单档盘口
+func (pe *PaperExchange) GetFuturesOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	if pe.market != nil {
+		if book, err := pe.market.GetFuturesOrderBook(ctx, symbol, limit); err == nil {
+			return book, nil
+		}
+	}
+	price, err := pe.GetFuturesPrice(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return syntheticOrderBook(symbol, price), nil
+}
+
+func syntheticOrderBook(symbol string, price float64) *OrderBook {
+	return &OrderBook{
+		Symbol: symbol,
+		Bids:   []PriceLevel{{Price: price}},
+		Asks:   []PriceLevel{{Price: price}},
+	}
+}
+
+func (pe *PaperExchange) GetKlines(symbol string, interval string, limit int) ([]KlineData, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetKlines(symbol, interval, limit)
+}
+
+func (pe *PaperExchange) GetFuturesKlines(symbol string, interval string, limit int) ([]KlineData, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetFuturesKlines(symbol, interval, limit)
+}
+
+func (pe *PaperExchange) Get24hrTicker(symbol string) (*TickerData, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.Get24hrTicker(symbol)
+}
+
+func (pe *PaperExchange) GetTopSymbols(limit int) ([]string, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetTopSymbols(limit)
+}
+
+func (pe *PaperExchange) GetTradingSymbols(ctx context.Context) ([]SymbolInfo, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetTradingSymbols(ctx)
+}
+
+func (pe *PaperExchange) GetFuturesTradingSymbols(ctx context.Context) ([]SymbolInfo, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetFuturesTradingSymbols(ctx)
+}
+
+func (pe *PaperExchange) GetFundingRates(ctx context.Context) ([]FundingRateInfo, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetFundingRates(ctx)
+}
+
+func (pe *PaperExchange) GetSymbolInfo(symbol string) (*SymbolInfo, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetSymbolInfo(symbol)
+}
+
+func (pe *PaperExchange) GetFuturesSymbolInfo(symbol string) (*SymbolInfo, error) {
+	market, err := pe.marketData()
+	if err != nil {
+		return nil, err
+	}
+	return market.GetFuturesSymbolInfo(symbol)
+}
+
+// symbolRules 模拟撮合沿用真实交易所的交易规则，行情不可用时不做规范化
+func (pe *PaperExchange) symbolRules(symbol string, futures bool) *SymbolInfo {
+	var (
+		info *SymbolInfo
+		err  error
+	)
+	if futures {
+		info, err = pe.GetFuturesSymbolInfo(symbol)
+	} else {
+		info, err = pe.GetSymbolInfo(symbol)
+	}
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// splitSymbol 拆分交易对的基础资产和计价资产
+func (pe *PaperExchange) splitSymbol(symbol string) (string, string) {
+	if pe.market != nil {
+		if info, err := pe.market.GetSymbolInfo(symbol); err == nil && info.BaseAsset != "" && info.QuoteAsset != "" {
+			return info.BaseAsset, info.QuoteAsset
+		}
+	}
+	return splitSymbolBySuffix(symbol)
+}
+
+// splitSymbolBySuffix 按常见计价资产后缀拆分交易对
+func splitSymbolBySuffix(symbol string) (string, string) {
+	for _, quote := range paperQuoteAssets {
+		if strings.HasSuffix(symbol, quote) && len(symbol) > len(quote) {
+			return strings.TrimSuffix(symbol, quote), quote
+		}
+	}
+	return symbol, paperDefaultQuoteAsset
+}
+
+// newPaperOrderID 生成模拟订单号，调用方需持有paperMu
+func newPaperOrderID() string {
+	return fmt.Sprintf("PAPER-%d", time.Now().UnixNano())
+}
+
+func isPaperOrderOpen(status string) bool {
+	return status == paperStatusNew || status == "PARTIALLY_FILLED"
+}
+
+// ensureAccount 首次使用时为模拟账户注入初始资金
+func (pe *PaperExchange) ensureAccount(tx *gorm.DB, market models.PaperMarket) error {
+	var count int64
+	if err := tx.Model(&models.PaperBalance{}).Where("user_id = ? AND market = ?", pe.userID, market).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	initial := 10000.0
+	if config.AppConfig != nil && config.AppConfig.Paper.InitialUSDT > 0 {
+		initial = config.AppConfig.Paper.InitialUSDT
+	}
+
+	return tx.Create(&models.PaperBalance{
+		UserID: pe.userID,
+		Market: market,
+		Asset:  paperDefaultQuoteAsset,
+		Free:   initial,
+	}).Error
+}
+
+// adjustBalance 调整模拟余额，force为false时不允许可用或冻结余额为负
+func (pe *PaperExchange) adjustBalance(tx *gorm.DB, market models.PaperMarket, asset string, freeDelta, lockedDelta float64, force bool) error {
+	var balance models.PaperBalance
+	err := tx.Where("user_id = ? AND market = ? AND asset = ?", pe.userID, market, asset).First(&balance).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		balance = models.PaperBalance{UserID: pe.userID, Market: market, Asset: asset}
+	}
+
+	free := balance.Free + freeDelta
+	locked := balance.Locked + lockedDelta
+	if !force && (free < -paperBalanceEpsilon || locked < -paperBalanceEpsilon) {
+		return fmt.Errorf("%w: 模拟账户%s可用%.8f", ErrInsufficientBalance, asset, balance.Free)
+	}
+	if math.Abs(locked) < paperBalanceEpsilon {
+		locked = 0
+	}
+
+	balance.Free = free
+	balance.Locked = locked
+	return tx.Save(&balance).Error
+}
+
+func spotFeeRate() float64 {
+	if config.AppConfig != nil && config.AppConfig.Paper.SpotFeeRate >= 0 {
+		return config.AppConfig.Paper.SpotFeeRate
+	}
+	return 0.001
+}
+
+func futuresFeeRate(maker bool) float64 {
+	if config.AppConfig == nil {
+		if maker {
+			return 0.0002
+		}
+		return 0.0004
+	}
+	if maker {
+		return config.AppConfig.Paper.FuturesMakerFeeRate
+	}
+	return config.AppConfig.Paper.FuturesTakerFeeRate
+}
+
+func paperOrderResult(po *models.PaperOrder) *OrderResult {
+	result := &OrderResult{
+		OrderID:            po.OrderID,
+		ClientOrderID:      po.ClientOrderID,
+		Symbol:             po.Symbol,
+		Side:               po.Side,
+		Type:               po.Type,
+		PositionSide:       po.PositionSide,
+		Status:             po.Status,
+		Price:              po.Price,
+		StopPrice:          po.StopPrice,
+		AvgPrice:           averagePrice(po.CumQuote, po.ExecutedQty),
+		OrigQty:            po.OrigQty,
+		ExecutedQty:        po.ExecutedQty,
+		CumulativeQuoteQty: po.CumQuote,
+		ReduceOnly:         po.ReduceOnly,
+		UpdateTime:         po.UpdatedAt.UnixMilli(),
+	}
+	if po.ExecutedQty > 0 {
+		result.Fills = []OrderFill{{
+			TradeID:         int64(po.ID),
+			Price:           result.AvgPrice,
+			Quantity:        po.ExecutedQty,
+			QuoteQty:        po.CumQuote,
+			Commission:      po.Commission,
+			CommissionAsset: po.CommissionAsset,
+			IsBuyer:         po.Side == "BUY",
+			IsMaker:         po.IsMaker,
+			RealizedPnL:     po.RealizedPnL,
+			Time:            result.UpdateTime,
+		}}
+	}
+	return result
+}
+
+func (pe *PaperExchange) findOrder(tx *gorm.DB, market models.PaperMarket, orderID string) (*models.PaperOrder, error) {
+	var po models.PaperOrder
+	if err := tx.Where("user_id = ? AND market = ? AND order_id = ?", pe.userID, market, orderID).First(&po).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+		}
+		return nil, err
+	}
+	return &po, nil
+}
+
+// isStopTriggered 判断条件单是否触发：止损类买单价格上穿、卖单价格下穿触发价，止盈类相反
+func isStopTriggered(orderType, side string, stopPrice, price float64) bool {
+	stopLike := strings.HasPrefix(orderType, "STOP")
+	if (side == "BUY") == stopLike {
+		return price >= stopPrice
+	}
+	return price <= stopPrice
+}
+
+// isLimitMarketable 判断限价单在当前价格下是否可以成交
+func isLimitMarketable(side string, limitPrice, price float64) bool {
+	if side == "BUY" {
+		return price <= limitPrice
+	}
+	return price >= limitPrice
+}
+
+// ===== 现货 =====
+
+// CreateSpotOrder 创建模拟现货订单
+func (pe *PaperExchange) CreateSpotOrder(ctx context.Context, order *models.Order) (*OrderResult, error) {
+	if order.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	side := strings.ToUpper(string(order.Side))
+	orderType := strings.ToUpper(string(order.Type))
+	if side != "BUY" && side != "SELL" {
+		return nil, ErrInvalidOrderSide
+	}
+
+	price, err := pe.GetPrice(ctx, order.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeSpotOrder(pe.symbolRules(order.Symbol, false), order, func() float64 { return price }); err != nil {
+		return nil, err
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	po := &models.PaperOrder{
+		UserID:        pe.userID,
+		Market:        models.PaperMarketSpot,
+		OrderID:       newPaperOrderID(),
+		ClientOrderID: order.ClientOrderID,
+		Symbol:        order.Symbol,
+		Side:          side,
+		Type:          orderType,
+		Price:         order.Price,
+		StopPrice:     order.StopPrice,
+		OrigQty:       order.Quantity,
+		Status:        paperStatusNew,
+	}
+	base, quote := pe.splitSymbol(order.Symbol)
+
+	err = pe.db.Transaction(func(tx *gorm.DB) error {
+		if err := pe.ensureAccount(tx, models.PaperMarketSpot); err != nil {
+			return err
+		}
+
+		switch orderType {
+		case "MARKET":
+			return pe.fillSpotOrder(tx, po, base, quote, price, false)
+		case "LIMIT", "LIMIT_MAKER":
+			if po.Price <= 0 {
+				return ErrInvalidPrice
+			}
+			if isLimitMarketable(side, po.Price, price) {
+				if orderType == "LIMIT_MAKER" {
+					return errors.New("LIMIT_MAKER订单会立即成交，已拒绝")
+				}
+				return pe.fillSpotOrder(tx, po, base, quote, price, false)
+			}
+		case "STOP_LOSS", "STOP", "TAKE_PROFIT", "STOP_LOSS_LIMIT", "TAKE_PROFIT_LIMIT":
+			if po.StopPrice <= 0 {
+				return errors.New("条件单必须设置触发价格")
+			}
+			if strings.HasSuffix(orderType, "_LIMIT") && po.Price <= 0 {
+				return ErrInvalidPrice
+			}
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidOrderType, orderType)
+		}
+
+		// 挂单冻结资产：买单冻结计价资产，卖单冻结基础资产
+		if side == "BUY" {
+			reservePrice := po.Price
+			if reservePrice <= 0 {
+				reservePrice = po.StopPrice
+			}
+			po.Reserved = po.OrigQty * reservePrice
+			if err := pe.adjustBalance(tx, models.PaperMarketSpot, quote, -po.Reserved, po.Reserved, false); err != nil {
+				return err
+			}
+		} else {
+			po.Reserved = po.OrigQty
+			if err := pe.adjustBalance(tx, models.PaperMarketSpot, base, -po.Reserved, po.Reserved, false); err != nil {
+				return err
+			}
+		}
+		return tx.Create(po).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order.IsSimulated = true
+	return paperOrderResult(po), nil
+}
+
+// fillSpotOrder 按成交价全部成交并结算余额，新订单会在此处落库
+func (pe *PaperExchange) fillSpotOrder(tx *gorm.DB, po *models.PaperOrder, base, quote string, fillPrice float64, maker bool) error {
+	qty := po.OrigQty - po.ExecutedQty
+	quoteQty := qty * fillPrice
+	feeRate := spotFeeRate()
+
+	if po.Side == "BUY" {
+		if po.Reserved > 0 {
+			if err := pe.adjustBalance(tx, models.PaperMarketSpot, quote, po.Reserved, -po.Reserved, false); err != nil {
+				return err
+			}
+			po.Reserved = 0
+		}
+		if err := pe.adjustBalance(tx, models.PaperMarketSpot, quote, -quoteQty, 0, false); err != nil {
+			return err
+		}
+		commission := qty * feeRate
+		if err := pe.adjustBalance(tx, models.PaperMarketSpot, base, qty-commission, 0, false); err != nil {
+			return err
+		}
+		po.Commission += commission
+		po.CommissionAsset = base
+	} else {
+		if po.Reserved > 0 {
+			if err := pe.adjustBalance(tx, models.PaperMarketSpot, base, po.Reserved, -po.Reserved, false); err != nil {
+				return err
+			}
+			po.Reserved = 0
+		}
+		if err := pe.adjustBalance(tx, models.PaperMarketSpot, base, -qty, 0, false); err != nil {
+			return err
+		}
+		commission := quoteQty * feeRate
+		if err := pe.adjustBalance(tx, models.PaperMarketSpot, quote, quoteQty-commission, 0, false); err != nil {
+			return err
+		}
+		po.Commission += commission
+		po.CommissionAsset = quote
+	}
+
+	po.ExecutedQty += qty
+	po.CumQuote += quoteQty
+	po.IsMaker = maker
+	po.Status = paperStatusFilled
+	return tx.Save(po).Error
+}
+
+// releaseSpotReservation 释放挂单冻结的资产
+func (pe *PaperExchange) releaseSpotReservation(tx *gorm.DB, po *models.PaperOrder) error {
+	if po.Reserved <= 0 {
+		return nil
+	}
+	base, quote := pe.splitSymbol(po.Symbol)
+	asset := base
+	if po.Side == "BUY" {
+		asset = quote
+	}
+	if err := pe.adjustBalance(tx, models.PaperMarketSpot, asset, po.Reserved, -po.Reserved, true); err != nil {
+		return err
+	}
+	po.Reserved = 0
+	return nil
+}
+
+// matchSpotOrder 用当前价格撮合一笔挂单，返回订单状态是否发生变化
+func (pe *PaperExchange) matchSpotOrder(tx *gorm.DB, po *models.PaperOrder, price float64) (bool, error) {
+	if po.OrderListID != "" {
+		// 同一OCO列表的其他订单可能已在本轮撮合中成交，先刷新状态
+		if err := tx.First(po, po.ID).Error; err != nil {
+			return false, err
+		}
+	}
+	if !isPaperOrderOpen(po.Status) {
+		return false, nil
+	}
+
+	changed := false
+	fillPrice := 0.0
+	maker := false
+	switch po.Type {
+	case "LIMIT", "LIMIT_MAKER":
+		if isLimitMarketable(po.Side, po.Price, price) {
+			fillPrice = po.Price
+			maker = true
+		}
+	default:
+		if !po.Triggered {
+			if !isStopTriggered(po.Type, po.Side, po.StopPrice, price) {
+				return false, nil
+			}
+			po.Triggered = true
+			changed = true
+		}
+		if strings.HasSuffix(po.Type, "_LIMIT") {
+			if isLimitMarketable(po.Side, po.Price, price) {
+				fillPrice = po.Price
+			}
+		} else {
+			fillPrice = price
+		}
+	}
+
+	if fillPrice <= 0 && !changed {
+		return false, nil
+	}
+
+	// OCO一方触发或成交后其余订单失效，共用的冻结资产转到该订单
+	if err := pe.settleOrderListSiblings(tx, po, paperStatusExpired, true); err != nil {
+		return false, err
+	}
+
+	if fillPrice <= 0 {
+		return true, tx.Save(po).Error
+	}
+
+	base, quote := pe.splitSymbol(po.Symbol)
+	reserved := po.Reserved
+	err := tx.Transaction(func(inner *gorm.DB) error {
+		return pe.fillSpotOrder(inner, po, base, quote, fillPrice, maker)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrInsufficientBalance) {
+			return false, err
+		}
+		// 余额不足时订单失效，成交结算已回滚，冻结资产需按原值释放
+		po.Reserved = reserved
+		log.Printf("模拟订单%s余额不足，订单失效: %v", po.OrderID, err)
+		if err := pe.releaseSpotReservation(tx, po); err != nil {
+			return false, err
+		}
+		po.Status = paperStatusExpired
+		return true, tx.Save(po).Error
+	}
+	return true, nil
+}
+
+// CancelSpotOrder 撤销模拟现货订单
+func (pe *PaperExchange) CancelSpotOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var result *OrderResult
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		po, err := pe.findOrder(tx, models.PaperMarketSpot, orderID)
+		if err != nil {
+			return err
+		}
+		if !isPaperOrderOpen(po.Status) {
+			return fmt.Errorf("订单已%s，无法撤销", po.Status)
+		}
+		if err := pe.releaseSpotReservation(tx, po); err != nil {
+			return err
+		}
+		po.Status = paperStatusCanceled
+		if err := tx.Save(po).Error; err != nil {
+			return err
+		}
+		// 撤销OCO中的任一订单会撤销整个列表
+		if err := pe.settleOrderListSiblings(tx, po, paperStatusCanceled, false); err != nil {
+			return err
+		}
+		result = paperOrderResult(po)
+		return nil
+	})
+	return result, err
+}
+
+// GetSpotOrderStatus 查询模拟现货订单状态，未完成的订单会先按当前价格撮合
+func (pe *PaperExchange) GetSpotOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	price, priceErr := pe.GetPrice(ctx, symbol)
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var result *OrderResult
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		po, err := pe.findOrder(tx, models.PaperMarketSpot, orderID)
+		if err != nil {
+			return err
+		}
+		if priceErr == nil {
+			if _, err := pe.matchSpotOrder(tx, po, price); err != nil {
+				return err
+			}
+		}
+		result = paperOrderResult(po)
+		return nil
+	})
+	return result, err
+}
+
+// GetSpotOrderTrades 查询模拟现货订单的成交，模拟订单一次全部成交，最多一条
+func (pe *PaperExchange) GetSpotOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	po, err := pe.findOrder(pe.db, models.PaperMarketSpot, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return paperOrderResult(po).Fills, nil
+}
+
+// settleOrderListSiblings 结束同一OCO列表中其余未完成的订单，keep为true时冻结资产转给当前订单，否则释放
+func (pe *PaperExchange) settleOrderListSiblings(tx *gorm.DB, po *models.PaperOrder, status string, keep bool) error {
+	if po.OrderListID == "" {
+		return nil
+	}
+
+	var siblings []models.PaperOrder
+	if err := tx.Where("user_id = ? AND order_list_id = ? AND order_id <> ? AND status IN ?",
+		pe.userID, po.OrderListID, po.OrderID, []string{paperStatusNew, "PARTIALLY_FILLED"}).Find(&siblings).Error; err != nil {
+		return err
+	}
+	for i := range siblings {
+		sibling := &siblings[i]
+		if keep {
+			po.Reserved += sibling.Reserved
+			sibling.Reserved = 0
+		} else if err := pe.releaseSpotReservation(tx, sibling); err != nil {
+			return err
+		}
+		sibling.Status = status
+		if err := tx.Save(sibling).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func paperOrderListResult(listID, listStatus string, orders []models.PaperOrder) *OrderListResult {
+	result := &OrderListResult{
+		OrderListID:     listID,
+		ContingencyType: "OCO",
+		ListStatusType:  listStatus,
+		ListOrderStatus: "EXECUTING",
+	}
+	if listStatus == "ALL_DONE" {
+		result.ListOrderStatus = "ALL_DONE"
+	}
+	for i := range orders {
+		result.Symbol = orders[i].Symbol
+		result.Orders = append(result.Orders, paperOrderResult(&orders[i]))
+		result.TransactionTime = orders[i].UpdatedAt.UnixMilli()
+	}
+	return result
+}
+
+// CreateSpotOCO 创建模拟OCO：限价单（LIMIT_MAKER）与止损单（STOP_LOSS_LIMIT，未设置止损限价时为STOP_LOSS），
+// 两单共用的冻结资产记在限价单上
+func (pe *PaperExchange) CreateSpotOCO(ctx context.Context, req *OCORequest) (*OrderListResult, error) {
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+	if req.Price <= 0 || req.StopPrice <= 0 {
+		return nil, ErrInvalidPrice
+	}
+	side := strings.ToUpper(string(req.Side))
+	if side != "BUY" && side != "SELL" {
+		return nil, ErrInvalidOrderSide
+	}
+
+	price, err := pe.GetPrice(ctx, req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeOCORequest(pe.symbolRules(req.Symbol, false), req, price); err != nil {
+		return nil, err
+	}
+	if side == "SELL" && (req.Price <= price || req.StopPrice >= price) {
+		return nil, fmt.Errorf("%w: 卖出OCO的限价须高于现价%.8f、触发价须低于现价", ErrInvalidPrice, price)
+	}
+	if side == "BUY" && (req.Price >= price || req.StopPrice <= price) {
+		return nil, fmt.Errorf("%w: 买入OCO的限价须低于现价%.8f、触发价须高于现价", ErrInvalidPrice, price)
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	listID := fmt.Sprintf("PAPER-OCO-%d", time.Now().UnixNano())
+	stopType := "STOP_LOSS"
+	if req.StopLimitPrice > 0 {
+		stopType = "STOP_LOSS_LIMIT"
+	}
+	orders := []models.PaperOrder{
+		{Type: "LIMIT_MAKER", Price: req.Price},
+		{Type: stopType, Price: req.StopLimitPrice, StopPrice: req.StopPrice},
+	}
+	for i := range orders {
+		orders[i].UserID = pe.userID
+		orders[i].Market = models.PaperMarketSpot
+		orders[i].OrderID = fmt.Sprintf("%s-%d", newPaperOrderID(), i)
+		orders[i].Symbol = req.Symbol
+		orders[i].Side = side
+		orders[i].OrigQty = req.Quantity
+		orders[i].OrderListID = listID
+		orders[i].Status = paperStatusNew
+	}
+	base, quote := pe.splitSymbol(req.Symbol)
+
+	err = pe.db.Transaction(func(tx *gorm.DB) error {
+		if err := pe.ensureAccount(tx, models.PaperMarketSpot); err != nil {
+			return err
+		}
+
+		// 买入按两单中较高的价格冻结计价资产，卖出冻结基础资产
+		limit := &orders[0]
+		if side == "BUY" {
+			reservePrice := math.Max(req.Price, math.Max(req.StopPrice, req.StopLimitPrice))
+			limit.Reserved = req.Quantity * reservePrice
+			if err := pe.adjustBalance(tx, models.PaperMarketSpot, quote, -limit.Reserved, limit.Reserved, false); err != nil {
+				return err
+			}
+		} else {
+			limit.Reserved = req.Quantity
+			if err := pe.adjustBalance(tx, models.PaperMarketSpot, base, -limit.Reserved, limit.Reserved, false); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&orders).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := paperOrderListResult(listID, "EXEC_STARTED", orders)
+	result.ListClientOrderID = req.ListClientOrderID
+	return result, nil
+}
+
+// CancelSpotOCO 撤销模拟OCO列表中未完成的订单
+func (pe *PaperExchange) CancelSpotOCO(ctx context.Context, symbol, orderListID string) (*OrderListResult, error) {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var result *OrderListResult
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		var orders []models.PaperOrder
+		if err := tx.Where("user_id = ? AND market = ? AND order_list_id = ?", pe.userID, models.PaperMarketSpot, orderListID).
+			Order("id").Find(&orders).Error; err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return fmt.Errorf("%w: %s", ErrOrderNotFound, orderListID)
+		}
+
+		canceled := 0
+		for i := range orders {
+			po := &orders[i]
+			if !isPaperOrderOpen(po.Status) {
+				continue
+			}
+			if err := pe.releaseSpotReservation(tx, po); err != nil {
+				return err
+			}
+			po.Status = paperStatusCanceled
+			if err := tx.Save(po).Error; err != nil {
+				return err
+			}
+			canceled++
+		}
+		if canceled == 0 {
+			return errors.New("订单列表已结束，无法撤销")
+		}
+		result = paperOrderListResult(orderListID, "ALL_DONE", orders)
+		return nil
+	})
+	return result, err
+}
+
+// ===== 期货 =====
+
+func paperPositionSide(side models.PositionSide) string {
+	if side == "" {
+		return "BOTH"
+	}
+	return strings.ToUpper(string(side))
+}
+
+// futuresPosition 读取模拟持仓，不存在时按该交易对的杠杆设置初始化（未落库）
+func (pe *PaperExchange) futuresPosition(tx *gorm.DB, symbol, positionSide string) (*models.FuturesPosition, error) {
+	var position models.FuturesPosition
+	err := tx.Where("user_id = ? AND symbol = ? AND position_side = ? AND is_simulated = ?",
+		pe.userID, symbol, positionSide, true).First(&position).Error
+	if err == nil {
+		return &position, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	position = models.FuturesPosition{
+		UserID:       pe.userID,
+		Symbol:       symbol,
+		PositionSide: models.PositionSide(positionSide),
+		Leverage:     paperDefaultLeverage,
+		MarginType:   models.MarginTypeCross,
+		IsSimulated:  true,
+	}
+
+	var setting models.FuturesPosition
+	if err := tx.Where("user_id = ? AND symbol = ? AND is_simulated = ?", pe.userID, symbol, true).
+		First(&setting).Error; err == nil {
+		position.Leverage = setting.Leverage
+		position.MarginType = setting.MarginType
+	}
+	return &position, nil
+}
+
+// refreshPosition 按标记价格更新未实现盈亏和预估强平价（逐仓模型近似计算）
+func refreshPosition(position *models.FuturesPosition, markPrice float64) {
+	position.MarkPrice = markPrice
+	qty := math.Abs(position.PositionAmt)
+	if qty < paperBalanceEpsilon {
+		position.PositionAmt = 0
+		position.EntryPrice = 0
+		position.IsolatedMargin = 0
+		position.UnRealizedProfit = 0
+		position.LiquidationPrice = 0
+		position.MaxNotionalValue = 0
+		return
+	}
+
+	position.UnRealizedProfit = (markPrice - position.EntryPrice) * position.PositionAmt
+	position.MaxNotionalValue = qty * markPrice
+	if position.PositionAmt > 0 {
+		position.LiquidationPrice = (position.EntryPrice*qty - position.IsolatedMargin) / (qty * (1 - paperMaintMarginRate))
+	} else {
+		position.LiquidationPrice = (position.EntryPrice*qty + position.IsolatedMargin) / (qty * (1 + paperMaintMarginRate))
+	}
+	if position.LiquidationPrice < 0 {
+		position.LiquidationPrice = 0
+	}
+}
+
+// CreateFuturesOrder 创建模拟期货订单
+func (pe *PaperExchange) CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*OrderResult, error) {
+	if order.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	side := strings.ToUpper(string(order.Side))
+	if side != "BUY" && side != "SELL" {
+		return nil, ErrInvalidOrderSide
+	}
+	orderType := string(binanceFuturesOrderType(order.Type))
+
+	price, err := pe.GetFuturesPrice(ctx, order.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if err := normalizeFuturesOrder(pe.symbolRules(order.Symbol, true), order, func() float64 { return price }); err != nil {
+		return nil, err
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	po := &models.PaperOrder{
+		UserID:          pe.userID,
+		Market:          models.PaperMarketFutures,
+		OrderID:         newPaperOrderID(),
+		ClientOrderID:   order.ClientOrderID,
+		Symbol:          order.Symbol,
+		Side:            side,
+		Type:            orderType,
+		PositionSide:    paperPositionSide(order.PositionSide),
+		Price:           order.Price,
+		StopPrice:       order.StopPrice,
+		OrigQty:         order.Quantity,
+		ReduceOnly:      order.ReduceOnly,
+		CallbackRate:    order.CallbackRate,
+		ActivationPrice: order.ActivationPrice,
+		Status:          paperStatusNew,
+	}
+
+	err = pe.db.Transaction(func(tx *gorm.DB) error {
+		if err := pe.ensureAccount(tx, models.PaperMarketFutures); err != nil {
+			return err
+		}
+		// 与币安一致：双向持仓必须指定LONG/SHORT，单向持仓只能用BOTH
+		if dualSide := pe.paperDualSide(tx); dualSide == (po.PositionSide == "BOTH") {
+			return fmt.Errorf("%w: 订单持仓方向%s与账户持仓模式不匹配", ErrInvalidOrderType, po.PositionSide)
+		}
+
+		switch orderType {
+		case "MARKET":
+			return pe.fillFuturesOrder(tx, po, price, false)
+		case "LIMIT":
+			if po.Price <= 0 {
+				return ErrInvalidPrice
+			}
+			if isLimitMarketable(side, po.Price, price) {
+				return pe.fillFuturesOrder(tx, po, price, false)
+			}
+			if err := pe.checkFuturesMargin(tx, po, po.Price); err != nil {
+				return err
+			}
+		case "STOP_MARKET", "STOP", "TAKE_PROFIT_MARKET", "TAKE_PROFIT":
+			if po.StopPrice <= 0 {
+				return errors.New("条件单必须设置触发价格")
+			}
+		case "TRAILING_STOP_MARKET":
+			if po.CallbackRate < 0.1 || po.CallbackRate > 10 {
+				return errors.New("跟踪止损回调比例必须在0.1-10之间")
+			}
+			po.StopPrice = 0
+			trailPaperOrder(po, price)
+		default:
+			return fmt.Errorf("%w: %s", ErrInvalidOrderType, orderType)
+		}
+		return tx.Create(po).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order.IsSimulated = true
+	return paperOrderResult(po), nil
+}
+
+// closesPosition 判断订单相对当前持仓是否为平仓方向
+func closesPosition(po *models.PaperOrder, positionAmt float64) bool {
+	switch po.PositionSide {
+	case "LONG":
+		return po.Side == "SELL"
+	case "SHORT":
+		return po.Side == "BUY"
+	default:
+		return (po.Side == "SELL" && positionAmt > 0) || (po.Side == "BUY" && positionAmt < 0)
+	}
+}
+
+// checkFuturesMargin 校验开仓所需保证金是否充足
+func (pe *PaperExchange) checkFuturesMargin(tx *gorm.DB, po *models.PaperOrder, price float64) error {
+	position, err := pe.futuresPosition(tx, po.Symbol, po.PositionSide)
+	if err != nil {
+		return err
+	}
+	if po.ReduceOnly || closesPosition(po, position.PositionAmt) {
+		return nil
+	}
+
+	_, quote := pe.splitSymbol(po.Symbol)
+	var balance models.PaperBalance
+	tx.Where("user_id = ? AND market = ? AND asset = ?", pe.userID, models.PaperMarketFutures, quote).First(&balance)
+	required := po.OrigQty * price / float64(position.Leverage)
+	if balance.Free+paperBalanceEpsilon < required {
+		return fmt.Errorf("%w: 模拟期货账户保证金不足，需要%.4f %s", ErrInsufficientBalance, required, quote)
+	}
+	return nil
+}
+
+// fillFuturesOrder 按成交价全部成交，更新持仓与钱包余额，新订单会在此处落库
+func (pe *PaperExchange) fillFuturesOrder(tx *gorm.DB, po *models.PaperOrder, fillPrice float64, maker bool) error {
+	position, err := pe.futuresPosition(tx, po.Symbol, po.PositionSide)
+	if err != nil {
+		return err
+	}
+	_, quote := pe.splitSymbol(po.Symbol)
+
+	qty := po.OrigQty - po.ExecutedQty
+	closeQty := 0.0
+	if closesPosition(po, position.PositionAmt) {
+		closeQty = math.Min(qty, math.Abs(position.PositionAmt))
+	}
+	openQty := qty - closeQty
+	if po.ReduceOnly || (po.PositionSide != "BOTH" && closeQty > 0) {
+		// 只减仓或双向持仓的平仓单最多平掉现有持仓
+		openQty = 0
+		qty = closeQty
+	}
+	if qty <= paperBalanceEpsilon {
+		return errPaperNothingToReduce
+	}
+
+	realizedPnL := 0.0
+	if closeQty > 0 {
+		direction := 1.0
+		if position.PositionAmt < 0 {
+			direction = -1.0
+		}
+		realizedPnL = (fillPrice - position.EntryPrice) * closeQty * direction
+		released := position.IsolatedMargin * closeQty / math.Abs(position.PositionAmt)
+		if err := pe.adjustBalance(tx, models.PaperMarketFutures, quote, released+realizedPnL, -released, true); err != nil {
+			return err
+		}
+		position.IsolatedMargin -= released
+		position.PositionAmt -= direction * closeQty
+	}
+
+	if openQty > 0 {
+		margin := openQty * fillPrice / float64(position.Leverage)
+		if err := pe.adjustBalance(tx, models.PaperMarketFutures, quote, -margin, margin, false); err != nil {
+			return err
+		}
+		direction := 1.0
+		if po.Side == "SELL" {
+			direction = -1.0
+		}
+		currentQty := math.Abs(position.PositionAmt)
+		position.EntryPrice = (position.EntryPrice*currentQty + fillPrice*openQty) / (currentQty + openQty)
+		position.PositionAmt += direction * openQty
+		position.IsolatedMargin += margin
+	}
+
+	commission := qty * fillPrice * futuresFeeRate(maker)
+	if err := pe.adjustBalance(tx, models.PaperMarketFutures, quote, -commission, 0, true); err != nil {
+		return err
+	}
+
+	refreshPosition(position, fillPrice)
+	if err := tx.Save(position).Error; err != nil {
+		return err
+	}
+
+	po.ExecutedQty += qty
+	po.CumQuote += qty * fillPrice
+	po.Commission += commission
+	po.CommissionAsset = quote
+	po.RealizedPnL += realizedPnL
+	po.IsMaker = maker
+	po.Status = paperStatusFilled
+	return tx.Save(po).Error
+}
+
+// matchFuturesOrder 用当前价格撮合一笔期货挂单，条件单触发后按市价（限价条件单按限价）成交
+func (pe *PaperExchange) matchFuturesOrder(tx *gorm.DB, po *models.PaperOrder, price float64) (bool, error) {
+	if !isPaperOrderOpen(po.Status) {
+		return false, nil
+	}
+
+	fillPrice := 0.0
+	maker := false
+	switch po.Type {
+	case "LIMIT":
+		if isLimitMarketable(po.Side, po.Price, price) {
+			fillPrice = po.Price
+			maker = true
+		}
+	case "TRAILING_STOP_MARKET":
+		changed, fire := trailPaperOrder(po, price)
+		if !fire {
+			if changed {
+				return true, tx.Save(po).Error
+			}
+			return false, nil
+		}
+		fillPrice = price
+	default:
+		if !isStopTriggered(po.Type, po.Side, po.StopPrice, price) {
+			return false, nil
+		}
+		po.Triggered = true
+		fillPrice = price
+		if po.Type == "STOP" || po.Type == "TAKE_PROFIT" {
+			fillPrice = po.Price
+		}
+	}
+
+	if fillPrice <= 0 {
+		return false, nil
+	}
+
+	err := tx.Transaction(func(inner *gorm.DB) error {
+		return pe.fillFuturesOrder(inner, po, fillPrice, maker)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrInsufficientBalance) && !errors.Is(err, errPaperNothingToReduce) {
+			return false, err
+		}
+		log.Printf("模拟期货订单%s无法成交，订单失效: %v", po.OrderID, err)
+		po.Status = paperStatusExpired
+		return true, tx.Save(po).Error
+	}
+	return true, nil
+}
+
+// trailPaperOrder 推进模拟跟踪止损单：价格到达激活价后（未设置时立即）激活，卖单跟随最高价、买单跟随最低价，
+// StopPrice记录按回调比例计算的当前触发价，返回订单是否变化以及是否触发
+func trailPaperOrder(po *models.PaperOrder, price float64) (changed, fire bool) {
+	sell := po.Side == "SELL"
+	if !po.Triggered {
+		if po.ActivationPrice > 0 && ((sell && price < po.ActivationPrice) || (!sell && price > po.ActivationPrice)) {
+			return false, false
+		}
+		po.Triggered = true
+		changed = true
+	}
+
+	rate := po.CallbackRate / 100
+	stop := price * (1 - rate)
+	if !sell {
+		stop = price * (1 + rate)
+	}
+	if po.StopPrice <= 0 || (sell && stop > po.StopPrice) || (!sell && stop < po.StopPrice) {
+		po.StopPrice = stop
+		changed = true
+	}
+	fire = (sell && price <= po.StopPrice) || (!sell && price >= po.StopPrice)
+	return changed, fire
+}
+
+// syncFuturesOrderRecord 将模拟委托的最新状态同步到futures_orders
+func (pe *PaperExchange) syncFuturesOrderRecord(tx *gorm.DB, po *models.PaperOrder) error {
+	return tx.Model(&models.FuturesOrder{}).
+		Where("user_id = ? AND order_id = ? AND is_simulated = ?", pe.userID, po.OrderID, true).
+		Updates(map[string]interface{}{
+			"status":               po.Status,
+			"executed_qty":         po.ExecutedQty,
+			"cumulative_quote_qty": po.CumQuote,
+		}).Error
+}
+
+// CancelFuturesOrder 撤销模拟期货订单
+func (pe *PaperExchange) CancelFuturesOrder(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var result *OrderResult
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		po, err := pe.findOrder(tx, models.PaperMarketFutures, orderID)
+		if err != nil {
+			return err
+		}
+		if !isPaperOrderOpen(po.Status) {
+			return fmt.Errorf("订单已%s，无法撤销", po.Status)
+		}
+		po.Status = paperStatusCanceled
+		if err := tx.Save(po).Error; err != nil {
+			return err
+		}
+		result = paperOrderResult(po)
+		return nil
+	})
+	return result, err
+}
+
+// GetFuturesOrderStatus 查询模拟期货订单状态，未完成的订单会先按当前价格撮合
+func (pe *PaperExchange) GetFuturesOrderStatus(ctx context.Context, symbol, orderID string) (*OrderResult, error) {
+	price, priceErr := pe.GetFuturesPrice(ctx, symbol)
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var result *OrderResult
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		po, err := pe.findOrder(tx, models.PaperMarketFutures, orderID)
+		if err != nil {
+			return err
+		}
+		if priceErr == nil {
+			changed, err := pe.matchFuturesOrder(tx, po, price)
+			if err != nil {
+				return err
+			}
+			if changed {
+				if err := pe.syncFuturesOrderRecord(tx, po); err != nil {
+					return err
+				}
+			}
+		}
+		result = paperOrderResult(po)
+		return nil
+	})
+	return result, err
+}
+
+// GetFuturesOrderTrades 查询模拟期货订单的成交，模拟订单一次全部成交，最多一条
+func (pe *PaperExchange) GetFuturesOrderTrades(ctx context.Context, symbol, orderID string) ([]OrderFill, error) {
+	po, err := pe.findOrder(pe.db, models.PaperMarketFutures, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return paperOrderResult(po).Fills, nil
+}
+
+// markPositions 按标记价格更新全部模拟持仓，价格触及强平价时强制平仓（损失全部仓位保证金）
+func (pe *PaperExchange) markPositions(ctx context.Context, tx *gorm.DB) ([]models.FuturesPosition, error) {
+	var positions []models.FuturesPosition
+	if err := tx.Where("user_id = ? AND is_simulated = ? AND position_amt != 0", pe.userID, true).Find(&positions).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range positions {
+		position := &positions[i]
+		markPrice, err := pe.GetFuturesPrice(ctx, position.Symbol)
+		if err != nil {
+			log.Printf("获取模拟持仓%s标记价格失败: %v", position.Symbol, err)
+			continue
+		}
+		refreshPosition(position, markPrice)
+
+		liquidated := position.LiquidationPrice > 0 &&
+			((position.PositionAmt > 0 && markPrice <= position.LiquidationPrice) ||
+				(position.PositionAmt < 0 && markPrice >= position.LiquidationPrice))
+		if liquidated {
+			_, quote := pe.splitSymbol(position.Symbol)
+			if err := pe.adjustBalance(tx, models.PaperMarketFutures, quote, 0, -position.IsolatedMargin, true); err != nil {
+				return nil, err
+			}
+			log.Printf("模拟持仓强制平仓: 用户%d %s %s 数量%.8f 标记价格%.8f",
+				pe.userID, position.Symbol, position.PositionSide, position.PositionAmt, markPrice)
+			position.PositionAmt = 0
+			refreshPosition(position, markPrice)
+		}
+
+		if err := tx.Save(position).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return positions, nil
+}
+
+// GetFuturesPositions 获取模拟期货持仓
+func (pe *PaperExchange) GetFuturesPositions(ctx context.Context) ([]*PositionInfo, error) {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var result []*PositionInfo
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		positions, err := pe.markPositions(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, position := range positions {
+			if position.PositionAmt == 0 {
+				continue
+			}
+			result = append(result, &PositionInfo{
+				Symbol:           position.Symbol,
+				PositionSide:     string(position.PositionSide),
+				PositionAmt:      position.PositionAmt,
+				EntryPrice:       position.EntryPrice,
+				MarkPrice:        position.MarkPrice,
+				UnrealizedProfit: position.UnRealizedProfit,
+				LiquidationPrice: position.LiquidationPrice,
+				Leverage:         position.Leverage,
+				MaxNotionalValue: position.MaxNotionalValue,
+				MarginType:       string(position.MarginType),
+				IsolatedMargin:   position.IsolatedMargin,
+				IsAutoAddMargin:  position.IsAutoAddMargin,
+			})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// updatePositionSettings 更新交易对的模拟持仓设置，尚无持仓记录时写入一条空仓记录保存设置
+func (pe *PaperExchange) updatePositionSettings(symbol string, updates map[string]interface{}) error {
+	return pe.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.FuturesPosition{}).
+			Where("user_id = ? AND symbol = ? AND is_simulated = ?", pe.userID, symbol, true).
+			Updates(updates)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+
+		position, err := pe.futuresPosition(tx, symbol, "BOTH")
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(position).Error; err != nil {
+			return err
+		}
+		return tx.Model(position).Updates(updates).Error
+	})
+}
+
+// SetFuturesLeverage 设置模拟杠杆倍数
+func (pe *PaperExchange) SetFuturesLeverage(ctx context.Context, symbol string, leverage int) error {
+	if leverage < 1 || leverage > 125 {
+		return fmt.Errorf("杠杆倍数无效: %d", leverage)
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	return pe.updatePositionSettings(symbol, map[string]interface{}{"leverage": leverage})
+}
+
+// SetFuturesMarginType 设置模拟保证金模式，有持仓时不允许切换
+func (pe *PaperExchange) SetFuturesMarginType(ctx context.Context, symbol string, marginType models.MarginType) error {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	var openCount int64
+	pe.db.Model(&models.FuturesPosition{}).
+		Where("user_id = ? AND symbol = ? AND is_simulated = ? AND position_amt != 0 AND margin_type != ?",
+			pe.userID, symbol, true, marginType).
+		Count(&openCount)
+	if openCount > 0 {
+		return errors.New("存在持仓时无法修改保证金模式")
+	}
+
+	return pe.updatePositionSettings(symbol, map[string]interface{}{"margin_type": marginType})
+}
+
+// paperDualSide 模拟账户是否为双向持仓模式，未设置时沿用双向持仓
+func (pe *PaperExchange) paperDualSide(tx *gorm.DB) bool {
+	var setting models.PaperSetting
+	if err := tx.Where("user_id = ?", pe.userID).First(&setting).Error; err != nil {
+		return true
+	}
+	return setting.FuturesPositionMode != models.PositionModeOneWay
+}
+
+// GetFuturesPositionMode 查询模拟期货持仓模式
+func (pe *PaperExchange) GetFuturesPositionMode(ctx context.Context) (bool, error) {
+	return pe.paperDualSide(pe.db), nil
+}
+
+// SetFuturesPositionMode 设置模拟期货持仓模式，与币安一致有持仓或挂单时不允许切换
+func (pe *PaperExchange) SetFuturesPositionMode(ctx context.Context, dualSide bool) error {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	mode := models.PositionModeOneWay
+	if dualSide {
+		mode = models.PositionModeHedge
+	}
+	if pe.paperDualSide(pe.db) == dualSide {
+		return nil
+	}
+
+	var positionCount, orderCount int64
+	pe.db.Model(&models.FuturesPosition{}).
+		Where("user_id = ? AND is_simulated = ? AND position_amt != 0", pe.userID, true).
+		Count(&positionCount)
+	pe.db.Model(&models.PaperOrder{}).
+		Where("user_id = ? AND market = ? AND status IN ?", pe.userID, models.PaperMarketFutures, []string{paperStatusNew, "PARTIALLY_FILLED"}).
+		Count(&orderCount)
+	if positionCount > 0 || orderCount > 0 {
+		return errors.New("存在持仓或挂单时无法切换持仓模式")
+	}
+
+	setting := models.PaperSetting{UserID: pe.userID}
+	return pe.db.Where("user_id = ?", pe.userID).
+		Assign(models.PaperSetting{FuturesPositionMode: mode}).
+		FirstOrCreate(&setting).Error
+}
+
+// ModifyFuturesIsolatedMargin 调整模拟持仓的保证金，amount为正时从可用余额追加，为负时释放回可用余额
+func (pe *PaperExchange) ModifyFuturesIsolatedMargin(ctx context.Context, symbol string, positionSide models.PositionSide, amount float64) error {
+	if amount == 0 {
+		return errors.New("保证金调整数量无效")
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	side := strings.ToUpper(string(positionSide))
+	if side == "" {
+		side = "BOTH"
+	}
+	return pe.db.Transaction(func(tx *gorm.DB) error {
+		var position models.FuturesPosition
+		if err := tx.Where("user_id = ? AND symbol = ? AND position_side = ? AND is_simulated = ? AND position_amt != 0",
+			pe.userID, symbol, side, true).First(&position).Error; err != nil {
+			return fmt.Errorf("模拟持仓%s %s不存在", symbol, side)
+		}
+		if amount < 0 && position.IsolatedMargin+amount < paperBalanceEpsilon {
+			return fmt.Errorf("%w: 模拟持仓保证金%.4f不足以减少%.4f", ErrInsufficientBalance, position.IsolatedMargin, -amount)
+		}
+
+		_, quote := pe.splitSymbol(symbol)
+		if err := pe.adjustBalance(tx, models.PaperMarketFutures, quote, -amount, amount, false); err != nil {
+			return err
+		}
+		position.IsolatedMargin += amount
+		markPrice := position.MarkPrice
+		if markPrice <= 0 {
+			markPrice = position.EntryPrice
+		}
+		refreshPosition(&position, markPrice)
+		return tx.Save(&position).Error
+	})
+}
+
+// ===== 账户 =====
+
+// GetAccountInfo 获取模拟现货账户
+func (pe *PaperExchange) GetAccountInfo(ctx context.Context) (*SpotAccount, error) {
+	if err := pe.ensureAccount(pe.db, models.PaperMarketSpot); err != nil {
+		return nil, err
+	}
+
+	var balances []models.PaperBalance
+	if err := pe.db.Where("user_id = ? AND market = ?", pe.userID, models.PaperMarketSpot).
+		Order("asset").Find(&balances).Error; err != nil {
+		return nil, err
+	}
+
+	account := &SpotAccount{
+		CanTrade:   true,
+		UpdateTime: time.Now().UnixMilli(),
+		Balances:   make([]AssetBalance, 0, len(balances)),
+	}
+	for _, balance := range balances {
+		if balance.Free == 0 && balance.Locked == 0 {
+			continue
+		}
+		account.Balances = append(account.Balances, AssetBalance{
+			Asset:  balance.Asset,
+			Free:   balance.Free,
+			Locked: balance.Locked,
+		})
+	}
+	return account, nil
+}
+
+// GetFuturesAccountInfo 获取模拟期货账户，钱包余额为可用余额加已占用保证金
+func (pe *PaperExchange) GetFuturesAccountInfo(ctx context.Context) (*FuturesAccount, error) {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	account := &FuturesAccount{UpdateTime: time.Now().UnixMilli()}
+	err := pe.db.Transaction(func(tx *gorm.DB) error {
+		if err := pe.ensureAccount(tx, models.PaperMarketFutures); err != nil {
+			return err
+		}
+		positions, err := pe.markPositions(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		unrealized := make(map[string]float64)
+		for _, position := range positions {
+			_, quote := pe.splitSymbol(position.Symbol)
+			unrealized[quote] += position.UnRealizedProfit
+			account.TotalMaintMargin += position.MaxNotionalValue * paperMaintMarginRate
+		}
+
+		var balances []models.PaperBalance
+		if err := tx.Where("user_id = ? AND market = ?", pe.userID, models.PaperMarketFutures).
+			Order("asset").Find(&balances).Error; err != nil {
+			return err
+		}
+		for _, balance := range balances {
+			wallet := balance.Free + balance.Locked
+			account.Assets = append(account.Assets, FuturesAssetBalance{
+				Asset:            balance.Asset,
+				WalletBalance:    wallet,
+				UnrealizedProfit: unrealized[balance.Asset],
+				MarginBalance:    wallet + unrealized[balance.Asset],
+				AvailableBalance: balance.Free,
+			})
+			account.TotalWalletBalance += wallet
+			account.TotalUnrealizedProfit += unrealized[balance.Asset]
+			account.AvailableBalance += balance.Free
+		}
+		account.TotalMarginBalance = account.TotalWalletBalance + account.TotalUnrealizedProfit
+		account.MaxWithdrawAmount = account.AvailableBalance
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// MatchOpenOrders 撮合该用户全部未完成的模拟委托，并按标记价格更新模拟持仓
+func (pe *PaperExchange) MatchOpenOrders(ctx context.Context) error {
+	var orders []models.PaperOrder
+	if err := pe.db.Where("user_id = ? AND status IN ?", pe.userID, []string{paperStatusNew, "PARTIALLY_FILLED"}).
+		Order("id").Find(&orders).Error; err != nil {
+		return err
+	}
+
+	// 同一轮撮合中每个交易对只取一次价格
+	prices := make(map[string]float64)
+	priceOf := func(market models.PaperMarket, symbol string) (float64, bool) {
+		key := string(market) + ":" + symbol
+		if price, ok := prices[key]; ok {
+			return price, price > 0
+		}
+		var price float64
+		var err error
+		if market == models.PaperMarketFutures {
+			price, err = pe.GetFuturesPrice(ctx, symbol)
+		} else {
+			price, err = pe.GetPrice(ctx, symbol)
+		}
+		if err != nil {
+			log.Printf("获取%s价格失败，跳过模拟撮合: %v", symbol, err)
+		}
+		prices[key] = price
+		return price, price > 0
+	}
+
+	for i := range orders {
+		po := &orders[i]
+		price, ok := priceOf(po.Market, po.Symbol)
+		if !ok {
+			continue
+		}
+
+		paperMu.Lock()
+		err := pe.db.Transaction(func(tx *gorm.DB) error {
+			if po.Market == models.PaperMarketFutures {
+				changed, err := pe.matchFuturesOrder(tx, po, price)
+				if err != nil || !changed {
+					return err
+				}
+				return pe.syncFuturesOrderRecord(tx, po)
+			}
+			// 现货订单记录由订单检查任务通过GetSpotOrderStatus同步，以便策略计算成交增量
+			_, err := pe.matchSpotOrder(tx, po, price)
+			return err
+		})
+		paperMu.Unlock()
+		if err != nil {
+			log.Printf("撮合模拟订单%s失败: %v", po.OrderID, err)
+		}
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+	return pe.db.Transaction(func(tx *gorm.DB) error {
+		_, err := pe.markPositions(ctx, tx)
+		return err
+	})
+}
+
+// TransferToFutures 从模拟现货账户划转资产到模拟期货账户
+func (pe *PaperExchange) TransferToFutures(ctx context.Context, asset string, amount float64) error {
+	if asset == "" || amount <= 0 {
+		return errors.New("划转参数无效")
+	}
+
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	asset = strings.ToUpper(asset)
+	return pe.db.Transaction(func(tx *gorm.DB) error {
+		if err := pe.adjustBalance(tx, models.PaperMarketSpot, asset, -amount, 0, false); err != nil {
+			return err
+		}
+		return pe.adjustBalance(tx, models.PaperMarketFutures, asset, amount, 0, true)
+	})
+}
+
+// Withdraw 模拟账户不支持提币
+func (pe *PaperExchange) Withdraw(ctx context.Context, asset, address, network string, amount float64, addressTag string) (string, error) {
+	return "", errors.New("模拟交易账户不支持提币")
+}
+
+// GetFuturesIncomeHistory 模拟账户不结算资金费用，手续费和平仓盈亏已记录在模拟成交中
+func (pe *PaperExchange) GetFuturesIncomeHistory(ctx context.Context, incomeType string, startTime time.Time, limit int) ([]FuturesIncomeRecord, error) {
+	return []FuturesIncomeRecord{}, nil
+}
+
+func (pe *PaperExchange) GetWithdrawHistory(ctx context.Context, asset string, limit int) ([]*WithdrawRecord, error) {
+	return []*WithdrawRecord{}, nil
+}
+
+func (pe *PaperExchange) ValidateAPICredentials(ctx context.Context) error {
+	return nil
+}
+
+func (pe *PaperExchange) DiagnoseAPIConnection(ctx context.Context) (map[string]interface{}, error) {
+	marketData := "recorded_prices"
+	if pe.market != nil {
+		marketData = pe.market.Name()
+	}
+	return map[string]interface{}{
+		"exchange":    pe.Name(),
+		"market_data": marketData,
+		"user_id":     pe.userID,
+	}, nil
+}
+
+func (pe *PaperExchange) Close() error {
+	if pe.market != nil {
+		return pe.market.Close()
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+type PaperTradingService struct {
+	db          *gorm.DB
+	userService *UserService
+}
+
+func NewPaperTradingService() *PaperTradingService {
+	return &PaperTradingService{
+		db:          config.DB,
+		userService: NewUserService(),
+	}
+}
+
+// SetUserPaperTrading 开启或关闭用户级模拟交易
+func (pts *PaperTradingService) SetUserPaperTrading(userID uint, enabled bool) error {
+	return pts.db.Model(&models.User{}).Where("id = ?", userID).Update("paper_trading", enabled).Error
+}
+
+// GetAccount 获取模拟账户概览：现货余额、期货钱包和模拟持仓
+func (pts *PaperTradingService) GetAccount(userID uint) (map[string]interface{}, error) {
+	var user models.User
+	if err := pts.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	exchange := pts.userService.GetPaperExchange(userID)
+	ctx := context.Background()
+
+	spot, err := exchange.GetAccountInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	futures, err := exchange.GetFuturesAccountInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := exchange.GetFuturesPositions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var openOrders int64
+	pts.db.Model(&models.PaperOrder{}).Where("user_id = ? AND status IN ?", userID, []string{paperStatusNew, "PARTIALLY_FILLED"}).Count(&openOrders)
+
+	return map[string]interface{}{
+		"paper_trading": user.PaperTrading,
+		"spot":          spot,
+		"futures":       futures,
+		"positions":     positions,
+		"open_orders":   openOrders,
+	}, nil
+}
+
+// ResetAccount 重置模拟账户：撤销全部模拟委托、清空模拟持仓并恢复初始资金
+func (pts *PaperTradingService) ResetAccount(userID uint) error {
+	paperMu.Lock()
+	defer paperMu.Unlock()
+
+	return pts.db.Transaction(func(tx *gorm.DB) error {
+		openStatuses := []string{paperStatusNew, "PARTIALLY_FILLED"}
+
+		if err := tx.Model(&models.PaperOrder{}).
+			Where("user_id = ? AND status IN ?", userID, openStatuses).
+			Updates(map[string]interface{}{"status": paperStatusCanceled, "reserved": 0}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Order{}).
+			Where("user_id = ? AND is_simulated = ? AND status IN ?", userID, true, openStatuses).
+			Update("status", paperStatusCanceled).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.FuturesOrder{}).
+			Where("user_id = ? AND is_simulated = ? AND status IN ?", userID, true, openStatuses).
+			Update("status", paperStatusCanceled).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ? AND is_simulated = ?", userID, true).Delete(&models.FuturesPosition{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.PaperBalance{}).Error; err != nil {
+			return err
+		}
+
+		exchange := NewPaperExchange(tx, userID, nil)
+		if err := exchange.ensureAccount(tx, models.PaperMarketSpot); err != nil {
+			return err
+		}
+		return exchange.ensureAccount(tx, models.PaperMarketFutures)
+	})
+}
+
+// GetOrders 分页获取模拟委托记录
+func (pts *PaperTradingService) GetOrders(userID uint, page, limit int) ([]models.PaperOrder, int64, error) {
+	var orders []models.PaperOrder
+	var total int64
+
+	query := pts.db.Model(&models.PaperOrder{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return orders, total, nil
+}
+
+// MatchOpenOrders 撮合所有用户未完成的模拟委托，并更新模拟持仓的标记价格
+func (pts *PaperTradingService) MatchOpenOrders() error {
+	var userIDs []uint
+	if err := pts.db.Model(&models.PaperOrder{}).
+		Where("status IN ?", []string{paperStatusNew, "PARTIALLY_FILLED"}).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	var positionUserIDs []uint
+	if err := pts.db.Model(&models.FuturesPosition{}).
+		Where("is_simulated = ? AND position_amt != 0", true).
+		Distinct().Pluck("user_id", &positionUserIDs).Error; err != nil {
+		return err
+	}
+
+	seen := make(map[uint]bool)
+	for _, userID := range append(userIDs, positionUserIDs...) {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+
+		exchange := pts.userService.GetPaperExchange(userID)
+		if err := exchange.MatchOpenOrders(context.Background()); err != nil {
+			log.Printf("撮合用户%d模拟订单失败: %v", userID, err)
+		}
+	}
+
+	return nil
+}
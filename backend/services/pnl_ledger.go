@@ -0,0 +1,689 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// PnLCostMethod 持仓成本结转方法
+type PnLCostMethod string
+
+const (
+	PnLCostFIFO    PnLCostMethod = "fifo"    // 先进先出，按开仓批次依次平仓
+	PnLCostAverage PnLCostMethod = "average" // 均价成本法，所有批次合并为一个平均成本
+)
+
+// PnLValuationAsset 跨交易对汇总时使用的计价资产
+const PnLValuationAsset = "USDT"
+
+// pnlEpsilon 数量比较容差
+const pnlEpsilon = 1e-12
+
+// ParsePnLCostMethod 解析成本结转方法，为空时默认先进先出
+func ParsePnLCostMethod(method string) (PnLCostMethod, error) {
+	switch PnLCostMethod(strings.ToLower(method)) {
+	case "", PnLCostFIFO:
+		return PnLCostFIFO, nil
+	case PnLCostAverage, "avg":
+		return PnLCostAverage, nil
+	default:
+		return "", fmt.Errorf("不支持的成本结转方法: %s", method)
+	}
+}
+
+// PnLPeriod 统计区间内的已实现盈亏
+type PnLPeriod struct {
+	RealizedPnL float64 `json:"realized_pnl"` // 平仓盈亏，未扣除手续费
+	Fees        float64 `json:"fees"`         // 折算为计价资产的手续费
+	Funding     float64 `json:"funding"`      // 永续合约资金费用，正数为收入
+	NetPnL      float64 `json:"net_pnl"`      // 扣除手续费并计入资金费用后的已实现盈亏
+	TradeCount  int     `json:"trade_count"`
+	Volume      float64 `json:"volume"` // 成交额
+}
+
+func (p *PnLPeriod) add(realized, fee, volume float64) {
+	p.RealizedPnL += realized
+	p.Fees += fee
+	p.NetPnL = p.RealizedPnL - p.Fees + p.Funding
+	p.TradeCount++
+	p.Volume += volume
+}
+
+func (p *PnLPeriod) addFunding(amount float64) {
+	p.Funding += amount
+	p.NetPnL = p.RealizedPnL - p.Fees + p.Funding
+}
+
+func (p *PnLPeriod) merge(other PnLPeriod, rate float64) {
+	p.RealizedPnL += other.RealizedPnL * rate
+	p.Fees += other.Fees * rate
+	p.Funding += other.Funding * rate
+	p.NetPnL = p.RealizedPnL - p.Fees + p.Funding
+	p.TradeCount += other.TradeCount
+	p.Volume += other.Volume * rate
+}
+
+// PnLPosition 单个策略在单个交易对、单个持仓方向上的持仓和盈亏，金额以该交易对的计价资产计
+type PnLPosition struct {
+	Market            MarketType         `json:"market"`
+	Symbol            string             `json:"symbol"`
+	QuoteAsset        string             `json:"quote_asset"`
+	StrategyID        *uint              `json:"strategy_id"` // 为空表示手动下单
+	PositionSide      string             `json:"position_side,omitempty"`
+	Quantity          float64            `json:"quantity"`           // 持仓数量，空头为负数
+	AverageCost       float64            `json:"average_cost"`       // 剩余持仓的平均开仓价
+	CostBasis         float64            `json:"cost_basis"`         // 剩余持仓的开仓成本
+	MarkPrice         float64            `json:"mark_price"`         // 估值价格，取不到时为0
+	UnrealizedPnL     float64            `json:"unrealized_pnl"`     // 按估值价格计算的浮动盈亏
+	UnmatchedQuantity float64            `json:"unmatched_quantity"` // 没有可匹配持仓的平仓数量，如策略启动前已持有的现货
+	UnconvertedFees   map[string]float64 `json:"unconverted_fees,omitempty"`
+	Daily             PnLPeriod          `json:"daily"`
+	Weekly            PnLPeriod          `json:"weekly"`
+	AllTime           PnLPeriod          `json:"all_time"`
+
+	baseAsset string
+	sign      int // 1多头，-1空头，0无持仓
+	lots      []pnlLot
+}
+
+// pnlLot 一笔开仓批次
+type pnlLot struct {
+	quantity float64
+	price    float64
+}
+
+// PnLReport 策略、交易对或账户维度的盈亏汇总，金额统一折算为PnLValuationAsset
+type PnLReport struct {
+	Scope          string             `json:"scope"` // account、symbol或strategy
+	Market         MarketType         `json:"market,omitempty"`
+	Symbol         string             `json:"symbol,omitempty"`
+	StrategyID     *uint              `json:"strategy_id,omitempty"`
+	Method         PnLCostMethod      `json:"method"`
+	ValuationAsset string             `json:"valuation_asset"`
+	Daily          PnLPeriod          `json:"daily"`
+	Weekly         PnLPeriod          `json:"weekly"`
+	AllTime        PnLPeriod          `json:"all_time"`
+	UnrealizedPnL  float64            `json:"unrealized_pnl"`
+	TotalPnL       float64            `json:"total_pnl"` // 累计已实现净盈亏加浮动盈亏
+	OpenPositions  int                `json:"open_positions"`
+	Unconverted    map[string]float64 `json:"unconverted,omitempty"`    // 无法折算的手续费和盈亏，按资产统计
+	MissingPrices  []string           `json:"missing_prices,omitempty"` // 没有估值价格的交易对
+	DayStart       time.Time          `json:"day_start"`
+	WeekStart      time.Time          `json:"week_start"`
+	GeneratedAt    time.Time          `json:"generated_at"`
+	Positions      []*PnLPosition     `json:"positions,omitempty"`
+}
+
+// PnLQuery 盈亏查询条件
+type PnLQuery struct {
+	UserID     uint
+	Market     MarketType // 为空时包含现货和期货
+	Symbol     string
+	StrategyID *uint // 现货和期货策略ID各自编号，按策略查询时必须指定Market
+	Simulated  *bool // 为空时包含模拟成交和真实成交
+	Method     PnLCostMethod
+}
+
+// ErrPnLMarketRequired 按策略查询盈亏时未指定市场
+var ErrPnLMarketRequired = errors.New("按策略查询盈亏时必须指定市场(spot或futures)")
+
+// PnLLedgerService 根据成交明细按批次结转成本，计算已实现盈亏、浮动盈亏和折算后的手续费
+type PnLLedgerService struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewPnLLedgerService() *PnLLedgerService {
+	return &PnLLedgerService{
+		db:  config.DB,
+		now: time.Now,
+	}
+}
+
+// UserSimulated 用户当前是否处于模拟交易模式，账户维度的盈亏默认只统计当前模式的成交
+func (pls *PnLLedgerService) UserSimulated(userID uint) bool {
+	return (&UserService{db: pls.db}).IsPaperTrading(userID)
+}
+
+// Positions 按查询条件重放成交，返回每个策略、交易对和持仓方向的持仓与盈亏
+func (pls *PnLLedgerService) Positions(query PnLQuery) ([]*PnLPosition, error) {
+	return pls.positions(query, newPnLPriceSource(pls.db, query.UserID))
+}
+
+func (pls *PnLLedgerService) positions(query PnLQuery, prices *pnlPriceSource) ([]*PnLPosition, error) {
+	if pls.db == nil {
+		return nil, errors.New("数据库未连接")
+	}
+	if query.StrategyID != nil && query.Market == "" {
+		return nil, ErrPnLMarketRequired
+	}
+
+	tx := pls.db.Where("user_id = ?", query.UserID)
+	if query.Market != "" {
+		tx = tx.Where("market = ?", string(query.Market))
+	}
+	if query.Symbol != "" {
+		tx = tx.Where("symbol = ?", strings.ToUpper(query.Symbol))
+	}
+	if query.StrategyID != nil {
+		tx = tx.Where("strategy_id = ?", *query.StrategyID)
+	}
+	if query.Simulated != nil {
+		if *query.Simulated {
+			tx = tx.Where("trade_id LIKE ?", paperTradeIDPrefix+"%")
+		} else {
+			tx = tx.Where("trade_id NOT LIKE ?", paperTradeIDPrefix+"%")
+		}
+	}
+
+	var trades []models.Trade
+	if err := tx.Order("trade_time, id").Find(&trades).Error; err != nil {
+		return nil, err
+	}
+
+	method := query.Method
+	if method == "" {
+		method = PnLCostFIFO
+	}
+
+	now := pls.now()
+	dayStart, weekStart := pnlPeriodStarts(now)
+
+	positions := make(map[string]*PnLPosition)
+	var order []string
+	for _, trade := range trades {
+		key := pnlPositionKey(trade)
+		position, ok := positions[key]
+		if !ok {
+			base, quote := splitSymbolBySuffix(strings.ToUpper(trade.Symbol))
+			position = &PnLPosition{
+				Market:       MarketType(trade.Market),
+				Symbol:       strings.ToUpper(trade.Symbol),
+				QuoteAsset:   quote,
+				StrategyID:   trade.StrategyID,
+				PositionSide: trade.PositionSide,
+				baseAsset:    base,
+			}
+			positions[key] = position
+			order = append(order, key)
+		}
+		position.apply(trade, method, prices, dayStart, weekStart)
+	}
+
+	// 资金费用不对应成交，计入同一策略、同一交易对的第一个持仓
+	if query.Market == "" || query.Market == MarketFutures {
+		if query.Simulated == nil || !*query.Simulated {
+			incomes, err := pls.fundingIncomes(query)
+			if err != nil {
+				return nil, err
+			}
+			for _, income := range incomes {
+				key := pnlFundingKey(income.Symbol, income.StrategyID)
+				position, ok := positions[key]
+				if !ok {
+					for _, candidate := range order {
+						if strings.HasPrefix(candidate, key+":") {
+							position = positions[candidate]
+							break
+						}
+					}
+				}
+				if position == nil {
+					base, quote := splitSymbolBySuffix(income.Symbol)
+					position = &PnLPosition{
+						Market:     MarketFutures,
+						Symbol:     income.Symbol,
+						QuoteAsset: quote,
+						StrategyID: income.StrategyID,
+						baseAsset:  base,
+					}
+					order = append(order, key)
+				}
+				positions[key] = position
+				position.applyFunding(income, prices, dayStart, weekStart)
+			}
+		}
+	}
+
+	result := make([]*PnLPosition, 0, len(order))
+	for _, key := range order {
+		position := positions[key]
+		position.markToMarket(prices)
+		result = append(result, position)
+	}
+	return result, nil
+}
+
+// Report 汇总查询条件下的全部持仓
+func (pls *PnLLedgerService) Report(query PnLQuery) (*PnLReport, error) {
+	prices := newPnLPriceSource(pls.db, query.UserID)
+	positions, err := pls.positions(query, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := "account"
+	if query.StrategyID != nil {
+		scope = "strategy"
+	} else if query.Symbol != "" {
+		scope = "symbol"
+	}
+
+	report := pls.newReport(scope, query.Method)
+	report.Market = query.Market
+	report.Symbol = strings.ToUpper(query.Symbol)
+	report.StrategyID = query.StrategyID
+	for _, position := range positions {
+		report.add(position, prices)
+	}
+	report.Positions = positions
+	return report, nil
+}
+
+// AccountPnL 账户盈亏及按交易对、按策略的拆分，各维度由同一批持仓汇总，合计一致
+func (pls *PnLLedgerService) AccountPnL(query PnLQuery) (map[string]interface{}, error) {
+	prices := newPnLPriceSource(pls.db, query.UserID)
+	positions, err := pls.positions(query, prices)
+	if err != nil {
+		return nil, err
+	}
+
+	account := pls.newReport("account", query.Method)
+	account.Market = query.Market
+
+	bySymbol := make(map[string]*PnLReport)
+	byStrategy := make(map[string]*PnLReport)
+	var symbolKeys, strategyKeys []string
+	for _, position := range positions {
+		account.add(position, prices)
+
+		symbolKey := string(position.Market) + ":" + position.Symbol
+		symbolReport, ok := bySymbol[symbolKey]
+		if !ok {
+			symbolReport = pls.newReport("symbol", query.Method)
+			symbolReport.Market = position.Market
+			symbolReport.Symbol = position.Symbol
+			bySymbol[symbolKey] = symbolReport
+			symbolKeys = append(symbolKeys, symbolKey)
+		}
+		symbolReport.add(position, prices)
+
+		strategyKey := string(position.Market) + ":manual"
+		if position.StrategyID != nil {
+			strategyKey = fmt.Sprintf("%s:%d", position.Market, *position.StrategyID)
+		}
+		strategyReport, ok := byStrategy[strategyKey]
+		if !ok {
+			strategyReport = pls.newReport("strategy", query.Method)
+			strategyReport.Market = position.Market
+			strategyReport.StrategyID = position.StrategyID
+			byStrategy[strategyKey] = strategyReport
+			strategyKeys = append(strategyKeys, strategyKey)
+		}
+		strategyReport.add(position, prices)
+	}
+
+	sort.Strings(symbolKeys)
+	sort.Strings(strategyKeys)
+	symbols := make([]*PnLReport, 0, len(symbolKeys))
+	for _, key := range symbolKeys {
+		symbols = append(symbols, bySymbol[key])
+	}
+	strategies := make([]*PnLReport, 0, len(strategyKeys))
+	for _, key := range strategyKeys {
+		strategies = append(strategies, byStrategy[key])
+	}
+
+	return map[string]interface{}{
+		"account":     account,
+		"by_symbol":   symbols,
+		"by_strategy": strategies,
+		"positions":   positions,
+	}, nil
+}
+
+func (pls *PnLLedgerService) newReport(scope string, method PnLCostMethod) *PnLReport {
+	if method == "" {
+		method = PnLCostFIFO
+	}
+	now := pls.now()
+	dayStart, weekStart := pnlPeriodStarts(now)
+	return &PnLReport{
+		Scope:          scope,
+		Method:         method,
+		ValuationAsset: PnLValuationAsset,
+		DayStart:       dayStart,
+		WeekStart:      weekStart,
+		GeneratedAt:    now,
+	}
+}
+
+// add 将单个持仓折算为计价资产后计入汇总，无法折算的金额记入Unconverted
+func (r *PnLReport) add(position *PnLPosition, prices *pnlPriceSource) {
+	for asset, fee := range position.UnconvertedFees {
+		r.addUnconverted(asset, fee)
+	}
+	if position.Quantity != 0 {
+		r.OpenPositions++
+		if position.MarkPrice <= 0 {
+			r.MissingPrices = appendUnique(r.MissingPrices, position.Symbol)
+		}
+	}
+
+	rate, ok := prices.valuationRate(position.QuoteAsset)
+	if !ok {
+		r.addUnconverted(position.QuoteAsset, position.AllTime.NetPnL+position.UnrealizedPnL)
+		r.MissingPrices = appendUnique(r.MissingPrices, position.QuoteAsset+PnLValuationAsset)
+		return
+	}
+
+	r.Daily.merge(position.Daily, rate)
+	r.Weekly.merge(position.Weekly, rate)
+	r.AllTime.merge(position.AllTime, rate)
+	r.UnrealizedPnL += position.UnrealizedPnL * rate
+	r.TotalPnL = r.AllTime.NetPnL + r.UnrealizedPnL
+}
+
+func (r *PnLReport) addUnconverted(asset string, amount float64) {
+	if amount == 0 {
+		return
+	}
+	if r.Unconverted == nil {
+		r.Unconverted = make(map[string]float64)
+	}
+	r.Unconverted[asset] += amount
+}
+
+// apply 按成本结转方法处理一笔成交：同向成交开仓，反向成交按批次平仓并计入已实现盈亏
+func (p *PnLPosition) apply(trade models.Trade, method PnLCostMethod, prices *pnlPriceSource, dayStart, weekStart time.Time) {
+	quantity := trade.Quantity
+	price := trade.Price
+	if quantity > 0 && trade.QuoteQty > 0 {
+		price = trade.QuoteQty / quantity
+	}
+	direction := 1
+	if trade.Side == models.OrderSideSell {
+		direction = -1
+	}
+
+	// 手续费折算为计价资产。现货以基础资产支付的手续费直接减少到账数量（卖出时额外扣减持仓），按成交价计费
+	fee := 0.0
+	feeAsset := strings.ToUpper(trade.CommissionAsset)
+	if trade.Commission > 0 && feeAsset != "" {
+		switch feeAsset {
+		case p.QuoteAsset:
+			fee = trade.Commission
+		case p.baseAsset:
+			fee = trade.Commission * price
+			if p.Market == MarketSpot {
+				quantity -= float64(direction) * trade.Commission
+			}
+		default:
+			if rate, ok := prices.conversionRate(feeAsset, p.QuoteAsset); ok {
+				fee = trade.Commission * rate
+			} else {
+				if p.UnconvertedFees == nil {
+					p.UnconvertedFees = make(map[string]float64)
+				}
+				p.UnconvertedFees[feeAsset] += trade.Commission
+			}
+		}
+	}
+
+	realized := 0.0
+	if p.sign != 0 && p.sign != direction {
+		closed := 0.0
+		realized, closed = p.close(quantity, price)
+		quantity -= closed
+	}
+	if quantity > pnlEpsilon {
+		if p.sign == 0 || p.sign == direction {
+			if p.canOpen(direction) {
+				p.open(quantity, price, direction, method)
+			} else {
+				p.UnmatchedQuantity += quantity
+			}
+		}
+	}
+	p.refresh()
+
+	volume := trade.QuoteQty
+	if volume <= 0 {
+		volume = trade.Price * trade.Quantity
+	}
+	p.AllTime.add(realized, fee, volume)
+	if !trade.TradeTime.Before(weekStart) {
+		p.Weekly.add(realized, fee, volume)
+	}
+	if !trade.TradeTime.Before(dayStart) {
+		p.Daily.add(realized, fee, volume)
+	}
+}
+
+// applyFunding 计入一笔资金费用，资产与计价资产不同时按汇率折算
+func (p *PnLPosition) applyFunding(income models.FuturesIncomeAllocation, prices *pnlPriceSource, dayStart, weekStart time.Time) {
+	amount := income.Income
+	asset := strings.ToUpper(income.Asset)
+	if asset != "" && asset != p.QuoteAsset {
+		rate, ok := prices.conversionRate(asset, p.QuoteAsset)
+		if !ok {
+			if p.UnconvertedFees == nil {
+				p.UnconvertedFees = make(map[string]float64)
+			}
+			// 无法折算的收入记为负的费用
+			p.UnconvertedFees[asset] -= amount
+			return
+		}
+		amount *= rate
+	}
+
+	p.AllTime.addFunding(amount)
+	if !income.IncomeTime.Before(weekStart) {
+		p.Weekly.addFunding(amount)
+	}
+	if !income.IncomeTime.Before(dayStart) {
+		p.Daily.addFunding(amount)
+	}
+}
+
+// canOpen 现货只能持有多头；期货双向持仓模式下多空方向由positionSide固定，单向持仓可以反手
+func (p *PnLPosition) canOpen(direction int) bool {
+	if p.Market == MarketSpot {
+		return direction > 0
+	}
+	switch strings.ToUpper(p.PositionSide) {
+	case "LONG":
+		return direction > 0
+	case "SHORT":
+		return direction < 0
+	default:
+		return true
+	}
+}
+
+func (p *PnLPosition) open(quantity, price float64, direction int, method PnLCostMethod) {
+	p.sign = direction
+	if method == PnLCostAverage && len(p.lots) > 0 {
+		lot := &p.lots[0]
+		total := lot.quantity + quantity
+		lot.price = (lot.price*lot.quantity + price*quantity) / total
+		lot.quantity = total
+		return
+	}
+	p.lots = append(p.lots, pnlLot{quantity: quantity, price: price})
+}
+
+// close 从最早的批次开始平仓，均价成本法只有一个批次。返回已实现盈亏和实际平仓数量
+func (p *PnLPosition) close(quantity, price float64) (float64, float64) {
+	realized, closed := 0.0, 0.0
+	for len(p.lots) > 0 && quantity-closed > pnlEpsilon {
+		lot := &p.lots[0]
+		matched := lot.quantity
+		if remaining := quantity - closed; remaining < matched {
+			matched = remaining
+		}
+		realized += float64(p.sign) * (price - lot.price) * matched
+		closed += matched
+		lot.quantity -= matched
+		if lot.quantity <= pnlEpsilon {
+			p.lots = p.lots[1:]
+		}
+	}
+	if len(p.lots) == 0 {
+		p.sign = 0
+	}
+	return realized, closed
+}
+
+func (p *PnLPosition) refresh() {
+	quantity, cost := 0.0, 0.0
+	for _, lot := range p.lots {
+		quantity += lot.quantity
+		cost += lot.quantity * lot.price
+	}
+	p.Quantity = float64(p.sign) * quantity
+	p.CostBasis = cost
+	p.AverageCost = 0
+	if quantity > 0 {
+		p.AverageCost = cost / quantity
+	}
+}
+
+// markToMarket 以最新价格（期货为标记价格）计算剩余持仓的浮动盈亏
+func (p *PnLPosition) markToMarket(prices *pnlPriceSource) {
+	p.MarkPrice, p.UnrealizedPnL = 0, 0
+	if p.Quantity == 0 {
+		return
+	}
+	price, ok := prices.markPrice(p.Market, p.Symbol)
+	if !ok {
+		return
+	}
+	p.MarkPrice = price
+	p.UnrealizedPnL = (price - p.AverageCost) * p.Quantity
+}
+
+func pnlPositionKey(trade models.Trade) string {
+	strategy := "manual"
+	if trade.StrategyID != nil {
+		strategy = fmt.Sprintf("%d", *trade.StrategyID)
+	}
+	return strings.Join([]string{trade.Market, strings.ToUpper(trade.Symbol), strategy, strings.ToLower(trade.PositionSide)}, ":")
+}
+
+// pnlFundingKey 资金费用按交易对和策略归属，是持仓键去掉持仓方向后的前缀
+func pnlFundingKey(symbol string, strategyID *uint) string {
+	strategy := "manual"
+	if strategyID != nil {
+		strategy = fmt.Sprintf("%d", *strategyID)
+	}
+	return strings.Join([]string{string(MarketFutures), strings.ToUpper(symbol), strategy}, ":")
+}
+
+// fundingIncomes 查询条件下按策略拆分后的资金费用，模拟交易不结算资金费用
+func (pls *PnLLedgerService) fundingIncomes(query PnLQuery) ([]models.FuturesIncomeAllocation, error) {
+	tx := pls.db.Where("user_id = ?", query.UserID)
+	if query.Symbol != "" {
+		tx = tx.Where("symbol = ?", strings.ToUpper(query.Symbol))
+	}
+	if query.StrategyID != nil {
+		tx = tx.Where("strategy_id = ?", *query.StrategyID)
+	}
+
+	var incomes []models.FuturesIncomeAllocation
+	if err := tx.Order("income_time, id").Find(&incomes).Error; err != nil {
+		return nil, err
+	}
+	return incomes, nil
+}
+
+// pnlPeriodStarts 当日零点和本周一零点（服务器时区）
+func pnlPeriodStarts(now time.Time) (time.Time, time.Time) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekday := (int(dayStart.Weekday()) + 6) % 7
+	return dayStart, dayStart.AddDate(0, 0, -weekday)
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// stableValuationAssets 没有对USDT报价时按1:1折算的稳定币
+var stableValuationAssets = map[string]bool{"USDT": true, "USDC": true, "FDUSD": true, "BUSD": true, "TUSD": true}
+
+// pnlPriceSource 估值价格来源：优先行情推送缓存，其次价格表，期货再参考同步的持仓标记价格。
+// 只读本地数据，不会为统计请求访问交易所
+type pnlPriceSource struct {
+	db     *gorm.DB
+	userID uint
+	cache  map[string]float64
+}
+
+func newPnLPriceSource(db *gorm.DB, userID uint) *pnlPriceSource {
+	return &pnlPriceSource{db: db, userID: userID, cache: make(map[string]float64)}
+}
+
+func (ps *pnlPriceSource) markPrice(market MarketType, symbol string) (float64, bool) {
+	key := marketKey(market, symbol)
+	if price, ok := ps.cache[key]; ok {
+		return price, price > 0
+	}
+
+	price, ok := DefaultMarketDataHub().GetPrice(market, symbol)
+	if !ok && market == MarketFutures {
+		var position models.FuturesPosition
+		if err := ps.db.Where("user_id = ? AND symbol = ? AND mark_price > 0", ps.userID, symbol).
+			Order("updated_at DESC").First(&position).Error; err == nil {
+			price, ok = position.MarkPrice, true
+		}
+	}
+	if !ok {
+		// 期货没有标记价格时用现货价格近似
+		var record models.Price
+		if err := ps.db.Where("symbol = ?", symbol).First(&record).Error; err == nil && record.Price > 0 {
+			price, ok = record.Price, true
+		}
+	}
+	if !ok {
+		price = 0
+	}
+	ps.cache[key] = price
+	return price, ok
+}
+
+// conversionRate 1单位from资产折合多少to资产，依次尝试from+to直接报价和to+from反向报价
+func (ps *pnlPriceSource) conversionRate(from, to string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	if price, ok := ps.markPrice(MarketSpot, from+to); ok {
+		return price, true
+	}
+	if price, ok := ps.markPrice(MarketSpot, to+from); ok {
+		return 1 / price, true
+	}
+	return 0, false
+}
+
+// valuationRate 计价资产折算为PnLValuationAsset的汇率
+func (ps *pnlPriceSource) valuationRate(asset string) (float64, bool) {
+	if rate, ok := ps.conversionRate(asset, PnLValuationAsset); ok {
+		return rate, true
+	}
+	if stableValuationAssets[asset] {
+		return 1, true
+	}
+	return 0, false
+}
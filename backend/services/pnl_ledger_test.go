@@ -0,0 +1,213 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+func TestPnLPositionApply(t *testing.T) {
+	type fill struct {
+		side       models.OrderSide
+		quantity   float64
+		price      float64
+		commission float64
+		feeAsset   string
+	}
+	buy := func(quantity, price float64) fill {
+		return fill{side: models.OrderSideBuy, quantity: quantity, price: price}
+	}
+	sell := func(quantity, price float64) fill {
+		return fill{side: models.OrderSideSell, quantity: quantity, price: price}
+	}
+
+	tests := []struct {
+		name          string
+		market        MarketType
+		positionSide  string
+		method        PnLCostMethod
+		fills         []fill
+		wantRealized  float64
+		wantFees      float64
+		wantQuantity  float64
+		wantAverage   float64
+		wantUnmatched float64
+	}{
+		{
+			name:         "fifo closes oldest lot first",
+			market:       MarketSpot,
+			method:       PnLCostFIFO,
+			fills:        []fill{buy(1, 100), buy(1, 120), sell(1.5, 130)},
+			wantRealized: 1*30 + 0.5*10,
+			wantQuantity: 0.5,
+			wantAverage:  120,
+		},
+		{
+			name:         "average cost merges lots",
+			market:       MarketSpot,
+			method:       PnLCostAverage,
+			fills:        []fill{buy(1, 100), buy(1, 120), sell(1.5, 130)},
+			wantRealized: 1.5 * 20,
+			wantQuantity: 0.5,
+			wantAverage:  110,
+		},
+		{
+			name:   "spot base asset fee reduces received quantity",
+			market: MarketSpot,
+			method: PnLCostFIFO,
+			fills: []fill{
+				{side: models.OrderSideBuy, quantity: 1, price: 100, commission: 0.001, feeAsset: "BTC"},
+				sell(0.999, 110),
+			},
+			wantRealized: 0.999 * 10,
+			wantFees:     0.1,
+		},
+		{
+			name:   "quote asset fees are summed",
+			market: MarketSpot,
+			method: PnLCostFIFO,
+			fills: []fill{
+				{side: models.OrderSideBuy, quantity: 1, price: 100, commission: 0.1, feeAsset: "USDT"},
+				{side: models.OrderSideSell, quantity: 1, price: 110, commission: 0.11, feeAsset: "USDT"},
+			},
+			wantRealized: 10,
+			wantFees:     0.21,
+		},
+		{
+			name:          "spot sell beyond holdings is unmatched",
+			market:        MarketSpot,
+			method:        PnLCostFIFO,
+			fills:         []fill{buy(1, 100), sell(2, 110)},
+			wantRealized:  10,
+			wantUnmatched: 1,
+		},
+		{
+			name:         "one-way futures reverses into a short",
+			market:       MarketFutures,
+			positionSide: "BOTH",
+			method:       PnLCostFIFO,
+			fills:        []fill{buy(1, 100), sell(2, 90)},
+			wantRealized: -10,
+			wantQuantity: -1,
+			wantAverage:  90,
+		},
+		{
+			name:         "one-way futures short closes at a profit",
+			market:       MarketFutures,
+			positionSide: "BOTH",
+			method:       PnLCostAverage,
+			fills:        []fill{sell(1, 100), sell(1, 110), buy(2, 95)},
+			wantRealized: 2 * 10,
+		},
+		{
+			name:         "hedge-mode short side",
+			market:       MarketFutures,
+			positionSide: "SHORT",
+			method:       PnLCostFIFO,
+			fills:        []fill{sell(2, 100), buy(1, 90)},
+			wantRealized: 10,
+			wantQuantity: -1,
+			wantAverage:  100,
+		},
+		{
+			name:          "hedge-mode long side cannot open with a sell",
+			market:        MarketFutures,
+			positionSide:  "LONG",
+			method:        PnLCostFIFO,
+			fills:         []fill{sell(1, 100)},
+			wantUnmatched: 1,
+		},
+	}
+
+	now := time.Now()
+	dayStart, weekStart := pnlPeriodStarts(now)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := &PnLPosition{
+				Market:       tt.market,
+				Symbol:       "BTCUSDT",
+				QuoteAsset:   "USDT",
+				PositionSide: tt.positionSide,
+				baseAsset:    "BTC",
+			}
+			for _, f := range tt.fills {
+				position.apply(models.Trade{
+					Market:          string(tt.market),
+					Symbol:          "BTCUSDT",
+					Side:            f.side,
+					PositionSide:    tt.positionSide,
+					Price:           f.price,
+					Quantity:        f.quantity,
+					QuoteQty:        f.price * f.quantity,
+					Commission:      f.commission,
+					CommissionAsset: f.feeAsset,
+					TradeTime:       now,
+				}, tt.method, nil, dayStart, weekStart)
+			}
+
+			if math.Abs(position.AllTime.RealizedPnL-tt.wantRealized) > 1e-9 {
+				t.Errorf("realized = %v, want %v", position.AllTime.RealizedPnL, tt.wantRealized)
+			}
+			if math.Abs(position.AllTime.Fees-tt.wantFees) > 1e-9 {
+				t.Errorf("fees = %v, want %v", position.AllTime.Fees, tt.wantFees)
+			}
+			if math.Abs(position.AllTime.NetPnL-(tt.wantRealized-tt.wantFees)) > 1e-9 {
+				t.Errorf("net = %v, want %v", position.AllTime.NetPnL, tt.wantRealized-tt.wantFees)
+			}
+			if position.AllTime.TradeCount != len(tt.fills) || position.Daily.TradeCount != len(tt.fills) {
+				t.Errorf("trade count = %d (daily %d), want %d", position.AllTime.TradeCount, position.Daily.TradeCount, len(tt.fills))
+			}
+			if math.Abs(position.Quantity-tt.wantQuantity) > 1e-9 {
+				t.Errorf("quantity = %v, want %v", position.Quantity, tt.wantQuantity)
+			}
+			if math.Abs(position.AverageCost-tt.wantAverage) > 1e-9 {
+				t.Errorf("average cost = %v, want %v", position.AverageCost, tt.wantAverage)
+			}
+			if math.Abs(position.UnmatchedQuantity-tt.wantUnmatched) > 1e-9 {
+				t.Errorf("unmatched = %v, want %v", position.UnmatchedQuantity, tt.wantUnmatched)
+			}
+		})
+	}
+}
+
+func TestPnLPeriodBoundaries(t *testing.T) {
+	now := time.Now()
+	dayStart, weekStart := pnlPeriodStarts(now)
+	position := &PnLPosition{Market: MarketSpot, Symbol: "BTCUSDT", QuoteAsset: "USDT", baseAsset: "BTC"}
+
+	trades := []struct {
+		side models.OrderSide
+		time time.Time
+	}{
+		{models.OrderSideBuy, weekStart.Add(-time.Hour)},
+		{models.OrderSideSell, dayStart.Add(-time.Nanosecond)},
+		{models.OrderSideBuy, dayStart},
+		{models.OrderSideSell, now},
+	}
+	for i, trade := range trades {
+		position.apply(models.Trade{
+			Side:      trade.side,
+			Price:     100 + float64(i)*10,
+			Quantity:  1,
+			QuoteQty:  100 + float64(i)*10,
+			TradeTime: trade.time,
+		}, PnLCostFIFO, nil, dayStart, weekStart)
+	}
+
+	// 第一轮买入在本周之前、卖出在今天之前，第二轮都在今天
+	if position.AllTime.TradeCount != 4 || position.AllTime.RealizedPnL != 20 {
+		t.Errorf("all time = %+v", position.AllTime)
+	}
+	wantWeekly := 3
+	if dayStart.Add(-time.Nanosecond).Before(weekStart) {
+		wantWeekly = 2
+	}
+	if position.Weekly.TradeCount != wantWeekly {
+		t.Errorf("weekly trade count = %d, want %d", position.Weekly.TradeCount, wantWeekly)
+	}
+	if position.Daily.TradeCount != 2 || position.Daily.RealizedPnL != 10 {
+		t.Errorf("daily = %+v", position.Daily)
+	}
+}
@@ -0,0 +1,482 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// ErrPreTradeRejected 订单未通过下单前风控检查，没有提交到交易所
+var ErrPreTradeRejected = errors.New("订单未通过下单前风控检查")
+
+// PreTradeError 下单前风控的拒绝原因，余额和保证金不足时同时匹配ErrInsufficientBalance
+type PreTradeError struct {
+	Reason  models.PreTradeReason
+	Message string
+}
+
+func (e *PreTradeError) Error() string {
+	return fmt.Sprintf("%s[%s]: %s", ErrPreTradeRejected, e.Reason, e.Message)
+}
+
+func (e *PreTradeError) Unwrap() []error {
+	if e.Reason == models.PreTradeInsufficientBalance || e.Reason == models.PreTradeInsufficientMargin {
+		return []error{ErrPreTradeRejected, ErrInsufficientBalance}
+	}
+	return []error{ErrPreTradeRejected}
+}
+
+// preTradeSnapshotTTL 账户快照有效期，同一次策略执行内连续下单时复用快照并在本地扣减
+const preTradeSnapshotTTL = 15 * time.Second
+
+// preTradeExchange 在现货和期货下单前检查可用余额、保证金、单笔名义价值、交易对敞口和价格偏离
+type preTradeExchange struct {
+	Exchange
+	db        *gorm.DB
+	userID    uint
+	simulated bool
+
+	mu        sync.Mutex
+	policy    *models.RiskPolicy
+	policyAt  time.Time
+	spot      *SpotAccount
+	spotAt    time.Time
+	futures   *FuturesAccount
+	positions []*PositionInfo
+	futuresAt time.Time
+}
+
+// WithPreTradeChecks 为交易所实例接入下单前风控，simulated表示该实例为模拟交易所
+func WithPreTradeChecks(db *gorm.DB, userID uint, simulated bool, exchange Exchange) Exchange {
+	if exchange == nil || db == nil {
+		return exchange
+	}
+	return &preTradeExchange{Exchange: exchange, db: db, userID: userID, simulated: simulated}
+}
+
+// preTradeOrder 现货和期货订单在风控检查中的统一表示
+type preTradeOrder struct {
+	market       MarketType
+	strategyID   *uint
+	symbol       string
+	side         models.OrderSide
+	orderType    models.OrderType
+	positionSide models.PositionSide
+	quantity     float64
+	price        float64
+	stopPrice    float64
+	reduceOnly   bool
+}
+
+func (pte *preTradeExchange) CreateSpotOrder(ctx context.Context, order *models.Order) (*OrderResult, error) {
+	pte.mu.Lock()
+	defer pte.mu.Unlock()
+
+	request := &preTradeOrder{
+		market:     MarketSpot,
+		strategyID: order.StrategyID,
+		symbol:     strings.ToUpper(order.Symbol),
+		side:       order.Side,
+		orderType:  order.Type,
+		quantity:   order.Quantity,
+		price:      order.Price,
+		stopPrice:  order.StopPrice,
+	}
+	notional, err := pte.check(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := pte.Exchange.CreateSpotOrder(ctx, order)
+	if err == nil {
+		pte.reserveSpot(request, notional, result.ExecutedQty, result.CumulativeQuoteQty)
+	}
+	return result, err
+}
+
+// reserveSpot 本地扣减快照中的可用余额，避免同一批订单重复使用同一笔资金；
+// 立即成交的部分计入买到的资产，同一批后续订单（如括号单入场后的止盈止损）可以使用
+func (pte *preTradeExchange) reserveSpot(request *preTradeOrder, notional, executedQty, quoteQty float64) {
+	if pte.spot == nil {
+		return
+	}
+	base, quote := splitSymbolBySuffix(request.symbol)
+	if request.side == models.OrderSideBuy {
+		pte.adjustSpotFree(quote, -notional)
+		pte.adjustSpotFree(base, executedQty)
+	} else {
+		pte.adjustSpotFree(base, -request.quantity)
+		pte.adjustSpotFree(quote, quoteQty)
+	}
+}
+
+func (pte *preTradeExchange) CreateFuturesOrder(ctx context.Context, order *models.FuturesOrder) (*OrderResult, error) {
+	pte.mu.Lock()
+	defer pte.mu.Unlock()
+
+	request := &preTradeOrder{
+		market:       MarketFutures,
+		strategyID:   order.StrategyID,
+		symbol:       strings.ToUpper(order.Symbol),
+		side:         order.Side,
+		orderType:    order.Type,
+		positionSide: order.PositionSide,
+		quantity:     order.Quantity,
+		price:        order.Price,
+		stopPrice:    order.StopPrice,
+		reduceOnly:   order.ReduceOnly,
+	}
+	notional, err := pte.check(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := pte.Exchange.CreateFuturesOrder(ctx, order)
+	if err == nil && pte.futures != nil && !pte.closesPosition(request) {
+		pte.futures.AvailableBalance -= notional / float64(pte.leverage(request))
+	}
+	return result, err
+}
+
+// CreateSpotOCO OCO订单按限价腿的价格和数量检查，两条腿共用同一份数量，只计一次
+func (pte *preTradeExchange) CreateSpotOCO(ctx context.Context, req *OCORequest) (*OrderListResult, error) {
+	pte.mu.Lock()
+	defer pte.mu.Unlock()
+
+	request := &preTradeOrder{
+		market:    MarketSpot,
+		symbol:    strings.ToUpper(req.Symbol),
+		side:      req.Side,
+		orderType: models.OrderTypeLimit,
+		quantity:  req.Quantity,
+		price:     req.Price,
+		stopPrice: req.StopPrice,
+	}
+	notional, err := pte.check(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := pte.Exchange.CreateSpotOCO(ctx, req)
+	if err == nil {
+		pte.reserveSpot(request, notional, 0, 0)
+	}
+	return result, err
+}
+
+// riskPolicy 用户的风控策略，未设置时使用配置默认值；与账户快照一起过期，熔断后尽快生效
+func (pte *preTradeExchange) riskPolicy() *models.RiskPolicy {
+	if pte.policy == nil || time.Since(pte.policyAt) >= preTradeSnapshotTTL {
+		pte.policy, pte.policyAt = LoadRiskPolicy(pte.db, pte.userID), time.Now()
+	}
+	return pte.policy
+}
+
+// check 依次执行价格偏离、熔断状态、单笔名义价值、可用资金和交易对敞口检查，返回订单的名义价值
+func (pte *preTradeExchange) check(ctx context.Context, order *preTradeOrder) (float64, error) {
+	policy := pte.riskPolicy()
+	reference := pte.referencePrice(ctx, order)
+
+	price := order.price
+	if price <= 0 {
+		price = order.stopPrice
+	}
+	if order.orderType == models.OrderTypeMarket || order.orderType == models.OrderTypeTrailingStopMarket || price <= 0 {
+		price = reference
+	}
+	if price <= 0 {
+		return 0, pte.reject(order, 0, 0, models.PreTradeNoReferencePrice, "无法获取当前价格，无法计算订单名义价值")
+	}
+	notional := order.quantity * price
+
+	// 只检查会立即成交方向的偏离：买价高于或卖价低于当前价格过多，挂在盘口外的限价单不受限制
+	if policy.MaxPriceDeviationBP > 0 && order.orderType == models.OrderTypeLimit && order.price > 0 && reference > 0 {
+		deviation := (order.price - reference) / reference * 10000
+		if order.side == models.OrderSideSell {
+			deviation = -deviation
+		}
+		if deviation > float64(policy.MaxPriceDeviationBP) {
+			return 0, pte.reject(order, price, notional, models.PreTradePriceDeviation,
+				fmt.Sprintf("限价%.8g偏离当前价格%.8g达%.0f个基点，上限%d", order.price, reference, deviation, policy.MaxPriceDeviationBP))
+		}
+	}
+
+	// 只减仓和平仓订单降低风险，不受熔断、名义价值、资金和敞口限制
+	if order.market == MarketFutures && pte.closesPosition(order) {
+		return notional, nil
+	}
+
+	// 熔断后只允许降低敞口的现货卖单
+	if policy.HaltedAt != nil && !(order.market == MarketSpot && order.side == models.OrderSideSell) {
+		return 0, pte.reject(order, price, notional, models.PreTradeTradingHalted,
+			fmt.Sprintf("账户风控已于%s熔断: %s", policy.HaltedAt.Format("2006-01-02 15:04:05"), policy.HaltReason))
+	}
+
+	if policy.MaxOrderNotional > 0 && notional > policy.MaxOrderNotional {
+		return 0, pte.reject(order, price, notional, models.PreTradeMaxOrderNotional,
+			fmt.Sprintf("订单名义价值%.4f超过单笔上限%.4f", notional, policy.MaxOrderNotional))
+	}
+
+	if order.market == MarketSpot {
+		if err := pte.checkSpotBalance(ctx, order, price, notional); err != nil {
+			return 0, err
+		}
+		if order.side != models.OrderSideBuy {
+			return notional, nil
+		}
+	} else if err := pte.checkFuturesMargin(ctx, order, price, notional); err != nil {
+		return 0, err
+	}
+
+	if policy.MaxSymbolExposure > 0 {
+		exposure := pte.symbolExposure(ctx, order, reference) + notional
+		if exposure > policy.MaxSymbolExposure {
+			return 0, pte.reject(order, price, notional, models.PreTradeSymbolExposure,
+				fmt.Sprintf("%s敞口%.4f将超过上限%.4f", order.symbol, exposure, policy.MaxSymbolExposure))
+		}
+	}
+	return notional, nil
+}
+
+// referencePrice 当前参考价格：期货优先使用持仓的标记价格，查询失败时返回0
+func (pte *preTradeExchange) referencePrice(ctx context.Context, order *preTradeOrder) float64 {
+	if order.market == MarketSpot {
+		price, err := pte.Exchange.GetPrice(ctx, order.symbol)
+		if err != nil {
+			return 0
+		}
+		return price
+	}
+
+	if _, positions, err := pte.futuresSnapshot(ctx); err == nil {
+		for _, position := range positions {
+			if position.Symbol == order.symbol && position.MarkPrice > 0 {
+				return position.MarkPrice
+			}
+		}
+	}
+	price, err := pte.Exchange.GetFuturesPrice(ctx, order.symbol)
+	if err != nil {
+		return 0
+	}
+	return price
+}
+
+// checkSpotBalance 买单需要足够的计价资产，卖单需要足够的基础资产
+func (pte *preTradeExchange) checkSpotBalance(ctx context.Context, order *preTradeOrder, price, notional float64) error {
+	account, err := pte.spotSnapshot(ctx)
+	if err != nil {
+		// 查询失败时交给交易所判断余额
+		log.Printf("下单前风控查询用户%d现货余额失败: %v", pte.userID, err)
+		return nil
+	}
+
+	base, quote := splitSymbolBySuffix(order.symbol)
+	asset, required := quote, notional
+	if order.side == models.OrderSideSell {
+		asset, required = base, order.quantity
+	}
+	free := 0.0
+	for _, balance := range account.Balances {
+		if balance.Asset == asset {
+			free = balance.Free
+			break
+		}
+	}
+	if free+paperBalanceEpsilon < required {
+		return pte.reject(order, price, notional, models.PreTradeInsufficientBalance,
+			fmt.Sprintf("需要%.8g %s，可用%.8g", required, asset, free))
+	}
+	return nil
+}
+
+// checkFuturesMargin 开仓订单需要的初始保证金不能超过可用保证金
+func (pte *preTradeExchange) checkFuturesMargin(ctx context.Context, order *preTradeOrder, price, notional float64) error {
+	account, _, err := pte.futuresSnapshot(ctx)
+	if err != nil {
+		log.Printf("下单前风控查询用户%d期货账户失败: %v", pte.userID, err)
+		return nil
+	}
+
+	leverage := pte.leverage(order)
+	required := notional / float64(leverage)
+	if account.AvailableBalance+paperBalanceEpsilon < required {
+		return pte.reject(order, price, notional, models.PreTradeInsufficientMargin,
+			fmt.Sprintf("%d倍杠杆需要保证金%.4f，可用%.4f", leverage, required, account.AvailableBalance))
+	}
+	return nil
+}
+
+// leverage 订单使用的杠杆：策略订单取策略杠杆，否则取持仓杠杆，都没有时按1倍计算
+func (pte *preTradeExchange) leverage(order *preTradeOrder) int {
+	if order.strategyID != nil {
+		var strategy models.FuturesStrategy
+		if err := pte.db.Select("id", "leverage").First(&strategy, *order.strategyID).Error; err == nil && strategy.Leverage > 0 {
+			return strategy.Leverage
+		}
+	}
+	for _, position := range pte.positions {
+		if position.Symbol == order.symbol && position.Leverage > 0 {
+			return position.Leverage
+		}
+	}
+	return 1
+}
+
+// closesPosition 期货订单是否为只减仓或平仓方向的订单
+func (pte *preTradeExchange) closesPosition(order *preTradeOrder) bool {
+	if order.market != MarketFutures {
+		return false
+	}
+	if order.reduceOnly {
+		return true
+	}
+	switch strings.ToUpper(string(order.positionSide)) {
+	case "LONG":
+		return order.side == models.OrderSideSell
+	case "SHORT":
+		return order.side == models.OrderSideBuy
+	}
+
+	// 单向持仓下反向且不超过持仓数量的订单为平仓
+	for _, position := range pte.positions {
+		if position.Symbol != order.symbol || position.PositionAmt == 0 {
+			continue
+		}
+		if order.quantity > math.Abs(position.PositionAmt) {
+			return false
+		}
+		return (order.side == models.OrderSideSell && position.PositionAmt > 0) ||
+			(order.side == models.OrderSideBuy && position.PositionAmt < 0)
+	}
+	return false
+}
+
+// symbolExposure 交易对的现有敞口：现货为持仓估值，期货为持仓名义价值，再加上同方向未成交订单的名义价值
+func (pte *preTradeExchange) symbolExposure(ctx context.Context, order *preTradeOrder, reference float64) float64 {
+	exposure := 0.0
+	if order.market == MarketSpot {
+		if account, err := pte.spotSnapshot(ctx); err == nil && reference > 0 {
+			base, _ := splitSymbolBySuffix(order.symbol)
+			for _, balance := range account.Balances {
+				if balance.Asset == base {
+					exposure += (balance.Free + balance.Locked) * reference
+				}
+			}
+		}
+	} else if _, positions, err := pte.futuresSnapshot(ctx); err == nil {
+		for _, position := range positions {
+			if position.Symbol != order.symbol {
+				continue
+			}
+			markPrice := position.MarkPrice
+			if markPrice <= 0 {
+				markPrice = reference
+			}
+			exposure += math.Abs(position.PositionAmt) * markPrice
+		}
+	}
+
+	type openOrder struct {
+		Quantity    float64
+		ExecutedQty float64
+		Price       float64
+		StopPrice   float64
+	}
+	var openOrders []openOrder
+	query := pte.db.Where("user_id = ? AND symbol = ? AND side = ? AND status IN ? AND is_simulated = ?",
+		pte.userID, order.symbol, order.side, []string{"NEW", "PARTIALLY_FILLED"}, pte.simulated)
+	if order.market == MarketFutures {
+		query = query.Model(&models.FuturesOrder{}).Where("reduce_only = ?", false)
+	} else {
+		query = query.Model(&models.Order{})
+	}
+	if err := query.Select("quantity", "executed_qty", "price", "stop_price").Find(&openOrders).Error; err != nil {
+		log.Printf("下单前风控查询用户%d挂单失败: %v", pte.userID, err)
+		return exposure
+	}
+	for _, open := range openOrders {
+		price := open.Price
+		if price <= 0 {
+			price = open.StopPrice
+		}
+		if price <= 0 {
+			price = reference
+		}
+		exposure += math.Max(open.Quantity-open.ExecutedQty, 0) * price
+	}
+	return exposure
+}
+
+// spotSnapshot 现货账户快照，过期后重新查询
+func (pte *preTradeExchange) spotSnapshot(ctx context.Context) (*SpotAccount, error) {
+	if pte.spot != nil && time.Since(pte.spotAt) < preTradeSnapshotTTL {
+		return pte.spot, nil
+	}
+	account, err := pte.Exchange.GetAccountInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pte.spot, pte.spotAt = account, time.Now()
+	return account, nil
+}
+
+// futuresSnapshot 期货账户和持仓快照，过期后重新查询
+func (pte *preTradeExchange) futuresSnapshot(ctx context.Context) (*FuturesAccount, []*PositionInfo, error) {
+	if pte.futures != nil && time.Since(pte.futuresAt) < preTradeSnapshotTTL {
+		return pte.futures, pte.positions, nil
+	}
+	account, err := pte.Exchange.GetFuturesAccountInfo(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	positions, err := pte.Exchange.GetFuturesPositions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	pte.futures, pte.positions, pte.futuresAt = account, positions, time.Now()
+	return account, positions, nil
+}
+
+func (pte *preTradeExchange) adjustSpotFree(asset string, delta float64) {
+	for i := range pte.spot.Balances {
+		if pte.spot.Balances[i].Asset == asset {
+			pte.spot.Balances[i].Free += delta
+			return
+		}
+	}
+	if delta > 0 {
+		pte.spot.Balances = append(pte.spot.Balances, AssetBalance{Asset: asset, Free: delta})
+	}
+}
+
+// reject 记录被拒绝的订单并返回拒绝原因
+func (pte *preTradeExchange) reject(order *preTradeOrder, price, notional float64, reason models.PreTradeReason, message string) error {
+	rejection := &models.PreTradeRejection{
+		UserID:      pte.userID,
+		StrategyID:  order.strategyID,
+		Market:      string(order.market),
+		Symbol:      order.symbol,
+		Side:        order.side,
+		Type:        order.orderType,
+		Quantity:    order.quantity,
+		Price:       price,
+		Notional:    notional,
+		Reason:      reason,
+		Message:     message,
+		IsSimulated: pte.simulated,
+	}
+	if err := pte.db.Create(rejection).Error; err != nil {
+		log.Printf("保存下单前风控拒绝记录失败: %v", err)
+	}
+	log.Printf("用户%d的%s %s %s订单被下单前风控拒绝[%s]: %s", pte.userID, order.market, order.symbol, order.side, reason, message)
+	return &PreTradeError{Reason: reason, Message: message}
+}
@@ -0,0 +1,386 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// KlineData K线数据
+type KlineData struct {
+	OpenTime  int64   `json:"open_time"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	Volume    float64 `json:"volume"`
+	CloseTime int64   `json:"close_time"`
+}
+
+// ScoreWeights 评分维度权重
+type ScoreWeights struct {
+	Technical   float64 `json:"technical_weight"`
+	Fundamental float64 `json:"fundamental_weight"`
+	Sentiment   float64 `json:"sentiment_weight"`
+	Structure   float64 `json:"structure_weight"`
+}
+
+// PairScore 交易对综合评分
+type PairScore struct {
+	Symbol           string             `json:"symbol"`
+	Price            float64            `json:"price"`
+	TechnicalScore   float64            `json:"technical_score"`
+	FundamentalScore float64            `json:"fundamental_score"`
+	SentimentScore   float64            `json:"sentiment_score"`
+	StructureScore   float64            `json:"structure_score"`
+	TotalScore       float64            `json:"total_score"`
+	Indicators       map[string]float64 `json:"indicators"`
+	UpdatedAt        time.Time          `json:"updated_at"`
+}
+
+// ScoreSnapshot 评分历史快照
+type ScoreSnapshot struct {
+	TotalScore       float64   `json:"total_score"`
+	TechnicalScore   float64   `json:"technical_score"`
+	FundamentalScore float64   `json:"fundamental_score"`
+	SentimentScore   float64   `json:"sentiment_score"`
+	StructureScore   float64   `json:"structure_score"`
+	Price            float64   `json:"price"`
+	Time             time.Time `json:"time"`
+}
+
+// minScoringKlines 计算评分所需的最少K线数量
+const minScoringKlines = 50
+
+// ScorePair 根据K线和24小时行情计算交易对的综合评分（0-100）
+func ScorePair(symbol string, klines []KlineData, ticker *TickerData, weights ScoreWeights) (*PairScore, error) {
+	if len(klines) < minScoringKlines {
+		return nil, errors.New("K线数据不足，无法评分")
+	}
+	if ticker == nil {
+		return nil, errors.New("缺少24小时行情数据")
+	}
+
+	closes := make([]float64, len(klines))
+	for i, k := range klines {
+		closes[i] = k.Close
+	}
+	price := closes[len(closes)-1]
+	if ticker.LastPrice > 0 {
+		price = ticker.LastPrice
+	}
+
+	indicators := map[string]float64{
+		"rsi":            calculateRSI(closes, 14),
+		"sma20":          calculateSMA(closes, 20),
+		"sma50":          calculateSMA(closes, 50),
+		"volatility":     calculateVolatility(closes, 24),
+		"volume_ratio":   calculateVolumeRatio(klines, 20),
+		"change_percent": ticker.PriceChangePercent,
+		"quote_volume":   ticker.Volume * price,
+	}
+	macd, signal, histogram := calculateMACD(closes)
+	indicators["macd"] = macd
+	indicators["macd_signal"] = signal
+	indicators["macd_histogram"] = histogram
+
+	score := &PairScore{
+		Symbol:           symbol,
+		Price:            price,
+		TechnicalScore:   technicalScore(price, indicators),
+		FundamentalScore: fundamentalScore(indicators),
+		SentimentScore:   sentimentScore(indicators),
+		StructureScore:   structureScore(price, ticker, indicators),
+		Indicators:       indicators,
+		UpdatedAt:        time.Now(),
+	}
+
+	totalWeight := weights.Technical + weights.Fundamental + weights.Sentiment + weights.Structure
+	if totalWeight <= 0 {
+		weights = ScoreWeights{Technical: 0.4, Fundamental: 0.25, Sentiment: 0.2, Structure: 0.15}
+		totalWeight = 1
+	}
+	score.TotalScore = (score.TechnicalScore*weights.Technical +
+		score.FundamentalScore*weights.Fundamental +
+		score.SentimentScore*weights.Sentiment +
+		score.StructureScore*weights.Structure) / totalWeight
+
+	return score, nil
+}
+
+// scoreWeightsFromConfig 从策略配置读取评分权重，未配置时使用默认权重
+func scoreWeightsFromConfig(cfg map[string]interface{}) ScoreWeights {
+	techWeight, _ := cfg["technical_weight"].(float64)
+	fundWeight, _ := cfg["fundamental_weight"].(float64)
+	sentWeight, _ := cfg["sentiment_weight"].(float64)
+	structWeight, _ := cfg["structure_weight"].(float64)
+	if techWeight+fundWeight+sentWeight+structWeight <= 0 {
+		return ScoreWeights{Technical: 0.4, Fundamental: 0.25, Sentiment: 0.2, Structure: 0.15}
+	}
+	return ScoreWeights{Technical: techWeight, Fundamental: fundWeight, Sentiment: sentWeight, Structure: structWeight}
+}
+
+// normalizeScoreWeights 校验配置中的评分权重，缺省时写入默认权重，总和不为1时归一化
+func normalizeScoreWeights(config map[string]interface{}) {
+	techWeight, _ := config["technical_weight"].(float64)
+	fundWeight, _ := config["fundamental_weight"].(float64)
+	sentWeight, _ := config["sentiment_weight"].(float64)
+	structWeight, _ := config["structure_weight"].(float64)
+
+	totalWeight := techWeight + fundWeight + sentWeight + structWeight
+	if totalWeight == 0 {
+		// 使用默认权重
+		config["technical_weight"] = 0.4
+		config["fundamental_weight"] = 0.25
+		config["sentiment_weight"] = 0.2
+		config["structure_weight"] = 0.15
+	} else if math.Abs(totalWeight-1.0) > 0.001 {
+		// 归一化权重
+		config["technical_weight"] = techWeight / totalWeight
+		config["fundamental_weight"] = fundWeight / totalWeight
+		config["sentiment_weight"] = sentWeight / totalWeight
+		config["structure_weight"] = structWeight / totalWeight
+	}
+}
+
+// scoreSymbol 拉取行情并计算交易对评分
+func scoreSymbol(exchange Exchange, symbol, interval string, limit int, weights ScoreWeights) (*PairScore, error) {
+	klines, err := exchange.GetKlines(symbol, interval, limit)
+	if err != nil {
+		return nil, err
+	}
+	ticker, err := exchange.Get24hrTicker(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return ScorePair(symbol, klines, ticker, weights)
+}
+
+// Snapshot 生成评分快照
+func (ps *PairScore) Snapshot() ScoreSnapshot {
+	return ScoreSnapshot{
+		TotalScore:       ps.TotalScore,
+		TechnicalScore:   ps.TechnicalScore,
+		FundamentalScore: ps.FundamentalScore,
+		SentimentScore:   ps.SentimentScore,
+		StructureScore:   ps.StructureScore,
+		Price:            ps.Price,
+		Time:             ps.UpdatedAt,
+	}
+}
+
+// technicalScore 技术面评分：RSI动量、均线趋势、MACD柱
+func technicalScore(price float64, ind map[string]float64) float64 {
+	rsi := ind["rsi"]
+	var rsiScore float64
+	switch {
+	case rsi < 30:
+		rsiScore = 60 // 超卖，存在反弹空间
+	case rsi < 50:
+		rsiScore = 40 + (rsi - 30)
+	case rsi <= 70:
+		rsiScore = 60 + (rsi-50)*2
+	default:
+		rsiScore = 100 - (rsi-70)*3 // 超买，逐步扣分
+	}
+
+	sma20, sma50 := ind["sma20"], ind["sma50"]
+	var trendScore float64
+	switch {
+	case price > sma20 && sma20 > sma50:
+		trendScore = 100
+	case price > sma20:
+		trendScore = 70
+	case price > sma50:
+		trendScore = 50
+	default:
+		trendScore = 20
+	}
+
+	macdScore := 50.0
+	if price > 0 {
+		macdScore = 50 + ind["macd_histogram"]/price*5000
+	}
+
+	return clampScore(rsiScore*0.35 + trendScore*0.4 + clampScore(macdScore)*0.25)
+}
+
+// fundamentalScore 基本面评分：以24小时成交额衡量流动性，100万USDT为0分，10亿USDT为满分
+func fundamentalScore(ind map[string]float64) float64 {
+	quoteVolume := ind["quote_volume"]
+	if quoteVolume <= 0 {
+		return 0
+	}
+	return clampScore((math.Log10(quoteVolume) - 6) / 3 * 100)
+}
+
+// sentimentScore 情绪面评分：24小时涨跌幅与成交量放大程度
+func sentimentScore(ind map[string]float64) float64 {
+	change := ind["change_percent"]
+	changeScore := 50 + change*5
+	if change > 15 {
+		// 短时暴涨，追高风险大
+		changeScore = 100 - (change-15)*5
+	}
+
+	volumeScore := 50 + (ind["volume_ratio"]-1)*50
+
+	return clampScore(clampScore(changeScore)*0.6 + clampScore(volumeScore)*0.4)
+}
+
+// structureScore 结构面评分：价格在24小时区间中的位置与波动率
+func structureScore(price float64, ticker *TickerData, ind map[string]float64) float64 {
+	rangeScore := 50.0
+	if ticker.HighPrice > ticker.LowPrice {
+		position := (price - ticker.LowPrice) / (ticker.HighPrice - ticker.LowPrice)
+		rangeScore = 100 - math.Abs(position-0.6)*150
+	}
+
+	volScore := 100 - (ind["volatility"]-0.005)*4000
+
+	return clampScore(clampScore(rangeScore)*0.5 + clampScore(volScore)*0.5)
+}
+
+// calculateSMA 简单移动平均
+func calculateSMA(values []float64, period int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	if period > len(values) {
+		period = len(values)
+	}
+	var sum float64
+	for _, v := range values[len(values)-period:] {
+		sum += v
+	}
+	return sum / float64(period)
+}
+
+// calculateEMASeries 指数移动平均序列
+func calculateEMASeries(values []float64, period int) []float64 {
+	if len(values) == 0 || period <= 0 {
+		return nil
+	}
+	k := 2.0 / float64(period+1)
+	series := make([]float64, len(values))
+	series[0] = values[0]
+	for i := 1; i < len(values); i++ {
+		series[i] = values[i]*k + series[i-1]*(1-k)
+	}
+	return series
+}
+
+// calculateRSI 相对强弱指数（Wilder平滑）
+func calculateRSI(closes []float64, period int) float64 {
+	if len(closes) <= period {
+		return 50
+	}
+
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		if change > 0 {
+			gain += change
+		} else {
+			loss -= change
+		}
+	}
+	avgGain := gain / float64(period)
+	avgLoss := loss / float64(period)
+
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		var g, l float64
+		if change > 0 {
+			g = change
+		} else {
+			l = -change
+		}
+		avgGain = (avgGain*float64(period-1) + g) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + l) / float64(period)
+	}
+
+	if avgLoss == 0 {
+		return 100
+	}
+	rs := avgGain / avgLoss
+	return 100 - 100/(1+rs)
+}
+
+// calculateMACD 计算MACD(12,26,9)，返回MACD线、信号线、柱值
+func calculateMACD(closes []float64) (float64, float64, float64) {
+	if len(closes) < 26 {
+		return 0, 0, 0
+	}
+	fast := calculateEMASeries(closes, 12)
+	slow := calculateEMASeries(closes, 26)
+	macdLine := make([]float64, len(closes))
+	for i := range closes {
+		macdLine[i] = fast[i] - slow[i]
+	}
+	signalLine := calculateEMASeries(macdLine, 9)
+	macd := macdLine[len(macdLine)-1]
+	signal := signalLine[len(signalLine)-1]
+	return macd, signal, macd - signal
+}
+
+// calculateVolatility 最近period根K线收益率的标准差
+func calculateVolatility(closes []float64, period int) float64 {
+	if len(closes) < 2 {
+		return 0
+	}
+	if period >= len(closes) {
+		period = len(closes) - 1
+	}
+	returns := make([]float64, 0, period)
+	for i := len(closes) - period; i < len(closes); i++ {
+		if closes[i-1] > 0 {
+			returns = append(returns, (closes[i]-closes[i-1])/closes[i-1])
+		}
+	}
+	return stdDev(returns)
+}
+
+// calculateVolumeRatio 最新K线成交量与此前period根平均成交量之比
+func calculateVolumeRatio(klines []KlineData, period int) float64 {
+	if len(klines) < 2 {
+		return 1
+	}
+	if period >= len(klines) {
+		period = len(klines) - 1
+	}
+	var sum float64
+	for _, k := range klines[len(klines)-1-period : len(klines)-1] {
+		sum += k.Volume
+	}
+	avg := sum / float64(period)
+	if avg == 0 {
+		return 1
+	}
+	return klines[len(klines)-1].Volume / avg
+}
+
+// stdDev 标准差
+func stdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// clampScore 将分数限制在0-100之间
+func clampScore(score float64) float64 {
+	if math.IsNaN(score) {
+		return 0
+	}
+	return math.Max(0, math.Min(100, score))
+}
@@ -0,0 +1,589 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// RiskService 管理用户风控策略，按账户级限额监控账户并在触发时执行熔断
+type RiskService struct {
+	db               *gorm.DB
+	userService      *UserService
+	equityService    *EquityService
+	orderListService *OrderListService
+	orderSync        *OrderSyncService
+}
+
+func NewRiskService() *RiskService {
+	return &RiskService{
+		db:               config.DB,
+		userService:      NewUserService(),
+		equityService:    NewEquityService(),
+		orderListService: NewOrderListService(),
+		orderSync:        NewOrderSyncService(),
+	}
+}
+
+// LoadRiskPolicy 读取用户的风控策略，未设置时返回配置文件中的默认限额
+func LoadRiskPolicy(db *gorm.DB, userID uint) *models.RiskPolicy {
+	var policy models.RiskPolicy
+	if err := db.Where("user_id = ?", userID).First(&policy).Error; err == nil {
+		return &policy
+	}
+
+	policy = models.RiskPolicy{UserID: userID}
+	if config.AppConfig != nil {
+		policy.MaxOrderNotional = config.AppConfig.Risk.MaxOrderNotional
+		policy.MaxSymbolExposure = config.AppConfig.Risk.MaxSymbolExposure
+		policy.MaxPriceDeviationBP = config.AppConfig.Risk.MaxPriceDeviationBP
+		policy.DailyLossLimit = config.AppConfig.Risk.DailyLossLimit
+		policy.MaxDrawdownPercent = config.AppConfig.Risk.MaxDrawdownPercent
+		policy.MaxGrossExposure = config.AppConfig.Risk.MaxGrossExposure
+		policy.MaxOpenOrders = config.AppConfig.Risk.MaxOpenOrders
+		policy.FlattenOnBreach = config.AppConfig.Risk.FlattenOnBreach
+		policy.LiqTopUpDistance = config.AppConfig.Risk.LiqTopUpDistance
+		policy.LiqTargetDistance = config.AppConfig.Risk.LiqTargetDistance
+		policy.LiqMaxTopUp = config.AppConfig.Risk.LiqMaxTopUp
+		policy.LiqTransferFromSpot = config.AppConfig.Risk.LiqTransferFromSpot
+		policy.LiqReduceDistance = config.AppConfig.Risk.LiqReduceDistance
+		policy.LiqReduceRatio = config.AppConfig.Risk.LiqReduceRatio
+	}
+	return &policy
+}
+
+// GetPolicy 获取用户当前生效的风控策略
+func (rs *RiskService) GetPolicy(userID uint) *models.RiskPolicy {
+	return LoadRiskPolicy(rs.db, userID)
+}
+
+// UpdatePolicy 保存用户的风控策略，0表示不限制
+func (rs *RiskService) UpdatePolicy(userID uint, update *models.RiskPolicy) (*models.RiskPolicy, error) {
+	if update.MaxOrderNotional < 0 || update.MaxSymbolExposure < 0 || update.MaxPriceDeviationBP < 0 ||
+		update.DailyLossLimit < 0 || update.MaxGrossExposure < 0 || update.MaxOpenOrders < 0 {
+		return nil, errors.New("风控限额不能为负数")
+	}
+	if update.MaxDrawdownPercent < 0 || update.MaxDrawdownPercent >= 100 {
+		return nil, errors.New("最大回撤百分比必须在0-100之间")
+	}
+	if update.LiqTopUpDistance < 0 || update.LiqTargetDistance < 0 || update.LiqMaxTopUp < 0 || update.LiqReduceDistance < 0 {
+		return nil, errors.New("强平距离设置不能为负数")
+	}
+	if update.LiqReduceRatio < 0 || update.LiqReduceRatio > 100 {
+		return nil, errors.New("减仓比例必须在0-100之间")
+	}
+
+	policy := LoadRiskPolicy(rs.db, userID)
+	policy.MaxOrderNotional = update.MaxOrderNotional
+	policy.MaxSymbolExposure = update.MaxSymbolExposure
+	policy.MaxPriceDeviationBP = update.MaxPriceDeviationBP
+	policy.DailyLossLimit = update.DailyLossLimit
+	policy.MaxDrawdownPercent = update.MaxDrawdownPercent
+	policy.MaxGrossExposure = update.MaxGrossExposure
+	policy.MaxOpenOrders = update.MaxOpenOrders
+	policy.FlattenOnBreach = update.FlattenOnBreach
+	policy.LiqTopUpDistance = update.LiqTopUpDistance
+	policy.LiqTargetDistance = update.LiqTargetDistance
+	policy.LiqMaxTopUp = update.LiqMaxTopUp
+	policy.LiqTransferFromSpot = update.LiqTransferFromSpot
+	policy.LiqReduceDistance = update.LiqReduceDistance
+	policy.LiqReduceRatio = update.LiqReduceRatio
+	if err := rs.db.Save(policy).Error; err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// GetRejections 分页查询下单前风控拒绝记录，strategyID不为空时只查询该策略
+func (rs *RiskService) GetRejections(userID uint, strategyID *uint, page, limit int) ([]models.PreTradeRejection, int64, error) {
+	var rejections []models.PreTradeRejection
+	var total int64
+
+	query := rs.db.Model(&models.PreTradeRejection{}).Where("user_id = ?", userID)
+	if strategyID != nil {
+		query = query.Where("strategy_id = ?", *strategyID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&rejections).Error; err != nil {
+		return nil, 0, err
+	}
+	return rejections, total, nil
+}
+
+// Resume 解除熔断恢复交易，回撤峰值从恢复时起重新计算；已停止的策略需要用户手动重新启动
+func (rs *RiskService) Resume(userID uint) (*models.RiskPolicy, error) {
+	policy := LoadRiskPolicy(rs.db, userID)
+	if policy.HaltedAt == nil {
+		return nil, errors.New("账户未处于风控熔断状态")
+	}
+	now := time.Now()
+	policy.HaltedAt = nil
+	policy.HaltReason = ""
+	policy.ResumedAt = &now
+	if err := rs.db.Save(policy).Error; err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// GetBreaches 分页查询账户风控触发记录
+func (rs *RiskService) GetBreaches(userID uint, page, limit int) ([]models.RiskBreach, int64, error) {
+	var breaches []models.RiskBreach
+	var total int64
+
+	query := rs.db.Model(&models.RiskBreach{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&breaches).Error; err != nil {
+		return nil, 0, err
+	}
+	return breaches, total, nil
+}
+
+// GetLiquidationActions 获取强平距离监控的处置记录
+func (rs *RiskService) GetLiquidationActions(userID uint, symbol string, page, limit int) ([]models.LiquidationAction, int64, error) {
+	var actions []models.LiquidationAction
+	var total int64
+
+	query := rs.db.Model(&models.LiquidationAction{}).Where("user_id = ?", userID)
+	if symbol != "" {
+		query = query.Where("symbol = ?", symbol)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Offset(offset).Limit(limit).Order("created_at desc").Find(&actions).Error; err != nil {
+		return nil, 0, err
+	}
+	return actions, total, nil
+}
+
+// MonitorAccounts 按用户当前的交易模式检查账户级限额，返回触发熔断的账户数
+func (rs *RiskService) MonitorAccounts() (int, error) {
+	if rs.db == nil {
+		return 0, errors.New("数据库未连接")
+	}
+
+	var users []models.User
+	if err := rs.db.Select("id", "paper_trading", "api_key", "secret_key").
+		Where("status = ? AND (paper_trading = ? OR (api_key != '' AND secret_key != ''))", models.StatusActive, true).
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	breached := 0
+	for _, user := range users {
+		breach, err := rs.EvaluateAccount(user.ID, user.PaperTrading)
+		if err != nil {
+			log.Printf("检查用户%d账户风控失败: %v", user.ID, err)
+			continue
+		}
+		if breach != nil {
+			breached++
+		}
+	}
+	return breached, nil
+}
+
+// EvaluateAccount 检查账户是否超过限额，超过时执行熔断并返回触发记录；已熔断或未设置限额的账户不检查
+func (rs *RiskService) EvaluateAccount(userID uint, simulated bool) (*models.RiskBreach, error) {
+	policy := LoadRiskPolicy(rs.db, userID)
+	if policy.HaltedAt != nil || !policy.AccountLimitsEnabled() {
+		return nil, nil
+	}
+
+	breach, err := rs.checkAccountLimits(policy, userID, simulated)
+	if err != nil || breach == nil {
+		return nil, err
+	}
+	return rs.TriggerKillSwitch(policy, breach), nil
+}
+
+// checkAccountLimits 依次检查未完成订单数、当日亏损、总敞口和权益回撤，返回第一个超限的规则
+func (rs *RiskService) checkAccountLimits(policy *models.RiskPolicy, userID uint, simulated bool) (*models.RiskBreach, error) {
+	newBreach := func(rule models.RiskRule, value, limit float64, format string, args ...interface{}) *models.RiskBreach {
+		return &models.RiskBreach{
+			UserID:      userID,
+			IsSimulated: simulated,
+			Rule:        rule,
+			Value:       value,
+			Threshold:   limit,
+			Message:     fmt.Sprintf(format, args...),
+		}
+	}
+
+	if policy.MaxOpenOrders > 0 {
+		var spotOrders, futuresOrders int64
+		openStatuses := []string{"NEW", "PARTIALLY_FILLED"}
+		if err := rs.db.Model(&models.Order{}).Where("user_id = ? AND status IN ? AND is_simulated = ?", userID, openStatuses, simulated).
+			Count(&spotOrders).Error; err != nil {
+			return nil, err
+		}
+		if err := rs.db.Model(&models.FuturesOrder{}).Where("user_id = ? AND status IN ? AND is_simulated = ?", userID, openStatuses, simulated).
+			Count(&futuresOrders).Error; err != nil {
+			return nil, err
+		}
+		if count := spotOrders + futuresOrders; count > int64(policy.MaxOpenOrders) {
+			return newBreach(models.RiskRuleOpenOrders, float64(count), float64(policy.MaxOpenOrders),
+				"未完成订单%d个超过上限%d个", count, policy.MaxOpenOrders), nil
+		}
+	}
+
+	// 当日手动恢复交易后不再按当日亏损熔断，否则恢复后会立即再次触发
+	dayStart, _ := pnlPeriodStarts(time.Now())
+	if policy.DailyLossLimit > 0 && (policy.ResumedAt == nil || policy.ResumedAt.Before(dayStart)) {
+		ledger := &PnLLedgerService{db: rs.db, now: time.Now}
+		report, err := ledger.Report(PnLQuery{UserID: userID, Simulated: &simulated})
+		if err != nil {
+			return nil, err
+		}
+		if loss := -report.Daily.NetPnL; loss >= policy.DailyLossLimit {
+			return newBreach(models.RiskRuleDailyLoss, loss, policy.DailyLossLimit,
+				"当日已实现亏损%.2f %s达到上限%.2f", loss, PnLValuationAsset, policy.DailyLossLimit), nil
+		}
+	}
+
+	if policy.MaxGrossExposure > 0 {
+		exposure, err := rs.grossExposure(userID, simulated)
+		if err != nil {
+			return nil, err
+		}
+		if exposure > policy.MaxGrossExposure {
+			return newBreach(models.RiskRuleGrossExposure, exposure, policy.MaxGrossExposure,
+				"账户总敞口%.2f %s超过上限%.2f", exposure, PnLValuationAsset, policy.MaxGrossExposure), nil
+		}
+	}
+
+	if policy.MaxDrawdownPercent > 0 {
+		current, err := rs.equityService.MeasureEquity(userID, simulated)
+		if err != nil {
+			return nil, err
+		}
+		// 有资产无法估值时权益被低估，跳过回撤判断，避免误触发熔断
+		if current.UnpricedAssets != "" {
+			log.Printf("用户%d资产%s无法估值，跳过回撤检查", userID, current.UnpricedAssets)
+			return nil, nil
+		}
+		peak, err := rs.equityPeak(userID, simulated, policy.ResumedAt)
+		if err != nil {
+			return nil, err
+		}
+		peak = math.Max(peak, current.TotalEquity)
+		if peak > 0 {
+			drawdown := (peak - current.TotalEquity) / peak * 100
+			if drawdown >= policy.MaxDrawdownPercent {
+				return newBreach(models.RiskRuleMaxDrawdown, drawdown, policy.MaxDrawdownPercent,
+					"权益%.2f距峰值%.2f回撤%.2f%%达到上限%.2f%%", current.TotalEquity, peak, drawdown, policy.MaxDrawdownPercent), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// failedWithdrawStatuses 未扣减余额的提币状态：已取消、已拒绝、失败
+var failedWithdrawStatuses = []string{"1", "3", "5"}
+
+// equityPeak 权益快照峰值，峰值之后的提币按当前价格从峰值中扣除，避免提币被当作回撤。
+// 以提币时间把快照分段，每段的峰值减去该段之后的提币总额，取各段最大值
+func (rs *RiskService) equityPeak(userID uint, simulated bool, resumedAt *time.Time) (float64, error) {
+	snapshots := rs.db.Model(&models.EquitySnapshot{}).Where("user_id = ? AND is_simulated = ?", userID, simulated)
+	if resumedAt != nil {
+		snapshots = snapshots.Where("snapshot_time >= ?", *resumedAt)
+	}
+
+	// 模拟账户没有提币
+	var withdrawals []models.WithdrawalHistory
+	if !simulated {
+		query := rs.db.Where("user_id = ? AND status NOT IN ?", userID, failedWithdrawStatuses)
+		if resumedAt != nil {
+			query = query.Where("apply_time >= ?", resumedAt.Unix())
+		}
+		if err := query.Order("apply_time").Find(&withdrawals).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	amounts := make([]float64, len(withdrawals))
+	after := 0.0
+	var valuer *equityValuer
+	for i, w := range withdrawals {
+		value := w.Amount + w.Fee
+		if !strings.EqualFold(w.Asset, PnLValuationAsset) {
+			if valuer == nil {
+				exchange, err := rs.accountExchange(userID, simulated)
+				if err != nil {
+					return 0, err
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				valuer = &equityValuer{ctx: ctx, exchange: exchange, prices: make(map[string]float64)}
+			}
+			var ok bool
+			if value, ok = valuer.value(w.Asset, value); !ok {
+				return 0, fmt.Errorf("提币资产%s无法估值", w.Asset)
+			}
+		}
+		amounts[i] = value
+		after += value
+	}
+
+	peak := 0.0
+	from := time.Time{}
+	for i := 0; i <= len(withdrawals); i++ {
+		segment := snapshots.Session(&gorm.Session{}).Where("snapshot_time >= ?", from)
+		if i < len(withdrawals) {
+			to := time.Unix(withdrawals[i].ApplyTime, 0)
+			segment = segment.Where("snapshot_time < ?", to)
+			from = to
+		}
+		var segmentPeak float64
+		if err := segment.Select("COALESCE(MAX(total_equity), 0)").Scan(&segmentPeak).Error; err != nil {
+			return 0, err
+		}
+		if segmentPeak > 0 {
+			peak = math.Max(peak, segmentPeak-after)
+		}
+		if i < len(withdrawals) {
+			after -= amounts[i]
+		}
+	}
+	return peak, nil
+}
+
+// accountExchange 按交易模式取得不带下单前风控的交易所实例，熔断处置的撤单和平仓不应被风控拦截
+func (rs *RiskService) accountExchange(userID uint, simulated bool) (Exchange, error) {
+	if simulated {
+		return rs.userService.GetPaperExchange(userID), nil
+	}
+	return rs.userService.GetExchange(userID)
+}
+
+// grossExposure 账户总敞口：现货非稳定币资产估值加期货持仓名义价值，折算为USDT
+func (rs *RiskService) grossExposure(userID uint, simulated bool) (float64, error) {
+	exchange, err := rs.accountExchange(userID, simulated)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	valuer := &equityValuer{ctx: ctx, exchange: exchange, prices: make(map[string]float64)}
+	exposure := 0.0
+	spot, err := exchange.GetAccountInfo(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("获取现货账户失败: %w", err)
+	}
+	for _, balance := range spot.Balances {
+		amount := balance.Free + balance.Locked
+		if amount <= 0 || stableValuationAssets[strings.ToUpper(balance.Asset)] {
+			continue
+		}
+		if value, ok := valuer.value(balance.Asset, amount); ok {
+			exposure += value
+		}
+	}
+
+	// 未开通期货的账户查询会失败，期货敞口按0计入
+	positions, err := exchange.GetFuturesPositions(ctx)
+	if err != nil {
+		log.Printf("获取用户%d期货持仓失败，期货敞口按0计入: %v", userID, err)
+		return exposure, nil
+	}
+	for _, position := range positions {
+		price := position.MarkPrice
+		if price <= 0 {
+			price = position.EntryPrice
+		}
+		exposure += math.Abs(position.PositionAmt) * price
+	}
+	return exposure, nil
+}
+
+// TriggerKillSwitch 执行熔断：暂停开仓、停止全部策略、撤销当前模式下的挂单，按策略平掉期货持仓，并保存触发记录
+func (rs *RiskService) TriggerKillSwitch(policy *models.RiskPolicy, breach *models.RiskBreach) *models.RiskBreach {
+	userID, simulated := breach.UserID, breach.IsSimulated
+	log.Printf("用户%d触发账户风控[%s]: %s", userID, breach.Rule, breach.Message)
+
+	// 先暂停开仓，避免处置期间策略继续下单
+	now := time.Now()
+	policy.HaltedAt = &now
+	policy.HaltReason = breach.Message
+	if err := rs.db.Save(policy).Error; err != nil {
+		log.Printf("保存用户%d熔断状态失败: %v", userID, err)
+	}
+
+	var actionErrors []string
+	fail := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		log.Printf("用户%d风控处置失败: %s", userID, message)
+		actionErrors = append(actionErrors, message)
+	}
+
+	reason := "账户风控熔断: " + breach.Message
+	var strategies []models.Strategy
+	if err := rs.db.Where("user_id = ? AND status IN ?", userID, models.ActiveStrategyStatuses).Find(&strategies).Error; err != nil {
+		fail("查询策略: %v", err)
+	}
+	for i := range strategies {
+		if err := transitionSpotStrategy(rs.db, &strategies[i], models.StrategyStatusPaused, models.StrategyActorRisk, reason); err != nil {
+			fail("暂停策略%d: %v", strategies[i].ID, err)
+			continue
+		}
+		breach.StrategiesStopped++
+	}
+	var futuresStrategies []models.FuturesStrategy
+	if err := rs.db.Where("user_id = ? AND status IN ?", userID, models.ActiveStrategyStatuses).Find(&futuresStrategies).Error; err != nil {
+		fail("查询期货策略: %v", err)
+	}
+	for i := range futuresStrategies {
+		if err := transitionFuturesStrategy(rs.db, &futuresStrategies[i], models.StrategyStatusPaused, models.StrategyActorRisk, reason); err != nil {
+			fail("暂停期货策略%d: %v", futuresStrategies[i].ID, err)
+			continue
+		}
+		breach.StrategiesStopped++
+	}
+
+	exchange, err := rs.accountExchange(userID, simulated)
+	if err != nil {
+		fail("获取交易所实例: %v", err)
+	} else {
+		breach.OrdersCanceled = rs.cancelOpenOrders(userID, simulated, exchange, fail)
+		if policy.FlattenOnBreach {
+			breach.PositionsClosed = rs.flattenPositions(userID, simulated, exchange, fail)
+		}
+	}
+
+	breach.ActionErrors = strings.Join(actionErrors, "\n")
+	if err := rs.db.Create(breach).Error; err != nil {
+		log.Printf("保存用户%d风控触发记录失败: %v", userID, err)
+	}
+	log.Printf("用户%d熔断处置完成：停止策略%d个，撤销订单%d个，平仓%d个",
+		userID, breach.StrategiesStopped, breach.OrdersCanceled, breach.PositionsClosed)
+	return breach
+}
+
+// cancelOpenOrders 撤销括号单、OCO和其余未完成的现货及期货订单，返回撤销的订单数
+func (rs *RiskService) cancelOpenOrders(userID uint, simulated bool, exchange Exchange, fail func(string, ...interface{})) int {
+	ctx := context.Background()
+	openStatuses := []string{"NEW", "PARTIALLY_FILLED"}
+	canceled := 0
+
+	var brackets []models.BracketOrder
+	rs.db.Where("user_id = ? AND is_simulated = ? AND status IN ?", userID, simulated,
+		[]models.BracketStatus{models.BracketStatusPending, models.BracketStatusActive}).Find(&brackets)
+	for _, bracket := range brackets {
+		if _, err := rs.orderListService.CancelBracket(ctx, userID, bracket.ID); err != nil {
+			fail("撤销括号单%d: %v", bracket.ID, err)
+		}
+	}
+
+	var spotOrders []models.Order
+	if err := rs.db.Where("user_id = ? AND status IN ? AND is_simulated = ?", userID, openStatuses, simulated).
+		Find(&spotOrders).Error; err != nil {
+		fail("查询现货挂单: %v", err)
+	}
+	canceledLists := make(map[string]bool)
+	for _, order := range spotOrders {
+		if order.OrderListID != "" {
+			if canceledLists[order.OrderListID] {
+				continue
+			}
+			canceledLists[order.OrderListID] = true
+			if _, err := rs.orderListService.CancelOCO(ctx, userID, order.OrderListID); err != nil {
+				fail("撤销OCO订单%s: %v", order.OrderListID, err)
+				continue
+			}
+			canceled++
+			continue
+		}
+		result, err := exchange.CancelSpotOrder(ctx, order.Symbol, order.OrderID)
+		if err != nil {
+			fail("撤销现货订单%s: %v", order.OrderID, err)
+			continue
+		}
+		rs.orderSync.applyOrderResult(userID, MarketSpot, result, order.OrderID)
+		canceled++
+	}
+
+	var futuresOrders []models.FuturesOrder
+	if err := rs.db.Where("user_id = ? AND status IN ? AND is_simulated = ?", userID, openStatuses, simulated).
+		Find(&futuresOrders).Error; err != nil {
+		fail("查询期货挂单: %v", err)
+	}
+	for _, order := range futuresOrders {
+		result, err := exchange.CancelFuturesOrder(ctx, order.Symbol, order.OrderID)
+		if err != nil {
+			fail("撤销期货订单%s: %v", order.OrderID, err)
+			continue
+		}
+		rs.orderSync.applyOrderResult(userID, MarketFutures, result, order.OrderID)
+		canceled++
+	}
+	return canceled
+}
+
+// flattenPositions 以市价平掉全部期货持仓，返回提交平仓单的持仓数
+func (rs *RiskService) flattenPositions(userID uint, simulated bool, exchange Exchange, fail func(string, ...interface{})) int {
+	ctx := context.Background()
+	positions, err := exchange.GetFuturesPositions(ctx)
+	if err != nil {
+		fail("查询期货持仓: %v", err)
+		return 0
+	}
+
+	closed := 0
+	for _, position := range positions {
+		if position.PositionAmt == 0 {
+			continue
+		}
+		side := models.OrderSideSell
+		if position.PositionAmt < 0 {
+			side = models.OrderSideBuy
+		}
+		positionSide := models.PositionSide(strings.ToLower(position.PositionSide))
+		if positionSide == "" {
+			positionSide = models.PositionSideBoth
+		}
+		order := &models.FuturesOrder{
+			UserID:       userID,
+			Symbol:       position.Symbol,
+			Side:         side,
+			PositionSide: positionSide,
+			Type:         models.OrderTypeMarket,
+			Quantity:     math.Abs(position.PositionAmt),
+			ReduceOnly:   positionSide == models.PositionSideBoth,
+			IsSimulated:  simulated,
+		}
+		result, err := exchange.CreateFuturesOrder(ctx, order)
+		if err != nil {
+			fail("平仓%s %s: %v", position.Symbol, position.PositionSide, err)
+			continue
+		}
+		order.OrderID = result.OrderID
+		order.Status = models.OrderStatus(result.Status)
+		order.ExecutedQty = result.ExecutedQty
+		order.CumulativeQuoteQty = result.CumulativeQuoteQty
+		if err := rs.db.Create(order).Error; err != nil {
+			log.Printf("保存风控平仓单失败: %v", err)
+		}
+		closed++
+	}
+	return closed
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+// GridSpacing 网格价格间隔方式
+type GridSpacing string
+
+const (
+	GridSpacingArithmetic GridSpacing = "arithmetic" // 等差：相邻价格相差固定金额
+	GridSpacingGeometric  GridSpacing = "geometric"  // 等比：相邻价格相差固定比例
+)
+
+// gridStateKey 网格状态在Strategy.State中的键
+const gridStateKey = "grid"
+
+// GridSlot 相邻两个价格层之间的一格。没有持仓时在下沿挂买单，买单成交后在上沿挂卖单，卖单成交即完成一次套利
+type GridSlot struct {
+	Index      int     `json:"index"`
+	Lower      float64 `json:"lower"`
+	Upper      float64 `json:"upper"`
+	Side       string  `json:"side,omitempty"`     // 当前挂单方向，为空表示未挂单
+	OrderID    string  `json:"order_id,omitempty"` // 当前挂单
+	Holding    float64 `json:"holding"`            // 买单成交后待卖出的数量
+	Cost       float64 `json:"cost"`               // 待卖出数量的买入成本（计价资产，含手续费）
+	Profit     float64 `json:"profit"`             // 该格累计已实现利润（计价资产）
+	RoundTrips int     `json:"round_trips"`        // 该格完成的买卖次数
+}
+
+// GridState 网格运行状态，配置变化时重建
+type GridState struct {
+	LowerPrice  float64     `json:"lower_price"`
+	UpperPrice  float64     `json:"upper_price"`
+	GridCount   int         `json:"grid_count"`
+	Spacing     GridSpacing `json:"spacing"`
+	Levels      []float64   `json:"levels"`
+	Slots       []*GridSlot `json:"slots"`
+	TotalProfit float64     `json:"total_profit"`
+	RoundTrips  int         `json:"round_trips"`
+}
+
+// gridConfig 网格策略配置
+type gridConfig struct {
+	lowerPrice float64
+	upperPrice float64
+	gridCount  int
+	spacing    GridSpacing
+	compound   bool
+}
+
+func parseGridConfig(config map[string]interface{}) (*gridConfig, error) {
+	cfg := &gridConfig{spacing: GridSpacingArithmetic}
+	cfg.upperPrice, _ = config["upper_price"].(float64)
+	cfg.lowerPrice, _ = config["lower_price"].(float64)
+	gridCount, _ := config["grid_count"].(float64)
+	cfg.gridCount = int(gridCount)
+	if cfg.lowerPrice <= 0 || cfg.upperPrice <= cfg.lowerPrice || cfg.gridCount < 2 {
+		return nil, errors.New("网格配置无效")
+	}
+
+	if spacing, ok := config["spacing"].(string); ok && spacing != "" {
+		switch GridSpacing(strings.ToLower(spacing)) {
+		case GridSpacingArithmetic:
+		case GridSpacingGeometric:
+			cfg.spacing = GridSpacingGeometric
+		default:
+			return nil, fmt.Errorf("不支持的网格间隔方式: %s", spacing)
+		}
+	}
+	cfg.compound, _ = config["compound"].(bool)
+	return cfg, nil
+}
+
+// gridLevels 计算从下限到上限（含两端）的gridCount个价格层
+func gridLevels(lower, upper float64, count int, spacing GridSpacing) []float64 {
+	levels := make([]float64, count)
+	for i := 0; i < count; i++ {
+		ratio := float64(i) / float64(count-1)
+		if spacing == GridSpacingGeometric {
+			levels[i] = lower * math.Pow(upper/lower, ratio)
+		} else {
+			levels[i] = lower + (upper-lower)*ratio
+		}
+	}
+	levels[count-1] = upper
+	return levels
+}
+
+func newGridState(cfg *gridConfig) *GridState {
+	state := &GridState{
+		LowerPrice: cfg.lowerPrice,
+		UpperPrice: cfg.upperPrice,
+		GridCount:  cfg.gridCount,
+		Spacing:    cfg.spacing,
+		Levels:     gridLevels(cfg.lowerPrice, cfg.upperPrice, cfg.gridCount, cfg.spacing),
+	}
+	for i := 0; i < cfg.gridCount-1; i++ {
+		state.Slots = append(state.Slots, &GridSlot{Index: i, Lower: state.Levels[i], Upper: state.Levels[i+1]})
+	}
+	return state
+}
+
+// matches 配置是否与当前网格一致
+func (gs *GridState) matches(cfg *gridConfig) bool {
+	return gs.GridCount == cfg.gridCount && gs.Spacing == cfg.spacing &&
+		gs.LowerPrice == cfg.lowerPrice && gs.UpperPrice == cfg.upperPrice && len(gs.Slots) == cfg.gridCount-1
+}
+
+// loadGridState 从Strategy.State读取网格状态，不存在时返回nil
+func loadGridState(strategy *models.Strategy) *GridState {
+	if strategy.State == nil || strategy.State[gridStateKey] == nil {
+		return nil
+	}
+	var state GridState
+	if err := decodeStateValue(strategy.State[gridStateKey], &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+// executeGridStrategy 现货网格：处理已结束的网格挂单并挂出反向单，再为空闲的格子补挂单。
+// 每格数量为strategy.Quantity平均分到grid_count-1格，开启compound后该格的已实现利润会加到后续买单数量中
+func (ss *StrategyService) executeGridStrategy(strategy *models.Strategy, exchange Exchange) error {
+	cfg, err := parseGridConfig(strategy.Config)
+	if err != nil {
+		return err
+	}
+
+	currentPrice, err := exchange.GetPrice(context.Background(), strategy.Symbol)
+	if err != nil {
+		return err
+	}
+
+	base, quote := splitSymbolBySuffix(strings.ToUpper(strategy.Symbol))
+	var info *SymbolInfo
+	if symbolInfo, err := exchange.GetSymbolInfo(strategy.Symbol); err == nil {
+		info = symbolInfo
+		if info.BaseAsset != "" {
+			base, quote = info.BaseAsset, info.QuoteAsset
+		}
+	}
+
+	state := loadGridState(strategy)
+	if state == nil || !state.matches(cfg) {
+		if state != nil {
+			// 网格参数被修改，撤销旧网格的挂单后按新参数重建，已实现利润和持仓保留。旧网格订单未全部结束时下一轮重试
+			if !ss.cancelGridOrders(strategy, state, exchange, base, quote) {
+				if err := ss.saveGridState(strategy, state); err != nil {
+					return err
+				}
+				return errors.New("旧网格订单尚未全部撤销，下一轮重试")
+			}
+			state = rebuildGridState(state, cfg)
+		} else {
+			state = newGridState(cfg)
+		}
+	}
+
+	for _, slot := range state.Slots {
+		if slot.OrderID == "" {
+			continue
+		}
+		if err := ss.settleGridOrder(state, slot, base, quote); err != nil {
+			log.Printf("处理网格策略%d第%d格订单失败: %v", strategy.ID, slot.Index, err)
+		}
+	}
+
+	// 价格超出网格区间时暂停开新仓，已有持仓的卖单照常挂出
+	inRange := currentPrice >= cfg.lowerPrice && currentPrice <= cfg.upperPrice
+	baseQuantity := strategy.Quantity / float64(len(state.Slots))
+	for _, slot := range state.Slots {
+		if slot.OrderID != "" {
+			continue
+		}
+
+		side, price, quantity := models.OrderSideSell, slot.Upper, slot.Holding
+		if slot.Holding <= 0 {
+			// 只在价格高于下沿时挂买单，避免买单立即以市价成交
+			if !inRange || currentPrice <= slot.Lower {
+				continue
+			}
+			side, price, quantity = models.OrderSideBuy, slot.Lower, baseQuantity
+			if cfg.compound && slot.Profit > 0 {
+				quantity += slot.Profit / slot.Lower
+			}
+		}
+
+		price, quantity = roundGridOrder(info, price, quantity)
+		if quantity <= 0 || (info != nil && (quantity < info.MinQty || price*quantity < info.MinNotional)) {
+			continue
+		}
+
+		order, err := ss.placeGridOrder(strategy, exchange, side, price, quantity)
+		if err != nil {
+			log.Printf("创建网格策略%d第%d格%s单失败: %v", strategy.ID, slot.Index, side, err)
+			continue
+		}
+		slot.Side = string(side)
+		slot.OrderID = order.OrderID
+	}
+
+	return ss.saveGridState(strategy, state)
+}
+
+// settleGridOrder 处理已结束的网格挂单：买单成交计入持仓，卖单成交按持仓成本结转利润。
+// 被撤销或过期的订单只结算已成交部分，格子回到空闲状态等待重新挂单
+func (ss *StrategyService) settleGridOrder(state *GridState, slot *GridSlot, base, quote string) error {
+	var order models.Order
+	if err := ss.db.Where("order_id = ?", slot.OrderID).First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slot.OrderID, slot.Side = "", ""
+			return nil
+		}
+		return err
+	}
+	if !isFinalOrderStatus(string(order.Status)) {
+		return nil
+	}
+
+	executed := order.ExecutedQty
+	quoteQty := order.CumulativeQuoteQty
+	if quoteQty <= 0 {
+		quoteQty = executed * order.Price
+	}
+	baseFee, quoteFee := ss.orderFees(order.OrderID, base, quote)
+
+	if executed > 0 {
+		if order.Side == models.OrderSideBuy {
+			slot.Holding += executed - baseFee
+			slot.Cost += quoteQty + quoteFee
+		} else if slot.Holding > 0 {
+			sold := math.Min(executed+baseFee, slot.Holding)
+			if order.Status == "FILLED" {
+				// 完全成交后不足一个步长的余量计入成本
+				sold = slot.Holding
+			}
+			cost := slot.Cost * sold / slot.Holding
+			profit := quoteQty - quoteFee - cost
+
+			slot.Holding -= sold
+			slot.Cost -= cost
+			slot.Profit += profit
+			state.TotalProfit += profit
+			if slot.Holding <= 1e-12 {
+				slot.Holding, slot.Cost = 0, 0
+				slot.RoundTrips++
+				state.RoundTrips++
+			}
+		}
+	}
+
+	slot.OrderID, slot.Side = "", ""
+	return nil
+}
+
+// orderFees 订单以基础资产和计价资产支付的手续费，其他资产支付的手续费不计入网格利润
+func (ss *StrategyService) orderFees(orderID, base, quote string) (float64, float64) {
+	var trades []models.Trade
+	if err := ss.db.Where("order_id = ? AND market = ?", orderID, string(MarketSpot)).Find(&trades).Error; err != nil {
+		return 0, 0
+	}
+
+	var baseFee, quoteFee float64
+	for _, trade := range trades {
+		switch strings.ToUpper(trade.CommissionAsset) {
+		case base:
+			baseFee += trade.Commission
+		case quote:
+			quoteFee += trade.Commission
+		}
+	}
+	return baseFee, quoteFee
+}
+
+// roundGridOrder 按最小价格变动单位和数量步长取整，没有交易对信息时原样返回
+func roundGridOrder(info *SymbolInfo, price, quantity float64) (float64, float64) {
+	if info == nil {
+		return price, quantity
+	}
+	if info.TickSize > 0 {
+		price = roundToStep(price, info.TickSize, "round")
+	} else if info.PricePrecision > 0 {
+		price = utils.RoundTo(price, info.PricePrecision)
+	}
+	if info.StepSize > 0 {
+		quantity = roundToStep(quantity, info.StepSize, "floor")
+	}
+	return price, quantity
+}
+
+func (ss *StrategyService) placeGridOrder(strategy *models.Strategy, exchange Exchange, side models.OrderSide, price, quantity float64) (*models.Order, error) {
+	order := &models.Order{
+		UserID:        strategy.UserID,
+		StrategyID:    &strategy.ID,
+		Symbol:        strategy.Symbol,
+		Side:          side,
+		Type:          models.OrderTypeLimit,
+		Quantity:      quantity,
+		Price:         price,
+		TimeInForce:   "GTC",
+		ClientOrderID: utils.GenerateUUID(),
+	}
+
+	resp, err := exchange.CreateSpotOrder(context.Background(), order)
+	if err != nil {
+		return nil, err
+	}
+
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	// 挂单可能立即成交，记录成交数量供下一轮结算
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
+
+	if err := ss.db.Create(order).Error; err != nil {
+		log.Printf("保存订单失败: %v", err)
+	}
+	return order, nil
+}
+
+// cancelGridOrders 撤销网格的全部挂单并结算已成交部分。撤单失败时按订单最新状态结算，
+// 订单仍未结束则保留在格子上并返回false，由调用方下一轮重试
+func (ss *StrategyService) cancelGridOrders(strategy *models.Strategy, state *GridState, exchange Exchange, base, quote string) bool {
+	ctx := context.Background()
+	orderSync := NewOrderSyncService()
+	pending := 0
+	for _, slot := range state.Slots {
+		if slot.OrderID == "" {
+			continue
+		}
+		resp, err := exchange.CancelSpotOrder(ctx, strategy.Symbol, slot.OrderID)
+		if err != nil {
+			log.Printf("撤销网格策略%d订单%s失败: %v", strategy.ID, slot.OrderID, err)
+			if resp, err = exchange.GetSpotOrderStatus(ctx, strategy.Symbol, slot.OrderID); err != nil {
+				log.Printf("查询网格策略%d订单%s失败: %v", strategy.ID, slot.OrderID, err)
+				pending++
+				continue
+			}
+			orderSync.applyOrderResult(strategy.UserID, MarketSpot, resp, slot.OrderID)
+		} else {
+			ss.db.Model(&models.Order{}).Where("order_id = ?", slot.OrderID).Updates(map[string]interface{}{
+				"status":               resp.Status,
+				"executed_qty":         resp.ExecutedQty,
+				"cumulative_quote_qty": resp.CumulativeQuoteQty,
+			})
+		}
+		if err := ss.settleGridOrder(state, slot, base, quote); err != nil {
+			log.Printf("处理网格策略%d第%d格订单失败: %v", strategy.ID, slot.Index, err)
+		}
+		if slot.OrderID != "" {
+			pending++
+		}
+	}
+	return pending == 0
+}
+
+// rebuildGridState 按新参数重建网格，保留已实现利润。旧网格各格的持仓连同成本转入新网格中包含其持仓均价的格子
+// （超出新区间的归入最近的格子），由该格在上沿挂卖单卖出
+func rebuildGridState(old *GridState, cfg *gridConfig) *GridState {
+	state := newGridState(cfg)
+	state.TotalProfit, state.RoundTrips = old.TotalProfit, old.RoundTrips
+	for _, held := range old.Slots {
+		if held.Holding <= 0 {
+			continue
+		}
+		average := held.Cost / held.Holding
+		target := state.Slots[len(state.Slots)-1]
+		for _, slot := range state.Slots {
+			if average < slot.Upper {
+				target = slot
+				break
+			}
+		}
+		target.Holding += held.Holding
+		target.Cost += held.Cost
+	}
+	return state
+}
+
+func (ss *StrategyService) saveGridState(strategy *models.Strategy, state *GridState) error {
+	strategyState := strategy.State
+	if strategyState == nil {
+		strategyState = make(models.StrategyState)
+	}
+	strategyState[gridStateKey] = encodeStateValue(state)
+	strategyState["grid_profit"] = state.TotalProfit
+	strategyState["grid_round_trips"] = state.RoundTrips
+	return ss.db.Model(strategy).Update("state", strategyState).Error
+}
+
+// gridStats 网格策略的利润汇总和每格明细
+func gridStats(strategy *models.Strategy) map[string]interface{} {
+	state := loadGridState(strategy)
+	if state == nil {
+		return nil
+	}
+
+	var holding, activeOrders int
+	for _, slot := range state.Slots {
+		if slot.Holding > 0 {
+			holding++
+		}
+		if slot.OrderID != "" {
+			activeOrders++
+		}
+	}
+	return map[string]interface{}{
+		"spacing":       state.Spacing,
+		"levels":        state.Levels,
+		"total_profit":  state.TotalProfit,
+		"round_trips":   state.RoundTrips,
+		"holding_slots": holding,
+		"active_orders": activeOrders,
+		"slots":         state.Slots,
+	}
+}
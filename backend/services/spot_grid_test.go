@@ -0,0 +1,219 @@
+package services
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建迁移了指定模型的内存SQLite数据库
+func newTestDB(t *testing.T, tables ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取测试数据库连接失败: %v", err)
+	}
+	// 内存数据库按连接隔离，只使用一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db
+}
+
+func TestSettleGridOrder(t *testing.T) {
+	tests := []struct {
+		name           string
+		slot           GridSlot
+		order          *models.Order
+		trades         []models.Trade
+		wantHolding    float64
+		wantCost       float64
+		wantProfit     float64
+		wantRoundTrips int
+		wantPending    bool
+	}{
+		{
+			name:        "buy fill adds holding net of base fee",
+			slot:        GridSlot{Lower: 100, Upper: 110},
+			order:       &models.Order{Side: models.OrderSideBuy, Status: "FILLED", Price: 100, ExecutedQty: 1, CumulativeQuoteQty: 100},
+			trades:      []models.Trade{{Commission: 0.001, CommissionAsset: "BTC"}},
+			wantHolding: 0.999,
+			wantCost:    100,
+		},
+		{
+			name:        "buy fill adds quote fee to cost and ignores other assets",
+			slot:        GridSlot{Lower: 100, Upper: 110},
+			order:       &models.Order{Side: models.OrderSideBuy, Status: "FILLED", Price: 100, ExecutedQty: 1, CumulativeQuoteQty: 100},
+			trades:      []models.Trade{{Commission: 0.1, CommissionAsset: "USDT"}, {Commission: 0.01, CommissionAsset: "BNB"}},
+			wantHolding: 1,
+			wantCost:    100.1,
+		},
+		{
+			name:        "buy fill without quote quantity uses limit price",
+			slot:        GridSlot{Lower: 100, Upper: 110},
+			order:       &models.Order{Side: models.OrderSideBuy, Status: "FILLED", Price: 100, ExecutedQty: 0.5},
+			wantHolding: 0.5,
+			wantCost:    50,
+		},
+		{
+			name:           "sell fill realizes profit and completes round trip",
+			slot:           GridSlot{Lower: 100, Upper: 110, Holding: 1, Cost: 100},
+			order:          &models.Order{Side: models.OrderSideSell, Status: "FILLED", Price: 110, ExecutedQty: 1, CumulativeQuoteQty: 110},
+			trades:         []models.Trade{{Commission: 0.11, CommissionAsset: "USDT"}},
+			wantProfit:     110 - 0.11 - 100,
+			wantRoundTrips: 1,
+		},
+		{
+			name:           "filled sell sweeps residual below one step",
+			slot:           GridSlot{Lower: 100, Upper: 110, Holding: 1.0004, Cost: 100.04},
+			order:          &models.Order{Side: models.OrderSideSell, Status: "FILLED", Price: 110, ExecutedQty: 1, CumulativeQuoteQty: 110},
+			wantProfit:     110 - 100.04,
+			wantRoundTrips: 1,
+		},
+		{
+			name:        "cancelled sell settles partial fill at pro-rata cost",
+			slot:        GridSlot{Lower: 100, Upper: 110, Holding: 2, Cost: 200},
+			order:       &models.Order{Side: models.OrderSideSell, Status: "CANCELED", Price: 110, ExecutedQty: 1, CumulativeQuoteQty: 110},
+			wantHolding: 1,
+			wantCost:    100,
+			wantProfit:  10,
+		},
+		{
+			name:        "open order leaves slot pending",
+			slot:        GridSlot{Lower: 100, Upper: 110},
+			order:       &models.Order{Side: models.OrderSideBuy, Status: "PARTIALLY_FILLED", Price: 100, ExecutedQty: 0.5, CumulativeQuoteQty: 50},
+			wantPending: true,
+		},
+		{
+			name:        "missing order frees slot",
+			slot:        GridSlot{Lower: 100, Upper: 110, Holding: 1, Cost: 100},
+			wantHolding: 1,
+			wantCost:    100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &models.Order{}, &models.Trade{})
+			ss := &StrategyService{db: db}
+
+			slot := tt.slot
+			slot.OrderID, slot.Side = "1001", "PENDING"
+			if tt.order != nil {
+				tt.order.OrderID, tt.order.Symbol = slot.OrderID, "BTCUSDT"
+				if err := db.Create(tt.order).Error; err != nil {
+					t.Fatalf("保存订单失败: %v", err)
+				}
+			}
+			for i, trade := range tt.trades {
+				trade.OrderID, trade.Symbol, trade.Market = slot.OrderID, "BTCUSDT", string(MarketSpot)
+				trade.TradeID = strconv.Itoa(i + 1)
+				if err := db.Create(&trade).Error; err != nil {
+					t.Fatalf("保存成交失败: %v", err)
+				}
+			}
+
+			state := &GridState{Slots: []*GridSlot{&slot}}
+			if err := ss.settleGridOrder(state, &slot, "BTC", "USDT"); err != nil {
+				t.Fatalf("settleGridOrder() error = %v", err)
+			}
+
+			if tt.wantPending {
+				if slot.OrderID == "" {
+					t.Fatal("open order was released from the slot")
+				}
+				return
+			}
+			if slot.OrderID != "" || slot.Side != "" {
+				t.Errorf("slot still holds order %q (%s)", slot.OrderID, slot.Side)
+			}
+			if math.Abs(slot.Holding-tt.wantHolding) > 1e-9 {
+				t.Errorf("holding = %v, want %v", slot.Holding, tt.wantHolding)
+			}
+			if math.Abs(slot.Cost-tt.wantCost) > 1e-9 {
+				t.Errorf("cost = %v, want %v", slot.Cost, tt.wantCost)
+			}
+			if math.Abs(slot.Profit-tt.wantProfit) > 1e-9 || math.Abs(state.TotalProfit-tt.wantProfit) > 1e-9 {
+				t.Errorf("profit = %v (total %v), want %v", slot.Profit, state.TotalProfit, tt.wantProfit)
+			}
+			if slot.RoundTrips != tt.wantRoundTrips || state.RoundTrips != tt.wantRoundTrips {
+				t.Errorf("round trips = %d (total %d), want %d", slot.RoundTrips, state.RoundTrips, tt.wantRoundTrips)
+			}
+		})
+	}
+}
+
+func TestRebuildGridState(t *testing.T) {
+	// 新网格的价格层为100、110、120、130
+	cfg := &gridConfig{lowerPrice: 100, upperPrice: 130, gridCount: 4, spacing: GridSpacingArithmetic}
+
+	tests := []struct {
+		name        string
+		held        []GridSlot
+		wantHolding []float64
+		wantCost    []float64
+	}{
+		{
+			name:        "no holdings",
+			held:        []GridSlot{{Lower: 90, Upper: 95}},
+			wantHolding: []float64{0, 0, 0},
+			wantCost:    []float64{0, 0, 0},
+		},
+		{
+			name:        "holding moves to the slot containing its average cost",
+			held:        []GridSlot{{Holding: 1, Cost: 112}, {Holding: 2, Cost: 202}},
+			wantHolding: []float64{2, 1, 0},
+			wantCost:    []float64{202, 112, 0},
+		},
+		{
+			name:        "holdings outside the new range go to the nearest slot",
+			held:        []GridSlot{{Holding: 1, Cost: 90}, {Holding: 1, Cost: 150}},
+			wantHolding: []float64{1, 0, 1},
+			wantCost:    []float64{90, 0, 150},
+		},
+		{
+			name:        "holdings in the same slot are merged",
+			held:        []GridSlot{{Holding: 1, Cost: 121}, {Holding: 0.5, Cost: 62.5}},
+			wantHolding: []float64{0, 0, 1.5},
+			wantCost:    []float64{0, 0, 183.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := &GridState{TotalProfit: 12.5, RoundTrips: 3}
+			for i := range tt.held {
+				slot := tt.held[i]
+				old.Slots = append(old.Slots, &slot)
+			}
+
+			state := rebuildGridState(old, cfg)
+			if !state.matches(cfg) {
+				t.Fatalf("rebuilt state does not match config: %+v", state)
+			}
+			if state.TotalProfit != 12.5 || state.RoundTrips != 3 {
+				t.Errorf("profit/round trips = %v/%d, want 12.5/3", state.TotalProfit, state.RoundTrips)
+			}
+			for i, slot := range state.Slots {
+				if math.Abs(slot.Holding-tt.wantHolding[i]) > 1e-9 || math.Abs(slot.Cost-tt.wantCost[i]) > 1e-9 {
+					t.Errorf("slot %d holding/cost = %v/%v, want %v/%v", i, slot.Holding, slot.Cost, tt.wantHolding[i], tt.wantCost[i])
+				}
+				if slot.OrderID != "" {
+					t.Errorf("slot %d carries order %q", i, slot.OrderID)
+				}
+			}
+		})
+	}
+}
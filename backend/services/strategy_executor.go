@@ -0,0 +1,1158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// executorTickInterval 执行器轮询间隔，各策略按自身update_interval决定是否运行
+	executorTickInterval = 10 * time.Second
+	// maxScoreHistory 每个交易对保留的评分历史数量
+	maxScoreHistory = 500
+	// maxClosedTrades State中保留的已平仓交易数量
+	maxClosedTrades = 200
+	// minQuantOrderUSDT 量化策略单笔最小下单金额
+	minQuantOrderUSDT = 10.0
+	// topSymbolsTTL 默认交易对列表的缓存时间
+	topSymbolsTTL = time.Hour
+)
+
+// QuantitativeConfig 量化策略配置（由validateQuantitativeStrategy补全默认值）
+type QuantitativeConfig struct {
+	Symbols           []string     `json:"symbols"`
+	MaxPositions      int          `json:"max_positions"`
+	TotalCapital      float64      `json:"total_capital_usdt"`
+	RiskPreference    string       `json:"risk_preference"`
+	UpdateInterval    int          `json:"update_interval"`
+	Weights           ScoreWeights `json:"weights"`
+	MaxDrawdown       float64      `json:"max_drawdown"`
+	StopLossPercent   float64      `json:"stop_loss_percent"`
+	TakeProfitPercent float64      `json:"take_profit_percent"`
+	MaxPositionSize   float64      `json:"max_position_size"`
+	EntryScore        float64      `json:"entry_score"`
+	ExitScore         float64      `json:"exit_score"`
+}
+
+// QuantPosition 量化策略持仓
+type QuantPosition struct {
+	Symbol        string    `json:"symbol"`
+	Quantity      float64   `json:"quantity"`
+	EntryPrice    float64   `json:"entry_price"`
+	CostUSDT      float64   `json:"cost_usdt"`
+	CurrentPrice  float64   `json:"current_price"`
+	UnrealizedPnL float64   `json:"unrealized_pnl"`
+	PnLPercent    float64   `json:"pnl_percent"`
+	EntryScore    float64   `json:"entry_score"`
+	OrderID       string    `json:"order_id"`
+	OpenedAt      time.Time `json:"opened_at"`
+}
+
+// QuantTrade 量化策略已平仓交易
+type QuantTrade struct {
+	Symbol     string    `json:"symbol"`
+	Quantity   float64   `json:"quantity"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	PnL        float64   `json:"pnl"`
+	PnLPercent float64   `json:"pnl_percent"`
+	Reason     string    `json:"reason"`
+	OpenedAt   time.Time `json:"opened_at"`
+	ClosedAt   time.Time `json:"closed_at"`
+}
+
+// QuantDailyStats 量化策略当日统计
+type QuantDailyStats struct {
+	Date        string  `json:"date"`
+	StartEquity float64 `json:"start_equity"`
+	RealizedPnL float64 `json:"realized_pnl"`
+	Trades      int     `json:"trades"`
+	Wins        int     `json:"wins"`
+	Losses      int     `json:"losses"`
+}
+
+// QuantitativeEngine 单个量化策略的运行时状态
+type QuantitativeEngine struct {
+	strategyID uint
+	userID     uint
+
+	mu           sync.RWMutex
+	runMu        sync.Mutex
+	config       QuantitativeConfig
+	scores       map[string]*PairScore
+	history      map[string][]ScoreSnapshot
+	positions    map[string]*QuantPosition
+	closedTrades []QuantTrade
+	cash         float64
+	peakEquity   float64
+	dailyStats   QuantDailyStats
+	halted       bool
+	haltReason   string
+	lastRun      time.Time
+	lastError    string
+
+	topSymbols          []string
+	topSymbolsUpdatedAt time.Time
+}
+
+// StrategyExecutor 量化策略执行器，负责调度所有活跃的量化策略
+type StrategyExecutor struct {
+	db              *gorm.DB
+	userService     *UserService
+	strategyService *StrategyService
+
+	mu      sync.RWMutex
+	engines map[uint]*QuantitativeEngine
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running bool
+}
+
+func NewStrategyExecutor(db *gorm.DB) *StrategyExecutor {
+	return &StrategyExecutor{
+		db:              db,
+		userService:     NewUserService(),
+		strategyService: NewStrategyService(),
+		engines:         make(map[uint]*QuantitativeEngine),
+	}
+}
+
+// Start 启动执行器
+func (se *StrategyExecutor) Start() error {
+	if se.db == nil {
+		return errors.New("数据库未连接")
+	}
+
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	if se.running {
+		return nil
+	}
+
+	se.ctx, se.cancel = context.WithCancel(context.Background())
+	se.running = true
+
+	se.wg.Add(1)
+	go se.run()
+
+	log.Println("量化策略执行器已启动")
+	return nil
+}
+
+// Stop 停止执行器并保存所有策略状态
+func (se *StrategyExecutor) Stop() {
+	se.mu.Lock()
+	if !se.running {
+		se.mu.Unlock()
+		return
+	}
+	se.running = false
+	se.cancel()
+	se.mu.Unlock()
+
+	se.wg.Wait()
+
+	se.mu.RLock()
+	for _, engine := range se.engines {
+		se.saveEngineState(engine)
+	}
+	se.mu.RUnlock()
+
+	log.Println("量化策略执行器已停止")
+}
+
+func (se *StrategyExecutor) run() {
+	defer se.wg.Done()
+
+	ticker := time.NewTicker(executorTickInterval)
+	defer ticker.Stop()
+
+	se.tick()
+	for {
+		select {
+		case <-se.ctx.Done():
+			return
+		case <-ticker.C:
+			se.tick()
+		}
+	}
+}
+
+// tick 加载活跃的量化策略，并运行已到更新间隔的策略
+func (se *StrategyExecutor) tick() {
+	var strategies []models.Strategy
+	if err := se.db.Where("type = ? AND status IN ?",
+		models.StrategyQuantitative, models.ActiveStrategyStatuses).Find(&strategies).Error; err != nil {
+		log.Printf("加载量化策略失败: %v", err)
+		return
+	}
+
+	active := make(map[uint]bool, len(strategies))
+	for i := range strategies {
+		strategy := &strategies[i]
+		active[strategy.ID] = true
+
+		engine := se.loadEngine(strategy)
+		if engine.resume() {
+			log.Printf("量化策略%d已恢复运行，以当前权益作为新的回撤基准", strategy.ID)
+			se.saveEngineState(engine)
+		}
+		if !engine.due() {
+			continue
+		}
+
+		if err := se.runEngine(engine, strategy); err != nil {
+			log.Printf("量化策略%d执行失败: %v", strategy.ID, err)
+		}
+	}
+
+	// 移除已停用策略的运行时状态
+	se.mu.Lock()
+	for id, engine := range se.engines {
+		if !active[id] {
+			se.saveEngineState(engine)
+			delete(se.engines, id)
+		}
+	}
+	se.mu.Unlock()
+}
+
+// loadEngine 获取或创建策略引擎，并同步最新配置
+func (se *StrategyExecutor) loadEngine(strategy *models.Strategy) *QuantitativeEngine {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+
+	engine, exists := se.engines[strategy.ID]
+	if !exists {
+		engine = newQuantitativeEngine(strategy)
+		se.engines[strategy.ID] = engine
+		return engine
+	}
+
+	engine.mu.Lock()
+	engine.config = parseQuantitativeConfig(strategy.Config)
+	engine.mu.Unlock()
+	return engine
+}
+
+func newQuantitativeEngine(strategy *models.Strategy) *QuantitativeEngine {
+	engine := &QuantitativeEngine{
+		strategyID: strategy.ID,
+		userID:     strategy.UserID,
+		config:     parseQuantitativeConfig(strategy.Config),
+		scores:     make(map[string]*PairScore),
+		history:    make(map[string][]ScoreSnapshot),
+		positions:  make(map[string]*QuantPosition),
+	}
+	engine.restoreState(strategy.State)
+	return engine
+}
+
+// parseQuantitativeConfig 解析量化策略配置
+func parseQuantitativeConfig(cfg models.StrategyConfig) QuantitativeConfig {
+	qc := QuantitativeConfig{
+		MaxPositions:      3,
+		RiskPreference:    "moderate",
+		UpdateInterval:    60,
+		MaxDrawdown:       15,
+		StopLossPercent:   8,
+		TakeProfitPercent: 20,
+		MaxPositionSize:   0.35,
+		Weights:           ScoreWeights{Technical: 0.4, Fundamental: 0.25, Sentiment: 0.2, Structure: 0.15},
+	}
+	if cfg == nil {
+		qc.EntryScore, qc.ExitScore = defaultScoreThresholds(qc.RiskPreference)
+		return qc
+	}
+
+	switch symbols := cfg["symbols"].(type) {
+	case []interface{}:
+		for _, s := range symbols {
+			if symbol, ok := s.(string); ok && symbol != "" {
+				qc.Symbols = append(qc.Symbols, utils.ToUpper(symbol))
+			}
+		}
+	case []string:
+		for _, symbol := range symbols {
+			if symbol != "" {
+				qc.Symbols = append(qc.Symbols, utils.ToUpper(symbol))
+			}
+		}
+	}
+
+	if v, ok := cfg["max_positions"].(float64); ok && v >= 1 {
+		qc.MaxPositions = int(v)
+	}
+	if v, ok := cfg["total_capital_usdt"].(float64); ok {
+		qc.TotalCapital = v
+	}
+	if v, ok := cfg["risk_preference"].(string); ok && v != "" {
+		qc.RiskPreference = v
+	}
+	if v, ok := cfg["update_interval"].(float64); ok && v > 0 {
+		qc.UpdateInterval = int(v)
+	}
+	if v, ok := cfg["max_drawdown"].(float64); ok && v > 0 {
+		qc.MaxDrawdown = v
+	}
+	if v, ok := cfg["stop_loss_percent"].(float64); ok && v > 0 {
+		qc.StopLossPercent = v
+	}
+	if v, ok := cfg["take_profit_percent"].(float64); ok && v > 0 {
+		qc.TakeProfitPercent = v
+	}
+	if v, ok := cfg["max_position_size"].(float64); ok && v > 0 {
+		qc.MaxPositionSize = v
+	}
+
+	qc.Weights = scoreWeightsFromConfig(cfg)
+
+	qc.EntryScore, qc.ExitScore = defaultScoreThresholds(qc.RiskPreference)
+	if v, ok := cfg["entry_score"].(float64); ok && v > 0 {
+		qc.EntryScore = v
+	}
+	if v, ok := cfg["exit_score"].(float64); ok && v > 0 {
+		qc.ExitScore = v
+	}
+
+	return qc
+}
+
+// defaultScoreThresholds 根据风险偏好返回开仓和平仓评分阈值
+func defaultScoreThresholds(riskPreference string) (float64, float64) {
+	switch riskPreference {
+	case "conservative":
+		return 72, 50
+	case "aggressive":
+		return 60, 40
+	default:
+		return 65, 45
+	}
+}
+
+// restoreState 从Strategy.State恢复持仓和资金状态
+func (qe *QuantitativeEngine) restoreState(state models.StrategyState) {
+	qe.cash = qe.config.TotalCapital
+	qe.peakEquity = qe.config.TotalCapital
+	if state == nil {
+		return
+	}
+
+	if v, ok := state["cash"].(float64); ok {
+		qe.cash = v
+	}
+	if v, ok := state["peak_equity"].(float64); ok && v > 0 {
+		qe.peakEquity = v
+	}
+	qe.halted, _ = state["halted"].(bool)
+	qe.haltReason, _ = state["halt_reason"].(string)
+	if v, ok := state["last_run"].(string); ok {
+		qe.lastRun, _ = time.Parse(time.RFC3339, v)
+	}
+
+	var positions []*QuantPosition
+	if err := decodeStateValue(state["positions"], &positions); err == nil {
+		for _, p := range positions {
+			qe.positions[p.Symbol] = p
+		}
+	}
+	_ = decodeStateValue(state["closed_trades"], &qe.closedTrades)
+	_ = decodeStateValue(state["daily_stats"], &qe.dailyStats)
+}
+
+// snapshotState 生成需要持久化到Strategy.State的状态
+func (qe *QuantitativeEngine) snapshotState(state models.StrategyState) models.StrategyState {
+	if state == nil {
+		state = make(models.StrategyState)
+	}
+
+	positions := make([]*QuantPosition, 0, len(qe.positions))
+	for _, p := range qe.positions {
+		positions = append(positions, p)
+	}
+
+	state["cash"] = qe.cash
+	state["peak_equity"] = qe.peakEquity
+	state["halted"] = qe.halted
+	state["halt_reason"] = qe.haltReason
+	state["last_run"] = qe.lastRun.Format(time.RFC3339)
+	state["positions"] = encodeStateValue(positions)
+	state["closed_trades"] = encodeStateValue(qe.closedTrades)
+	state["daily_stats"] = encodeStateValue(qe.dailyStats)
+	return state
+}
+
+// saveEngineState 持久化策略引擎状态
+func (se *StrategyExecutor) saveEngineState(engine *QuantitativeEngine) {
+	var strategy models.Strategy
+	if err := se.db.First(&strategy, engine.strategyID).Error; err != nil {
+		log.Printf("加载量化策略%d失败: %v", engine.strategyID, err)
+		return
+	}
+
+	engine.mu.RLock()
+	state := engine.snapshotState(strategy.State)
+	engine.mu.RUnlock()
+
+	if err := se.db.Model(&strategy).Update("state", state).Error; err != nil {
+		log.Printf("保存量化策略%d状态失败: %v", engine.strategyID, err)
+	}
+}
+
+// resume 回撤熔断会把策略转为暂停，策略重新启动后回到活跃状态时清除熔断标记，
+// 并以当前权益作为新的回撤基准，避免恢复后立即再次熔断。返回是否清除了熔断
+func (qe *QuantitativeEngine) resume() bool {
+	qe.mu.Lock()
+	defer qe.mu.Unlock()
+	if !qe.halted {
+		return false
+	}
+	qe.halted = false
+	qe.haltReason = ""
+	qe.peakEquity = qe.equity()
+	return true
+}
+
+// due 策略未被风控暂停且已到更新间隔
+func (qe *QuantitativeEngine) due() bool {
+	qe.mu.RLock()
+	defer qe.mu.RUnlock()
+	return !qe.halted && time.Since(qe.lastRun) >= time.Duration(qe.config.UpdateInterval)*time.Second
+}
+
+// quantExit 待执行的平仓
+type quantExit struct {
+	Symbol string
+	Reason string
+}
+
+// runEngine 执行一次完整的评分、风控和调仓流程。
+// 调仓决策在锁内基于当前状态生成，下单在锁外进行，成交结果再加锁写回，避免交易所请求阻塞查询接口
+func (se *StrategyExecutor) runEngine(engine *QuantitativeEngine, strategy *models.Strategy) error {
+	engine.runMu.Lock()
+	defer engine.runMu.Unlock()
+
+	defer func() {
+		engine.mu.Lock()
+		engine.lastRun = time.Now()
+		engine.mu.Unlock()
+		se.saveEngineState(engine)
+	}()
+
+	exchange, err := se.userService.GetTradingExchange(strategy.UserID, strategy.PaperTrading)
+	if err != nil {
+		engine.setError(err)
+		return err
+	}
+
+	symbols, err := engine.universe(exchange)
+	if err != nil {
+		engine.setError(err)
+		return err
+	}
+
+	// 评分阶段不持有锁，避免阻塞查询接口
+	engine.mu.RLock()
+	weights := engine.config.Weights
+	engine.mu.RUnlock()
+
+	scores := make(map[string]*PairScore, len(symbols))
+	for _, symbol := range symbols {
+		score, err := scoreSymbol(exchange, symbol, "1h", 100, weights)
+		if err != nil {
+			log.Printf("量化策略%d评分%s失败: %v", strategy.ID, symbol, err)
+			continue
+		}
+		scores[symbol] = score
+	}
+
+	engine.mu.Lock()
+	engine.applyScores(scores)
+	engine.rollDailyStats()
+	engine.markToMarket()
+
+	// 最大回撤风控：清仓并暂停策略
+	drawdown, maxDrawdown := engine.drawdownPercent(), engine.config.MaxDrawdown
+	halt := drawdown >= maxDrawdown
+	exits := make([]quantExit, 0)
+	for symbol, position := range engine.positions {
+		reason := engine.exitReason(position)
+		if halt {
+			reason = "max_drawdown"
+		}
+		if reason != "" {
+			exits = append(exits, quantExit{Symbol: symbol, Reason: reason})
+		}
+	}
+	engine.mu.Unlock()
+
+	if halt {
+		log.Printf("量化策略%d回撤%.2f%%超过上限%.2f%%，清仓并暂停", strategy.ID, drawdown, maxDrawdown)
+	}
+
+	// 止损、止盈、评分衰减平仓
+	for _, exit := range exits {
+		if err := se.closePosition(engine, strategy, exchange, exit.Symbol, exit.Reason); err != nil {
+			log.Printf("量化策略%d平仓%s失败: %v", strategy.ID, exit.Symbol, err)
+		}
+	}
+
+	if halt {
+		haltReason := fmt.Sprintf("回撤%.2f%%超过上限%.2f%%", drawdown, maxDrawdown)
+		engine.mu.Lock()
+		engine.halted = true
+		engine.haltReason = haltReason
+		engine.mu.Unlock()
+		if err := transitionSpotStrategy(se.db, strategy, models.StrategyStatusPaused, models.StrategyActorRisk, haltReason); err != nil {
+			log.Printf("暂停量化策略%d失败: %v", strategy.ID, err)
+		}
+		return nil
+	}
+
+	// 按评分从高到低开仓，每笔开仓前按最新资金重新计算仓位
+	engine.mu.RLock()
+	candidates := engine.entryCandidates()
+	engine.mu.RUnlock()
+
+	for _, score := range candidates {
+		engine.mu.RLock()
+		full := len(engine.positions) >= engine.config.MaxPositions
+		allocation := utils.MinFloat64(engine.config.TotalCapital*engine.config.MaxPositionSize, engine.cash)
+		engine.mu.RUnlock()
+		if full || allocation < minQuantOrderUSDT {
+			break
+		}
+		if err := se.openPosition(engine, strategy, exchange, score, allocation); err != nil {
+			log.Printf("量化策略%d开仓%s失败: %v", strategy.ID, score.Symbol, err)
+		}
+	}
+
+	engine.mu.Lock()
+	engine.lastError = ""
+	engine.mu.Unlock()
+	return nil
+}
+
+// universe 返回策略需要评分的交易对，未配置时使用成交额前10的USDT交易对
+func (qe *QuantitativeEngine) universe(exchange Exchange) ([]string, error) {
+	qe.mu.RLock()
+	symbols := append([]string{}, qe.config.Symbols...)
+	top := append([]string{}, qe.topSymbols...)
+	stale := time.Since(qe.topSymbolsUpdatedAt) > topSymbolsTTL || len(top) == 0
+	qe.mu.RUnlock()
+
+	if len(symbols) == 0 {
+		if stale {
+			latest, err := exchange.GetTopSymbols(10)
+			if err != nil {
+				return nil, err
+			}
+			top = latest
+			qe.mu.Lock()
+			qe.topSymbols = latest
+			qe.topSymbolsUpdatedAt = time.Now()
+			qe.mu.Unlock()
+		}
+		symbols = append(symbols, top...)
+	}
+
+	// 已持仓的交易对必须继续评分，保证平仓判断有最新数据
+	qe.mu.RLock()
+	defer qe.mu.RUnlock()
+	for symbol := range qe.positions {
+		if !utils.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols, nil
+}
+
+func (qe *QuantitativeEngine) setError(err error) {
+	qe.mu.Lock()
+	qe.lastError = err.Error()
+	qe.mu.Unlock()
+}
+
+// applyScores 更新最新评分和评分历史
+func (qe *QuantitativeEngine) applyScores(scores map[string]*PairScore) {
+	for symbol, score := range scores {
+		qe.scores[symbol] = score
+		history := append(qe.history[symbol], score.Snapshot())
+		if len(history) > maxScoreHistory {
+			history = history[len(history)-maxScoreHistory:]
+		}
+		qe.history[symbol] = history
+	}
+}
+
+// rollDailyStats 跨日时重置当日统计
+func (qe *QuantitativeEngine) rollDailyStats() {
+	today := time.Now().Format("2006-01-02")
+	if qe.dailyStats.Date != today {
+		qe.dailyStats = QuantDailyStats{Date: today, StartEquity: qe.equity()}
+	}
+}
+
+// markToMarket 用最新评分价格更新持仓盈亏和权益峰值
+func (qe *QuantitativeEngine) markToMarket() {
+	for symbol, position := range qe.positions {
+		if score, ok := qe.scores[symbol]; ok && score.Price > 0 {
+			position.CurrentPrice = score.Price
+		}
+		if position.CurrentPrice <= 0 {
+			position.CurrentPrice = position.EntryPrice
+		}
+		position.UnrealizedPnL = (position.CurrentPrice - position.EntryPrice) * position.Quantity
+		if position.EntryPrice > 0 {
+			position.PnLPercent = (position.CurrentPrice - position.EntryPrice) / position.EntryPrice * 100
+		}
+	}
+
+	if equity := qe.equity(); equity > qe.peakEquity {
+		qe.peakEquity = equity
+	}
+}
+
+// equity 当前权益 = 可用资金 + 持仓市值
+func (qe *QuantitativeEngine) equity() float64 {
+	equity := qe.cash
+	for _, position := range qe.positions {
+		price := position.CurrentPrice
+		if price <= 0 {
+			price = position.EntryPrice
+		}
+		equity += position.Quantity * price
+	}
+	return equity
+}
+
+// exposure 当前持仓市值
+func (qe *QuantitativeEngine) exposure() float64 {
+	return qe.equity() - qe.cash
+}
+
+// drawdownPercent 当前权益相对峰值的回撤百分比
+func (qe *QuantitativeEngine) drawdownPercent() float64 {
+	if qe.peakEquity <= 0 {
+		return 0
+	}
+	drawdown := (qe.peakEquity - qe.equity()) / qe.peakEquity * 100
+	if drawdown < 0 {
+		return 0
+	}
+	return drawdown
+}
+
+// exitReason 判断持仓是否需要平仓
+func (qe *QuantitativeEngine) exitReason(position *QuantPosition) string {
+	switch {
+	case position.PnLPercent <= -qe.config.StopLossPercent:
+		return "stop_loss"
+	case position.PnLPercent >= qe.config.TakeProfitPercent:
+		return "take_profit"
+	}
+	if score, ok := qe.scores[position.Symbol]; ok && score.TotalScore < qe.config.ExitScore {
+		return "score_decay"
+	}
+	return ""
+}
+
+// entryCandidates 返回达到开仓阈值且未持仓的交易对，按评分降序
+func (qe *QuantitativeEngine) entryCandidates() []*PairScore {
+	candidates := make([]*PairScore, 0)
+	for symbol, score := range qe.scores {
+		if _, held := qe.positions[symbol]; held {
+			continue
+		}
+		if score.TotalScore >= qe.config.EntryScore {
+			candidates = append(candidates, score)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].TotalScore > candidates[j].TotalScore
+	})
+	return candidates
+}
+
+// openPosition 市价买入开仓，下单时不持有引擎锁。以基础资产支付的手续费从持仓数量中扣除，保证平仓时余额足够
+func (se *StrategyExecutor) openPosition(engine *QuantitativeEngine, strategy *models.Strategy, exchange Exchange, score *PairScore, allocation float64) error {
+	order := &models.Order{
+		UserID:        strategy.UserID,
+		StrategyID:    &strategy.ID,
+		Symbol:        score.Symbol,
+		Side:          models.OrderSideBuy,
+		Type:          models.OrderTypeMarket,
+		Quantity:      allocation / score.Price,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+
+	executedQty, quoteQty, err := submitMarketOrder(se.db, exchange, order)
+	if err != nil {
+		return err
+	}
+	if executedQty <= 0 {
+		return fmt.Errorf("订单%s未成交", order.OrderID)
+	}
+
+	quantity := executedQty - se.baseFee(exchange, score.Symbol, order.OrderID)
+	if quantity <= 0 {
+		return fmt.Errorf("订单%s扣除手续费后数量为0", order.OrderID)
+	}
+
+	engine.mu.Lock()
+	engine.cash -= quoteQty
+	engine.positions[score.Symbol] = &QuantPosition{
+		Symbol:       score.Symbol,
+		Quantity:     quantity,
+		EntryPrice:   quoteQty / quantity,
+		CostUSDT:     quoteQty,
+		CurrentPrice: quoteQty / executedQty,
+		EntryScore:   score.TotalScore,
+		OrderID:      order.OrderID,
+		OpenedAt:     time.Now(),
+	}
+	engine.mu.Unlock()
+
+	log.Printf("量化策略%d开仓: %s 数量%.8f 均价%.8f 评分%.2f",
+		strategy.ID, score.Symbol, quantity, quoteQty/quantity, score.TotalScore)
+	return nil
+}
+
+// baseFee 订单以基础资产支付的手续费，成交记录尚未同步时向交易所查询
+func (se *StrategyExecutor) baseFee(exchange Exchange, symbol, orderID string) float64 {
+	baseFee, _ := (&StrategyService{db: se.db}).spotOrderFees(exchange, symbol, orderID)
+	return baseFee
+}
+
+// closePosition 市价卖出平仓并记录交易，下单时不持有引擎锁
+func (se *StrategyExecutor) closePosition(engine *QuantitativeEngine, strategy *models.Strategy, exchange Exchange, symbol, reason string) error {
+	engine.mu.RLock()
+	position, ok := engine.positions[symbol]
+	var quantity float64
+	if ok {
+		quantity = position.Quantity
+	}
+	engine.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	order := &models.Order{
+		UserID:        strategy.UserID,
+		StrategyID:    &strategy.ID,
+		Symbol:        symbol,
+		Side:          models.OrderSideSell,
+		Type:          models.OrderTypeMarket,
+		Quantity:      quantity,
+		ClientOrderID: utils.GenerateUUID(),
+	}
+
+	executedQty, quoteQty, err := submitMarketOrder(se.db, exchange, order)
+	if err != nil {
+		return err
+	}
+	if executedQty <= 0 {
+		return fmt.Errorf("订单%s未成交", order.OrderID)
+	}
+
+	engine.mu.Lock()
+	defer engine.mu.Unlock()
+
+	exitPrice := quoteQty / executedQty
+	pnl := (exitPrice - position.EntryPrice) * executedQty
+	engine.cash += quoteQty
+
+	trade := QuantTrade{
+		Symbol:     symbol,
+		Quantity:   executedQty,
+		EntryPrice: position.EntryPrice,
+		ExitPrice:  exitPrice,
+		PnL:        pnl,
+		PnLPercent: (exitPrice - position.EntryPrice) / position.EntryPrice * 100,
+		Reason:     reason,
+		OpenedAt:   position.OpenedAt,
+		ClosedAt:   time.Now(),
+	}
+	engine.closedTrades = append(engine.closedTrades, trade)
+	if len(engine.closedTrades) > maxClosedTrades {
+		engine.closedTrades = engine.closedTrades[len(engine.closedTrades)-maxClosedTrades:]
+	}
+
+	engine.dailyStats.Trades++
+	engine.dailyStats.RealizedPnL += pnl
+	if pnl >= 0 {
+		engine.dailyStats.Wins++
+	} else {
+		engine.dailyStats.Losses++
+	}
+
+	// 部分成交时保留剩余持仓
+	if remaining := position.Quantity - executedQty; remaining > position.Quantity*0.001 {
+		position.Quantity = remaining
+		position.CostUSDT = remaining * position.EntryPrice
+	} else {
+		delete(engine.positions, symbol)
+	}
+
+	log.Printf("量化策略%d平仓: %s 原因%s 盈亏%.4f USDT", strategy.ID, symbol, reason, pnl)
+	return nil
+}
+
+// getEngine 获取用户的量化策略引擎；strategyID为0时使用用户最近创建的量化策略
+func (se *StrategyExecutor) getEngine(userID, strategyID uint) (*QuantitativeEngine, *models.Strategy, error) {
+	if se.db == nil {
+		return nil, nil, errors.New("数据库未连接")
+	}
+
+	var strategy models.Strategy
+	query := se.db.Where("user_id = ? AND type = ?", userID, models.StrategyQuantitative)
+	if strategyID > 0 {
+		query = query.Where("id = ?", strategyID)
+	}
+	if err := query.Order("created_at desc").First(&strategy).Error; err != nil {
+		return nil, nil, errors.New("量化策略不存在")
+	}
+
+	se.mu.RLock()
+	engine, exists := se.engines[strategy.ID]
+	se.mu.RUnlock()
+	if exists {
+		return engine, &strategy, nil
+	}
+
+	// 未运行的策略只读取持久化状态
+	return newQuantitativeEngine(&strategy), &strategy, nil
+}
+
+// GetScores 获取策略最新评分，按总分降序
+func (se *StrategyExecutor) GetScores(userID, strategyID uint) ([]*PairScore, error) {
+	engine, _, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	scores := make([]*PairScore, 0, len(engine.scores))
+	for _, score := range engine.scores {
+		scores = append(scores, score)
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		return scores[i].TotalScore > scores[j].TotalScore
+	})
+	return scores, nil
+}
+
+// GetScoreDetails 获取单个交易对的评分详情；未缓存时实时计算
+func (se *StrategyExecutor) GetScoreDetails(userID, strategyID uint, symbol string) (*PairScore, error) {
+	engine, strategy, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	score, ok := engine.scores[symbol]
+	weights := engine.config.Weights
+	engine.mu.RUnlock()
+	if ok {
+		return score, nil
+	}
+
+	exchange, err := se.userService.GetTradingExchange(userID, strategy.PaperTrading)
+	if err != nil {
+		return nil, err
+	}
+	return scoreSymbol(exchange, symbol, "1h", 100, weights)
+}
+
+// GetTopPairs 获取评分最高的交易对
+func (se *StrategyExecutor) GetTopPairs(userID, strategyID uint, limit int) ([]*PairScore, error) {
+	scores, err := se.GetScores(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
+
+// GetHistoricalScores 获取评分历史；symbol为空时返回全部交易对
+func (se *StrategyExecutor) GetHistoricalScores(userID, strategyID uint, symbol string, limit int) (map[string][]ScoreSnapshot, error) {
+	engine, _, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	result := make(map[string][]ScoreSnapshot)
+	for s, history := range engine.history {
+		if symbol != "" && s != symbol {
+			continue
+		}
+		if limit > 0 && len(history) > limit {
+			history = history[len(history)-limit:]
+		}
+		result[s] = append([]ScoreSnapshot{}, history...)
+	}
+	return result, nil
+}
+
+// GetPositions 获取当前持仓
+func (se *StrategyExecutor) GetPositions(userID, strategyID uint) ([]QuantPosition, error) {
+	engine, _, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	positions := make([]QuantPosition, 0, len(engine.positions))
+	for _, position := range engine.positions {
+		positions = append(positions, *position)
+	}
+	sort.Slice(positions, func(i, j int) bool {
+		return positions[i].OpenedAt.Before(positions[j].OpenedAt)
+	})
+	return positions, nil
+}
+
+// GetRiskMetrics 获取风险指标
+func (se *StrategyExecutor) GetRiskMetrics(userID, strategyID uint) (map[string]interface{}, error) {
+	engine, strategy, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	equity := engine.equity()
+	exposure := engine.exposure()
+
+	var riskToStop float64
+	for _, position := range engine.positions {
+		riskToStop += position.Quantity * position.EntryPrice * engine.config.StopLossPercent / 100
+	}
+
+	return map[string]interface{}{
+		"strategy_id":         strategy.ID,
+		"status":              strategy.Status,
+		"is_active":           strategy.IsActive,
+		"halted":              engine.halted,
+		"halt_reason":         engine.haltReason,
+		"total_capital":       engine.config.TotalCapital,
+		"equity":              equity,
+		"cash":                engine.cash,
+		"exposure":            exposure,
+		"exposure_percent":    utils.CalculatePercent(exposure, equity),
+		"peak_equity":         engine.peakEquity,
+		"current_drawdown":    engine.drawdownPercent(),
+		"max_drawdown_limit":  engine.config.MaxDrawdown,
+		"open_positions":      len(engine.positions),
+		"max_positions":       engine.config.MaxPositions,
+		"stop_loss_percent":   engine.config.StopLossPercent,
+		"take_profit_percent": engine.config.TakeProfitPercent,
+		"risk_to_stop":        riskToStop,
+		"daily_stats":         engine.dailyStats,
+		"last_run":            engine.lastRun,
+		"last_error":          engine.lastError,
+	}, nil
+}
+
+// GetStrategyConfig 获取策略配置
+func (se *StrategyExecutor) GetStrategyConfig(userID, strategyID uint) (map[string]interface{}, error) {
+	engine, strategy, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	return map[string]interface{}{
+		"strategy_id": strategy.ID,
+		"name":        strategy.Name,
+		"config":      strategy.Config,
+		"effective":   engine.config,
+	}, nil
+}
+
+// UpdateStrategyConfig 更新策略配置，沿用创建策略时的校验和默认值
+func (se *StrategyExecutor) UpdateStrategyConfig(userID, strategyID uint, updates map[string]interface{}) (models.StrategyConfig, error) {
+	engine, strategy, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := make(models.StrategyConfig)
+	for k, v := range strategy.Config {
+		cfg[k] = v
+	}
+	for k, v := range updates {
+		cfg[k] = v
+	}
+	strategy.Config = cfg
+
+	if err := se.strategyService.validateQuantitativeStrategy(strategy); err != nil {
+		return nil, err
+	}
+
+	if err := se.db.Model(strategy).Update("config", strategy.Config).Error; err != nil {
+		return nil, err
+	}
+
+	engine.mu.Lock()
+	engine.config = parseQuantitativeConfig(strategy.Config)
+	engine.mu.Unlock()
+
+	return strategy.Config, nil
+}
+
+// UpdateCapital 调整策略总资金，差额计入可用资金
+func (se *StrategyExecutor) UpdateCapital(userID, strategyID uint, capital float64) error {
+	if capital < 100 {
+		return errors.New("总资金最少100 USDT")
+	}
+
+	engine, strategy, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return err
+	}
+
+	engine.mu.Lock()
+	delta := capital - engine.config.TotalCapital
+	if engine.cash+delta < 0 {
+		engine.mu.Unlock()
+		return errors.New("可用资金不足，无法减少到该金额")
+	}
+	engine.cash += delta
+	engine.peakEquity += delta
+	engine.config.TotalCapital = capital
+	engine.mu.Unlock()
+
+	cfg := strategy.Config
+	if cfg == nil {
+		cfg = make(models.StrategyConfig)
+	}
+	cfg["total_capital_usdt"] = capital
+	if err := se.db.Model(strategy).Update("config", cfg).Error; err != nil {
+		return err
+	}
+
+	se.saveEngineState(engine)
+	return nil
+}
+
+// ResetDailyStats 重置当日统计，并以当前权益作为新的回撤基准
+func (se *StrategyExecutor) ResetDailyStats(userID, strategyID uint) error {
+	engine, _, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return err
+	}
+
+	engine.mu.Lock()
+	engine.dailyStats = QuantDailyStats{
+		Date:        time.Now().Format("2006-01-02"),
+		StartEquity: engine.equity(),
+	}
+	engine.peakEquity = engine.equity()
+	engine.halted = false
+	engine.haltReason = ""
+	engine.mu.Unlock()
+
+	se.saveEngineState(engine)
+	return nil
+}
+
+// GetPerformanceStats 获取策略绩效统计
+func (se *StrategyExecutor) GetPerformanceStats(userID, strategyID uint) (map[string]interface{}, error) {
+	engine, strategy, err := se.getEngine(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	engine.mu.RLock()
+	defer engine.mu.RUnlock()
+
+	var totalPnL, grossProfit, grossLoss float64
+	var wins, losses int
+	for _, trade := range engine.closedTrades {
+		totalPnL += trade.PnL
+		if trade.PnL >= 0 {
+			wins++
+			grossProfit += trade.PnL
+		} else {
+			losses++
+			grossLoss -= trade.PnL
+		}
+	}
+
+	var unrealizedPnL float64
+	for _, position := range engine.positions {
+		unrealizedPnL += position.UnrealizedPnL
+	}
+
+	profitFactor := 0.0
+	if grossLoss > 0 {
+		profitFactor = grossProfit / grossLoss
+	}
+
+	equity := engine.equity()
+	return map[string]interface{}{
+		"strategy_id":      strategy.ID,
+		"total_capital":    engine.config.TotalCapital,
+		"equity":           equity,
+		"total_return":     utils.CalculatePercentChange(engine.config.TotalCapital, equity),
+		"realized_pnl":     totalPnL,
+		"unrealized_pnl":   unrealizedPnL,
+		"total_trades":     len(engine.closedTrades),
+		"winning_trades":   wins,
+		"losing_trades":    losses,
+		"win_rate":         utils.CalculatePercent(float64(wins), float64(len(engine.closedTrades))),
+		"profit_factor":    profitFactor,
+		"current_drawdown": engine.drawdownPercent(),
+		"recent_trades":    engine.closedTrades,
+	}, nil
+}
+
+// encodeStateValue 将结构体转换为可存入JSON State的通用结构
+func encodeStateValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// decodeStateValue 将State中的通用结构还原为结构体
+func decodeStateValue(raw interface{}, out interface{}) error {
+	if raw == nil {
+		return errors.New("state value is empty")
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
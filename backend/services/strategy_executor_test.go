@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuantitativeEngineResume(t *testing.T) {
+	engine := &QuantitativeEngine{
+		config:     QuantitativeConfig{UpdateInterval: 60},
+		positions:  map[string]*QuantPosition{"ETHUSDT": {Symbol: "ETHUSDT", Quantity: 2, EntryPrice: 100, CurrentPrice: 90}},
+		cash:       500,
+		peakEquity: 1000,
+		halted:     true,
+		haltReason: "回撤30.00%超过上限20.00%",
+		lastRun:    time.Now().Add(-time.Hour),
+	}
+
+	if engine.due() {
+		t.Fatal("halted engine reported due")
+	}
+	if !engine.resume() {
+		t.Fatal("resume() = false for a halted engine")
+	}
+	if engine.halted || engine.haltReason != "" {
+		t.Errorf("halt not cleared: halted=%v reason=%q", engine.halted, engine.haltReason)
+	}
+	if engine.peakEquity != 680 {
+		t.Errorf("peak equity = %v, want current equity 680", engine.peakEquity)
+	}
+	if !engine.due() {
+		t.Error("resumed engine not due after its update interval")
+	}
+	if engine.resume() {
+		t.Error("resume() = true for an engine that is not halted")
+	}
+}
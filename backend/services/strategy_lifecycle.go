@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// 策略持续执行失败达到次数且持续时间超过窗口后标记为失败，避免网络抖动误判
+const (
+	strategyFailureThreshold = 5
+	strategyFailureWindow    = 10 * time.Minute
+)
+
+// userStrategyStatuses 用户可以主动变更到的状态，其余状态由调度器和风控变更
+var userStrategyStatuses = []models.StrategyStatus{models.StrategyStatusArmed, models.StrategyStatusPaused, models.StrategyStatusStopping}
+
+// transitionStrategyStatus 校验并变更策略状态，同步is_active和is_completed并记录历史。
+// 更新以当前状态为条件，状态已被其他操作修改时返回错误
+func transitionStrategyStatus(db *gorm.DB, model interface{}, market MarketType, strategyID, userID uint, from, to models.StrategyStatus, actor, reason string) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("策略状态不能从%s变更为%s", from, to)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(model).Where("id = ? AND status = ?", strategyID, from).Updates(map[string]interface{}{
+			"status":       to,
+			"is_active":    to.IsActive(),
+			"is_completed": to == models.StrategyStatusCompleted,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("策略%d状态已变更，请刷新后重试", strategyID)
+		}
+
+		return tx.Create(&models.StrategyStatusHistory{
+			UserID:     userID,
+			Market:     string(market),
+			StrategyID: strategyID,
+			FromStatus: from,
+			ToStatus:   to,
+			Actor:      actor,
+			Reason:     reason,
+		}).Error
+	})
+}
+
+// transitionSpotStrategy 变更现货策略状态并同步内存中的策略
+func transitionSpotStrategy(db *gorm.DB, strategy *models.Strategy, to models.StrategyStatus, actor, reason string) error {
+	if err := transitionStrategyStatus(db, &models.Strategy{}, MarketSpot, strategy.ID, strategy.UserID, strategy.Status, to, actor, reason); err != nil {
+		return err
+	}
+	strategy.Status, strategy.IsActive, strategy.IsCompleted = to, to.IsActive(), to == models.StrategyStatusCompleted
+	return nil
+}
+
+// transitionFuturesStrategy 变更期货策略状态并同步内存中的策略
+func transitionFuturesStrategy(db *gorm.DB, strategy *models.FuturesStrategy, to models.StrategyStatus, actor, reason string) error {
+	if err := transitionStrategyStatus(db, &models.FuturesStrategy{}, MarketFutures, strategy.ID, strategy.UserID, strategy.Status, to, actor, reason); err != nil {
+		return err
+	}
+	strategy.Status, strategy.IsActive, strategy.IsCompleted = to, to.IsActive(), to == models.StrategyStatusCompleted
+	return nil
+}
+
+// validateUserStrategyStatus 用户只能启动、暂停或停止策略
+func validateUserStrategyStatus(to models.StrategyStatus) error {
+	for _, status := range userStrategyStatuses {
+		if status == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持手动变更为%s状态，只能启动(armed)、暂停(paused)或停止(stopping)", to)
+}
+
+// toggleTarget 启停切换的目标状态：执行中的策略暂停，其余启动
+func toggleTarget(status models.StrategyStatus) models.StrategyStatus {
+	if status.IsActive() {
+		return models.StrategyStatusPaused
+	}
+	return models.StrategyStatusArmed
+}
+
+// getStrategyStatusHistory 分页查询策略状态变更历史，按时间倒序
+func getStrategyStatusHistory(db *gorm.DB, market MarketType, strategyID uint, page, limit int) ([]models.StrategyStatusHistory, int64, error) {
+	var histories []models.StrategyStatusHistory
+	var total int64
+
+	query := db.Model(&models.StrategyStatusHistory{}).Where("market = ? AND strategy_id = ?", market, strategyID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&histories).Error; err != nil {
+		return nil, 0, err
+	}
+	return histories, total, nil
+}
+
+// strategyFailure 策略连续执行失败的记录，同一策略可能被轮询和行情事件并发执行
+type strategyFailure struct {
+	mu      sync.Mutex
+	count   int
+	since   time.Time
+	tripped bool // 已判定为失败，避免并发执行重复标记
+}
+
+// strategyFailures 按市场和策略ID记录连续执行失败，成功执行一次即清除
+var strategyFailures sync.Map
+
+// recordStrategyExecution 记录一次执行结果，连续失败达到阈值时返回true
+func recordStrategyExecution(market MarketType, strategyID uint, err error) bool {
+	key := fmt.Sprintf("%s:%d", market, strategyID)
+	if err == nil {
+		strategyFailures.Delete(key)
+		return false
+	}
+
+	now := time.Now()
+	value, _ := strategyFailures.LoadOrStore(key, &strategyFailure{since: now})
+	failure := value.(*strategyFailure)
+
+	failure.mu.Lock()
+	defer failure.mu.Unlock()
+	if failure.tripped {
+		return false
+	}
+	failure.count++
+	if failure.count >= strategyFailureThreshold && now.Sub(failure.since) >= strategyFailureWindow {
+		failure.tripped = true
+		strategyFailures.CompareAndDelete(key, failure)
+		return true
+	}
+	return false
+}
+
+// afterStrategyExecution 执行成功且已有订单时由等待触发转为运行中，持续失败时标记为失败
+func (ss *StrategyService) afterStrategyExecution(strategy *models.Strategy, execErr error) {
+	if recordStrategyExecution(MarketSpot, strategy.ID, execErr) {
+		if err := transitionSpotStrategy(ss.db, strategy, models.StrategyStatusFailed, models.StrategyActorScheduler, execErr.Error()); err != nil {
+			log.Printf("标记策略%d失败状态失败: %v", strategy.ID, err)
+		} else {
+			log.Printf("策略%d持续执行失败，已停止: %v", strategy.ID, execErr)
+		}
+		return
+	}
+	if execErr != nil || strategy.Status != models.StrategyStatusArmed {
+		return
+	}
+
+	var orders int64
+	ss.db.Model(&models.Order{}).Where("strategy_id = ?", strategy.ID).Count(&orders)
+	if orders > 0 {
+		// 执行过程中策略可能已完成或被暂停，条件更新失败时忽略
+		transitionSpotStrategy(ss.db, strategy, models.StrategyStatusRunning, models.StrategyActorScheduler, "策略已下单")
+	}
+}
+
+// afterFuturesStrategyExecution 期货策略执行后的状态变更，规则同现货策略
+func (fs *FuturesService) afterFuturesStrategyExecution(strategy *models.FuturesStrategy, execErr error) {
+	if recordStrategyExecution(MarketFutures, strategy.ID, execErr) {
+		if err := transitionFuturesStrategy(fs.db, strategy, models.StrategyStatusFailed, models.StrategyActorScheduler, execErr.Error()); err != nil {
+			log.Printf("标记期货策略%d失败状态失败: %v", strategy.ID, err)
+		} else {
+			log.Printf("期货策略%d持续执行失败，已停止: %v", strategy.ID, execErr)
+		}
+		return
+	}
+	if execErr != nil || strategy.Status != models.StrategyStatusArmed {
+		return
+	}
+
+	var orders int64
+	fs.db.Model(&models.FuturesOrder{}).Where("strategy_id = ?", strategy.ID).Count(&orders)
+	if orders > 0 {
+		transitionFuturesStrategy(fs.db, strategy, models.StrategyStatusRunning, models.StrategyActorScheduler, "策略已下单")
+	}
+}
+
+// completeStrategy 策略执行完毕，由执行器调用
+func (ss *StrategyService) completeStrategy(strategy *models.Strategy, reason string) error {
+	return transitionSpotStrategy(ss.db, strategy, models.StrategyStatusCompleted, models.StrategyActorSystem, reason)
+}
+
+// completeFuturesStrategy 期货策略执行完毕，由执行器调用
+func (fs *FuturesService) completeFuturesStrategy(strategy *models.FuturesStrategy, reason string) error {
+	return transitionFuturesStrategy(fs.db, strategy, models.StrategyStatusCompleted, models.StrategyActorSystem, reason)
+}
+
+// openOrderStatuses 停止策略时需要撤销的订单状态
+var openOrderStatuses = []string{"NEW", "PARTIALLY_FILLED"}
+
+// finishStopping 撤销停止中策略的全部挂单，撤完后标记为已完成，撤单失败时下一轮重试。持仓和已成交部分保留
+func (ss *StrategyService) finishStopping(strategy *models.Strategy, exchange Exchange) error {
+	var orders []models.Order
+	if err := ss.db.Where("strategy_id = ? AND status IN ?", strategy.ID, openOrderStatuses).Find(&orders).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, order := range orders {
+		resp, err := exchange.CancelSpotOrder(context.Background(), order.Symbol, order.OrderID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("撤销订单%s失败: %w", order.OrderID, err))
+			continue
+		}
+		ss.db.Model(&models.Order{}).Where("order_id = ?", order.OrderID).Updates(map[string]interface{}{
+			"status":               resp.Status,
+			"executed_qty":         resp.ExecutedQty,
+			"cumulative_quote_qty": resp.CumulativeQuoteQty,
+		})
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	return ss.completeStrategy(strategy, fmt.Sprintf("已停止，撤销挂单%d个", len(orders)))
+}
+
+// finishFuturesStopping 撤销停止中期货策略的全部挂单（含止盈止损单），撤完后标记为已完成，持仓保留
+func (fs *FuturesService) finishFuturesStopping(strategy *models.FuturesStrategy, exchange Exchange) error {
+	var orders []models.FuturesOrder
+	if err := fs.db.Where("strategy_id = ? AND status IN ?", strategy.ID, openOrderStatuses).Find(&orders).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, order := range orders {
+		resp, err := exchange.CancelFuturesOrder(context.Background(), order.Symbol, order.OrderID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("撤销期货订单%s失败: %w", order.OrderID, err))
+			continue
+		}
+		fs.db.Model(&models.FuturesOrder{}).Where("order_id = ?", order.OrderID).Updates(map[string]interface{}{
+			"status":               resp.Status,
+			"executed_qty":         resp.ExecutedQty,
+			"cumulative_quote_qty": resp.CumulativeQuoteQty,
+		})
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	return fs.completeFuturesStrategy(strategy, fmt.Sprintf("已停止，撤销挂单%d个", len(orders)))
+}
+
+// recordStrategyCreated 记录策略创建时的初始状态
+func recordStrategyCreated(db *gorm.DB, market MarketType, strategyID, userID uint) {
+	if err := db.Create(&models.StrategyStatusHistory{
+		UserID:     userID,
+		Market:     string(market),
+		StrategyID: strategyID,
+		ToStatus:   models.StrategyStatusDraft,
+		Actor:      models.StrategyActorUser,
+		Reason:     "创建策略",
+	}).Error; err != nil {
+		log.Printf("记录策略%d状态历史失败: %v", strategyID, err)
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecordStrategyExecution(t *testing.T) {
+	const strategyID = 900001
+	key := fmt.Sprintf("%s:%d", MarketSpot, strategyID)
+	defer strategyFailures.Delete(key)
+	execErr := errors.New("下单失败")
+
+	// 达到次数但未持续到失败窗口时不标记失败
+	for i := 0; i < strategyFailureThreshold; i++ {
+		if recordStrategyExecution(MarketSpot, strategyID, execErr) {
+			t.Fatalf("failure %d tripped before the failure window elapsed", i+1)
+		}
+	}
+
+	// 成功执行一次即清除记录
+	if recordStrategyExecution(MarketSpot, strategyID, nil) {
+		t.Fatal("successful execution reported failure")
+	}
+	if _, ok := strategyFailures.Load(key); ok {
+		t.Fatal("failure record kept after a successful execution")
+	}
+
+	strategyFailures.Store(key, &strategyFailure{since: time.Now().Add(-strategyFailureWindow)})
+	for i := 1; i < strategyFailureThreshold; i++ {
+		if recordStrategyExecution(MarketSpot, strategyID, execErr) {
+			t.Fatalf("failure %d tripped below the threshold", i)
+		}
+	}
+	if !recordStrategyExecution(MarketSpot, strategyID, execErr) {
+		t.Fatal("failure did not trip at the threshold after the window elapsed")
+	}
+	if _, ok := strategyFailures.Load(key); ok {
+		t.Fatal("failure record kept after tripping")
+	}
+}
+
+func TestRecordStrategyExecutionConcurrent(t *testing.T) {
+	const strategyID = 900002
+	key := fmt.Sprintf("%s:%d", MarketFutures, strategyID)
+	defer strategyFailures.Delete(key)
+	strategyFailures.Store(key, &strategyFailure{since: time.Now().Add(-strategyFailureWindow)})
+
+	var tripped atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < strategyFailureThreshold*4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if recordStrategyExecution(MarketFutures, strategyID, errors.New("下单失败")) {
+				tripped.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 并发执行只标记一次失败，之后的失败重新开始计时
+	if got := tripped.Load(); got != 1 {
+		t.Errorf("tripped %d times, want 1", got)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
-	"strconv"
 	"time"
 
 	"github.com/ccj241/cctrade/config"
@@ -18,6 +16,7 @@ import (
 type StrategyService struct {
 	db          *gorm.DB
 	userService *UserService
+	now         func() time.Time // 回测时替换为模拟时钟
 }
 
 func NewStrategyService() *StrategyService {
@@ -30,6 +29,7 @@ func NewStrategyService() *StrategyService {
 func (ss *StrategyService) CreateStrategy(userID uint, strategyData map[string]interface{}) (*models.Strategy, error) {
 	strategy := &models.Strategy{
 		UserID: userID,
+		Status: models.StrategyStatusDraft,
 	}
 
 	if name, ok := strategyData["name"].(string); ok {
@@ -90,6 +90,10 @@ func (ss *StrategyService) CreateStrategy(userID uint, strategyData map[string]i
 		strategy.AutoRestart = autoRestart
 	}
 
+	if paperTrading, ok := strategyData["paper_trading"].(bool); ok {
+		strategy.PaperTrading = paperTrading
+	}
+
 	if config, ok := strategyData["config"].(map[string]interface{}); ok {
 		strategy.Config = models.StrategyConfig(config)
 	}
@@ -101,6 +105,7 @@ func (ss *StrategyService) CreateStrategy(userID uint, strategyData map[string]i
 	if err := ss.db.Create(strategy).Error; err != nil {
 		return nil, err
 	}
+	recordStrategyCreated(ss.db, MarketSpot, strategy.ID, userID)
 
 	return strategy, nil
 }
@@ -113,10 +118,16 @@ func (ss *StrategyService) validateStrategyConfig(strategy *models.Strategy) err
 		return ss.validateGridStrategy(strategy)
 	case models.StrategyDCA:
 		return ss.validateDCAStrategy(strategy)
+	case models.StrategyTWAP, models.StrategyVWAP:
+		return ss.validateExecutionStrategy(strategy)
 	case models.StrategyIceberg, models.StrategySlowIceberg:
 		return ss.validateIcebergStrategy(strategy)
 	case models.StrategyQuantitative:
 		return ss.validateQuantitativeStrategy(strategy)
+	case models.StrategyWeightedScoring:
+		return ss.validateWeightedScoringStrategy(strategy)
+	case models.StrategyCashCarry:
+		return errors.New("期现套利需要同时持有永续空头，请创建期货策略")
 	default:
 		return nil
 	}
@@ -148,6 +159,11 @@ func (ss *StrategyService) validateSimpleStrategy(strategy *models.Strategy) err
 		config["timeout"] = 5.0 // 默认5分钟
 	}
 
+	// 验证跟踪止损
+	if _, err := parseTrailingConfig(strategy); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -173,12 +189,37 @@ func (ss *StrategyService) validateGridStrategy(strategy *models.Strategy) error
 		return errors.New("网格数量至少为2")
 	}
 
+	if spacing, exists := config["spacing"]; exists {
+		value, ok := spacing.(string)
+		if !ok || (GridSpacing(value) != GridSpacingArithmetic && GridSpacing(value) != GridSpacingGeometric) {
+			return errors.New("网格间隔方式只能为arithmetic或geometric")
+		}
+	}
+
+	if compound, exists := config["compound"]; exists {
+		if _, ok := compound.(bool); !ok {
+			return errors.New("compound必须为布尔值")
+		}
+	}
+
+	if strategy.Quantity <= 0 {
+		return errors.New("网格策略需要设置总数量")
+	}
+
 	return nil
 }
 
 func (ss *StrategyService) validateDCAStrategy(strategy *models.Strategy) error {
 	config := strategy.Config
 
+	if mode, _ := config["mode"].(string); mode == dcaModeBot {
+		if strategy.Quantity <= 0 {
+			return errors.New("DCA机器人需要设置基础单数量")
+		}
+		_, err := parseDCABotConfig(config)
+		return err
+	}
+
 	interval, ok := config["interval"].(float64)
 	if !ok || interval <= 0 {
 		return errors.New("DCA策略需要设置投资间隔")
@@ -192,6 +233,20 @@ func (ss *StrategyService) validateDCAStrategy(strategy *models.Strategy) error
 	return nil
 }
 
+func (ss *StrategyService) validateExecutionStrategy(strategy *models.Strategy) error {
+	if strategy.Side != models.OrderSideBuy && strategy.Side != models.OrderSideSell {
+		return errors.New("交易方向只能为buy或sell")
+	}
+	cfg, err := parseExecutionConfig(strategy.Type, strategy.Config)
+	if err != nil {
+		return err
+	}
+	if cfg.totalQuantity <= 0 && strategy.Quantity <= 0 {
+		return errors.New("算法执行策略需要设置总数量")
+	}
+	return nil
+}
+
 func (ss *StrategyService) validateIcebergStrategy(strategy *models.Strategy) error {
 	// 冰山策略必须有触发价格
 	if strategy.TriggerPrice <= 0 {
@@ -280,25 +335,7 @@ func (ss *StrategyService) validateQuantitativeStrategy(strategy *models.Strateg
 	}
 
 	// 验证策略权重（总和必须为1）
-	techWeight, _ := config["technical_weight"].(float64)
-	fundWeight, _ := config["fundamental_weight"].(float64)
-	sentWeight, _ := config["sentiment_weight"].(float64)
-	structWeight, _ := config["structure_weight"].(float64)
-
-	totalWeight := techWeight + fundWeight + sentWeight + structWeight
-	if totalWeight == 0 {
-		// 使用默认权重
-		config["technical_weight"] = 0.4
-		config["fundamental_weight"] = 0.25
-		config["sentiment_weight"] = 0.2
-		config["structure_weight"] = 0.15
-	} else if math.Abs(totalWeight-1.0) > 0.001 {
-		// 归一化权重
-		config["technical_weight"] = techWeight / totalWeight
-		config["fundamental_weight"] = fundWeight / totalWeight
-		config["sentiment_weight"] = sentWeight / totalWeight
-		config["structure_weight"] = structWeight / totalWeight
-	}
+	normalizeScoreWeights(config)
 
 	// 验证风险管理参数
 	maxDrawdown, ok := config["max_drawdown"].(float64)
@@ -354,11 +391,86 @@ func (ss *StrategyService) validateQuantitativeStrategy(strategy *models.Strateg
 	return nil
 }
 
-func (ss *StrategyService) GetUserStrategies(userID uint, page, limit int) ([]models.Strategy, int64, error) {
+func (ss *StrategyService) validateWeightedScoringStrategy(strategy *models.Strategy) error {
+	config := strategy.Config
+	if config == nil {
+		config = make(models.StrategyConfig)
+		strategy.Config = config
+	}
+
+	// 验证交易对列表，未指定时只对策略交易对评分
+	symbols, ok := config["symbols"].([]interface{})
+	if !ok || len(symbols) == 0 {
+		config["symbols"] = []string{strategy.Symbol}
+	} else {
+		for _, s := range symbols {
+			symbol, ok := s.(string)
+			if !ok {
+				return errors.New("交易对列表格式无效")
+			}
+			if err := utils.ValidateSymbol(utils.ToUpper(symbol)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 验证下单金额
+	orderAmount, ok := config["order_amount_usdt"].(float64)
+	if (!ok || orderAmount <= 0) && strategy.Quantity <= 0 {
+		return errors.New("加权评分策略需要设置每次开仓金额或数量")
+	}
+	if ok && orderAmount > 0 && orderAmount < 10 {
+		return errors.New("每次开仓金额最少10 USDT")
+	}
+
+	// 验证开仓和平仓阈值
+	entryThreshold, ok := config["entry_threshold"].(float64)
+	if !ok || entryThreshold <= 0 || entryThreshold > 100 {
+		entryThreshold = 65.0
+		config["entry_threshold"] = entryThreshold
+	}
+
+	exitThreshold, ok := config["exit_threshold"].(float64)
+	if !ok || exitThreshold <= 0 || exitThreshold > 100 {
+		exitThreshold = 45.0
+		config["exit_threshold"] = exitThreshold
+	}
+
+	if exitThreshold >= entryThreshold {
+		return errors.New("平仓阈值必须小于开仓阈值")
+	}
+
+	// 验证K线周期
+	interval, ok := config["kline_interval"].(string)
+	if !ok || !utils.Contains([]string{"5m", "15m", "30m", "1h", "4h", "1d"}, interval) {
+		config["kline_interval"] = "1h"
+	}
+
+	// 验证评分间隔
+	evaluateInterval, ok := config["evaluate_interval"].(float64)
+	if !ok || evaluateInterval < 30 || evaluateInterval > 86400 {
+		config["evaluate_interval"] = 300.0 // 默认5分钟
+	}
+
+	// 验证评分历史保留数量
+	historySize, ok := config["history_size"].(float64)
+	if !ok || historySize < 10 || historySize > 1000 {
+		config["history_size"] = 200.0
+	}
+
+	normalizeScoreWeights(config)
+
+	return nil
+}
+
+func (ss *StrategyService) GetUserStrategies(userID uint, status models.StrategyStatus, page, limit int) ([]models.Strategy, int64, error) {
 	var strategies []models.Strategy
 	var total int64
 
 	query := ss.db.Model(&models.Strategy{}).Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
 	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
@@ -380,7 +492,7 @@ func (ss *StrategyService) GetStrategyByID(userID, strategyID uint) (*models.Str
 }
 
 func (ss *StrategyService) UpdateStrategy(userID, strategyID uint, updates map[string]interface{}) error {
-	allowedFields := []string{"name", "is_active", "auto_restart", "take_profit", "stop_loss", "config"}
+	allowedFields := []string{"name", "auto_restart", "take_profit", "stop_loss", "config", "paper_trading"}
 	filteredUpdates := make(map[string]interface{})
 
 	for field, value := range updates {
@@ -389,55 +501,156 @@ func (ss *StrategyService) UpdateStrategy(userID, strategyID uint, updates map[s
 		}
 	}
 
-	if len(filteredUpdates) == 0 {
+	// is_active保留兼容，转换为启动或暂停
+	active, hasActive := updates["is_active"].(bool)
+	if len(filteredUpdates) == 0 && !hasActive {
 		return errors.New("没有有效的更新字段")
 	}
 
-	return ss.db.Model(&models.Strategy{}).Where("id = ? AND user_id = ?", strategyID, userID).Updates(filteredUpdates).Error
+	if _, ok := filteredUpdates["paper_trading"]; ok {
+		var strategy models.Strategy
+		if err := ss.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+			return err
+		}
+		if strategy.IsActive {
+			return errors.New("请先停止策略再切换模拟交易模式")
+		}
+	}
+
+	// 替换配置时按策略类型重新校验并补全默认值
+	if config, ok := filteredUpdates["config"].(map[string]interface{}); ok {
+		var strategy models.Strategy
+		if err := ss.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+			return err
+		}
+		strategy.Config = models.StrategyConfig(config)
+		if err := ss.validateStrategyConfig(&strategy); err != nil {
+			return err
+		}
+		filteredUpdates["config"] = strategy.Config
+	} else if _, ok := filteredUpdates["config"]; ok {
+		return errors.New("策略配置格式无效")
+	}
+
+	if len(filteredUpdates) > 0 {
+		if err := ss.db.Model(&models.Strategy{}).Where("id = ? AND user_id = ?", strategyID, userID).Updates(filteredUpdates).Error; err != nil {
+			return err
+		}
+	}
+
+	if hasActive {
+		strategy, err := ss.GetStrategyByID(userID, strategyID)
+		if err != nil {
+			return err
+		}
+		if active != strategy.Status.IsActive() {
+			return transitionSpotStrategy(ss.db, strategy, toggleTarget(strategy.Status), models.StrategyActorUser, "更新is_active")
+		}
+	}
+	return nil
 }
 
+// ToggleStrategy 执行中的策略暂停，其余启动
 func (ss *StrategyService) ToggleStrategy(userID, strategyID uint) error {
-	var strategy models.Strategy
-	if err := ss.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
+	strategy, err := ss.GetStrategyByID(userID, strategyID)
+	if err != nil {
 		return err
 	}
 
-	return ss.db.Model(&strategy).Update("is_active", !strategy.IsActive).Error
+	return transitionSpotStrategy(ss.db, strategy, toggleTarget(strategy.Status), models.StrategyActorUser, "切换启停")
+}
+
+// SetStrategyStatus 用户启动、暂停或停止策略，停止后由调度器撤销挂单
+func (ss *StrategyService) SetStrategyStatus(userID, strategyID uint, status models.StrategyStatus, reason string) (*models.Strategy, error) {
+	if err := validateUserStrategyStatus(status); err != nil {
+		return nil, err
+	}
+	strategy, err := ss.GetStrategyByID(userID, strategyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := transitionSpotStrategy(ss.db, strategy, status, models.StrategyActorUser, reason); err != nil {
+		return nil, err
+	}
+	return strategy, nil
+}
+
+// GetStrategyStatusHistory 策略状态变更历史
+func (ss *StrategyService) GetStrategyStatusHistory(userID, strategyID uint, page, limit int) ([]models.StrategyStatusHistory, int64, error) {
+	if _, err := ss.GetStrategyByID(userID, strategyID); err != nil {
+		return nil, 0, err
+	}
+	return getStrategyStatusHistory(ss.db, MarketSpot, strategyID, page, limit)
 }
 
 func (ss *StrategyService) DeleteStrategy(userID, strategyID uint) error {
 	return ss.db.Where("id = ? AND user_id = ?", strategyID, userID).Delete(&models.Strategy{}).Error
 }
 
+// ExecuteStrategy 执行一轮策略并按执行结果更新策略状态
 func (ss *StrategyService) ExecuteStrategy(strategy *models.Strategy) error {
-	apiKey, secretKey, err := ss.userService.GetUserAPIKeys(strategy.UserID)
+	err := ss.executeStrategy(strategy)
+	ss.afterStrategyExecution(strategy, err)
+	return err
+}
+
+// executeStrategy 停止中的策略只撤销挂单，其余按策略类型执行
+func (ss *StrategyService) executeStrategy(strategy *models.Strategy) error {
+	exchange, err := ss.userService.GetTradingExchange(strategy.UserID, strategy.PaperTrading)
 	if err != nil {
 		return err
 	}
 
-	binanceService, err := NewBinanceService(apiKey, secretKey)
-	if err != nil {
-		return err
+	if strategy.Status == models.StrategyStatusStopping {
+		return ss.finishStopping(strategy, exchange)
 	}
+	return ss.executeWithExchange(strategy, exchange)
+}
 
+// executeWithExchange 按策略类型在指定交易所上执行一轮，实盘、模拟盘和回测共用
+func (ss *StrategyService) executeWithExchange(strategy *models.Strategy, exchange Exchange) error {
 	switch strategy.Type {
 	case models.StrategySimple:
-		return ss.executeSimpleStrategy(strategy, binanceService)
+		return ss.executeSimpleStrategy(strategy, exchange)
 	case models.StrategyIceberg:
-		return ss.executeIcebergStrategy(strategy, binanceService)
+		return ss.executeIcebergStrategy(strategy, exchange)
 	case models.StrategySlowIceberg:
-		return ss.executeSlowIcebergStrategy(strategy, binanceService)
+		return ss.executeSlowIcebergStrategy(strategy, exchange)
 	case models.StrategyGrid:
-		return ss.executeGridStrategy(strategy, binanceService)
+		return ss.executeGridStrategy(strategy, exchange)
 	case models.StrategyDCA:
-		return ss.executeDCAStrategy(strategy, binanceService)
+		return ss.executeDCAStrategy(strategy, exchange)
+	case models.StrategyTWAP, models.StrategyVWAP:
+		return ss.executeExecutionStrategy(strategy, exchange)
+	case models.StrategyWeightedScoring:
+		return ss.executeWeightedScoringStrategy(strategy, exchange)
+	case models.StrategyQuantitative:
+		// 综合量化策略由StrategyExecutor按update_interval独立调度
+		return nil
 	default:
 		return fmt.Errorf("不支持的策略类型: %s", strategy.Type)
 	}
 }
 
-func (ss *StrategyService) executeSimpleStrategy(strategy *models.Strategy, binanceService *BinanceService) error {
-	currentPrice, err := binanceService.GetPrice(context.Background(), strategy.Symbol)
+// currentTime 当前时间，回测时返回K线时间
+func (ss *StrategyService) currentTime() time.Time {
+	if ss.now != nil {
+		return ss.now()
+	}
+	return time.Now()
+}
+
+func (ss *StrategyService) executeSimpleStrategy(strategy *models.Strategy, exchange Exchange) error {
+	// 入场单已提交的策略由跟踪止损接管
+	if trailing := loadTrailingState(strategy); trailing != nil {
+		return ss.runTrailing(strategy, exchange, trailing)
+	}
+	trailingCfg, err := parseTrailingConfig(strategy)
+	if err != nil {
+		return err
+	}
+
+	currentPrice, err := exchange.GetPrice(context.Background(), strategy.Symbol)
 	if err != nil {
 		return err
 	}
@@ -473,24 +686,35 @@ func (ss *StrategyService) executeSimpleStrategy(strategy *models.Strategy, bina
 		order.TimeInForce = "GTC"
 	}
 
-	resp, err := binanceService.CreateSpotOrder(context.Background(), order)
+	resp, err := exchange.CreateSpotOrder(context.Background(), order)
 	if err != nil {
 		return err
 	}
 
-	order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+	order.OrderID = resp.OrderID
 	order.Status = models.OrderStatus(resp.Status)
+	// 市价单可能立即成交，记录成交数量供跟踪止损确认入场
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
 
 	if err := ss.db.Create(order).Error; err != nil {
 		log.Printf("保存订单失败: %v", err)
 	}
 
-	ss.db.Model(strategy).Update("is_completed", true)
+	if trailingCfg != nil {
+		trailing, err := ss.startTrailing(strategy, order.OrderID)
+		if err != nil {
+			return err
+		}
+		return ss.runTrailing(strategy, exchange, trailing)
+	}
+
+	ss.completeStrategy(strategy, "订单已提交")
 
 	return nil
 }
 
-func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, binanceService *BinanceService) error {
+func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, exchange Exchange) error {
 	config := strategy.Config
 	layers := int(config["layers"].(float64))
 	layerQuantities := config["layer_quantities"].([]interface{})
@@ -498,7 +722,7 @@ func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, bin
 	timeout := int(config["timeout"].(float64))
 
 	// 获取当前价格
-	currentPrice, err := binanceService.GetPrice(context.Background(), strategy.Symbol)
+	currentPrice, err := exchange.GetPrice(context.Background(), strategy.Symbol)
 	if err != nil {
 		return err
 	}
@@ -518,10 +742,10 @@ func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, bin
 	// 如果有活跃订单，检查是否超时
 	if len(existingOrders) > 0 {
 		oldestOrder := existingOrders[0]
-		if time.Since(oldestOrder.CreatedAt).Minutes() > float64(timeout) {
+		if ss.currentTime().Sub(oldestOrder.CreatedAt).Minutes() > float64(timeout) {
 			// 取消所有未完成订单
 			for _, order := range existingOrders {
-				if _, err := binanceService.CancelSpotOrder(context.Background(), order.Symbol, order.OrderID); err != nil {
+				if _, err := exchange.CancelSpotOrder(context.Background(), order.Symbol, order.OrderID); err != nil {
 					log.Printf("取消订单失败: %v", err)
 				}
 				ss.db.Model(&order).Update("status", "CANCELED")
@@ -532,7 +756,7 @@ func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, bin
 	}
 
 	// 获取买一/卖一价
-	depth, err := binanceService.GetOrderBook(context.Background(), strategy.Symbol, 5)
+	depth, err := exchange.GetOrderBook(context.Background(), strategy.Symbol, 5)
 	if err != nil {
 		return err
 	}
@@ -540,13 +764,13 @@ func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, bin
 	var basePrice float64
 	if strategy.Side == models.OrderSideBuy {
 		if len(depth.Bids) > 0 {
-			basePrice, _ = strconv.ParseFloat(depth.Bids[0].Price, 64)
+			basePrice = depth.Bids[0].Price
 		} else {
 			basePrice = currentPrice * 0.999 // 没有买一价时使用当前价格-0.1%
 		}
 	} else {
 		if len(depth.Asks) > 0 {
-			basePrice, _ = strconv.ParseFloat(depth.Asks[0].Price, 64)
+			basePrice = depth.Asks[0].Price
 		} else {
 			basePrice = currentPrice * 1.001 // 没有卖一价时使用当前价格+0.1%
 		}
@@ -582,13 +806,13 @@ func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, bin
 			ClientOrderID: utils.GenerateUUID(),
 		}
 
-		resp, err := binanceService.CreateSpotOrder(context.Background(), order)
+		resp, err := exchange.CreateSpotOrder(context.Background(), order)
 		if err != nil {
 			log.Printf("创建第%d层订单失败: %v", i+1, err)
 			continue
 		}
 
-		order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+		order.OrderID = resp.OrderID
 		order.Status = models.OrderStatus(resp.Status)
 
 		if err := ss.db.Create(order).Error; err != nil {
@@ -599,7 +823,7 @@ func (ss *StrategyService) executeIcebergStrategy(strategy *models.Strategy, bin
 	return nil
 }
 
-func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy, binanceService *BinanceService) error {
+func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy, exchange Exchange) error {
 	config := strategy.Config
 	layers := int(config["layers"].(float64))
 	layerQuantities := config["layer_quantities"].([]interface{})
@@ -607,7 +831,7 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 	timeout := int(config["timeout"].(float64))
 
 	// 获取当前价格
-	currentPrice, err := binanceService.GetPrice(context.Background(), strategy.Symbol)
+	currentPrice, err := exchange.GetPrice(context.Background(), strategy.Symbol)
 	if err != nil {
 		return err
 	}
@@ -637,7 +861,7 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 
 	// 检查是否所有层都已完成
 	if currentLayerInt >= layers {
-		ss.db.Model(strategy).Update("is_completed", true)
+		ss.completeStrategy(strategy, "所有层级已完成")
 		return nil
 	}
 
@@ -648,12 +872,12 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 	if len(activeOrders) > 0 {
 		// 检查超时
 		for _, order := range activeOrders {
-			if time.Since(order.CreatedAt).Minutes() > float64(timeout) {
+			if ss.currentTime().Sub(order.CreatedAt).Minutes() > float64(timeout) {
 				// 获取订单最新状态
-				orderResp, err := binanceService.GetSpotOrderStatus(context.Background(), order.Symbol, order.OrderID)
+				orderResp, err := exchange.GetSpotOrderStatus(context.Background(), order.Symbol, order.OrderID)
 				if err == nil {
 					// 更新订单状态
-					executedQty, _ := strconv.ParseFloat(orderResp.ExecutedQuantity, 64)
+					executedQty := orderResp.ExecutedQty
 					if executedQty > 0 {
 						// 部分成交，更新已成交数量
 						layerFilledQuantity += executedQty
@@ -668,7 +892,7 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 				}
 
 				// 取消超时订单
-				if _, err := binanceService.CancelSpotOrder(context.Background(), order.Symbol, order.OrderID); err != nil {
+				if _, err := exchange.CancelSpotOrder(context.Background(), order.Symbol, order.OrderID); err != nil {
 					log.Printf("取消订单失败: %v", err)
 				} else {
 					ss.db.Model(&order).Update("status", "CANCELED")
@@ -702,7 +926,7 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 			strategyState["layer_filled_quantity"] = 0
 			strategyState["total_filled_quantity"] = totalFilledQuantity
 			ss.db.Model(strategy).Update("state", strategyState)
-			ss.db.Model(strategy).Update("is_completed", true)
+			ss.completeStrategy(strategy, "所有层级已完成")
 			return nil
 		}
 
@@ -722,7 +946,7 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 			strategyState["layer_filled_quantity"] = 0
 			strategyState["total_filled_quantity"] = totalFilledQuantity
 			ss.db.Model(strategy).Update("state", strategyState)
-			ss.db.Model(strategy).Update("is_completed", true)
+			ss.completeStrategy(strategy, "所有层级已完成")
 			return nil
 		}
 		quantityRatio := layerQuantities[currentLayerInt].(float64)
@@ -730,7 +954,7 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 	}
 
 	// 获取最新的买一/卖一价（每次挂单都重新获取，确保价格最新）
-	depth, err := binanceService.GetOrderBook(context.Background(), strategy.Symbol, 5)
+	depth, err := exchange.GetOrderBook(context.Background(), strategy.Symbol, 5)
 	if err != nil {
 		return err
 	}
@@ -738,13 +962,13 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 	var basePrice float64
 	if strategy.Side == models.OrderSideBuy {
 		if len(depth.Bids) > 0 {
-			basePrice, _ = strconv.ParseFloat(depth.Bids[0].Price, 64)
+			basePrice = depth.Bids[0].Price
 		} else {
 			basePrice = currentPrice * 0.999
 		}
 	} else {
 		if len(depth.Asks) > 0 {
-			basePrice, _ = strconv.ParseFloat(depth.Asks[0].Price, 64)
+			basePrice = depth.Asks[0].Price
 		} else {
 			basePrice = currentPrice * 1.001
 		}
@@ -775,13 +999,13 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 	log.Printf("慢冰山策略: 第%d层，挂单数量: %.8f，基准价格(买/卖1价): %.8f，浮动万分之%.0f，最终价格: %.8f",
 		currentLayerInt+1, remainingQty, basePrice, priceFloat, layerPrice)
 
-	resp, err := binanceService.CreateSpotOrder(context.Background(), order)
+	resp, err := exchange.CreateSpotOrder(context.Background(), order)
 	if err != nil {
 		log.Printf("创建订单失败: %v", err)
 		return err
 	}
 
-	order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+	order.OrderID = resp.OrderID
 	order.Status = models.OrderStatus(resp.Status)
 
 	if err := ss.db.Create(order).Error; err != nil {
@@ -797,87 +1021,18 @@ func (ss *StrategyService) executeSlowIcebergStrategy(strategy *models.Strategy,
 	return nil
 }
 
-func (ss *StrategyService) executeGridStrategy(strategy *models.Strategy, binanceService *BinanceService) error {
+func (ss *StrategyService) executeDCAStrategy(strategy *models.Strategy, exchange Exchange) error {
 	config := strategy.Config
-	upperPrice := config["upper_price"].(float64)
-	lowerPrice := config["lower_price"].(float64)
-	gridCount := int(config["grid_count"].(float64))
-
-	priceGap := (upperPrice - lowerPrice) / float64(gridCount-1)
-
-	currentPrice, err := binanceService.GetPrice(context.Background(), strategy.Symbol)
-	if err != nil {
-		return err
+	if mode, _ := config["mode"].(string); mode == dcaModeBot {
+		return ss.executeDCABot(strategy, exchange)
 	}
 
-	if currentPrice < lowerPrice || currentPrice > upperPrice {
-		return nil
-	}
-
-	var existingOrders []models.Order
-	ss.db.Where("strategy_id = ? AND status = ?", strategy.ID, "NEW").Find(&existingOrders)
-
-	if len(existingOrders) >= gridCount {
-		return nil
-	}
-
-	for i := 0; i < gridCount; i++ {
-		price := lowerPrice + float64(i)*priceGap
-
-		exists := false
-		for _, order := range existingOrders {
-			if math.Abs(order.Price-price) < 0.001 {
-				exists = true
-				break
-			}
-		}
-
-		if exists {
-			continue
-		}
-
-		side := models.OrderSideBuy
-		if price > currentPrice {
-			side = models.OrderSideSell
-		}
-
-		order := &models.Order{
-			UserID:        strategy.UserID,
-			StrategyID:    &strategy.ID,
-			Symbol:        strategy.Symbol,
-			Side:          side,
-			Type:          models.OrderTypeLimit,
-			Quantity:      strategy.Quantity / float64(gridCount),
-			Price:         price,
-			TimeInForce:   "GTC",
-			ClientOrderID: utils.GenerateUUID(),
-		}
-
-		resp, err := binanceService.CreateSpotOrder(context.Background(), order)
-		if err != nil {
-			log.Printf("创建网格订单失败: %v", err)
-			continue
-		}
-
-		order.OrderID = strconv.FormatInt(resp.OrderID, 10)
-		order.Status = models.OrderStatus(resp.Status)
-
-		if err := ss.db.Create(order).Error; err != nil {
-			log.Printf("保存订单失败: %v", err)
-		}
-	}
-
-	return nil
-}
-
-func (ss *StrategyService) executeDCAStrategy(strategy *models.Strategy, binanceService *BinanceService) error {
-	config := strategy.Config
 	interval := config["interval"].(float64)
 	totalAmount := config["total_amount"].(float64)
 
 	var lastOrder models.Order
 	if err := ss.db.Where("strategy_id = ?", strategy.ID).Order("created_at desc").First(&lastOrder).Error; err == nil {
-		if time.Since(lastOrder.CreatedAt).Hours() < interval {
+		if ss.currentTime().Sub(lastOrder.CreatedAt).Hours() < interval {
 			return nil
 		}
 	}
@@ -891,7 +1046,7 @@ func (ss *StrategyService) executeDCAStrategy(strategy *models.Strategy, binance
 	}
 
 	if totalInvested >= totalAmount {
-		ss.db.Model(strategy).Update("is_completed", true)
+		ss.completeStrategy(strategy, "已达到定投总额")
 		return nil
 	}
 
@@ -905,13 +1060,16 @@ func (ss *StrategyService) executeDCAStrategy(strategy *models.Strategy, binance
 		ClientOrderID: utils.GenerateUUID(),
 	}
 
-	resp, err := binanceService.CreateSpotOrder(context.Background(), order)
+	resp, err := exchange.CreateSpotOrder(context.Background(), order)
 	if err != nil {
 		return err
 	}
 
-	order.OrderID = strconv.FormatInt(resp.OrderID, 10)
+	order.OrderID = resp.OrderID
 	order.Status = models.OrderStatus(resp.Status)
+	// 市价单立即成交，不会再被订单轮询同步，需在此记录成交额供定投总额判断
+	order.ExecutedQty = resp.ExecutedQty
+	order.CumulativeQuoteQty = resp.CumulativeQuoteQty
 
 	if err := ss.db.Create(order).Error; err != nil {
 		log.Printf("保存订单失败: %v", err)
@@ -920,6 +1078,234 @@ func (ss *StrategyService) executeDCAStrategy(strategy *models.Strategy, binance
 	return nil
 }
 
+func (ss *StrategyService) executeWeightedScoringStrategy(strategy *models.Strategy, exchange Exchange) error {
+	config := strategy.Config
+	// 配置缺失或类型不符时使用创建时的默认值
+	entryThreshold, ok := config["entry_threshold"].(float64)
+	if !ok {
+		entryThreshold = 65.0
+	}
+	exitThreshold, ok := config["exit_threshold"].(float64)
+	if !ok {
+		exitThreshold = 45.0
+	}
+	interval, ok := config["kline_interval"].(string)
+	if !ok {
+		interval = "1h"
+	}
+	evaluateInterval, ok := config["evaluate_interval"].(float64)
+	if !ok {
+		evaluateInterval = 300.0
+	}
+	historySize := 200
+	if size, ok := config["history_size"].(float64); ok {
+		historySize = int(size)
+	}
+	orderAmount, _ := config["order_amount_usdt"].(float64)
+	weights := scoreWeightsFromConfig(config)
+
+	var symbols []string
+	switch list := config["symbols"].(type) {
+	case []interface{}:
+		for _, s := range list {
+			if symbol, ok := s.(string); ok {
+				symbols = append(symbols, utils.ToUpper(symbol))
+			}
+		}
+	case []string:
+		symbols = list
+	}
+	if len(symbols) == 0 {
+		symbols = []string{strategy.Symbol}
+	}
+
+	strategyState := strategy.State
+	if strategyState == nil {
+		strategyState = make(models.StrategyState)
+	}
+
+	// 未到评分间隔则跳过
+	if lastEvaluated, ok := strategyState["last_evaluated"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, lastEvaluated); err == nil && ss.currentTime().Sub(t).Seconds() < evaluateInterval {
+			return nil
+		}
+	}
+
+	scores := make(map[string]*PairScore)
+	_ = decodeStateValue(strategyState["scores"], &scores)
+	history := make(map[string][]ScoreSnapshot)
+	_ = decodeStateValue(strategyState["score_history"], &history)
+	positions := make(map[string]*QuantPosition)
+	_ = decodeStateValue(strategyState["positions"], &positions)
+	realizedPnL, _ := strategyState["realized_pnl"].(float64)
+
+	for _, symbol := range symbols {
+		score, err := scoreSymbol(exchange, symbol, interval, 100, weights)
+		if err != nil {
+			log.Printf("加权评分策略%d评分%s失败: %v", strategy.ID, symbol, err)
+			continue
+		}
+
+		// 上一次评分用于判断是否上穿开仓阈值
+		previousScore := -1.0
+		if previous, ok := scores[symbol]; ok {
+			previousScore = previous.TotalScore
+		}
+
+		scores[symbol] = score
+		symbolHistory := append(history[symbol], score.Snapshot())
+		if len(symbolHistory) > historySize {
+			symbolHistory = symbolHistory[len(symbolHistory)-historySize:]
+		}
+		history[symbol] = symbolHistory
+
+		position, held := positions[symbol]
+		switch {
+		case held && score.TotalScore < exitThreshold:
+			// 评分衰减到平仓阈值以下，市价卖出
+			order := &models.Order{
+				UserID:        strategy.UserID,
+				StrategyID:    &strategy.ID,
+				Symbol:        symbol,
+				Side:          models.OrderSideSell,
+				Type:          models.OrderTypeMarket,
+				Quantity:      position.Quantity,
+				ClientOrderID: utils.GenerateUUID(),
+			}
+			executedQty, quoteQty, err := submitMarketOrder(ss.db, exchange, order)
+			if err != nil {
+				log.Printf("加权评分策略%d平仓%s失败: %v", strategy.ID, symbol, err)
+				continue
+			}
+			if executedQty <= 0 {
+				continue
+			}
+			baseFee, quoteFee := ss.spotOrderFees(exchange, symbol, order.OrderID)
+			pnl, closed := reduceWeightedPosition(position, executedQty, quoteQty, baseFee, quoteFee)
+			realizedPnL += pnl
+			if closed {
+				delete(positions, symbol)
+			}
+			log.Printf("加权评分策略%d平仓%s: 评分%.2f低于%.2f，盈亏%.4f", strategy.ID, symbol, score.TotalScore, exitThreshold, pnl)
+
+		case !held && weightedScoringEntry(previousScore, score.TotalScore, entryThreshold):
+			// 评分上穿开仓阈值，市价买入
+			quantity := strategy.Quantity
+			if orderAmount > 0 {
+				quantity = orderAmount / score.Price
+			}
+			order := &models.Order{
+				UserID:        strategy.UserID,
+				StrategyID:    &strategy.ID,
+				Symbol:        symbol,
+				Side:          models.OrderSideBuy,
+				Type:          models.OrderTypeMarket,
+				Quantity:      quantity,
+				ClientOrderID: utils.GenerateUUID(),
+			}
+			executedQty, quoteQty, err := submitMarketOrder(ss.db, exchange, order)
+			if err != nil {
+				log.Printf("加权评分策略%d开仓%s失败: %v", strategy.ID, symbol, err)
+				continue
+			}
+			if executedQty <= 0 {
+				continue
+			}
+			// 以基础资产支付的手续费从持仓数量中扣除，保证平仓时余额足够；以计价资产支付的手续费计入开仓成本
+			baseFee, quoteFee := ss.spotOrderFees(exchange, symbol, order.OrderID)
+			quantity = executedQty - baseFee
+			if quantity <= 0 {
+				log.Printf("加权评分策略%d开仓%s扣除手续费后数量为0", strategy.ID, symbol)
+				continue
+			}
+			positions[symbol] = &QuantPosition{
+				Symbol:     symbol,
+				Quantity:   quantity,
+				EntryPrice: (quoteQty + quoteFee) / quantity,
+				CostUSDT:   quoteQty + quoteFee,
+				EntryScore: score.TotalScore,
+				OrderID:    order.OrderID,
+				OpenedAt:   ss.currentTime(),
+			}
+			log.Printf("加权评分策略%d开仓%s: 评分%.2f上穿%.2f", strategy.ID, symbol, score.TotalScore, entryThreshold)
+		}
+	}
+
+	// 更新持仓市值
+	for symbol, position := range positions {
+		if score, ok := scores[symbol]; ok {
+			position.CurrentPrice = score.Price
+			position.UnrealizedPnL = (score.Price - position.EntryPrice) * position.Quantity
+			if position.EntryPrice > 0 {
+				position.PnLPercent = (score.Price - position.EntryPrice) / position.EntryPrice * 100
+			}
+		}
+	}
+
+	strategyState["scores"] = encodeStateValue(scores)
+	strategyState["score_history"] = encodeStateValue(history)
+	strategyState["positions"] = encodeStateValue(positions)
+	strategyState["realized_pnl"] = realizedPnL
+	strategyState["last_evaluated"] = ss.currentTime().Format(time.RFC3339)
+
+	return ss.db.Model(strategy).Update("state", strategyState).Error
+}
+
+// weightedScoringEntry 评分是否上穿开仓阈值。首次评分（previousScore<0）已在阈值之上也视为上穿，
+// 策略启动时即满足条件的交易对不必等待评分回落再上穿
+func weightedScoringEntry(previousScore, score, entryThreshold float64) bool {
+	return previousScore < entryThreshold && score >= entryThreshold
+}
+
+// reduceWeightedPosition 按平仓成交扣减加权评分策略的持仓，返回扣除平仓手续费后的已实现盈亏和持仓是否已全部平掉。
+// 开仓手续费已计入EntryPrice
+func reduceWeightedPosition(position *QuantPosition, executedQty, quoteQty, baseFee, quoteFee float64) (float64, bool) {
+	proceeds := quoteQty - quoteFee
+	if executedQty > 0 {
+		proceeds -= baseFee * quoteQty / executedQty
+	}
+	pnl := proceeds - executedQty*position.EntryPrice
+
+	if remaining := position.Quantity - executedQty; remaining > position.Quantity*0.001 {
+		position.Quantity = remaining
+		position.CostUSDT = remaining * position.EntryPrice
+		return pnl, false
+	}
+	return pnl, true
+}
+
+// spotOrderFees 现货订单以基础资产和计价资产支付的手续费，成交记录尚未同步时向交易所查询
+func (ss *StrategyService) spotOrderFees(exchange Exchange, symbol, orderID string) (float64, float64) {
+	base, quote := splitSymbolBySuffix(symbol)
+	baseFee, quoteFee := ss.orderFees(orderID, base, quote)
+	if baseFee == 0 && quoteFee == 0 {
+		baseFee, quoteFee = exchangeOrderFees(exchange, symbol, orderID, base, quote)
+	}
+	return baseFee, quoteFee
+}
+
+// submitMarketOrder 提交市价单并保存订单记录，返回成交数量和成交额
+func submitMarketOrder(db *gorm.DB, exchange Exchange, order *models.Order) (float64, float64, error) {
+	resp, err := exchange.CreateSpotOrder(context.Background(), order)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	executedQty := resp.ExecutedQty
+	quoteQty := resp.CumulativeQuoteQty
+
+	order.OrderID = resp.OrderID
+	order.Status = models.OrderStatus(resp.Status)
+	order.ExecutedQty = executedQty
+	order.CumulativeQuoteQty = quoteQty
+
+	if err := db.Create(order).Error; err != nil {
+		log.Printf("保存订单失败: %v", err)
+	}
+
+	return executedQty, quoteQty, nil
+}
+
 func (ss *StrategyService) GetStrategyStats(userID, strategyID uint) (map[string]interface{}, error) {
 	var strategy models.Strategy
 	if err := ss.db.Where("id = ? AND user_id = ?", strategyID, userID).First(&strategy).Error; err != nil {
@@ -929,7 +1315,6 @@ func (ss *StrategyService) GetStrategyStats(userID, strategyID uint) (map[string
 	var totalOrders int64
 	var filledOrders int64
 	var totalVolume float64
-	var totalProfit float64
 
 	ss.db.Model(&models.Order{}).Where("strategy_id = ?", strategyID).Count(&totalOrders)
 	ss.db.Model(&models.Order{}).Where("strategy_id = ? AND status = ?", strategyID, "FILLED").Count(&filledOrders)
@@ -941,13 +1326,61 @@ func (ss *StrategyService) GetStrategyStats(userID, strategyID uint) (map[string
 		totalVolume += order.CumulativeQuoteQty
 	}
 
+	// 盈亏、手续费和挂单比例按实际成交明细计算
+	var trades []models.Trade
+	if err := ss.db.Where("strategy_id = ? AND market = ?", strategyID, string(MarketSpot)).Find(&trades).Error; err != nil {
+		return nil, err
+	}
+	summary := SummarizeSpotTrades(strategy.Symbol, trades)
+
+	// 盈亏由盈亏账本统一计算，与账户、交易对维度的统计口径一致
+	pnl, err := NewPnLLedgerService().Report(PnLQuery{
+		UserID:     userID,
+		Market:     MarketSpot,
+		StrategyID: &strategy.ID,
+		Method:     PnLCostFIFO,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	stats := map[string]interface{}{
-		"total_orders":  totalOrders,
-		"filled_orders": filledOrders,
-		"total_volume":  totalVolume,
-		"total_profit":  totalProfit,
-		"is_active":     strategy.IsActive,
-		"is_completed":  strategy.IsCompleted,
+		"total_orders":   totalOrders,
+		"filled_orders":  filledOrders,
+		"total_volume":   totalVolume,
+		"total_profit":   pnl.TotalPnL,
+		"realized_pnl":   pnl.AllTime.NetPnL,
+		"unrealized_pnl": pnl.UnrealizedPnL,
+		"daily_pnl":      pnl.Daily.NetPnL,
+		"weekly_pnl":     pnl.Weekly.NetPnL,
+		"fees_in_quote":  pnl.AllTime.Fees,
+		"fees":           summary.Fees,
+		"trade_count":    summary.TradeCount,
+		"maker_trades":   summary.MakerCount,
+		"taker_trades":   summary.TakerCount,
+		"maker_ratio":    summary.MakerRatio,
+		"open_quantity":  summary.OpenQuantity,
+		"average_cost":   summary.AverageCost,
+		"unmatched_sell": summary.UnmatchedSell,
+		"status":         strategy.Status,
+		"is_active":      strategy.IsActive,
+		"is_completed":   strategy.IsCompleted,
+	}
+	if strategy.Type == models.StrategyGrid {
+		stats["grid"] = gridStats(&strategy)
+	}
+	if strategy.Type == models.StrategyDCA {
+		if bot := dcaStats(strategy.State); bot != nil {
+			stats["dca"] = bot
+		}
+	}
+	if trailing := trailingStats(&strategy); trailing != nil {
+		stats["trailing"] = trailing
+	}
+	if strategy.Type == models.StrategyTWAP || strategy.Type == models.StrategyVWAP {
+		if execution := executionStats(strategy.State); execution != nil {
+			stats["execution"] = execution
+		}
 	}
 
 	return stats, nil
@@ -985,19 +1418,20 @@ func (ss *StrategyService) calculateDefaultLayerPriceFloats(layers int) []float6
 }
 
 // syncOrderStatus 同步订单状态并更新策略状态
-func (ss *StrategyService) syncOrderStatus(strategy *models.Strategy, order *models.Order, binanceService *BinanceService) error {
+func (ss *StrategyService) syncOrderStatus(strategy *models.Strategy, order *models.Order, exchange Exchange) error {
 	// 获取订单最新状态
-	orderResp, err := binanceService.GetSpotOrderStatus(context.Background(), order.Symbol, order.OrderID)
+	orderResp, err := exchange.GetSpotOrderStatus(context.Background(), order.Symbol, order.OrderID)
 	if err != nil {
 		return err
 	}
 
 	// 更新订单状态
-	executedQty, _ := strconv.ParseFloat(orderResp.ExecutedQuantity, 64)
+	executedQty := orderResp.ExecutedQty
 
 	updates := map[string]interface{}{
-		"status":       orderResp.Status,
-		"executed_qty": executedQty,
+		"status":               orderResp.Status,
+		"executed_qty":         executedQty,
+		"cumulative_quote_qty": orderResp.CumulativeQuoteQty,
 	}
 
 	if err := ss.db.Model(order).Updates(updates).Error; err != nil {
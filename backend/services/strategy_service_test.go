@@ -0,0 +1,102 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedScoringEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous float64
+		score    float64
+		want     bool
+	}{
+		{"first evaluation above threshold", -1, 70, true},
+		{"first evaluation below threshold", -1, 60, false},
+		{"crosses threshold", 60, 66, true},
+		{"reaches threshold exactly", 64.9, 65, true},
+		{"stays above threshold", 70, 72, false},
+		{"stays below threshold", 50, 60, false},
+		{"falls below threshold", 70, 60, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weightedScoringEntry(tt.previous, tt.score, 65); got != tt.want {
+				t.Errorf("weightedScoringEntry(%v, %v, 65) = %v, want %v", tt.previous, tt.score, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReduceWeightedPosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		position     QuantPosition
+		executedQty  float64
+		quoteQty     float64
+		baseFee      float64
+		quoteFee     float64
+		wantPnL      float64
+		wantClosed   bool
+		wantQuantity float64
+	}{
+		{
+			name:        "full exit deducts quote fee",
+			position:    QuantPosition{Quantity: 1, EntryPrice: 100.1, CostUSDT: 100.1},
+			executedQty: 1,
+			quoteQty:    110,
+			quoteFee:    0.11,
+			wantPnL:     110 - 0.11 - 100.1,
+			wantClosed:  true,
+		},
+		{
+			name:        "base asset fee valued at exit price",
+			position:    QuantPosition{Quantity: 0.999, EntryPrice: 100.1},
+			executedQty: 0.999,
+			quoteQty:    109.89,
+			baseFee:     0.001,
+			wantPnL:     109.89 - 0.001*110 - 0.999*100.1,
+			wantClosed:  true,
+		},
+		{
+			name:         "partial exit keeps the remainder",
+			position:     QuantPosition{Quantity: 2, EntryPrice: 100, CostUSDT: 200},
+			executedQty:  1,
+			quoteQty:     90,
+			quoteFee:     0.09,
+			wantPnL:      90 - 0.09 - 100,
+			wantQuantity: 1,
+		},
+		{
+			name:        "dust remainder closes the position",
+			position:    QuantPosition{Quantity: 1.0005, EntryPrice: 100},
+			executedQty: 1,
+			quoteQty:    100,
+			wantPnL:     0,
+			wantClosed:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := tt.position
+			pnl, closed := reduceWeightedPosition(&position, tt.executedQty, tt.quoteQty, tt.baseFee, tt.quoteFee)
+			if math.Abs(pnl-tt.wantPnL) > 1e-9 {
+				t.Errorf("pnl = %v, want %v", pnl, tt.wantPnL)
+			}
+			if closed != tt.wantClosed {
+				t.Fatalf("closed = %v, want %v", closed, tt.wantClosed)
+			}
+			if !closed {
+				if math.Abs(position.Quantity-tt.wantQuantity) > 1e-9 {
+					t.Errorf("remaining quantity = %v, want %v", position.Quantity, tt.wantQuantity)
+				}
+				if math.Abs(position.CostUSDT-tt.wantQuantity*position.EntryPrice) > 1e-9 {
+					t.Errorf("remaining cost = %v, want %v", position.CostUSDT, tt.wantQuantity*position.EntryPrice)
+				}
+			}
+		})
+	}
+}
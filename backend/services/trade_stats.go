@@ -0,0 +1,160 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ccj241/cctrade/models"
+)
+
+// TradeSummary 按成交明细汇总的盈亏、手续费和挂单/吃单比例
+type TradeSummary struct {
+	TradeCount    int                `json:"trade_count"`
+	MakerCount    int                `json:"maker_count"`
+	TakerCount    int                `json:"taker_count"`
+	MakerRatio    float64            `json:"maker_ratio"`    // 挂单成交笔数占比
+	MakerVolume   float64            `json:"maker_volume"`   // 挂单成交额（计价资产）
+	TakerVolume   float64            `json:"taker_volume"`   // 吃单成交额（计价资产）
+	BuyQuantity   float64            `json:"buy_quantity"`   // 买入数量
+	SellQuantity  float64            `json:"sell_quantity"`  // 卖出数量
+	Volume        float64            `json:"volume"`         // 成交额（计价资产）
+	RealizedPnL   float64            `json:"realized_pnl"`   // 已实现盈亏（计价资产，已扣除以计价资产和基础资产支付的手续费）
+	Fees          map[string]float64 `json:"fees"`           // 按资产统计的手续费
+	OpenQuantity  float64            `json:"open_quantity"`  // 现货按均价成本法剩余的持仓数量
+	AverageCost   float64            `json:"average_cost"`   // 现货剩余持仓的平均成本
+	UnmatchedSell float64            `json:"unmatched_sell"` // 现货无买入成本可匹配的卖出数量，不计入已实现盈亏
+}
+
+// sortTrades 按成交时间排序，同一时间按记录ID排序
+func sortTrades(trades []models.Trade) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].TradeTime.Equal(trades[j].TradeTime) {
+			return trades[i].ID < trades[j].ID
+		}
+		return trades[i].TradeTime.Before(trades[j].TradeTime)
+	})
+}
+
+// summarizeCounts 统计笔数、成交额、挂单吃单和手续费
+func summarizeCounts(summary *TradeSummary, trade models.Trade) {
+	summary.TradeCount++
+	summary.Volume += trade.QuoteQty
+	if trade.IsMaker {
+		summary.MakerCount++
+		summary.MakerVolume += trade.QuoteQty
+	} else {
+		summary.TakerCount++
+		summary.TakerVolume += trade.QuoteQty
+	}
+	if trade.Side == models.OrderSideBuy {
+		summary.BuyQuantity += trade.Quantity
+	} else {
+		summary.SellQuantity += trade.Quantity
+	}
+	if trade.Commission > 0 && trade.CommissionAsset != "" {
+		summary.Fees[strings.ToUpper(trade.CommissionAsset)] += trade.Commission
+	}
+}
+
+func finishSummary(summary *TradeSummary) {
+	if summary.TradeCount > 0 {
+		summary.MakerRatio = float64(summary.MakerCount) / float64(summary.TradeCount)
+	}
+}
+
+// SummarizeSpotTrades 用均价成本法计算现货成交的已实现盈亏：买入累加持仓成本，卖出按平均成本结转。
+// 以基础资产支付的手续费减少持仓数量，以计价资产支付的手续费计入成本或冲减卖出所得，其他资产（如BNB）只统计不折算
+func SummarizeSpotTrades(symbol string, trades []models.Trade) *TradeSummary {
+	base, quote := splitSymbolBySuffix(strings.ToUpper(symbol))
+	summary := &TradeSummary{Fees: make(map[string]float64)}
+
+	sortTrades(trades)
+
+	positionQty, positionCost := 0.0, 0.0
+	for _, trade := range trades {
+		summarizeCounts(summary, trade)
+
+		feeAsset := strings.ToUpper(trade.CommissionAsset)
+		if trade.Side == models.OrderSideBuy {
+			qty, cost := trade.Quantity, trade.QuoteQty
+			switch feeAsset {
+			case base:
+				qty -= trade.Commission
+			case quote:
+				cost += trade.Commission
+			}
+			positionQty += qty
+			positionCost += cost
+			continue
+		}
+
+		proceeds := trade.QuoteQty
+		if feeAsset == quote {
+			proceeds -= trade.Commission
+		}
+		sellQty := trade.Quantity
+		if feeAsset == base {
+			sellQty += trade.Commission
+		}
+
+		matched := math.Min(sellQty, positionQty)
+		if matched <= 0 {
+			summary.UnmatchedSell += sellQty
+			continue
+		}
+		averageCost := positionCost / positionQty
+		summary.RealizedPnL += proceeds*matched/sellQty - averageCost*matched
+		summary.UnmatchedSell += sellQty - matched
+
+		positionQty -= matched
+		positionCost -= averageCost * matched
+		if positionQty <= 1e-12 {
+			positionQty, positionCost = 0, 0
+		}
+	}
+
+	summary.OpenQuantity = positionQty
+	if positionQty > 0 {
+		summary.AverageCost = positionCost / positionQty
+	}
+	finishSummary(summary)
+	return summary
+}
+
+// SummarizeFuturesTrades 期货已实现盈亏取交易所返回的每笔realizedPnl，并扣除以保证金资产支付的手续费
+func SummarizeFuturesTrades(symbol string, trades []models.Trade) *TradeSummary {
+	_, quote := splitSymbolBySuffix(strings.ToUpper(symbol))
+	summary := &TradeSummary{Fees: make(map[string]float64)}
+
+	sortTrades(trades)
+
+	for _, trade := range trades {
+		summarizeCounts(summary, trade)
+
+		summary.RealizedPnL += trade.RealizedPnL
+		if strings.EqualFold(trade.CommissionAsset, quote) {
+			summary.RealizedPnL -= trade.Commission
+		}
+	}
+
+	finishSummary(summary)
+	return summary
+}
+
+// mergeTradeSummary 合并不同交易对的汇总，持仓数量和平均成本不可跨交易对合并，保持为0
+func mergeTradeSummary(total, summary *TradeSummary) {
+	total.TradeCount += summary.TradeCount
+	total.MakerCount += summary.MakerCount
+	total.TakerCount += summary.TakerCount
+	total.MakerVolume += summary.MakerVolume
+	total.TakerVolume += summary.TakerVolume
+	total.BuyQuantity += summary.BuyQuantity
+	total.SellQuantity += summary.SellQuantity
+	total.Volume += summary.Volume
+	total.RealizedPnL += summary.RealizedPnL
+	for asset, fee := range summary.Fees {
+		total.Fees[asset] += fee
+	}
+	finishSummary(total)
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ccj241/cctrade/models"
+	"github.com/ccj241/cctrade/utils"
+)
+
+// trailingStateKey 跟踪止损状态在Strategy.State中的键
+const trailingStateKey = "trailing"
+
+// trailingDefaultLimitTimeout 限价离场单的默认等待时间，超时未完全成交时撤单并以市价补足
+const trailingDefaultLimitTimeout = 60 * time.Second
+
+// TrailingMode 回撤距离的计算方式
+type TrailingMode string
+
+const (
+	TrailingModePercent  TrailingMode = "percent"  // 距离为极值的百分比
+	TrailingModeAbsolute TrailingMode = "absolute" // 距离为固定价差
+)
+
+// 跟踪止损所处阶段
+const (
+	trailingPhaseEntry   = "entry"   // 等待入场单成交
+	trailingPhaseArmed   = "armed"   // 已持仓，等待价格到达激活价
+	trailingPhaseActive  = "active"  // 跟踪极值中
+	trailingPhaseExiting = "exiting" // 离场单已提交
+	trailingPhaseDone    = "done"
+)
+
+// TrailingState 跟踪止损运行状态，每次变化都写回State，重启后从上次的极值继续跟踪。
+// 买入入场时跟踪最高价、回撤卖出；卖出入场时跟踪最低价、反弹买回
+type TrailingState struct {
+	Phase           string     `json:"phase"`
+	EntryOrderID    string     `json:"entry_order_id,omitempty"`
+	ExitSide        string     `json:"exit_side"`
+	Quantity        float64    `json:"quantity"`
+	EntryPrice      float64    `json:"entry_price"`
+	ActivationPrice float64    `json:"activation_price,omitempty"`
+	Extreme         float64    `json:"extreme"`    // 激活后的最高价（做多）或最低价（做空）
+	StopPrice       float64    `json:"stop_price"` // 当前离场触发价
+	ExitOrderID     string     `json:"exit_order_id,omitempty"`
+	ExitLimitPrice  float64    `json:"exit_limit_price,omitempty"` // 限价离场单价格，市价离场时为0
+	ExitPlacedAt    *time.Time `json:"exit_placed_at,omitempty"`
+	ExitedQuantity  float64    `json:"exited_quantity"`
+	ExitValue       float64    `json:"exit_value"`
+	ExitReason      string     `json:"exit_reason,omitempty"` // trailing_stop或stop_loss
+	TriggerPrice    float64    `json:"trigger_price,omitempty"`
+	TriggeredAt     *time.Time `json:"triggered_at,omitempty"`
+}
+
+// trailingConfig 跟踪止损配置，来自config.trailing；activation_price未设置时取strategy.TakeProfit，
+// strategy.StopLoss作为激活前后都生效的固定止损价
+type trailingConfig struct {
+	mode            TrailingMode
+	distance        float64
+	activationPrice float64
+	stopLoss        float64
+	exitType        models.OrderType
+	limitOffset     float64       // 限价离场时相对触发价让出的百分比
+	limitTimeout    time.Duration // 限价离场单的最长等待时间
+}
+
+// parseTrailingConfig 解析config.trailing，未配置时返回nil
+func parseTrailingConfig(strategy *models.Strategy) (*trailingConfig, error) {
+	raw, exists := strategy.Config["trailing"]
+	if !exists || raw == nil {
+		return nil, nil
+	}
+	config, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("trailing配置必须为对象")
+	}
+
+	cfg := &trailingConfig{
+		mode:            TrailingModePercent,
+		activationPrice: strategy.TakeProfit,
+		stopLoss:        strategy.StopLoss,
+		exitType:        models.OrderTypeMarket,
+		limitTimeout:    trailingDefaultLimitTimeout,
+	}
+	if mode, ok := config["mode"].(string); ok && mode != "" {
+		switch TrailingMode(strings.ToLower(mode)) {
+		case TrailingModePercent:
+		case TrailingModeAbsolute:
+			cfg.mode = TrailingModeAbsolute
+		default:
+			return nil, fmt.Errorf("不支持的跟踪方式: %s", mode)
+		}
+	}
+
+	cfg.distance, _ = config["distance"].(float64)
+	if cfg.distance <= 0 || (cfg.mode == TrailingModePercent && cfg.distance >= 100) {
+		return nil, errors.New("跟踪回撤距离无效")
+	}
+
+	if activation, ok := config["activation_price"].(float64); ok && activation > 0 {
+		cfg.activationPrice = activation
+	}
+
+	if exitType, ok := config["exit_type"].(string); ok && exitType != "" {
+		switch models.OrderType(strings.ToLower(exitType)) {
+		case models.OrderTypeMarket:
+		case models.OrderTypeLimit:
+			cfg.exitType = models.OrderTypeLimit
+		default:
+			return nil, fmt.Errorf("离场订单类型只能为market或limit: %s", exitType)
+		}
+	}
+
+	cfg.limitOffset, _ = config["limit_offset"].(float64)
+	if cfg.limitOffset < 0 || cfg.limitOffset >= 100 {
+		return nil, errors.New("限价离场偏移必须在0-100之间")
+	}
+
+	if timeout, ok := config["limit_timeout"].(float64); ok {
+		if timeout <= 0 {
+			return nil, errors.New("限价离场等待时间必须大于0秒")
+		}
+		cfg.limitTimeout = time.Duration(timeout * float64(time.Second))
+	}
+	return cfg, nil
+}
+
+// long 是否为买入入场、卖出离场
+func (ts *TrailingState) long() bool {
+	return ts.ExitSide == string(models.OrderSideSell)
+}
+
+// trailStop 按极值和回撤距离计算离场触发价
+func (cfg *trailingConfig) trailStop(extreme float64, long bool) float64 {
+	distance := cfg.distance
+	if cfg.mode == TrailingModePercent {
+		distance = extreme * cfg.distance / 100
+	}
+	if long {
+		return extreme - distance
+	}
+	return extreme + distance
+}
+
+// loadTrailingState 读取跟踪止损状态，不存在时返回nil
+func loadTrailingState(strategy *models.Strategy) *TrailingState {
+	if strategy.State == nil || strategy.State[trailingStateKey] == nil {
+		return nil
+	}
+	var state TrailingState
+	if err := decodeStateValue(strategy.State[trailingStateKey], &state); err != nil {
+		return nil
+	}
+	return &state
+}
+
+func (ss *StrategyService) saveTrailingState(strategy *models.Strategy, state *TrailingState) error {
+	if strategy.State == nil {
+		strategy.State = make(models.StrategyState)
+	}
+	strategy.State[trailingStateKey] = encodeStateValue(state)
+	return ss.db.Model(strategy).Update("state", strategy.State).Error
+}
+
+// startTrailing 入场单提交后开始跟踪，策略在离场完成前保持未完成状态
+func (ss *StrategyService) startTrailing(strategy *models.Strategy, entryOrderID string) (*TrailingState, error) {
+	state := &TrailingState{
+		Phase:        trailingPhaseEntry,
+		EntryOrderID: entryOrderID,
+		ExitSide:     string(oppositeOrder(strategy.Side)),
+	}
+	return state, ss.saveTrailingState(strategy, state)
+}
+
+// runTrailing 推进一轮跟踪止损：等待入场成交、等待激活、更新极值、触发离场并等待离场成交
+func (ss *StrategyService) runTrailing(strategy *models.Strategy, exchange Exchange, state *TrailingState) error {
+	cfg, err := parseTrailingConfig(strategy)
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		return errors.New("trailing配置已被移除")
+	}
+
+	venue := newSpotOrderVenue(ss, strategy, exchange)
+	before, _ := json.Marshal(state)
+	finished, runErr := ss.advanceTrailing(strategy, cfg, state, venue)
+
+	// 价格事件触发频繁，只在状态变化时写库
+	if after, _ := json.Marshal(state); !bytes.Equal(before, after) {
+		if err := ss.saveTrailingState(strategy, state); err != nil {
+			return err
+		}
+	}
+	if finished {
+		return ss.completeStrategy(strategy, "跟踪止损已结束")
+	}
+	return runErr
+}
+
+func (ss *StrategyService) advanceTrailing(strategy *models.Strategy, cfg *trailingConfig, state *TrailingState, venue *spotOrderVenue) (bool, error) {
+	label := fmt.Sprintf("策略%d", strategy.ID)
+
+	switch state.Phase {
+	case trailingPhaseDone:
+		return true, nil
+
+	case trailingPhaseEntry:
+		fill, err := venue.orderFill(state.EntryOrderID)
+		if err != nil || fill == nil {
+			return false, err
+		}
+		quantity := fill.Executed
+		if strategy.Side == models.OrderSideBuy {
+			quantity -= fill.BaseFee
+		}
+		if quantity <= 0 {
+			log.Printf("%s入场单未成交，跟踪止损结束", label)
+			state.Phase = trailingPhaseDone
+			return true, nil
+		}
+		state.Quantity = quantity
+		state.EntryPrice = fill.QuoteQty / fill.Executed
+		state.ActivationPrice = cfg.activationPrice
+		state.Phase = trailingPhaseArmed
+
+	case trailingPhaseExiting:
+		return ss.settleTrailingExit(state, cfg, venue, label)
+	}
+
+	price, err := venue.price()
+	if err != nil {
+		return false, err
+	}
+	long := state.long()
+
+	if state.Phase == trailingPhaseArmed {
+		activated := state.ActivationPrice <= 0 ||
+			(long && price >= state.ActivationPrice) || (!long && price <= state.ActivationPrice)
+		if activated {
+			state.Phase = trailingPhaseActive
+			state.Extreme = price
+			if state.ActivationPrice <= 0 && state.EntryPrice > 0 {
+				// 没有激活价时从入场价开始跟踪
+				state.Extreme = state.EntryPrice
+				if (long && price > state.Extreme) || (!long && price < state.Extreme) {
+					state.Extreme = price
+				}
+			}
+			state.StopPrice = cfg.trailStop(state.Extreme, long)
+		}
+	} else if state.Phase == trailingPhaseActive {
+		if (long && price > state.Extreme) || (!long && price < state.Extreme) {
+			state.Extreme = price
+			state.StopPrice = cfg.trailStop(state.Extreme, long)
+		}
+	}
+
+	reason := ""
+	if state.Phase == trailingPhaseActive && ((long && price <= state.StopPrice) || (!long && price >= state.StopPrice)) {
+		reason = "trailing_stop"
+	} else if cfg.stopLoss > 0 && ((long && price <= cfg.stopLoss) || (!long && price >= cfg.stopLoss)) {
+		reason = "stop_loss"
+	}
+	if reason == "" {
+		return false, nil
+	}
+
+	now := ss.currentTime()
+	state.ExitReason, state.TriggerPrice, state.TriggeredAt = reason, price, &now
+	log.Printf("%s触发%s，价格%.8f，极值%.8f", label, reason, price, state.Extreme)
+	if err := ss.placeTrailingExit(state, cfg, venue, state.Quantity, cfg.exitType); err != nil {
+		return false, err
+	}
+	return ss.settleTrailingExit(state, cfg, venue, label)
+}
+
+// placeTrailingExit 提交离场单，限价单价格为触发价向不利方向让出limit_offset
+func (ss *StrategyService) placeTrailingExit(state *TrailingState, cfg *trailingConfig, venue *spotOrderVenue, quantity float64, orderType models.OrderType) error {
+	price := 0.0
+	if orderType == models.OrderTypeLimit {
+		price = state.TriggerPrice * (1 - cfg.limitOffset/100)
+		if !state.long() {
+			price = state.TriggerPrice * (1 + cfg.limitOffset/100)
+		}
+	}
+	price, quantity = roundGridOrder(venue.symbolInfo(), price, quantity)
+	if quantity <= 0 {
+		return errors.New("离场数量过小")
+	}
+
+	orderID, err := venue.placeOrder(models.OrderSide(state.ExitSide), orderType, price, quantity, true)
+	if err != nil {
+		return err
+	}
+	now := ss.currentTime()
+	state.ExitOrderID, state.ExitLimitPrice, state.ExitPlacedAt = orderID, price, &now
+	state.Phase = trailingPhaseExiting
+	return nil
+}
+
+// settleTrailingExit 离场单结束后结算，被撤销或过期且有剩余数量时以市价补足。
+// 限价离场单等待超时或价格越过限价仍未成交时撤单，剩余数量同样以市价补足
+func (ss *StrategyService) settleTrailingExit(state *TrailingState, cfg *trailingConfig, venue *spotOrderVenue, label string) (bool, error) {
+	fill, err := venue.orderFill(state.ExitOrderID)
+	if err != nil {
+		return false, err
+	}
+	if fill == nil {
+		if !ss.trailingExitStale(state, cfg, venue) {
+			return false, nil
+		}
+		log.Printf("%s限价离场单%s未及时成交，撤单后以市价离场", label, state.ExitOrderID)
+		if err := venue.cancelOrder(state.ExitOrderID); err != nil {
+			return false, err
+		}
+		if fill, err = venue.orderFill(state.ExitOrderID); err != nil || fill == nil {
+			return false, err
+		}
+	}
+	state.ExitedQuantity += fill.Executed
+	state.ExitValue += fill.QuoteQty
+	state.ExitOrderID = ""
+
+	remaining := state.Quantity - state.ExitedQuantity
+	info := venue.symbolInfo()
+	if fill.Filled || remaining <= executionDust(info) || (info != nil && remaining < info.MinQty) {
+		state.Phase = trailingPhaseDone
+		log.Printf("%s跟踪止损离场完成，成交%.8f", label, state.ExitedQuantity)
+		return true, nil
+	}
+
+	if err := ss.placeTrailingExit(state, cfg, venue, remaining, models.OrderTypeMarket); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
+// trailingExitStale 限价离场单是否需要撤单改为市价：等待超过limit_timeout，或价格已越过限价（做多跌破、做空涨破）
+func (ss *StrategyService) trailingExitStale(state *TrailingState, cfg *trailingConfig, venue *spotOrderVenue) bool {
+	if state.ExitLimitPrice <= 0 {
+		return false
+	}
+	if state.ExitPlacedAt != nil && ss.currentTime().Sub(*state.ExitPlacedAt) >= cfg.limitTimeout {
+		return true
+	}
+	price, err := venue.price()
+	if err != nil {
+		return false
+	}
+	if state.long() {
+		return price < state.ExitLimitPrice
+	}
+	return price > state.ExitLimitPrice
+}
+
+// trailingStats 跟踪止损进度，离场成交均价和相对入场价的收益率
+func trailingStats(strategy *models.Strategy) map[string]interface{} {
+	state := loadTrailingState(strategy)
+	if state == nil {
+		return nil
+	}
+	stats := map[string]interface{}{
+		"phase":            state.Phase,
+		"entry_price":      state.EntryPrice,
+		"quantity":         state.Quantity,
+		"activation_price": state.ActivationPrice,
+		"extreme":          state.Extreme,
+		"stop_price":       state.StopPrice,
+		"exit_reason":      state.ExitReason,
+	}
+	if state.ExitedQuantity > 0 && state.EntryPrice > 0 {
+		exitPrice := state.ExitValue / state.ExitedQuantity
+		change := (exitPrice - state.EntryPrice) / state.EntryPrice * 100
+		if !state.long() {
+			change = -change
+		}
+		stats["exit_price"] = exitPrice
+		stats["return_percent"] = utils.RoundTo(change, 4)
+	}
+	return stats
+}
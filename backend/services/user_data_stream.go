@@ -0,0 +1,436 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccj241/cctrade/config"
+	"github.com/ccj241/cctrade/models"
+	"gorm.io/gorm"
+)
+
+// UserDataEventType 用户数据流事件类型
+type UserDataEventType string
+
+const (
+	UserDataEventOrderUpdate      UserDataEventType = "order_update"
+	UserDataEventListenKeyExpired UserDataEventType = "listen_key_expired"
+)
+
+// UserDataEvent 用户数据流推送事件，Applied为订单更新落库后的结果（系统外订单为nil）
+type UserDataEvent struct {
+	Type    UserDataEventType   `json:"type"`
+	Market  MarketType          `json:"market"`
+	UserID  uint                `json:"user_id"`
+	Order   *OrderUpdate        `json:"order,omitempty"`
+	Applied *AppliedOrderUpdate `json:"-"`
+	Time    int64               `json:"time"`
+}
+
+// UserDataEventHandler 用户数据流事件回调，在用户的事件处理协程中按推送顺序调用，不能长时间阻塞
+type UserDataEventHandler func(event *UserDataEvent)
+
+// UserDataStreamer 支持listenKey用户数据流的交易所实现该接口
+type UserDataStreamer interface {
+	StartUserDataStream(ctx context.Context, market MarketType) (string, error)
+	KeepaliveUserDataStream(ctx context.Context, market MarketType, listenKey string) error
+	CloseUserDataStream(ctx context.Context, market MarketType, listenKey string) error
+	ServeUserDataStream(market MarketType, listenKey string, handler UserDataEventHandler, errHandler func(error)) (<-chan struct{}, func(), error)
+}
+
+const (
+	userStreamKeepalive     = 30 * time.Minute // listenKey有效期60分钟，每30分钟续期
+	userStreamRefresh       = 30 * time.Second // 重新计算需要推送的用户的周期
+	userStreamMinBackoff    = time.Second
+	userStreamMaxBackoff    = time.Minute
+	userStreamStableAfter   = time.Minute
+	userStreamEventBuffer   = 256 // 单个用户待落库的推送事件上限，超出时丢弃并改为对账
+	defaultReconcileSeconds = 300
+)
+
+// userStreamSession 单个用户的数据流会话，apiKey变化时重建。
+// 推送事件经events交给会话的处理协程落库，读协程不等待数据库
+type userStreamSession struct {
+	apiKey      string
+	cancel      context.CancelFunc
+	live        map[MarketType]bool
+	events      chan *UserDataEvent
+	reconciling atomic.Bool
+}
+
+// UserDataStreamManager 按用户维护现货和U本位合约listenKey：负责创建、续期、过期重建和断线重连，
+// 并把executionReport和ORDER_TRADE_UPDATE立即写入订单、成交和策略状态
+type UserDataStreamManager struct {
+	db          *gorm.DB
+	userService *UserService
+	orderSync   *OrderSyncService
+	enabled     bool
+	reconcile   time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.RWMutex
+	sessions map[uint]*userStreamSession
+
+	subMu       sync.RWMutex
+	subscribers []UserDataEventHandler
+}
+
+func NewUserDataStreamManager(db *gorm.DB) *UserDataStreamManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := &UserDataStreamManager{
+		db:          db,
+		userService: &UserService{db: db},
+		orderSync:   &OrderSyncService{db: db},
+		enabled:     true,
+		reconcile:   defaultReconcileSeconds * time.Second,
+		ctx:         ctx,
+		cancel:      cancel,
+		sessions:    make(map[uint]*userStreamSession),
+	}
+
+	if config.AppConfig != nil {
+		manager.enabled = config.AppConfig.UserStream.Enabled
+		if config.AppConfig.UserStream.ReconcileSeconds > 0 {
+			manager.reconcile = time.Duration(config.AppConfig.UserStream.ReconcileSeconds) * time.Second
+		}
+	}
+
+	return manager
+}
+
+// Start 启动用户会话维护协程
+func (m *UserDataStreamManager) Start() {
+	if !m.enabled {
+		log.Println("用户数据流未启用，订单状态将使用定时轮询")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(userStreamRefresh)
+		defer ticker.Stop()
+
+		log.Println("用户数据流管理器已启动")
+		m.refreshSessions()
+
+		for {
+			select {
+			case <-m.ctx.Done():
+				log.Println("用户数据流管理器已停止")
+				return
+			case <-ticker.C:
+				m.refreshSessions()
+			}
+		}
+	}()
+}
+
+// Stop 断开所有用户数据流并关闭listenKey
+func (m *UserDataStreamManager) Stop() {
+	m.cancel()
+}
+
+// Subscribe 注册用户数据流事件回调，订单更新已落库后才会回调
+func (m *UserDataStreamManager) Subscribe(handler UserDataEventHandler) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.subscribers = append(m.subscribers, handler)
+}
+
+// IsLive 用户指定市场的数据流是否在线，为false时调用方应通过轮询同步订单
+func (m *UserDataStreamManager) IsLive(userID uint, market MarketType) bool {
+	if m == nil {
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	session, ok := m.sessions[userID]
+	return ok && session.live[market]
+}
+
+// ReconcileInterval 数据流在线时挂单的对账间隔
+func (m *UserDataStreamManager) ReconcileInterval() time.Duration {
+	if m == nil {
+		return 0
+	}
+	return m.reconcile
+}
+
+// refreshSessions 为有真实挂单或活跃实盘策略的用户建立数据流，不再需要的用户断开
+func (m *UserDataStreamManager) refreshSessions() {
+	userIDs, err := StreamingUserIDs(m.db)
+	if err != nil {
+		log.Printf("获取需要用户数据流的用户失败: %v", err)
+		return
+	}
+
+	wanted := make(map[uint]string, len(userIDs))
+	for _, userID := range userIDs {
+		apiKey, _, err := m.userService.GetUserAPIKeys(userID)
+		if err != nil {
+			continue
+		}
+		wanted[userID] = apiKey
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for userID, session := range m.sessions {
+		if apiKey, ok := wanted[userID]; !ok || apiKey != session.apiKey {
+			session.cancel()
+			delete(m.sessions, userID)
+		}
+	}
+
+	for userID, apiKey := range wanted {
+		if _, ok := m.sessions[userID]; ok {
+			continue
+		}
+
+		exchange, err := m.userService.GetExchange(userID)
+		if err != nil {
+			log.Printf("创建用户%d交易所服务失败: %v", userID, err)
+			continue
+		}
+		streamer, ok := unwrapExchange(exchange).(UserDataStreamer)
+		if !ok {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(m.ctx)
+		session := &userStreamSession{
+			apiKey: apiKey,
+			cancel: cancel,
+			live:   make(map[MarketType]bool),
+			events: make(chan *UserDataEvent, userStreamEventBuffer),
+		}
+		m.sessions[userID] = session
+		go m.processEvents(ctx, session, userID)
+		for _, market := range []MarketType{MarketSpot, MarketFutures} {
+			go m.runStream(ctx, session, userID, market, exchange, streamer)
+		}
+	}
+}
+
+// setLive 更新会话的在线状态，已被替换的旧会话不影响新会话
+func (m *UserDataStreamManager) setLive(session *userStreamSession, market MarketType, live bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session.live[market] = live
+}
+
+// runStream 维持用户单个市场的数据流：定时续期listenKey，过期或续期返回-1125时重建，断线按指数退避重连
+func (m *UserDataStreamManager) runStream(ctx context.Context, session *userStreamSession, userID uint, market MarketType, exchange Exchange, streamer UserDataStreamer) {
+	backoff := userStreamMinBackoff
+
+	for {
+		connectedAt, err := m.serveOnce(ctx, session, userID, market, exchange, streamer)
+		m.setLive(session, market, false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			log.Printf("用户%d %s数据流异常: %v，%v后重连", userID, market, err, backoff)
+		} else {
+			log.Printf("用户%d %s数据流已断开，%v后重连", userID, market, backoff)
+		}
+		if !connectedAt.IsZero() && time.Since(connectedAt) > userStreamStableAfter {
+			backoff = userStreamMinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > userStreamMaxBackoff {
+			backoff = userStreamMaxBackoff
+		}
+	}
+}
+
+// serveOnce 创建listenKey并保持一次连接，返回连接建立时间
+func (m *UserDataStreamManager) serveOnce(ctx context.Context, session *userStreamSession, userID uint, market MarketType, exchange Exchange, streamer UserDataStreamer) (time.Time, error) {
+	listenKey, err := streamer.StartUserDataStream(ctx, market)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		if err := streamer.CloseUserDataStream(context.Background(), market, listenKey); err != nil && !errors.Is(err, ErrListenKeyNotFound) {
+			log.Printf("关闭用户%d %s listenKey失败: %v", userID, market, err)
+		}
+	}()
+
+	expired := make(chan struct{})
+	var expiredOnce sync.Once
+	done, stop, err := streamer.ServeUserDataStream(market, listenKey, func(event *UserDataEvent) {
+		if event.Type == UserDataEventListenKeyExpired {
+			expiredOnce.Do(func() { close(expired) })
+			return
+		}
+		m.enqueueEvent(session, userID, market, exchange, event)
+	}, func(err error) {
+		log.Printf("用户%d %s数据流错误: %v", userID, market, err)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer stop()
+
+	connectedAt := time.Now()
+	m.setLive(session, market, true)
+	log.Printf("用户%d %s数据流已连接", userID, market)
+
+	// 连接建立前的成交没有推送，立即对账一次
+	go m.orderSync.ReconcileOpenOrders(userID, market, false, exchange, 0)
+
+	keepalive := time.NewTicker(userStreamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return connectedAt, nil
+		case <-done:
+			return connectedAt, nil
+		case <-expired:
+			return connectedAt, errors.New("listenKey已过期")
+		case <-keepalive.C:
+			if err := streamer.KeepaliveUserDataStream(ctx, market, listenKey); err != nil {
+				if errors.Is(err, ErrListenKeyNotFound) {
+					return connectedAt, err
+				}
+				log.Printf("用户%d %s listenKey续期失败: %v", userID, market, err)
+			}
+		}
+	}
+}
+
+// enqueueEvent 在读协程中把推送交给用户的处理协程；队列已满时丢弃，并对账一次补上丢弃的订单更新
+func (m *UserDataStreamManager) enqueueEvent(session *userStreamSession, userID uint, market MarketType, exchange Exchange, event *UserDataEvent) {
+	select {
+	case session.events <- event:
+		return
+	default:
+	}
+
+	log.Printf("用户%d %s推送积压，丢弃事件并改为对账", userID, market)
+	if session.reconciling.CompareAndSwap(false, true) {
+		go func() {
+			defer session.reconciling.Store(false)
+			m.orderSync.ReconcileOpenOrders(userID, market, false, exchange, 0)
+		}()
+	}
+}
+
+// processEvents 按推送顺序处理用户的事件，会话关闭时退出
+func (m *UserDataStreamManager) processEvents(ctx context.Context, session *userStreamSession, userID uint) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-session.events:
+			m.handleEvent(userID, event)
+		}
+	}
+}
+
+// handleEvent 订单更新落库后分发给订阅者
+func (m *UserDataStreamManager) handleEvent(userID uint, event *UserDataEvent) {
+	event.UserID = userID
+
+	if event.Type == UserDataEventOrderUpdate {
+		applied, err := m.orderSync.ApplyOrderUpdate(userID, event.Order)
+		if err != nil {
+			log.Printf("应用用户%d订单%s推送失败: %v", userID, event.Order.OrderID, err)
+			return
+		}
+		event.Applied = applied
+	}
+
+	m.subMu.RLock()
+	subscribers := m.subscribers
+	m.subMu.RUnlock()
+	for _, handler := range subscribers {
+		handler(event)
+	}
+}
+
+// StreamingUserIDs 需要用户数据流的用户：有真实挂单，或有非模拟的活跃策略且未开启模拟交易
+func StreamingUserIDs(db *gorm.DB) ([]uint, error) {
+	if db == nil {
+		return nil, gorm.ErrInvalidDB
+	}
+
+	openStatuses := []string{"NEW", "PARTIALLY_FILLED"}
+	seen := make(map[uint]bool)
+	var result []uint
+	collect := func(ids []uint) {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+	}
+
+	var ids []uint
+	if err := db.Model(&models.Order{}).
+		Where("status IN ? AND is_simulated = ?", openStatuses, false).
+		Distinct().Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	collect(ids)
+
+	ids = nil
+	if err := db.Model(&models.FuturesOrder{}).
+		Where("status IN ? AND is_simulated = ?", openStatuses, false).
+		Distinct().Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	collect(ids)
+
+	paperUsers := db.Model(&models.User{}).Select("id").Where("paper_trading = ?", true)
+
+	ids = nil
+	if err := db.Model(&models.Strategy{}).
+		Where("status IN ? AND paper_trading = ?", models.OpenStrategyStatuses, false).
+		Where("user_id NOT IN (?)", paperUsers).
+		Distinct().Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	collect(ids)
+
+	ids = nil
+	if err := db.Model(&models.FuturesStrategy{}).
+		Where("status IN ? AND paper_trading = ?", models.OpenStrategyStatuses, false).
+		Where("user_id NOT IN (?)", paperUsers).
+		Distinct().Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	collect(ids)
+
+	return result, nil
+}
+
+// unwrapExchange 去掉风控和行情装饰层，取得底层交易所实现
+func unwrapExchange(exchange Exchange) Exchange {
+	if wrapped, ok := exchange.(*preTradeExchange); ok {
+		exchange = wrapped.Exchange
+	}
+	if wrapped, ok := exchange.(*marketDataExchange); ok {
+		return wrapped.Exchange
+	}
+	return exchange
+}
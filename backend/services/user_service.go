@@ -140,13 +140,13 @@ func (us *UserService) UpdateUserAPIKeys(userID uint, apiKey, secretKey string)
 	shouldValidate := false // 暂时禁用自动验证，避免保存时出错
 
 	if shouldValidate {
-		binanceService, err := NewBinanceService(encryptedAPIKey, encryptedSecretKey)
+		exchange, err := NewExchange(encryptedAPIKey, encryptedSecretKey)
 		if err != nil {
 			// 如果创建服务失败，记录错误但继续保存
 			logrus.WithError(err).Warn("Failed to create Binance service for validation")
 		} else {
 			// 尝试验证，但不阻止保存
-			if err := binanceService.ValidateAPICredentials(context.Background()); err != nil {
+			if err := exchange.ValidateAPICredentials(context.Background()); err != nil {
 				// 记录验证失败，但仍然允许保存密钥
 				logrus.WithError(err).Warn("API validation failed. Keys will be saved but may not work")
 			} else {
@@ -194,6 +194,64 @@ func (us *UserService) GetUserAPIKeys(userID uint) (string, string, error) {
 	return "", "", errors.New("API密钥未设置，请在个人资料中配置API密钥，或联系管理员设置全局密钥")
 }
 
+// GetExchange 使用用户的API密钥创建真实交易所实例
+func (us *UserService) GetExchange(userID uint) (Exchange, error) {
+	apiKey, secretKey, err := us.GetUserAPIKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+	exchange, err := NewExchange(apiKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return WithMarketData(exchange), nil
+}
+
+// GetPaperExchange 创建用户的模拟交易所实例，有API密钥时使用用户行情通道，否则使用公开行情
+func (us *UserService) GetPaperExchange(userID uint) *PaperExchange {
+	market, err := us.GetExchange(userID)
+	if err != nil {
+		market, err = NewPublicExchange()
+		if err != nil {
+			logrus.WithError(err).WithField("user_id", userID).Warn("创建模拟交易行情服务失败，将使用记录价格")
+			market = nil
+		} else {
+			market = WithMarketData(market)
+		}
+	}
+	return NewPaperExchange(us.db, userID, market)
+}
+
+// GetTradingExchange 按交易模式创建交易所实例：策略或用户开启模拟交易时返回模拟交易所，
+// 返回的实例在下单前执行风控检查
+func (us *UserService) GetTradingExchange(userID uint, paperTrading bool) (Exchange, error) {
+	if !paperTrading {
+		var user models.User
+		if err := us.db.Select("id", "paper_trading").First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		paperTrading = user.PaperTrading
+	}
+
+	if paperTrading {
+		return WithPreTradeChecks(us.db, userID, true, us.GetPaperExchange(userID)), nil
+	}
+	exchange, err := us.GetExchange(userID)
+	if err != nil {
+		return nil, err
+	}
+	return WithPreTradeChecks(us.db, userID, false, exchange), nil
+}
+
+// IsPaperTrading 用户当前是否开启模拟交易，查询失败时按实盘处理
+func (us *UserService) IsPaperTrading(userID uint) bool {
+	var user models.User
+	if err := us.db.Select("id", "paper_trading").First(&user, userID).Error; err != nil {
+		return false
+	}
+	return user.PaperTrading
+}
+
 // ValidateUserAPIKeys 验证用户的API密钥是否有效
 func (us *UserService) ValidateUserAPIKeys(userID uint) error {
 	var user models.User
@@ -217,13 +275,13 @@ func (us *UserService) ValidateUserAPIKeys(userID uint) error {
 	}
 
 	// 创建币安服务实例
-	binanceService, err := NewBinanceService(decryptedAPIKey, decryptedSecretKey)
+	exchange, err := NewExchange(decryptedAPIKey, decryptedSecretKey)
 	if err != nil {
 		return fmt.Errorf("创建币安服务失败: %w", err)
 	}
 
 	// 验证API凭证
-	if err := binanceService.ValidateAPICredentials(context.Background()); err != nil {
+	if err := exchange.ValidateAPICredentials(context.Background()); err != nil {
 		return fmt.Errorf("API密钥验证失败: %w", err)
 	}
 
@@ -332,7 +390,7 @@ func (us *UserService) GetUserStats(userID uint) (map[string]interface{}, error)
 
 	us.db.Model(&models.Strategy{}).Where("user_id = ?", userID).Count(&strategiesCount)
 	us.db.Model(&models.Order{}).Where("user_id = ?", userID).Count(&ordersCount)
-	us.db.Model(&models.Strategy{}).Where("user_id = ? AND is_active = ?", userID, true).Count(&activeStrategiesCount)
+	us.db.Model(&models.Strategy{}).Where("user_id = ? AND status IN ?", userID, models.ActiveStrategyStatuses).Count(&activeStrategiesCount)
 
 	stats := map[string]interface{}{
 		"strategies_count":        strategiesCount,
@@ -142,12 +142,12 @@ func (ws *WithdrawalService) ExecuteWithdrawal(withdrawal *models.Withdrawal) er
 		return err
 	}
 
-	binanceService, err := NewBinanceService(apiKey, secretKey)
+	exchange, err := NewExchange(apiKey, secretKey)
 	if err != nil {
 		return err
 	}
 
-	account, err := binanceService.GetAccountInfo(context.Background())
+	account, err := exchange.GetAccountInfo(context.Background())
 	if err != nil {
 		return err
 	}
@@ -155,8 +155,7 @@ func (ws *WithdrawalService) ExecuteWithdrawal(withdrawal *models.Withdrawal) er
 	var currentBalance float64
 	for _, balance := range account.Balances {
 		if balance.Asset == withdrawal.Asset {
-			free, _ := strconv.ParseFloat(balance.Free, 64)
-			currentBalance = free
+			currentBalance = balance.Free
 			break
 		}
 	}
@@ -167,7 +166,7 @@ func (ws *WithdrawalService) ExecuteWithdrawal(withdrawal *models.Withdrawal) er
 
 	if withdrawal.TriggerPrice > 0 {
 		symbol := withdrawal.Asset + "USDT"
-		currentPrice, err := binanceService.GetPrice(context.Background(), symbol)
+		currentPrice, err := exchange.GetPrice(context.Background(), symbol)
 		if err != nil {
 			log.Printf("获取价格失败: %v", err)
 			return nil
@@ -178,7 +177,7 @@ func (ws *WithdrawalService) ExecuteWithdrawal(withdrawal *models.Withdrawal) er
 		}
 	}
 
-	resp, err := binanceService.Withdraw(context.Background(), withdrawal.Asset, withdrawal.Address, withdrawal.Network, withdrawal.Amount, "")
+	withdrawID, err := exchange.Withdraw(context.Background(), withdrawal.Asset, withdrawal.Address, withdrawal.Network, withdrawal.Amount, "")
 	if err != nil {
 		return err
 	}
@@ -190,7 +189,7 @@ func (ws *WithdrawalService) ExecuteWithdrawal(withdrawal *models.Withdrawal) er
 		Amount:       withdrawal.Amount,
 		Address:      withdrawal.Address,
 		Network:      withdrawal.Network,
-		TxID:         resp.ID,
+		TxID:         withdrawID,
 		Status:       "PENDING",
 		ApplyTime:    utils.GetCurrentTimestamp(),
 	}
@@ -242,26 +241,24 @@ func (ws *WithdrawalService) SyncWithdrawalHistory(userID uint) error {
 		return err
 	}
 
-	binanceService, err := NewBinanceService(apiKey, secretKey)
+	exchange, err := NewExchange(apiKey, secretKey)
 	if err != nil {
 		return err
 	}
-	withdrawals, err := binanceService.GetWithdrawHistory(context.Background(), "", 100)
+	withdrawals, err := exchange.GetWithdrawHistory(context.Background(), "", 100)
 	if err != nil {
 		return err
 	}
 
 	for _, withdraw := range withdrawals {
-		amount, _ := strconv.ParseFloat(withdraw.Amount, 64)
-		fee, _ := strconv.ParseFloat(withdraw.TransactionFee, 64)
 		applyTime, _ := strconv.ParseInt(withdraw.ApplyTime, 10, 64)
 		completeTime, _ := strconv.ParseInt(withdraw.CompleteTime, 10, 64)
 
 		history := &models.WithdrawalHistory{
 			UserID:       userID,
-			Asset:        withdraw.Coin,
-			Amount:       amount,
-			Fee:          fee,
+			Asset:        withdraw.Asset,
+			Amount:       withdraw.Amount,
+			Fee:          withdraw.Fee,
 			Address:      withdraw.Address,
 			Network:      withdraw.Network,
 			TxID:         withdraw.TxID,
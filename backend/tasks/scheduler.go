@@ -3,7 +3,7 @@ package tasks
 import (
 	"context"
 	"log"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ccj241/cctrade/config"
@@ -19,9 +19,21 @@ type Scheduler struct {
 	dualInvestmentService *services.DualInvestmentService
 	withdrawalService     *services.WithdrawalService
 	userService           *services.UserService
+	paperTradingService   *services.PaperTradingService
+	orderSyncService      *services.OrderSyncService
+	orderListService      *services.OrderListService
+	equityService         *services.EquityService
+	riskService           *services.RiskService
+	fundingService        *services.FundingService
+	marketDataHub         *services.MarketDataHub
+	userDataStream        *services.UserDataStreamManager
+
+	triggerMu     sync.Mutex
+	lastTriggered map[string]time.Time // 交易对最近一次由价格事件触发策略的时间
+	triggering    sync.Map             // 正在执行策略的交易对，事件触发与轮询兜底共用，避免同一交易对并发执行
 }
 
-func NewScheduler() *Scheduler {
+func NewScheduler(marketDataHub *services.MarketDataHub, userDataStream *services.UserDataStreamManager) *Scheduler {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Scheduler{
 		ctx:                   ctx,
@@ -31,6 +43,15 @@ func NewScheduler() *Scheduler {
 		dualInvestmentService: services.NewDualInvestmentService(),
 		withdrawalService:     services.NewWithdrawalService(),
 		userService:           services.NewUserService(),
+		paperTradingService:   services.NewPaperTradingService(),
+		orderSyncService:      services.NewOrderSyncService(),
+		orderListService:      services.NewOrderListService(),
+		equityService:         services.NewEquityService(),
+		riskService:           services.NewRiskService(),
+		fundingService:        services.NewFundingService(),
+		marketDataHub:         marketDataHub,
+		userDataStream:        userDataStream,
+		lastTriggered:         make(map[string]time.Time),
 	}
 }
 
@@ -42,6 +63,17 @@ func (s *Scheduler) Start() {
 	go s.withdrawalCheckTask()
 	go s.dualInvestmentTask()
 	go s.futuresMonitorTask()
+	go s.paperMatchingTask()
+	go s.equitySnapshotTask()
+	go s.riskMonitorTask()
+	go s.fundingSyncTask()
+
+	if s.marketDataHub != nil {
+		s.marketDataHub.Subscribe(s.onMarketEvent)
+	}
+	if s.userDataStream != nil {
+		s.userDataStream.Subscribe(s.onUserDataEvent)
+	}
 
 	log.Println("所有定时任务已启动")
 }
@@ -74,18 +106,24 @@ func (s *Scheduler) orderCheckTask() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
-	log.Println("订单检查任务已启动，每30秒检查一次")
+	log.Println("订单对账任务已启动，每30秒检查一次")
 
 	for {
 		select {
 		case <-s.ctx.Done():
-			log.Println("订单检查任务已停止")
+			log.Println("订单对账任务已停止")
 			return
 		case <-ticker.C:
 			if err := s.checkOrders(); err != nil {
 				log.Printf("检查订单失败: %v", err)
 			}
 
+			s.backfillTrades()
+
+			if err := s.orderListService.ProcessOrderLists(s.ctx); err != nil {
+				log.Printf("处理OCO和括号单失败: %v", err)
+			}
+
 			if err := s.executeActiveStrategies(); err != nil {
 				log.Printf("执行活跃策略失败: %v", err)
 			}
@@ -154,113 +192,199 @@ func (s *Scheduler) futuresMonitorTask() {
 			if err := s.updateAllPositions(); err != nil {
 				log.Printf("更新持仓信息失败: %v", err)
 			}
+
+			if err := s.futuresService.MonitorLiquidationRisk(); err != nil {
+				log.Printf("检查期货强平距离失败: %v", err)
+			}
+
+			if err := s.futuresService.ManageProtections(); err != nil {
+				log.Printf("处理期货止盈止损失败: %v", err)
+			}
+
+			if _, err := s.futuresService.CleanupOrphanReduceOnlyOrders(); err != nil {
+				log.Printf("清理孤儿只减仓订单失败: %v", err)
+			}
 		}
 	}
 }
 
-func (s *Scheduler) updatePrices() error {
-	symbols := []string{"BTCUSDT", "ETHUSDT", "BNBUSDT", "ADAUSDT", "DOTUSDT", "XRPUSDT", "LTCUSDT", "LINKUSDT"}
+func (s *Scheduler) paperMatchingTask() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
 
-	for _, symbol := range symbols {
-		var user models.User
-		if err := config.DB.Where("api_key != '' AND secret_key != ''").First(&user).Error; err != nil {
-			continue
-		}
+	log.Println("模拟撮合任务已启动，每10秒撮合一次")
 
-		apiKey, secretKey, err := s.userService.GetUserAPIKeys(user.ID)
-		if err != nil {
-			continue
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Println("模拟撮合任务已停止")
+			return
+		case <-ticker.C:
+			if err := s.paperTradingService.MatchOpenOrders(); err != nil {
+				log.Printf("撮合模拟订单失败: %v", err)
+			}
 		}
+	}
+}
 
-		binanceService, err := services.NewBinanceService(apiKey, secretKey)
-		if err != nil {
-			log.Printf("创建Binance服务失败: %v", err)
-			continue
-		}
+// equitySnapshotTask 定期记录账户权益快照，用于权益曲线和绩效指标
+func (s *Scheduler) equitySnapshotTask() {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
 
-		price, err := binanceService.GetPrice(context.Background(), symbol)
-		if err != nil {
-			log.Printf("获取%s价格失败: %v", symbol, err)
-			continue
-		}
+	log.Println("权益快照任务已启动，每1小时记录一次")
 
-		var priceModel models.Price
-		if err := config.DB.Where("symbol = ?", symbol).First(&priceModel).Error; err != nil {
-			priceModel = models.Price{
-				Symbol: symbol,
-				Price:  price,
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Println("权益快照任务已停止")
+			return
+		case <-ticker.C:
+			count, err := s.equityService.SnapshotAllUsers()
+			if err != nil {
+				log.Printf("记录权益快照失败: %v", err)
+				continue
 			}
-			config.DB.Create(&priceModel)
-		} else {
-			config.DB.Model(&priceModel).Update("price", price)
+			log.Printf("已记录%d个账户的权益快照", count)
 		}
+	}
+}
 
-		// 只在Redis可用时缓存价格
-		if config.Redis != nil {
-			ctx := context.Background()
-			config.Redis.Set(ctx, "price:"+symbol, price, 5*time.Minute)
+// riskMonitorTask 定期检查账户级风控限额，超限时停止策略、撤单并按设置平仓
+func (s *Scheduler) riskMonitorTask() {
+	interval := time.Minute
+	if config.AppConfig != nil && config.AppConfig.Risk.MonitorSeconds > 0 {
+		interval = time.Duration(config.AppConfig.Risk.MonitorSeconds) * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("账户风控监控任务已启动，每%v检查一次", interval)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Println("账户风控监控任务已停止")
+			return
+		case <-ticker.C:
+			breached, err := s.riskService.MonitorAccounts()
+			if err != nil {
+				log.Printf("检查账户风控失败: %v", err)
+				continue
+			}
+			if breached > 0 {
+				log.Printf("%d个账户触发风控熔断", breached)
+			}
 		}
 	}
+}
 
-	return nil
+// fundingSyncTask 同步资金费率和用户的期货资金流水
+func (s *Scheduler) fundingSyncTask() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	log.Println("资金费率同步任务已启动，每5分钟同步一次")
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			log.Println("资金费率同步任务已停止")
+			return
+		case <-ticker.C:
+			if _, err := s.fundingService.SyncFundingRates(s.ctx); err != nil {
+				log.Printf("同步资金费率失败: %v", err)
+			}
+
+			if err := s.fundingService.SyncAllIncomeHistory(s.ctx); err != nil {
+				log.Printf("同步期货资金流水失败: %v", err)
+			}
+		}
+	}
 }
 
-func (s *Scheduler) checkOrders() error {
-	var orders []models.Order
-	if err := config.DB.Where("status IN ?", []string{"NEW", "PARTIALLY_FILLED"}).Find(&orders).Error; err != nil {
+// updatePrices 为行情推送未覆盖或已失效的活跃交易对轮询价格，作为推送的兜底
+func (s *Scheduler) updatePrices() error {
+	active, err := services.ActiveMarketSymbols(config.DB)
+	if err != nil {
 		return err
 	}
 
-	for _, order := range orders {
-		apiKey, secretKey, err := s.userService.GetUserAPIKeys(order.UserID)
-		if err != nil {
+	var exchange services.Exchange
+	for _, symbol := range active[services.MarketSpot] {
+		if s.marketDataHub.IsLive(services.MarketSpot, symbol) {
 			continue
 		}
 
-		binanceService, err := services.NewBinanceService(apiKey, secretKey)
-		if err != nil {
-			log.Printf("创建Binance服务失败: %v", err)
-			continue
+		if exchange == nil {
+			exchange, err = services.NewPublicExchange()
+			if err != nil {
+				return err
+			}
 		}
 
-		orderStatus, err := binanceService.GetSpotOrderStatus(context.Background(), order.Symbol, order.OrderID)
+		price, err := exchange.GetPrice(context.Background(), symbol)
 		if err != nil {
-			log.Printf("检查订单状态失败: %v", err)
+			log.Printf("获取%s价格失败: %v", symbol, err)
 			continue
 		}
 
-		executedQty, _ := strconv.ParseFloat(orderStatus.ExecutedQuantity, 64)
-		cumulativeQuoteQty, _ := strconv.ParseFloat(orderStatus.CummulativeQuoteQuantity, 64)
-
-		config.DB.Model(&order).Updates(map[string]interface{}{
-			"status":               orderStatus.Status,
-			"executed_qty":         executedQty,
-			"cumulative_quote_qty": cumulativeQuoteQty,
-		})
-
-		// 如果订单关联了策略，并且是慢冰山策略，更新策略状态
-		if order.StrategyID != nil && (orderStatus.Status == "FILLED" || orderStatus.Status == "PARTIALLY_FILLED") {
-			var strategy models.Strategy
-			if err := config.DB.First(&strategy, *order.StrategyID).Error; err == nil {
-				if strategy.Type == models.StrategySlowIceberg && strategy.State != nil {
-					strategyState := strategy.State
-
-					// 更新已成交数量
-					if orderStatus.Status == "FILLED" {
-						// 订单完全成交，当前层已成交数量清零
-						strategyState["layer_filled_quantity"] = 0.0
-					} else if orderStatus.Status == "PARTIALLY_FILLED" {
-						// 部分成交，更新当前层已成交数量
-						currentLayerFilled, _ := strategyState["layer_filled_quantity"].(float64)
-						strategyState["layer_filled_quantity"] = currentLayerFilled + executedQty - order.ExecutedQty
-					}
-
-					// 更新总成交数量
-					totalFilled, _ := strategyState["total_filled_quantity"].(float64)
-					strategyState["total_filled_quantity"] = totalFilled + executedQty - order.ExecutedQty
-
-					// 保存策略状态
-					config.DB.Model(&strategy).Update("state", strategyState)
+		services.RecordPrice(config.DB, services.MarketSpot, symbol, price)
+	}
+
+	return nil
+}
+
+// checkOrders 轮询对账未完成订单：用户数据流在线时只检查长时间未更新的订单，离线或模拟订单全部检查
+func (s *Scheduler) checkOrders() error {
+	type openOrderOwner struct {
+		UserID      uint
+		IsSimulated bool
+	}
+
+	openStatuses := []string{"NEW", "PARTIALLY_FILLED"}
+
+	var spotOwners []openOrderOwner
+	if err := config.DB.Model(&models.Order{}).Select("DISTINCT user_id, is_simulated").
+		Where("status IN ?", openStatuses).Scan(&spotOwners).Error; err != nil {
+		return err
+	}
+
+	var futuresUserIDs []uint
+	if err := config.DB.Model(&models.FuturesOrder{}).
+		Where("status IN ? AND is_simulated = ?", openStatuses, false).
+		Distinct().Pluck("user_id", &futuresUserIDs).Error; err != nil {
+		return err
+	}
+
+	var futuresOwners []openOrderOwner
+	for _, userID := range futuresUserIDs {
+		futuresOwners = append(futuresOwners, openOrderOwner{UserID: userID})
+	}
+
+	for market, owners := range map[services.MarketType][]openOrderOwner{
+		services.MarketSpot:    spotOwners,
+		services.MarketFutures: futuresOwners,
+	} {
+		for _, owner := range owners {
+			var exchange services.Exchange
+			var staleAfter time.Duration
+			if owner.IsSimulated {
+				exchange = s.userService.GetPaperExchange(owner.UserID)
+			} else {
+				var err error
+				exchange, err = s.userService.GetExchange(owner.UserID)
+				if err != nil {
+					continue
+				}
+				if s.userDataStream.IsLive(owner.UserID, market) {
+					staleAfter = s.userDataStream.ReconcileInterval()
+				}
+			}
+
+			for _, applied := range s.orderSyncService.ReconcileOpenOrders(owner.UserID, market, owner.IsSimulated, exchange, staleAfter) {
+				if applied.FilledDelta > 0 && !owner.IsSimulated {
+					log.Printf("对账发现用户%d订单%s有未推送的成交，状态%s", owner.UserID, applied.OrderID, applied.Status)
 				}
 			}
 		}
@@ -269,16 +393,42 @@ func (s *Scheduler) checkOrders() error {
 	return nil
 }
 
+// backfillTrades 为轮询发现成交但缺少成交明细的订单补录trades
+func (s *Scheduler) backfillTrades() {
+	exchangeFor := func(userID uint, simulated bool) (services.Exchange, error) {
+		if simulated {
+			return s.userService.GetPaperExchange(userID), nil
+		}
+		return s.userService.GetExchange(userID)
+	}
+
+	for _, market := range []services.MarketType{services.MarketSpot, services.MarketFutures} {
+		if count := s.orderSyncService.BackfillTrades(market, 50, exchangeFor); count > 0 {
+			log.Printf("已补录%d个%s订单的成交明细", count, market)
+		}
+	}
+}
+
 func (s *Scheduler) executeActiveStrategies() error {
 	var strategies []models.Strategy
-	if err := config.DB.Where("is_active = ? AND is_completed = ?", true, false).Find(&strategies).Error; err != nil {
+	if err := config.DB.Where("status IN ?", models.ScheduledStrategyStatuses).Find(&strategies).Error; err != nil {
 		return err
 	}
 
 	for _, strategy := range strategies {
+		// 行情推送正常的交易对由价格事件触发，这里只做兜底
+		if s.marketDataHub.IsLive(services.MarketSpot, strategy.Symbol) {
+			continue
+		}
+
+		// 事件触发正在执行该交易对时跳过，下一轮再兜底
+		if !s.acquireSymbol(services.MarketSpot, strategy.Symbol) {
+			continue
+		}
 		if err := s.strategyService.ExecuteStrategy(&strategy); err != nil {
 			log.Printf("执行策略%d失败: %v", strategy.ID, err)
 		}
+		s.releaseSymbol(services.MarketSpot, strategy.Symbol)
 	}
 
 	return nil
@@ -286,16 +436,153 @@ func (s *Scheduler) executeActiveStrategies() error {
 
 func (s *Scheduler) executeActiveFuturesStrategies() error {
 	var strategies []models.FuturesStrategy
-	if err := config.DB.Where("is_active = ? AND is_completed = ?", true, false).Find(&strategies).Error; err != nil {
+	if err := config.DB.Where("status IN ?", models.ScheduledStrategyStatuses).Find(&strategies).Error; err != nil {
 		return err
 	}
 
 	for _, strategy := range strategies {
+		if s.marketDataHub.IsLive(services.MarketFutures, strategy.Symbol) {
+			continue
+		}
+
+		if !s.acquireSymbol(services.MarketFutures, strategy.Symbol) {
+			continue
+		}
 		if err := s.futuresService.ExecuteFuturesStrategy(&strategy); err != nil {
 			log.Printf("执行期货策略%d失败: %v", strategy.ID, err)
 		}
+		s.releaseSymbol(services.MarketFutures, strategy.Symbol)
+	}
+
+	return nil
+}
+
+// acquireSymbol 取得交易对的执行权，已有调用方在执行该交易对的策略时返回false
+func (s *Scheduler) acquireSymbol(market services.MarketType, symbol string) bool {
+	_, running := s.triggering.LoadOrStore(string(market)+":"+symbol, true)
+	return !running
+}
+
+// releaseSymbol 释放交易对的执行权
+func (s *Scheduler) releaseSymbol(market services.MarketType, symbol string) {
+	s.triggering.Delete(string(market) + ":" + symbol)
+}
+
+// onMarketEvent 价格事件到达时触发该交易对上的活跃策略，同一交易对按配置的最小间隔节流
+func (s *Scheduler) onMarketEvent(event *services.MarketEvent) {
+	if event.Price <= 0 {
+		return
+	}
+
+	interval := time.Second
+	if config.AppConfig != nil && config.AppConfig.Market.TriggerInterval > 0 {
+		interval = time.Duration(config.AppConfig.Market.TriggerInterval) * time.Millisecond
+	}
+
+	key := string(event.Market) + ":" + event.Symbol
+	now := time.Now()
+
+	s.triggerMu.Lock()
+	if now.Sub(s.lastTriggered[key]) < interval {
+		s.triggerMu.Unlock()
+		return
 	}
+	s.lastTriggered[key] = now
+	s.triggerMu.Unlock()
 
+	if !s.acquireSymbol(event.Market, event.Symbol) {
+		return
+	}
+
+	go func() {
+		defer s.releaseSymbol(event.Market, event.Symbol)
+
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err := s.triggerStrategies(event.Market, event.Symbol); err != nil {
+			log.Printf("价格事件触发%s策略失败: %v", event.Symbol, err)
+		}
+	}()
+}
+
+// onUserDataEvent 策略订单有新成交时立即触发该交易对上的策略，不受价格事件节流限制
+func (s *Scheduler) onUserDataEvent(event *services.UserDataEvent) {
+	applied := event.Applied
+	if applied == nil || applied.FilledDelta <= 0 {
+		return
+	}
+
+	// 手动下单的成交可能是括号单入场或止盈止损成交
+	if applied.StrategyID == nil {
+		if applied.Market == services.MarketSpot {
+			go func() {
+				if err := s.orderListService.ProcessOrderLists(s.ctx); err != nil {
+					log.Printf("处理OCO和括号单失败: %v", err)
+				}
+			}()
+		}
+		return
+	}
+
+	// 期货策略入场或保护单成交后立即联动止盈止损
+	if applied.Market == services.MarketFutures {
+		strategyID := *applied.StrategyID
+		go func() {
+			if err := s.futuresService.ManageStrategyProtection(strategyID); err != nil {
+				log.Printf("处理期货策略%d止盈止损失败: %v", strategyID, err)
+			}
+		}()
+	}
+
+	if !s.acquireSymbol(applied.Market, applied.Symbol) {
+		return
+	}
+
+	go func() {
+		defer s.releaseSymbol(applied.Market, applied.Symbol)
+
+		if s.ctx.Err() != nil {
+			return
+		}
+		if err := s.triggerStrategies(applied.Market, applied.Symbol); err != nil {
+			log.Printf("成交事件触发%s策略失败: %v", applied.Symbol, err)
+		}
+	}()
+}
+
+// triggerStrategies 执行指定交易对上的活跃策略
+func (s *Scheduler) triggerStrategies(market services.MarketType, symbol string) error {
+	if market == services.MarketFutures {
+		var strategies []models.FuturesStrategy
+		if err := config.DB.Where("symbol = ? AND status IN ?", symbol, models.ScheduledStrategyStatuses).Find(&strategies).Error; err != nil {
+			return err
+		}
+
+		for _, strategy := range strategies {
+			if err := s.futuresService.ExecuteFuturesStrategy(&strategy); err != nil {
+				log.Printf("执行期货策略%d失败: %v", strategy.ID, err)
+			}
+		}
+		return nil
+	}
+
+	var strategies []models.Strategy
+	if err := config.DB.Where("symbol = ? AND status IN ?", symbol, models.ScheduledStrategyStatuses).Find(&strategies).Error; err != nil {
+		return err
+	}
+	// 加权评分策略还对配置中的其他交易对评分
+	scoring, err := services.ConfigSymbolStrategies(config.DB, symbol)
+	if err != nil {
+		return err
+	}
+	strategies = append(strategies, scoring...)
+
+	for _, strategy := range strategies {
+		if err := s.strategyService.ExecuteStrategy(&strategy); err != nil {
+			log.Printf("执行策略%d失败: %v", strategy.ID, err)
+		}
+	}
 	return nil
 }
 